@@ -0,0 +1,224 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingress_filter_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/openconfig/featureprofiles/internal/acl"
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1 and
+// dut:port2 -> ate:port2.
+//
+//   - ate:port1 -> dut:port1 subnet 192.0.2.0/30
+//   - ate:port2 -> dut:port2 subnet 192.0.2.4/30
+//
+// Traffic is sent from the permitted and denied source networks added
+// to ate:port1 to ate:port2, through the ACL applied to the packets
+// received by dut:port1.
+const (
+	ipv4PrefixLen = 30
+
+	permitNetName = "permitNet"
+	permitPrefix  = "198.51.100.0/24"
+	denyNetName   = "denyNet"
+	denyPrefix    = "203.0.113.0/24"
+
+	aclName = "INGRESS-FILTER"
+	// permitID, denyID and denyAllID are the sequence numbers of the
+	// entries of the ACL.
+	permitID  = 10
+	denyID    = 20
+	denyAllID = 30
+
+	// counterTolerancePct is how many percent the matched-packets
+	// counter of an ACL entry may differ from the packets the ATE sent
+	// that match it.
+	counterTolerancePct = 1
+)
+
+var (
+	dutPort1 = attrs.Attributes{
+		Desc:    "dutPort1",
+		IPv4:    "192.0.2.1",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort1 = attrs.Attributes{
+		Name:    "atePort1",
+		IPv4:    "192.0.2.2",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	dutPort2 = attrs.Attributes{
+		Desc:    "dutPort2",
+		IPv4:    "192.0.2.5",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort2 = attrs.Attributes{
+		Name:    "atePort2",
+		IPv4:    "192.0.2.6",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	// filter permits packets from permitPrefix and denies those from
+	// denyPrefix, and explicitly denies all others.
+	filter = &acl.Set{
+		Name: aclName,
+		Type: telemetry.Acl_ACL_TYPE_ACL_IPV4,
+		Entries: []*acl.Entry{
+			{SequenceID: permitID, Match: acl.Match{SrcPrefix: permitPrefix}, Action: telemetry.Acl_FORWARDING_ACTION_ACCEPT},
+			{SequenceID: denyID, Match: acl.Match{SrcPrefix: denyPrefix}, Action: telemetry.Acl_FORWARDING_ACTION_DROP},
+			{SequenceID: denyAllID, Action: telemetry.Acl_FORWARDING_ACTION_DROP},
+		},
+	}
+)
+
+// configureDUT configures port1 and port2 on the DUT.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	d := dut.Config()
+	for _, p := range []struct {
+		id    string
+		attrs *attrs.Attributes
+	}{
+		{"port1", &dutPort1},
+		{"port2", &dutPort2},
+	} {
+		dp := dut.Port(t, p.id)
+		i := p.attrs.NewInterface(dp.Name())
+		d.Interface(dp.Name()).Replace(t, i)
+		fptest.LogYgot(t, dp.String(), d.Interface(dp.Name()), i)
+	}
+}
+
+// configureACL applies the filter ACL to the packets received by
+// dut:port1.
+func configureACL(t *testing.T, dut *ondatra.DUTDevice) {
+	a, err := acl.Build([]*acl.Set{filter})
+	if err != nil {
+		t.Fatalf("Cannot build ACL: %v", err)
+	}
+	acl.AttachIngress(a, dut.Port(t, "port1").Name(), filter)
+	acl.Configure(t, dut, a)
+}
+
+// configureATE configures port1, with the permitted and denied source
+// networks, and port2 on the ATE.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice, dut *ondatra.DUTDevice) *ondatra.ATETopology {
+	top := ate.Topology().New()
+	i1 := atePort1.AddToATE(top, ate.Port(t, "port1"), &dutPort1)
+	i1.AddNetwork(permitNetName).IPv4().WithAddress(permitPrefix)
+	i1.AddNetwork(denyNetName).IPv4().WithAddress(denyPrefix)
+	atePort2.AddToATE(top, ate.Port(t, "port2"), &dutPort2)
+	traffic.StartProtocolsAndAwait(t, ate, top, &traffic.Readiness{
+		DUT: dut,
+		Neighbors: map[string]*attrs.Attributes{
+			"port1": &atePort1,
+			"port2": &atePort2,
+		},
+	})
+	return top
+}
+
+// checkMatched checks that the matched-packets counter of the ACL entry
+// id incremented by the packets the flow sent within
+// counterTolerancePct, and that the counters of the other entries did
+// not increment by more than the tolerance.
+func checkMatched(t *testing.T, before, after map[uint32]uint64, id uint32, r *traffic.Result) {
+	t.Helper()
+	tolerance := uint64(math.Ceil(float64(r.OutPkts) * counterTolerancePct / 100))
+	for _, e := range filter.Entries {
+		b, okB := before[e.SequenceID]
+		a, okA := after[e.SequenceID]
+		if !okB || !okA {
+			t.Errorf("DUT does not report matched-packets of ACL %s entry %d", filter.Name, e.SequenceID)
+			continue
+		}
+		got := a - b
+		t.Logf("ACL %s entry %d matched %d packets", filter.Name, e.SequenceID, got)
+		want := uint64(0)
+		if e.SequenceID == id {
+			want = r.OutPkts
+		}
+		if got+tolerance < want || got > want+tolerance {
+			t.Errorf("ACL %s entry %d matched-packets incremented by %d, want %d within %d%%", filter.Name, e.SequenceID, got, want, counterTolerancePct)
+		}
+	}
+}
+
+func TestIngressFilter(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	configureDUT(t, dut)
+	configureACL(t, dut)
+	defer acl.Delete(t, dut)
+
+	ate := ondatra.ATE(t, "ate")
+	top := configureATE(t, ate, dut)
+	defer top.StopProtocols(t)
+
+	intf := dut.Port(t, "port1").Name()
+	newFlow := func(name, srcNet string) *ondatra.Flow {
+		return traffic.NewIPv4Flow(t, ate, top, &traffic.FlowParams{
+			Name:       name,
+			Src:        &atePort1,
+			SrcNetwork: srcNet,
+			Dst:        &atePort2,
+		})
+	}
+	permitFlow := newFlow("Permit", permitNetName)
+	denyFlow := newFlow("Deny", denyNetName)
+
+	t.Run("Attached", func(t *testing.T) {
+		s := dut.Telemetry().Acl().Interface(intf).IngressAclSet(filter.Name, filter.Type).Get(t)
+		if got := s.GetSetName(); got != filter.Name {
+			t.Errorf("DUT ingress ACL set of %s got %q, want %q", intf, got, filter.Name)
+		}
+	})
+
+	t.Run("Permitted", func(t *testing.T) {
+		before := acl.IngressMatchedPackets(t, dut, intf, filter)
+		r := traffic.ValidateFlow(t, ate, permitFlow, nil)
+		after := acl.IngressMatchedPackets(t, dut, intf, filter)
+		checkMatched(t, before, after, permitID, r)
+	})
+
+	t.Run("Denied", func(t *testing.T) {
+		before := acl.IngressMatchedPackets(t, dut, intf, filter)
+		r := traffic.ValidateFlow(t, ate, denyFlow, &traffic.Options{WantLoss: true})
+		after := acl.IngressMatchedPackets(t, dut, intf, filter)
+		checkMatched(t, before, after, denyID, r)
+	})
+
+	t.Run("Removed", func(t *testing.T) {
+		acl.Delete(t, dut)
+		traffic.ValidateFlow(t, ate, denyFlow, nil)
+		traffic.ValidateFlow(t, ate, permitFlow, nil)
+	})
+}
@@ -0,0 +1,238 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package md5_auth_test
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/bgp"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+)
+
+var (
+	maxRotationOutage = flag.Duration("max_rotation_outage", 30*time.Second,
+		"Maximum traffic outage while the MD5 password is rotated on the DUT and then on the ATE.")
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1 and
+// dut:port2 -> ate:port2.
+//
+//   - ate:port1 -> dut:port1 subnet 192.0.2.0/30
+//   - ate:port2 -> dut:port2 subnet 192.0.2.4/30
+//
+// ate:port1 is an eBGP peer of the DUT authenticated with TCP MD5, and
+// advertises ateNetCIDR, to which traffic is sent from ate:port2.
+const (
+	ipv4PrefixLen = 30
+
+	dutAS = 64500
+	ateAS = 64501
+
+	ateNetName = "bgpNet"
+	ateNetCIDR = "203.0.113.0/24"
+
+	md5Key      = "fp-md5-key"
+	wrongMD5Key = "fp-wrong-key"
+	rotatedKey  = "fp-rotated-key"
+
+	// failDeadline is how long the session must stay down with a
+	// mismatched password.
+	failDeadline = 2 * time.Minute
+	// rotationDelay is how long after the DUT the ATE changes its
+	// password.
+	rotationDelay = 5 * time.Second
+
+	frameRate    = 1000 // frames per second
+	routeTimeout = time.Minute
+	settleTime   = 10 * time.Second
+)
+
+var (
+	dutPort1 = attrs.Attributes{
+		Desc:    "dutPort1",
+		IPv4:    "192.0.2.1",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort1 = attrs.Attributes{
+		Name:    "atePort1",
+		IPv4:    "192.0.2.2",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	dutPort2 = attrs.Attributes{
+		Desc:    "dutPort2",
+		IPv4:    "192.0.2.5",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort2 = attrs.Attributes{
+		Name:    "atePort2",
+		IPv4:    "192.0.2.6",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	// atePeer is the eBGP speaker on ate:port1, whose password is
+	// changed by the test.
+	atePeer = &bgp.ATEPeer{
+		ATE:    &atePort1,
+		DUT:    &dutPort1,
+		AS:     ateAS,
+		Routes: []*bgp.Routes{{Name: ateNetName, CIDR: ateNetCIDR}},
+		MD5Key: md5Key,
+	}
+)
+
+// configureDUT configures port1 and port2 and the BGP neighbor to
+// ate:port1 with the MD5 password on the DUT.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	d := dut.Config()
+
+	p1 := dut.Port(t, "port1")
+	d.Interface(p1.Name()).Replace(t, dutPort1.NewInterface(p1.Name()))
+
+	p2 := dut.Port(t, "port2")
+	d.Interface(p2.Name()).Replace(t, dutPort2.NewInterface(p2.Name()))
+
+	bgp.ConfigureDUT(t, dut, bgp.DUTConfig(dutPort1.IPv4, dutAS, atePeer.Neighbors()...))
+}
+
+// configureATE configures port1 and port2 on the ATE, with port1
+// peering with the DUT and advertising the destination network.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice) *ondatra.ATETopology {
+	top := ate.Topology().New()
+	i1 := atePort1.AddToATE(top, ate.Port(t, "port1"), &dutPort1)
+	atePeer.AddToATE(t, i1)
+	atePort2.AddToATE(top, ate.Port(t, "port2"), &dutPort2)
+	return top
+}
+
+// awaitNotEstablished waits for the session to the ATE to go down.
+func awaitNotEstablished(t *testing.T, dut *ondatra.DUTDevice) bool {
+	nbrPath := dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).
+		Protocol(telemetry.PolicyTypes_INSTALL_PROTOCOL_TYPE_BGP, bgp.ProtocolName).Bgp().Neighbor(atePort1.IPv4)
+	_, ok := fptest.AwaitFunc[telemetry.E_Bgp_Neighbor_SessionState](t, nbrPath.SessionState().Watch, routeTimeout, "session not ESTABLISHED",
+		func(q *telemetry.QualifiedE_Bgp_Neighbor_SessionState) bool {
+			return q.IsPresent() && q.Val(t) != telemetry.Bgp_Neighbor_SessionState_ESTABLISHED
+		})
+	return ok
+}
+
+// bounce restarts the session of the ATE, waiting for the DUT to see it
+// go down.
+func bounce(t *testing.T, dut *ondatra.DUTDevice, top *ondatra.ATETopology) {
+	atePeer.StopSessions(t, top)
+	if !awaitNotEstablished(t, dut) {
+		t.FailNow()
+	}
+	atePeer.StartSessions(t, top)
+}
+
+func TestMD5Authentication(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	ate := ondatra.ATE(t, "ate")
+
+	configureDUT(t, dut)
+	defer bgp.DeleteDUT(t, dut)
+	top := configureATE(t, ate)
+	bgp.StartATEPeers(t, dut, top, atePeer)
+	defer top.StopProtocols(t)
+
+	nbrPath := dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).
+		Protocol(telemetry.PolicyTypes_INSTALL_PROTOCOL_TYPE_BGP, bgp.ProtocolName).Bgp().Neighbor(atePort1.IPv4)
+	aftPrefix := dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).Afts().Ipv4Entry(ateNetCIDR).Prefix()
+
+	t.Run("MatchingPassword", func(t *testing.T) {
+		if got, want := nbrPath.SessionState().Get(t), telemetry.Bgp_Neighbor_SessionState_ESTABLISHED; got != want {
+			t.Errorf("Neighbor %s session-state got %v, want %v", atePort1.IPv4, got, want)
+		}
+		fptest.Await(t, aftPrefix.Watch, routeTimeout, ateNetCIDR)
+	})
+
+	t.Run("WrongPassword", func(t *testing.T) {
+		atePeer.SetMD5Key(t, top, wrongMD5Key)
+		bounce(t, dut, top)
+		if _, ok := nbrPath.SessionState().Watch(t, failDeadline, func(q *telemetry.QualifiedE_Bgp_Neighbor_SessionState) bool {
+			return q.IsPresent() && q.Val(t) == telemetry.Bgp_Neighbor_SessionState_ESTABLISHED
+		}).Await(t); ok {
+			t.Errorf("BGP session to %s established within %v with a wrong MD5 password", atePort1.IPv4, failDeadline)
+		}
+		switch got := nbrPath.SessionState().Get(t); got {
+		case telemetry.Bgp_Neighbor_SessionState_IDLE, telemetry.Bgp_Neighbor_SessionState_CONNECT, telemetry.Bgp_Neighbor_SessionState_ACTIVE:
+			t.Logf("Neighbor %s session-state %v with a wrong MD5 password", atePort1.IPv4, got)
+		default:
+			t.Errorf("Neighbor %s session-state got %v with a wrong MD5 password, want IDLE, CONNECT or ACTIVE", atePort1.IPv4, got)
+		}
+
+		atePeer.SetMD5Key(t, top, md5Key)
+		bounce(t, dut, top)
+		if !bgp.AwaitEstablished(t, dut, atePort1.IPv4, bgp.EstablishTimeout) {
+			t.Fatalf("BGP session to %s is not re-established within %v after restoring the MD5 password", atePort1.IPv4, bgp.EstablishTimeout)
+		}
+	})
+
+	t.Run("KeyRotation", func(t *testing.T) {
+		if !fptest.Await(t, aftPrefix.Watch, routeTimeout, ateNetCIDR) {
+			t.FailNow()
+		}
+		bg := traffic.StartBackground(t, ate, traffic.NewIPv4Flow(t, ate, top, &traffic.FlowParams{
+			Name:       "KeyRotation",
+			Src:        &atePort2,
+			Dst:        &atePort1,
+			DstNetwork: ateNetName,
+			DUT:        dut,
+			SrcPort:    dut.Port(t, "port2"),
+			DstPort:    dut.Port(t, "port1"),
+		}), frameRate)
+		time.Sleep(settleTime)
+
+		transitions := nbrPath.EstablishedTransitions().Get(t)
+		rotateTime := time.Now()
+		dut.Config().NetworkInstance(*deviations.DefaultNetworkInstance).
+			Protocol(telemetry.PolicyTypes_INSTALL_PROTOCOL_TYPE_BGP, bgp.ProtocolName).Bgp().
+			Neighbor(atePort1.IPv4).AuthPassword().Replace(t, rotatedKey)
+		time.Sleep(rotationDelay)
+		atePeer.SetMD5Key(t, top, rotatedKey)
+		time.Sleep(settleTime)
+
+		if !bgp.AwaitEstablished(t, dut, atePort1.IPv4, bgp.EstablishTimeout) {
+			t.Errorf("BGP session to %s is not established within %v after rotating the MD5 password", atePort1.IPv4, bgp.EstablishTimeout)
+		}
+		fptest.Await(t, aftPrefix.Watch, routeTimeout, ateNetCIDR)
+		time.Sleep(settleTime)
+		bg.Stop(t)
+
+		t.Logf("Neighbor %s established-transitions went from %d to %d during the rotation", atePort1.IPv4, transitions, nbrPath.EstablishedTransitions().Get(t))
+		outage := bg.OutageBetween(rotateTime, time.Now())
+		t.Logf("Rotating the MD5 password lost %d packets, i.e. %v outage", outage.LostPkts, outage.Duration)
+		if outage.Duration > *maxRotationOutage {
+			t.Errorf("Outage while rotating the MD5 password got %v, want at most %v", outage.Duration, *maxRotationOutage)
+		}
+	})
+}
@@ -0,0 +1,290 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session_test
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/bgp"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1 and
+// dut:port2 -> ate:port2.
+//
+//   - ate:port1 -> dut:port1 subnet 192.0.2.0/30 2001:db8::192:0:2:0/126
+//   - ate:port2 -> dut:port2 subnet 192.0.2.4/30 2001:db8::192:0:2:4/126
+//
+// ate:port1 is an eBGP peer of the DUT over IPv4 and IPv6, and
+// advertises routeCount prefixes of each address family.  Traffic is
+// sent from ate:port2 to the advertised prefixes.
+const (
+	ipv4PrefixLen = 30
+	ipv6PrefixLen = 126
+
+	dutAS = 64500
+	ateAS = 64501
+
+	routeCount  = 10
+	ateNetIPv4  = "bgpNetIPv4"
+	ateNetIPv6  = "bgpNetIPv6"
+	ateNetCIDR4 = "203.0.113.0/28"
+	ateNetCIDR6 = "2001:db8:1::/64"
+
+	routeTimeout = time.Minute
+)
+
+var (
+	dutPort1 = attrs.Attributes{
+		Desc:    "dutPort1",
+		IPv4:    "192.0.2.1",
+		IPv6:    "2001:db8::192:0:2:1",
+		IPv4Len: ipv4PrefixLen,
+		IPv6Len: ipv6PrefixLen,
+	}
+
+	atePort1 = attrs.Attributes{
+		Name:    "atePort1",
+		IPv4:    "192.0.2.2",
+		IPv6:    "2001:db8::192:0:2:2",
+		IPv4Len: ipv4PrefixLen,
+		IPv6Len: ipv6PrefixLen,
+	}
+
+	dutPort2 = attrs.Attributes{
+		Desc:    "dutPort2",
+		IPv4:    "192.0.2.5",
+		IPv6:    "2001:db8::192:0:2:5",
+		IPv4Len: ipv4PrefixLen,
+		IPv6Len: ipv6PrefixLen,
+	}
+
+	atePort2 = attrs.Attributes{
+		Name:    "atePort2",
+		IPv4:    "192.0.2.6",
+		IPv6:    "2001:db8::192:0:2:6",
+		IPv4Len: ipv4PrefixLen,
+		IPv6Len: ipv6PrefixLen,
+	}
+
+	// atePeer is the eBGP speaker on ate:port1, with a session for each
+	// address family.
+	atePeer = &bgp.ATEPeer{
+		ATE: &atePort1,
+		DUT: &dutPort1,
+		AS:  ateAS,
+		Routes: []*bgp.Routes{
+			{Name: ateNetIPv4, CIDR: ateNetCIDR4, Count: routeCount},
+			{Name: ateNetIPv6, CIDR: ateNetCIDR6, Count: routeCount},
+		},
+	}
+)
+
+// configureDUT configures port1 and port2 and the BGP neighbors to
+// ate:port1 on the DUT.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	d := dut.Config()
+
+	p1 := dut.Port(t, "port1")
+	d.Interface(p1.Name()).Replace(t, dutPort1.NewInterface(p1.Name()))
+
+	p2 := dut.Port(t, "port2")
+	d.Interface(p2.Name()).Replace(t, dutPort2.NewInterface(p2.Name()))
+
+	bgp.ConfigureDUT(t, dut, bgp.DUTConfig(dutPort1.IPv4, dutAS, atePeer.Neighbors()...))
+}
+
+// deleteBGP removes the BGP config from the DUT, and checks that none
+// is left behind.
+func deleteBGP(t *testing.T, dut *ondatra.DUTDevice) {
+	bgp.DeleteDUT(t, dut)
+	p := dut.Config().NetworkInstance(*deviations.DefaultNetworkInstance).
+		Protocol(telemetry.PolicyTypes_INSTALL_PROTOCOL_TYPE_BGP, bgp.ProtocolName).Bgp()
+	if q := p.Lookup(t); q.IsPresent() {
+		fptest.LogYgot(t, "DUT BGP after delete", p, q.Val(t))
+		t.Errorf("BGP config is present after delete, want none")
+	}
+}
+
+// configureATE configures port1 and port2 on the ATE, with port1
+// peering with the DUT and advertising the routes.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice) *ondatra.ATETopology {
+	top := ate.Topology().New()
+	i1 := atePort1.AddToATE(top, ate.Port(t, "port1"), &dutPort1)
+	atePeer.AddToATE(t, i1)
+	atePort2.AddToATE(top, ate.Port(t, "port2"), &dutPort2)
+	return top
+}
+
+// prefixes returns the count consecutive prefixes starting at cidr, as
+// advertised by bgp.Routes.
+func prefixes(cidr string, count int) ([]string, error) {
+	_, n, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	ones, _ := n.Mask.Size()
+	if ones == 0 {
+		return nil, fmt.Errorf("cannot advertise consecutive prefixes of %s", cidr)
+	}
+	var pfxs []string
+	ip := n.IP
+	for i := 0; i < count; i++ {
+		pfxs = append(pfxs, fmt.Sprintf("%s/%d", ip, ones))
+		ip = nextPrefix(ip, ones)
+	}
+	return pfxs, nil
+}
+
+// nextPrefix returns the address of the prefix of length ones following
+// the one at ip.
+func nextPrefix(ip net.IP, ones int) net.IP {
+	next := append(net.IP(nil), ip...)
+	bit := ones - 1
+	inc := byte(1) << (7 - bit%8)
+	for i := bit / 8; i >= 0; i-- {
+		old := next[i]
+		next[i] += inc
+		if next[i] > old {
+			break
+		}
+		inc = 1
+	}
+	return next
+}
+
+// verifySession checks that the session to the neighbor is established
+// with the expected capabilities and address family negotiated.
+func verifySession(t *testing.T, dut *ondatra.DUTDevice, addr string, afiSafi telemetry.E_BgpTypes_AFI_SAFI_TYPE) {
+	nbrPath := dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).
+		Protocol(telemetry.PolicyTypes_INSTALL_PROTOCOL_TYPE_BGP, bgp.ProtocolName).Bgp().Neighbor(addr)
+	if got, want := nbrPath.SessionState().Get(t), telemetry.Bgp_Neighbor_SessionState_ESTABLISHED; got != want {
+		t.Errorf("Neighbor %s session-state got %v, want %v", addr, got, want)
+	}
+
+	capabilities := map[telemetry.E_BgpTypes_BGP_CAPABILITY]bool{
+		telemetry.BgpTypes_BGP_CAPABILITY_ROUTE_REFRESH: false,
+		telemetry.BgpTypes_BGP_CAPABILITY_ASN32:         false,
+		telemetry.BgpTypes_BGP_CAPABILITY_MPBGP:         false,
+	}
+	for _, c := range nbrPath.SupportedCapabilities().Get(t) {
+		capabilities[c] = true
+	}
+	for c, present := range capabilities {
+		if !present {
+			t.Errorf("Neighbor %s capability not reported: %v", addr, c)
+		}
+	}
+
+	if !nbrPath.AfiSafi(afiSafi).Active().Get(t) {
+		t.Errorf("Neighbor %s afi-safi %v active got false, want true", addr, afiSafi)
+	}
+}
+
+// verifyRoutes checks that the DUT received and installed the routes
+// from the neighbor, and that they are programmed in the AFT.  Awaiting
+// each AFT entry reports its own error.
+func verifyRoutes(t *testing.T, dut *ondatra.DUTDevice, addr string, afiSafi telemetry.E_BgpTypes_AFI_SAFI_TYPE, cidr string) {
+	ni := dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance)
+	prefixesPath := ni.Protocol(telemetry.PolicyTypes_INSTALL_PROTOCOL_TYPE_BGP, bgp.ProtocolName).Bgp().
+		Neighbor(addr).AfiSafi(afiSafi).Prefixes()
+	compare := func(val *telemetry.QualifiedUint32) bool {
+		return val.IsPresent() && val.Val(t) == routeCount
+	}
+	if got, ok := prefixesPath.Received().Watch(t, routeTimeout, compare).Await(t); !ok {
+		t.Errorf("Neighbor %s received prefixes got %v, want %d", addr, got, routeCount)
+	}
+	if got, ok := prefixesPath.Installed().Watch(t, routeTimeout, compare).Await(t); !ok {
+		t.Errorf("Neighbor %s installed prefixes got %v, want %d", addr, got, routeCount)
+	}
+
+	pfxs, err := prefixes(cidr, routeCount)
+	if err != nil {
+		t.Fatalf("Cannot list the advertised prefixes: %v", err)
+	}
+	for _, pfx := range pfxs {
+		if afiSafi == telemetry.BgpTypes_AFI_SAFI_TYPE_IPV6_UNICAST {
+			fptest.Await(t, ni.Afts().Ipv6Entry(pfx).Prefix().Watch, routeTimeout, pfx)
+		} else {
+			fptest.Await(t, ni.Afts().Ipv4Entry(pfx).Prefix().Watch, routeTimeout, pfx)
+		}
+	}
+}
+
+func TestSession(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	ate := ondatra.ATE(t, "ate")
+
+	configureDUT(t, dut)
+	defer deleteBGP(t, dut)
+	top := configureATE(t, ate)
+	bgp.StartATEPeers(t, dut, top, atePeer)
+	defer top.StopProtocols(t)
+
+	cases := []struct {
+		desc    string
+		addr    string
+		afiSafi telemetry.E_BgpTypes_AFI_SAFI_TYPE
+		network string
+		cidr    string
+		newFlow func(testing.TB, *ondatra.ATEDevice, *ondatra.ATETopology, *traffic.FlowParams) *ondatra.Flow
+	}{{
+		desc:    "IPv4",
+		addr:    atePort1.IPv4,
+		afiSafi: telemetry.BgpTypes_AFI_SAFI_TYPE_IPV4_UNICAST,
+		network: ateNetIPv4,
+		cidr:    ateNetCIDR4,
+		newFlow: traffic.NewIPv4Flow,
+	}, {
+		desc:    "IPv6",
+		addr:    atePort1.IPv6,
+		afiSafi: telemetry.BgpTypes_AFI_SAFI_TYPE_IPV6_UNICAST,
+		network: ateNetIPv6,
+		cidr:    ateNetCIDR6,
+		newFlow: traffic.NewIPv6Flow,
+	}}
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			verifySession(t, dut, tc.addr, tc.afiSafi)
+			verifyRoutes(t, dut, tc.addr, tc.afiSafi, tc.cidr)
+
+			flow := tc.newFlow(t, ate, top, &traffic.FlowParams{
+				Name:       tc.desc,
+				Src:        &atePort2,
+				Dst:        &atePort1,
+				DstNetwork: tc.network,
+				DUT:        dut,
+				SrcPort:    dut.Port(t, "port2"),
+				DstPort:    dut.Port(t, "port1"),
+			})
+			traffic.ValidateFlow(t, ate, flow, nil)
+		})
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package withdraw_convergence_test
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/bgp"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+)
+
+var (
+	maxDataplaneConvergence = flag.Duration("max_dataplane_convergence", 2*time.Second,
+		"Maximum time from the route withdrawal until the DUT stops forwarding traffic to the prefix.")
+	maxRIBConvergence = flag.Duration("max_rib_convergence", 5*time.Second,
+		"Maximum time from the route withdrawal until the prefix is removed from the AFT telemetry.")
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1 and
+// dut:port2 -> ate:port2.
+//
+//   - ate:port1 -> dut:port1 subnet 192.0.2.0/30
+//   - ate:port2 -> dut:port2 subnet 192.0.2.4/30
+//
+// ate:port1 is an eBGP peer of the DUT advertising ateNetCIDR, to which
+// traffic is sent from ate:port2 throughout the test.
+const (
+	ipv4PrefixLen = 30
+
+	dutAS = 64500
+	ateAS = 64501
+
+	ateNetName = "bgpNet"
+	ateNetCIDR = "203.0.113.0/24"
+
+	frameRate    = 1000 // frames per second
+	routeTimeout = time.Minute
+	// settleTime is how long traffic is sent before and after each
+	// route change, so that the sample intervals around it are complete.
+	settleTime = 5 * time.Second
+)
+
+var (
+	dutPort1 = attrs.Attributes{
+		Desc:    "dutPort1",
+		IPv4:    "192.0.2.1",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort1 = attrs.Attributes{
+		Name:    "atePort1",
+		IPv4:    "192.0.2.2",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	dutPort2 = attrs.Attributes{
+		Desc:    "dutPort2",
+		IPv4:    "192.0.2.5",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort2 = attrs.Attributes{
+		Name:    "atePort2",
+		IPv4:    "192.0.2.6",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	// atePeer is the eBGP speaker on ate:port1 advertising the
+	// destination network.
+	atePeer = &bgp.ATEPeer{
+		ATE:    &atePort1,
+		DUT:    &dutPort1,
+		AS:     ateAS,
+		Routes: []*bgp.Routes{{Name: ateNetName, CIDR: ateNetCIDR}},
+	}
+)
+
+// configureDUT configures port1 and port2 and the BGP neighbor to
+// ate:port1 on the DUT.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	d := dut.Config()
+
+	p1 := dut.Port(t, "port1")
+	d.Interface(p1.Name()).Replace(t, dutPort1.NewInterface(p1.Name()))
+
+	p2 := dut.Port(t, "port2")
+	d.Interface(p2.Name()).Replace(t, dutPort2.NewInterface(p2.Name()))
+
+	bgp.ConfigureDUT(t, dut, bgp.DUTConfig(dutPort1.IPv4, dutAS, atePeer.Neighbors()...))
+}
+
+// configureATE configures port1 and port2 on the ATE, with port1
+// advertising the destination network over eBGP.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice) *ondatra.ATETopology {
+	top := ate.Topology().New()
+	i1 := atePort1.AddToATE(top, ate.Port(t, "port1"), &dutPort1)
+	atePeer.AddToATE(t, i1)
+	atePort2.AddToATE(top, ate.Port(t, "port2"), &dutPort2)
+	return top
+}
+
+// awaitAFTEntry waits for the destination network to be present in, or
+// absent from, the AFT telemetry, and returns when it was observed.
+func awaitAFTEntry(t *testing.T, dut *ondatra.DUTDevice, present bool) (time.Time, bool) {
+	prefix := dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).Afts().Ipv4Entry(ateNetCIDR).Prefix()
+	what := "prefix " + ateNetCIDR
+	if !present {
+		what = "no prefix " + ateNetCIDR
+	}
+	_, ok := fptest.AwaitFunc[string](t, prefix.Watch, routeTimeout, what, func(q *telemetry.QualifiedString) bool {
+		return q.IsPresent() == present
+	})
+	return time.Now(), ok
+}
+
+// sinceEvent returns the time from the event until t, or zero if t is
+// zero or before the event.
+func sinceEvent(event, t time.Time) time.Duration {
+	if t.Before(event) {
+		return 0
+	}
+	return t.Sub(event)
+}
+
+func TestWithdrawConvergence(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	ate := ondatra.ATE(t, "ate")
+
+	configureDUT(t, dut)
+	defer bgp.DeleteDUT(t, dut)
+	top := configureATE(t, ate)
+	bgp.StartATEPeers(t, dut, top, atePeer)
+	defer top.StopProtocols(t)
+
+	if _, ok := awaitAFTEntry(t, dut, true); !ok {
+		t.FailNow()
+	}
+
+	p := &traffic.FlowParams{
+		Name:       "Withdraw",
+		Src:        &atePort2,
+		Dst:        &atePort1,
+		DstNetwork: ateNetName,
+		DUT:        dut,
+		SrcPort:    dut.Port(t, "port2"),
+		DstPort:    dut.Port(t, "port1"),
+	}
+	bg := traffic.StartBackground(t, ate, traffic.NewIPv4Flow(t, ate, top, p), frameRate)
+	time.Sleep(settleTime)
+
+	withdrawTime := atePeer.WithdrawRoutes(t, top, ateNetName)
+	ribRemoved, removed := awaitAFTEntry(t, dut, false)
+	time.Sleep(settleTime)
+
+	advertiseTime := atePeer.AdvertiseRoutes(t, top, ateNetName)
+	ribRestored, restored := awaitAFTEntry(t, dut, true)
+	time.Sleep(settleTime)
+
+	stopTime := time.Now()
+	bg.Stop(t)
+	outage, stopped := bg.OutageSince(withdrawTime)
+
+	t.Run("Withdraw", func(t *testing.T) {
+		if !removed {
+			t.Fatalf("Prefix %s was not removed from the AFT after the withdrawal", ateNetCIDR)
+		}
+		if stopped.IsZero() {
+			t.Fatalf("Traffic to %s was still forwarded after the withdrawal", ateNetCIDR)
+		}
+		c := &traffic.Convergence{
+			Event:     "withdraw " + ateNetCIDR,
+			Time:      withdrawTime,
+			Dataplane: sinceEvent(withdrawTime, stopped),
+			RIB:       sinceEvent(withdrawTime, ribRemoved),
+		}
+		traffic.RecordConvergence(t, c)
+		t.Logf("Withdrawal of %s converged in %v in the dataplane and %v in the RIB", ateNetCIDR, c.Dataplane, c.RIB)
+		if c.Dataplane > *maxDataplaneConvergence {
+			t.Errorf("Dataplane convergence after the withdrawal got %v, want at most %v", c.Dataplane, *maxDataplaneConvergence)
+		}
+		if c.RIB > *maxRIBConvergence {
+			t.Errorf("RIB convergence after the withdrawal got %v, want at most %v", c.RIB, *maxRIBConvergence)
+		}
+	})
+
+	t.Run("Readvertise", func(t *testing.T) {
+		if !restored {
+			t.Fatalf("Prefix %s was not restored in the AFT after the re-advertisement", ateNetCIDR)
+		}
+		// The traffic resumes when the outage that started with the
+		// withdrawal ends, which must be before the last sample.
+		if !outage.End.Before(stopTime.Add(-traffic.BackgroundSampleInterval)) {
+			t.Errorf("Traffic to %s did not recover after the re-advertisement, outage %v-%v", ateNetCIDR, outage.Start, outage.End)
+		}
+		resumed := stopped.Add(outage.Duration)
+		c := &traffic.Convergence{
+			Event:     "advertise " + ateNetCIDR,
+			Time:      advertiseTime,
+			Dataplane: sinceEvent(advertiseTime, resumed),
+			RIB:       sinceEvent(advertiseTime, ribRestored),
+		}
+		traffic.RecordConvergence(t, c)
+		t.Logf("Re-advertisement of %s converged in %v in the dataplane and %v in the RIB", ateNetCIDR, c.Dataplane, c.RIB)
+
+		traffic.ValidateFlow(t, ate, traffic.NewIPv4Flow(t, ate, top, &traffic.FlowParams{
+			Name:       "Recovered",
+			Src:        &atePort2,
+			Dst:        &atePort1,
+			DstNetwork: ateNetName,
+			DUT:        dut,
+			SrcPort:    dut.Port(t, "port2"),
+			DstPort:    dut.Port(t, "port1"),
+		}), nil)
+	})
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/openconfig/featureprofiles/internal/fptest"
 	"github.com/openconfig/ondatra"
 	"github.com/openconfig/ondatra/config/acl"
+	"github.com/openconfig/ondatra/ixnet"
 	"github.com/openconfig/ondatra/telemetry"
 	"github.com/openconfig/ygot/ygot"
 )
@@ -72,6 +73,9 @@ const (
 	bgpPort                  = 179
 	peerGrpName              = "BGP-PEER-GROUP"
 	ateDstCIDR               = "192.0.2.6/32"
+	// How long the ATE port2 peers stay down in helper mode, well
+	// within grRestartTime.
+	helperDownTime = 30 * time.Second
 )
 
 var (
@@ -143,7 +147,9 @@ func buildNbrList(asN uint32) []*bgpNeighbor {
 	return []*bgpNeighbor{nbr1v4, nbr2v4, nbr1v6, nbr2v6}
 }
 
-func bgpWithNbr(as uint32, nbrs []*bgpNeighbor) *telemetry.NetworkInstance_Protocol_Bgp {
+// bgpWithNbr returns the BGP config of the DUT, which is a graceful
+// restart helper only, not restarting itself, if helperOnly is set.
+func bgpWithNbr(as uint32, nbrs []*bgpNeighbor, helperOnly bool) *telemetry.NetworkInstance_Protocol_Bgp {
 	bgp := &telemetry.NetworkInstance_Protocol_Bgp{}
 	g := bgp.GetOrCreateGlobal()
 	g.As = ygot.Uint32(as)
@@ -152,6 +158,9 @@ func bgpWithNbr(as uint32, nbrs []*bgpNeighbor) *telemetry.NetworkInstance_Proto
 	bgpgr.Enabled = ygot.Bool(true)
 	bgpgr.RestartTime = ygot.Uint16(grRestartTime)
 	bgpgr.StaleRoutesTime = ygot.Uint16(grStaleRouteTime)
+	if helperOnly {
+		bgpgr.HelperOnly = ygot.Bool(true)
+	}
 
 	pg := bgp.GetOrCreatePeerGroup(peerGrpName)
 	pg.PeerAs = ygot.Uint32(ateAS)
@@ -219,7 +228,15 @@ func checkBgpStatus(t *testing.T, dut *ondatra.DUTDevice) {
 	}
 }
 
-func configureATE(t *testing.T, ate *ondatra.ATEDevice) []*ondatra.Flow {
+type ateConfig struct {
+	topo  *ondatra.ATETopology
+	flows []*ondatra.Flow
+	// port2Peers are the BGP peers of ate:port2, which advertise the
+	// destinations of the flows.
+	port2Peers []*ixnet.BGPPeer
+}
+
+func configureATE(t *testing.T, ate *ondatra.ATEDevice) *ateConfig {
 	topo := ate.Topology().New()
 	port1 := ate.Port(t, "port1")
 	ifDut1 := topo.AddInterface(ateSrc.Name).WithPort(port1)
@@ -239,10 +256,12 @@ func configureATE(t *testing.T, ate *ondatra.ATEDevice) []*ondatra.Flow {
 		WithTypeExternal().Capabilities().WithGracefulRestart(true)
 
 	bgpDut2 := ifDut2.BGP()
-	bgpDut2.AddPeer().WithPeerAddress(dutDst.IPv4).WithLocalASN(ateAS).
-		WithTypeExternal().Capabilities().WithGracefulRestart(true)
-	bgpDut2.AddPeer().WithPeerAddress(dutDst.IPv6).WithLocalASN(ateAS).
-		WithTypeExternal().Capabilities().WithGracefulRestart(true)
+	peer2v4 := bgpDut2.AddPeer().WithPeerAddress(dutDst.IPv4).WithLocalASN(ateAS).
+		WithTypeExternal()
+	peer2v4.Capabilities().WithGracefulRestart(true)
+	peer2v6 := bgpDut2.AddPeer().WithPeerAddress(dutDst.IPv6).WithLocalASN(ateAS).
+		WithTypeExternal()
+	peer2v6.Capabilities().WithGracefulRestart(true)
 
 	bgpNeti1 := ifDut1.AddNetwork("bgpNeti1") // ate port1
 	bgpNeti1.IPv4().WithAddress(advertisedRoutesv4CIDRp2).WithCount(routeCount)
@@ -276,7 +295,7 @@ func configureATE(t *testing.T, ate *ondatra.ATEDevice) []*ondatra.Flow {
 		WithDstEndpoints(ifDut2).
 		WithHeaders(ethHeader, ipv4Header).
 		WithFrameSize(512)
-	return []*ondatra.Flow{flowipv4}
+	return &ateConfig{topo: topo, flows: []*ondatra.Flow{flowipv4}, port2Peers: []*ixnet.BGPPeer{peer2v4, peer2v6}}
 }
 
 func verifyNoPacketLoss(t *testing.T, ate *ondatra.ATEDevice, allFlows []*ondatra.Flow) {
@@ -373,10 +392,10 @@ func configACLInterface(t *testing.T, dut *ondatra.DUTDevice, iFace *telemetry.A
 	return aclConf
 }
 
-func TestTrafficWithGracefulRestartSpeaker(t *testing.T) {
-	dut := ondatra.DUT(t, "dut")
-	ate := ondatra.ATE(t, "ate")
-
+// configureGracefulRestart configures the DUT and the ATE, with the DUT
+// a graceful restart helper only if helperOnly is set, and verifies
+// that the BGP sessions are established.
+func configureGracefulRestart(t *testing.T, dut *ondatra.DUTDevice, ate *ondatra.ATEDevice, helperOnly bool) *ateConfig {
 	// Configure interface on the DUT
 	t.Run("configureDut", func(t *testing.T) {
 		t.Log("Start DUT interface Config")
@@ -389,15 +408,15 @@ func TestTrafficWithGracefulRestartSpeaker(t *testing.T) {
 		dutConfPath := dut.Config().NetworkInstance(*deviations.DefaultNetworkInstance).Protocol(telemetry.PolicyTypes_INSTALL_PROTOCOL_TYPE_BGP, "BGP").Bgp()
 		dutConfPath.Delete(t)
 		nbrList := buildNbrList(ateAS)
-		dutConf := bgpWithNbr(dutAS, nbrList)
+		dutConf := bgpWithNbr(dutAS, nbrList, helperOnly)
 		dutConfPath.Replace(t, dutConf)
 		fptest.LogYgot(t, "DUT BGP Config", dutConfPath, dutConfPath.Get(t))
 	})
 	// ATE Configuration.
-	var allFlows []*ondatra.Flow
+	var conf *ateConfig
 	t.Run("configureATE", func(t *testing.T) {
 		t.Log("Start ATE Config")
-		allFlows = configureATE(t, ate)
+		conf = configureATE(t, ate)
 	})
 	// Verify Port Status
 	t.Run("verifyDUTPorts", func(t *testing.T) {
@@ -408,6 +427,14 @@ func TestTrafficWithGracefulRestartSpeaker(t *testing.T) {
 		t.Log("Check BGP parameters")
 		checkBgpStatus(t, dut)
 	})
+	return conf
+}
+
+func TestTrafficWithGracefulRestartSpeaker(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	ate := ondatra.ATE(t, "ate")
+	allFlows := configureGracefulRestart(t, dut, ate, false).flows
+
 	// Starting ATE Traffic
 	t.Run("VerifyTrafficPassBeforeAcLBlock", func(t *testing.T) {
 		t.Log("Send Traffic with GR timer enabled. Traffic should pass")
@@ -480,3 +507,60 @@ func TestTrafficWithGracefulRestartSpeaker(t *testing.T) {
 		verifyNoPacketLoss(t, ate, allFlows)
 	})
 }
+
+func TestTrafficWithGracefulRestartHelper(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	ate := ondatra.ATE(t, "ate")
+	conf := configureGracefulRestart(t, dut, ate, true)
+
+	statePath := dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).Protocol(telemetry.PolicyTypes_INSTALL_PROTOCOL_TYPE_BGP, "BGP").Bgp()
+	nbrPath := statePath.Neighbor(ateDst.IPv4)
+	setPeersActive := func(active bool) {
+		for _, peer := range conf.port2Peers {
+			peer.WithActive(active)
+		}
+		conf.topo.UpdateBGPPeerStates(t)
+	}
+
+	t.Run("VerifyTrafficPassWhileATERestarting", func(t *testing.T) {
+		t.Log("Stop the BGP sessions of ATE port2 without withdrawing its routes, as a restarting speaker would")
+		ate.Traffic().Start(t, conf.flows...)
+		startTime := time.Now()
+		setPeersActive(false)
+		_, ok := nbrPath.SessionState().Watch(t, time.Minute, func(val *telemetry.QualifiedE_Bgp_Neighbor_SessionState) bool {
+			return val.IsPresent() && val.Val(t) != telemetry.Bgp_Neighbor_SessionState_ESTABLISHED
+		}).Await(t)
+		if !ok {
+			fptest.LogYgot(t, "BGP reported state", nbrPath, nbrPath.Get(t))
+			t.Errorf("BGP session did not go Down as expected")
+		}
+		if got := nbrPath.GracefulRestart().PeerRestarting().Get(t); !got {
+			t.Errorf("Get(BGP peer %s peer-restarting): got %v, want true", ateDst.IPv4, got)
+		}
+		time.Sleep(helperDownTime - time.Since(startTime))
+		t.Log("Send Traffic while the ATE is restarting. Traffic should pass as the DUT is a GR helper!")
+		setPeersActive(true)
+		ate.Traffic().Stop(t)
+		t.Log("Traffic stopped")
+		verifyNoPacketLoss(t, ate, conf.flows)
+	})
+
+	t.Run("VerifyBGPEstablished", func(t *testing.T) {
+		t.Logf("Waiting for BGP neighbor to establish...")
+		_, ok := nbrPath.SessionState().Watch(t, time.Minute, func(val *telemetry.QualifiedE_Bgp_Neighbor_SessionState) bool {
+			return val.IsPresent() && val.Val(t) == telemetry.Bgp_Neighbor_SessionState_ESTABLISHED
+		}).Await(t)
+		if !ok {
+			fptest.LogYgot(t, "BGP reported state", nbrPath, nbrPath.Get(t))
+			t.Errorf("BGP session not Established as expected")
+		}
+		if got := nbrPath.GracefulRestart().PeerRestarting().Get(t); got {
+			t.Errorf("Get(BGP peer %s peer-restarting): got %v, want false", ateDst.IPv4, got)
+		}
+	})
+
+	t.Run("VerifyTrafficPassBGPRestored", func(t *testing.T) {
+		sendTraffic(t, ate, conf.flows, trafficDuration)
+		verifyNoPacketLoss(t, ate, conf.flows)
+	})
+}
@@ -0,0 +1,238 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecmp_test
+
+import (
+	"flag"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/openconfig/featureprofiles/internal/aftcheck"
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/bgp"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+)
+
+var (
+	maxWithdrawOutage = flag.Duration("max_withdraw_outage", time.Second,
+		"Maximum traffic outage after one of the ECMP paths is withdrawn.")
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1,
+// dut:port2 -> ate:port2 and dut:port3 -> ate:port3.
+//
+//   - ate:port1 -> dut:port1 subnet 192.0.2.0/30
+//   - ate:port2 -> dut:port2 subnet 192.0.2.4/30
+//   - ate:port3 -> dut:port3 subnet 192.0.2.8/30
+//
+// ate:port2 and ate:port3 are eBGP peers of the DUT in the same AS,
+// both advertising ateNetCIDR with identical attributes, to which
+// traffic is sent from ate:port1.
+const (
+	ipv4PrefixLen = 30
+
+	dutAS = 64500
+	ateAS = 64501
+
+	ateNetName = "bgpNet"
+	ateNetCIDR = "203.0.113.0/24"
+
+	maximumPaths = 2
+
+	routeTimeout = time.Minute
+	pollInterval = 5 * time.Second
+	// balanceTolerancePct is the maximum deviation in percentage points
+	// of the fraction of traffic received on each port from the
+	// expected fraction.
+	balanceTolerancePct = 10
+)
+
+var (
+	dutPort1 = attrs.Attributes{
+		Desc:    "dutPort1",
+		IPv4:    "192.0.2.1",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort1 = attrs.Attributes{
+		Name:    "atePort1",
+		IPv4:    "192.0.2.2",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	dutPort2 = attrs.Attributes{
+		Desc:    "dutPort2",
+		IPv4:    "192.0.2.5",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort2 = attrs.Attributes{
+		Name:    "atePort2",
+		IPv4:    "192.0.2.6",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	dutPort3 = attrs.Attributes{
+		Desc:    "dutPort3",
+		IPv4:    "192.0.2.9",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort3 = attrs.Attributes{
+		Name:    "atePort3",
+		IPv4:    "192.0.2.10",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	// atePeer2 and atePeer3 are the eBGP speakers on ate:port2 and
+	// ate:port3 advertising the destination network.
+	atePeer2 = &bgp.ATEPeer{
+		ATE:    &atePort2,
+		DUT:    &dutPort2,
+		AS:     ateAS,
+		Routes: []*bgp.Routes{{Name: ateNetName, CIDR: ateNetCIDR}},
+	}
+	atePeer3 = &bgp.ATEPeer{
+		ATE:    &atePort3,
+		DUT:    &dutPort3,
+		AS:     ateAS,
+		Routes: []*bgp.Routes{{Name: ateNetName, CIDR: ateNetCIDR}},
+	}
+
+	// entropy varies the L4 source port of the traffic so that it is
+	// hashed across both paths.
+	entropy = &traffic.Entropy{Protocol: traffic.UDP}
+
+	// distributionOpts measure the distribution of the traffic across
+	// ports once it settled after a change.
+	distributionOpts = &traffic.DistributionOptions{
+		Settle:    true,
+		MinFrames: entropy.MinFrames(balanceTolerancePct / 100.0),
+	}
+)
+
+// configureDUT configures port1, port2 and port3 and the BGP neighbors
+// to ate:port2 and ate:port3 with eBGP multipath on the DUT.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	d := dut.Config()
+
+	p1 := dut.Port(t, "port1")
+	d.Interface(p1.Name()).Replace(t, dutPort1.NewInterface(p1.Name()))
+
+	p2 := dut.Port(t, "port2")
+	d.Interface(p2.Name()).Replace(t, dutPort2.NewInterface(p2.Name()))
+
+	p3 := dut.Port(t, "port3")
+	d.Interface(p3.Name()).Replace(t, dutPort3.NewInterface(p3.Name()))
+
+	cfg := bgp.DUTConfig(dutPort1.IPv4, dutAS, append(atePeer2.Neighbors(), atePeer3.Neighbors()...)...)
+	bgp.EnableMultipath(cfg, maximumPaths)
+	bgp.ConfigureDUT(t, dut, cfg)
+}
+
+// configureATE configures port1, port2 and port3 on the ATE, with port2
+// and port3 advertising the destination network over eBGP.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice) *ondatra.ATETopology {
+	top := ate.Topology().New()
+	atePort1.AddToATE(top, ate.Port(t, "port1"), &dutPort1)
+	i2 := atePort2.AddToATE(top, ate.Port(t, "port2"), &dutPort2)
+	atePeer2.AddToATE(t, i2)
+	i3 := atePort3.AddToATE(top, ate.Port(t, "port3"), &dutPort3)
+	atePeer3.AddToATE(t, i3)
+	return top
+}
+
+// awaitNextHops waits for the AFT entry of the destination network to
+// reference a next hop group with next hops to the wanted addresses,
+// and reports whether it did.
+func awaitNextHops(t *testing.T, dut *ondatra.DUTDevice, want ...string) bool {
+	sort.Strings(want)
+	var got []string
+	for deadline := time.Now().Add(routeTimeout); time.Now().Before(deadline); time.Sleep(pollInterval) {
+		got = nil
+		for _, nh := range aftcheck.EntryNextHops(t, dut, *deviations.DefaultNetworkInstance, ateNetCIDR) {
+			got = append(got, nh.IPAddress)
+		}
+		sort.Strings(got)
+		if cmp.Equal(want, got) {
+			return true
+		}
+	}
+	t.Errorf("AFT next hops of %s got %v, want %v", ateNetCIDR, got, want)
+	return false
+}
+
+func TestMultipathECMP(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	ate := ondatra.ATE(t, "ate")
+
+	configureDUT(t, dut)
+	defer bgp.DeleteDUT(t, dut)
+	top := configureATE(t, ate)
+	bgp.StartATEPeers(t, dut, top, atePeer2, atePeer3)
+	defer top.StopProtocols(t)
+
+	mp := dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).
+		Protocol(telemetry.PolicyTypes_INSTALL_PROTOCOL_TYPE_BGP, bgp.ProtocolName).Bgp().Global().UseMultiplePaths()
+	if !mp.Enabled().Get(t) {
+		t.Errorf("BGP use-multiple-paths enabled got false, want true")
+	}
+	if got := mp.Ebgp().MaximumPaths().Get(t); got != maximumPaths {
+		t.Errorf("BGP eBGP maximum-paths got %d, want %d", got, maximumPaths)
+	}
+
+	if !awaitNextHops(t, dut, atePort2.IPv4, atePort3.IPv4) {
+		t.FailNow()
+	}
+
+	flow := traffic.NewEntropyFlow(t, ate, top, &traffic.FlowParams{
+		Name:       "ECMP",
+		Src:        &atePort1,
+		Dst:        &atePort2,
+		AltDsts:    []*attrs.Attributes{&atePort3},
+		DstNetwork: ateNetName,
+	}, entropy)
+	bg := traffic.StartBackground(t, ate, flow, traffic.MinEntropyFrameRate)
+
+	t.Run("Balanced", func(t *testing.T) {
+		traffic.CheckDistribution(t, ate, []string{"port2", "port3"}, []uint64{1, 1}, balanceTolerancePct, distributionOpts)
+	})
+
+	withdrawTime := atePeer3.WithdrawRoutes(t, top, ateNetName)
+	t.Run("Consolidated", func(t *testing.T) {
+		awaitNextHops(t, dut, atePort2.IPv4)
+		traffic.CheckDistribution(t, ate, []string{"port2", "port3"}, []uint64{1, 0}, balanceTolerancePct, distributionOpts)
+	})
+
+	bg.Stop(t)
+	outage, _ := bg.OutageSince(withdrawTime)
+	t.Logf("Withdrawing the path via ATE port-3 lost %d packets, i.e. %v outage", outage.LostPkts, outage.Duration)
+	if outage.Duration > *maxWithdrawOutage {
+		t.Errorf("Outage after withdrawing the path via ATE port-3 got %v, want at most %v", outage.Duration, *maxWithdrawOutage)
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package four_octet_as_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/bgp"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+	otgtelemetry "github.com/openconfig/ondatra/telemetry/otg"
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1 and
+// dut:port2 -> ate:port2.
+//
+//   - ate:port1 -> dut:port1 subnet 192.0.2.0/30
+//   - ate:port2 -> dut:port2 subnet 192.0.2.4/30
+//
+// Both ATE ports are eBGP peers of the DUT.  ate:port1 advertises
+// ateNetCIDR with a 4-byte AS, which the DUT advertises to ate:port2,
+// from which traffic is sent to ateNetCIDR.  The 4-byte AS numbers are
+// from the documentation range of RFC 5398.
+const (
+	ipv4PrefixLen = 30
+
+	dutAS     = 65537
+	ate1AS    = 65538
+	ate2AS    = 65539
+	ate2ASOld = 64502
+
+	// asTrans is the AS that stands for a 4-byte AS to a speaker that
+	// only supports 2-byte AS numbers, per RFC 6793.
+	asTrans = 23456
+
+	ateNetName      = "bgpNet"
+	ateNetCIDR      = "203.0.113.0/24"
+	ateNetAddress   = "203.0.113.0"
+	ateNetPrefixLen = 24
+	ateNetStart     = "203.0.113.1"
+	ateNetCount     = 250
+
+	flowName     = "FourOctetAS"
+	routeTimeout = time.Minute
+	pollInterval = 5 * time.Second
+)
+
+var (
+	dutPort1 = attrs.Attributes{
+		Desc:    "dutPort1",
+		IPv4:    "192.0.2.1",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort1 = attrs.Attributes{
+		Name:    "atePort1",
+		MAC:     "02:00:01:01:01:01",
+		IPv4:    "192.0.2.2",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	dutPort2 = attrs.Attributes{
+		Desc:    "dutPort2",
+		IPv4:    "192.0.2.5",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort2 = attrs.Attributes{
+		Name:    "atePort2",
+		MAC:     "02:00:02:01:01:01",
+		IPv4:    "192.0.2.6",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	// atePeer1 is the 4-byte AS speaker on ate:port1 advertising the
+	// destination network.
+	atePeer1 = &bgp.OTGPeer{
+		ATE:    &atePort1,
+		DUT:    &dutPort1,
+		AS:     ate1AS,
+		Routes: []*bgp.Routes{{Name: ateNetName, CIDR: ateNetCIDR}},
+	}
+)
+
+// configureDUT configures port1 and port2 on the DUT.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	d := dut.Config()
+
+	p1 := dut.Port(t, "port1")
+	d.Interface(p1.Name()).Replace(t, dutPort1.NewInterface(p1.Name()))
+
+	p2 := dut.Port(t, "port2")
+	d.Interface(p2.Name()).Replace(t, dutPort2.NewInterface(p2.Name()))
+}
+
+// configureATE configures port1 and port2 on the ATE with the BGP peers
+// on them.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice, atePeer2 *bgp.OTGPeer) gosnappi.Config {
+	top := ate.OTG().NewConfig(t)
+	atePort1.AddToOTG(top, ate.Port(t, "port1"), &dutPort1)
+	atePort2.AddToOTG(top, ate.Port(t, "port2"), &dutPort2)
+	atePeer1.AddToOTG(t, top)
+	atePeer2.AddToOTG(t, top)
+	return top
+}
+
+// verifyNeighbor checks that the DUT neighbor to the peer reports its AS,
+// and whether the four-octet AS capability was negotiated.
+func verifyNeighbor(t *testing.T, dut *ondatra.DUTDevice, p *bgp.OTGPeer, wantASN32 bool) {
+	nbr := dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).
+		Protocol(telemetry.PolicyTypes_INSTALL_PROTOCOL_TYPE_BGP, bgp.ProtocolName).Bgp().Neighbor(p.ATE.IPv4).Get(t)
+	if got, want := nbr.GetPeerAs(), p.AS; got != want {
+		t.Errorf("Neighbor %s peer-as got %d, want %d", p.ATE.IPv4, got, want)
+	}
+	gotASN32 := false
+	for _, c := range nbr.GetSupportedCapabilities() {
+		if c == telemetry.BgpTypes_BGP_CAPABILITY_ASN32 {
+			gotASN32 = true
+		}
+	}
+	if gotASN32 != wantASN32 {
+		t.Errorf("Neighbor %s supported-capabilities %v got ASN32 %t, want %t", p.ATE.IPv4, nbr.GetSupportedCapabilities(), gotASN32, wantASN32)
+	}
+}
+
+// receivedASPath waits for the peer to receive the destination network
+// from the DUT, and returns the AS numbers of the AS_SEQUENCE segments
+// of its AS path.
+func receivedASPath(t *testing.T, ate *ondatra.ATEDevice, p *bgp.OTGPeer) ([]uint32, bool) {
+	prefixes := ate.OTG().Telemetry().BgpPeer(p.Name()).UnicastIpv4PrefixAny()
+	for deadline := time.Now().Add(routeTimeout); time.Now().Before(deadline); time.Sleep(pollInterval) {
+		for _, prefix := range prefixes.Get(t) {
+			if prefix.GetAddress() != ateNetAddress || prefix.GetPrefixLength() != ateNetPrefixLen {
+				continue
+			}
+			var asPath []uint32
+			for _, seg := range prefix.AsPath {
+				if seg.SegmentType == otgtelemetry.State_SegmentType_AS_SEQUENCE {
+					asPath = append(asPath, seg.AsNumbers...)
+				}
+			}
+			return asPath, true
+		}
+	}
+	return nil, false
+}
+
+func TestFourOctetAS(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	ate := ondatra.ATE(t, "ate")
+	configureDUT(t, dut)
+
+	cases := []struct {
+		desc string
+		// atePeer2 is the speaker on ate:port2 receiving the destination
+		// network from the DUT.
+		atePeer2 *bgp.OTGPeer
+		// wantASN32 is whether the four-octet AS capability is negotiated
+		// with atePeer2.
+		wantASN32 bool
+		// wantASPath is the AS path of the destination network received
+		// by atePeer2.
+		wantASPath []uint32
+	}{{
+		desc:       "FourOctetPeer",
+		atePeer2:   &bgp.OTGPeer{ATE: &atePort2, DUT: &dutPort2, AS: ate2AS},
+		wantASN32:  true,
+		wantASPath: []uint32{dutAS, ate1AS},
+	}, {
+		desc:       "TwoOctetPeer",
+		atePeer2:   &bgp.OTGPeer{ATE: &atePort2, DUT: &dutPort2, AS: ate2ASOld, TwoOctetAS: true},
+		wantASN32:  false,
+		wantASPath: []uint32{asTrans, asTrans},
+	}}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			nbrs := append(atePeer1.Neighbors(), c.atePeer2.Neighbors()...)
+			bgp.ConfigureDUT(t, dut, bgp.DUTConfig(dutPort1.IPv4, dutAS, nbrs...))
+			defer bgp.DeleteDUT(t, dut)
+
+			top := configureATE(t, ate, c.atePeer2)
+			bgp.StartOTGPeers(t, dut, ate, top, atePeer1, c.atePeer2)
+			defer ate.OTG().StopProtocols(t)
+
+			verifyNeighbor(t, dut, atePeer1, true)
+			verifyNeighbor(t, dut, c.atePeer2, c.wantASN32)
+
+			aftPrefix := dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).Afts().Ipv4Entry(ateNetCIDR).Prefix()
+			if !fptest.Await(t, aftPrefix.Watch, routeTimeout, ateNetCIDR) {
+				t.Fatalf("DUT did not install %s from %s within %v", ateNetCIDR, atePort1.IPv4, routeTimeout)
+			}
+
+			asPath, ok := receivedASPath(t, ate, c.atePeer2)
+			if !ok {
+				t.Fatalf("ATE peer %s did not receive %s within %v", c.atePeer2.Name(), ateNetCIDR, routeTimeout)
+			}
+			if diff := cmp.Diff(c.wantASPath, asPath); diff != "" {
+				t.Errorf("AS path of %s received by %s -want,+got:\n%s", ateNetCIDR, c.atePeer2.Name(), diff)
+			}
+
+			// The flow can only be added once the protocols are running, and
+			// pushing it restarts them.
+			top.Flows().Clear()
+			traffic.AddOTGIPv4Flow(t, ate, top, &traffic.OTGFlowParams{
+				Name:     flowName,
+				Src:      &atePort2,
+				Dst:      &atePort1,
+				SrcPort:  ate.Port(t, "port2"),
+				DstPort:  ate.Port(t, "port1"),
+				Gateway:  &dutPort2,
+				DstStart: ateNetStart,
+				DstCount: ateNetCount,
+			})
+			bgp.StartOTGPeers(t, dut, ate, top, atePeer1, c.atePeer2)
+			if !fptest.Await(t, aftPrefix.Watch, routeTimeout, ateNetCIDR) {
+				t.Fatalf("DUT did not install %s from %s within %v", ateNetCIDR, atePort1.IPv4, routeTimeout)
+			}
+			traffic.ValidateOTGFlow(t, ate, top, flowName, nil)
+		})
+	}
+}
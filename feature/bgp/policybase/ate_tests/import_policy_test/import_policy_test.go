@@ -0,0 +1,259 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package import_policy_test
+
+import (
+	"flag"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/bgp"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/policy"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+)
+
+var (
+	maxPolicyConvergence = flag.Duration("max_policy_convergence", 30*time.Second,
+		"Maximum time for the prefixes permitted by an updated import policy to be installed.")
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1 and
+// dut:port2 -> ate:port2.
+//
+//   - ate:port1 -> dut:port1 subnet 192.0.2.0/30
+//   - ate:port2 -> dut:port2 subnet 192.0.2.4/30
+//
+// ate:port1 is an eBGP peer of the DUT advertising the /28 prefixes of
+// 203.0.113.0/25: the first half as permittedNet, which the import
+// policy accepts, and the second half as rejectedNet.
+const (
+	ipv4PrefixLen = 30
+
+	dutAS = 64500
+	ateAS = 64501
+
+	routesPerNet    = 4
+	permittedNet    = "permittedNet"
+	permittedCIDR   = "203.0.113.0/28"
+	rejectedNet     = "rejectedNet"
+	rejectedCIDR    = "203.0.113.64/28"
+	permittedSet    = "PERMITTED"
+	permittedSetPfx = "203.0.113.0/26"
+	permittedSetMLR = "28..28"
+	importPolicy    = "IMPORT"
+
+	routeTimeout = time.Minute
+)
+
+var (
+	dutPort1 = attrs.Attributes{
+		Desc:    "dutPort1",
+		IPv4:    "192.0.2.1",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort1 = attrs.Attributes{
+		Name:    "atePort1",
+		IPv4:    "192.0.2.2",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	dutPort2 = attrs.Attributes{
+		Desc:    "dutPort2",
+		IPv4:    "192.0.2.5",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort2 = attrs.Attributes{
+		Name:    "atePort2",
+		IPv4:    "192.0.2.6",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	// atePeer is the eBGP speaker on ate:port1 advertising the
+	// permitted and the rejected prefixes.
+	atePeer = &bgp.ATEPeer{
+		ATE: &atePort1,
+		DUT: &dutPort1,
+		AS:  ateAS,
+		Routes: []*bgp.Routes{
+			{Name: permittedNet, CIDR: permittedCIDR, Count: routesPerNet},
+			{Name: rejectedNet, CIDR: rejectedCIDR, Count: routesPerNet},
+		},
+	}
+
+	// prefixSets are the prefix sets of the import policy.
+	prefixSets = []*policy.PrefixSet{{
+		Name:            permittedSet,
+		Prefixes:        []string{permittedSetPfx},
+		MaskLengthRange: permittedSetMLR,
+	}}
+
+	// filterPolicy accepts the routes in permittedSet and rejects the
+	// others.
+	filterPolicy = &policy.Definition{
+		Name: importPolicy,
+		Statements: []*policy.Statement{
+			{PrefixSet: permittedSet, Result: telemetry.RoutingPolicy_PolicyResultType_ACCEPT_ROUTE},
+			{Result: telemetry.RoutingPolicy_PolicyResultType_REJECT_ROUTE},
+		},
+	}
+)
+
+// configurePolicy replaces the routing policy of the DUT with the prefix
+// sets and the import policy def.
+func configurePolicy(t *testing.T, dut *ondatra.DUTDevice, def *policy.Definition) {
+	rp, err := policy.Build(prefixSets, []*policy.Definition{def})
+	if err != nil {
+		t.Fatalf("Cannot build the routing policy: %v", err)
+	}
+	policy.Configure(t, dut, rp)
+}
+
+// configureDUT configures port1 and port2, the filtering import policy,
+// and the BGP neighbor to ate:port1 with the policy applied on the DUT.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	d := dut.Config()
+
+	p1 := dut.Port(t, "port1")
+	d.Interface(p1.Name()).Replace(t, dutPort1.NewInterface(p1.Name()))
+
+	p2 := dut.Port(t, "port2")
+	d.Interface(p2.Name()).Replace(t, dutPort2.NewInterface(p2.Name()))
+
+	configurePolicy(t, dut, filterPolicy)
+	nbrs := atePeer.Neighbors()
+	for _, nbr := range nbrs {
+		nbr.ImportPolicy = []string{importPolicy}
+	}
+	bgp.ConfigureDUT(t, dut, bgp.DUTConfig(dutPort1.IPv4, dutAS, nbrs...))
+}
+
+// configureATE configures port1 and port2 on the ATE, with port1
+// peering with the DUT and advertising the routes.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice) *ondatra.ATETopology {
+	top := ate.Topology().New()
+	i1 := atePort1.AddToATE(top, ate.Port(t, "port1"), &dutPort1)
+	atePeer.AddToATE(t, i1)
+	atePort2.AddToATE(top, ate.Port(t, "port2"), &dutPort2)
+	return top
+}
+
+// netPrefixes returns the routesPerNet /28 prefixes of a network,
+// starting with the first-th /28 of 203.0.113.0/24.
+func netPrefixes(first int) []string {
+	var pfxs []string
+	for i := first; i < first+routesPerNet; i++ {
+		pfxs = append(pfxs, fmt.Sprintf("203.0.113.%d/28", 16*i))
+	}
+	return pfxs
+}
+
+// verifyAFT checks that the permitted prefixes are in the AFT, and that
+// the rejected prefixes are absent unless wantRejected.
+func verifyAFT(t *testing.T, dut *ondatra.DUTDevice, wantRejected bool) {
+	afts := dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).Afts()
+	for _, pfx := range netPrefixes(0) {
+		fptest.Await(t, afts.Ipv4Entry(pfx).Prefix().Watch, routeTimeout, pfx)
+	}
+	for _, pfx := range netPrefixes(routesPerNet) {
+		if wantRejected {
+			fptest.Await(t, afts.Ipv4Entry(pfx).Prefix().Watch, routeTimeout, pfx)
+		} else if afts.Ipv4Entry(pfx).Prefix().Lookup(t).IsPresent() {
+			t.Errorf("Prefix %s rejected by the import policy is in the AFT", pfx)
+		}
+	}
+}
+
+// verifyTraffic sends traffic to the permitted and the rejected
+// prefixes, and checks that the traffic to the rejected prefixes is
+// dropped unless wantRejected.
+func verifyTraffic(t *testing.T, dut *ondatra.DUTDevice, ate *ondatra.ATEDevice, top *ondatra.ATETopology, wantRejected bool) {
+	for _, c := range []struct {
+		network  string
+		wantLoss bool
+	}{
+		{permittedNet, false},
+		{rejectedNet, !wantRejected},
+	} {
+		flow := traffic.NewIPv4Flow(t, ate, top, &traffic.FlowParams{
+			Name:       c.network,
+			Src:        &atePort2,
+			Dst:        &atePort1,
+			DstNetwork: c.network,
+			DUT:        dut,
+			SrcPort:    dut.Port(t, "port2"),
+			DstPort:    dut.Port(t, "port1"),
+		})
+		traffic.ValidateFlow(t, ate, flow, &traffic.Options{WantLoss: c.wantLoss})
+	}
+}
+
+func TestImportPolicy(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	ate := ondatra.ATE(t, "ate")
+
+	configureDUT(t, dut)
+	defer policy.Delete(t, dut)
+	defer bgp.DeleteDUT(t, dut)
+	top := configureATE(t, ate)
+	bgp.StartATEPeers(t, dut, top, atePeer)
+	defer top.StopProtocols(t)
+
+	nbrPath := dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).
+		Protocol(telemetry.PolicyTypes_INSTALL_PROTOCOL_TYPE_BGP, bgp.ProtocolName).Bgp().Neighbor(atePort1.IPv4)
+	installed := nbrPath.AfiSafi(telemetry.BgpTypes_AFI_SAFI_TYPE_IPV4_UNICAST).Prefixes().Installed()
+
+	t.Run("Filtered", func(t *testing.T) {
+		fptest.Await(t, installed.Watch, routeTimeout, uint32(routesPerNet))
+		verifyAFT(t, dut, false)
+		verifyTraffic(t, dut, ate, top, false)
+	})
+
+	transitions := nbrPath.EstablishedTransitions().Get(t)
+	start := time.Now()
+	configurePolicy(t, dut, policy.AcceptAll(importPolicy))
+
+	t.Run("PermitAll", func(t *testing.T) {
+		if fptest.Await(t, installed.Watch, routeTimeout, uint32(2*routesPerNet)) {
+			elapsed := time.Since(start)
+			t.Logf("Prefixes permitted by the updated policy installed in %v", elapsed)
+			if elapsed > *maxPolicyConvergence {
+				t.Errorf("Installing the prefixes permitted by the updated policy got %v, want at most %v", elapsed, *maxPolicyConvergence)
+			}
+		}
+		if got, want := nbrPath.SessionState().Get(t), telemetry.Bgp_Neighbor_SessionState_ESTABLISHED; got != want {
+			t.Errorf("Neighbor %s session-state got %v after the policy update, want %v", atePort1.IPv4, got, want)
+		}
+		if got := nbrPath.EstablishedTransitions().Get(t); got != transitions {
+			t.Errorf("Neighbor %s established-transitions got %d after the policy update, want %d (no session reset)", atePort1.IPv4, got, transitions)
+		}
+		verifyAFT(t, dut, true)
+		verifyTraffic(t, dut, ate, top, true)
+	})
+}
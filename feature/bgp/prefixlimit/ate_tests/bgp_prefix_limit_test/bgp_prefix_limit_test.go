@@ -112,8 +112,14 @@ func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
 	i2 := dutDst.NewInterface(p2)
 	dc.Interface(p2).Replace(t, i2)
 
-	dutConfPath := dc.NetworkInstance(*deviations.DefaultNetworkInstance).Protocol(telemetry.PolicyTypes_INSTALL_PROTOCOL_TYPE_BGP, "BGP").Bgp()
-	dutConf := createBGPNeighbor(dutAS, ateAS, prefixLimit, grRestartTime)
+	configureBGP(t, dut, false)
+}
+
+// configureBGP configures BGP on the DUT, with prefix limits that tear
+// the sessions down when exceeded unless preventTeardown is set.
+func configureBGP(t *testing.T, dut *ondatra.DUTDevice, preventTeardown bool) {
+	dutConfPath := dut.Config().NetworkInstance(*deviations.DefaultNetworkInstance).Protocol(telemetry.PolicyTypes_INSTALL_PROTOCOL_TYPE_BGP, "BGP").Bgp()
+	dutConf := createBGPNeighbor(dutAS, ateAS, prefixLimit, grRestartTime, preventTeardown)
 	dutConfPath.Replace(t, dutConf)
 }
 
@@ -203,7 +209,7 @@ type BGPNeighbor struct {
 	isV4         bool
 }
 
-func createBGPNeighbor(localAs, peerAs, pLimit uint32, restartTime uint16) *telemetry.NetworkInstance_Protocol_Bgp {
+func createBGPNeighbor(localAs, peerAs, pLimit uint32, restartTime uint16, preventTeardown bool) *telemetry.NetworkInstance_Protocol_Bgp {
 
 	nbrs := []*BGPNeighbor{
 		{as: peerAs, pfxLimit: pLimit, neighborip: ateSrc.IPv4, isV4: true},
@@ -228,6 +234,7 @@ func createBGPNeighbor(localAs, peerAs, pLimit uint32, restartTime uint16) *tele
 			afisafi.Enabled = ygot.Bool(true)
 			prefixLimit := afisafi.GetOrCreateIpv4Unicast().GetOrCreatePrefixLimit()
 			prefixLimit.MaxPrefixes = ygot.Uint32(nbr.pfxLimit)
+			prefixLimit.PreventTeardown = ygot.Bool(preventTeardown)
 		} else {
 			nv6 := bgp.GetOrCreateNeighbor(nbr.neighborip)
 			nv6.PeerAs = ygot.Uint32(nbr.as)
@@ -237,6 +244,7 @@ func createBGPNeighbor(localAs, peerAs, pLimit uint32, restartTime uint16) *tele
 			afisafi6.Enabled = ygot.Bool(true)
 			prefixLimit6 := afisafi6.GetOrCreateIpv6Unicast().GetOrCreatePrefixLimit()
 			prefixLimit6.MaxPrefixes = ygot.Uint32(nbr.pfxLimit)
+			prefixLimit6.PreventTeardown = ygot.Bool(preventTeardown)
 		}
 	}
 	return bgp
@@ -276,7 +284,7 @@ func waitForBGPSession(t *testing.T, dut *ondatra.DUTDevice, wantEstablished boo
 	}
 }
 
-func verifyPrefixLimitTelemetry(t *testing.T, n *telemetry.NetworkInstance_Protocol_Bgp_Neighbor, wantEstablished bool) {
+func verifyPrefixLimitTelemetry(t *testing.T, n *telemetry.NetworkInstance_Protocol_Bgp_Neighbor, wantExceeded bool) {
 	t.Run("verifyPrefixLimitTelemetry", func(t *testing.T) {
 		// TODO: Remove skip when Telemetry Parameters are supported
 		t.Skip("Skipped since Telemetry parameters are not supported")
@@ -288,8 +296,8 @@ func verifyPrefixLimitTelemetry(t *testing.T, n *telemetry.NetworkInstance_Proto
 		if maxPrefix != prefixLimit {
 			t.Errorf("PrefixLimit max-prefixes v4 mismatch: got %d, want %d", maxPrefix, prefixLimit)
 		}
-		if (wantEstablished && limitExceeded) || (!wantEstablished && !limitExceeded) {
-			t.Errorf("PrefixLimitExceeded v4 mismatch: got %t, want %t", limitExceeded, !wantEstablished)
+		if limitExceeded != wantExceeded {
+			t.Errorf("PrefixLimitExceeded v4 mismatch: got %t, want %t", limitExceeded, wantExceeded)
 		}
 
 		maxPrefix = plv6.GetMaxPrefixes()
@@ -297,8 +305,8 @@ func verifyPrefixLimitTelemetry(t *testing.T, n *telemetry.NetworkInstance_Proto
 		if maxPrefix != prefixLimit {
 			t.Errorf("PrefixLimit max-prefixes v6 mismatch: got %d, want %d", maxPrefix, prefixLimit)
 		}
-		if (wantEstablished && limitExceeded) || (!wantEstablished && !limitExceeded) {
-			t.Errorf("PrefixLimitExceeded v6 mismatch: got %t, want %t", limitExceeded, !wantEstablished)
+		if limitExceeded != wantExceeded {
+			t.Errorf("PrefixLimitExceeded v6 mismatch: got %t, want %t", limitExceeded, wantExceeded)
 		}
 	})
 }
@@ -311,6 +319,10 @@ func (tc *testCase) verifyBGPTelemetry(t *testing.T, dut *ondatra.DUTDevice) {
 	if !tc.wantEstablished {
 		installedRoutes = 0
 	}
+	// With prevent-teardown, the routes beyond the limit may or may not
+	// be installed: only the routes received are checked, and the
+	// traffic shows that the routes within the limit are retained.
+	checkInstalled := !tc.preventTeardown || tc.numRoutes <= prefixLimit
 
 	compare := func(val *telemetry.QualifiedUint32) bool {
 		return val.IsPresent() && val.Val(t) == installedRoutes
@@ -318,24 +330,28 @@ func (tc *testCase) verifyBGPTelemetry(t *testing.T, dut *ondatra.DUTDevice) {
 	t.Log("Verifying BGP state")
 	statePath := dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).Protocol(telemetry.PolicyTypes_INSTALL_PROTOCOL_TYPE_BGP, "BGP").Bgp()
 	prefixes := statePath.Neighbor(ateDst.IPv4).AfiSafi(telemetry.BgpTypes_AFI_SAFI_TYPE_IPV4_UNICAST).Prefixes()
-	if got, ok := prefixes.Installed().Watch(t, time.Minute, compare).Await(t); !ok {
-		t.Errorf("Installed prefixes v4 mismatch: got %v, want %v", got.Val(t), installedRoutes)
+	if checkInstalled {
+		if got, ok := prefixes.Installed().Watch(t, time.Minute, compare).Await(t); !ok {
+			t.Errorf("Installed prefixes v4 mismatch: got %v, want %v", got.Val(t), installedRoutes)
+		}
 	}
 	if got, ok := prefixes.Received().Watch(t, time.Minute, compare).Await(t); !ok {
 		t.Errorf("Received prefixes v4 mismatch: got %v, want %v", got.Val(t), installedRoutes)
 	}
 	nv4 := statePath.Neighbor(ateDst.IPv4).Get(t)
-	verifyPrefixLimitTelemetry(t, nv4, tc.wantEstablished)
+	verifyPrefixLimitTelemetry(t, nv4, tc.numRoutes > prefixLimit)
 
 	prefixesv6 := statePath.Neighbor(ateDst.IPv6).AfiSafi(telemetry.BgpTypes_AFI_SAFI_TYPE_IPV6_UNICAST).Prefixes()
-	if got, ok := prefixesv6.Installed().Watch(t, time.Minute, compare).Await(t); !ok {
-		t.Errorf("Installed prefixes v6 mismatch: got %v, want %v", got.Val(t), installedRoutes)
+	if checkInstalled {
+		if got, ok := prefixesv6.Installed().Watch(t, time.Minute, compare).Await(t); !ok {
+			t.Errorf("Installed prefixes v6 mismatch: got %v, want %v", got.Val(t), installedRoutes)
+		}
 	}
 	if got, ok := prefixesv6.Received().Watch(t, time.Minute, compare).Await(t); !ok {
 		t.Errorf("Received prefixes v6 mismatch: got %v, want %v", got.Val(t), installedRoutes)
 	}
 	nv6 := statePath.Neighbor(ateDst.IPv6).Get(t)
-	verifyPrefixLimitTelemetry(t, nv6, tc.wantEstablished)
+	verifyPrefixLimitTelemetry(t, nv6, tc.numRoutes > prefixLimit)
 }
 
 func (tc *testCase) verifyNoPacketLoss(t *testing.T, ate *ondatra.ATEDevice, allFlows []*ondatra.Flow) {
@@ -418,6 +434,9 @@ type testCase struct {
 	numRoutes        uint32
 	wantEstablished  bool
 	wantNoPacketLoss bool
+	// preventTeardown is whether the prefix limits only warn when
+	// exceeded.
+	preventTeardown bool
 }
 
 func (tc *testCase) run(t *testing.T, conf *config, dut *ondatra.DUTDevice, ate *ondatra.ATEDevice) {
@@ -474,6 +493,20 @@ func TestTrafficBGPPrefixLimit(t *testing.T) {
 		numRoutes:        prefixLimit,
 		wantEstablished:  true,
 		wantNoPacketLoss: true,
+	}, {
+		name:             "WarningOnlyOverLimit",
+		desc:             "BGP Session kept with prefixes outside a warning-only limit",
+		numRoutes:        prefixLimit + 1,
+		wantEstablished:  true,
+		wantNoPacketLoss: true,
+		preventTeardown:  true,
+	}, {
+		name:             "WarningOnlyAtLimit",
+		desc:             "BGP Prefixes back at threshold of a warning-only limit",
+		numRoutes:        prefixLimit,
+		wantEstablished:  true,
+		wantNoPacketLoss: true,
+		preventTeardown:  true,
 	}}
 
 	dut := ondatra.DUT(t, "dut")
@@ -486,7 +519,13 @@ func TestTrafficBGPPrefixLimit(t *testing.T) {
 	t.Log("Start ATE Config")
 	conf := configureATE(t, ate)
 
+	preventTeardown := false
 	for _, tc := range cases {
+		if tc.preventTeardown != preventTeardown {
+			t.Logf("Configuring BGP prefix limits with prevent-teardown %t", tc.preventTeardown)
+			configureBGP(t, dut, tc.preventTeardown)
+			preventTeardown = tc.preventTeardown
+		}
 		t.Run(tc.name, func(t *testing.T) {
 			tc.run(t, conf, dut, ate)
 		})
@@ -0,0 +1,197 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oper_status_on_change_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/link"
+	"github.com/openconfig/featureprofiles/internal/onchange"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ygot/ygot"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of dut:port1 -> ate:port1 and
+// dut:port2 -> ate:port2.
+//
+//   - dut:port1 -> ate:port1 subnet 192.0.2.0/30
+//   - dut:port2 -> ate:port2 subnet 192.0.2.4/30
+//
+// dut:port1 is disabled and re-enabled, while dut:port2 is left
+// untouched.
+const (
+	ipv4PrefixLen = 30
+
+	// notifyDelay is how long the DUT may take to send the oper-status
+	// notification after the admin state of the port is set.
+	notifyDelay = 10 * time.Second
+	// settleTime is how long updates of the untouched port are watched
+	// for after the last change.
+	settleTime = 5 * time.Second
+)
+
+var (
+	dutPort1 = attrs.Attributes{
+		Desc:    "dutPort1",
+		IPv4:    "192.0.2.1",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort1 = attrs.Attributes{
+		Name:    "atePort1",
+		IPv4:    "192.0.2.2",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	dutPort2 = attrs.Attributes{
+		Desc:    "dutPort2",
+		IPv4:    "192.0.2.5",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort2 = attrs.Attributes{
+		Name:    "atePort2",
+		IPv4:    "192.0.2.6",
+		IPv4Len: ipv4PrefixLen,
+	}
+)
+
+// configureDUT configures port1 and port2 on the DUT.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	d := dut.Config()
+	for id, a := range map[string]*attrs.Attributes{"port1": &dutPort1, "port2": &dutPort2} {
+		dp := dut.Port(t, id)
+		i := a.NewInterface(dp.Name())
+		d.Interface(dp.Name()).Replace(t, i)
+		fptest.LogYgot(t, dp.String(), d.Interface(dp.Name()), i)
+	}
+}
+
+// configureATE configures port1 and port2 on the ATE, and waits for the
+// DUT ports to be up.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice, dut *ondatra.DUTDevice) *ondatra.ATETopology {
+	top := ate.Topology().New()
+	atePort1.AddToATE(top, ate.Port(t, "port1"), &dutPort1)
+	atePort2.AddToATE(top, ate.Port(t, "port2"), &dutPort2)
+	traffic.StartProtocolsAndAwait(t, ate, top, &traffic.Readiness{
+		DUT:       dut,
+		Neighbors: map[string]*attrs.Attributes{"port1": &atePort1, "port2": &atePort2},
+	})
+	return top
+}
+
+// operStatusPath returns the gNMI path of the oper-status of the
+// interface.
+func operStatusPath(name string) *gpb.Path {
+	return &gpb.Path{Elem: []*gpb.PathElem{
+		{Name: "interfaces"},
+		{Name: "interface", Key: map[string]string{"name": name}},
+		{Name: "state"},
+		{Name: "oper-status"},
+	}}
+}
+
+// awaitChange waits for the ON_CHANGE notification setting the
+// oper-status of the path to the given value, for a change requested
+// at start, and checks it arrives within notifyDelay.
+func awaitChange(t *testing.T, c *onchange.Collector, path, want string, start time.Time) {
+	t.Helper()
+	u, err := c.Await(path, want, start, notifyDelay)
+	if err != nil {
+		t.Fatalf("ON_CHANGE notification not received: %v", err)
+	}
+	t.Logf("Got %s %s %v after the change", u.Path, u.String(), u.Received.Sub(start).Round(time.Millisecond))
+	if u.Path != path {
+		t.Errorf("ON_CHANGE notification path got %q, want %q", u.Path, path)
+	}
+}
+
+func TestOperStatusOnChange(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	configureDUT(t, dut)
+
+	ate := ondatra.ATE(t, "ate")
+	top := configureATE(t, ate, dut)
+	defer top.StopProtocols(t)
+
+	dp1 := dut.Port(t, "port1")
+	dp2 := dut.Port(t, "port2")
+	p1, p2 := operStatusPath(dp1.Name()), operStatusPath(dp2.Name())
+	path1, err := ygot.PathToString(p1)
+	if err != nil {
+		t.Fatalf("Cannot format path %v: %v", p1, err)
+	}
+	path2, err := ygot.PathToString(p2)
+	if err != nil {
+		t.Fatalf("Cannot format path %v: %v", p2, err)
+	}
+
+	c := onchange.Subscribe(t, dut, p1, p2)
+	defer c.Close()
+
+	t.Run("InitialSync", func(t *testing.T) {
+		initial := c.Initial()
+		for _, path := range []string{path1, path2} {
+			u, ok := initial[path]
+			if !ok {
+				t.Errorf("No update of %s before the sync response", path)
+				continue
+			}
+			if got := u.String(); got != "UP" {
+				t.Errorf("Initial update of %s got %s, want UP", path, got)
+			}
+		}
+	})
+
+	synced := time.Now()
+	// The port is disabled by the test rather than the subtest, so that
+	// it is only re-enabled on cleanup if the test fails before doing so.
+	start := link.DisableDUTPort(t, dut, dp1)
+	t.Run("Down", func(t *testing.T) {
+		awaitChange(t, c, path1, "DOWN", start)
+	})
+	start = link.EnableDUTPort(t, dut, dp1)
+	t.Run("Up", func(t *testing.T) {
+		awaitChange(t, c, path1, "UP", start)
+	})
+	time.Sleep(settleTime)
+
+	if err := c.Err(); err != nil {
+		t.Errorf("Subscription ended early: %v", err)
+	}
+	t.Run("UntouchedPort", func(t *testing.T) {
+		for _, u := range c.Changes(path2, synced) {
+			t.Errorf("Spurious update of untouched %s to %s at %v", u.Path, u.String(), u.Timestamp)
+		}
+	})
+	t.Run("MonotonicTimestamps", func(t *testing.T) {
+		for _, err := range onchange.NonMonotonic(c.Updates()) {
+			t.Error(err)
+		}
+	})
+}
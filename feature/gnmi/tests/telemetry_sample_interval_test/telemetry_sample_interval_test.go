@@ -0,0 +1,48 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telemetry_sample_interval_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/samplecheck"
+	"github.com/openconfig/ondatra"
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// TestCountersSampleInterval subscribes to the counters of a DUT port in
+// SAMPLE mode, and verifies that they are streamed at the requested
+// sample interval.
+//
+// Topology:
+//
+//	dut:port1
+func TestCountersSampleInterval(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	dp := dut.Port(t, "port1")
+	path := fmt.Sprintf("/interfaces/interface[name=%s]/state/counters", dp.Name())
+
+	for _, interval := range []time.Duration{10 * time.Second, 30 * time.Second} {
+		t.Run(interval.String(), func(t *testing.T) {
+			samplecheck.Verify(t, dut, []string{path}, interval, nil)
+		})
+	}
+}
@@ -0,0 +1,264 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cert_rotation_test
+
+import (
+	"context"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/certs"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/gribi"
+	"github.com/openconfig/featureprofiles/internal/sanity"
+	"github.com/openconfig/gribigo/fluent"
+	"github.com/openconfig/ondatra"
+
+	cpb "github.com/openconfig/gnoi/cert"
+)
+
+var certID = flag.String("cert_id", "", "ID of the certificate of the gRPC server of the DUT. If empty, the ID GetCertificates reports for the certificate the server presents is used.")
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+const (
+	// caValidity and certValidity are how long the generated CA and
+	// server certificates are valid for.
+	caValidity   = 7 * 24 * time.Hour
+	certValidity = 7 * 24 * time.Hour
+
+	// certTimeout is how long the DUT may take to present a certificate
+	// on new connections after a rotation step, and certInterval how
+	// often it is checked.
+	certTimeout  = time.Minute
+	certInterval = 5 * time.Second
+
+	// sampleInterval is the sample interval of the gNMI subscription
+	// opened before the rotation, and updateTimeout how long it may
+	// take to receive an update after it.
+	sampleInterval = time.Second
+	updateTimeout  = 30 * time.Second
+
+	// nhIndex and nhAddress are the gRIBI next hop programmed on the
+	// session opened before the rotation.
+	nhIndex   = 1
+	nhAddress = "192.0.2.254"
+)
+
+// serverCertID returns the ID of the certificate of the gRPC server,
+// which presents the current certificate.
+func serverCertID(t *testing.T, c cpb.CertificateManagementClient, current *x509.Certificate) string {
+	t.Helper()
+	if *certID != "" {
+		return *certID
+	}
+	resp, err := c.GetCertificates(context.Background(), &cpb.GetCertificatesRequest{})
+	if err != nil {
+		t.Fatalf("GetCertificates failed: %v", err)
+	}
+	for _, info := range resp.GetCertificateInfo() {
+		cert, err := certs.ParseCertPEM(info.GetCertificate().GetCertificate())
+		if err != nil {
+			t.Logf("Cannot parse certificate %q: %v", info.GetCertificateId(), err)
+			continue
+		}
+		if cert.Equal(current) {
+			return info.GetCertificateId()
+		}
+	}
+	t.Fatalf("GetCertificates reports no certificate matching the one the gRPC server presents, set --cert_id")
+	return ""
+}
+
+// newServerCert returns a certificate signed by the CA for the same
+// names as the current certificate, and its key.
+func newServerCert(t *testing.T, ca *certs.KeyPair, current *x509.Certificate) *certs.KeyPair {
+	t.Helper()
+	kp, err := ca.Issue(current.Subject.CommonName, current.DNSNames, current.IPAddresses, certValidity)
+	if err != nil {
+		t.Fatalf("Cannot issue server certificate: %v", err)
+	}
+	return kp
+}
+
+// startRotation starts a Rotate RPC loading the key pair as the
+// certificate with the given ID, and returns its stream once the DUT
+// acknowledged the load.
+func startRotation(ctx context.Context, t *testing.T, c cpb.CertificateManagementClient, id string, kp, ca *certs.KeyPair) cpb.CertificateManagement_RotateClient {
+	t.Helper()
+	pub, err := kp.PublicKeyPEM()
+	if err != nil {
+		t.Fatalf("Cannot encode public key: %v", err)
+	}
+	stream, err := c.Rotate(ctx)
+	if err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	if err := stream.Send(&cpb.RotateCertificateRequest{
+		RotateRequest: &cpb.RotateCertificateRequest_LoadCertificate{
+			LoadCertificate: &cpb.LoadCertificateRequest{
+				Certificate: &cpb.Certificate{
+					Type:        cpb.CertificateType_CT_X509,
+					Certificate: kp.CertPEM(),
+				},
+				KeyPair: &cpb.KeyPair{
+					PrivateKey: kp.PrivateKeyPEM(),
+					PublicKey:  pub,
+				},
+				CertificateId: id,
+				CaCertificates: []*cpb.Certificate{{
+					Type:        cpb.CertificateType_CT_X509,
+					Certificate: ca.CertPEM(),
+				}},
+			},
+		},
+	}); err != nil {
+		t.Fatalf("Rotate cannot send the certificate: %v", err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Rotate did not load the certificate: %v", err)
+	}
+	if resp.GetLoadCertificate() == nil {
+		t.Fatalf("Rotate got response %v, want a load certificate response", resp)
+	}
+	return stream
+}
+
+// finalizeRotation finalizes the rotation of the stream, and waits for
+// the DUT to end the RPC.
+func finalizeRotation(t *testing.T, stream cpb.CertificateManagement_RotateClient) {
+	t.Helper()
+	if err := stream.Send(&cpb.RotateCertificateRequest{
+		RotateRequest: &cpb.RotateCertificateRequest_FinalizeRotation{
+			FinalizeRotation: &cpb.FinalizeRequest{},
+		},
+	}); err != nil {
+		t.Fatalf("Rotate cannot send the finalize request: %v", err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatalf("Rotate cannot close: %v", err)
+	}
+	if resp, err := stream.Recv(); err != io.EOF {
+		t.Fatalf("Rotate after finalize got response %v and error %v, want the RPC to end", resp, err)
+	}
+}
+
+// awaitServerCert waits for the gRPC server of the DUT to present the
+// certificate on new connections.
+func awaitServerCert(t *testing.T, dut *ondatra.DUTDevice, want *x509.Certificate) error {
+	t.Helper()
+	start := time.Now()
+	for {
+		got, err := certs.ServerCertificate(t, dut)
+		switch {
+		case err != nil:
+			t.Logf("Cannot get the server certificate: %v", err)
+		case got.Equal(want):
+			t.Logf("Server presents certificate %q serial %v after %v", want.Subject.CommonName, want.SerialNumber, time.Since(start).Round(time.Second))
+			return nil
+		default:
+			t.Logf("Server presents certificate serial %v, want serial %v", got.SerialNumber, want.SerialNumber)
+		}
+		if time.Since(start) >= certTimeout {
+			return fmt.Errorf("server does not present certificate serial %v after %v", want.SerialNumber, certTimeout)
+		}
+		time.Sleep(certInterval)
+	}
+}
+
+func TestCertRotation(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	c := dut.RawAPIs().GNOI().Default(t).CertificateManagement()
+	original, err := certs.ServerCertificate(t, dut)
+	if err != nil {
+		t.Fatalf("Cannot get the server certificate: %v", err)
+	}
+	t.Logf("Server presents certificate %q serial %v, issued by %q", original.Subject.CommonName, original.SerialNumber, original.Issuer.CommonName)
+	id := serverCertID(t, c, original)
+	t.Logf("Rotating certificate %q", id)
+
+	ca, err := certs.NewCA("featureprofiles test CA", caValidity)
+	if err != nil {
+		t.Fatalf("Cannot create CA: %v", err)
+	}
+
+	t.Run("AbortedRotation", func(t *testing.T) {
+		kp := newServerCert(t, ca, original)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		startRotation(ctx, t, c, id, kp, ca)
+		if err := awaitServerCert(t, dut, kp.Cert); err != nil {
+			t.Fatalf("New certificate not presented before the rotation is finalized: %v", err)
+		}
+		t.Logf("Abort the rotation without finalizing it")
+		cancel()
+		if err := awaitServerCert(t, dut, original); err != nil {
+			t.Errorf("Aborted rotation not rolled back to the original certificate: %v", err)
+		}
+	})
+
+	t.Run("Rotation", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		sub, err := sanity.Subscribe(t, dut, sampleInterval)
+		if err != nil {
+			t.Fatalf("Cannot subscribe: %v", err)
+		}
+		defer sub.Close()
+		if err := sub.AwaitUpdate(time.Time{}, updateTimeout); err != nil {
+			t.Fatalf("gNMI subscription got no update before the rotation: %v", err)
+		}
+		gribic := &gribi.Client{DUT: dut, Persistence: true}
+		defer gribic.Close(t)
+		if err := gribic.Start(t); err != nil {
+			t.Fatalf("gRIBI connection cannot be established: %v", err)
+		}
+		gribic.BecomeLeader(t)
+
+		kp := newServerCert(t, ca, original)
+		stream := startRotation(ctx, t, c, id, kp, ca)
+		if err := awaitServerCert(t, dut, kp.Cert); err != nil {
+			t.Fatalf("New certificate not presented before the rotation is finalized: %v", err)
+		}
+		// The timestamps of the updates are compared with the last one
+		// received before the rotation is finalized, rather than the
+		// local time, in case the clock of the DUT is off.
+		finalized, _ := sub.State()
+		finalizeRotation(t, stream)
+
+		t.Run("NewConnections", func(t *testing.T) {
+			if err := awaitServerCert(t, dut, kp.Cert); err != nil {
+				t.Errorf("New certificate not presented after the rotation is finalized: %v", err)
+			}
+		})
+		t.Run("ExistingGNMISession", func(t *testing.T) {
+			if err := sub.AwaitUpdate(finalized, updateTimeout); err != nil {
+				t.Errorf("gNMI subscription opened before the rotation got no update after it: %v", err)
+			}
+		})
+		t.Run("ExistingGRIBISession", func(t *testing.T) {
+			gribic.AddNH(t, nhIndex, nhAddress, *deviations.DefaultNetworkInstance, fluent.InstalledInRIB)
+			gribic.Flush(t)
+		})
+	})
+}
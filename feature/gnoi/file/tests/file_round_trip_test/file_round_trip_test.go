@@ -0,0 +1,264 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file_round_trip_test
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/transfer"
+	"github.com/openconfig/ondatra"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	fpb "github.com/openconfig/gnoi/file"
+	tpb "github.com/openconfig/gnoi/types"
+)
+
+var (
+	fileDir      = flag.String("file_dir", "", "Directory of the DUT to write the test files to. If empty, a writable directory is chosen by the DUT vendor.")
+	forbiddenDir = flag.String("forbidden_dir", "/proc", "Directory of the DUT outside those File.Put is allowed to write to.")
+	largeFileMB  = flag.Int("large_file_mb", 50, "Size in megabytes of the large file streamed to the DUT.")
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+const (
+	// smallFileSize is the size of the file of the round trip, which
+	// fits in a few chunks.
+	smallFileSize = 200 * 1024
+	// permissions are the permissions of the files written, in the
+	// octal digits gNOI expects.
+	permissions = 644
+	hashMethod  = tpb.HashType_MD5
+
+	// rpcTimeout is how long the RPCs for the small file may take, and
+	// largeRPCTimeout those for the large file.
+	rpcTimeout      = time.Minute
+	largeRPCTimeout = 10 * time.Minute
+)
+
+// writableDirs are the directories of the DUT the test files are
+// written to by vendor, unless --file_dir is set.
+var writableDirs = map[ondatra.Vendor]string{
+	ondatra.ARISTA:  "/mnt/flash",
+	ondatra.CISCO:   "/misc/disk1",
+	ondatra.JUNIPER: "/var/tmp",
+	ondatra.NOKIA:   "/tmp",
+}
+
+// writableDir returns the directory of the DUT to write the test files
+// to.
+func writableDir(t *testing.T, dut *ondatra.DUTDevice) string {
+	t.Helper()
+	if *fileDir != "" {
+		return *fileDir
+	}
+	dir, ok := writableDirs[dut.Vendor()]
+	if !ok {
+		t.Fatalf("No writable directory known for DUT vendor %v, set --file_dir", dut.Vendor())
+	}
+	return dir
+}
+
+// content returns a reader of size bytes of pseudo-random content,
+// which is the same for the same seed.
+func content(seed, size int64) io.Reader {
+	return io.LimitReader(rand.New(rand.NewSource(seed)), size)
+}
+
+// put writes the content read from r to the remote file in chunks,
+// followed by its hash, and returns the hash.
+func put(ctx context.Context, c fpb.FileClient, remoteFile string, r io.Reader) (*tpb.HashType, error) {
+	stream, err := c.Put(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Once the DUT fails the RPC, Send returns io.EOF and CloseAndRecv
+	// returns the error of the RPC.
+	send := func(req *fpb.PutRequest) error {
+		if err := stream.Send(req); err != io.EOF {
+			return err
+		}
+		_, err := stream.CloseAndRecv()
+		return err
+	}
+	if err := send(&fpb.PutRequest{
+		Request: &fpb.PutRequest_Open{
+			Open: &fpb.PutRequest_Details{
+				RemoteFile:  remoteFile,
+				Permissions: permissions,
+			},
+		},
+	}); err != nil {
+		return nil, err
+	}
+	h, err := transfer.Send(r, transfer.ChunkSize, hashMethod, func(b []byte) error {
+		return send(&fpb.PutRequest{Request: &fpb.PutRequest_Contents{Contents: b}})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := send(&fpb.PutRequest{Request: &fpb.PutRequest_Hash{Hash: h}}); err != nil {
+		return nil, err
+	}
+	if _, err := stream.CloseAndRecv(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// get reads the remote file, checks the content received against the
+// hash the DUT sends, which must use hashMethod, and returns that hash
+// and the size received.
+func get(ctx context.Context, c fpb.FileClient, remoteFile string) (*tpb.HashType, int64, error) {
+	stream, err := c.Get(ctx, &fpb.GetRequest{RemoteFile: remoteFile})
+	if err != nil {
+		return nil, 0, err
+	}
+	h, err := transfer.NewHash(hashMethod)
+	if err != nil {
+		return nil, 0, err
+	}
+	var size int64
+	var want *tpb.HashType
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		switch r := resp.GetResponse().(type) {
+		case *fpb.GetResponse_Contents:
+			h.Write(r.Contents)
+			size += int64(len(r.Contents))
+		case *fpb.GetResponse_Hash:
+			want = r.Hash
+		}
+	}
+	if want == nil {
+		return nil, 0, status.Error(codes.DataLoss, "no hash received")
+	}
+	if err := transfer.Verify(h, hashMethod, want); err != nil {
+		return nil, 0, err
+	}
+	return want, size, nil
+}
+
+// stat returns the stat of the remote file.
+func stat(ctx context.Context, c fpb.FileClient, remoteFile string) (*fpb.StatInfo, error) {
+	resp, err := c.Stat(ctx, &fpb.StatRequest{Path: remoteFile})
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range resp.GetStats() {
+		if s.GetPath() == remoteFile {
+			return s, nil
+		}
+	}
+	// Not a NotFound status, since the DUT must fail the RPC for a file
+	// that does not exist.
+	return nil, fmt.Errorf("no stat for %s in %v", remoteFile, resp)
+}
+
+// roundTrip puts a file of the given size to the remote file, checks
+// its stat, gets it back, removes it and checks it is gone.
+func roundTrip(t *testing.T, c fpb.FileClient, remoteFile string, size int64, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	t.Logf("Put %d bytes to %s", size, remoteFile)
+	start := time.Now()
+	sent, err := put(ctx, c, remoteFile, content(size, size))
+	if err != nil {
+		t.Fatalf("File.Put of %s failed: %v", remoteFile, err)
+	}
+	t.Logf("File.Put of %s took %v", remoteFile, time.Since(start).Round(time.Millisecond))
+	removed := false
+	defer func() {
+		if !removed {
+			c.Remove(context.Background(), &fpb.RemoveRequest{RemoteFile: remoteFile})
+		}
+	}()
+
+	s, err := stat(ctx, c, remoteFile)
+	if err != nil {
+		t.Fatalf("File.Stat of %s failed: %v", remoteFile, err)
+	}
+	if got := s.GetSize(); got != uint64(size) {
+		t.Errorf("File.Stat of %s size got %d, want %d", remoteFile, got, size)
+	}
+	if got := s.GetPermissions(); got != permissions {
+		t.Errorf("File.Stat of %s permissions got %d, want %d", remoteFile, got, permissions)
+	}
+
+	got, gotSize, err := get(ctx, c, remoteFile)
+	if err != nil {
+		t.Fatalf("File.Get of %s failed: %v", remoteFile, err)
+	}
+	if gotSize != size {
+		t.Errorf("File.Get of %s got %d bytes, want %d", remoteFile, gotSize, size)
+	}
+	if !bytes.Equal(got.GetHash(), sent.GetHash()) {
+		t.Errorf("File.Get of %s got hash %x, want %x put", remoteFile, got.GetHash(), sent.GetHash())
+	}
+
+	if _, err := c.Remove(ctx, &fpb.RemoveRequest{RemoteFile: remoteFile}); err != nil {
+		t.Fatalf("File.Remove of %s failed: %v", remoteFile, err)
+	}
+	removed = true
+	if _, err := stat(ctx, c, remoteFile); status.Code(err) != codes.NotFound {
+		t.Errorf("File.Stat of removed %s got error %v, want NotFound", remoteFile, err)
+	}
+}
+
+func TestFileRoundTrip(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	c := dut.RawAPIs().GNOI().Default(t).File()
+	dir := writableDir(t, dut)
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		roundTrip(t, c, path.Join(dir, "fp_file_round_trip.bin"), smallFileSize, rpcTimeout)
+	})
+
+	t.Run("LargeFile", func(t *testing.T) {
+		roundTrip(t, c, path.Join(dir, "fp_file_large.bin"), int64(*largeFileMB)<<20, largeRPCTimeout)
+	})
+
+	t.Run("PutForbidden", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout)
+		defer cancel()
+		remoteFile := path.Join(*forbiddenDir, "fp_file_forbidden.bin")
+		_, err := put(ctx, c, remoteFile, content(smallFileSize, smallFileSize))
+		if code := status.Code(err); code != codes.PermissionDenied {
+			t.Errorf("File.Put of %s got code %v, want PermissionDenied: %v", remoteFile, code, err)
+		}
+		if err == nil {
+			c.Remove(ctx, &fpb.RemoveRequest{RemoteFile: remoteFile})
+		}
+	})
+}
@@ -24,6 +24,7 @@ import (
 	"time"
 
 	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/transfer"
 	closer "github.com/openconfig/gocloser"
 	"github.com/openconfig/ondatra"
 	"github.com/openconfig/ondatra/telemetry"
@@ -33,6 +34,7 @@ import (
 
 	ospb "github.com/openconfig/gnoi/os"
 	spb "github.com/openconfig/gnoi/system"
+	tpb "github.com/openconfig/gnoi/types"
 )
 
 var packageReader func(context.Context) (io.ReadCloser, error) = func(ctx context.Context) (io.ReadCloser, error) {
@@ -276,28 +278,16 @@ func (tc *testCase) verifyInstall(ctx context.Context, t *testing.T) {
 }
 
 func transferContent(ic ospb.OS_InstallClient, reader io.ReadCloser) error {
-	// The gNOI SetPackage operation sets the maximum chunk size at 64K,
-	// so assuming the install operation allows for up to the same size.
-	buf := make([]byte, 64*1024)
 	defer closer.CloseAndLog(reader.Close, "error closing package file")
-	for {
-		n, err := reader.Read(buf)
-		if n > 0 {
-			tc := &ospb.InstallRequest{
-				Request: &ospb.InstallRequest_TransferContent{
-					TransferContent: buf[0:n],
-				},
-			}
-			if err := ic.Send(tc); err != nil {
-				return err
-			}
-		}
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return err
-		}
+	// OS.Install does not verify a hash of the content.
+	if _, err := transfer.Send(reader, transfer.ChunkSize, tpb.HashType_UNSPECIFIED, func(b []byte) error {
+		return ic.Send(&ospb.InstallRequest{
+			Request: &ospb.InstallRequest_TransferContent{
+				TransferContent: b,
+			},
+		})
+	}); err != nil {
+		return err
 	}
 	te := &ospb.InstallRequest{
 		Request: &ospb.InstallRequest_TransferEnd{
@@ -17,8 +17,10 @@ package chassis_reboot_status_and_cancel_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/sanity"
 	spb "github.com/openconfig/gnoi/system"
 	"github.com/openconfig/ondatra"
 )
@@ -26,6 +28,11 @@ import (
 const (
 	oneMinuteInNanoSecond = 6e10
 	rebootDelay           = 120
+	// cancelDelay is the delay of the reboot cancelled by
+	// TestCancelReboot, and cancelGrace how long past the time it was
+	// scheduled for the DUT is checked not to have rebooted.
+	cancelDelay = 60 * time.Second
+	cancelGrace = 60 * time.Second
 )
 
 func TestMain(m *testing.M) {
@@ -47,6 +54,8 @@ func TestMain(m *testing.M) {
 //     - Verify the reboot status is active.
 //   - Send reboot cancel request.
 //     - Verify the reboot status is not active.
+//   - Wait past the time the reboot was scheduled for.
+//     - Verify the DUT is reachable and its boot time unchanged.
 //
 // Topology:
 //   dut:port1 <--> ate:port1
@@ -131,10 +140,11 @@ func TestCancelReboot(t *testing.T) {
 
 	rebootRequest := &spb.RebootRequest{
 		Method:  spb.RebootMethod_COLD,
-		Delay:   rebootDelay * oneMinuteInNanoSecond,
+		Delay:   uint64(cancelDelay.Nanoseconds()),
 		Message: "Reboot chassis with delay",
 		Force:   true,
 	}
+	bootTime := dut.Telemetry().System().BootTime().Get(t)
 
 	t.Logf("Cancel reboot request before the test")
 	rebootCancel, err := gnoiClient.System().CancelReboot(context.Background(), &spb.CancelRebootRequest{})
@@ -174,4 +184,13 @@ func TestCancelReboot(t *testing.T) {
 	if rebootStatus.GetActive() {
 		t.Errorf("rebootStatus.GetActive(): got %v, want false", rebootStatus.GetActive())
 	}
+
+	t.Logf("Wait until %v past the cancelled reboot time", cancelGrace)
+	time.Sleep(cancelDelay + cancelGrace)
+	if err := sanity.Probe(t, dut, sanity.GNOI); err != nil {
+		t.Fatalf("DUT unreachable after the cancelled reboot time: %v", err)
+	}
+	if got := dut.Telemetry().System().BootTime().Get(t); got != bootTime {
+		t.Errorf("DUT boot time: got %v, want %v unchanged since the reboot was cancelled", got, bootTime)
+	}
 }
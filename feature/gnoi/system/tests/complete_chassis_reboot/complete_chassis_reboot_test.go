@@ -22,6 +22,7 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/sanity"
 	spb "github.com/openconfig/gnoi/system"
 	"github.com/openconfig/ondatra"
 	"github.com/openconfig/testt"
@@ -35,6 +36,9 @@ const (
 	maxRebootTime = 900
 	// Maximum wait time for all components to be in responsive state
 	maxCompWaitTime = 600
+	// Maximum wait time for the gNMI, gRIBI and gNOI services to answer
+	// once the DUT is back.
+	maxServiceWaitTime = 5 * time.Minute
 )
 
 func TestMain(m *testing.M) {
@@ -59,6 +63,8 @@ func TestMain(m *testing.M) {
 //   - Verify the following items.
 //     - DUT boot time is updated after reboot.
 //     - DUT software version is the same after the reboot.
+//   - Verify in both cases that the gNMI, gRIBI and gNOI services of the
+//     DUT answer RPCs on new connections after the reboot.
 //
 // Topology:
 //   dut:port1 <--> ate:port1
@@ -167,6 +173,11 @@ func TestChassisReboot(t *testing.T) {
 			}
 			t.Logf("Device boot time: %.2f seconds", time.Since(startReboot).Seconds())
 
+			if err := sanity.AwaitServices(t, dut, maxServiceWaitTime); err != nil {
+				t.Fatalf("DUT services did not recover after the reboot: %v", err)
+			}
+			t.Logf("DUT services recovered %.2f seconds after reboot started", time.Since(startReboot).Seconds())
+
 			bootTimeAfterReboot := dut.Telemetry().System().BootTime().Get(t)
 			t.Logf("DUT boot time after reboot: %v", bootTimeAfterReboot)
 			if bootTimeAfterReboot <= bootTimeBeforeReboot {
@@ -0,0 +1,122 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gnmi_process_restart_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/process"
+	"github.com/openconfig/featureprofiles/internal/sanity"
+	"github.com/openconfig/ondatra"
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// The testbed consists of dut:port1, which is configured before the
+// gNMI process is restarted.
+const (
+	ipv4PrefixLen = 30
+
+	// sampleInterval is the sample interval of the subscriptions, and
+	// updateTimeout how long they may take to receive an update.
+	sampleInterval = time.Second
+	updateTimeout  = 30 * time.Second
+	// endTimeout is how long the subscription opened before the restart
+	// may take to end after it.
+	endTimeout = time.Minute
+	// restartTimeout is how long the gNMI server may take to become
+	// reachable again after the process is restarted.
+	restartTimeout = 5 * time.Minute
+)
+
+var dutPort1 = attrs.Attributes{
+	Desc:    "dutPort1 configured before the gNMI process restart",
+	IPv4:    "192.0.2.1",
+	IPv4Len: ipv4PrefixLen,
+}
+
+func TestGNMIProcessRestart(t *testing.T) {
+	if *deviations.GNMIProcessRestartUnsupported {
+		t.Skip("Skipping due to --deviation_gnmi_process_restart_unsupported")
+	}
+	dut := ondatra.DUT(t, "dut")
+	name := process.Name(t, dut, process.GNMI)
+
+	dp := dut.Port(t, "port1")
+	d := dut.Config()
+	i := dutPort1.NewInterface(dp.Name())
+	d.Interface(dp.Name()).Replace(t, i)
+	fptest.LogYgot(t, dp.String(), d.Interface(dp.Name()), i)
+
+	bootTime := dut.Telemetry().System().BootTime().Get(t)
+	sub, err := sanity.Subscribe(t, dut, sampleInterval)
+	if err != nil {
+		t.Fatalf("Cannot subscribe: %v", err)
+	}
+	defer sub.Close()
+	if err := sub.AwaitUpdate(time.Time{}, updateTimeout); err != nil {
+		t.Fatalf("gNMI subscription got no update before the restart: %v", err)
+	}
+	beforeRestart, _ := sub.State()
+
+	process.Restart(t, dut, name)
+	start := time.Now()
+
+	t.Run("SubscriptionEnded", func(t *testing.T) {
+		if err := sub.AwaitError(endTimeout); err == nil {
+			t.Errorf("gNMI subscription opened before restarting process %s still open after %v", name, endTimeout)
+		} else {
+			t.Logf("gNMI subscription ended %v after restarting process %s: %v", time.Since(start).Round(time.Second), name, err)
+		}
+	})
+
+	if err := sanity.AwaitServices(t, dut, restartTimeout, sanity.GNMI); err != nil {
+		t.Fatalf("gNMI server not reachable after restarting process %s: %v", name, err)
+	}
+	t.Logf("gNMI server reachable %v after restarting process %s", time.Since(start).Round(time.Second), name)
+
+	t.Run("NewSubscription", func(t *testing.T) {
+		sub, err := sanity.Subscribe(t, dut, sampleInterval)
+		if err != nil {
+			t.Fatalf("Cannot subscribe after the restart: %v", err)
+		}
+		defer sub.Close()
+		if err := sub.AwaitUpdate(beforeRestart, updateTimeout); err != nil {
+			t.Errorf("gNMI subscription opened after the restart got no fresh update: %v", err)
+		}
+	})
+
+	t.Run("NoReboot", func(t *testing.T) {
+		if got := dut.Telemetry().System().BootTime().Get(t); got != bootTime {
+			t.Errorf("DUT boot-time got %d, want %d unchanged by restarting process %s", got, bootTime, name)
+		}
+	})
+
+	t.Run("ConfigPreserved", func(t *testing.T) {
+		got := d.Interface(dp.Name()).Get(t)
+		if got.GetDescription() != dutPort1.Desc {
+			t.Errorf("DUT %s description got %q, want %q configured before the restart", dp, got.GetDescription(), dutPort1.Desc)
+		}
+		if got.GetSubinterface(0).GetIpv4().GetAddress(dutPort1.IPv4) == nil {
+			t.Errorf("DUT %s IPv4 address %s configured before the restart is missing", dp, dutPort1.IPv4)
+		}
+	})
+}
@@ -18,12 +18,15 @@ import (
 	"context"
 	"io"
 	"testing"
+	"time"
 
 	"github.com/openconfig/featureprofiles/internal/fptest"
 	spb "github.com/openconfig/gnoi/system"
 	tpb "github.com/openconfig/gnoi/types"
 	"github.com/openconfig/ondatra"
 	"github.com/openconfig/ondatra/netutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const (
@@ -43,6 +46,11 @@ const (
 	minimumMaxTime           = 1
 	//StdDeviation would be 0 if we only send 1 ping.
 	minimumStdDev = 1
+	// Above the largest IP packet.
+	oversizedPingRequestSize = 65536
+	tracerouteMaxTTL         = 3
+	// How long a ping or traceroute may take before it is considered hanging.
+	rpcTimeout = time.Minute
 )
 
 func TestMain(m *testing.M) {
@@ -62,6 +70,11 @@ func TestMain(m *testing.M) {
 //     - l3protocol: Layer3 protocol IPv4 or IPv6 for the ping.
 //  - Verify echo replies are correct.
 //     - Echo reply contains echo reply source, RTT time, bytes received, packet sequence and ttl.
+//  - Send gNOI ping request with a size above the largest IP packet.
+//     - Verify the ping fails with an InvalidArgument or OutOfRange error.
+//  - Send gNOI traceroute request to the loopback with a max_ttl of 3.
+//     - Verify hop 1 is the loopback address with a round trip time, and
+//       no hop exceeds the max_ttl.
 //  - Verify ping summary stats in the response.
 //     - source: Source of received bytes. It is the source address of ping request.
 //     - sent: Total packets sent.
@@ -94,9 +107,10 @@ func TestMain(m *testing.M) {
 //    https://github.com/fullstorydev/grpcurl
 //
 
-func TestGNOIPing(t *testing.T) {
-	dut := ondatra.DUT(t, "dut")
-
+// loopbackAddresses returns the first IPv4 and IPv6 addresses of the
+// loopback interface of the DUT.
+func loopbackAddresses(t *testing.T, dut *ondatra.DUTDevice) (string, string) {
+	t.Helper()
 	lbIntf := netutil.LoopbackInterface(t, dut, 0)
 	lo0 := dut.Telemetry().Interface(lbIntf).Subinterface(0)
 	ipv4Addrs := lo0.Ipv4().AddressAny().Get(t)
@@ -109,16 +123,22 @@ func TestGNOIPing(t *testing.T) {
 	if len(ipv6Addrs) == 0 {
 		t.Fatalf("Failed to get a valid IPv6 loopback address: %+v", ipv6Addrs)
 	}
+	return ipv4Addrs[0].GetIp(), ipv6Addrs[0].GetIp()
+}
+
+func TestGNOIPing(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	ipv4Addr, ipv6Addr := loopbackAddresses(t, dut)
 
 	commonExpectedIPv4Reply := &spb.PingResponse{
-		Source:   ipv4Addrs[0].GetIp(),
+		Source:   ipv4Addr,
 		Time:     minimumPingTime,
 		Bytes:    minimumPingReplySize,
 		Sequence: minimumPingReplySequence,
 		Ttl:      minimumPingReplyTTL,
 	}
 	commonExpectedIPv6Reply := &spb.PingResponse{
-		Source:   ipv6Addrs[0].GetIp(),
+		Source:   ipv6Addr,
 		Time:     minimumPingTime,
 		Bytes:    minimumPingReplySize,
 		Sequence: minimumPingReplySequence,
@@ -141,38 +161,38 @@ func TestGNOIPing(t *testing.T) {
 	}{{
 		desc: "Check ping with IPv4 destination",
 		pingRequest: &spb.PingRequest{
-			Destination: ipv4Addrs[0].GetIp(),
+			Destination: ipv4Addr,
 		},
 		expectedReply: commonExpectedIPv4Reply,
 		expectedStats: commonExpectedReplyStats,
 	}, {
 		desc: "Check ping with IPv6 destination",
 		pingRequest: &spb.PingRequest{
-			Destination: ipv6Addrs[0].GetIp(),
+			Destination: ipv6Addr,
 		},
 		expectedReply: commonExpectedIPv6Reply,
 		expectedStats: commonExpectedReplyStats,
 	}, {
 		desc: "Check ping with IPv4 source",
 		pingRequest: &spb.PingRequest{
-			Destination: ipv4Addrs[0].GetIp(),
-			Source:      ipv4Addrs[0].GetIp(),
+			Destination: ipv4Addr,
+			Source:      ipv4Addr,
 		},
 		expectedReply: commonExpectedIPv4Reply,
 		expectedStats: commonExpectedReplyStats,
 	}, {
 		desc: "Check ping with IPv6 source",
 		pingRequest: &spb.PingRequest{
-			Destination: ipv6Addrs[0].GetIp(),
-			Source:      ipv6Addrs[0].GetIp(),
+			Destination: ipv6Addr,
+			Source:      ipv6Addr,
 		},
 		expectedReply: commonExpectedIPv6Reply,
 		expectedStats: commonExpectedReplyStats,
 	}, {
 		desc: "Check ping with IPv4 l3protocol",
 		pingRequest: &spb.PingRequest{
-			Destination: ipv4Addrs[0].GetIp(),
-			Source:      ipv4Addrs[0].GetIp(),
+			Destination: ipv4Addr,
+			Source:      ipv4Addr,
 			L3Protocol:  tpb.L3Protocol_IPV4,
 		},
 		expectedReply: commonExpectedIPv4Reply,
@@ -180,8 +200,8 @@ func TestGNOIPing(t *testing.T) {
 	}, {
 		desc: "Check ping with IPv6 l3protocol",
 		pingRequest: &spb.PingRequest{
-			Destination: ipv6Addrs[0].GetIp(),
-			Source:      ipv6Addrs[0].GetIp(),
+			Destination: ipv6Addr,
+			Source:      ipv6Addr,
 			L3Protocol:  tpb.L3Protocol_IPV6,
 		},
 		expectedReply: commonExpectedIPv6Reply,
@@ -189,8 +209,8 @@ func TestGNOIPing(t *testing.T) {
 	}, {
 		desc: "Check ping with IPv4 interval and wait",
 		pingRequest: &spb.PingRequest{
-			Destination: ipv4Addrs[0].GetIp(),
-			Source:      ipv4Addrs[0].GetIp(),
+			Destination: ipv4Addr,
+			Source:      ipv4Addr,
 			L3Protocol:  tpb.L3Protocol_IPV4,
 			Interval:    123456,
 			Wait:        12345678,
@@ -200,8 +220,8 @@ func TestGNOIPing(t *testing.T) {
 	}, {
 		desc: "Check ping with IPv6 interval and wait",
 		pingRequest: &spb.PingRequest{
-			Destination: ipv6Addrs[0].GetIp(),
-			Source:      ipv6Addrs[0].GetIp(),
+			Destination: ipv6Addr,
+			Source:      ipv6Addr,
 			L3Protocol:  tpb.L3Protocol_IPV6,
 			Interval:    1234567,
 			Wait:        123456789,
@@ -211,8 +231,8 @@ func TestGNOIPing(t *testing.T) {
 	}, {
 		desc: "Check ping with IPv4 do_not_resolve",
 		pingRequest: &spb.PingRequest{
-			Destination:  ipv4Addrs[0].GetIp(),
-			Source:       ipv4Addrs[0].GetIp(),
+			Destination:  ipv4Addr,
+			Source:       ipv4Addr,
 			L3Protocol:   tpb.L3Protocol_IPV4,
 			Interval:     123456,
 			Wait:         12345678,
@@ -223,8 +243,8 @@ func TestGNOIPing(t *testing.T) {
 	}, {
 		desc: "Check ping with IPv6 do_not_resolve",
 		pingRequest: &spb.PingRequest{
-			Destination:  ipv6Addrs[0].GetIp(),
-			Source:       ipv6Addrs[0].GetIp(),
+			Destination:  ipv6Addr,
+			Source:       ipv6Addr,
 			L3Protocol:   tpb.L3Protocol_IPV6,
 			Interval:     1234567,
 			Wait:         123456789,
@@ -235,8 +255,8 @@ func TestGNOIPing(t *testing.T) {
 	}, {
 		desc: "Check ping with IPv4 DF bit",
 		pingRequest: &spb.PingRequest{
-			Destination:   ipv4Addrs[0].GetIp(),
-			Source:        ipv4Addrs[0].GetIp(),
+			Destination:   ipv4Addr,
+			Source:        ipv4Addr,
 			L3Protocol:    tpb.L3Protocol_IPV4,
 			Interval:      123456,
 			Wait:          12345678,
@@ -247,8 +267,8 @@ func TestGNOIPing(t *testing.T) {
 	}, {
 		desc: "Check ping with IPv4 count",
 		pingRequest: &spb.PingRequest{
-			Destination: ipv4Addrs[0].GetIp(),
-			Source:      ipv4Addrs[0].GetIp(),
+			Destination: ipv4Addr,
+			Source:      ipv4Addr,
 			L3Protocol:  tpb.L3Protocol_IPV4,
 			Interval:    123456,
 			Wait:        12345678,
@@ -266,8 +286,8 @@ func TestGNOIPing(t *testing.T) {
 	}, {
 		desc: "Check ping with IPv6 count",
 		pingRequest: &spb.PingRequest{
-			Destination: ipv6Addrs[0].GetIp(),
-			Source:      ipv6Addrs[0].GetIp(),
+			Destination: ipv6Addr,
+			Source:      ipv6Addr,
 			L3Protocol:  tpb.L3Protocol_IPV6,
 			Interval:    1234567,
 			Wait:        123456789,
@@ -285,8 +305,8 @@ func TestGNOIPing(t *testing.T) {
 	}, {
 		desc: "Check ping with IPv4 minimum packet size",
 		pingRequest: &spb.PingRequest{
-			Destination: ipv4Addrs[0].GetIp(),
-			Source:      ipv4Addrs[0].GetIp(),
+			Destination: ipv4Addr,
+			Source:      ipv4Addr,
 			L3Protocol:  tpb.L3Protocol_IPV4,
 			Interval:    123456,
 			Wait:        12345678,
@@ -294,7 +314,7 @@ func TestGNOIPing(t *testing.T) {
 			Size:        minimumPingRequestSize,
 		},
 		expectedReply: &spb.PingResponse{
-			Source:   ipv4Addrs[0].GetIp(),
+			Source:   ipv4Addr,
 			Time:     minimumPingTime,
 			Bytes:    minimumPingRequestSize - icmpHeaderSize,
 			Sequence: minimumPingReplySequence,
@@ -304,8 +324,8 @@ func TestGNOIPing(t *testing.T) {
 	}, {
 		desc: "Check ping with IPv4 maximum default packet size",
 		pingRequest: &spb.PingRequest{
-			Destination: ipv4Addrs[0].GetIp(),
-			Source:      ipv4Addrs[0].GetIp(),
+			Destination: ipv4Addr,
+			Source:      ipv4Addr,
 			L3Protocol:  tpb.L3Protocol_IPV4,
 			Interval:    123456,
 			Wait:        12345678,
@@ -313,7 +333,7 @@ func TestGNOIPing(t *testing.T) {
 			Size:        maximumDefaultPingRequestSize,
 		},
 		expectedReply: &spb.PingResponse{
-			Source:   ipv4Addrs[0].GetIp(),
+			Source:   ipv4Addr,
 			Time:     minimumPingTime,
 			Bytes:    maximumDefaultPingRequestSize - icmpHeaderSize,
 			Sequence: minimumPingReplySequence,
@@ -323,8 +343,8 @@ func TestGNOIPing(t *testing.T) {
 	}, {
 		desc: "Check ping with IPv4 maximum packet size",
 		pingRequest: &spb.PingRequest{
-			Destination: ipv4Addrs[0].GetIp(),
-			Source:      ipv4Addrs[0].GetIp(),
+			Destination: ipv4Addr,
+			Source:      ipv4Addr,
 			L3Protocol:  tpb.L3Protocol_IPV4,
 			Interval:    123456,
 			Wait:        12345678,
@@ -332,7 +352,7 @@ func TestGNOIPing(t *testing.T) {
 			Size:        maximumPingRequestSize,
 		},
 		expectedReply: &spb.PingResponse{
-			Source:   ipv4Addrs[0].GetIp(),
+			Source:   ipv4Addr,
 			Time:     minimumPingTime,
 			Bytes:    maximumPingRequestSize - icmpHeaderSize,
 			Sequence: minimumPingReplySequence,
@@ -342,8 +362,8 @@ func TestGNOIPing(t *testing.T) {
 	}, {
 		desc: "Check ping with IPv6 minimum packet size",
 		pingRequest: &spb.PingRequest{
-			Destination: ipv6Addrs[0].GetIp(),
-			Source:      ipv6Addrs[0].GetIp(),
+			Destination: ipv6Addr,
+			Source:      ipv6Addr,
 			L3Protocol:  tpb.L3Protocol_IPV6,
 			Interval:    123456,
 			Wait:        12345678,
@@ -351,7 +371,7 @@ func TestGNOIPing(t *testing.T) {
 			Size:        minimumPingRequestSize,
 		},
 		expectedReply: &spb.PingResponse{
-			Source:   ipv6Addrs[0].GetIp(),
+			Source:   ipv6Addr,
 			Time:     minimumPingTime,
 			Bytes:    minimumPingRequestSize - icmpHeaderSize,
 			Sequence: minimumPingReplySequence,
@@ -361,8 +381,8 @@ func TestGNOIPing(t *testing.T) {
 	}, {
 		desc: "Check ping with IPv6 maximum default packet size",
 		pingRequest: &spb.PingRequest{
-			Destination: ipv6Addrs[0].GetIp(),
-			Source:      ipv6Addrs[0].GetIp(),
+			Destination: ipv6Addr,
+			Source:      ipv6Addr,
 			L3Protocol:  tpb.L3Protocol_IPV6,
 			Interval:    123456,
 			Wait:        12345678,
@@ -370,7 +390,7 @@ func TestGNOIPing(t *testing.T) {
 			Size:        maximumDefaultPingRequestSize,
 		},
 		expectedReply: &spb.PingResponse{
-			Source:   ipv6Addrs[0].GetIp(),
+			Source:   ipv6Addr,
 			Time:     minimumPingTime,
 			Bytes:    maximumDefaultPingRequestSize - icmpHeaderSize,
 			Sequence: minimumPingReplySequence,
@@ -380,8 +400,8 @@ func TestGNOIPing(t *testing.T) {
 	}, {
 		desc: "Check ping with IPv6 maximum packet size",
 		pingRequest: &spb.PingRequest{
-			Destination: ipv6Addrs[0].GetIp(),
-			Source:      ipv6Addrs[0].GetIp(),
+			Destination: ipv6Addr,
+			Source:      ipv6Addr,
 			L3Protocol:  tpb.L3Protocol_IPV6,
 			Interval:    123456,
 			Wait:        12345678,
@@ -389,7 +409,7 @@ func TestGNOIPing(t *testing.T) {
 			Size:        maximumPingRequestSize,
 		},
 		expectedReply: &spb.PingResponse{
-			Source:   ipv6Addrs[0].GetIp(),
+			Source:   ipv6Addr,
 			Time:     minimumPingTime,
 			Bytes:    maximumPingRequestSize - icmpHeaderSize,
 			Sequence: minimumPingReplySequence,
@@ -471,6 +491,110 @@ func TestGNOIPing(t *testing.T) {
 	}
 }
 
+func TestGNOIPingOversized(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	ipv4Addr, ipv6Addr := loopbackAddresses(t, dut)
+	gnoiClient := dut.RawAPIs().GNOI().Default(t)
+
+	for _, req := range []*spb.PingRequest{{
+		Destination: ipv4Addr,
+		L3Protocol:  tpb.L3Protocol_IPV4,
+		Count:       5,
+		Size:        oversizedPingRequestSize,
+	}, {
+		Destination: ipv6Addr,
+		L3Protocol:  tpb.L3Protocol_IPV6,
+		Count:       5,
+		Size:        oversizedPingRequestSize,
+	}} {
+		t.Run(req.GetL3Protocol().String(), func(t *testing.T) {
+			t.Logf("Sent ping request: %v", req)
+			ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout)
+			defer cancel()
+			// The error may be returned by the request or by the first
+			// response of the stream.
+			pingClient, err := gnoiClient.System().Ping(ctx, req)
+			if err == nil {
+				_, err = fetchResponses(pingClient)
+			}
+			switch code := status.Code(err); code {
+			case codes.InvalidArgument, codes.OutOfRange:
+				t.Logf("Ping failed as expected: %v", err)
+			case codes.OK:
+				t.Errorf("Ping of %d bytes: got no error, want InvalidArgument or OutOfRange", req.GetSize())
+			default:
+				t.Errorf("Ping of %d bytes: got error code %v, want InvalidArgument or OutOfRange: %v", req.GetSize(), code, err)
+			}
+		})
+	}
+}
+
+func TestGNOITraceroute(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	ipv4Addr, ipv6Addr := loopbackAddresses(t, dut)
+	gnoiClient := dut.RawAPIs().GNOI().Default(t)
+
+	for _, req := range []*spb.TracerouteRequest{{
+		Destination:  ipv4Addr,
+		L3Protocol:   tpb.L3Protocol_IPV4,
+		MaxTtl:       tracerouteMaxTTL,
+		DoNotResolve: true,
+	}, {
+		Destination:  ipv6Addr,
+		L3Protocol:   tpb.L3Protocol_IPV6,
+		MaxTtl:       tracerouteMaxTTL,
+		DoNotResolve: true,
+	}} {
+		t.Run(req.GetL3Protocol().String(), func(t *testing.T) {
+			t.Logf("Sent traceroute request: %v", req)
+			ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout)
+			defer cancel()
+			traceClient, err := gnoiClient.System().Traceroute(ctx, req)
+			if err != nil {
+				t.Fatalf("Failed to query gnoi endpoint: %v", err)
+			}
+			var responses []*spb.TracerouteResponse
+			for {
+				resp, err := traceClient.Recv()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Failed to handle gnoi traceroute client stream: %v", err)
+				}
+				responses = append(responses, resp)
+			}
+			t.Logf("Got traceroute responses: Items: %v\n, Content: %v\n\n", len(responses), responses)
+			// The first response only reports the traceroute parameters.
+			if len(responses) < 2 {
+				t.Fatalf("Number of traceroute responses: got %v, want the initial response and at least one hop", len(responses))
+			}
+			if got := responses[0].GetHops(); got != tracerouteMaxTTL {
+				t.Errorf("Traceroute hops: got %v, want %v", got, tracerouteMaxTTL)
+			}
+			firstHop := false
+			for _, resp := range responses[1:] {
+				if resp.GetHop() < 1 || resp.GetHop() > tracerouteMaxTTL {
+					t.Errorf("Traceroute hop: got %v, want within 1..%v", resp.GetHop(), tracerouteMaxTTL)
+				}
+				if resp.GetHop() != 1 {
+					continue
+				}
+				firstHop = true
+				if resp.GetAddress() != req.GetDestination() {
+					t.Errorf("Traceroute hop 1 address: got %v, want %v", resp.GetAddress(), req.GetDestination())
+				}
+				if resp.GetRtt() <= 0 {
+					t.Errorf("Traceroute hop 1 rtt: got %v, want > 0", resp.GetRtt())
+				}
+			}
+			if !firstHop {
+				t.Errorf("Traceroute to %v: got no response for hop 1", req.GetDestination())
+			}
+		})
+	}
+}
+
 func fetchResponses(c spb.System_PingClient) ([]*spb.PingResponse, error) {
 	pingResp := []*spb.PingResponse{}
 	for {
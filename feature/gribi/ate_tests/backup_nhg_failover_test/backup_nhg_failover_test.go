@@ -0,0 +1,305 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup_nhg_failover_test
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/gribi"
+	"github.com/openconfig/featureprofiles/internal/link"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/gribigo/fluent"
+	"github.com/openconfig/ondatra"
+)
+
+var (
+	maxFailoverTime = flag.Duration("max_failover_time", time.Second,
+		"Maximum traffic interruption while failing over to the backup NextHopGroup.")
+	trigger = flag.String("failover_trigger", "admin_down",
+		"How port-2 is taken down to trigger the failover: admin_down to administratively disable the DUT port via gNMI, or ate_port_down to disable the ATE port so the DUT sees loss of light.")
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1,
+// dut:port2 -> ate:port2 and dut:port3 -> ate:port3.
+//
+//   - ate:port1 -> dut:port1 subnet 192.0.2.0/30
+//   - ate:port2 -> dut:port2 subnet 192.0.2.4/30
+//   - ate:port3 -> dut:port3 subnet 192.0.2.8/30
+//
+// The destination network 203.0.113.0/24 is routed via the primary
+// NextHopGroup 10 to ate:port2, with the backup NextHopGroup 20 to
+// ate:port3.
+const (
+	ipv4PrefixLen    = 30
+	ateDstNetCIDR    = "203.0.113.0/24"
+	primaryNHIndex   = 1
+	primaryNHGIndex  = 10
+	backupNHIndex    = 2
+	backupNHGIndex   = 20
+	frameRate        = 1000 // frames per second
+	settleTime       = 5 * time.Second
+	measureTime      = 10 * time.Second
+	minRatio         = 0.99
+	maxStrayPktRatio = 1 - minRatio
+)
+
+var (
+	dutPort1 = attrs.Attributes{
+		Desc:    "dutPort1",
+		IPv4:    "192.0.2.1",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort1 = attrs.Attributes{
+		Name:    "atePort1",
+		IPv4:    "192.0.2.2",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	dutPort2 = attrs.Attributes{
+		Desc:    "dutPort2",
+		IPv4:    "192.0.2.5",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort2 = attrs.Attributes{
+		Name:    "atePort2",
+		IPv4:    "192.0.2.6",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	dutPort3 = attrs.Attributes{
+		Desc:    "dutPort3",
+		IPv4:    "192.0.2.9",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort3 = attrs.Attributes{
+		Name:    "atePort3",
+		IPv4:    "192.0.2.10",
+		IPv4Len: ipv4PrefixLen,
+	}
+)
+
+// configureDUT configures port1, port2 and port3 on the DUT.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	d := dut.Config()
+
+	p1 := dut.Port(t, "port1")
+	d.Interface(p1.Name()).Replace(t, dutPort1.NewInterface(p1.Name()))
+
+	p2 := dut.Port(t, "port2")
+	d.Interface(p2.Name()).Replace(t, dutPort2.NewInterface(p2.Name()))
+
+	p3 := dut.Port(t, "port3")
+	d.Interface(p3.Name()).Replace(t, dutPort3.NewInterface(p3.Name()))
+}
+
+// configureATE configures port1, port2 and port3 on the ATE.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice) *ondatra.ATETopology {
+	top := ate.Topology().New()
+	atePort1.AddToATE(top, ate.Port(t, "port1"), &dutPort1)
+	atePort2.AddToATE(top, ate.Port(t, "port2"), &dutPort2)
+	atePort3.AddToATE(top, ate.Port(t, "port3"), &dutPort3)
+	return top
+}
+
+// newFlow creates a flow from ate:port1 to the destination network,
+// which may be received by ate:port2 or ate:port3.
+func newFlow(ate *ondatra.ATEDevice, top *ondatra.ATETopology) *ondatra.Flow {
+	ipv4Header := ondatra.NewIPv4Header()
+	ipv4Header.DstAddressRange().
+		WithMin("203.0.113.1").
+		WithMax("203.0.113.254").
+		WithCount(254)
+
+	return ate.Traffic().NewFlow("Flow").
+		WithSrcEndpoints(top.Interfaces()[atePort1.Name]).
+		WithDstEndpoints(top.Interfaces()[atePort2.Name], top.Interfaces()[atePort3.Name]).
+		WithHeaders(ondatra.NewEthernetHeader(), ipv4Header)
+}
+
+// egress is the number of packets sent by a DUT port and received by
+// the ATE port connected to it during a measurement.
+type egress struct {
+	dutOutPkts uint64
+	ateInPkts  uint64
+}
+
+// measureEgress measures the packets sent by the DUT ports and
+// received by the ATE ports while traffic is running.
+func measureEgress(t *testing.T, dut *ondatra.DUTDevice, ate *ondatra.ATEDevice, names ...string) map[string]*egress {
+	counters := func() map[string]*egress {
+		m := map[string]*egress{}
+		for _, name := range names {
+			m[name] = &egress{
+				dutOutPkts: dut.Telemetry().Interface(dut.Port(t, name).Name()).Counters().OutUnicastPkts().Get(t),
+				ateInPkts:  ate.Telemetry().Interface(ate.Port(t, name).Name()).Counters().InPkts().Get(t),
+			}
+		}
+		return m
+	}
+	time.Sleep(settleTime)
+	before := counters()
+	time.Sleep(measureTime)
+	after := counters()
+	for name, e := range after {
+		e.dutOutPkts -= before[name].dutOutPkts
+		e.ateInPkts -= before[name].ateInPkts
+	}
+	return after
+}
+
+// checkActive checks that the DUT telemetry and the ATE counters agree
+// that traffic egresses via the port wantActive and not via the port
+// wantIdle, i.e. that the NextHopGroup egressing via wantActive is
+// active.
+func checkActive(t *testing.T, dut *ondatra.DUTDevice, ate *ondatra.ATEDevice, wantActive, wantIdle string) {
+	t.Helper()
+	got := measureEgress(t, dut, ate, wantActive, wantIdle)
+	sent := float64(frameRate) * measureTime.Seconds()
+	for name, e := range got {
+		t.Logf("DUT %s sent %d and ATE %s received %d packets", name, e.dutOutPkts, name, e.ateInPkts)
+	}
+	if a := got[wantActive]; float64(a.dutOutPkts) < minRatio*sent || float64(a.ateInPkts) < minRatio*sent {
+		t.Errorf("Traffic via %s got DUT out-unicast-pkts %d and ATE in-pkts %d, want at least %g of %g sent", wantActive, a.dutOutPkts, a.ateInPkts, minRatio, sent)
+	}
+	if i := got[wantIdle]; float64(i.dutOutPkts) > maxStrayPktRatio*sent || float64(i.ateInPkts) > maxStrayPktRatio*sent {
+		t.Errorf("Traffic via %s got DUT out-unicast-pkts %d and ATE in-pkts %d, want at most %g of %g sent", wantIdle, i.dutOutPkts, i.ateInPkts, maxStrayPktRatio, sent)
+	}
+}
+
+// checkAFT checks that the destination network uses the primary
+// NextHopGroup, with the backup NextHopGroup as its backup.
+func checkAFT(t *testing.T, dut *ondatra.DUTDevice) {
+	afts := dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).Afts()
+	ipv4Path := afts.Ipv4Entry(ateDstNetCIDR)
+	if !fptest.Await(t, ipv4Path.Prefix().Watch, time.Minute, ateDstNetCIDR) {
+		t.FailNow()
+	}
+	nhg := afts.NextHopGroup(ipv4Path.NextHopGroup().Get(t)).Get(t)
+	if got, want := nhg.GetProgrammedId(), uint64(primaryNHGIndex); got != want {
+		t.Errorf("next-hop-group/state/programmed-id got %d, want %d", got, want)
+	}
+	if got, want := afts.NextHopGroup(nhg.GetBackupNextHopGroup()).ProgrammedId().Get(t), uint64(backupNHGIndex); got != want {
+		t.Errorf("next-hop-group/state/backup-next-hop-group programmed-id got %d, want %d", got, want)
+	}
+}
+
+// disablePort2 takes port-2 down as selected by --failover_trigger, and
+// returns the time it was requested.
+func disablePort2(t *testing.T, dut *ondatra.DUTDevice, ate *ondatra.ATEDevice) time.Time {
+	switch *trigger {
+	case "admin_down":
+		return link.DisableDUTPort(t, dut, dut.Port(t, "port2"))
+	case "ate_port_down":
+		return link.DisableATEPort(t, ate, dut, "port2").Requested
+	}
+	t.Fatalf("Unsupported --failover_trigger %q, want admin_down or ate_port_down", *trigger)
+	return time.Time{}
+}
+
+// enablePort2 brings port-2 back up as selected by --failover_trigger,
+// and returns the time it was requested.
+func enablePort2(t *testing.T, dut *ondatra.DUTDevice, ate *ondatra.ATEDevice) time.Time {
+	if *trigger == "ate_port_down" {
+		return link.EnableATEPort(t, ate, dut, "port2").Requested
+	}
+	return link.EnableDUTPort(t, dut, dut.Port(t, "port2"))
+}
+
+func TestBackupNHGFailover(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	ate := ondatra.ATE(t, "ate")
+
+	configureDUT(t, dut)
+	top := configureATE(t, ate)
+	top.Push(t).StartProtocols(t)
+	defer top.StopProtocols(t)
+
+	wantInstalled := fluent.InstalledInFIB
+	if *deviations.GRIBIRIBAckOnly {
+		wantInstalled = fluent.InstalledInRIB
+	}
+	c := &gribi.Client{
+		DUT:                  dut,
+		FibACK:               !*deviations.GRIBIRIBAckOnly,
+		Persistence:          true,
+		InitialElectionIDLow: 10,
+	}
+	defer c.Close(t)
+	if err := c.Start(t); err != nil {
+		t.Fatalf("gRIBI connection could not be established: %v", err)
+	}
+	c.BecomeLeader(t)
+	defer c.Flush(t)
+
+	t.Logf("Program %s via NextHopGroup %d to ATE port-2 with backup NextHopGroup %d to ATE port-3.", ateDstNetCIDR, primaryNHGIndex, backupNHGIndex)
+	c.AddNH(t, backupNHIndex, atePort3.IPv4, *deviations.DefaultNetworkInstance, wantInstalled)
+	c.AddNHG(t, backupNHGIndex, map[uint64]uint64{backupNHIndex: 1}, *deviations.DefaultNetworkInstance, wantInstalled)
+	c.AddNH(t, primaryNHIndex, atePort2.IPv4, *deviations.DefaultNetworkInstance, wantInstalled)
+	c.AddNHG(t, primaryNHGIndex, map[uint64]uint64{primaryNHIndex: 1}, *deviations.DefaultNetworkInstance, wantInstalled,
+		&gribi.NHGOptions{BackupNHG: backupNHGIndex})
+	c.AddIPv4(t, ateDstNetCIDR, primaryNHGIndex, *deviations.DefaultNetworkInstance, "", wantInstalled)
+
+	t.Run("AFT", func(t *testing.T) {
+		checkAFT(t, dut)
+	})
+
+	bg := traffic.StartBackground(t, ate, newFlow(ate, top), frameRate)
+
+	t.Run("Primary", func(t *testing.T) {
+		checkActive(t, dut, ate, "port2", "port3")
+	})
+
+	disableTime := disablePort2(t, dut, ate)
+
+	t.Run("Backup", func(t *testing.T) {
+		checkActive(t, dut, ate, "port3", "port2")
+	})
+
+	enableTime := enablePort2(t, dut, ate)
+
+	// The backup NextHopGroup is only used while the primary
+	// NextHopGroup has no usable next hop, so traffic is expected to
+	// revert to the primary once DUT port-2 is up again.
+	t.Run("Revert", func(t *testing.T) {
+		checkActive(t, dut, ate, "port2", "port3")
+	})
+
+	bg.Stop(t)
+
+	t.Run("FailoverLoss", func(t *testing.T) {
+		failover := bg.OutageBetween(disableTime, enableTime)
+		t.Logf("Failover to backup NextHopGroup lost %d packets, i.e. %v interruption", failover.LostPkts, failover.Duration)
+		if failover.Duration > *maxFailoverTime {
+			t.Errorf("Interruption during failover got %v, want at most %v", failover.Duration, *maxFailoverTime)
+		}
+		revert := bg.OutageBetween(enableTime, time.Now())
+		t.Logf("Reverting to primary NextHopGroup lost %d packets, i.e. %v interruption", revert.LostPkts, revert.Duration)
+	})
+}
@@ -0,0 +1,273 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bgp_route_preference_test
+
+import (
+	"flag"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/bgp"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/gribi"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/gribigo/fluent"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+)
+
+var (
+	maxConvergence = flag.Duration("max_convergence", 2*time.Second,
+		"Maximum outage while traffic shifts from the gRIBI path to the BGP path.")
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1,
+// dut:port2 -> ate:port2 and dut:port3 -> ate:port3.
+//
+//   - ate:port1 -> dut:port1 subnet 192.0.2.0/30
+//   - ate:port2 -> dut:port2 subnet 192.0.2.4/30
+//   - ate:port3 -> dut:port3 subnet 192.0.2.8/30
+//
+// The destination network 203.0.113.0/24 is routed to ate:port2 via
+// gRIBI, and advertised by ate:port3 over eBGP.
+const (
+	ipv4PrefixLen = 30
+	ateDstNetCIDR = "203.0.113.0/24"
+	ateDstNetName = "bgpNet"
+	nhIndex       = 1
+	nhgIndex      = 42
+
+	dutAS = 64500
+	ateAS = 64501
+
+	frameRate = 1000 // frames per second
+	// minRatio is the minimum fraction of the transmitted packets that
+	// should be received on the expected port.
+	minRatio = 0.99
+)
+
+var (
+	dutPort1 = attrs.Attributes{
+		Desc:    "dutPort1",
+		IPv4:    "192.0.2.1",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort1 = attrs.Attributes{
+		Name:    "atePort1",
+		IPv4:    "192.0.2.2",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	dutPort2 = attrs.Attributes{
+		Desc:    "dutPort2",
+		IPv4:    "192.0.2.5",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort2 = attrs.Attributes{
+		Name:    "atePort2",
+		IPv4:    "192.0.2.6",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	dutPort3 = attrs.Attributes{
+		Desc:    "dutPort3",
+		IPv4:    "192.0.2.9",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort3 = attrs.Attributes{
+		Name:    "atePort3",
+		IPv4:    "192.0.2.10",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	// atePeer is the eBGP speaker on ate:port3 advertising the
+	// destination network.
+	atePeer = &bgp.ATEPeer{
+		ATE:    &atePort3,
+		DUT:    &dutPort3,
+		AS:     ateAS,
+		Routes: []*bgp.Routes{{Name: ateDstNetName, CIDR: ateDstNetCIDR}},
+	}
+)
+
+// configureDUT configures port1, port2 and port3 and a BGP neighbor to
+// ate:port3 on the DUT.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	d := dut.Config()
+
+	p1 := dut.Port(t, "port1")
+	d.Interface(p1.Name()).Replace(t, dutPort1.NewInterface(p1.Name()))
+
+	p2 := dut.Port(t, "port2")
+	d.Interface(p2.Name()).Replace(t, dutPort2.NewInterface(p2.Name()))
+
+	p3 := dut.Port(t, "port3")
+	d.Interface(p3.Name()).Replace(t, dutPort3.NewInterface(p3.Name()))
+
+	bgp.ConfigureDUT(t, dut, bgp.DUTConfig(dutPort3.IPv4, dutAS, atePeer.Neighbors()...))
+}
+
+// configureATE configures port1, port2 and port3 on the ATE, with
+// port3 advertising the destination network over eBGP.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice) *ondatra.ATETopology {
+	top := ate.Topology().New()
+	atePort1.AddToATE(top, ate.Port(t, "port1"), &dutPort1)
+	atePort2.AddToATE(top, ate.Port(t, "port2"), &dutPort2)
+	i3 := atePort3.AddToATE(top, ate.Port(t, "port3"), &dutPort3)
+	atePeer.AddToATE(t, i3)
+	return top
+}
+
+// newFlow creates a flow from ate:port1 to the destination network.
+func newFlow(ate *ondatra.ATEDevice, top *ondatra.ATETopology) *ondatra.Flow {
+	ipv4Header := ondatra.NewIPv4Header()
+	ipv4Header.DstAddressRange().
+		WithMin("203.0.113.1").
+		WithMax("203.0.113.254").
+		WithCount(254)
+
+	return ate.Traffic().NewFlow("Flow").
+		WithSrcEndpoints(top.Interfaces()[atePort1.Name]).
+		WithDstEndpoints(top.Interfaces()[atePort2.Name], top.Interfaces()[atePort3.Name]).
+		WithHeaders(ondatra.NewEthernetHeader(), ipv4Header).
+		WithFrameRateFPS(frameRate)
+}
+
+// portInPkts returns the number of packets received by the ATE port.
+func portInPkts(t *testing.T, ate *ondatra.ATEDevice, ap *ondatra.Port) uint64 {
+	return ate.Telemetry().Interface(ap.Name()).Counters().InPkts().Get(t)
+}
+
+// awaitPortInPkts waits for the ATE port to have received at least
+// want packets.
+func awaitPortInPkts(t *testing.T, ate *ondatra.ATEDevice, ap *ondatra.Port, want uint64) {
+	inPkts := ate.Telemetry().Interface(ap.Name()).Counters().InPkts()
+	fptest.AwaitFunc[uint64](t, inPkts.Watch, time.Minute, fmt.Sprintf("port %s received %d packets", ap.ID(), want), func(q *telemetry.QualifiedUint64) bool {
+		return q.IsPresent() && q.Val(t) >= want
+	})
+}
+
+// testTraffic sends traffic to the destination network, and checks
+// that wantPort receives it while otherPort does not.
+func testTraffic(t *testing.T, ate *ondatra.ATEDevice, top *ondatra.ATETopology, wantPort, otherPort *ondatra.Port) {
+	flow := newFlow(ate, top)
+	wantBefore, otherBefore := portInPkts(t, ate, wantPort), portInPkts(t, ate, otherPort)
+	outPkts := traffic.RunFlow(t, ate, flow, nil).OutPkts
+	wantAfter, otherAfter := portInPkts(t, ate, wantPort), portInPkts(t, ate, otherPort)
+
+	if outPkts == 0 {
+		t.Fatalf("Flow %s sent no packets", flow.Name())
+	}
+	if got := wantAfter - wantBefore; float64(got) < minRatio*float64(outPkts) {
+		t.Errorf("Port %s received %d packets, want at least %g of %d sent", wantPort.ID(), got, minRatio, outPkts)
+	}
+	if got := otherAfter - otherBefore; float64(got) > (1-minRatio)*float64(outPkts) {
+		t.Errorf("Port %s received %d packets, want at most %g of %d sent", otherPort.ID(), got, 1-minRatio, outPkts)
+	}
+}
+
+// aftNHGProgrammedID returns the gRIBI programmed ID of the
+// next-hop-group used by the destination network in the AFT.
+func aftNHGProgrammedID(t *testing.T, dut *ondatra.DUTDevice) uint64 {
+	afts := dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).Afts()
+	nhg := afts.Ipv4Entry(ateDstNetCIDR).NextHopGroup().Get(t)
+	return afts.NextHopGroup(nhg).ProgrammedId().Get(t)
+}
+
+func TestBGPRoutePreference(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	ate := ondatra.ATE(t, "ate")
+
+	configureDUT(t, dut)
+	defer bgp.DeleteDUT(t, dut)
+	top := configureATE(t, ate)
+	bgp.StartATEPeers(t, dut, top, atePeer)
+	defer top.StopProtocols(t)
+
+	ap2 := ate.Port(t, "port2")
+	ap3 := ate.Port(t, "port3")
+
+	ipv4Path := dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).Afts().Ipv4Entry(ateDstNetCIDR)
+	if !fptest.Await(t, ipv4Path.Prefix().Watch, time.Minute, ateDstNetCIDR) {
+		t.FailNow()
+	}
+	t.Run("BGPOnly", func(t *testing.T) {
+		testTraffic(t, ate, top, ap3, ap2)
+	})
+
+	wantInstalled := fluent.InstalledInFIB
+	if *deviations.GRIBIRIBAckOnly {
+		wantInstalled = fluent.InstalledInRIB
+	}
+	c := &gribi.Client{
+		DUT:                  dut,
+		FibACK:               !*deviations.GRIBIRIBAckOnly,
+		Persistence:          true,
+		InitialElectionIDLow: 10,
+	}
+	defer c.Close(t)
+	if err := c.Start(t); err != nil {
+		t.Fatalf("gRIBI connection could not be established: %v", err)
+	}
+	c.BecomeLeader(t)
+	defer c.Flush(t)
+
+	t.Logf("Program %s to ATE port-2 via gRIBI.", ateDstNetCIDR)
+	c.AddNH(t, nhIndex, atePort2.IPv4, *deviations.DefaultNetworkInstance, wantInstalled)
+	c.AddNHG(t, nhgIndex, map[uint64]uint64{nhIndex: 1}, *deviations.DefaultNetworkInstance, wantInstalled)
+	c.AddIPv4(t, ateDstNetCIDR, nhgIndex, *deviations.DefaultNetworkInstance, "", wantInstalled)
+
+	t.Run("GRIBIPreferred", func(t *testing.T) {
+		if got := aftNHGProgrammedID(t, dut); got != nhgIndex {
+			t.Errorf("next-hop-group/state/programmed-id got %d, want %d", got, nhgIndex)
+		}
+		testTraffic(t, ate, top, ap2, ap3)
+	})
+
+	t.Run("BGPFallback", func(t *testing.T) {
+		// The background flow is forwarded to port2 by the gRIBI route
+		// before it is deleted, and to port3 by the BGP route after, so
+		// its outage is the convergence to the BGP path.
+		bg := traffic.StartBackground(t, ate, newFlow(ate, top), frameRate)
+		awaitPortInPkts(t, ate, ap2, portInPkts(t, ate, ap2)+frameRate)
+
+		t.Logf("Delete %s from gRIBI.", ateDstNetCIDR)
+		deleted := time.Now()
+		c.DeleteIPv4(t, ateDstNetCIDR, *deviations.DefaultNetworkInstance, wantInstalled)
+		awaitPortInPkts(t, ate, ap3, portInPkts(t, ate, ap3)+frameRate)
+		bg.Stop(t)
+
+		outage, _ := bg.OutageSince(deleted)
+		t.Logf("Traffic shifted to the BGP path with %d packets lost, i.e. %v outage", outage.LostPkts, outage.Duration)
+		if outage.Duration > *maxConvergence {
+			t.Errorf("Convergence to the BGP path got %v, want at most %v", outage.Duration, *maxConvergence)
+		}
+
+		testTraffic(t, ate, top, ap3, ap2)
+	})
+}
@@ -0,0 +1,318 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package failure_recovery_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/gribi"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	spb "github.com/openconfig/gribi/v1/proto/service"
+	"github.com/openconfig/gribigo/client"
+	"github.com/openconfig/gribigo/fluent"
+	"github.com/openconfig/ondatra"
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1 and
+// dut:port2 -> ate:port2.
+//
+//   - Source: ate:port1 -> dut:port1 subnet 192.0.2.0/30
+//   - Destination: dut:port2 -> ate:port2 subnet 192.0.2.4/30
+//
+// Two destination networks are configured behind ate:port2.  The
+// failing operations reference a NextHopGroup that is never
+// installed, for prefixes in 198.18.0.0/15 that are not configured on
+// the ATE.
+const (
+	plen4 = 30
+
+	ateDstNetName  = "dstnet"
+	ateDstNetCIDR  = "203.0.113.0/24"
+	ateDstNet2Name = "dstnet2"
+	ateDstNet2CIDR = "198.51.100.0/24"
+
+	failedCIDR1 = "198.18.0.0/24"
+	failedCIDR2 = "198.18.1.0/24"
+
+	nhIndex         = 42
+	nhWeight        = 1
+	nhgIndex        = 10
+	missingNHGIndex = 999
+)
+
+var (
+	ateSrc = attrs.Attributes{
+		Name:    "ateSrc",
+		IPv4:    "192.0.2.1",
+		IPv4Len: plen4,
+	}
+
+	dutSrc = attrs.Attributes{
+		Desc:    "DUT to ATE source",
+		IPv4:    "192.0.2.2",
+		IPv4Len: plen4,
+	}
+
+	dutDst = attrs.Attributes{
+		Desc:    "DUT to ATE destination",
+		IPv4:    "192.0.2.5",
+		IPv4Len: plen4,
+	}
+
+	ateDst = attrs.Attributes{
+		Name:    "ateDst",
+		IPv4:    "192.0.2.6",
+		IPv4Len: plen4,
+	}
+)
+
+// configureDUT configures port1 and port2 on the DUT.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	d := dut.Config()
+
+	p1 := dut.Port(t, "port1")
+	d.Interface(p1.Name()).Replace(t, dutSrc.NewInterface(p1.Name()))
+
+	p2 := dut.Port(t, "port2")
+	d.Interface(p2.Name()).Replace(t, dutDst.NewInterface(p2.Name()))
+}
+
+// configureATE configures port1 and port2 on the ATE, with both
+// destination networks behind port2.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice) *ondatra.ATETopology {
+	top := ate.Topology().New()
+	ateSrc.AddToATE(top, ate.Port(t, "port1"), &dutSrc)
+	i2 := ateDst.AddToATE(top, ate.Port(t, "port2"), &dutDst)
+	i2.AddNetwork(ateDstNetName).IPv4().WithAddress(ateDstNetCIDR)
+	i2.AddNetwork(ateDstNet2Name).IPv4().WithAddress(ateDstNet2CIDR)
+	return top
+}
+
+// testTraffic generates traffic flow from ate:port1 to the named
+// destination network and checks for packet loss.
+func testTraffic(t *testing.T, ate *ondatra.ATEDevice, top *ondatra.ATETopology, netName string) {
+	flow := ate.Traffic().NewFlow(netName).
+		WithSrcEndpoints(top.Interfaces()[ateSrc.Name]).
+		WithDstEndpoints(top.Interfaces()[ateDst.Name].Networks()[netName]).
+		WithHeaders(ondatra.NewEthernetHeader(), ondatra.NewIPv4Header())
+
+	traffic.ValidateFlow(t, ate, flow, nil)
+}
+
+// opResult is the expected result of an operation.  A nil status only
+// requires that some result is received for the operation.
+type opResult struct {
+	id     uint64
+	status *spb.AFTResult_Status
+}
+
+// statusPtr returns a pointer to the status.
+func statusPtr(s spb.AFTResult_Status) *spb.AFTResult_Status {
+	return &s
+}
+
+// checkResults checks the programming result of each operation by its
+// operation ID, reporting the IDs for which no result was received.
+func checkResults(t *testing.T, res []*client.OpResult, want []*opResult) {
+	t.Helper()
+	got := map[uint64]spb.AFTResult_Status{}
+	for _, r := range res {
+		if r.ProgrammingResult != spb.AFTResult_UNSET {
+			got[r.OperationID] = r.ProgrammingResult
+		}
+	}
+	for _, w := range want {
+		s, ok := got[w.id]
+		switch {
+		case !ok:
+			t.Errorf("Operation %d: no result received", w.id)
+		case w.status != nil && s != *w.status:
+			t.Errorf("Operation %d: got result %v, want %v", w.id, s, *w.status)
+		}
+	}
+}
+
+// testForwardReference sends a ModifyRequest with an IPv4Entry before
+// the NextHopGroup it references, and then immediately the same
+// entries in the correct order on the same session.
+func testForwardReference(t *testing.T, args *testArgs) {
+	ops := args.ops.Next(3)
+	args.c.Modify().AddEntry(t,
+		fluent.NextHopEntry().
+			WithNetworkInstance(*deviations.DefaultNetworkInstance).
+			WithIndex(nhIndex).
+			WithIPAddress(ateDst.IPv4),
+		fluent.IPv4Entry().
+			WithNetworkInstance(*deviations.DefaultNetworkInstance).
+			WithPrefix(ateDstNetCIDR).
+			WithNextHopGroup(nhgIndex),
+		fluent.NextHopGroupEntry().
+			WithNetworkInstance(*deviations.DefaultNetworkInstance).
+			WithID(nhgIndex).
+			AddNextHop(nhIndex, nhWeight),
+	)
+	if err := gribi.Await(args.ctx, t, args.c, nil, ops...); err != nil {
+		t.Errorf("Await got error for ModifyRequest: %v", err)
+	}
+	checkResults(t, args.c.Results(t), []*opResult{
+		{id: ops[0]},
+		{id: ops[1], status: statusPtr(spb.AFTResult_FAILED)},
+		{id: ops[2]},
+	})
+
+	t.Log("Send the same entries in the correct order on the same session.")
+	ops = args.ops.Next(3)
+	args.c.Modify().AddEntry(t,
+		fluent.NextHopEntry().
+			WithNetworkInstance(*deviations.DefaultNetworkInstance).
+			WithIndex(nhIndex).
+			WithIPAddress(ateDst.IPv4),
+		fluent.NextHopGroupEntry().
+			WithNetworkInstance(*deviations.DefaultNetworkInstance).
+			WithID(nhgIndex).
+			AddNextHop(nhIndex, nhWeight),
+		fluent.IPv4Entry().
+			WithNetworkInstance(*deviations.DefaultNetworkInstance).
+			WithPrefix(ateDstNetCIDR).
+			WithNextHopGroup(nhgIndex),
+	)
+	if err := gribi.Await(args.ctx, t, args.c, nil, ops...); err != nil {
+		t.Errorf("Await got error for ModifyRequest: %v", err)
+	}
+	checkResults(t, args.c.Results(t), []*opResult{
+		{id: ops[0], status: statusPtr(args.wantInstalled)},
+		{id: ops[1], status: statusPtr(args.wantInstalled)},
+		{id: ops[2], status: statusPtr(args.wantInstalled)},
+	})
+
+	testTraffic(t, args.ate, args.top, ateDstNetName)
+}
+
+// testInterleaved sends a ModifyRequest with a succeeding IPv4Entry
+// between two IPv4Entries referencing a NextHopGroup that does not
+// exist.  It relies on NextHopGroup 10 installed by
+// testForwardReference.
+func testInterleaved(t *testing.T, args *testArgs) {
+	ops := args.ops.Next(3)
+	args.c.Modify().AddEntry(t,
+		fluent.IPv4Entry().
+			WithNetworkInstance(*deviations.DefaultNetworkInstance).
+			WithPrefix(failedCIDR1).
+			WithNextHopGroup(missingNHGIndex),
+		fluent.IPv4Entry().
+			WithNetworkInstance(*deviations.DefaultNetworkInstance).
+			WithPrefix(ateDstNet2CIDR).
+			WithNextHopGroup(nhgIndex),
+		fluent.IPv4Entry().
+			WithNetworkInstance(*deviations.DefaultNetworkInstance).
+			WithPrefix(failedCIDR2).
+			WithNextHopGroup(missingNHGIndex),
+	)
+	if err := gribi.Await(args.ctx, t, args.c, nil, ops...); err != nil {
+		t.Errorf("Await got error for ModifyRequest: %v", err)
+	}
+	checkResults(t, args.c.Results(t), []*opResult{
+		{id: ops[0], status: statusPtr(spb.AFTResult_FAILED)},
+		{id: ops[1], status: statusPtr(args.wantInstalled)},
+		{id: ops[2], status: statusPtr(spb.AFTResult_FAILED)},
+	})
+
+	testTraffic(t, args.ate, args.top, ateDstNet2Name)
+}
+
+// testArgs holds the objects needed by a test case.
+type testArgs struct {
+	ctx           context.Context
+	c             *fluent.GRIBIClient
+	ops           *gribi.OpIDs
+	ate           *ondatra.ATEDevice
+	top           *ondatra.ATETopology
+	wantInstalled spb.AFTResult_Status
+}
+
+func TestFailureRecovery(t *testing.T) {
+	ctx := context.Background()
+	dut := ondatra.DUT(t, "dut")
+	configureDUT(t, dut)
+
+	ate := ondatra.ATE(t, "ate")
+	top := configureATE(t, ate)
+	top.Push(t).StartProtocols(t)
+
+	c := fluent.NewClient()
+	conn := c.Connection().
+		WithStub(dut.RawAPIs().GRIBI().Default(t)).
+		WithRedundancyMode(fluent.ElectedPrimaryClient).
+		WithPersistence().
+		WithInitialElectionID(1 /* low */, 0 /* hi */) // ID must be > 0.
+	if !*deviations.GRIBIRIBAckOnly {
+		conn.WithFIBACK()
+	}
+	c.Start(ctx, t)
+	defer c.Stop(t)
+	c.StartSending(ctx, t)
+	if err := gribi.Await(ctx, t, c, nil); err != nil {
+		t.Fatalf("Await got error during session negotiation: %v", err)
+	}
+	defer func() {
+		_, err := c.Flush().
+			WithElectionOverride().
+			WithAllNetworkInstances().
+			Send()
+		if err != nil {
+			t.Errorf("Cannot flush: %v", err)
+		}
+	}()
+
+	args := &testArgs{ctx: ctx, c: c, ops: &gribi.OpIDs{}, ate: ate, top: top}
+	args.wantInstalled = spb.AFTResult_FIB_PROGRAMMED
+	if *deviations.GRIBIRIBAckOnly {
+		args.wantInstalled = spb.AFTResult_RIB_PROGRAMMED
+	}
+
+	// The cases share the session, and hence the operation IDs, so they
+	// must run in order.
+	cases := []struct {
+		name string
+		desc string
+		fn   func(t *testing.T, args *testArgs)
+	}{{
+		name: "ForwardReference",
+		desc: "A correct ModifyRequest after a failed forward reference is installed and forwards.",
+		fn:   testForwardReference,
+	}, {
+		name: "Interleaved",
+		desc: "Each operation of a ModifyRequest with interleaved failures gets its own result.",
+		fn:   testInterleaved,
+	}}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Logf("Name: %s", tc.name)
+			t.Logf("Description: %s", tc.desc)
+			tc.fn(t, args)
+		})
+	}
+}
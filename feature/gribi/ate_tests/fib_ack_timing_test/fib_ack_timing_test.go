@@ -0,0 +1,275 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fib_ack_timing_test
+
+import (
+	"context"
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/gribi"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	spb "github.com/openconfig/gribi/v1/proto/service"
+	"github.com/openconfig/gribigo/client"
+	"github.com/openconfig/gribigo/fluent"
+	"github.com/openconfig/ondatra"
+)
+
+var (
+	maxFIBACKLatency = flag.Duration("max_fib_ack_latency", 0,
+		"Upper bound on the latency of a FIB ACK for a single operation; 0 derives it from the RIB ACK latency of the operation in the same run.")
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1 and
+// dut:port2 -> ate:port2.
+//
+//   - Source: ate:port1 -> dut:port1 subnet 192.0.2.0/30
+//   - Destination: dut:port2 -> ate:port2 subnet 192.0.2.4/30
+//
+// A traffic flow is sent from ate:port1 to the destination network
+// 203.0.113.0/24, which is routed via gRIBI to ate:port2.
+const (
+	plen4 = 30
+
+	ateDstNetName = "dstnet"
+	ateDstNetCIDR = "203.0.113.0/24"
+
+	nhIndex  = 1
+	nhgIndex = 1
+
+	// Unless -max_fib_ack_latency is set, the FIB ACK latency of an
+	// entry may be up to fibACKLatencyFactor times its RIB ACK latency
+	// in the RIB ACK session, plus fibACKLatencySlack for programming
+	// the forwarding plane, which takes about as long whatever the RIB
+	// ACK latency of the device.
+	fibACKLatencyFactor = 5
+	fibACKLatencySlack  = time.Second
+)
+
+var (
+	ateSrc = attrs.Attributes{
+		Name:    "ateSrc",
+		IPv4:    "192.0.2.1",
+		IPv4Len: plen4,
+	}
+
+	dutSrc = attrs.Attributes{
+		Desc:    "DUT to ATE source",
+		IPv4:    "192.0.2.2",
+		IPv4Len: plen4,
+	}
+
+	dutDst = attrs.Attributes{
+		Desc:    "DUT to ATE destination",
+		IPv4:    "192.0.2.5",
+		IPv4Len: plen4,
+	}
+
+	ateDst = attrs.Attributes{
+		Name:    "ateDst",
+		IPv4:    "192.0.2.6",
+		IPv4Len: plen4,
+	}
+)
+
+// configureDUT configures port1 and port2 on the DUT.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	d := dut.Config()
+
+	p1 := dut.Port(t, "port1")
+	d.Interface(p1.Name()).Replace(t, dutSrc.NewInterface(p1.Name()))
+
+	p2 := dut.Port(t, "port2")
+	d.Interface(p2.Name()).Replace(t, dutDst.NewInterface(p2.Name()))
+}
+
+// configureATE configures port1 and port2 on the ATE.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice) *ondatra.ATETopology {
+	top := ate.Topology().New()
+
+	p1 := ate.Port(t, "port1")
+	ateSrc.AddToATE(top, p1, &dutSrc)
+
+	p2 := ate.Port(t, "port2")
+	i2 := ateDst.AddToATE(top, p2, &dutDst)
+	i2.AddNetwork(ateDstNetName).IPv4().WithAddress(ateDstNetCIDR)
+
+	return top
+}
+
+// testTraffic starts traffic immediately and checks for packet loss.
+func testTraffic(t *testing.T, ate *ondatra.ATEDevice, top *ondatra.ATETopology) {
+	i1 := top.Interfaces()[ateSrc.Name]
+	n2 := top.Interfaces()[ateDst.Name].Networks()[ateDstNetName]
+
+	flow := ate.Traffic().NewFlow("Flow").
+		WithSrcEndpoints(i1).
+		WithDstEndpoints(n2).
+		WithHeaders(ondatra.NewEthernetHeader(), ondatra.NewIPv4Header())
+
+	traffic.ValidateFlow(t, ate, flow, nil)
+}
+
+// opLatencies maps an operation ID to the latency of its result.
+type opLatencies map[uint64]time.Duration
+
+// resultLatencies returns the latencies of the operation results with
+// the given programming status.
+func resultLatencies(res []*client.OpResult, status spb.AFTResult_Status) opLatencies {
+	lats := make(opLatencies)
+	for _, r := range res {
+		if r.Details == nil || r.ProgrammingResult != status {
+			continue
+		}
+		lats[r.OperationID] = time.Duration(r.Latency)
+	}
+	return lats
+}
+
+// programEntries programs the next hop, next-hop-group, and IPv4 entry
+// in a new gRIBI session, negotiating FIB ACK if fibACK, and returns
+// the IDs of their operations, in that order, and the operation
+// results.  If afterInstall is not nil, it is called right after the
+// results have been received and before the entries are flushed.
+func programEntries(t *testing.T, dut *ondatra.DUTDevice, fibACK bool, afterInstall func(t *testing.T)) ([]uint64, []*client.OpResult) {
+	ctx := context.Background()
+	gribic := dut.RawAPIs().GRIBI().Default(t)
+
+	c := fluent.NewClient()
+	conn := c.Connection().
+		WithStub(gribic).
+		WithRedundancyMode(fluent.ElectedPrimaryClient).
+		WithPersistence().
+		WithInitialElectionID(1 /* low */, 0 /* hi */) // ID must be > 0.
+	if fibACK {
+		conn.WithFIBACK()
+	}
+
+	c.Start(ctx, t)
+	defer c.Stop(t)
+	c.StartSending(ctx, t)
+	if err := gribi.Await(ctx, t, c, nil); err != nil {
+		t.Fatalf("Await got error during session negotiation: %v", err)
+	}
+	defer func() {
+		_, err := c.Flush().
+			WithElectionOverride().
+			WithAllNetworkInstances().
+			Send()
+		if err != nil {
+			t.Errorf("Cannot flush: %v", err)
+		}
+	}()
+
+	ops := new(gribi.OpIDs).Next(3)
+	c.Modify().AddEntry(t,
+		fluent.NextHopEntry().
+			WithNetworkInstance(*deviations.DefaultNetworkInstance).
+			WithIndex(nhIndex).
+			WithIPAddress(ateDst.IPv4),
+		fluent.NextHopGroupEntry().
+			WithNetworkInstance(*deviations.DefaultNetworkInstance).
+			WithID(nhgIndex).
+			AddNextHop(nhIndex, 1),
+		fluent.IPv4Entry().
+			WithNetworkInstance(*deviations.DefaultNetworkInstance).
+			WithPrefix(ateDstNetCIDR).
+			WithNextHopGroup(nhgIndex),
+	)
+	if err := gribi.Await(ctx, t, c, nil, ops...); err != nil {
+		t.Fatalf("Await got error for ModifyRequest: %v", err)
+	}
+
+	if afterInstall != nil {
+		afterInstall(t)
+	}
+	return ops, c.Results(t)
+}
+
+// maxFIBLatency returns the upper bound on the FIB ACK latency of an
+// entry with the RIB ACK latency.
+func maxFIBLatency(ribLat time.Duration) time.Duration {
+	if *maxFIBACKLatency > 0 {
+		return *maxFIBACKLatency
+	}
+	return fibACKLatencyFactor*ribLat + fibACKLatencySlack
+}
+
+// entryNames names the entries that programEntries programs, in order.
+var entryNames = []string{"NH", "NHG", "IPv4 entry"}
+
+func TestFIBACKTiming(t *testing.T) {
+	if *deviations.GRIBIRIBAckOnly {
+		t.Skip("Skipping due to --deviation_gribi_riback_only")
+	}
+
+	dut := ondatra.DUT(t, "dut")
+	configureDUT(t, dut)
+
+	ate := ondatra.ATE(t, "ate")
+	top := configureATE(t, ate)
+	top.Push(t).StartProtocols(t)
+
+	t.Log("Program the entries with RIB ACK.")
+	ribOps, ribRes := programEntries(t, dut, false, nil)
+	ribLats := resultLatencies(ribRes, spb.AFTResult_RIB_PROGRAMMED)
+
+	t.Log("Program the same entries with FIB ACK, and send traffic as soon as the ACK arrives.")
+	fibOps, fibRes := programEntries(t, dut, true, func(t *testing.T) {
+		t.Run("Traffic", func(t *testing.T) {
+			testTraffic(t, ate, top)
+		})
+	})
+	fibLats := resultLatencies(fibRes, spb.AFTResult_FIB_PROGRAMMED)
+	// A device may also send RIB_PROGRAMMED ahead of FIB_PROGRAMMED in
+	// the FIB ACK session, in which case the FIB ACK must not precede it.
+	fibRIBLats := resultLatencies(fibRes, spb.AFTResult_RIB_PROGRAMMED)
+
+	// Each session programs the same entries in the same order, so the
+	// operations of an entry are compared by their position.
+	for i, name := range entryNames {
+		ribLat, ok := ribLats[ribOps[i]]
+		if !ok {
+			t.Errorf("%s: got no RIB ACK in the RIB ACK session: %v", name, ribRes)
+			continue
+		}
+		fibLat, ok := fibLats[fibOps[i]]
+		if !ok {
+			t.Errorf("%s: got no FIB ACK in the FIB ACK session: %v", name, fibRes)
+			continue
+		}
+		t.Logf("%s: RIB ACK latency %v, FIB ACK latency %v", name, ribLat, fibLat)
+		if fibLat < ribLat {
+			t.Errorf("%s: FIB ACK latency %v is earlier than RIB ACK latency %v", name, fibLat, ribLat)
+		}
+		if lat, ok := fibRIBLats[fibOps[i]]; ok && fibLat < lat {
+			t.Errorf("%s: FIB ACK latency %v is earlier than RIB ACK latency %v in the same session", name, fibLat, lat)
+		}
+		if max := maxFIBLatency(ribLat); fibLat > max {
+			t.Errorf("%s: FIB ACK latency %v exceeds %v", name, fibLat, max)
+		}
+	}
+}
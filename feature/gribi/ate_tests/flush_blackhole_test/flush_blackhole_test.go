@@ -0,0 +1,275 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flush_blackhole_test
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/gribi"
+	"github.com/openconfig/featureprofiles/internal/static"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/gribigo/fluent"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+)
+
+var (
+	maxBlackholeTime = flag.Duration("max_blackhole_time", 10*time.Second,
+		"Maximum time after a gRIBI Flush until traffic to the flushed prefix is dropped.")
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1,
+// dut:port2 -> ate:port2 and dut:port3 -> ate:port3.
+//
+//   - ate:port1 -> dut:port1 subnet 192.0.2.0/30
+//   - ate:port2 -> dut:port2 subnet 192.0.2.4/30
+//   - ate:port3 -> dut:port3 subnet 192.0.2.8/30
+//
+// The destination network 203.0.113.0/24 is routed to ate:port2 via
+// gRIBI, and the control network 198.51.100.0/24 is routed to ate:port3
+// via a static route.
+const (
+	ipv4PrefixLen  = 30
+	ateDstNetCIDR  = "203.0.113.0/24"
+	controlNetCIDR = "198.51.100.0/24"
+	nhIndex        = 1
+	nhgIndex       = 42
+
+	frameRate      = 1000 // frames per second
+	sampleInterval = 500 * time.Millisecond
+	// settleTime is how long traffic runs before the Flush.
+	settleTime = 10 * time.Second
+)
+
+var (
+	dutPort1 = attrs.Attributes{
+		Desc:    "dutPort1",
+		IPv4:    "192.0.2.1",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort1 = attrs.Attributes{
+		Name:    "atePort1",
+		IPv4:    "192.0.2.2",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	dutPort2 = attrs.Attributes{
+		Desc:    "dutPort2",
+		IPv4:    "192.0.2.5",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort2 = attrs.Attributes{
+		Name:    "atePort2",
+		IPv4:    "192.0.2.6",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	dutPort3 = attrs.Attributes{
+		Desc:    "dutPort3",
+		IPv4:    "192.0.2.9",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort3 = attrs.Attributes{
+		Name:    "atePort3",
+		IPv4:    "192.0.2.10",
+		IPv4Len: ipv4PrefixLen,
+	}
+)
+
+// configureDUT configures port1, port2 and port3 on the DUT.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	d := dut.Config()
+
+	p1 := dut.Port(t, "port1")
+	d.Interface(p1.Name()).Replace(t, dutPort1.NewInterface(p1.Name()))
+
+	p2 := dut.Port(t, "port2")
+	d.Interface(p2.Name()).Replace(t, dutPort2.NewInterface(p2.Name()))
+
+	p3 := dut.Port(t, "port3")
+	d.Interface(p3.Name()).Replace(t, dutPort3.NewInterface(p3.Name()))
+}
+
+// configureATE configures port1, port2 and port3 on the ATE.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice) *ondatra.ATETopology {
+	top := ate.Topology().New()
+	atePort1.AddToATE(top, ate.Port(t, "port1"), &dutPort1)
+	atePort2.AddToATE(top, ate.Port(t, "port2"), &dutPort2)
+	atePort3.AddToATE(top, ate.Port(t, "port3"), &dutPort3)
+	return top
+}
+
+// newFlow creates a flow from ate:port1 to the destination range on
+// the ATE port dst.
+func newFlow(ate *ondatra.ATEDevice, top *ondatra.ATETopology, name, minAddr, maxAddr string, dst *attrs.Attributes) *ondatra.Flow {
+	ipv4Header := ondatra.NewIPv4Header()
+	ipv4Header.DstAddressRange().
+		WithMin(minAddr).
+		WithMax(maxAddr).
+		WithCount(254)
+
+	return ate.Traffic().NewFlow(name).
+		WithSrcEndpoints(top.Interfaces()[atePort1.Name]).
+		WithDstEndpoints(top.Interfaces()[dst.Name]).
+		WithHeaders(ondatra.NewEthernetHeader(), ipv4Header).
+		WithFrameRateFPS(frameRate)
+}
+
+// blackholeTime returns how long after the given time the flow stopped
+// receiving packets for good, i.e. the start of the first interval after
+// which every interval has packets sent and none received.  It returns
+// false if the flow was still receiving packets in the last interval.
+func blackholeTime(intervals []*traffic.Interval, after time.Time) (time.Duration, bool) {
+	var start time.Time
+	found := false
+	for _, iv := range intervals {
+		if iv.End.Before(after) {
+			continue
+		}
+		switch {
+		case iv.OutPkts == 0:
+			// No packets sent, so the interval says nothing about loss.
+		case iv.InPkts == 0:
+			if !found {
+				start, found = iv.Start, true
+			}
+		default:
+			found = false
+		}
+	}
+	if !found {
+		return 0, false
+	}
+	if start.Before(after) {
+		return 0, true
+	}
+	return start.Sub(after), true
+}
+
+// checkAFT waits for the prefix to be present or absent in the AFT.
+func checkAFT(t *testing.T, dut *ondatra.DUTDevice, prefix string, present bool) {
+	ipv4Path := dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).Afts().Ipv4Entry(prefix)
+	if present {
+		fptest.Await(t, ipv4Path.Prefix().Watch, time.Minute, prefix)
+		return
+	}
+	fptest.AwaitFunc[string](t, ipv4Path.Prefix().Watch, time.Minute, "no prefix", func(val *telemetry.QualifiedString) bool {
+		return !val.IsPresent()
+	})
+}
+
+func TestFlushBlackhole(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	ate := ondatra.ATE(t, "ate")
+
+	configureDUT(t, dut)
+	top := configureATE(t, ate)
+	top.Push(t).StartProtocols(t)
+	defer top.StopProtocols(t)
+
+	t.Logf("Configure a static route for %s to ATE port-3.", controlNetCIDR)
+	static.Configure(t, dut, *deviations.DefaultNetworkInstance, controlNetCIDR, &static.NextHop{
+		Address:   atePort3.IPv4,
+		Interface: dut.Port(t, "port3").Name(),
+	})
+	defer static.Delete(t, dut, *deviations.DefaultNetworkInstance, controlNetCIDR)
+	checkAFT(t, dut, controlNetCIDR, true)
+
+	wantInstalled := fluent.InstalledInFIB
+	if *deviations.GRIBIRIBAckOnly {
+		wantInstalled = fluent.InstalledInRIB
+	}
+	c := &gribi.Client{
+		DUT:                  dut,
+		FibACK:               !*deviations.GRIBIRIBAckOnly,
+		Persistence:          true,
+		InitialElectionIDLow: 10,
+	}
+	defer c.Close(t)
+	if err := c.Start(t); err != nil {
+		t.Fatalf("gRIBI connection could not be established: %v", err)
+	}
+	c.BecomeLeader(t)
+	defer c.Flush(t)
+
+	t.Logf("Program %s to ATE port-2 via gRIBI.", ateDstNetCIDR)
+	c.AddNH(t, nhIndex, atePort2.IPv4, *deviations.DefaultNetworkInstance, wantInstalled)
+	c.AddNHG(t, nhgIndex, map[uint64]uint64{nhIndex: 1}, *deviations.DefaultNetworkInstance, wantInstalled)
+	c.AddIPv4(t, ateDstNetCIDR, nhgIndex, *deviations.DefaultNetworkInstance, "", wantInstalled)
+	checkAFT(t, dut, ateDstNetCIDR, true)
+
+	gribiFlow := newFlow(ate, top, "GRIBIFlow", "203.0.113.1", "203.0.113.254", &atePort2)
+	controlFlow := newFlow(ate, top, "ControlFlow", "198.51.100.1", "198.51.100.254", &atePort3)
+	ate.Traffic().Start(t, gribiFlow, controlFlow)
+	gribiSampler := traffic.StartSampler(t, ate, gribiFlow.Name(), sampleInterval)
+	controlSampler := traffic.StartSampler(t, ate, controlFlow.Name(), sampleInterval)
+	time.Sleep(settleTime)
+
+	flushTime := time.Now()
+	c.Flush(t)
+	time.Sleep(*maxBlackholeTime)
+	t.Logf("Validate that ATE port-2 receives no traffic once %s is black-holed.", ateDstNetCIDR)
+	traffic.CheckNoTraffic(t, ate, []string{"port2"}, 0, func() {
+		time.Sleep(settleTime)
+	})
+
+	gribiSamples := gribiSampler.Stop(t)
+	controlSamples := controlSampler.Stop(t)
+	ate.Traffic().Stop(t)
+
+	t.Run("Blackhole", func(t *testing.T) {
+		d, ok := blackholeTime(traffic.Intervals(gribiSamples), flushTime)
+		if !ok {
+			t.Fatalf("Flow %s was still receiving packets %v after Flush", gribiFlow.Name(), time.Since(flushTime))
+		}
+		t.Logf("Flow %s black-holed %v after Flush", gribiFlow.Name(), d)
+		if d > *maxBlackholeTime {
+			t.Errorf("Time until flow %s black-holed after Flush got %v, want at most %v", gribiFlow.Name(), d, *maxBlackholeTime)
+		}
+	})
+
+	t.Run("AFT", func(t *testing.T) {
+		checkAFT(t, dut, ateDstNetCIDR, false)
+		checkAFT(t, dut, controlNetCIDR, true)
+	})
+
+	t.Run("Control", func(t *testing.T) {
+		worst := traffic.MaxLoss(traffic.Intervals(controlSamples))
+		if worst == nil {
+			t.Fatalf("Flow %s has no counter samples", controlFlow.Name())
+		}
+		t.Logf("Highest loss %.3f%% (%d of %d packets received) between %v and %v",
+			worst.LossPct(), worst.InPkts, worst.OutPkts,
+			worst.Start.Format(time.RFC3339), worst.End.Format(time.RFC3339))
+		if got := worst.LossPct(); got > *deviations.TrafficLossTolerance {
+			t.Errorf("Loss of flow %s during Flush got %.3f%%, want at most %g%%", controlFlow.Name(), got, *deviations.TrafficLossTolerance)
+		}
+	})
+}
@@ -24,6 +24,7 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/openconfig/featureprofiles/internal/aftcheck"
 	"github.com/openconfig/featureprofiles/internal/attrs"
 	"github.com/openconfig/featureprofiles/internal/deviations"
 	"github.com/openconfig/featureprofiles/internal/fptest"
@@ -401,29 +402,6 @@ func flushGRIBI(t *testing.T, gRIBI *fluent.GRIBIClient) {
 	}
 }
 
-// aftNextHopWeights queries AFT telemetry using Get() and returns
-// the weights. If not-found, an empty list is returned.
-func aftNextHopWeights(t *testing.T, dut *ondatra.DUTDevice, nhg uint64, networkInstance string) []uint64 {
-	aft := dut.Telemetry().NetworkInstance(networkInstance).Afts().Get(t)
-	var nhgD *telemetry.NetworkInstance_Afts_NextHopGroup
-	for _, nhgData := range aft.NextHopGroup {
-		if nhgData.GetProgrammedId() == nhg {
-			nhgD = nhgData
-			break
-		}
-	}
-	if nhgD == nil {
-		return []uint64{}
-	}
-
-	got := []uint64{}
-	for _, nhD := range nhgD.NextHop {
-		got = append(got, nhD.GetWeight())
-	}
-
-	return got
-}
-
 // testBasicHierarchicalWeight tests and validates traffic through 4 Vlans.
 func testBasicHierarchicalWeight(ctx context.Context, t *testing.T, dut *ondatra.DUTDevice,
 	ate *ondatra.ATEDevice, top *ondatra.ATETopology, gRIBI *fluent.GRIBIClient) {
@@ -488,7 +466,7 @@ func testBasicHierarchicalWeight(ctx context.Context, t *testing.T, dut *ondatra
 			2: {1, 3},
 			3: {2, 3},
 		} {
-			got := aftNextHopWeights(t, dut, nhg, defaultVRF)
+			got := aftcheck.Weights(aftcheck.NextHops(t, dut, defaultVRF, nhg))
 			ok := cmp.Equal(weights, got, cmpopts.SortSlices(func(a, b uint64) bool { return a < b }))
 			if !ok {
 				t.Errorf("Valid weights not present for NI: %s, NHG: %d, got: %v, want: %v", defaultVRF, nhg, got, weights)
@@ -573,7 +551,7 @@ func testHierarchicalWeightBoundaryScenario(ctx context.Context, t *testing.T, d
 			2: {2, 3},
 			3: {1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1},
 		} {
-			got := aftNextHopWeights(t, dut, nhg, defaultVRF)
+			got := aftcheck.Weights(aftcheck.NextHops(t, dut, defaultVRF, nhg))
 			ok := cmp.Equal(weights, got, cmpopts.SortSlices(func(a, b uint64) bool { return a < b }))
 			if !ok {
 				t.Errorf("Valid weights not present for NI: %s, NHG: %d, got: %v, want: %v", defaultVRF, nhg, got, weights)
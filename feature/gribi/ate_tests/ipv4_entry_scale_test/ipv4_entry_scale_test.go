@@ -0,0 +1,483 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipv4_entry_scale_test
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/aftcheck"
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/gribi"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/featureprofiles/internal/utilization"
+	spb "github.com/openconfig/gribi/v1/proto/service"
+	"github.com/openconfig/gribigo/fluent"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+	"github.com/openconfig/ygot/ygot"
+)
+
+var (
+	scaleCount = flag.Int("gribi_scale_count", 10000,
+		"Number of IPv4 /32 entries to program; reduce for smaller testbeds.")
+	cpuThreshold = flag.Float64("gribi_scale_cpu_threshold", 90,
+		"CPU utilization in percent the DUT may only exceed for -gribi_scale_utilization_grace while programming; 0 only records it.")
+	memoryThreshold = flag.Float64("gribi_scale_memory_threshold", 90,
+		"Memory utilization in percent the DUT may only exceed for -gribi_scale_utilization_grace while programming; 0 only records it.")
+	utilizationGrace = flag.Duration("gribi_scale_utilization_grace", 2*time.Minute,
+		"How long the DUT utilization may stay above a threshold while programming.")
+	workers = flag.Int("gribi_scale_workers", 0,
+		"Number of workers sending the batches without awaiting each one; 0 awaits each batch before sending the next.")
+	maxInFlight = flag.Int("gribi_scale_max_in_flight", gribi.DefaultMaxInFlight,
+		"Number of operations that may await their result with -gribi_scale_workers.")
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1 and dut:port2 ->
+// ate:port2.  There are 8 VLAN sub-interfaces between dut:port2 and
+// ate:port2, each of which is a gRIBI next hop.
+//
+//   - ate:port1 -> dut:port1 subnet 192.0.2.0/30
+//   - dut:port2.i -> ate:port2.i VLAN-ID i+1 subnet 198.51.100.(4*i)/30
+//
+// The IPv4 entries are /32 prefixes allocated consecutively from
+// 198.18.0.0 (BMWG), and are spread across the next-hop-groups.
+const (
+	plen4 = 30
+
+	nhCount       = 8
+	nhgCount      = 64
+	nhIndexStart  = 1
+	nhgIndexStart = 1
+	batchSize     = 200
+	sampleCount   = 1000
+
+	ateSrcName     = "src"
+	ateDstNetName  = "dstnet"
+	startPrefix    = "198.18.0.0"
+	batchAwaitTime = 2 * time.Minute
+
+	utilizationInterval = 5 * time.Second
+	// telemetryAwaitTime is how long to wait after programming for the
+	// AFT telemetry of the IPv4 entries not received yet.
+	telemetryAwaitTime = 2 * time.Minute
+	// maxReportedMissing caps the number of prefixes reported as missing
+	// from the AFT telemetry.
+	maxReportedMissing = 10
+)
+
+var (
+	ateSrc = attrs.Attributes{
+		Name:    ateSrcName,
+		IPv4:    "192.0.2.1",
+		IPv4Len: plen4,
+	}
+
+	dutSrc = attrs.Attributes{
+		Desc:    "DUT to ATE source",
+		IPv4:    "192.0.2.2",
+		IPv4Len: plen4,
+	}
+)
+
+// dstAddrs returns the DUT and ATE addresses of the ith destination
+// sub-interface.
+func dstAddrs(i int) (dutIPv4, ateIPv4 string) {
+	return fmt.Sprintf("198.51.100.%d", 4*i+1), fmt.Sprintf("198.51.100.%d", 4*i+2)
+}
+
+// configureDUT configures port1 and the port2 sub-interfaces on the DUT.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	d := dut.Config()
+
+	p1 := dut.Port(t, "port1")
+	d.Interface(p1.Name()).Replace(t, dutSrc.NewInterface(p1.Name()))
+
+	p2 := dut.Port(t, "port2")
+	i2 := &telemetry.Interface{Name: ygot.String(p2.Name())}
+	i2.Description = ygot.String("DUT to ATE destination")
+	i2.Type = telemetry.IETFInterfaces_InterfaceType_ethernetCsmacd
+	if *deviations.InterfaceEnabled {
+		i2.Enabled = ygot.Bool(true)
+	}
+	for i := 0; i < nhCount; i++ {
+		dutIPv4, _ := dstAddrs(i)
+		s := i2.GetOrCreateSubinterface(uint32(i + 1))
+		if *deviations.DeprecatedVlanID {
+			s.GetOrCreateVlan().VlanId = telemetry.UnionUint16(uint16(i + 1))
+		} else {
+			s.GetOrCreateVlan().GetOrCreateMatch().GetOrCreateSingleTagged().VlanId = ygot.Uint16(uint16(i + 1))
+		}
+		s4 := s.GetOrCreateIpv4()
+		if *deviations.InterfaceEnabled {
+			s4.Enabled = ygot.Bool(true)
+		}
+		s4.GetOrCreateAddress(dutIPv4).PrefixLength = ygot.Uint8(plen4)
+	}
+	d.Interface(p2.Name()).Replace(t, i2)
+}
+
+// configureATE configures port1 and the port2 sub-interfaces on the
+// ATE, and returns the ATE addresses of the destination
+// sub-interfaces.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice) (*ondatra.ATETopology, []string) {
+	top := ate.Topology().New()
+
+	p1 := ate.Port(t, "port1")
+	i1 := top.AddInterface(ateSrc.Name).WithPort(p1)
+	i1.IPv4().
+		WithAddress(ateSrc.IPv4CIDR()).
+		WithDefaultGateway(dutSrc.IPv4)
+
+	p2 := ate.Port(t, "port2")
+	var nhAddrs []string
+	for i := 0; i < nhCount; i++ {
+		dutIPv4, ateIPv4 := dstAddrs(i)
+		name := fmt.Sprintf("dst%d", i)
+		i2 := top.AddInterface(name).WithPort(p2)
+		i2.Ethernet().WithVLANID(uint16(i + 1))
+		i2.IPv4().
+			WithAddress(fmt.Sprintf("%s/%d", ateIPv4, plen4)).
+			WithDefaultGateway(dutIPv4)
+		if i == 0 {
+			i2.AddNetwork(ateDstNetName).IPv4().WithAddress(startPrefix + "/32").WithCount(uint32(*scaleCount))
+		}
+		nhAddrs = append(nhAddrs, ateIPv4)
+	}
+
+	top.Push(t).StartProtocols(t)
+	return top, nhAddrs
+}
+
+// batchResult is the programming result of a single ModifyRequest.
+type batchResult struct {
+	Batch     int     `json:"batch"`
+	Phase     string  `json:"phase,omitempty"`
+	Entries   int     `json:"entries"`
+	AckTimeMS float64 `json:"ack_time_ms"`
+}
+
+// phaseResult is the programming result of a phase of the pipelined
+// programming.
+type phaseResult struct {
+	Phase        string  `json:"phase"`
+	Entries      int     `json:"entries"`
+	WallTimeMS   float64 `json:"wall_time_ms"`
+	OpsPerSecond float64 `json:"ops_per_second"`
+}
+
+// scaleSummary is written as a JSON test artifact.
+type scaleSummary struct {
+	IPv4Entries     int           `json:"ipv4_entries"`
+	NextHopGroups   int           `json:"next_hop_groups"`
+	NextHops        int           `json:"next_hops"`
+	BatchSize       int           `json:"batch_size"`
+	FIBACK          bool          `json:"fib_ack"`
+	Workers         int           `json:"workers,omitempty"`
+	MaxInFlight     int           `json:"max_in_flight,omitempty"`
+	Batches         []batchResult `json:"batches,omitempty"`
+	Phases          []phaseResult `json:"phases,omitempty"`
+	TotalWallTimeMS float64       `json:"total_wall_time_ms"`
+	OpsPerSecond    float64       `json:"ops_per_second"`
+	// TelemetryLatencyMS holds percentiles of the time from the
+	// acknowledgement of each IPv4 entry to its AFT telemetry, keyed by
+	// "p50", "p90", "p99" and "max".
+	TelemetryLatencyMS map[string]float64 `json:"telemetry_latency_ms,omitempty"`
+	TelemetryMissing   int                `json:"telemetry_missing"`
+}
+
+// milliseconds returns the duration in milliseconds.
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// programBatches programs the entries in batches, awaiting each batch
+// before sending the next one, and records the time of each batch.
+func programBatches(ctx context.Context, t *testing.T, c *gribi.Client, entries *gribi.Entries, summary *scaleSummary) {
+	fc := c.Fluent(t)
+	start := time.Now()
+	for i, batch := range gribi.Batches(entries.All(), batchSize) {
+		batchStart := time.Now()
+		fc.Modify().AddEntry(t, batch...)
+		if err := c.AwaitTimeout(ctx, t, batchAwaitTime); err != nil {
+			t.Fatalf("Await got error for batch %d: %v", i, err)
+		}
+		summary.Batches = append(summary.Batches, batchResult{
+			Batch:     i,
+			Entries:   len(batch),
+			AckTimeMS: milliseconds(time.Since(batchStart)),
+		})
+	}
+	wallTime := time.Since(start)
+	summary.TotalWallTimeMS = milliseconds(wallTime)
+	summary.OpsPerSecond = float64(len(entries.All())) / wallTime.Seconds()
+	t.Logf("Programmed %d entries in %d batches in %v", len(entries.All()), len(summary.Batches), wallTime)
+}
+
+// programPipelined programs the entries from a pool of workers, and
+// records the time of each phase.
+func programPipelined(t *testing.T, c *gribi.Client, entries *gribi.Entries, summary *scaleSummary) {
+	summary.Workers = *workers
+	summary.MaxInFlight = *maxInFlight
+	stats, err := gribi.Program(t, c.Fluent(t), entries, &gribi.PipelineOptions{
+		BatchSize:   batchSize,
+		Workers:     *workers,
+		MaxInFlight: *maxInFlight,
+		FIBACK:      c.FibACK,
+	})
+	for _, ps := range stats.Phases {
+		for _, bs := range ps.Batches {
+			summary.Batches = append(summary.Batches, batchResult{
+				Batch:     len(summary.Batches),
+				Phase:     ps.Name,
+				Entries:   bs.Ops,
+				AckTimeMS: milliseconds(bs.Duration),
+			})
+		}
+		summary.Phases = append(summary.Phases, phaseResult{
+			Phase:        ps.Name,
+			Entries:      ps.Ops,
+			WallTimeMS:   milliseconds(ps.Duration),
+			OpsPerSecond: ps.OpsPerSecond(),
+		})
+	}
+	summary.TotalWallTimeMS = milliseconds(stats.Duration)
+	summary.OpsPerSecond = stats.OpsPerSecond()
+	if err != nil {
+		t.Fatalf("Cannot program the entries: %v", err)
+	}
+	t.Logf("Programmed %d entries with %d workers in %v, %.0f operations per second", stats.Ops, *workers, stats.Duration, stats.OpsPerSecond())
+}
+
+// ackTimes returns when each IPv4 prefix was acknowledged with the
+// status.
+func ackTimes(t *testing.T, c *gribi.Client, status spb.AFTResult_Status) map[string]time.Time {
+	acked := make(map[string]time.Time)
+	for _, res := range c.Fluent(t).Results(t) {
+		if res.Details != nil && res.Details.IPv4Prefix != "" && res.ProgrammingResult == status {
+			acked[res.Details.IPv4Prefix] = time.Unix(0, res.Timestamp)
+		}
+	}
+	return acked
+}
+
+// verifyTelemetry waits for the AFT telemetry of all the IPv4 entries
+// that the collector did not receive while programming, and records
+// the latency percentiles from their acknowledgement to their
+// telemetry.
+func verifyTelemetry(t *testing.T, col *aftcheck.Collector, acked map[string]time.Time, summary *scaleSummary) {
+	missing := col.Await(telemetryAwaitTime)
+	if err := col.Err(); err != nil {
+		t.Errorf("AFT telemetry subscription ended: %v", err)
+	}
+	summary.TelemetryMissing = len(missing)
+	latencies := col.Latencies(acked)
+	ds := make([]time.Duration, 0, len(latencies))
+	for _, d := range latencies {
+		ds = append(ds, d)
+	}
+	if len(ds) > 0 {
+		summary.TelemetryLatencyMS = map[string]float64{
+			"p50": milliseconds(aftcheck.Percentile(ds, 50)),
+			"p90": milliseconds(aftcheck.Percentile(ds, 90)),
+			"p99": milliseconds(aftcheck.Percentile(ds, 99)),
+			"max": milliseconds(aftcheck.Percentile(ds, 100)),
+		}
+		t.Logf("AFT telemetry latency of %d IPv4 entries in ms: %v", len(ds), summary.TelemetryLatencyMS)
+	}
+	if len(missing) > 0 {
+		reported := missing
+		if len(reported) > maxReportedMissing {
+			reported = reported[:maxReportedMissing]
+		}
+		t.Errorf("AFT telemetry of %d IPv4 entries not received within %v after programming, including %v", len(missing), telemetryAwaitTime, reported)
+	}
+}
+
+// writeSummary writes the summary to the test outputs directory.
+func writeSummary(t *testing.T, s *scaleSummary) {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		t.Errorf("Cannot marshal scale summary: %v", err)
+		return
+	}
+	if err := fptest.WriteOutput(t.Name()+" summary", ".json", string(b)); err != nil {
+		t.Errorf("Cannot write scale summary: %v", err)
+	}
+}
+
+// testTraffic sends a flow to a sample of the programmed destinations
+// of each next-hop-group, all at the same time, and checks each flow for
+// packet loss.
+func testTraffic(t *testing.T, ate *ondatra.ATEDevice, top *ondatra.ATETopology, count int) {
+	src := top.Interfaces()[ateSrcName]
+	dst := top.Interfaces()["dst0"].Networks()[ateDstNetName]
+
+	stride := 1
+	if count > sampleCount {
+		stride = count / sampleCount
+	}
+	// Prefix i is programmed via next-hop-group i%nhgCount, so the
+	// sampled prefixes of next-hop-group g are g, g+step, and so on.
+	step := nhgCount * stride
+	// The range of each flow ends right before the sample following its
+	// last one, past the programmed prefixes, so that the ATE spaces its
+	// addresses by step.
+	prefixes, err := gribi.IPv4Prefixes(startPrefix, count+step)
+	if err != nil {
+		t.Fatalf("Cannot sample the destinations: %v", err)
+	}
+	addr := func(i int) string { return strings.TrimSuffix(prefixes[i], "/32") }
+
+	var flows []*ondatra.Flow
+	for g := 0; g < nhgCount && g < count; g++ {
+		n := (count-1-g)/step + 1
+		ipv4Header := ondatra.NewIPv4Header()
+		ipv4Header.DstAddressRange().
+			WithMin(addr(g)).
+			WithMax(addr(g + n*step - 1)).
+			WithCount(uint32(n))
+
+		flows = append(flows, ate.Traffic().NewFlow(fmt.Sprintf("NHG%d", nhgIndexStart+g)).
+			WithSrcEndpoints(src).
+			WithDstEndpoints(dst).
+			WithHeaders(ondatra.NewEthernetHeader(), ipv4Header))
+	}
+	traffic.ValidateFlows(t, ate, flows, nil)
+}
+
+func TestIPv4EntryScale(t *testing.T) {
+	ctx := context.Background()
+	dut := ondatra.DUT(t, "dut")
+	ate := ondatra.ATE(t, "ate")
+
+	if *scaleCount <= 0 {
+		t.Fatalf("Invalid -gribi_scale_count %d", *scaleCount)
+	}
+
+	configureDUT(t, dut)
+	top, nhAddrs := configureATE(t, ate)
+
+	entries, err := gribi.GenerateEntries(&gribi.EntryParams{
+		NetworkInstance: *deviations.DefaultNetworkInstance,
+		NHAddresses:     nhAddrs,
+		NHIndexStart:    nhIndexStart,
+		NHGCount:        nhgCount,
+		NHGIndexStart:   nhgIndexStart,
+		StartPrefix:     startPrefix,
+		PrefixCount:     *scaleCount,
+	})
+	if err != nil {
+		t.Fatalf("Cannot generate gRIBI entries: %v", err)
+	}
+
+	c := &gribi.Client{
+		DUT:                  dut,
+		FibACK:               !*deviations.GRIBIRIBAckOnly,
+		Persistence:          true,
+		InitialElectionIDLow: 1,
+	}
+	defer c.Close(t)
+	if err := c.Start(t); err != nil {
+		t.Fatalf("gRIBI connection could not be established: %v", err)
+	}
+	c.BecomeLeader(t)
+	// Flush is deferred right after the client becomes leader so that a
+	// failure partway through programming does not leave entries behind.
+	defer c.Flush(t)
+
+	wantStatus := spb.AFTResult_FIB_PROGRAMMED
+	if *deviations.GRIBIRIBAckOnly {
+		wantStatus = spb.AFTResult_RIB_PROGRAMMED
+	}
+
+	summary := &scaleSummary{
+		IPv4Entries:   *scaleCount,
+		NextHopGroups: nhgCount,
+		NextHops:      nhCount,
+		BatchSize:     batchSize,
+		FIBACK:        c.FibACK,
+	}
+	defer writeSummary(t, summary)
+
+	// The DUT utilization is sampled while the entries are programmed,
+	// and written to the test outputs directory.
+	mon := utilization.Start(t, dut, &utilization.Options{
+		Interval:        utilizationInterval,
+		CPUThreshold:    *cpuThreshold,
+		MemoryThreshold: *memoryThreshold,
+		Grace:           *utilizationGrace,
+	})
+	// The AFT telemetry is collected while the entries are programmed,
+	// so that verifying it overlaps with programming.
+	col := aftcheck.Collect(t, dut, *deviations.DefaultNetworkInstance, entries.Prefixes, nil)
+	defer col.Close()
+	programmed := t.Run("Program", func(t *testing.T) {
+		if *workers > 0 {
+			programPipelined(t, c, entries, summary)
+		} else {
+			programBatches(ctx, t, c, entries, summary)
+		}
+
+		if installed := len(ackTimes(t, c, wantStatus)); installed != *scaleCount {
+			t.Fatalf("IPv4 entries reported %s got %d, want %d", wantStatus, installed, *scaleCount)
+		}
+	})
+	mon.Stop(t)
+	if !programmed {
+		t.Fatal("Not verifying the IPv4 entries, since programming them failed")
+	}
+
+	t.Run("Telemetry", func(t *testing.T) {
+		verifyTelemetry(t, col, ackTimes(t, c, wantStatus), summary)
+	})
+
+	t.Run("Traffic", func(t *testing.T) {
+		testTraffic(t, ate, top, *scaleCount)
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		gr, err := c.Fluent(t).Get().
+			WithNetworkInstance(*deviations.DefaultNetworkInstance).
+			WithAFT(fluent.IPv4).
+			Send()
+		if err != nil {
+			t.Fatalf("gRIBI Get got unexpected error: %v", err)
+		}
+		got := 0
+		for _, e := range gr.GetEntry() {
+			if e.GetIpv4() != nil {
+				got++
+			}
+		}
+		if got != *scaleCount {
+			t.Errorf("gRIBI Get IPv4 entries got %d, want %d", got, *scaleCount)
+		}
+	})
+}
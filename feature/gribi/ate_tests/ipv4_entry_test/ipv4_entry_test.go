@@ -23,6 +23,7 @@ import (
 	"github.com/openconfig/featureprofiles/internal/attrs"
 	"github.com/openconfig/featureprofiles/internal/deviations"
 	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/traffic"
 	"github.com/openconfig/gribigo/chk"
 	"github.com/openconfig/gribigo/client"
 	"github.com/openconfig/gribigo/constants"
@@ -313,7 +314,7 @@ func TestIPv4Entry(t *testing.T) {
 						chk.HasResult(t, c.Results(t), wantResult, chk.IgnoreOperationID())
 					}
 
-					validateTrafficFlows(t, ate, tc.wantGoodFlows, tc.wantBadFlows)
+					validateTrafficFlows(t, ate, dut, tc.wantGoodFlows, tc.wantBadFlows)
 				})
 			}
 		})
@@ -369,26 +370,32 @@ func createFlow(name string, ate *ondatra.ATEDevice, ateTop *ondatra.ATETopology
 	return flow
 }
 
-func validateTrafficFlows(t *testing.T, ate *ondatra.ATEDevice, good []*ondatra.Flow, bad []*ondatra.Flow) {
-	if len(good) == 0 && len(bad) == 0 {
-		return
-	}
-
-	flows := append(good, bad...)
-	ate.Traffic().Start(t, flows...)
-	time.Sleep(15 * time.Second)
-	ate.Traffic().Stop(t)
-
-	for _, flow := range good {
-		if got := ate.Telemetry().Flow(flow.Name()).LossPct().Get(t); got > 0 {
-			t.Fatalf("LossPct for flow %s: got %g, want 0", flow.Name(), got)
+// validateTrafficFlows runs the flows and checks that the good flows have
+// no loss and the bad flows are lost.  If there are good flows, which
+// the DUT forwards, it also checks the DUT counters: the DUT must not
+// discard packets on port1, and must forward at least the packets the
+// ATE sent to ports 2 and 3.
+func validateTrafficFlows(t *testing.T, ate *ondatra.ATEDevice, dut *ondatra.DUTDevice, good []*ondatra.Flow, bad []*ondatra.Flow) {
+	if len(good) > 0 {
+		before := traffic.SnapshotCounters(t, dut, "port1", "port2", "port3")
+		results := traffic.ValidateFlows(t, ate, good, nil)
+		counters := traffic.StableCounters(t, dut, "port1", "port2", "port3").Diff(before)
+		t.Logf("DUT counters incremented by:\n%v", counters)
+
+		var sent uint64
+		for _, r := range results {
+			sent += r.OutPkts
+		}
+		if err := counters.NoDrops("port1"); err != nil {
+			t.Errorf("DUT dropped packets: %v", err)
+		}
+		if err := counters.ForwardedAtLeast(sent, "port1", "port2", "port3"); err != nil {
+			t.Errorf("DUT did not forward the %d packets sent by the ATE: %v", sent, err)
 		}
 	}
 
-	for _, flow := range bad {
-		if got := ate.Telemetry().Flow(flow.Name()).LossPct().Get(t); got < 100 {
-			t.Fatalf("LossPct for flow %s: got %g, want 100", flow.Name(), got)
-		}
+	if len(bad) > 0 {
+		traffic.ValidateFlows(t, ate, bad, &traffic.Options{WantLoss: true})
 	}
 }
 
@@ -190,22 +190,16 @@ func addRoute(ctx context.Context, t *testing.T, args *testArgs, clientA *gribi.
 func verifyAFT(ctx context.Context, t *testing.T, args *testArgs) {
 	t.Logf("Verify through AFT Telemetry that %s is active", ateDstNetCIDR)
 	ipv4Path := args.dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).Afts().Ipv4Entry(ateDstNetCIDR)
-	if got, ok := ipv4Path.Prefix().Watch(t, time.Minute, func(val *telemetry.QualifiedString) bool {
-		return val.IsPresent() && val.Val(t) == ateDstNetCIDR
-	}).Await(t); !ok {
-		t.Errorf("ipv4-entry/state/prefix got %s, want %s", got.Val(t), ateDstNetCIDR)
-	}
+	fptest.Await(t, ipv4Path.Prefix().Watch, time.Minute, ateDstNetCIDR)
 }
 
 // verifyNoAFT verifies through AFT Telemetry that a route is NOT present on the DUT.
 func verifyNoAFT(ctx context.Context, t *testing.T, args *testArgs) {
 	t.Logf("Verify through Telemetry that the route to %s is not present", ateDstNetCIDR)
 	ipv4Path := args.dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).Afts().Ipv4Entry(ateDstNetCIDR)
-	if got, ok := ipv4Path.Prefix().Watch(t, time.Minute, func(val *telemetry.QualifiedString) bool {
-		return !val.IsPresent() || (val.IsPresent() && val.Val(t) == "")
-	}).Await(t); !ok {
-		t.Errorf("ipv4-entry/state/prefix got %s, want nil", got.Val(t))
-	}
+	fptest.AwaitFunc[string](t, ipv4Path.Prefix().Watch, time.Minute, "no prefix", func(val *telemetry.QualifiedString) bool {
+		return !val.IsPresent() || val.Val(t) == ""
+	})
 }
 
 // verifyTraffic verifies that traffic flows through the DUT without any loss.
@@ -0,0 +1,267 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package longest_prefix_match_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/gribi"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/gribigo/fluent"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1,
+// dut:port2 -> ate:port2 and dut:port3 -> ate:port3.
+//
+//   - ate:port1 -> dut:port1 subnet 192.0.2.0/30
+//   - ate:port2 -> dut:port2 subnet 192.0.2.4/30
+//   - ate:port3 -> dut:port3 subnet 192.0.2.8/30
+//
+// The covering network 203.0.113.0/24 is routed to ate:port2 via
+// NextHopGroup 20, and the more specific host 203.0.113.77/32 is routed
+// to ate:port3 via NextHopGroup 30.
+const (
+	ipv4PrefixLen = 30
+	coverCIDR     = "203.0.113.0/24"
+	hostCIDR      = "203.0.113.77/32"
+	hostAddr      = "203.0.113.77"
+	otherAddr     = "203.0.113.200"
+	coverNHIndex  = 2
+	coverNHGIndex = 20
+	hostNHIndex   = 3
+	hostNHGIndex  = 30
+
+	// minRatio is the minimum fraction of the transmitted packets that
+	// should be received on the expected ports.
+	minRatio = 0.99
+)
+
+var (
+	dutPort1 = attrs.Attributes{
+		Desc:    "dutPort1",
+		IPv4:    "192.0.2.1",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort1 = attrs.Attributes{
+		Name:    "atePort1",
+		IPv4:    "192.0.2.2",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	dutPort2 = attrs.Attributes{
+		Desc:    "dutPort2",
+		IPv4:    "192.0.2.5",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort2 = attrs.Attributes{
+		Name:    "atePort2",
+		IPv4:    "192.0.2.6",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	dutPort3 = attrs.Attributes{
+		Desc:    "dutPort3",
+		IPv4:    "192.0.2.9",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort3 = attrs.Attributes{
+		Name:    "atePort3",
+		IPv4:    "192.0.2.10",
+		IPv4Len: ipv4PrefixLen,
+	}
+)
+
+// configureDUT configures port1, port2 and port3 on the DUT.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	d := dut.Config()
+
+	p1 := dut.Port(t, "port1")
+	d.Interface(p1.Name()).Replace(t, dutPort1.NewInterface(p1.Name()))
+
+	p2 := dut.Port(t, "port2")
+	d.Interface(p2.Name()).Replace(t, dutPort2.NewInterface(p2.Name()))
+
+	p3 := dut.Port(t, "port3")
+	d.Interface(p3.Name()).Replace(t, dutPort3.NewInterface(p3.Name()))
+}
+
+// configureATE configures port1, port2 and port3 on the ATE.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice) *ondatra.ATETopology {
+	top := ate.Topology().New()
+	atePort1.AddToATE(top, ate.Port(t, "port1"), &dutPort1)
+	atePort2.AddToATE(top, ate.Port(t, "port2"), &dutPort2)
+	atePort3.AddToATE(top, ate.Port(t, "port3"), &dutPort3)
+	return top
+}
+
+// flowCase is a flow to a destination address and the ATE port
+// expected to receive it.
+type flowCase struct {
+	name     string
+	dstAddr  string
+	wantPort *ondatra.Port
+}
+
+// testTraffic sends the flows from ate:port1 together, and checks that
+// each ATE destination port receives the packets of the flows expected
+// there and no others.
+func testTraffic(t *testing.T, ate *ondatra.ATEDevice, top *ondatra.ATETopology, cases []flowCase) {
+	ports := []*ondatra.Port{ate.Port(t, "port2"), ate.Port(t, "port3")}
+
+	var flows []*ondatra.Flow
+	for _, c := range cases {
+		ipv4Header := ondatra.NewIPv4Header()
+		ipv4Header.DstAddressRange().WithMin(c.dstAddr).WithCount(1)
+		flows = append(flows, ate.Traffic().NewFlow(c.name).
+			WithSrcEndpoints(top.Interfaces()[atePort1.Name]).
+			WithDstEndpoints(top.Interfaces()[atePort2.Name], top.Interfaces()[atePort3.Name]).
+			WithHeaders(ondatra.NewEthernetHeader(), ipv4Header))
+	}
+
+	before := traffic.PortInPkts(t, ate, ports)
+	results := traffic.ValidateFlows(t, ate, flows, nil)
+	after := traffic.PortInPkts(t, ate, ports)
+
+	want := make(map[string]uint64)
+	var total uint64
+	for i, c := range cases {
+		want[c.wantPort.ID()] += results[i].OutPkts
+		total += results[i].OutPkts
+	}
+	if total == 0 {
+		t.Fatalf("Flows sent no packets")
+	}
+	slack := (1 - minRatio) * float64(total)
+	for i, p := range ports {
+		got, w := after[i]-before[i], want[p.ID()]
+		if math.Abs(float64(got)-float64(w)) > slack {
+			t.Errorf("Port %s received %d packets, want %d (+/- %.0f) from flows %v", p.ID(), got, w, slack, flowsTo(cases, p))
+		}
+	}
+}
+
+// flowsTo returns the names of the flows expected on the port.
+func flowsTo(cases []flowCase, p *ondatra.Port) []string {
+	var names []string
+	for _, c := range cases {
+		if c.wantPort.ID() == p.ID() {
+			names = append(names, c.name)
+		}
+	}
+	return names
+}
+
+// aftNextHopGroup waits for the prefix to be present in the AFT and
+// returns the programmed ID of its next-hop-group.
+func aftNextHopGroup(t *testing.T, dut *ondatra.DUTDevice, prefix string) uint64 {
+	t.Helper()
+	afts := dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).Afts()
+	ipv4Path := afts.Ipv4Entry(prefix)
+	if !fptest.Await(t, ipv4Path.Prefix().Watch, time.Minute, prefix) {
+		t.FailNow()
+	}
+	return afts.NextHopGroup(ipv4Path.NextHopGroup().Get(t)).ProgrammedId().Get(t)
+}
+
+func TestLongestPrefixMatch(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	ate := ondatra.ATE(t, "ate")
+
+	configureDUT(t, dut)
+	top := configureATE(t, ate)
+	top.Push(t).StartProtocols(t)
+	defer top.StopProtocols(t)
+
+	ap2 := ate.Port(t, "port2")
+	ap3 := ate.Port(t, "port3")
+
+	wantInstalled := fluent.InstalledInFIB
+	if *deviations.GRIBIRIBAckOnly {
+		wantInstalled = fluent.InstalledInRIB
+	}
+	c := &gribi.Client{
+		DUT:                  dut,
+		FibACK:               !*deviations.GRIBIRIBAckOnly,
+		Persistence:          true,
+		InitialElectionIDLow: 10,
+	}
+	defer c.Close(t)
+	if err := c.Start(t); err != nil {
+		t.Fatalf("gRIBI connection could not be established: %v", err)
+	}
+	c.BecomeLeader(t)
+	defer c.Flush(t)
+
+	t.Logf("Program %s to ATE port-2 and %s to ATE port-3 via gRIBI.", coverCIDR, hostCIDR)
+	c.AddNH(t, coverNHIndex, atePort2.IPv4, *deviations.DefaultNetworkInstance, wantInstalled)
+	c.AddNHG(t, coverNHGIndex, map[uint64]uint64{coverNHIndex: 1}, *deviations.DefaultNetworkInstance, wantInstalled)
+	c.AddIPv4(t, coverCIDR, coverNHGIndex, *deviations.DefaultNetworkInstance, "", wantInstalled)
+	c.AddNH(t, hostNHIndex, atePort3.IPv4, *deviations.DefaultNetworkInstance, wantInstalled)
+	c.AddNHG(t, hostNHGIndex, map[uint64]uint64{hostNHIndex: 1}, *deviations.DefaultNetworkInstance, wantInstalled)
+	c.AddIPv4(t, hostCIDR, hostNHGIndex, *deviations.DefaultNetworkInstance, "", wantInstalled)
+
+	t.Run("AFT", func(t *testing.T) {
+		if got, want := aftNextHopGroup(t, dut, coverCIDR), uint64(coverNHGIndex); got != want {
+			t.Errorf("%s next-hop-group/state/programmed-id got %d, want %d", coverCIDR, got, want)
+		}
+		if got, want := aftNextHopGroup(t, dut, hostCIDR), uint64(hostNHGIndex); got != want {
+			t.Errorf("%s next-hop-group/state/programmed-id got %d, want %d", hostCIDR, got, want)
+		}
+	})
+
+	t.Run("Traffic", func(t *testing.T) {
+		testTraffic(t, ate, top, []flowCase{
+			{name: "HostRoute", dstAddr: hostAddr, wantPort: ap3},
+			{name: "CoveringRoute", dstAddr: otherAddr, wantPort: ap2},
+		})
+	})
+
+	t.Logf("Delete %s from gRIBI.", hostCIDR)
+	c.DeleteIPv4(t, hostCIDR, *deviations.DefaultNetworkInstance, wantInstalled)
+
+	t.Run("AFTAfterDelete", func(t *testing.T) {
+		ipv4Path := dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).Afts().Ipv4Entry(hostCIDR)
+		fptest.AwaitFunc[string](t, ipv4Path.Prefix().Watch, time.Minute, "no prefix", func(val *telemetry.QualifiedString) bool {
+			return !val.IsPresent()
+		})
+		if got, want := aftNextHopGroup(t, dut, coverCIDR), uint64(coverNHGIndex); got != want {
+			t.Errorf("%s next-hop-group/state/programmed-id got %d, want %d", coverCIDR, got, want)
+		}
+	})
+
+	t.Run("TrafficAfterDelete", func(t *testing.T) {
+		testTraffic(t, ate, top, []flowCase{
+			{name: "HostRouteDeleted", dstAddr: hostAddr, wantPort: ap2},
+			{name: "CoveringRoute", dstAddr: otherAddr, wantPort: ap2},
+		})
+	})
+}
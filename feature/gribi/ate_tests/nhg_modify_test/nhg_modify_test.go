@@ -0,0 +1,228 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nhg_modify_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/openconfig/featureprofiles/internal/aftcheck"
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/gribi"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/gribigo/fluent"
+	"github.com/openconfig/ondatra"
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1,
+// dut:port2 -> ate:port2 and dut:port3 -> ate:port3.
+//
+//   - ate:port1 -> dut:port1 subnet 192.0.2.0/30
+//   - ate:port2 -> dut:port2 subnet 192.0.2.4/30
+//   - ate:port3 -> dut:port3 subnet 192.0.2.8/30
+//
+// The destination network 203.0.113.0/24 is routed via NextHopGroup
+// 10, which starts with a next hop to ate:port2 and is grown to also
+// include a next hop to ate:port3.
+const (
+	ipv4PrefixLen = 30
+	ateDstNetCIDR = "203.0.113.0/24"
+	nh1Index      = 1
+	nh2Index      = 2
+	nhgIndex      = 10
+
+	frameRate      = 1000 // frames per second
+	sampleInterval = time.Second
+	// balanceTolerancePct is the maximum deviation in percentage points
+	// of the fraction of traffic received on each port from the
+	// expected fraction.
+	balanceTolerancePct = 15
+)
+
+var (
+	dutPort1 = attrs.Attributes{
+		Desc:    "dutPort1",
+		IPv4:    "192.0.2.1",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort1 = attrs.Attributes{
+		Name:    "atePort1",
+		IPv4:    "192.0.2.2",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	dutPort2 = attrs.Attributes{
+		Desc:    "dutPort2",
+		IPv4:    "192.0.2.5",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort2 = attrs.Attributes{
+		Name:    "atePort2",
+		IPv4:    "192.0.2.6",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	dutPort3 = attrs.Attributes{
+		Desc:    "dutPort3",
+		IPv4:    "192.0.2.9",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort3 = attrs.Attributes{
+		Name:    "atePort3",
+		IPv4:    "192.0.2.10",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	// distributionOpts measure the distribution of the traffic across
+	// ports once it settled after a change.
+	distributionOpts = &traffic.DistributionOptions{Settle: true}
+)
+
+// configureDUT configures port1, port2 and port3 on the DUT.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	d := dut.Config()
+
+	p1 := dut.Port(t, "port1")
+	d.Interface(p1.Name()).Replace(t, dutPort1.NewInterface(p1.Name()))
+
+	p2 := dut.Port(t, "port2")
+	d.Interface(p2.Name()).Replace(t, dutPort2.NewInterface(p2.Name()))
+
+	p3 := dut.Port(t, "port3")
+	d.Interface(p3.Name()).Replace(t, dutPort3.NewInterface(p3.Name()))
+}
+
+// configureATE configures port1, port2 and port3 on the ATE.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice) *ondatra.ATETopology {
+	top := ate.Topology().New()
+	atePort1.AddToATE(top, ate.Port(t, "port1"), &dutPort1)
+	atePort2.AddToATE(top, ate.Port(t, "port2"), &dutPort2)
+	atePort3.AddToATE(top, ate.Port(t, "port3"), &dutPort3)
+	return top
+}
+
+// newFlow creates a flow from ate:port1 to the destination network,
+// varying the destination address so that it is hashed across all the
+// next hops of the NextHopGroup.
+func newFlow(ate *ondatra.ATEDevice, top *ondatra.ATETopology) *ondatra.Flow {
+	ipv4Header := ondatra.NewIPv4Header()
+	ipv4Header.DstAddressRange().
+		WithMin("203.0.113.1").
+		WithMax("203.0.113.254").
+		WithCount(254)
+
+	return ate.Traffic().NewFlow("Flow").
+		WithSrcEndpoints(top.Interfaces()[atePort1.Name]).
+		WithDstEndpoints(top.Interfaces()[atePort2.Name], top.Interfaces()[atePort3.Name]).
+		WithHeaders(ondatra.NewEthernetHeader(), ipv4Header).
+		WithFrameRateFPS(frameRate)
+}
+
+func TestNHGModify(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	ate := ondatra.ATE(t, "ate")
+
+	configureDUT(t, dut)
+	top := configureATE(t, ate)
+	top.Push(t).StartProtocols(t)
+
+	wantInstalled := fluent.InstalledInFIB
+	if *deviations.GRIBIRIBAckOnly {
+		wantInstalled = fluent.InstalledInRIB
+	}
+	c := &gribi.Client{
+		DUT:                  dut,
+		FibACK:               !*deviations.GRIBIRIBAckOnly,
+		Persistence:          true,
+		InitialElectionIDLow: 10,
+	}
+	defer c.Close(t)
+	if err := c.Start(t); err != nil {
+		t.Fatalf("gRIBI connection could not be established: %v", err)
+	}
+	c.BecomeLeader(t)
+	defer c.Flush(t)
+
+	t.Logf("Program %s via NextHopGroup %d with a next hop to ATE port-2.", ateDstNetCIDR, nhgIndex)
+	c.AddNH(t, nh1Index, atePort2.IPv4, *deviations.DefaultNetworkInstance, wantInstalled)
+	c.AddNH(t, nh2Index, atePort3.IPv4, *deviations.DefaultNetworkInstance, wantInstalled)
+	c.AddNHG(t, nhgIndex, map[uint64]uint64{nh1Index: 1}, *deviations.DefaultNetworkInstance, wantInstalled)
+	c.AddIPv4(t, ateDstNetCIDR, nhgIndex, *deviations.DefaultNetworkInstance, "", wantInstalled)
+
+	flow := newFlow(ate, top)
+	ate.Traffic().Start(t, flow)
+	sampler := traffic.StartSampler(t, ate, flow.Name(), sampleInterval)
+	stopped := false
+	defer func() {
+		if !stopped {
+			ate.Traffic().Stop(t)
+		}
+	}()
+
+	t.Run("OneNextHop", func(t *testing.T) {
+		traffic.CheckDistribution(t, ate, []string{"port2", "port3"}, []uint64{1, 0}, balanceTolerancePct, distributionOpts)
+	})
+
+	t.Run("Grow", func(t *testing.T) {
+		t.Logf("Add a next hop to ATE port-3 to NextHopGroup %d.", nhgIndex)
+		c.AddNHG(t, nhgIndex, map[uint64]uint64{nh1Index: 1, nh2Index: 1}, *deviations.DefaultNetworkInstance, wantInstalled)
+		if got, want := aftcheck.Weights(aftcheck.NextHops(t, dut, *deviations.DefaultNetworkInstance, nhgIndex)), []uint64{1, 1}; !cmp.Equal(got, want) {
+			t.Errorf("next-hop-group/next-hop/state/weight got %v, want %v", got, want)
+		}
+		traffic.CheckDistribution(t, ate, []string{"port2", "port3"}, []uint64{1, 1}, balanceTolerancePct, distributionOpts)
+	})
+
+	t.Run("Shrink", func(t *testing.T) {
+		t.Logf("Remove the next hop to ATE port-3 from NextHopGroup %d.", nhgIndex)
+		c.AddNHG(t, nhgIndex, map[uint64]uint64{nh1Index: 1}, *deviations.DefaultNetworkInstance, wantInstalled)
+		if got, want := aftcheck.Weights(aftcheck.NextHops(t, dut, *deviations.DefaultNetworkInstance, nhgIndex)), []uint64{1}; !cmp.Equal(got, want) {
+			t.Errorf("next-hop-group/next-hop/state/weight got %v, want %v", got, want)
+		}
+		traffic.CheckDistribution(t, ate, []string{"port2", "port3"}, []uint64{1, 0}, balanceTolerancePct, distributionOpts)
+	})
+
+	samples := sampler.Stop(t)
+	ate.Traffic().Stop(t)
+	stopped = true
+
+	t.Run("Loss", func(t *testing.T) {
+		worst := traffic.MaxLoss(traffic.Intervals(samples))
+		if worst == nil {
+			t.Fatalf("Flow %s has no counter samples", flow.Name())
+		}
+		t.Logf("Highest loss %.3f%% (%d of %d packets received) between %v and %v",
+			worst.LossPct(), worst.InPkts, worst.OutPkts,
+			worst.Start.Format(time.RFC3339), worst.End.Format(time.RFC3339))
+		if got := worst.LossPct(); got > *deviations.TrafficLossTolerance {
+			t.Errorf("Loss during NextHopGroup modification got %.3f%%, want at most %g%%", got, *deviations.TrafficLossTolerance)
+		}
+		if got := ate.Telemetry().Flow(flow.Name()).LossPct().Get(t); float64(got) > *deviations.TrafficLossTolerance {
+			t.Errorf("LossPct for flow %s got %g, want at most %g", flow.Name(), got, *deviations.TrafficLossTolerance)
+		}
+	})
+}
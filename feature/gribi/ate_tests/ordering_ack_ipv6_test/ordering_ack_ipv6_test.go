@@ -0,0 +1,290 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ordering_ack_ipv6_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/gribi"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/gribigo/chk"
+	"github.com/openconfig/gribigo/constants"
+	"github.com/openconfig/gribigo/fluent"
+	"github.com/openconfig/ondatra"
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1 and
+// dut:port2 -> ate:port2.  The first pair is called the "source"
+// pair, and the second the "destination" pair.
+//
+//   - Source: ate:port1 -> dut:port1 subnets 192.0.2.0/30 and
+//     2001:db8::/126
+//   - Destination: dut:port2 -> ate:port2 subnets 192.0.2.4/30 and
+//     2001:db8::4/126
+//
+// A traffic flow is sent from ate:port1 to the destination network
+// 2001:db8:1::/64 behind ate:port2.
+const (
+	plen4 = 30
+	plen6 = 126
+
+	ateDstNetName = "dstnet"
+	ateDstNetCIDR = "2001:db8:1::/64"
+
+	nhIndex  = 42
+	nhWeight = 1
+	nhgIndex = 10
+
+	awaitDuration = 2 * time.Minute
+)
+
+var (
+	ateSrc = attrs.Attributes{
+		Name:    "ateSrc",
+		IPv4:    "192.0.2.1",
+		IPv6:    "2001:db8::1",
+		IPv4Len: plen4,
+		IPv6Len: plen6,
+	}
+
+	dutSrc = attrs.Attributes{
+		Desc:    "DUT to ATE source",
+		IPv4:    "192.0.2.2",
+		IPv6:    "2001:db8::2",
+		IPv4Len: plen4,
+		IPv6Len: plen6,
+	}
+
+	dutDst = attrs.Attributes{
+		Desc:    "DUT to ATE destination",
+		IPv4:    "192.0.2.5",
+		IPv6:    "2001:db8::5",
+		IPv4Len: plen4,
+		IPv6Len: plen6,
+	}
+
+	ateDst = attrs.Attributes{
+		Name:    "dst",
+		IPv4:    "192.0.2.6",
+		IPv6:    "2001:db8::6",
+		IPv4Len: plen4,
+		IPv6Len: plen6,
+	}
+)
+
+// configureDUT configures port1 and port2 on the DUT.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	d := dut.Config()
+
+	p1 := dut.Port(t, "port1")
+	d.Interface(p1.Name()).Replace(t, dutSrc.NewInterface(p1.Name()))
+
+	p2 := dut.Port(t, "port2")
+	d.Interface(p2.Name()).Replace(t, dutDst.NewInterface(p2.Name()))
+}
+
+// configureATE configures port1 and port2 on the ATE, with the
+// destination network behind port2.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice) *ondatra.ATETopology {
+	top := ate.Topology().New()
+	ateSrc.AddToATE(top, ate.Port(t, "port1"), &dutSrc)
+	i2 := ateDst.AddToATE(top, ate.Port(t, "port2"), &dutDst)
+	i2.AddNetwork(ateDstNetName).IPv6().WithAddress(ateDstNetCIDR)
+	return top
+}
+
+// testArgs holds the objects needed by a test case.
+type testArgs struct {
+	ctx context.Context
+	c   *fluent.GRIBIClient
+	ops *gribi.OpIDs
+	dut *ondatra.DUTDevice
+	ate *ondatra.ATEDevice
+	top *ondatra.ATETopology
+
+	wantInstalled fluent.ProgrammingResult
+}
+
+// testTraffic sends an IPv6 flow from ate:port1 to the destination
+// network and checks for packet loss.
+func testTraffic(t *testing.T, args *testArgs) {
+	flow := traffic.NewIPv6Flow(t, args.ate, args.top, &traffic.FlowParams{
+		Name:       "IPv6",
+		Src:        &ateSrc,
+		Dst:        &ateDst,
+		DstNetwork: ateDstNetName,
+		DUT:        args.dut,
+		SrcPort:    args.dut.Port(t, "port1"),
+		DstPort:    args.dut.Port(t, "port2"),
+	})
+	traffic.ValidateFlow(t, args.ate, flow, nil)
+}
+
+// testModifyIPv6NHG configures a ModifyRequest with a NextHop and an
+// IPv6Entry before a NextHopGroup which is invalid due to the forward
+// reference.
+func testModifyIPv6NHG(t *testing.T, args *testArgs) {
+	ops := args.ops.Next(3)
+	args.c.Modify().AddEntry(t,
+		fluent.NextHopEntry().
+			WithNetworkInstance(*deviations.DefaultNetworkInstance).
+			WithIndex(nhIndex).
+			WithIPAddress(ateDst.IPv6),
+		gribi.NewIPv6Entry().
+			WithNetworkInstance(*deviations.DefaultNetworkInstance).
+			WithPrefix(ateDstNetCIDR).
+			WithNextHopGroup(nhgIndex),
+		fluent.NextHopGroupEntry().
+			WithNetworkInstance(*deviations.DefaultNetworkInstance).
+			WithID(nhgIndex).
+			AddNextHop(nhIndex, nhWeight),
+	)
+	if err := gribi.Await(args.ctx, t, args.c, nil, ops...); err != nil {
+		t.Fatalf("Await got error for ModifyRequest: %v", err)
+	}
+
+	gribi.HasIPv6Result(t, args.c.Results(t), ops[1], ateDstNetCIDR, constants.Add, fluent.ProgrammingFailed)
+}
+
+// testModifyNHGIPv6 configures a ModifyRequest with a NextHopGroup and
+// an IPv6Entry.
+func testModifyNHGIPv6(t *testing.T, args *testArgs) {
+	ops := args.ops.Next(3)
+	args.c.Modify().AddEntry(t,
+		fluent.NextHopEntry().
+			WithNetworkInstance(*deviations.DefaultNetworkInstance).
+			WithIndex(nhIndex).
+			WithIPAddress(ateDst.IPv6),
+		fluent.NextHopGroupEntry().
+			WithNetworkInstance(*deviations.DefaultNetworkInstance).
+			WithID(nhgIndex).
+			AddNextHop(nhIndex, nhWeight),
+		gribi.NewIPv6Entry().
+			WithNetworkInstance(*deviations.DefaultNetworkInstance).
+			WithPrefix(ateDstNetCIDR).
+			WithNextHopGroup(nhgIndex),
+	)
+	if err := gribi.Await(args.ctx, t, args.c, nil, ops...); err != nil {
+		t.Fatalf("Await got error for ModifyRequest: %v", err)
+	}
+
+	res := args.c.Results(t)
+	chk.HasResult(t, res,
+		fluent.OperationResult().
+			WithOperationID(ops[0]).
+			WithOperationType(constants.Add).
+			WithNextHopOperation(nhIndex).
+			WithProgrammingResult(args.wantInstalled).
+			AsResult(),
+	)
+	chk.HasResult(t, res,
+		fluent.OperationResult().
+			WithOperationID(ops[1]).
+			WithOperationType(constants.Add).
+			WithNextHopGroupOperation(nhgIndex).
+			WithProgrammingResult(args.wantInstalled).
+			AsResult(),
+	)
+	gribi.HasIPv6Result(t, res, ops[2], ateDstNetCIDR, constants.Add, args.wantInstalled)
+
+	t.Run("Telemetry", func(t *testing.T) {
+		ipv6Path := args.dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).Afts().Ipv6Entry(ateDstNetCIDR)
+		fptest.Await(t, ipv6Path.Prefix().Watch, awaitDuration, ateDstNetCIDR)
+	})
+
+	t.Run("Traffic", func(t *testing.T) {
+		testTraffic(t, args)
+	})
+}
+
+var cases = []struct {
+	name string
+	desc string
+	fn   func(*testing.T, *testArgs)
+}{
+	{
+		name: "Modify IPv6 and NHG",
+		desc: "A single ModifyRequest with the following ordered operations is responded to with an error: (1) An AFTOperation containing an IPv6Entry referencing NextHopGroup 10. (2) An AFTOperation containing a NextHopGroup id=10.",
+		fn:   testModifyIPv6NHG,
+	},
+	{
+		name: "Modify NHG and IPv6",
+		desc: "A single ModifyRequest with the following ordered operations is installed (verified through telemetry and traffic): (1) An AFTOperation containing a NextHopGroup 10 pointing to a NextHop to ATE port-2. (2) An AFTOperation containing an IPv6Entry referencing NextHopGroup 10.",
+		fn:   testModifyNHGIPv6,
+	},
+}
+
+func TestOrderingACKIPv6(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	ctx := context.Background()
+	gribic := dut.RawAPIs().GRIBI().Default(t)
+
+	configureDUT(t, dut)
+	ate := ondatra.ATE(t, "ate")
+	top := configureATE(t, ate)
+	top.Push(t).StartProtocols(t)
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Logf("Name: %s", tc.name)
+			t.Logf("Description: %s", tc.desc)
+
+			c := fluent.NewClient()
+			conn := c.Connection().
+				WithStub(gribic).
+				WithRedundancyMode(fluent.ElectedPrimaryClient).
+				WithInitialElectionID(1 /* low */, 0 /* hi */). // ID must be > 0.
+				WithPersistence()
+			if !*deviations.GRIBIRIBAckOnly {
+				conn.WithFIBACK()
+			}
+
+			c.Start(ctx, t)
+			defer c.Stop(t)
+			c.StartSending(ctx, t)
+			if err := gribi.Await(ctx, t, c, nil); err != nil {
+				t.Fatalf("Await got error during session negotiation: %v", err)
+			}
+			defer func() {
+				_, err := c.Flush().
+					WithElectionOverride().
+					WithAllNetworkInstances().
+					Send()
+				if err != nil {
+					t.Errorf("Cannot flush: %v", err)
+				}
+			}()
+
+			args := &testArgs{ctx: ctx, c: c, ops: &gribi.OpIDs{}, dut: dut, ate: ate, top: top}
+			args.wantInstalled = fluent.InstalledInFIB
+			if *deviations.GRIBIRIBAckOnly {
+				args.wantInstalled = fluent.InstalledInRIB
+			}
+			tc.fn(t, args)
+		})
+	}
+}
@@ -20,11 +20,17 @@ import (
 	"testing"
 	"time"
 
-	"github.com/google/go-cmp/cmp"
-	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/openconfig/featureprofiles/internal/aftcheck"
 	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/confirm"
 	"github.com/openconfig/featureprofiles/internal/deviations"
 	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/gribi"
+	"github.com/openconfig/featureprofiles/internal/link"
+	"github.com/openconfig/featureprofiles/internal/presence"
+	"github.com/openconfig/featureprofiles/internal/setrequest"
+	"github.com/openconfig/featureprofiles/internal/topocache"
+	"github.com/openconfig/featureprofiles/internal/traffic"
 	"github.com/openconfig/gribigo/chk"
 	"github.com/openconfig/gribigo/constants"
 	"github.com/openconfig/gribigo/fluent"
@@ -65,7 +71,10 @@ const (
 	nhWeight = 1
 	nhgIndex = 10
 
-	awaitDuration = 2 * time.Minute
+	// aftTimeout is how long to wait for the AFT telemetry to reflect
+	// the gRIBI operations on devices that only ACK the RIB, or the
+	// withdrawal of the entries of a client.
+	aftTimeout = 2 * time.Minute
 )
 
 var (
@@ -113,21 +122,54 @@ func configInterfaceDUT(i *telemetry.Interface, a *attrs.Attributes) *telemetry.
 	return i
 }
 
-// configureDUT configures port1 and port2 on the DUT.
-func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+// configConsistencyTimeout is how long to wait for the config and state
+// of the DUT interfaces to reflect the intended config.
+const configConsistencyTimeout = time.Minute
+
+// configureDUT configures port1 and port2 on the DUT in a single
+// SetRequest, and checks that their config and state reflect the
+// intended config.
+func configureDUT(t testing.TB, dut *ondatra.DUTDevice) {
 	d := dut.Config()
 
 	p1 := dut.Port(t, "port1")
-	i1 := &telemetry.Interface{Name: ygot.String(p1.Name())}
-	d.Interface(p1.Name()).Replace(t, configInterfaceDUT(i1, &dutSrc))
-
+	i1 := configInterfaceDUT(&telemetry.Interface{Name: ygot.String(p1.Name())}, &dutSrc)
 	p2 := dut.Port(t, "port2")
-	i2 := &telemetry.Interface{Name: ygot.String(p2.Name())}
-	d.Interface(p2.Name()).Replace(t, configInterfaceDUT(i2, &dutDst))
+	i2 := configInterfaceDUT(&telemetry.Interface{Name: ygot.String(p2.Name())}, &dutDst)
+
+	// The interfaces are first replaced with one SetRequest each, as the
+	// baseline of the time a single SetRequest takes.
+	start := time.Now()
+	d.Interface(p1.Name()).Replace(t, i1)
+	d.Interface(p2.Name()).Replace(t, i2)
+	perPath := time.Since(start)
+
+	start = time.Now()
+	if err := setrequest.NewBatch().
+		Replace(p1.String(), d.Interface(p1.Name()), i1).
+		Replace(p2.String(), d.Interface(p2.Name()), i2).
+		Set(t, dut); err != nil {
+		t.Fatalf("Cannot configure DUT interfaces: %v", err)
+	}
+	t.Logf("DUT interfaces configured in %v with one SetRequest per interface, and in %v with a single SetRequest", perPath, time.Since(start))
+
+	ok1 := confirm.ConfigAndState(t, dut, d.Interface(p1.Name()), i1, configConsistencyTimeout)
+	ok2 := confirm.ConfigAndState(t, dut, d.Interface(p2.Name()), i2, configConsistencyTimeout)
+	if !ok1 || !ok2 {
+		t.Fatal("DUT interfaces do not reflect the intended config")
+	}
 }
 
-// configureATE configures port1 and port2 on the ATE.
+// ateTop is the topology of the ATE, built by the first test and shared
+// by the others, so that topocache does not push it again.
+var ateTop *ondatra.ATETopology
+
+// configureATE configures port1 and port2 on the ATE, unless an earlier
+// test already did, and returns the topology.
 func configureATE(t *testing.T, ate *ondatra.ATEDevice) *ondatra.ATETopology {
+	if ateTop != nil {
+		return ateTop
+	}
 	top := ate.Topology().New()
 
 	p1 := ate.Port(t, "port1")
@@ -143,49 +185,80 @@ func configureATE(t *testing.T, ate *ondatra.ATEDevice) *ondatra.ATETopology {
 		WithDefaultGateway(dutDst.IPv4)
 	i2.AddNetwork(ateDstNetName).IPv4().WithAddress(ateDstNetCIDR)
 
+	ateTop = top
 	return top
 }
 
-// testTraffic generates traffic flow from source network to
-// destination network via ate:port1 to ate:port2 and checks for
-// packet loss.
-func testTraffic(
-	t *testing.T,
-	ate *ondatra.ATEDevice,
-	top *ondatra.ATETopology,
-) {
+// startProtocols starts the protocols of the pushed ATE topology, unless
+// an earlier test already started them, and waits for the DUT to
+// resolve both ATE interfaces.
+func startProtocols(t *testing.T, ate *ondatra.ATEDevice, dut *ondatra.DUTDevice, top *ondatra.ATETopology) {
+	defer fptest.TimeStep(t, "start protocols")()
+	topocache.EnsureStarted(t, ate, top)
+	traffic.AwaitProtocols(t, ate, top, &traffic.Readiness{
+		DUT: dut,
+		Neighbors: map[string]*attrs.Attributes{
+			"port1": &ateSrc,
+			"port2": &ateDst,
+		},
+	})
+}
+
+// setupTestbed configures the DUT while the ATE topology is pushed,
+// since neither depends on the other, then starts the ATE protocols.
+// The topology is the same for each test, so it is only pushed and its
+// protocols started by the first.
+func setupTestbed(t *testing.T, dut *ondatra.DUTDevice, ate *ondatra.ATEDevice) *ondatra.ATETopology {
+	defer fptest.TimeStep(t, "set up testbed")()
+	top := configureATE(t, ate)
+	fptest.RunConcurrently(t,
+		fptest.Step{Name: "configure DUT", Run: func(t testing.TB) { configureDUT(t, dut) }},
+		fptest.Step{Name: "push ATE topology", Run: func(t testing.TB) { topocache.EnsurePushed(t, ate, top) }},
+	)
+	startProtocols(t, ate, dut, top)
+	return top
+}
+
+// newFlow creates a flow from source network to destination network
+// via ate:port1 to ate:port2.
+func newFlow(ate *ondatra.ATEDevice, top *ondatra.ATETopology) *ondatra.Flow {
 	i1 := top.Interfaces()[ateSrc.Name]
 	i2 := top.Interfaces()[ateDst.Name]
 	n2 := i2.Networks()[ateDstNetName]
 
 	ethHeader := ondatra.NewEthernetHeader()
 	ipv4Header := ondatra.NewIPv4Header()
-	flow := ate.Traffic().NewFlow("Flow").
+	return ate.Traffic().NewFlow("Flow").
 		WithSrcEndpoints(i1).
 		WithDstEndpoints(n2).
 		WithHeaders(ethHeader, ipv4Header)
-
-	ate.Traffic().Start(t, flow)
-	time.Sleep(15 * time.Second)
-	ate.Traffic().Stop(t)
-
-	flowPath := ate.Telemetry().Flow(flow.Name())
-	if got := flowPath.LossPct().Get(t); got > 0 {
-		t.Errorf("LossPct for flow %s got %g, want 0", flow.Name(), got)
-	}
 }
 
-// awaitTimeout calls a fluent client Await, adding a timeout to the context.
-func awaitTimeout(ctx context.Context, c *fluent.GRIBIClient, t testing.TB) error {
-	subctx, cancel := context.WithTimeout(ctx, awaitDuration)
-	defer cancel()
-	return c.Await(subctx, t)
+// testTraffic generates traffic flow from source network to
+// destination network via ate:port1 to ate:port2 and checks for
+// packet loss, and that the unicast packet counters of dut:port1 and
+// dut:port2 agree with the packets the ATE sent and received.
+func testTraffic(
+	t *testing.T,
+	ate *ondatra.ATEDevice,
+	dut *ondatra.DUTDevice,
+	top *ondatra.ATETopology,
+) {
+	defer fptest.TimeStep(t, "test traffic")()
+	before := traffic.SnapshotCounters(t, dut, "port1", "port2")
+	r := traffic.ValidateFlow(t, ate, newFlow(ate, top), &traffic.Options{Retry: true})
+	counters := traffic.StableCounters(t, dut, "port1", "port2").Diff(before)
+	t.Logf("DUT counters incremented by:\n%v", counters)
+	if err := counters.MatchesFlow(r, traffic.DefaultCounterSlack, "port1", "port2"); err != nil {
+		t.Errorf("DUT counters do not match the ATE flow statistics: %v", err)
+	}
 }
 
 // testArgs holds the objects needed by a test case.
 type testArgs struct {
 	ctx           context.Context
 	c             *fluent.GRIBIClient
+	ops           *gribi.OpIDs
 	dut           *ondatra.DUTDevice
 	ate           *ondatra.ATEDevice
 	top           *ondatra.ATETopology
@@ -197,6 +270,7 @@ type testCaseFunc func(t *testing.T, args *testArgs)
 
 // testModifyNHG configures a NextHopGroup referencing a NextHop.
 func testModifyNHG(t *testing.T, args *testArgs) {
+	ops := args.ops.Next(2)
 	args.c.Modify().AddEntry(t,
 		fluent.NextHopEntry().
 			WithNetworkInstance(*deviations.DefaultNetworkInstance).
@@ -207,14 +281,14 @@ func testModifyNHG(t *testing.T, args *testArgs) {
 			WithID(nhgIndex).
 			AddNextHop(nhIndex, nhWeight),
 	)
-	if err := awaitTimeout(args.ctx, args.c, t); err != nil {
+	if err := gribi.Await(args.ctx, t, args.c, nil, ops...); err != nil {
 		t.Errorf("Await got error for ModifyRequest: %v", err)
 	}
 
 	res := args.c.Results(t)
 	chk.HasResult(t, res,
 		fluent.OperationResult().
-			WithOperationID(1).
+			WithOperationID(ops[0]).
 			WithOperationType(constants.Add).
 			WithNextHopOperation(nhIndex).
 			WithProgrammingResult(args.wantInstalled).
@@ -222,7 +296,7 @@ func testModifyNHG(t *testing.T, args *testArgs) {
 	)
 	chk.HasResult(t, res,
 		fluent.OperationResult().
-			WithOperationID(2).
+			WithOperationID(ops[1]).
 			WithOperationType(constants.Add).
 			WithNextHopGroupOperation(nhgIndex).
 			WithProgrammingResult(args.wantInstalled).
@@ -230,18 +304,14 @@ func testModifyNHG(t *testing.T, args *testArgs) {
 	)
 
 	t.Run("Telemetry", func(t *testing.T) {
-		got := aftNextHopWeights(t, args.dut, nhgIndex, *deviations.DefaultNetworkInstance)
-		want := []uint64{nhWeight}
-		ok := cmp.Equal(want, got, cmpopts.SortSlices(func(a, b uint64) bool { return a < b }))
-		if !ok {
-			t.Errorf("next-hop-group/next-hop/state/weight got %v, want %v", got, want)
-		}
+		aftcheck.NHGWeights(t, args.dut, *deviations.DefaultNetworkInstance, nhgIndex, []uint64{nhWeight}, aftOpts())
 	})
 }
 
 // testModifyIPv4NHG configures a ModifyRequest with a NextHop and an IPv4Entry before a
 // NextHopGroup which is invalid due to the forward reference.
 func testModifyIPv4NHG(t *testing.T, args *testArgs) {
+	ops := args.ops.Next(3)
 	args.c.Modify().AddEntry(t,
 		fluent.NextHopEntry().
 			WithNetworkInstance(*deviations.DefaultNetworkInstance).
@@ -256,14 +326,14 @@ func testModifyIPv4NHG(t *testing.T, args *testArgs) {
 			WithID(nhgIndex).
 			AddNextHop(nhIndex, nhWeight),
 	)
-	if err := awaitTimeout(args.ctx, args.c, t); err != nil {
+	if err := gribi.Await(args.ctx, t, args.c, nil, ops...); err != nil {
 		t.Fatalf("Await got error for ModifyRequest: %v", err)
 	}
 
 	res := args.c.Results(t)
 	chk.HasResult(t, res,
 		fluent.OperationResult().
-			WithOperationID(2).
+			WithOperationID(ops[1]).
 			WithOperationType(constants.Add).
 			WithIPv4Operation(ateDstNetCIDR).
 			WithProgrammingResult(fluent.ProgrammingFailed).
@@ -273,6 +343,7 @@ func testModifyIPv4NHG(t *testing.T, args *testArgs) {
 
 // testModifyNHGIPv4 configures a ModifyRequest with a NextHopGroup and IPv4Entry.
 func testModifyNHGIPv4(t *testing.T, args *testArgs) {
+	ops := args.ops.Next(3)
 	args.c.Modify().AddEntry(t,
 		fluent.NextHopEntry().
 			WithNetworkInstance(*deviations.DefaultNetworkInstance).
@@ -287,14 +358,14 @@ func testModifyNHGIPv4(t *testing.T, args *testArgs) {
 			WithPrefix(ateDstNetCIDR).
 			WithNextHopGroup(nhgIndex),
 	)
-	if err := awaitTimeout(args.ctx, args.c, t); err != nil {
+	if err := gribi.Await(args.ctx, t, args.c, nil, ops...); err != nil {
 		t.Fatalf("Await got error for ModifyRequest: %v", err)
 	}
 
 	res := args.c.Results(t)
 	chk.HasResult(t, res,
 		fluent.OperationResult().
-			WithOperationID(1).
+			WithOperationID(ops[0]).
 			WithOperationType(constants.Add).
 			WithNextHopOperation(nhIndex).
 			WithProgrammingResult(args.wantInstalled).
@@ -302,7 +373,7 @@ func testModifyNHGIPv4(t *testing.T, args *testArgs) {
 	)
 	chk.HasResult(t, res,
 		fluent.OperationResult().
-			WithOperationID(2).
+			WithOperationID(ops[1]).
 			WithOperationType(constants.Add).
 			WithNextHopGroupOperation(nhgIndex).
 			WithProgrammingResult(args.wantInstalled).
@@ -310,7 +381,7 @@ func testModifyNHGIPv4(t *testing.T, args *testArgs) {
 	)
 	chk.HasResult(t, res,
 		fluent.OperationResult().
-			WithOperationID(3).
+			WithOperationID(ops[2]).
 			WithOperationType(constants.Add).
 			WithIPv4Operation(ateDstNetCIDR).
 			WithProgrammingResult(args.wantInstalled).
@@ -318,69 +389,87 @@ func testModifyNHGIPv4(t *testing.T, args *testArgs) {
 	)
 
 	t.Run("Telemetry", func(t *testing.T) {
-		got := aftNextHopWeights(t, args.dut, nhgIndex, *deviations.DefaultNetworkInstance)
-		want := []uint64{nhWeight}
-		ok := cmp.Equal(want, got, cmpopts.SortSlices(func(a, b uint64) bool { return a < b }))
-		if !ok {
-			t.Errorf("next-hop-group/next-hop/state/weight got %v, want %v", got, want)
-		}
+		aftcheck.NHGWeights(t, args.dut, *deviations.DefaultNetworkInstance, nhgIndex, []uint64{nhWeight}, aftOpts())
+		aftcheck.IPv4Entry(t, args.dut, *deviations.DefaultNetworkInstance, ateDstNetCIDR, nhgIndex, aftOpts())
+		checkProgrammedNHG(t, args.dut)
+	})
 
-		ipv4Path := args.dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).Afts().Ipv4Entry(ateDstNetCIDR)
-		if got, want := ipv4Path.Prefix().Get(t), ateDstNetCIDR; got != want {
-			t.Errorf("ipv4-entry/state/prefix got %s, want %s", got, want)
-		}
+	t.Run("Conformance", func(t *testing.T) {
+		checkAFTLeaves(t, args.dut)
 	})
 
 	t.Run("Traffic", func(t *testing.T) {
-		testTraffic(t, args.ate, args.top)
+		testTraffic(t, args.ate, args.dut, args.top)
 	})
 }
 
-// aftNextHopWeights queries AFT telemetry using Get() and returns
-// the weights. If not-found, an empty list is returned.
-func aftNextHopWeights(t *testing.T, dut *ondatra.DUTDevice, nhg uint64, networkInstance string) []uint64 {
-	aft := dut.Telemetry().NetworkInstance(networkInstance).Afts().Get(t)
-	var nhgD *telemetry.NetworkInstance_Afts_NextHopGroup
-	for _, nhgData := range aft.NextHopGroup {
-		if nhgData.GetProgrammedId() == nhg {
-			nhgD = nhgData
-			break
-		}
+// checkAFTLeaves checks which leaves of the AFT subtree of the installed
+// IPv4Entry the DUT reports, failing only if required ones are missing.
+func checkAFTLeaves(t *testing.T, dut *ondatra.DUTDevice) {
+	defer fptest.TimeStep(t, "check AFT leaves")()
+	afts := dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).Afts()
+	q := afts.Ipv4Entry(ateDstNetCIDR).NextHopGroup().Lookup(t)
+	if !q.IsPresent() {
+		t.Fatalf("ipv4-entry %s has no next-hop-group", ateDstNetCIDR)
 	}
-	if nhgD == nil {
-		return []uint64{}
+	var indexes []uint64
+	for _, nh := range aftcheck.EntryNextHops(t, dut, *deviations.DefaultNetworkInstance, ateDstNetCIDR) {
+		indexes = append(indexes, nh.Index)
 	}
+	presence.Check(t, presence.AFTLeaves(afts, ateDstNetCIDR, q.Val(t), indexes))
+}
 
-	got := []uint64{}
-	for _, nhD := range nhgD.NextHop {
-		got = append(got, nhD.GetWeight())
-	}
+// checkProgrammedNHG checks that the AFT reports exactly one
+// next-hop-group for nhgIndex, with the next hop to ATE port-2, and
+// that the installed IPv4Entry references its key.
+func checkProgrammedNHG(t *testing.T, dut *ondatra.DUTDevice) {
+	aftcheck.ProgrammedNHGs(t, dut, []*aftcheck.ProgrammedNHG{{
+		NetworkInstance:  *deviations.DefaultNetworkInstance,
+		ID:               nhgIndex,
+		Prefixes:         []string{ateDstNetCIDR},
+		NextHopAddresses: []string{ateDst.IPv4},
+	}}, aftOpts())
+}
 
-	return got
+// aftOpts returns the options of the AFT telemetry checks, which wait
+// longer for devices that only ACK the RIB.
+func aftOpts() *aftcheck.Options {
+	if *deviations.GRIBIRIBAckOnly {
+		return &aftcheck.Options{Timeout: aftTimeout}
+	}
+	return nil
 }
 
 // testModifyIPv4AddDelAdd configures a ModifyRequest with AFT operations to add, delete,
 // and add IPv4Entry.
 func testModifyIPv4AddDelAdd(t *testing.T, args *testArgs) {
-	testModifyNHG(t, args) // Uses operation IDs 1 and 2.
+	modifyIPv4AddDelAdd(t, args)
+}
+
+// modifyIPv4AddDelAdd performs the operations of
+// testModifyIPv4AddDelAdd, and returns the IDs of the add, delete and
+// add operations of the IPv4Entry.
+func modifyIPv4AddDelAdd(t *testing.T, args *testArgs) []uint64 {
+	testModifyNHG(t, args)
 
 	ent := fluent.IPv4Entry().
 		WithNetworkInstance(*deviations.DefaultNetworkInstance).
 		WithPrefix(ateDstNetCIDR).
 		WithNextHopGroup(nhgIndex)
 
+	ops := args.ops.Next(3)
 	args.c.Modify().
 		AddEntry(t, ent).
 		DeleteEntry(t, ent).
 		AddEntry(t, ent)
-	if err := awaitTimeout(args.ctx, args.c, t); err != nil {
+	if err := gribi.Await(args.ctx, t, args.c, nil, ops...); err != nil {
 		t.Fatalf("Await got error for ModifyRequest: %v", err)
 	}
 
 	res := args.c.Results(t)
 	chk.HasResult(t, res,
 		fluent.OperationResult().
-			WithOperationID(3).
+			WithOperationID(ops[0]).
 			WithOperationType(constants.Add).
 			WithIPv4Operation(ateDstNetCIDR).
 			WithProgrammingResult(args.wantInstalled).
@@ -388,7 +477,7 @@ func testModifyIPv4AddDelAdd(t *testing.T, args *testArgs) {
 	)
 	chk.HasResult(t, res,
 		fluent.OperationResult().
-			WithOperationID(4).
+			WithOperationID(ops[1]).
 			WithOperationType(constants.Delete).
 			WithIPv4Operation(ateDstNetCIDR).
 			WithProgrammingResult(args.wantInstalled).
@@ -396,7 +485,7 @@ func testModifyIPv4AddDelAdd(t *testing.T, args *testArgs) {
 	)
 	chk.HasResult(t, res,
 		fluent.OperationResult().
-			WithOperationID(5).
+			WithOperationID(ops[2]).
 			WithOperationType(constants.Add).
 			WithIPv4Operation(ateDstNetCIDR).
 			WithProgrammingResult(args.wantInstalled).
@@ -404,15 +493,45 @@ func testModifyIPv4AddDelAdd(t *testing.T, args *testArgs) {
 	)
 
 	t.Run("Telemetry", func(t *testing.T) {
-		ipv4Path := args.dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).Afts().Ipv4Entry(ateDstNetCIDR)
-		if got, want := ipv4Path.Prefix().Get(t), ateDstNetCIDR; got != want {
-			t.Errorf("ipv4-entry/state/prefix got %s, want %s", got, want)
-		}
+		aftcheck.IPv4Entry(t, args.dut, *deviations.DefaultNetworkInstance, ateDstNetCIDR, nhgIndex, aftOpts())
+		checkProgrammedNHG(t, args.dut)
 	})
 
 	t.Run("Traffic", func(t *testing.T) {
-		testTraffic(t, args.ate, args.top)
+		testTraffic(t, args.ate, args.dut, args.top)
 	})
+	return ops
+}
+
+// ackTime returns when the client received the ACK of the operation.
+func ackTime(t *testing.T, c *fluent.GRIBIClient, opID uint64) time.Time {
+	for _, r := range c.Results(t) {
+		if r.OperationID == opID {
+			return time.Unix(0, r.Timestamp)
+		}
+	}
+	t.Fatalf("Got no result for operation %d", opID)
+	return time.Time{}
+}
+
+// testAFTStream subscribes ON_CHANGE to the AFT before performing the
+// operations of testModifyIPv4AddDelAdd, and verifies that the DUT
+// streams the creation, deletion and re-creation of the IPv4Entry in
+// order after each ACK.
+func testAFTStream(t *testing.T, args *testArgs) {
+	s := aftcheck.Subscribe(t, args.dut, *deviations.DefaultNetworkInstance)
+	defer s.Close()
+
+	ops := modifyIPv4AddDelAdd(t, args)
+
+	s.Expect(t, ateDstNetCIDR, []*aftcheck.WantEvent{
+		{Kind: aftcheck.Created, NHG: nhgIndex, ACK: ackTime(t, args.c, ops[0])},
+		{Kind: aftcheck.Deleted, ACK: ackTime(t, args.c, ops[1])},
+		{Kind: aftcheck.Created, NHG: nhgIndex, ACK: ackTime(t, args.c, ops[2])},
+	}, aftOpts())
+	if err := s.Err(); err != nil {
+		t.Errorf("AFT subscription failed: %v", err)
+	}
 }
 
 var cases = []struct {
@@ -440,6 +559,11 @@ var cases = []struct {
 		desc: "A single ModifyRequest with the following ordered operations is installed (verified through telemetry and traffic): (1) An AFT entry adding IPv4Entry 203.0.113.0/24. (2) An AFT entry deleting IPv4Entry 203.0.113.0/24. (3) An AFT entry adding IPv4Entry 203.0.113.0/24.",
 		fn:   testModifyIPv4AddDelAdd,
 	},
+	{
+		name: "AFT Stream IPv4 Add Del Add",
+		desc: "An ON_CHANGE subscription to the AFT opened before the operations of Modify IPv4 Add Del Add receives notifications of the creation, deletion and re-creation of IPv4Entry 203.0.113.0/24, in order, after the ACK of each operation.",
+		fn:   testAFTStream,
+	},
 }
 
 func TestOrderingACK(t *testing.T) {
@@ -449,13 +573,9 @@ func TestOrderingACK(t *testing.T) {
 	ctx := context.Background()
 	gribic := dut.RawAPIs().GRIBI().Default(t)
 
-	// Configure the DUT
-	configureDUT(t, dut)
-
-	// Configure the ATE
 	ate := ondatra.ATE(t, "ate")
-	top := configureATE(t, ate)
-	top.Push(t).StartProtocols(t)
+	top := setupTestbed(t, dut, ate)
+	link.WatchFlaps(t, dut, "port1", "port2")
 
 	const (
 		usePreserve = "PRESERVE"
@@ -468,9 +588,11 @@ func TestOrderingACK(t *testing.T) {
 			if *deviations.GRIBIPreserveOnly && persist == useDelete {
 				t.Skip("Skipping due to --deviation_gribi_preserve_only")
 			}
+			defer fptest.TimeStep(t, "persistence "+persist)()
 
 			for _, tc := range cases {
 				t.Run(tc.name, func(t *testing.T) {
+					defer fptest.TimeStep(t, tc.name)()
 					t.Logf("Name: %s", tc.name)
 					t.Logf("Description: %s", tc.desc)
 
@@ -491,15 +613,18 @@ func TestOrderingACK(t *testing.T) {
 						conn.WithFIBACK()
 					}
 
+					endStart := fptest.TimeStep(t, "start gRIBI client")
 					c.Start(ctx, t)
 					defer c.Stop(t)
 					c.StartSending(ctx, t)
-					if err := awaitTimeout(ctx, c, t); err != nil {
+					if err := gribi.Await(ctx, t, c, nil); err != nil {
 						t.Fatalf("Await got error during session negotiation: %v", err)
 					}
+					endStart()
 
 					if persist == usePreserve {
 						defer func() {
+							defer fptest.TimeStep(t, "flush gRIBI")()
 							_, err := c.Flush().
 								WithElectionOverride().
 								WithAllNetworkInstances().
@@ -510,7 +635,7 @@ func TestOrderingACK(t *testing.T) {
 						}()
 					}
 
-					args := &testArgs{ctx: ctx, c: c, dut: dut, ate: ate, top: top}
+					args := &testArgs{ctx: ctx, c: c, ops: &gribi.OpIDs{}, dut: dut, ate: ate, top: top}
 					args.wantInstalled = fluent.InstalledInFIB
 					if *deviations.GRIBIRIBAckOnly {
 						args.wantInstalled = fluent.InstalledInRIB
@@ -521,3 +646,94 @@ func TestOrderingACK(t *testing.T) {
 		})
 	}
 }
+
+// TestDeletePersistence validates that entries installed by a client
+// using persistence mode DELETE are removed once its session ends.
+func TestDeletePersistence(t *testing.T) {
+	if *deviations.GRIBIPreserveOnly {
+		t.Skip("Skipping due to --deviation_gribi_preserve_only")
+	}
+
+	dut := ondatra.DUT(t, "dut")
+	ctx := context.Background()
+	gribic := dut.RawAPIs().GRIBI().Default(t)
+
+	ate := ondatra.ATE(t, "ate")
+	top := setupTestbed(t, dut, ate)
+
+	c := fluent.NewClient()
+	conn := c.Connection().
+		WithStub(gribic).
+		WithRedundancyMode(fluent.ElectedPrimaryClient).
+		WithInitialElectionID(1 /* low */, 0 /* hi */) // ID must be > 0.
+	if !*deviations.GRIBIRIBAckOnly {
+		conn.WithFIBACK()
+	}
+
+	endStart := fptest.TimeStep(t, "start gRIBI client")
+	c.Start(ctx, t)
+	stopped := false
+	defer func() {
+		if !stopped {
+			c.Stop(t)
+		}
+	}()
+	c.StartSending(ctx, t)
+	if err := gribi.Await(ctx, t, c, nil); err != nil {
+		t.Fatalf("Await got error during session negotiation: %v", err)
+	}
+	endStart()
+
+	args := &testArgs{ctx: ctx, c: c, ops: &gribi.OpIDs{}, dut: dut, ate: ate, top: top}
+	args.wantInstalled = fluent.InstalledInFIB
+	if *deviations.GRIBIRIBAckOnly {
+		args.wantInstalled = fluent.InstalledInRIB
+	}
+	t.Log("Install an IPv4Entry with persistence mode DELETE and verify forwarding.")
+	endInstall := fptest.TimeStep(t, "install IPv4Entry")
+	testModifyNHGIPv4(t, args)
+	endInstall()
+
+	t.Log("Stop the client and wait for the IPv4Entry to be withdrawn.")
+	endWithdraw := fptest.TimeStep(t, "withdraw IPv4Entry")
+	c.Stop(t)
+	stopped = true
+
+	ok := aftcheck.NoIPv4Entry(t, dut, *deviations.DefaultNetworkInstance, ateDstNetCIDR, &aftcheck.Options{Timeout: aftTimeout})
+	endWithdraw()
+	if !ok {
+		t.FailNow()
+	}
+
+	t.Run("Traffic", func(t *testing.T) {
+		traffic.ValidateFlow(t, ate, newFlow(ate, top), &traffic.Options{WantLoss: true})
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		defer fptest.TimeStep(t, "gRIBI Get")()
+		fc := fluent.NewClient()
+		fc.Connection().
+			WithStub(gribic).
+			WithRedundancyMode(fluent.ElectedPrimaryClient).
+			WithInitialElectionID(2 /* low */, 0 /* hi */)
+		fc.Start(ctx, t)
+		defer fc.Stop(t)
+		fc.StartSending(ctx, t)
+		if err := gribi.Await(ctx, t, fc, nil); err != nil {
+			t.Fatalf("Await got error during session negotiation: %v", err)
+		}
+
+		gr, err := fc.Get().
+			WithNetworkInstance(*deviations.DefaultNetworkInstance).
+			WithAFT(fluent.IPv4).
+			Send()
+		if err != nil {
+			t.Fatalf("gRIBI Get got unexpected error: %v", err)
+		}
+		for _, e := range gr.GetEntry() {
+			if e.GetIpv4().GetPrefix() == ateDstNetCIDR {
+				t.Errorf("gRIBI Get got IPv4Entry %s, want not present", ateDstNetCIDR)
+			}
+		}
+	})
+}
@@ -0,0 +1,233 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package process_restart_test
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/gribi"
+	"github.com/openconfig/featureprofiles/internal/process"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/gribigo/fluent"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+)
+
+var (
+	maxInterruption = flag.Duration("max_interruption", 0,
+		"Maximum dataplane interruption while the gRIBI process restarts.")
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1 and
+// dut:port2 -> ate:port2.
+//
+//   - ate:port1 -> dut:port1 subnet 192.0.2.0/30
+//   - ate:port2 -> dut:port2 subnet 192.0.2.4/30
+//
+// The destination network 203.0.113.0/24 is routed to ate:port2 via
+// gRIBI.
+const (
+	ipv4PrefixLen = 30
+	ateDstNetCIDR = "203.0.113.0/24"
+	nhIndex       = 1
+	nhgIndex      = 42
+
+	frameRate = 1000 // frames per second
+	// restartTimeout is how long the gRIBI server may take to become
+	// reachable again after the process is restarted.
+	restartTimeout = 5 * time.Minute
+)
+
+var (
+	dutPort1 = attrs.Attributes{
+		Desc:    "dutPort1",
+		IPv4:    "192.0.2.1",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort1 = attrs.Attributes{
+		Name:    "atePort1",
+		IPv4:    "192.0.2.2",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	dutPort2 = attrs.Attributes{
+		Desc:    "dutPort2",
+		IPv4:    "192.0.2.5",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort2 = attrs.Attributes{
+		Name:    "atePort2",
+		IPv4:    "192.0.2.6",
+		IPv4Len: ipv4PrefixLen,
+	}
+)
+
+// configureDUT configures port1 and port2 on the DUT.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	d := dut.Config()
+
+	p1 := dut.Port(t, "port1")
+	d.Interface(p1.Name()).Replace(t, dutPort1.NewInterface(p1.Name()))
+
+	p2 := dut.Port(t, "port2")
+	d.Interface(p2.Name()).Replace(t, dutPort2.NewInterface(p2.Name()))
+}
+
+// configureATE configures port1 and port2 on the ATE.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice) *ondatra.ATETopology {
+	top := ate.Topology().New()
+	atePort1.AddToATE(top, ate.Port(t, "port1"), &dutPort1)
+	atePort2.AddToATE(top, ate.Port(t, "port2"), &dutPort2)
+	return top
+}
+
+// newFlow creates a flow from ate:port1 to the destination network.
+func newFlow(ate *ondatra.ATEDevice, top *ondatra.ATETopology) *ondatra.Flow {
+	ipv4Header := ondatra.NewIPv4Header()
+	ipv4Header.DstAddressRange().
+		WithMin("203.0.113.1").
+		WithMax("203.0.113.254").
+		WithCount(254)
+
+	return ate.Traffic().NewFlow("Flow").
+		WithSrcEndpoints(top.Interfaces()[atePort1.Name]).
+		WithDstEndpoints(top.Interfaces()[atePort2.Name]).
+		WithHeaders(ondatra.NewEthernetHeader(), ipv4Header).
+		WithFrameRateFPS(frameRate)
+}
+
+// awaitAFT waits for the destination network to be present in the AFT.
+func awaitAFT(t *testing.T, dut *ondatra.DUTDevice) {
+	ipv4Path := dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).Afts().Ipv4Entry(ateDstNetCIDR)
+	fptest.Await(t, ipv4Path.Prefix().Watch, time.Minute, ateDstNetCIDR)
+}
+
+// awaitForwarding waits for the flow to be received by the ATE, so the
+// process is restarted while the DUT forwards it.
+func awaitForwarding(t *testing.T, ate *ondatra.ATEDevice, flow *ondatra.Flow) {
+	inPkts := ate.Telemetry().Flow(flow.Name()).Counters().InPkts()
+	fptest.AwaitFunc[uint64](t, inPkts.Watch, time.Minute, "received packets", func(q *telemetry.QualifiedUint64) bool {
+		return q.IsPresent() && q.Val(t) > 0
+	})
+}
+
+func TestProcessRestart(t *testing.T) {
+	if *deviations.GRIBIProcessRestartUnsupported {
+		t.Skip("Skipping due to --deviation_gribi_process_restart_unsupported")
+	}
+
+	dut := ondatra.DUT(t, "dut")
+	ate := ondatra.ATE(t, "ate")
+	name := process.Name(t, dut, process.GRIBI)
+
+	configureDUT(t, dut)
+	top := configureATE(t, ate)
+	top.Push(t).StartProtocols(t)
+
+	wantInstalled := fluent.InstalledInFIB
+	if *deviations.GRIBIRIBAckOnly {
+		wantInstalled = fluent.InstalledInRIB
+	}
+	c := &gribi.Client{
+		DUT:                  dut,
+		FibACK:               !*deviations.GRIBIRIBAckOnly,
+		Persistence:          true,
+		InitialElectionIDLow: 10,
+	}
+	defer c.Close(t)
+	if err := c.Start(t); err != nil {
+		t.Fatalf("gRIBI connection could not be established: %v", err)
+	}
+	c.BecomeLeader(t)
+
+	t.Logf("Program %s to ATE port-2 via gRIBI.", ateDstNetCIDR)
+	c.AddNH(t, nhIndex, atePort2.IPv4, *deviations.DefaultNetworkInstance, wantInstalled)
+	c.AddNHG(t, nhgIndex, map[uint64]uint64{nhIndex: 1}, *deviations.DefaultNetworkInstance, wantInstalled)
+	c.AddIPv4(t, ateDstNetCIDR, nhgIndex, *deviations.DefaultNetworkInstance, "", wantInstalled)
+	awaitAFT(t, dut)
+
+	flow := newFlow(ate, top)
+	ate.Traffic().Start(t, flow)
+	awaitForwarding(t, ate, flow)
+
+	pid := process.Restart(t, dut, name)
+	start := time.Now()
+	if err := c.Reconnect(t, restartTimeout); err != nil {
+		ate.Traffic().Stop(t)
+		t.Fatalf("gRIBI connection could not be re-established: %v", err)
+	}
+	t.Logf("gRIBI server reachable %v after restarting process %s", time.Since(start), name)
+	defer c.Flush(t)
+
+	newPID := process.AwaitRestarted(t, dut, name, pid, restartTimeout)
+	t.Logf("Process %s restarted with PID %d %v after restarting it", name, newPID, time.Since(start))
+	awaitAFT(t, dut)
+	ate.Traffic().Stop(t)
+
+	t.Run("Interruption", func(t *testing.T) {
+		counters := ate.Telemetry().Flow(flow.Name()).Counters()
+		outPkts, inPkts := counters.OutPkts().Get(t), counters.InPkts().Get(t)
+		if outPkts == 0 {
+			t.Fatalf("Flow %s sent no packets", flow.Name())
+		}
+		var lost uint64
+		if outPkts > inPkts {
+			lost = outPkts - inPkts
+		}
+		interruption := time.Duration(lost) * time.Second / frameRate
+		t.Logf("Process %s restarted with %d packets lost, i.e. %v interruption", name, lost, interruption)
+		if interruption > *maxInterruption {
+			t.Errorf("Interruption during process restart got %v, want at most %v", interruption, *maxInterruption)
+		}
+	})
+
+	t.Run("AFT", func(t *testing.T) {
+		awaitAFT(t, dut)
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		gr, err := c.Fluent(t).Get().
+			WithNetworkInstance(*deviations.DefaultNetworkInstance).
+			WithAFT(fluent.IPv4).
+			Send()
+		if err != nil {
+			t.Fatalf("gRIBI Get got unexpected error: %v", err)
+		}
+		for _, e := range gr.GetEntry() {
+			if e.GetIpv4().GetPrefix() == ateDstNetCIDR {
+				return
+			}
+		}
+		t.Errorf("gRIBI Get did not return IPv4Entry %s", ateDstNetCIDR)
+	})
+
+	t.Run("Traffic", func(t *testing.T) {
+		traffic.ValidateFlow(t, ate, newFlow(ate, top), nil)
+	})
+}
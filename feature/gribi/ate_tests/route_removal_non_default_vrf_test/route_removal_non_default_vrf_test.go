@@ -23,6 +23,7 @@ import (
 	"github.com/openconfig/featureprofiles/internal/deviations"
 	"github.com/openconfig/featureprofiles/internal/fptest"
 	"github.com/openconfig/featureprofiles/internal/gribi"
+	"github.com/openconfig/featureprofiles/internal/traffic"
 	"github.com/openconfig/gribigo/fluent"
 	"github.com/openconfig/ondatra"
 	"github.com/openconfig/ondatra/telemetry"
@@ -192,7 +193,7 @@ func flushNonDefaultVrfPrimary(ctx context.Context, t *testing.T, dut *ondatra.D
 	}
 
 	t.Log("After flush, left entry should be 0, and packets can no longer be forwarded")
-	flowPath = testTraffic(t, ate, ateTop, srcEndPoint, dstEndPoint)
+	flowPath = testNoTraffic(t, ate, ateTop, srcEndPoint, dstEndPoint)
 	if got := flowPath.LossPct().Get(t); got == 0 {
 		t.Error("Traffic can still be forwarded between ATE port-1 and ATE port-2")
 	} else {
@@ -237,7 +238,7 @@ func flushNonDefaultVrfFailover(ctx context.Context, t *testing.T, dut *ondatra.
 	}
 
 	t.Log("After flush, left entry should be 0, and packets can no longer be forwarded")
-	flowPath := testTraffic(t, ate, ateTop, srcEndPoint, dstEndPoint)
+	flowPath := testNoTraffic(t, ate, ateTop, srcEndPoint, dstEndPoint)
 	if got := flowPath.LossPct().Get(t); got == 0 {
 		t.Error("Traffic can still be forwarded between ATE port-1 and ATE port-2")
 	} else {
@@ -376,6 +377,16 @@ func testTraffic(t *testing.T, ate *ondatra.ATEDevice, top *ondatra.ATETopology,
 	return flowPath
 }
 
+// testNoTraffic generates traffic as testTraffic does, and checks that
+// ATE port-2 does not receive it.
+func testNoTraffic(t *testing.T, ate *ondatra.ATEDevice, top *ondatra.ATETopology, srcEndPoint, dstEndPoint *ondatra.Interface) *ateflow.FlowPath {
+	var flowPath *ateflow.FlowPath
+	traffic.CheckNoTraffic(t, ate, []string{"port2"}, 0, func() {
+		flowPath = testTraffic(t, ate, top, srcEndPoint, dstEndPoint)
+	})
+	return flowPath
+}
+
 // verifyEntry checks if the entry is active through AFT Telemetry.
 func verifyEntry(t *testing.T, dut *ondatra.DUTDevice, networkInstanceName string, ateDstNetCIDR string) bool {
 	ipv4Entry := dut.Telemetry().NetworkInstance(networkInstanceName).Afts().Ipv4Entry(ateDstNetCIDR)
@@ -398,11 +409,7 @@ func injectIPEntry(ctx context.Context, t *testing.T, dut *ondatra.DUTDevice, cl
 
 	// After adding the entry, verify the entry is active through AFT Telemetry.
 	ipv4Path := dut.Telemetry().NetworkInstance(networkInstanceName).Afts().Ipv4Entry(ateDstNetCIDR)
-	if got, ok := ipv4Path.Prefix().Watch(t, time.Minute, func(val *telemetry.QualifiedString) bool {
-		return val.IsPresent() && val.Val(t) == ateDstNetCIDR
-	}).Await(t); !ok {
-		t.Errorf("ipv4-entry/state/prefix got %s, want %s", got.Val(t), ateDstNetCIDR)
-	}
+	fptest.Await(t, ipv4Path.Prefix().Watch, time.Minute, ateDstNetCIDR)
 }
 
 // validateNotPrimaryError validates the canonical and exact error details for flush operation of non-primary client.
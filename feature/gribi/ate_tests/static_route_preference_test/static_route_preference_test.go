@@ -0,0 +1,212 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package static_route_preference_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/gribi"
+	"github.com/openconfig/featureprofiles/internal/static"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/gribigo/fluent"
+	"github.com/openconfig/ondatra"
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1,
+// dut:port2 -> ate:port2 and dut:port3 -> ate:port3.
+//
+//   - ate:port1 -> dut:port1 subnet 192.0.2.0/30
+//   - ate:port2 -> dut:port2 subnet 192.0.2.4/30
+//   - ate:port3 -> dut:port3 subnet 192.0.2.8/30
+//
+// The destination network 203.0.113.0/24 is routed to ate:port2 via
+// gRIBI and to ate:port3 via a static route.
+const (
+	ipv4PrefixLen = 30
+	ateDstNetCIDR = "203.0.113.0/24"
+	nhIndex       = 1
+	nhgIndex      = 42
+
+	// minRatio is the minimum fraction of the transmitted packets that
+	// should be received on the expected port.
+	minRatio = 0.99
+)
+
+var (
+	dutPort1 = attrs.Attributes{
+		Desc:    "dutPort1",
+		IPv4:    "192.0.2.1",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort1 = attrs.Attributes{
+		Name:    "atePort1",
+		IPv4:    "192.0.2.2",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	dutPort2 = attrs.Attributes{
+		Desc:    "dutPort2",
+		IPv4:    "192.0.2.5",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort2 = attrs.Attributes{
+		Name:    "atePort2",
+		IPv4:    "192.0.2.6",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	dutPort3 = attrs.Attributes{
+		Desc:    "dutPort3",
+		IPv4:    "192.0.2.9",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort3 = attrs.Attributes{
+		Name:    "atePort3",
+		IPv4:    "192.0.2.10",
+		IPv4Len: ipv4PrefixLen,
+	}
+)
+
+// configureDUT configures port1, port2 and port3 on the DUT.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	d := dut.Config()
+
+	p1 := dut.Port(t, "port1")
+	d.Interface(p1.Name()).Replace(t, dutPort1.NewInterface(p1.Name()))
+
+	p2 := dut.Port(t, "port2")
+	d.Interface(p2.Name()).Replace(t, dutPort2.NewInterface(p2.Name()))
+
+	p3 := dut.Port(t, "port3")
+	d.Interface(p3.Name()).Replace(t, dutPort3.NewInterface(p3.Name()))
+}
+
+// configureATE configures port1, port2 and port3 on the ATE.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice) *ondatra.ATETopology {
+	top := ate.Topology().New()
+	atePort1.AddToATE(top, ate.Port(t, "port1"), &dutPort1)
+	atePort2.AddToATE(top, ate.Port(t, "port2"), &dutPort2)
+	atePort3.AddToATE(top, ate.Port(t, "port3"), &dutPort3)
+	return top
+}
+
+// testTraffic sends traffic from ate:port1 to the destination network,
+// and checks that wantPort receives it while otherPort does not.
+func testTraffic(t *testing.T, ate *ondatra.ATEDevice, top *ondatra.ATETopology, wantPort, otherPort *ondatra.Port) {
+	inPkts := func() (uint64, uint64) {
+		return ate.Telemetry().Interface(wantPort.Name()).Counters().InPkts().Get(t),
+			ate.Telemetry().Interface(otherPort.Name()).Counters().InPkts().Get(t)
+	}
+
+	ipv4Header := ondatra.NewIPv4Header()
+	ipv4Header.DstAddressRange().
+		WithMin("203.0.113.1").
+		WithMax("203.0.113.254").
+		WithCount(254)
+
+	flow := ate.Traffic().NewFlow("Flow").
+		WithSrcEndpoints(top.Interfaces()[atePort1.Name]).
+		WithDstEndpoints(top.Interfaces()[atePort2.Name], top.Interfaces()[atePort3.Name]).
+		WithHeaders(ondatra.NewEthernetHeader(), ipv4Header)
+
+	wantBefore, otherBefore := inPkts()
+	outPkts := traffic.RunFlow(t, ate, flow, nil).OutPkts
+	wantAfter, otherAfter := inPkts()
+
+	if outPkts == 0 {
+		t.Fatalf("Flow %s sent no packets", flow.Name())
+	}
+	if got := wantAfter - wantBefore; float64(got) < minRatio*float64(outPkts) {
+		t.Errorf("Port %s received %d packets, want at least %g of %d sent", wantPort.ID(), got, minRatio, outPkts)
+	}
+	if got := otherAfter - otherBefore; float64(got) > (1-minRatio)*float64(outPkts) {
+		t.Errorf("Port %s received %d packets, want at most %g of %d sent", otherPort.ID(), got, 1-minRatio, outPkts)
+	}
+}
+
+func TestStaticRoutePreference(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	ate := ondatra.ATE(t, "ate")
+
+	configureDUT(t, dut)
+	top := configureATE(t, ate)
+	top.Push(t).StartProtocols(t)
+	defer top.StopProtocols(t)
+
+	ap2 := ate.Port(t, "port2")
+	ap3 := ate.Port(t, "port3")
+	ipv4Path := dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).Afts().Ipv4Entry(ateDstNetCIDR)
+
+	t.Logf("Configure a static route for %s to ATE port-3.", ateDstNetCIDR)
+	static.Configure(t, dut, *deviations.DefaultNetworkInstance, ateDstNetCIDR, &static.NextHop{
+		Address:   atePort3.IPv4,
+		Interface: dut.Port(t, "port3").Name(),
+	})
+	defer static.Delete(t, dut, *deviations.DefaultNetworkInstance, ateDstNetCIDR)
+
+	if !fptest.Await(t, ipv4Path.Prefix().Watch, time.Minute, ateDstNetCIDR) {
+		t.FailNow()
+	}
+	t.Run("StaticOnly", func(t *testing.T) {
+		testTraffic(t, ate, top, ap3, ap2)
+	})
+
+	wantInstalled := fluent.InstalledInFIB
+	if *deviations.GRIBIRIBAckOnly {
+		wantInstalled = fluent.InstalledInRIB
+	}
+	c := &gribi.Client{
+		DUT:                  dut,
+		FibACK:               !*deviations.GRIBIRIBAckOnly,
+		Persistence:          true,
+		InitialElectionIDLow: 10,
+	}
+	defer c.Close(t)
+	if err := c.Start(t); err != nil {
+		t.Fatalf("gRIBI connection could not be established: %v", err)
+	}
+	c.BecomeLeader(t)
+	defer c.Flush(t)
+
+	t.Logf("Program %s to ATE port-2 via gRIBI.", ateDstNetCIDR)
+	c.AddNH(t, nhIndex, atePort2.IPv4, *deviations.DefaultNetworkInstance, wantInstalled)
+	c.AddNHG(t, nhgIndex, map[uint64]uint64{nhIndex: 1}, *deviations.DefaultNetworkInstance, wantInstalled)
+	c.AddIPv4(t, ateDstNetCIDR, nhgIndex, *deviations.DefaultNetworkInstance, "", wantInstalled)
+
+	t.Run("GRIBIPreferred", func(t *testing.T) {
+		testTraffic(t, ate, top, ap2, ap3)
+	})
+
+	t.Logf("Delete %s from gRIBI.", ateDstNetCIDR)
+	c.DeleteIPv4(t, ateDstNetCIDR, *deviations.DefaultNetworkInstance, wantInstalled)
+
+	t.Run("StaticFallback", func(t *testing.T) {
+		testTraffic(t, ate, top, ap3, ap2)
+	})
+}
@@ -16,14 +16,18 @@ package supervisor_failure_test
 
 import (
 	"context"
+	"fmt"
+	"sort"
 	"testing"
 	"time"
 
+	gocmp "github.com/google/go-cmp/cmp"
 	"github.com/openconfig/featureprofiles/internal/attrs"
 	cmp "github.com/openconfig/featureprofiles/internal/components"
 	"github.com/openconfig/featureprofiles/internal/deviations"
 	"github.com/openconfig/featureprofiles/internal/fptest"
 	"github.com/openconfig/featureprofiles/internal/gribi"
+	"github.com/openconfig/featureprofiles/internal/traffic"
 	"github.com/openconfig/gribigo/fluent"
 	"github.com/openconfig/ondatra"
 	"github.com/openconfig/ondatra/telemetry"
@@ -58,6 +62,9 @@ const (
 	secondaryController = telemetry.PlatformTypes_ComponentRedundantRole_SECONDARY
 	switchTrigger       = telemetry.PlatformTypes_ComponentRedundantRoleSwitchoverReasonTrigger_SYSTEM_INITIATED
 	maxSwitchoverTime   = 900
+	// maxSwitchoverLossPct is the maximum loss of the traffic running
+	// across the switchover.
+	maxSwitchoverLossPct = 1.0
 )
 
 var (
@@ -164,16 +171,6 @@ func sendTraffic(t *testing.T, ate *ondatra.ATEDevice, flow *ondatra.Flow) {
 	ate.Traffic().Start(t, flow)
 }
 
-// Function to verify traffic
-func verifyTraffic(t *testing.T, ate *ondatra.ATEDevice, flow *ondatra.Flow) {
-	flowPath := ate.Telemetry().Flow(flow.Name())
-	if got := flowPath.LossPct().Get(t); got > 0 {
-		t.Errorf("LossPct for flow %s got %g, want 0", flow.Name(), got)
-	} else {
-		t.Logf("Traffic flows fine from ATE-port1 to ATE-port2")
-	}
-}
-
 // Function to stop traffic
 func stopTraffic(t *testing.T, ate *ondatra.ATEDevice) {
 	t.Logf("Stopping traffic")
@@ -259,18 +256,48 @@ func validateTelemetry(t *testing.T, dut *ondatra.DUTDevice, primaryAfterSwitch
 	}
 }
 
+// switchoverReady waits for the controller to report it is ready for a
+// switchover.
 func switchoverReady(t *testing.T, dut *ondatra.DUTDevice, controller string) bool {
-	switchoverReady := dut.Telemetry().Component(controller).SwitchoverReady()
-	_, ok := switchoverReady.Watch(t, 30*time.Minute, func(val *telemetry.QualifiedBool) bool {
-		return val != nil && val.Val(t) == true
-	}).Await(t)
-	return ok
+	return fptest.Await(t, dut.Telemetry().Component(controller).SwitchoverReady().Watch, 30*time.Minute, true)
+}
+
+// gribiEntries returns the entries in the default network instance
+// returned by a gRIBI Get, as sorted strings identifying each entry.
+func gribiEntries(t *testing.T, c *gribi.Client) []string {
+	gr, err := c.Fluent(t).Get().
+		WithNetworkInstance(*deviations.DefaultNetworkInstance).
+		WithAFT(fluent.AllAFTs).
+		Send()
+	if err != nil {
+		t.Fatalf("gRIBI Get got unexpected error: %v", err)
+	}
+	var entries []string
+	for _, e := range gr.GetEntry() {
+		switch {
+		case e.GetIpv4() != nil:
+			entries = append(entries, fmt.Sprintf("ipv4:%s->%d", e.GetIpv4().GetPrefix(), e.GetIpv4().GetIpv4Entry().GetNextHopGroup().GetValue()))
+		case e.GetNextHopGroup() != nil:
+			entries = append(entries, fmt.Sprintf("nhg:%d", e.GetNextHopGroup().GetId()))
+		case e.GetNextHop() != nil:
+			entries = append(entries, fmt.Sprintf("nh:%d", e.GetNextHop().GetIndex()))
+		}
+	}
+	sort.Strings(entries)
+	return entries
 }
 
 func TestSupFailure(t *testing.T) {
 	dut := ondatra.DUT(t, "dut")
 	ctx := context.Background()
 
+	// Only perform the switchover for the chassis with dual controllers.
+	controllers := cmp.FindComponentsByType(t, dut, controlcardType)
+	t.Logf("Found controller list: %v", controllers)
+	if len(controllers) != 2 {
+		t.Skipf("Dual controllers required on %v: got %v, want 2", dut.Model(), len(controllers))
+	}
+
 	// Configure the DUT
 	configureDUT(t, dut)
 
@@ -302,19 +329,14 @@ func TestSupFailure(t *testing.T) {
 	}
 	// Program a route and ensure AFT telemetry returns FIB_PROGRAMMED
 	routeInstall(ctx, t, args)
-	// Verify that static route(203.0.113.0/24) to ATE port-2 is preferred by the traffic.`
+	entriesBeforeSwitch := gribiEntries(t, &clientA)
+	t.Logf("gRIBI entries before switchover: %v", entriesBeforeSwitch)
+
+	// Verify that the route (203.0.113.0/24) to ATE port-2 is preferred by the traffic.
 	srcEndPoint := args.top.Interfaces()[atePort1.Name]
 	dstEndPoint := args.top.Interfaces()[atePort2.Name]
 	flow := createTrafficFlow(t, args.ate, args.top, srcEndPoint, dstEndPoint)
-	sendTraffic(t, args.ate, flow)
-	verifyTraffic(t, args.ate, flow)
-
-	controllers := cmp.FindComponentsByType(t, dut, controlcardType)
-	t.Logf("Found controller list: %v", controllers)
-	// Only perform the switchover for the chassis with dual controllers.
-	if len(controllers) != 2 {
-		t.Skipf("Dual controllers required on %v: got %v, want 2", dut.Model(), len(controllers))
-	}
+	traffic.ValidateFlow(t, args.ate, flow, nil)
 
 	secondaryBeforeSwitch, primaryBeforeSwitch := findSecondaryController(t, dut, controllers)
 
@@ -322,6 +344,9 @@ func TestSupFailure(t *testing.T) {
 		t.Fatalf("Controller %q did not become switchover-ready before test.", primaryBeforeSwitch)
 	}
 
+	// Run traffic across the switchover.
+	sendTraffic(t, args.ate, flow)
+
 	gnoiClient := dut.RawAPIs().GNOI().Default(t)
 	switchoverRequest := &spb.SwitchControlProcessorRequest{
 		ControlProcessor: &tpb.Path{
@@ -357,16 +382,16 @@ func TestSupFailure(t *testing.T) {
 
 	// Old secondary controller becomes primary after switchover.
 	primaryAfterSwitch := secondaryBeforeSwitch
+	if !fptest.Await(t, dut.Telemetry().Component(primaryAfterSwitch).RedundantRole().Watch, maxSwitchoverTime*time.Second, primaryController) {
+		t.Fatalf("Controller %q did not become primary after switchover.", primaryAfterSwitch)
+	}
 
 	validateTelemetry(t, dut, primaryAfterSwitch)
-	// Assume Controller Switchover happened, ensure traffic flows without loss.
-	// Verify the entry for 203.0.113.0/24 is active through AFT Telemetry.
-	// Try starting the gribi client twice as switchover may reset the connection.
-	if err := clientA.Start(t); err != nil {
-		t.Logf("gRIBI Connection could not be established: %v\nRetrying...", err)
-		if err = clientA.Start(t); err != nil {
-			t.Fatalf("gRIBI Connection could not be established: %v", err)
-		}
+
+	// The switchover resets the gRIBI connection, so reconnect to the
+	// gRIBI server on the new primary controller.
+	if err := clientA.Reconnect(t, maxSwitchoverTime*time.Second); err != nil {
+		t.Fatalf("gRIBI Connection could not be re-established: %v", err)
 	}
 
 	// Verify the entry for 203.0.113.0/24 is active through AFT Telemetry.
@@ -377,8 +402,33 @@ func TestSupFailure(t *testing.T) {
 		t.Logf("ipv4-entry found for %s after controller switchover..", got)
 	}
 
-	verifyTraffic(t, args.ate, flow)
+	// Verify that the traffic running across the switchover lost at most
+	// maxSwitchoverLossPct of its packets.
 	stopTraffic(t, args.ate)
+	if got := ate.Telemetry().Flow(flow.Name()).LossPct().Get(t); got > maxSwitchoverLossPct {
+		t.Errorf("LossPct for flow %s across switchover got %g, want at most %g", flow.Name(), got, maxSwitchoverLossPct)
+	}
+
+	// Verify a fresh client gets the same entries from the new primary
+	// controller.
+	clientB := gribi.Client{
+		DUT:                  dut,
+		FibACK:               false,
+		Persistence:          true,
+		InitialElectionIDLow: 11,
+	}
+	defer clientB.Close(t)
+	if err := clientB.Start(t); err != nil {
+		t.Fatalf("gRIBI Connection can not be established for clientB: %v", err)
+	}
+	entriesAfterSwitch := gribiEntries(t, &clientB)
+	if diff := gocmp.Diff(entriesBeforeSwitch, entriesAfterSwitch); diff != "" {
+		t.Errorf("gRIBI entries after switchover differ (-before +after):\n%s", diff)
+	}
+
+	// Verify traffic flows without loss after switchover.
+	traffic.ValidateFlow(t, args.ate, flow, nil)
+
 	top.StopProtocols(t)
 	clientA.Flush(t)
 }
@@ -0,0 +1,224 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unresolved_next_hop_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/gribi"
+	"github.com/openconfig/featureprofiles/internal/static"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	spb "github.com/openconfig/gribi/v1/proto/service"
+	"github.com/openconfig/gribigo/client"
+	"github.com/openconfig/gribigo/fluent"
+	"github.com/openconfig/ondatra"
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1 and
+// dut:port2 -> ate:port2.
+//
+//   - Source: ate:port1 -> dut:port1 subnet 192.0.2.0/30
+//   - Destination: dut:port2 -> ate:port2 subnet 192.0.2.4/30
+//
+// The next hop 192.0.2.254 is not covered by any connected subnet or
+// route until a static route to ate:port2 is added for it.
+const (
+	plen4 = 30
+
+	ateDstNetName = "dstnet"
+	ateDstNetCIDR = "203.0.113.0/24"
+
+	unresolvedNH     = "192.0.2.254"
+	unresolvedNHCIDR = "192.0.2.254/32"
+
+	nhIndex  = 1
+	nhgIndex = 1
+)
+
+var (
+	ateSrc = attrs.Attributes{
+		Name:    "ateSrc",
+		IPv4:    "192.0.2.1",
+		IPv4Len: plen4,
+	}
+
+	dutSrc = attrs.Attributes{
+		Desc:    "DUT to ATE source",
+		IPv4:    "192.0.2.2",
+		IPv4Len: plen4,
+	}
+
+	dutDst = attrs.Attributes{
+		Desc:    "DUT to ATE destination",
+		IPv4:    "192.0.2.5",
+		IPv4Len: plen4,
+	}
+
+	ateDst = attrs.Attributes{
+		Name:    "ateDst",
+		IPv4:    "192.0.2.6",
+		IPv4Len: plen4,
+	}
+)
+
+// configureDUT configures port1 and port2 on the DUT.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	d := dut.Config()
+
+	p1 := dut.Port(t, "port1")
+	d.Interface(p1.Name()).Replace(t, dutSrc.NewInterface(p1.Name()))
+
+	p2 := dut.Port(t, "port2")
+	d.Interface(p2.Name()).Replace(t, dutDst.NewInterface(p2.Name()))
+}
+
+// configureATE configures port1 and port2 on the ATE.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice) *ondatra.ATETopology {
+	top := ate.Topology().New()
+	ateSrc.AddToATE(top, ate.Port(t, "port1"), &dutSrc)
+	i2 := ateDst.AddToATE(top, ate.Port(t, "port2"), &dutDst)
+	i2.AddNetwork(ateDstNetName).IPv4().WithAddress(ateDstNetCIDR)
+	return top
+}
+
+// testTraffic generates traffic flow from ate:port1 to the
+// destination network and checks for packet loss.
+func testTraffic(t *testing.T, ate *ondatra.ATEDevice, top *ondatra.ATETopology) {
+	flow := ate.Traffic().NewFlow("Flow").
+		WithSrcEndpoints(top.Interfaces()[ateSrc.Name]).
+		WithDstEndpoints(top.Interfaces()[ateDst.Name].Networks()[ateDstNetName]).
+		WithHeaders(ondatra.NewEthernetHeader(), ondatra.NewIPv4Header())
+
+	traffic.ValidateFlow(t, ate, flow, nil)
+}
+
+// ipv4Results returns the programming results of the operations on the
+// IPv4 prefix in the order they were received.
+func ipv4Results(res []*client.OpResult, prefix string) []spb.AFTResult_Status {
+	var got []spb.AFTResult_Status
+	for _, r := range res {
+		if r.Details != nil && r.Details.IPv4Prefix == prefix {
+			got = append(got, r.ProgrammingResult)
+		}
+	}
+	return got
+}
+
+// addIPv4 adds the IPv4 entry of the destination network, and returns
+// the programming results of the IPv4 operations so far.
+func addIPv4(ctx context.Context, t *testing.T, c *fluent.GRIBIClient, ops *gribi.OpIDs) []spb.AFTResult_Status {
+	ids := ops.Next(1)
+	c.Modify().AddEntry(t,
+		fluent.IPv4Entry().
+			WithNetworkInstance(*deviations.DefaultNetworkInstance).
+			WithPrefix(ateDstNetCIDR).
+			WithNextHopGroup(nhgIndex),
+	)
+	if err := gribi.Await(ctx, t, c, nil, ids...); err != nil {
+		t.Fatalf("Await got error for ModifyRequest: %v", err)
+	}
+	return ipv4Results(c.Results(t), ateDstNetCIDR)
+}
+
+func TestUnresolvedNextHop(t *testing.T) {
+	if *deviations.GRIBIRIBAckOnly {
+		t.Skip("Skipping due to --deviation_gribi_riback_only")
+	}
+
+	ctx := context.Background()
+	dut := ondatra.DUT(t, "dut")
+	configureDUT(t, dut)
+
+	ate := ondatra.ATE(t, "ate")
+	top := configureATE(t, ate)
+	top.Push(t).StartProtocols(t)
+
+	c := fluent.NewClient()
+	c.Connection().
+		WithStub(dut.RawAPIs().GRIBI().Default(t)).
+		WithRedundancyMode(fluent.ElectedPrimaryClient).
+		WithPersistence().
+		WithFIBACK().
+		WithInitialElectionID(1 /* low */, 0 /* hi */) // ID must be > 0.
+	c.Start(ctx, t)
+	defer c.Stop(t)
+	c.StartSending(ctx, t)
+	if err := gribi.Await(ctx, t, c, nil); err != nil {
+		t.Fatalf("Await got error during session negotiation: %v", err)
+	}
+	ops := &gribi.OpIDs{}
+	defer func() {
+		_, err := c.Flush().
+			WithElectionOverride().
+			WithAllNetworkInstances().
+			Send()
+		if err != nil {
+			t.Errorf("Cannot flush: %v", err)
+		}
+	}()
+
+	t.Logf("Add a NextHop to %s, which has no covering route, and a NextHopGroup.", unresolvedNH)
+	ids := ops.Next(2)
+	c.Modify().AddEntry(t,
+		fluent.NextHopEntry().
+			WithNetworkInstance(*deviations.DefaultNetworkInstance).
+			WithIndex(nhIndex).
+			WithIPAddress(unresolvedNH),
+		fluent.NextHopGroupEntry().
+			WithNetworkInstance(*deviations.DefaultNetworkInstance).
+			WithID(nhgIndex).
+			AddNextHop(nhIndex, 1),
+	)
+	if err := gribi.Await(ctx, t, c, nil, ids...); err != nil {
+		t.Fatalf("Await got error for ModifyRequest: %v", err)
+	}
+
+	t.Run("Unresolved", func(t *testing.T) {
+		want := spb.AFTResult_FIB_FAILED
+		if *deviations.GRIBIUnresolvedNextHopFailed {
+			want = spb.AFTResult_FAILED
+		}
+		got := addIPv4(ctx, t, c, ops)
+		if len(got) == 0 || got[len(got)-1] != want {
+			t.Errorf("IPv4Entry %s results got %v, want last result %v", ateDstNetCIDR, got, want)
+		}
+	})
+
+	t.Logf("Add a static route for %s to ATE port-2.", unresolvedNHCIDR)
+	static.Configure(t, dut, *deviations.DefaultNetworkInstance, unresolvedNHCIDR, &static.NextHop{
+		Address:   ateDst.IPv4,
+		Interface: dut.Port(t, "port2").Name(),
+	})
+	defer static.Delete(t, dut, *deviations.DefaultNetworkInstance, unresolvedNHCIDR)
+
+	t.Run("Resolved", func(t *testing.T) {
+		got := addIPv4(ctx, t, c, ops)
+		if want := spb.AFTResult_FIB_PROGRAMMED; len(got) == 0 || got[len(got)-1] != want {
+			t.Fatalf("IPv4Entry %s results got %v, want last result %v", ateDstNetCIDR, got, want)
+		}
+		testTraffic(t, ate, top)
+	})
+}
@@ -20,7 +20,9 @@ import (
 	"testing"
 	"time"
 
-	"github.com/google/go-cmp/cmp"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/topocache"
+	"github.com/openconfig/featureprofiles/internal/traffic"
 	"github.com/openconfig/gribigo/chk"
 	"github.com/openconfig/gribigo/fluent"
 	"github.com/openconfig/ondatra"
@@ -36,18 +38,17 @@ func nextHopsEvenly(atePorts []*ondatra.Port) []nextHop {
 		if ateid == ateSrcPort {
 			continue
 		}
-		nexthops = append(nexthops, nextHop{ateid, 1, 0})
+		nexthops = append(nexthops, nextHop{ateid, 1})
 	}
 	return nexthops
 }
 
-// portWantsEvenly generates wanted weights assuming that the traffic
+// portWeightsEvenly generates wanted weights assuming that the traffic
 // should be evenly distributed across the ports that are still up.
-func portWantsEvenly(atePorts []*ondatra.Port, numUps int) []float64 {
-	weights := make([]float64, len(atePorts))
-	x := 1.0 / float64(numUps)
+func portWeightsEvenly(atePorts []*ondatra.Port, numUps int) []uint64 {
+	weights := make([]uint64, len(atePorts))
 	for i := 1; i <= numUps; i++ {
-		weights[i] = x
+		weights[i] = 1
 	}
 	return weights
 }
@@ -65,19 +66,12 @@ func testNextHopRemaining(
 	t.Logf("inPkts = %v", inPkts)
 	t.Logf("outPkts = %v", outPkts)
 
-	got, inSum := normalize(inPkts)
-	want := portWantsEvenly(atePorts, numUps)
-	t.Logf("inPkts normalized got: %v", got)
-	t.Logf("weights want: %v", want)
-
 	// Report diagnosis.
 	t.Run("Ratio", func(t *testing.T) {
-		if diff := cmp.Diff(want, got, approxOpt); diff != "" {
-			t.Errorf("Packet distribution ratios -want,+got:\n%s", diff)
-		}
+		traffic.ReportDistribution(t, portIDs(atePorts), nil, inPkts, portWeightsEvenly(atePorts, numUps), ratioTolerancePct)
 	})
 	t.Run("Loss", func(t *testing.T) {
-		if outPkts[0] > inSum {
+		if inSum := sum(inPkts); outPkts[0] > inSum {
 			t.Errorf("Traffic flow sent %d packets, received only %d",
 				outPkts[0], inSum)
 		}
@@ -95,10 +89,13 @@ func TestPortFlap(t *testing.T) {
 	// Configure the DUT
 	configureDUT(t, dut)
 
-	// Configure the ATE
+	// Configure the ATE, unless an earlier test already pushed the same
+	// topology.  This test disables ATE ports, so the next test must push
+	// the topology again.
 	ate := ondatra.ATE(t, "ate")
 	top := configureATE(t, ate)
-	top.Push(t).StartProtocols(t)
+	topocache.EnsureStarted(t, ate, top)
+	defer topocache.Invalidate(ate)
 
 	// Create nexthops across the dst atePorts.
 	atePorts := sortPorts(ate.Ports())
@@ -164,7 +161,9 @@ func TestPortFlap(t *testing.T) {
 				dp := dut.Port(t, atePorts[i].ID())
 				dip := dt.Interface(dp.Name())
 				t.Logf("Awaiting DUT port down: %v", dp)
-				dip.OperStatus().Await(t, time.Minute, telemetry.Interface_OperStatus_DOWN)
+				if !fptest.Await(t, dip.OperStatus().Watch, time.Minute, telemetry.Interface_OperStatus_DOWN) {
+					t.FailNow()
+				}
 				t.Log("Port is down.")
 			}
 			testNextHopRemaining(t, numUps, dut, ate, top)
@@ -0,0 +1,317 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package decap_vrf_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/layers"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/gribi"
+	"github.com/openconfig/featureprofiles/internal/otgutils"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/gribigo/chk"
+	"github.com/openconfig/gribigo/constants"
+	"github.com/openconfig/gribigo/fluent"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+	otgtelemetry "github.com/openconfig/ondatra/telemetry/otg"
+	"github.com/openconfig/ygot/ygot"
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1 and
+// dut:port2 -> ate:port2.
+//
+//   - ate:port1 -> dut:port1 subnet 192.0.2.0/30
+//   - ate:port2 -> dut:port2 subnet 192.0.2.4/30
+//
+// IP-in-IP packets to the outer destination network 203.0.113.0/24 are
+// decapsulated in the default network instance, and the inner packet is
+// looked up in vrfName, where the inner destination network
+// 198.51.100.0/24 is routed to ate:port2.  Inner destinations in
+// 198.18.0.0/24 have no entry in vrfName.
+const (
+	ipv4PrefixLen = 30
+	vrfName       = "VRF-1"
+
+	outerDstCIDR    = "203.0.113.0/24"
+	outerDst        = "203.0.113.1"
+	innerDstCIDR    = "198.51.100.0/24"
+	innerDstStart   = "198.51.100.1"
+	missingDstStart = "198.18.0.1"
+	innerDstCount   = 250
+
+	decapNHIndex  = 1
+	decapNHGIndex = 10
+	vrfNHIndex    = 2
+	vrfNHGIndex   = 20
+
+	flowPackets = 1000
+	flowPPS     = 100
+	// flowTimeout is how long a flow may take to transmit its packets.
+	flowTimeout = 2 * flowPackets / flowPPS * time.Second
+	// noiseFrames is the number of frames a port may receive from
+	// control plane protocols while checking that it receives no traffic.
+	noiseFrames = 10
+)
+
+var (
+	dutPort1 = attrs.Attributes{
+		Desc:    "dutPort1",
+		IPv4:    "192.0.2.1",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort1 = attrs.Attributes{
+		Name:    "atePort1",
+		MAC:     "02:00:01:01:01:01",
+		IPv4:    "192.0.2.2",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	dutPort2 = attrs.Attributes{
+		Desc:    "dutPort2",
+		IPv4:    "192.0.2.5",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort2 = attrs.Attributes{
+		Name:    "atePort2",
+		MAC:     "02:00:02:01:01:01",
+		IPv4:    "192.0.2.6",
+		IPv4Len: ipv4PrefixLen,
+	}
+)
+
+// configureDUT configures port1 and port2 in the default network
+// instance, and the VRF used for the inner lookup.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	d := dut.Config()
+
+	p1 := dut.Port(t, "port1")
+	d.Interface(p1.Name()).Replace(t, dutPort1.NewInterface(p1.Name()))
+
+	p2 := dut.Port(t, "port2")
+	d.Interface(p2.Name()).Replace(t, dutPort2.NewInterface(p2.Name()))
+
+	vrf := &telemetry.NetworkInstance{
+		Name:    ygot.String(vrfName),
+		Enabled: ygot.Bool(true),
+		Type:    telemetry.NetworkInstanceTypes_NETWORK_INSTANCE_TYPE_L3VRF,
+	}
+	d.NetworkInstance(vrfName).Replace(t, vrf)
+}
+
+// configureATE configures port1 and port2 on the ATE, with a capture on
+// port2.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice) gosnappi.Config {
+	top := ate.OTG().NewConfig(t)
+	atePort1.AddToOTG(top, ate.Port(t, "port1"), &dutPort1)
+	atePort2.AddToOTG(top, ate.Port(t, "port2"), &dutPort2)
+	traffic.EnableCapture(top, ate.Port(t, "port2").ID())
+	return top
+}
+
+// addFlow adds a flow of IP-in-IP packets from ate:port1 to the outer
+// destination, with inner UDP packets to innerStart.
+func addFlow(t *testing.T, ate *ondatra.ATEDevice, top gosnappi.Config, name, innerStart string) {
+	otg := ate.OTG()
+	otg.Telemetry().Interface(atePort1.Name+".Eth").Ipv4Neighbor(dutPort1.IPv4).LinkLayerAddress().Watch(
+		t, time.Minute, func(val *otgtelemetry.QualifiedString) bool {
+			return val.IsPresent()
+		}).Await(t)
+	dstMac := otg.Telemetry().Interface(atePort1.Name + ".Eth").Ipv4Neighbor(dutPort1.IPv4).LinkLayerAddress().Get(t)
+
+	flow := top.Flows().Add().SetName(name)
+	flow.Metrics().SetEnable(true)
+	flow.TxRx().Port().
+		SetTxName(ate.Port(t, "port1").ID()).
+		SetRxName(ate.Port(t, "port2").ID())
+	flow.Duration().FixedPackets().SetPackets(flowPackets)
+	flow.Rate().SetPps(flowPPS)
+	eth := flow.Packet().Add().Ethernet()
+	eth.Src().SetValue(atePort1.MAC)
+	eth.Dst().SetValue(dstMac)
+	outer := flow.Packet().Add().Ipv4()
+	outer.Src().SetValue(atePort1.IPv4)
+	outer.Dst().SetValue(outerDst)
+	inner := flow.Packet().Add().Ipv4()
+	inner.Src().SetValue(atePort1.IPv4)
+	inner.Dst().Increment().SetStart(innerStart).SetCount(innerDstCount)
+	flow.Packet().Add().Udp()
+}
+
+// runFlow runs the named flow alone, and returns the number of frames
+// received by each ATE port while it ran, and the packets captured on
+// ate:port2.
+func runFlow(t *testing.T, ate *ondatra.ATEDevice, top gosnappi.Config, name, innerStart string) (map[string]uint64, []*traffic.Packet) {
+	otg := ate.OTG()
+	top.Flows().Clear().Items()
+	addFlow(t, ate, top, name, innerStart)
+	otg.PushConfig(t, top)
+	otg.StartProtocols(t)
+
+	before := map[string]uint64{}
+	for _, p := range top.Ports().Items() {
+		before[p.Name()] = otg.Telemetry().Port(p.Name()).Counters().InFrames().Get(t)
+	}
+
+	capturePort := ate.Port(t, "port2").ID()
+	traffic.StartCapture(t, ate, capturePort)
+	traffic.RunOTGFlow(t, ate, top, name, &traffic.Options{MinTxPkts: flowPackets, MaxDuration: flowTimeout})
+	traffic.StopCapture(t, ate, capturePort)
+	otgutils.LogPortMetrics(t, otg, top)
+
+	received := map[string]uint64{}
+	for _, p := range top.Ports().Items() {
+		received[p.Name()] = otg.Telemetry().Port(p.Name()).Counters().InFrames().Get(t) - before[p.Name()]
+	}
+	return received, traffic.CapturedPackets(t, ate, capturePort)
+}
+
+// checkAFT waits for the prefix to be present in the AFT of the network
+// instance.
+func checkAFT(t *testing.T, dut *ondatra.DUTDevice, ni, prefix string) {
+	ipv4Path := dut.Telemetry().NetworkInstance(ni).Afts().Ipv4Entry(prefix)
+	if got, ok := ipv4Path.Prefix().Watch(t, time.Minute, func(val *telemetry.QualifiedString) bool {
+		return val.IsPresent() && val.Val(t) == prefix
+	}).Await(t); !ok {
+		t.Errorf("Network instance %s ipv4-entry/state/prefix got %v, want %s", ni, got, prefix)
+	}
+}
+
+func TestDecapVRF(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	ate := ondatra.ATE(t, "ate")
+
+	configureDUT(t, dut)
+	defer dut.Config().NetworkInstance(vrfName).Delete(t)
+	top := configureATE(t, ate)
+	ate.OTG().PushConfig(t, top)
+	ate.OTG().StartProtocols(t)
+
+	wantInstalled := fluent.InstalledInFIB
+	if *deviations.GRIBIRIBAckOnly {
+		wantInstalled = fluent.InstalledInRIB
+	}
+	c := &gribi.Client{
+		DUT:                  dut,
+		FibACK:               !*deviations.GRIBIRIBAckOnly,
+		Persistence:          true,
+		InitialElectionIDLow: 10,
+	}
+	defer c.Close(t)
+	if err := c.Start(t); err != nil {
+		t.Fatalf("gRIBI connection could not be established: %v", err)
+	}
+	c.BecomeLeader(t)
+	defer c.Flush(t)
+
+	t.Logf("Program %s in %s to ATE port-2.", innerDstCIDR, vrfName)
+	c.AddNH(t, vrfNHIndex, atePort2.IPv4, *deviations.DefaultNetworkInstance, wantInstalled)
+	c.AddNHG(t, vrfNHGIndex, map[uint64]uint64{vrfNHIndex: 1}, *deviations.DefaultNetworkInstance, wantInstalled)
+	c.AddIPv4(t, innerDstCIDR, vrfNHGIndex, vrfName, *deviations.DefaultNetworkInstance, wantInstalled)
+
+	t.Logf("Program %s to decapsulate and look up in %s.", outerDstCIDR, vrfName)
+	fc := c.Fluent(t)
+	fc.Modify().AddEntry(t,
+		fluent.NextHopEntry().
+			WithNetworkInstance(*deviations.DefaultNetworkInstance).
+			WithIndex(decapNHIndex).
+			WithDecapsulateHeader(fluent.IPinIP).
+			WithNextHopNetworkInstance(vrfName),
+	)
+	if err := c.AwaitTimeout(context.Background(), t, time.Minute); err != nil {
+		t.Fatalf("Await got error for ModifyRequest: %v", err)
+	}
+	chk.HasResult(t, fc.Results(t),
+		fluent.OperationResult().
+			WithNextHopOperation(decapNHIndex).
+			WithOperationType(constants.Add).
+			WithProgrammingResult(wantInstalled).
+			AsResult(),
+		chk.IgnoreOperationID(),
+	)
+	c.AddNHG(t, decapNHGIndex, map[uint64]uint64{decapNHIndex: 1}, *deviations.DefaultNetworkInstance, wantInstalled)
+	c.AddIPv4(t, outerDstCIDR, decapNHGIndex, *deviations.DefaultNetworkInstance, "", wantInstalled)
+
+	t.Run("AFT", func(t *testing.T) {
+		checkAFT(t, dut, *deviations.DefaultNetworkInstance, outerDstCIDR)
+		checkAFT(t, dut, vrfName, innerDstCIDR)
+	})
+
+	t.Run("Decap", func(t *testing.T) {
+		const flowName = "Decap"
+		_, pkts := runFlow(t, ate, top, flowName, innerDstStart)
+
+		counters := ate.OTG().Telemetry().Flow(flowName).Counters()
+		if out, in := counters.OutPkts().Get(t), counters.InPkts().Get(t); out == 0 || in < out {
+			t.Errorf("Flow %s received %d of %d packets, want all", flowName, in, out)
+		}
+
+		_, innerNet, err := net.ParseCIDR(innerDstCIDR)
+		if err != nil {
+			t.Fatalf("Cannot parse %s: %v", innerDstCIDR, err)
+		}
+		var decapped, encapped int
+		for _, p := range pkts {
+			outer := p.Outer()
+			switch {
+			case outer == nil:
+			case p.Inner() != nil && outer.Dst == outerDst:
+				encapped++
+			case innerNet.Contains(net.ParseIP(outer.Dst)):
+				decapped++
+				if outer.Src != atePort1.IPv4 || outer.Protocol != layers.IPProtocolUDP {
+					t.Errorf("Decapsulated header got %+v, want src %s, protocol %v", outer, atePort1.IPv4, layers.IPProtocolUDP)
+				}
+			}
+		}
+		t.Logf("Captured %d decapsulated and %d encapsulated packets", decapped, encapped)
+		if decapped == 0 {
+			t.Errorf("Captured no decapsulated packets to %s", innerDstCIDR)
+		}
+		if encapped > 0 {
+			t.Errorf("Captured %d packets still encapsulated to %s, want 0", encapped, outerDst)
+		}
+	})
+
+	t.Run("NoVRFEntry", func(t *testing.T) {
+		const flowName = "NoVRFEntry"
+		received, _ := runFlow(t, ate, top, flowName, missingDstStart)
+
+		if got := ate.OTG().Telemetry().Flow(flowName).Counters().InPkts().Get(t); got > 0 {
+			t.Errorf("Flow %s received %d packets, want 0", flowName, got)
+		}
+		for port, got := range received {
+			if got > noiseFrames {
+				t.Errorf("Port %s received %d frames, want at most %d", port, got, noiseFrames)
+			}
+		}
+	})
+}
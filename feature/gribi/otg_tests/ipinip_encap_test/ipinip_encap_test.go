@@ -0,0 +1,290 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipinip_encap_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/layers"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/gribi"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/gribigo/chk"
+	"github.com/openconfig/gribigo/constants"
+	"github.com/openconfig/gribigo/fluent"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+	otgtelemetry "github.com/openconfig/ondatra/telemetry/otg"
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1 and
+// dut:port2 -> ate:port2.
+//
+//   - ate:port1 -> dut:port1 subnet 192.0.2.0/30
+//   - ate:port2 -> dut:port2 subnet 192.0.2.4/30
+//
+// Traffic to the inner destination network 198.51.100.0/24 is
+// encapsulated by the DUT in an outer IPv4 header from tunnelSrc to
+// ate:port2.
+const (
+	ipv4PrefixLen = 30
+	innerDstCIDR  = "198.51.100.0/24"
+	innerDstStart = "198.51.100.1"
+	innerDstCount = 250
+	tunnelSrc     = "203.0.113.1"
+	nhIndex       = 1
+	nhgIndex      = 42
+
+	flowName    = "Flow"
+	flowPackets = 1000
+	flowPPS     = 100
+	udpSrcPort  = 49152
+	udpDstPort  = 50000
+	// flowTimeout is how long the flow may take to transmit its packets.
+	flowTimeout = 2 * flowPackets / flowPPS * time.Second
+)
+
+var (
+	dutPort1 = attrs.Attributes{
+		Desc:    "dutPort1",
+		IPv4:    "192.0.2.1",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort1 = attrs.Attributes{
+		Name:    "atePort1",
+		MAC:     "02:00:01:01:01:01",
+		IPv4:    "192.0.2.2",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	dutPort2 = attrs.Attributes{
+		Desc:    "dutPort2",
+		IPv4:    "192.0.2.5",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort2 = attrs.Attributes{
+		Name:    "atePort2",
+		MAC:     "02:00:02:01:01:01",
+		IPv4:    "192.0.2.6",
+		IPv4Len: ipv4PrefixLen,
+	}
+)
+
+// configureDUT configures port1 and port2 on the DUT.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	d := dut.Config()
+
+	p1 := dut.Port(t, "port1")
+	d.Interface(p1.Name()).Replace(t, dutPort1.NewInterface(p1.Name()))
+
+	p2 := dut.Port(t, "port2")
+	d.Interface(p2.Name()).Replace(t, dutPort2.NewInterface(p2.Name()))
+}
+
+// configureATE configures port1 and port2 on the ATE, with a capture on
+// port2.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice) gosnappi.Config {
+	top := ate.OTG().NewConfig(t)
+	atePort1.AddToOTG(top, ate.Port(t, "port1"), &dutPort1)
+	atePort2.AddToOTG(top, ate.Port(t, "port2"), &dutPort2)
+	traffic.EnableCapture(top, ate.Port(t, "port2").ID())
+	return top
+}
+
+// addFlow adds a flow of UDP packets from ate:port1 to the inner
+// destination network.
+func addFlow(t *testing.T, ate *ondatra.ATEDevice, top gosnappi.Config) {
+	otg := ate.OTG()
+	otg.Telemetry().Interface(atePort1.Name+".Eth").Ipv4Neighbor(dutPort1.IPv4).LinkLayerAddress().Watch(
+		t, time.Minute, func(val *otgtelemetry.QualifiedString) bool {
+			return val.IsPresent()
+		}).Await(t)
+	dstMac := otg.Telemetry().Interface(atePort1.Name + ".Eth").Ipv4Neighbor(dutPort1.IPv4).LinkLayerAddress().Get(t)
+
+	top.Flows().Clear().Items()
+	flow := top.Flows().Add().SetName(flowName)
+	flow.Metrics().SetEnable(true)
+	flow.TxRx().Port().
+		SetTxName(ate.Port(t, "port1").ID()).
+		SetRxName(ate.Port(t, "port2").ID())
+	flow.Duration().FixedPackets().SetPackets(flowPackets)
+	flow.Rate().SetPps(flowPPS)
+	eth := flow.Packet().Add().Ethernet()
+	eth.Src().SetValue(atePort1.MAC)
+	eth.Dst().SetValue(dstMac)
+	v4 := flow.Packet().Add().Ipv4()
+	v4.Src().SetValue(atePort1.IPv4)
+	v4.Dst().Increment().SetStart(innerDstStart).SetCount(innerDstCount)
+	udp := flow.Packet().Add().Udp()
+	udp.SrcPort().SetValue(udpSrcPort)
+	udp.DstPort().SetValue(udpDstPort)
+}
+
+// checkAFT checks the encapsulation of the next hops used by the inner
+// destination network, where the AFT telemetry populates it.
+func checkAFT(t *testing.T, dut *ondatra.DUTDevice) {
+	afts := dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).Afts()
+	nhgID := afts.Ipv4Entry(innerDstCIDR).NextHopGroup().Get(t)
+	nhg := afts.NextHopGroup(nhgID).Get(t)
+	if got, want := nhg.GetProgrammedId(), uint64(nhgIndex); got != want {
+		t.Errorf("next-hop-group/state/programmed-id got %d, want %d", got, want)
+	}
+	for idx := range nhg.NextHop {
+		nh := afts.NextHop(idx).Get(t)
+		if h := nh.GetEncapsulateHeader(); h != telemetry.AftTypes_EncapsulationHeaderType_UNSET {
+			if want := telemetry.AftTypes_EncapsulationHeaderType_IPV4; h != want {
+				t.Errorf("next-hop %d state/encapsulate-header got %v, want %v", idx, h, want)
+			}
+		} else {
+			t.Logf("next-hop %d state/encapsulate-header is not populated", idx)
+		}
+		ipinip := nh.GetIpInIp()
+		if ipinip == nil {
+			t.Logf("next-hop %d ip-in-ip is not populated", idx)
+			continue
+		}
+		if got := ipinip.GetSrcIp(); got != tunnelSrc {
+			t.Errorf("next-hop %d ip-in-ip/state/src-ip got %s, want %s", idx, got, tunnelSrc)
+		}
+		if got := ipinip.GetDstIp(); got != atePort2.IPv4 {
+			t.Errorf("next-hop %d ip-in-ip/state/dst-ip got %s, want %s", idx, got, atePort2.IPv4)
+		}
+	}
+}
+
+// checkCapture checks that the packets received by ate:port2 for the
+// inner destination network are encapsulated as expected.
+func checkCapture(t *testing.T, pkts []*traffic.Packet) {
+	_, innerNet, err := net.ParseCIDR(innerDstCIDR)
+	if err != nil {
+		t.Fatalf("Cannot parse %s: %v", innerDstCIDR, err)
+	}
+	var encapped, plain int
+	for _, p := range pkts {
+		inner := p.Inner()
+		if inner == nil {
+			if outer := p.Outer(); outer != nil && innerNet.Contains(net.ParseIP(outer.Dst)) {
+				plain++
+			}
+			continue
+		}
+		if !innerNet.Contains(net.ParseIP(inner.Dst)) {
+			continue
+		}
+		encapped++
+		outer := p.Outer()
+		if outer.Src != tunnelSrc || outer.Dst != atePort2.IPv4 || outer.Protocol != layers.IPProtocolIPv4 {
+			t.Errorf("Outer header got %+v, want src %s, dst %s, protocol %v", outer, tunnelSrc, atePort2.IPv4, layers.IPProtocolIPv4)
+		}
+		if inner.Src != atePort1.IPv4 || inner.Protocol != layers.IPProtocolUDP {
+			t.Errorf("Inner header got %+v, want src %s, protocol %v", inner, atePort1.IPv4, layers.IPProtocolUDP)
+		}
+	}
+	t.Logf("Captured %d encapsulated and %d unencapsulated packets to %s", encapped, plain, innerDstCIDR)
+	if encapped == 0 {
+		t.Errorf("Captured no encapsulated packets to %s", innerDstCIDR)
+	}
+	if plain > 0 {
+		t.Errorf("Captured %d unencapsulated packets to %s, want 0", plain, innerDstCIDR)
+	}
+}
+
+func TestIPinIPEncap(t *testing.T) {
+	if *deviations.GRIBIEncapNextHopUnsupported {
+		t.Skip("Skipping due to --deviation_gribi_encap_next_hop_unsupported")
+	}
+
+	dut := ondatra.DUT(t, "dut")
+	ate := ondatra.ATE(t, "ate")
+	otg := ate.OTG()
+
+	configureDUT(t, dut)
+	top := configureATE(t, ate)
+	otg.PushConfig(t, top)
+	otg.StartProtocols(t)
+
+	wantInstalled := fluent.InstalledInFIB
+	if *deviations.GRIBIRIBAckOnly {
+		wantInstalled = fluent.InstalledInRIB
+	}
+	c := &gribi.Client{
+		DUT:                  dut,
+		FibACK:               !*deviations.GRIBIRIBAckOnly,
+		Persistence:          true,
+		InitialElectionIDLow: 10,
+	}
+	defer c.Close(t)
+	if err := c.Start(t); err != nil {
+		t.Fatalf("gRIBI connection could not be established: %v", err)
+	}
+	c.BecomeLeader(t)
+	defer c.Flush(t)
+
+	t.Logf("Program %s via a next hop encapsulating from %s to %s.", innerDstCIDR, tunnelSrc, atePort2.IPv4)
+	fc := c.Fluent(t)
+	fc.Modify().AddEntry(t,
+		fluent.NextHopEntry().
+			WithNetworkInstance(*deviations.DefaultNetworkInstance).
+			WithIndex(nhIndex).
+			WithIPAddress(atePort2.IPv4).
+			WithEncapsulateHeader(fluent.IPinIP).
+			WithIPinIP(tunnelSrc, atePort2.IPv4),
+	)
+	if err := c.AwaitTimeout(context.Background(), t, time.Minute); err != nil {
+		t.Fatalf("Await got error for ModifyRequest: %v", err)
+	}
+	chk.HasResult(t, fc.Results(t),
+		fluent.OperationResult().
+			WithNextHopOperation(nhIndex).
+			WithOperationType(constants.Add).
+			WithProgrammingResult(wantInstalled).
+			AsResult(),
+		chk.IgnoreOperationID(),
+	)
+	c.AddNHG(t, nhgIndex, map[uint64]uint64{nhIndex: 1}, *deviations.DefaultNetworkInstance, wantInstalled)
+	c.AddIPv4(t, innerDstCIDR, nhgIndex, *deviations.DefaultNetworkInstance, "", wantInstalled)
+
+	t.Run("AFT", func(t *testing.T) {
+		checkAFT(t, dut)
+	})
+
+	t.Run("Capture", func(t *testing.T) {
+		addFlow(t, ate, top)
+		otg.PushConfig(t, top)
+		otg.StartProtocols(t)
+
+		capturePort := ate.Port(t, "port2").ID()
+		traffic.StartCapture(t, ate, capturePort)
+		traffic.RunOTGFlow(t, ate, top, flowName, &traffic.Options{MinTxPkts: flowPackets, MaxDuration: flowTimeout})
+		traffic.StopCapture(t, ate, capturePort)
+
+		checkCapture(t, traffic.CapturedPackets(t, ate, capturePort))
+	})
+}
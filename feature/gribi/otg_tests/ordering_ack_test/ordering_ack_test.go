@@ -19,19 +19,19 @@ import (
 	"flag"
 	"fmt"
 	"testing"
-	"time"
 
 	"github.com/open-traffic-generator/snappi/gosnappi"
 	"github.com/openconfig/featureprofiles/internal/attrs"
 	"github.com/openconfig/featureprofiles/internal/deviations"
 	"github.com/openconfig/featureprofiles/internal/fptest"
-	"github.com/openconfig/featureprofiles/internal/otgutils"
+	"github.com/openconfig/featureprofiles/internal/gribi"
+	"github.com/openconfig/featureprofiles/internal/tcheck"
+	"github.com/openconfig/featureprofiles/internal/traffic"
 	"github.com/openconfig/gribigo/chk"
 	"github.com/openconfig/gribigo/constants"
 	"github.com/openconfig/gribigo/fluent"
 	"github.com/openconfig/ondatra"
 	"github.com/openconfig/ondatra/telemetry"
-	otgtelemetry "github.com/openconfig/ondatra/telemetry/otg"
 	"github.com/openconfig/ygot/ygot"
 )
 
@@ -40,6 +40,9 @@ var (
 	// in order to establish a baseline to highlight the non-compliant behavior.  They
 	// should be set to the more strict setting.
 	checkTelemetry = flag.Bool("telemetry", false /* TODO: set to true */, "Check AFT telemetry.")
+
+	maxAvgLatency = flag.Duration("max_avg_latency", 0,
+		"Upper bound on the average forwarding latency of the traffic via the gRIBI route, or 0 not to check the latency.")
 )
 
 func TestMain(m *testing.M) {
@@ -72,11 +75,11 @@ const (
 	ateDstNetStartIp      = "203.0.113.1"
 	ateDstNetAddressCount = 250
 
+	flowName = "Flow"
+
 	nhIndex  = 42
 	nhWeight = 1
 	nhgIndex = 10
-
-	awaitDuration = 2 * time.Minute
 )
 
 var (
@@ -141,90 +144,37 @@ func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
 
 // configureATE configures port1 and port2 on the ATE.
 func configureATE(t *testing.T, ate *ondatra.ATEDevice) gosnappi.Config {
-	otg := ate.OTG()
-	top := otg.NewConfig(t)
-
-	top.Ports().Add().SetName(ate.Port(t, "port1").ID())
-	i1 := top.Devices().Add().SetName(ate.Port(t, "port1").ID())
-	eth1 := i1.Ethernets().Add().SetName(ateSrc.Name + ".Eth").
-		SetPortName(i1.Name()).SetMac(ateSrc.MAC)
-	eth1.Ipv4Addresses().Add().SetName(ateSrc.Name + ".IPv4").
-		SetAddress(ateSrc.IPv4).SetGateway(dutSrc.IPv4).
-		SetPrefix(int32(ateSrc.IPv4Len))
-
-	top.Ports().Add().SetName(ate.Port(t, "port2").ID())
-	i2 := top.Devices().Add().SetName(ate.Port(t, "port2").ID())
-	eth2 := i2.Ethernets().Add().SetName(ateDst.Name + ".Eth").
-		SetPortName(i2.Name()).SetMac(ateDst.MAC)
-	eth2.Ipv4Addresses().Add().SetName(ateDst.Name + ".IPv4").
-		SetAddress(ateDst.IPv4).SetGateway(dutDst.IPv4).
-		SetPrefix(int32(ateDst.IPv4Len))
-
+	top := ate.OTG().NewConfig(t)
+	ateSrc.AddToOTG(top, ate.Port(t, "port1"), &dutSrc)
+	ateDst.AddToOTG(top, ate.Port(t, "port2"), &dutDst)
 	return top
 }
 
-// Waits for at least one ARP entry on any OTG interface
-func waitOTGARPEntry(t *testing.T) {
-	ate := ondatra.ATE(t, "ate")
-	ate.OTG().Telemetry().InterfaceAny().Ipv4NeighborAny().LinkLayerAddress().Watch(
-		t, time.Minute, func(val *otgtelemetry.QualifiedString) bool {
-			return val.IsPresent()
-		}).Await(t)
-}
-
 // testTraffic generates traffic flow from source network to
 // destination network via ate:port1 to ate:port2 and checks for
-// packet loss.
-
-func testTraffic(
-	t *testing.T,
-	ate *ondatra.ATEDevice,
-	top gosnappi.Config,
-) {
-	otg := ate.OTG()
-	flowName := "Flow"
-	waitOTGARPEntry(t)
-	dstMac := otg.Telemetry().Interface(ateSrc.Name + ".Eth").Ipv4Neighbor(dutSrc.IPv4).LinkLayerAddress().Get(t)
+// packet loss and forwarding latency.
+func testTraffic(t *testing.T, ate *ondatra.ATEDevice, top gosnappi.Config) {
 	top.Flows().Clear().Items()
-	flowipv4 := top.Flows().Add().SetName("Flow")
-	flowipv4.Metrics().SetEnable(true)
-	flowipv4.TxRx().Port().
-		SetTxName(ate.Port(t, "port1").ID()).
-		SetRxName(ate.Port(t, "port2").ID())
-	flowipv4.Duration().SetChoice("continuous")
-	e1 := flowipv4.Packet().Add().Ethernet()
-	e1.Src().SetValue(ateSrc.MAC)
-	e1.Dst().SetChoice("value").SetValue(dstMac)
-	v4 := flowipv4.Packet().Add().Ipv4()
-	v4.Src().SetValue(ateSrc.IPv4)
-	v4.Dst().Increment().SetStart(ateDstNetStartIp).SetCount(ateDstNetAddressCount)
-	otg.PushConfig(t, top)
-
-	otg.StartTraffic(t)
-	time.Sleep(15 * time.Second)
-	t.Logf("Stop traffic")
-	otg.StopTraffic(t)
-
-	otgutils.LogFlowMetrics(t, otg, top)
-	txPkts := otg.Telemetry().Flow("Flow").Counters().OutPkts().Get(t)
-	rxPkts := otg.Telemetry().Flow("Flow").Counters().InPkts().Get(t)
-
-	if got := (txPkts - rxPkts) * 100 / txPkts; got > 0 {
-		t.Errorf("LossPct for flow %s got %v, want 0", flowName, got)
-	}
-}
-
-// awaitTimeout calls a fluent client Await, adding a timeout to the context.
-func awaitTimeout(ctx context.Context, c *fluent.GRIBIClient, t testing.TB) error {
-	subctx, cancel := context.WithTimeout(ctx, awaitDuration)
-	defer cancel()
-	return c.Await(subctx, t)
+	traffic.AddOTGIPv4Flow(t, ate, top, &traffic.OTGFlowParams{
+		Name:     flowName,
+		Src:      &ateSrc,
+		Dst:      &ateDst,
+		SrcPort:  ate.Port(t, "port1"),
+		DstPort:  ate.Port(t, "port2"),
+		Gateway:  &dutSrc,
+		DstStart: ateDstNetStartIp,
+		DstCount: ateDstNetAddressCount,
+	})
+	ate.OTG().PushConfig(t, top)
+	ate.OTG().StartProtocols(t)
+	traffic.ValidateOTGFlow(t, ate, top, flowName, &traffic.Options{MaxAvgLatency: *maxAvgLatency, Retry: true})
 }
 
 // testArgs holds the objects needed by a test case.
 type testArgs struct {
 	ctx           context.Context
 	c             *fluent.GRIBIClient
+	ops           *gribi.OpIDs
 	dut           *ondatra.DUTDevice
 	ate           *ondatra.ATEDevice
 	top           gosnappi.Config
@@ -236,6 +186,7 @@ type testCaseFunc func(t *testing.T, args *testArgs)
 
 // testModifyNHG configures a NextHopGroup referencing a NextHop.
 func testModifyNHG(t *testing.T, args *testArgs) {
+	ops := args.ops.Next(2)
 	args.c.Modify().AddEntry(t,
 		fluent.NextHopEntry().
 			WithNetworkInstance(*deviations.DefaultNetworkInstance).
@@ -246,14 +197,14 @@ func testModifyNHG(t *testing.T, args *testArgs) {
 			WithID(nhgIndex).
 			AddNextHop(nhIndex, nhWeight),
 	)
-	if err := awaitTimeout(args.ctx, args.c, t); err != nil {
+	if err := gribi.Await(args.ctx, t, args.c, nil, ops...); err != nil {
 		t.Errorf("Await got error for ModifyRequest: %v", err)
 	}
 
 	res := args.c.Results(t)
 	chk.HasResult(t, res,
 		fluent.OperationResult().
-			WithOperationID(1).
+			WithOperationID(ops[0]).
 			WithOperationType(constants.Add).
 			WithNextHopOperation(nhIndex).
 			WithProgrammingResult(args.wantInstalled).
@@ -261,7 +212,7 @@ func testModifyNHG(t *testing.T, args *testArgs) {
 	)
 	chk.HasResult(t, res,
 		fluent.OperationResult().
-			WithOperationID(2).
+			WithOperationID(ops[1]).
 			WithOperationType(constants.Add).
 			WithNextHopGroupOperation(nhgIndex).
 			WithProgrammingResult(args.wantInstalled).
@@ -273,11 +224,11 @@ func testModifyNHG(t *testing.T, args *testArgs) {
 			t.Skip()
 		}
 		nhgNhPath := args.dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).Afts().NextHopGroup(nhgIndex).NextHop(nhIndex)
-		if got, want := nhgNhPath.Index().Get(t), uint64(nhIndex); got != want {
-			t.Errorf("next-hop-group/next-hop/state/index got %d, want %d", got, want)
-		}
-		if got, want := nhgNhPath.Weight().Get(t), uint64(nhWeight); got != want {
-			t.Errorf("next-hop-group/next-hop/state/weight got %d, want %d", got, want)
+		if err := tcheck.CheckAll(t,
+			tcheck.Equal(nhgNhPath.Index(), uint64(nhIndex)),
+			tcheck.Equal(nhgNhPath.Weight(), uint64(nhWeight)),
+		); err != nil {
+			t.Error(err)
 		}
 	})
 }
@@ -285,6 +236,7 @@ func testModifyNHG(t *testing.T, args *testArgs) {
 // testModifyIPv4NHG configures a ModifyRequest with a NextHop and an IPv4Entry before a
 // NextHopGroup which is invalid due to the forward reference.
 func testModifyIPv4NHG(t *testing.T, args *testArgs) {
+	ops := args.ops.Next(3)
 	args.c.Modify().AddEntry(t,
 		fluent.NextHopEntry().
 			WithNetworkInstance(*deviations.DefaultNetworkInstance).
@@ -299,14 +251,14 @@ func testModifyIPv4NHG(t *testing.T, args *testArgs) {
 			WithID(nhgIndex).
 			AddNextHop(nhIndex, nhWeight),
 	)
-	if err := awaitTimeout(args.ctx, args.c, t); err != nil {
+	if err := gribi.Await(args.ctx, t, args.c, nil, ops...); err != nil {
 		t.Fatalf("Await got error for ModifyRequest: %v", err)
 	}
 
 	res := args.c.Results(t)
 	chk.HasResult(t, res,
 		fluent.OperationResult().
-			WithOperationID(2).
+			WithOperationID(ops[1]).
 			WithOperationType(constants.Add).
 			WithIPv4Operation(ateDstNetCIDR).
 			WithProgrammingResult(fluent.ProgrammingFailed).
@@ -316,6 +268,7 @@ func testModifyIPv4NHG(t *testing.T, args *testArgs) {
 
 // testModifyNHGIPv4 configures a ModifyRequest with a NextHopGroup and IPv4Entry.
 func testModifyNHGIPv4(t *testing.T, args *testArgs) {
+	ops := args.ops.Next(3)
 	args.c.Modify().AddEntry(t,
 		fluent.NextHopEntry().
 			WithNetworkInstance(*deviations.DefaultNetworkInstance).
@@ -330,14 +283,14 @@ func testModifyNHGIPv4(t *testing.T, args *testArgs) {
 			WithPrefix(ateDstNetCIDR).
 			WithNextHopGroup(nhgIndex),
 	)
-	if err := awaitTimeout(args.ctx, args.c, t); err != nil {
+	if err := gribi.Await(args.ctx, t, args.c, nil, ops...); err != nil {
 		t.Fatalf("Await got error for ModifyRequest: %v", err)
 	}
 
 	res := args.c.Results(t)
 	chk.HasResult(t, res,
 		fluent.OperationResult().
-			WithOperationID(1).
+			WithOperationID(ops[0]).
 			WithOperationType(constants.Add).
 			WithNextHopOperation(nhIndex).
 			WithProgrammingResult(args.wantInstalled).
@@ -345,7 +298,7 @@ func testModifyNHGIPv4(t *testing.T, args *testArgs) {
 	)
 	chk.HasResult(t, res,
 		fluent.OperationResult().
-			WithOperationID(2).
+			WithOperationID(ops[1]).
 			WithOperationType(constants.Add).
 			WithNextHopGroupOperation(nhgIndex).
 			WithProgrammingResult(args.wantInstalled).
@@ -353,7 +306,7 @@ func testModifyNHGIPv4(t *testing.T, args *testArgs) {
 	)
 	chk.HasResult(t, res,
 		fluent.OperationResult().
-			WithOperationID(3).
+			WithOperationID(ops[2]).
 			WithOperationType(constants.Add).
 			WithIPv4Operation(ateDstNetCIDR).
 			WithProgrammingResult(args.wantInstalled).
@@ -364,20 +317,16 @@ func testModifyNHGIPv4(t *testing.T, args *testArgs) {
 		if !*checkTelemetry {
 			t.Skip()
 		}
-		nhgNhPath := args.dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).Afts().NextHopGroup(nhgIndex).NextHop(nhIndex)
-		if got, want := nhgNhPath.Index().Get(t), uint64(nhIndex); got != want {
-			t.Errorf("next-hop-group/next-hop/state/index got %d, want %d", got, want)
-		}
-		if got, want := nhgNhPath.Weight().Get(t), uint64(nhWeight); got != want {
-			t.Errorf("next-hop-group/next-hop/state/weight got %d, want %d", got, want)
-		}
-
-		ipv4Path := args.dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).Afts().Ipv4Entry(ateDstNetCIDR)
-		if got, want := ipv4Path.NextHopGroup().Get(t), uint64(nhgIndex); got != want {
-			t.Errorf("ipv4-entry/state/next-hop-group got %d, want %d", got, want)
-		}
-		if got, want := ipv4Path.Prefix().Get(t), ateDstNetCIDR; got != want {
-			t.Errorf("ipv4-entry/state/prefix got %s, want %s", got, want)
+		afts := args.dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).Afts()
+		nhgNhPath := afts.NextHopGroup(nhgIndex).NextHop(nhIndex)
+		ipv4Path := afts.Ipv4Entry(ateDstNetCIDR)
+		if err := tcheck.CheckAll(t,
+			tcheck.Equal(nhgNhPath.Index(), uint64(nhIndex)),
+			tcheck.Equal(nhgNhPath.Weight(), uint64(nhWeight)),
+			tcheck.Equal(ipv4Path.NextHopGroup(), uint64(nhgIndex)),
+			tcheck.Equal(ipv4Path.Prefix(), ateDstNetCIDR),
+		); err != nil {
+			t.Error(err)
 		}
 	})
 
@@ -389,25 +338,26 @@ func testModifyNHGIPv4(t *testing.T, args *testArgs) {
 // testModifyIPv4AddDelAdd configures a ModifyRequest with AFT operations to add, delete,
 // and add IPv4Entry.
 func testModifyIPv4AddDelAdd(t *testing.T, args *testArgs) {
-	testModifyNHG(t, args) // Uses operation IDs 1 and 2.
+	testModifyNHG(t, args)
 
 	ent := fluent.IPv4Entry().
 		WithNetworkInstance(*deviations.DefaultNetworkInstance).
 		WithPrefix(ateDstNetCIDR).
 		WithNextHopGroup(nhgIndex)
 
+	ops := args.ops.Next(3)
 	args.c.Modify().
 		AddEntry(t, ent).
 		DeleteEntry(t, ent).
 		AddEntry(t, ent)
-	if err := awaitTimeout(args.ctx, args.c, t); err != nil {
+	if err := gribi.Await(args.ctx, t, args.c, nil, ops...); err != nil {
 		t.Fatalf("Await got error for ModifyRequest: %v", err)
 	}
 
 	res := args.c.Results(t)
 	chk.HasResult(t, res,
 		fluent.OperationResult().
-			WithOperationID(3).
+			WithOperationID(ops[0]).
 			WithOperationType(constants.Add).
 			WithIPv4Operation(ateDstNetCIDR).
 			WithProgrammingResult(args.wantInstalled).
@@ -415,7 +365,7 @@ func testModifyIPv4AddDelAdd(t *testing.T, args *testArgs) {
 	)
 	chk.HasResult(t, res,
 		fluent.OperationResult().
-			WithOperationID(4).
+			WithOperationID(ops[1]).
 			WithOperationType(constants.Delete).
 			WithIPv4Operation(ateDstNetCIDR).
 			WithProgrammingResult(args.wantInstalled).
@@ -423,7 +373,7 @@ func testModifyIPv4AddDelAdd(t *testing.T, args *testArgs) {
 	)
 	chk.HasResult(t, res,
 		fluent.OperationResult().
-			WithOperationID(5).
+			WithOperationID(ops[2]).
 			WithOperationType(constants.Add).
 			WithIPv4Operation(ateDstNetCIDR).
 			WithProgrammingResult(args.wantInstalled).
@@ -435,11 +385,11 @@ func testModifyIPv4AddDelAdd(t *testing.T, args *testArgs) {
 			t.Skip()
 		}
 		ipv4Path := args.dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).Afts().Ipv4Entry(ateDstNetCIDR)
-		if got, want := ipv4Path.NextHopGroup().Get(t), uint64(nhgIndex); got != want {
-			t.Errorf("ipv4-entry/state/next-hop-group got %d, want %d", got, want)
-		}
-		if got, want := ipv4Path.Prefix().Get(t), ateDstNetCIDR; got != want {
-			t.Errorf("ipv4-entry/state/prefix got %s, want %s", got, want)
+		if err := tcheck.CheckAll(t,
+			tcheck.Equal(ipv4Path.NextHopGroup(), uint64(nhgIndex)),
+			tcheck.Equal(ipv4Path.Prefix(), ateDstNetCIDR),
+		); err != nil {
+			t.Error(err)
 		}
 	})
 
@@ -528,7 +478,7 @@ func TestOrderingACK(t *testing.T) {
 					c.Start(ctx, t)
 					defer c.Stop(t)
 					c.StartSending(ctx, t)
-					if err := awaitTimeout(ctx, c, t); err != nil {
+					if err := gribi.Await(ctx, t, c, nil); err != nil {
 						t.Fatalf("Await got error during session negotiation: %v", err)
 					}
 
@@ -544,7 +494,7 @@ func TestOrderingACK(t *testing.T) {
 						}()
 					}
 
-					args := &testArgs{ctx: ctx, c: c, dut: dut, ate: ate, top: top}
+					args := &testArgs{ctx: ctx, c: c, ops: &gribi.OpIDs{}, dut: dut, ate: ate, top: top}
 					args.wantInstalled = fluent.InstalledInFIB
 					if *deviations.GRIBIRIBAckOnly {
 						args.wantInstalled = fluent.InstalledInRIB
@@ -0,0 +1,212 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ttl_decrement_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/layers"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/gribi"
+	"github.com/openconfig/featureprofiles/internal/otgutils"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/gribigo/fluent"
+	"github.com/openconfig/ondatra"
+	otgtelemetry "github.com/openconfig/ondatra/telemetry/otg"
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1 and
+// dut:port2 -> ate:port2.
+//
+//   - ate:port1 -> dut:port1 subnet 192.0.2.0/30
+//   - ate:port2 -> dut:port2 subnet 192.0.2.4/30
+//
+// Traffic to the destination network 198.51.100.0/24 is forwarded by a
+// gRIBI route to ate:port2, where it is captured.
+const (
+	ipv4PrefixLen = 30
+	dstCIDR       = "198.51.100.0/24"
+	dstStart      = "198.51.100.1"
+	dstCount      = 250
+	nhIndex       = 1
+	nhgIndex      = 42
+
+	flowName    = "Flow"
+	flowPackets = 1000
+	flowPPS     = 100
+	flowTTL     = 64
+	flowDSCP    = 46
+)
+
+var (
+	dutPort1 = attrs.Attributes{
+		Desc:    "dutPort1",
+		IPv4:    "192.0.2.1",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort1 = attrs.Attributes{
+		Name:    "atePort1",
+		MAC:     "02:00:01:01:01:01",
+		IPv4:    "192.0.2.2",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	dutPort2 = attrs.Attributes{
+		Desc:    "dutPort2",
+		IPv4:    "192.0.2.5",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort2 = attrs.Attributes{
+		Name:    "atePort2",
+		MAC:     "02:00:02:01:01:01",
+		IPv4:    "192.0.2.6",
+		IPv4Len: ipv4PrefixLen,
+	}
+)
+
+// configureDUT configures port1 and port2 on the DUT.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	d := dut.Config()
+
+	p1 := dut.Port(t, "port1")
+	d.Interface(p1.Name()).Replace(t, dutPort1.NewInterface(p1.Name()))
+
+	p2 := dut.Port(t, "port2")
+	d.Interface(p2.Name()).Replace(t, dutPort2.NewInterface(p2.Name()))
+}
+
+// configureATE configures port1 and port2 on the ATE.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice) gosnappi.Config {
+	top := ate.OTG().NewConfig(t)
+	atePort1.AddToOTG(top, ate.Port(t, "port1"), &dutPort1)
+	atePort2.AddToOTG(top, ate.Port(t, "port2"), &dutPort2)
+	return top
+}
+
+// addFlow adds a flow of UDP packets from ate:port1 to the destination
+// network, with a fixed TTL and DSCP.
+func addFlow(t *testing.T, ate *ondatra.ATEDevice, top gosnappi.Config) {
+	otg := ate.OTG()
+	otg.Telemetry().Interface(atePort1.Name+".Eth").Ipv4Neighbor(dutPort1.IPv4).LinkLayerAddress().Watch(
+		t, time.Minute, func(val *otgtelemetry.QualifiedString) bool {
+			return val.IsPresent()
+		}).Await(t)
+	dstMac := otg.Telemetry().Interface(atePort1.Name + ".Eth").Ipv4Neighbor(dutPort1.IPv4).LinkLayerAddress().Get(t)
+
+	top.Flows().Clear().Items()
+	flow := top.Flows().Add().SetName(flowName)
+	flow.Metrics().SetEnable(true)
+	flow.TxRx().Port().
+		SetTxName(ate.Port(t, "port1").ID()).
+		SetRxName(ate.Port(t, "port2").ID())
+	flow.Duration().FixedPackets().SetPackets(flowPackets)
+	flow.Rate().SetPps(flowPPS)
+	eth := flow.Packet().Add().Ethernet()
+	eth.Src().SetValue(atePort1.MAC)
+	eth.Dst().SetValue(dstMac)
+	v4 := flow.Packet().Add().Ipv4()
+	v4.Src().SetValue(atePort1.IPv4)
+	v4.Dst().Increment().SetStart(dstStart).SetCount(dstCount)
+	v4.TimeToLive().SetValue(flowTTL)
+	v4.Priority().Dscp().Phb().SetValue(flowDSCP)
+	flow.Packet().Add().Udp()
+}
+
+// checkCapture checks that the packets received by ate:port2 for the
+// destination network had their TTL decremented once and their DSCP
+// preserved.
+func checkCapture(t *testing.T, pkts []*traffic.Packet) {
+	_, dstNet, err := net.ParseCIDR(dstCIDR)
+	if err != nil {
+		t.Fatalf("Cannot parse %s: %v", dstCIDR, err)
+	}
+	var matched int
+	for _, p := range pkts {
+		hdr := p.Outer()
+		if hdr == nil || !dstNet.Contains(net.ParseIP(hdr.Dst)) {
+			continue
+		}
+		matched++
+		if hdr.TTL != flowTTL-1 {
+			t.Errorf("Packet to %s got TTL %d, want %d", hdr.Dst, hdr.TTL, flowTTL-1)
+		}
+		if hdr.DSCP != flowDSCP {
+			t.Errorf("Packet to %s got DSCP %d, want %d", hdr.Dst, hdr.DSCP, flowDSCP)
+		}
+		if hdr.Src != atePort1.IPv4 || hdr.Protocol != layers.IPProtocolUDP {
+			t.Errorf("Packet header got %+v, want src %s, protocol %v", hdr, atePort1.IPv4, layers.IPProtocolUDP)
+		}
+	}
+	t.Logf("Captured %d packets to %s", matched, dstCIDR)
+	if matched == 0 {
+		t.Errorf("Captured no packets to %s", dstCIDR)
+	}
+}
+
+func TestTTLDecrement(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	ate := ondatra.ATE(t, "ate")
+	otg := ate.OTG()
+
+	configureDUT(t, dut)
+	top := configureATE(t, ate)
+	capture := traffic.NewCapture(t, ate, top, ate.Port(t, "port2"))
+	otg.PushConfig(t, top)
+	otg.StartProtocols(t)
+
+	wantInstalled := fluent.InstalledInFIB
+	if *deviations.GRIBIRIBAckOnly {
+		wantInstalled = fluent.InstalledInRIB
+	}
+	c := &gribi.Client{
+		DUT:                  dut,
+		FibACK:               !*deviations.GRIBIRIBAckOnly,
+		Persistence:          true,
+		InitialElectionIDLow: 10,
+	}
+	defer c.Close(t)
+	if err := c.Start(t); err != nil {
+		t.Fatalf("gRIBI connection could not be established: %v", err)
+	}
+	c.BecomeLeader(t)
+	defer c.Flush(t)
+
+	t.Logf("Program %s via a next hop to %s.", dstCIDR, atePort2.IPv4)
+	c.AddNH(t, nhIndex, atePort2.IPv4, *deviations.DefaultNetworkInstance, wantInstalled)
+	c.AddNHG(t, nhgIndex, map[uint64]uint64{nhIndex: 1}, *deviations.DefaultNetworkInstance, wantInstalled)
+	c.AddIPv4(t, dstCIDR, nhgIndex, *deviations.DefaultNetworkInstance, "", wantInstalled)
+
+	addFlow(t, ate, top)
+	otg.PushConfig(t, top)
+	otg.StartProtocols(t)
+
+	pkts := capture.Run(t, flowPackets/flowPPS*time.Second+5*time.Second)
+	otgutils.LogFlowMetrics(t, otg, top)
+	checkCapture(t, pkts)
+}
@@ -20,8 +20,8 @@ import (
 	"testing"
 	"time"
 
-	"github.com/google/go-cmp/cmp"
 	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/openconfig/featureprofiles/internal/traffic"
 	"github.com/openconfig/gribigo/chk"
 	"github.com/openconfig/gribigo/fluent"
 	"github.com/openconfig/ondatra"
@@ -37,18 +37,17 @@ func nextHopsEvenly(atePorts []*ondatra.Port) []nextHop {
 		if ateid == ateSrcPort {
 			continue
 		}
-		nexthops = append(nexthops, nextHop{ateid, 1, 0})
+		nexthops = append(nexthops, nextHop{ateid, 1})
 	}
 	return nexthops
 }
 
-// portWantsEvenly generates wanted weights assuming that the traffic
+// portWeightsEvenly generates wanted weights assuming that the traffic
 // should be evenly distributed across the ports that are still up.
-func portWantsEvenly(atePorts []*ondatra.Port, numUps int) []float64 {
-	weights := make([]float64, len(atePorts))
-	x := 1.0 / float64(numUps)
+func portWeightsEvenly(atePorts []*ondatra.Port, numUps int) []uint64 {
+	weights := make([]uint64, len(atePorts))
 	for i := 1; i <= numUps; i++ {
-		weights[i] = x
+		weights[i] = 1
 	}
 	return weights
 }
@@ -66,19 +65,12 @@ func testNextHopRemaining(
 	t.Logf("inPkts = %v", inPkts)
 	t.Logf("outPkts = %v", outPkts)
 
-	got, inSum := normalize(inPkts)
-	want := portWantsEvenly(atePorts, numUps)
-	t.Logf("inPkts normalized got: %v", got)
-	t.Logf("weights want: %v", want)
-
 	// Report diagnosis.
 	t.Run("Ratio", func(t *testing.T) {
-		if diff := cmp.Diff(want, got, approxOpt); diff != "" {
-			t.Errorf("Packet distribution ratios -want,+got:\n%s", diff)
-		}
+		traffic.ReportDistribution(t, portIDs(atePorts), nil, inPkts, portWeightsEvenly(atePorts, numUps), ratioTolerancePct)
 	})
 	t.Run("Loss", func(t *testing.T) {
-		if outPkts[0] > inSum {
+		if inSum := sum(inPkts); outPkts[0] > inSum {
 			t.Errorf("Traffic flow sent %d packets, received only %d",
 				outPkts[0], inSum)
 		}
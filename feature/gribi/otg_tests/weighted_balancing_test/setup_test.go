@@ -145,8 +145,6 @@ type nextHop struct {
 	// gRIBI weight set for the next hop.  If 0, defaults to 1. See:
 	// https://github.com/openconfig/autobahn/issues/10
 	Weight uint64
-
-	Want float64 // expected traffic distribution (approximate).
 }
 
 // dutInterface builds a DUT interface ygot struct for a given port
@@ -380,18 +378,13 @@ func generateTraffic(t *testing.T, ate *ondatra.ATEDevice, config gosnappi.Confi
 	return atePorts, inPkts, outPkts
 }
 
-// normalize normalizes the input values so that the output values sum
-// to 1.0 but reflect the proportions of the input.  For example,
-// input [1, 2, 3, 4] is normalized to [0.1, 0.2, 0.3, 0.4].
-func normalize(xs []uint64) (ys []float64, sum uint64) {
+// sum returns the sum of the packet counters.
+func sum(xs []uint64) uint64 {
+	var total uint64
 	for _, x := range xs {
-		sum += x
-	}
-	ys = make([]float64, len(xs))
-	for i, x := range xs {
-		ys[i] = float64(x) / float64(sum)
+		total += x
 	}
-	return ys, sum
+	return total
 }
 
 // generates a list of random tcp ports values
@@ -403,18 +396,31 @@ func generateRandomPortList(count int) []int32 {
 	return a
 }
 
-// portWants converts the nextHop wanted weights to per-port wanted
-// weights listed in the same order as atePorts.
-func portWants(nexthops []nextHop, atePorts []*ondatra.Port) []float64 {
+// portIDs returns the IDs of the atePorts.
+func portIDs(atePorts []*ondatra.Port) []string {
+	ids := make([]string, len(atePorts))
+	for i, ap := range atePorts {
+		ids[i] = ap.ID()
+	}
+	return ids
+}
+
+// portWeights converts the nextHop weights to per-port wanted weights
+// listed in the same order as atePorts.  Ports without a next hop get a
+// zero weight.
+func portWeights(nexthops []nextHop, atePorts []*ondatra.Port) []uint64 {
 	indexOfPort := make(map[string]int)
 	for i, ap := range atePorts {
 		indexOfPort["ate:"+ap.ID()] = i
 	}
 
-	weights := make([]float64, len(atePorts))
+	weights := make([]uint64, len(atePorts))
 	for _, nh := range nexthops {
 		if i, ok := indexOfPort[nh.Port]; ok {
-			weights[i] = nh.Want
+			weights[i] = nh.Weight
+			if weights[i] == 0 {
+				weights[i] = 1
+			}
 		}
 	}
 
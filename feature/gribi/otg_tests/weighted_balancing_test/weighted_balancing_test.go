@@ -19,10 +19,9 @@ import (
 	"testing"
 	"time"
 
-	"github.com/google/go-cmp/cmp"
-	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/open-traffic-generator/snappi/gosnappi"
 
+	"github.com/openconfig/featureprofiles/internal/traffic"
 	"github.com/openconfig/gribigo/chk"
 	"github.com/openconfig/gribigo/fluent"
 	"github.com/openconfig/ondatra"
@@ -41,21 +40,21 @@ var (
 		{
 			TestName:    "OneNextHop",
 			Description: "With NHG 10 containing 1 next hop, 100% of traffic is forwarded to the installed next-hop.",
-			NextHops:    []nextHop{{"ate:port2", 0, 1.0}},
+			NextHops:    []nextHop{{"ate:port2", 0}},
 		},
 		{
 			TestName:    "TwoNextHops",
 			Description: "With NHG 10 containing 2 next hops with no associated weights assigned, 50% of traffic is forwarded to each next-hop.",
-			NextHops:    []nextHop{{"ate:port2", 0, 0.5}, {"ate:port3", 0, 0.5}},
+			NextHops:    []nextHop{{"ate:port2", 0}, {"ate:port3", 0}},
 		},
 		{
 			TestName:    "EightNextHops",
 			Description: "With NHG 10 containing 8 next hops, with no associated weights assigned, 12.5% of traffic is forwarded to each next-hop.",
 			NextHops: []nextHop{
-				{"ate:port2", 0, 0.125}, {"ate:port3", 0, 0.125},
-				{"ate:port4", 0, 0.125}, {"ate:port5", 0, 0.125},
-				{"ate:port6", 0, 0.125}, {"ate:port7", 0, 0.125},
-				{"ate:port8", 0, 0.125}, {"ate:port9", 0, 0.125},
+				{"ate:port2", 0}, {"ate:port3", 0},
+				{"ate:port4", 0}, {"ate:port5", 0},
+				{"ate:port6", 0}, {"ate:port7", 0},
+				{"ate:port8", 0}, {"ate:port9", 0},
 			},
 		},
 
@@ -64,27 +63,27 @@ var (
 		{
 			TestName:    "Weight_1_1",
 			Description: "Weight 1:1 - 50% per-NH.",
-			NextHops:    []nextHop{{"ate:port2", 1, 0.5}, {"ate:port3", 1, 0.5}},
+			NextHops:    []nextHop{{"ate:port2", 1}, {"ate:port3", 1}},
 		},
 		{
 			TestName:    "Weight_2_1",
 			Description: "Weight 2:1 - 66% traffic to NH1, 33% to NH2.",
-			NextHops:    []nextHop{{"ate:port2", 2, 0.6667}, {"ate:port3", 1, 0.3333}},
+			NextHops:    []nextHop{{"ate:port2", 2}, {"ate:port3", 1}},
 		},
 		{
 			TestName:    "Weight_9_1",
 			Description: "Weight 9:1 - 90% traffic to NH1, 10% to NH2.",
-			NextHops:    []nextHop{{"ate:port2", 9, 0.9}, {"ate:port3", 1, 0.1}},
+			NextHops:    []nextHop{{"ate:port2", 9}, {"ate:port3", 1}},
 		},
 		{
 			TestName:    "Weight_31_1",
 			Description: "Weight 31:1 - ~96.9% traffic to NH1, ~3.1% to NH2.",
-			NextHops:    []nextHop{{"ate:port2", 31, 0.96875}, {"ate:port3", 1, 0.03125}},
+			NextHops:    []nextHop{{"ate:port2", 31}, {"ate:port3", 1}},
 		},
 		{
 			TestName:    "Weight_63_1",
 			Description: "Weight 63:1 - ~98.4% traffic to NH1, ~1.6% to NH2.",
-			NextHops:    []nextHop{{"ate:port2", 63, 0.984375}, {"ate:port3", 1, 0.015625}},
+			NextHops:    []nextHop{{"ate:port2", 63}, {"ate:port3", 1}},
 		},
 	}
 
@@ -103,7 +102,10 @@ var (
 	}
 )
 
-var approxOpt = cmpopts.EquateApprox(0 /* frac */, 0.01 /* absolute */)
+// ratioTolerancePct is the maximum deviation in percentage points of
+// the fraction of traffic received on each port from the expected
+// fraction.
+const ratioTolerancePct = 1
 
 // testNextHop performs traffic test according to the next hop configuration.
 func testNextHop(
@@ -163,19 +165,12 @@ func testNextHop(
 	t.Logf("inPkts = %v", inPkts)
 	t.Logf("outPkts = %v", outPkts)
 
-	got, inSum := normalize(inPkts)
-	want := portWants(nexthops, atePorts)
-	t.Logf("inPkts normalized got: %v", got)
-	t.Logf("weights want: %v", want)
-
 	// Report diagnosis.
 	t.Run("Ratio", func(t *testing.T) {
-		if diff := cmp.Diff(want, got, approxOpt); diff != "" {
-			t.Errorf("Packet distribution ratios -want,+got:\n%s", diff)
-		}
+		traffic.ReportDistribution(t, portIDs(atePorts), nil, inPkts, portWeights(nexthops, atePorts), ratioTolerancePct)
 	})
 	t.Run("Loss", func(t *testing.T) {
-		if outPkts[0] > inSum {
+		if inSum := sum(inPkts); outPkts[0] > inSum {
 			t.Errorf("Traffic flow sent %d packets, received only %d",
 				outPkts[0], inSum)
 		}
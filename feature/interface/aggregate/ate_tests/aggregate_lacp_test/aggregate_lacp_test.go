@@ -0,0 +1,312 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aggregate_lacp_test
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/netutil"
+	"github.com/openconfig/ondatra/telemetry"
+)
+
+var (
+	maxRemovalOutage = flag.Duration("max_member_removal_outage", time.Second,
+		"Maximum traffic outage after the DUT removes a member from the aggregate, in excess of the time it took to remove it.")
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the aggregate testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1 and an aggregate of
+// dut:port{2-3} -> ate:port{2-3} running LACP.
+//
+//   - ate:port1 -> dut:port1 subnet 192.0.2.0/30 2001:db8::0/126
+//   - ate:port{2-3} -> dut:port{2-3} subnet 192.0.2.4/30 2001:db8::4/126
+//
+// Traffic is sent from ate:port1 to the ATE aggregate, varying the L4
+// source port so that the DUT hashes it across the members.
+const (
+	ipv4PrefixLen = 30
+	ipv6PrefixLen = 126
+
+	// lacpTimeout is how long to wait for the DUT to add a member to, or
+	// remove it from, the aggregate.  It allows for the LACP partner
+	// timeout of the slow period, 3 times 30 seconds.
+	lacpTimeout = 2 * time.Minute
+	// operStatusTimeout is how long to wait for the oper-status of the
+	// aggregate to reflect a change of its members.
+	operStatusTimeout = time.Minute
+	// balanceTolerancePct is the maximum deviation in percentage points
+	// of the fraction of traffic received on each member from the
+	// expected fraction.
+	balanceTolerancePct = 10
+)
+
+var (
+	dutPort1 = attrs.Attributes{
+		Desc:    "dutPort1",
+		IPv4:    "192.0.2.1",
+		IPv6:    "2001:db8::1",
+		IPv4Len: ipv4PrefixLen,
+		IPv6Len: ipv6PrefixLen,
+	}
+
+	atePort1 = attrs.Attributes{
+		Name:    "atePort1",
+		IPv4:    "192.0.2.2",
+		IPv6:    "2001:db8::2",
+		IPv4Len: ipv4PrefixLen,
+		IPv6Len: ipv6PrefixLen,
+	}
+
+	dutAgg = attrs.Attributes{
+		Desc:    "dutAgg",
+		IPv4:    "192.0.2.5",
+		IPv6:    "2001:db8::5",
+		IPv4Len: ipv4PrefixLen,
+		IPv6Len: ipv6PrefixLen,
+	}
+
+	ateAgg = attrs.Attributes{
+		Name:    "ateAgg",
+		IPv4:    "192.0.2.6",
+		IPv6:    "2001:db8::6",
+		IPv4Len: ipv4PrefixLen,
+		IPv6Len: ipv6PrefixLen,
+	}
+
+	memberIDs = []string{"port2", "port3"}
+
+	entropy = &traffic.Entropy{Protocol: traffic.UDP}
+
+	// distributionOpts measure the distribution of the traffic across
+	// members once it settled after a change.
+	distributionOpts = &traffic.DistributionOptions{
+		Settle:    true,
+		MinFrames: entropy.MinFrames(balanceTolerancePct / 100.0),
+	}
+)
+
+// configureDUT configures port1 and a LACP aggregate of port2 and
+// port3 on the DUT, and returns the aggregate.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) *attrs.Aggregate {
+	p1 := dut.Port(t, "port1")
+	i1 := dutPort1.NewInterface(p1.Name())
+	dut.Config().Interface(p1.Name()).Replace(t, i1)
+
+	agg := &attrs.Aggregate{
+		ID:           netutil.NextBundleInterface(t, dut),
+		LagType:      telemetry.IfAggregate_AggregationType_LACP,
+		LACPInterval: telemetry.Lacp_LacpPeriodType_FAST,
+	}
+	for _, id := range memberIDs {
+		agg.Members = append(agg.Members, dut.Port(t, id).Name())
+	}
+	dutAgg.ConfigureAggregate(t, dut, agg)
+	return agg
+}
+
+// configureATE configures port1 and a LACP LAG of port2 and port3 on
+// the ATE, and waits for the DUT to resolve port1 and to bring the
+// aggregate up.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice, dut *ondatra.DUTDevice, agg *attrs.Aggregate) *ondatra.ATETopology {
+	top := ate.Topology().New()
+	atePort1.AddToATE(top, ate.Port(t, "port1"), &dutPort1)
+	var aps []*ondatra.Port
+	for _, id := range memberIDs {
+		aps = append(aps, ate.Port(t, id))
+	}
+	ateAgg.AddLAGToATE(top, aps, &dutAgg, true)
+	traffic.StartProtocolsAndAwait(t, ate, top, &traffic.Readiness{
+		DUT:       dut,
+		Neighbors: map[string]*attrs.Attributes{"port1": &atePort1},
+	})
+	if !fptest.Await(t, dut.Telemetry().Interface(agg.ID).OperStatus().Watch, lacpTimeout, telemetry.Interface_OperStatus_UP) {
+		t.Fatalf("DUT aggregate %s oper-status is not UP within %v", agg.ID, lacpTimeout)
+	}
+	return top
+}
+
+// verifyMember checks the LACP state of the DUT member port: it must
+// be in sync and distributing, actively running LACP, and have the ATE
+// LAG as its partner.  It returns the member state.
+func verifyMember(t *testing.T, dut *ondatra.DUTDevice, agg *attrs.Aggregate, name string) *telemetry.Lacp_Interface_Member {
+	t.Helper()
+	mp := dut.Telemetry().Lacp().Interface(agg.ID).Member(name)
+	if !fptest.Await(t, mp.Synchronization().Watch, lacpTimeout, telemetry.Lacp_LacpSynchronizationType_IN_SYNC) {
+		t.Errorf("DUT member %s is not IN_SYNC within %v", name, lacpTimeout)
+	}
+	m := mp.Get(t)
+	fptest.LogYgot(t, "LACP member "+name, mp, m)
+
+	if got, want := m.GetActivity(), telemetry.Lacp_LacpActivityType_ACTIVE; got != want {
+		t.Errorf("DUT member %s activity got %v, want %v", name, got, want)
+	}
+	if !m.GetAggregatable() {
+		t.Errorf("DUT member %s aggregatable got false, want true", name)
+	}
+	if !m.GetCollecting() {
+		t.Errorf("DUT member %s collecting got false, want true", name)
+	}
+	if !m.GetDistributing() {
+		t.Errorf("DUT member %s distributing got false, want true", name)
+	}
+	if m.GetPartnerId() == "" {
+		t.Errorf("DUT member %s has no partner-id", name)
+	}
+	if m.GetPartnerId() == m.GetSystemId() {
+		t.Errorf("DUT member %s partner-id %s is the system-id of the DUT", name, m.GetPartnerId())
+	}
+	if m.GetPartnerKey() == 0 {
+		t.Errorf("DUT member %s has no partner-key", name)
+	}
+	if m.GetPartnerPortNum() == 0 {
+		t.Errorf("DUT member %s has no partner-port-num", name)
+	}
+	return m
+}
+
+// setATELACP enables or disables LACP on the ATE member port with the
+// given ID, and waits for the DUT to start or stop distributing traffic
+// to the member.  It returns the time LACP was enabled or disabled, and
+// how long the DUT took to react.
+func setATELACP(t *testing.T, ate *ondatra.ATEDevice, dut *ondatra.DUTDevice, agg *attrs.Aggregate, id string, enabled bool) (time.Time, time.Duration) {
+	t.Helper()
+	ap, dp := ate.Port(t, id), dut.Port(t, id)
+	t.Logf("Setting LACP enabled to %t on ATE port %s", enabled, ap.Name())
+	start := time.Now()
+	ate.Actions().NewSetLACPState().WithPort(ap).WithEnabled(enabled).Send(t)
+	if !fptest.Await(t, dut.Telemetry().Lacp().Interface(agg.ID).Member(dp.Name()).Distributing().Watch, lacpTimeout, enabled) {
+		t.Fatalf("DUT member %s distributing is not %t within %v", dp.Name(), enabled, lacpTimeout)
+	}
+	d := time.Since(start)
+	t.Logf("DUT member %s distributing became %t after %v", dp.Name(), enabled, d)
+	return start, d
+}
+
+func TestAggregateLACP(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	agg := configureDUT(t, dut)
+
+	ate := ondatra.ATE(t, "ate")
+	top := configureATE(t, ate, dut, agg)
+	defer top.StopProtocols(t)
+
+	t.Run("Members", func(t *testing.T) {
+		aggPath := dut.Telemetry().Interface(agg.ID)
+		if got, want := aggPath.Type().Get(t), telemetry.IETFInterfaces_InterfaceType_ieee8023adLag; got != want {
+			t.Errorf("DUT aggregate %s type got %v, want %v", agg.ID, got, want)
+		}
+		if got, want := aggPath.OperStatus().Get(t), telemetry.Interface_OperStatus_UP; got != want {
+			t.Errorf("DUT aggregate %s oper-status got %v, want %v", agg.ID, got, want)
+		}
+		var partners []*telemetry.Lacp_Interface_Member
+		for _, name := range agg.Members {
+			if got := dut.Telemetry().Interface(name).Ethernet().AggregateId().Get(t); got != agg.ID {
+				t.Errorf("DUT member %s aggregate-id got %q, want %q", name, got, agg.ID)
+			}
+			partners = append(partners, verifyMember(t, dut, agg, name))
+		}
+		// Both members are connected to the same ATE LAG.
+		for _, m := range partners[1:] {
+			if m.GetPartnerId() != partners[0].GetPartnerId() || m.GetPartnerKey() != partners[0].GetPartnerKey() {
+				t.Errorf("DUT members %s and %s have different partners: %s key %d and %s key %d",
+					partners[0].GetInterface(), m.GetInterface(),
+					partners[0].GetPartnerId(), partners[0].GetPartnerKey(),
+					m.GetPartnerId(), m.GetPartnerKey())
+			}
+		}
+	})
+
+	flow := traffic.NewEntropyFlow(t, ate, top, &traffic.FlowParams{
+		Name: "LACP",
+		Src:  &atePort1,
+		Dst:  &ateAgg,
+	}, entropy)
+	bg := traffic.StartBackground(t, ate, flow, traffic.MinEntropyFrameRate)
+
+	t.Run("Balanced", func(t *testing.T) {
+		traffic.CheckDistribution(t, ate, memberIDs, []uint64{1, 1}, balanceTolerancePct, distributionOpts)
+	})
+
+	// Stop LACP on ate:port3, so that the DUT times out its partner and
+	// removes dut:port3 from the aggregate.  The traffic hashed to the
+	// member is lost until then.
+	var removed time.Time
+	var removalTime time.Duration
+	t.Run("MemberRemoved", func(t *testing.T) {
+		removed, removalTime = setATELACP(t, ate, dut, agg, "port3", false)
+		// MemberRestored enables LACP again, unless this test fails.
+		t.Cleanup(func() {
+			if t.Failed() {
+				setATELACP(t, ate, dut, agg, "port3", true)
+			}
+		})
+		if got, want := dut.Telemetry().Interface(agg.ID).OperStatus().Get(t), telemetry.Interface_OperStatus_UP; got != want {
+			t.Errorf("DUT aggregate %s oper-status got %v, want %v", agg.ID, got, want)
+		}
+		traffic.CheckDistribution(t, ate, memberIDs, []uint64{1, 0}, balanceTolerancePct, distributionOpts)
+	})
+
+	t.Run("MemberRestored", func(t *testing.T) {
+		setATELACP(t, ate, dut, agg, "port3", true)
+		verifyMember(t, dut, agg, dut.Port(t, "port3").Name())
+		traffic.CheckDistribution(t, ate, memberIDs, []uint64{1, 1}, balanceTolerancePct, distributionOpts)
+	})
+
+	bg.Stop(t)
+	if !removed.IsZero() {
+		limit := removalTime + *maxRemovalOutage
+		outage := bg.OutageBetween(removed, removed.Add(limit))
+		t.Logf("Removing DUT member port3 lost %d packets, i.e. %v outage", outage.LostPkts, outage.Duration)
+		if outage.Duration > limit {
+			t.Errorf("Outage after removing DUT member port3 got %v, want at most %v", outage.Duration, limit)
+		}
+	}
+
+	// With min-links set to both members, the aggregate goes down when
+	// the DUT removes one of them.
+	t.Run("MinLinks", func(t *testing.T) {
+		minLinks := dut.Config().Interface(agg.ID).Aggregation().MinLinks()
+		minLinks.Replace(t, uint16(len(agg.Members)))
+		defer minLinks.Delete(t)
+
+		aggStatus := dut.Telemetry().Interface(agg.ID).OperStatus()
+		if !fptest.Await(t, aggStatus.Watch, operStatusTimeout, telemetry.Interface_OperStatus_UP) {
+			t.Errorf("DUT aggregate %s oper-status is not UP within %v with %d members", agg.ID, operStatusTimeout, len(agg.Members))
+		}
+
+		setATELACP(t, ate, dut, agg, "port3", false)
+		defer setATELACP(t, ate, dut, agg, "port3", true)
+		want := telemetry.Interface_OperStatus_LOWER_LAYER_DOWN
+		if *deviations.InterfaceOperStatus {
+			want = telemetry.Interface_OperStatus_DOWN
+		}
+		if !fptest.Await(t, aggStatus.Watch, operStatusTimeout, want) {
+			t.Errorf("DUT aggregate %s oper-status is not %v within %v below min-links", agg.ID, want, operStatusTimeout)
+		}
+	})
+}
@@ -0,0 +1,256 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aggregate_static_test
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/link"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/netutil"
+	"github.com/openconfig/ondatra/telemetry"
+)
+
+var (
+	maxMemberOutage = flag.Duration("max_member_outage", time.Second,
+		"Maximum traffic outage when a member of the aggregate is disabled or enabled.")
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the aggregate testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1 and a static
+// aggregate of dut:port{2-3} -> ate:port{2-3}.
+//
+//   - ate:port1 -> dut:port1 subnet 192.0.2.0/30 2001:db8::0/126
+//   - ate:port{2-3} -> dut:port{2-3} subnet 192.0.2.4/30 2001:db8::4/126
+//
+// Traffic is sent from ate:port1 to the ATE aggregate, varying the L4
+// source port so that the DUT hashes it across the members.
+const (
+	ipv4PrefixLen = 30
+	ipv6PrefixLen = 126
+
+	// operStatusTimeout is how long to wait for the oper-status of the
+	// aggregate to reflect a change of its members.
+	operStatusTimeout = time.Minute
+	// balanceTolerancePct is the maximum deviation in percentage points
+	// of the fraction of traffic received on each member from the
+	// expected fraction.
+	balanceTolerancePct = 10
+)
+
+var (
+	dutPort1 = attrs.Attributes{
+		Desc:    "dutPort1",
+		IPv4:    "192.0.2.1",
+		IPv6:    "2001:db8::1",
+		IPv4Len: ipv4PrefixLen,
+		IPv6Len: ipv6PrefixLen,
+	}
+
+	atePort1 = attrs.Attributes{
+		Name:    "atePort1",
+		IPv4:    "192.0.2.2",
+		IPv6:    "2001:db8::2",
+		IPv4Len: ipv4PrefixLen,
+		IPv6Len: ipv6PrefixLen,
+	}
+
+	dutAgg = attrs.Attributes{
+		Desc:    "dutAgg",
+		IPv4:    "192.0.2.5",
+		IPv6:    "2001:db8::5",
+		IPv4Len: ipv4PrefixLen,
+		IPv6Len: ipv6PrefixLen,
+	}
+
+	ateAgg = attrs.Attributes{
+		Name:    "ateAgg",
+		IPv4:    "192.0.2.6",
+		IPv6:    "2001:db8::6",
+		IPv4Len: ipv4PrefixLen,
+		IPv6Len: ipv6PrefixLen,
+	}
+
+	memberIDs = []string{"port2", "port3"}
+
+	entropy = &traffic.Entropy{Protocol: traffic.UDP}
+
+	// distributionOpts measure the distribution of the traffic across
+	// members once it settled after a change.
+	distributionOpts = &traffic.DistributionOptions{
+		Settle:    true,
+		MinFrames: entropy.MinFrames(balanceTolerancePct / 100.0),
+	}
+)
+
+// configureDUT configures port1 and a static aggregate of port2 and
+// port3 on the DUT, and returns the aggregate.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) *attrs.Aggregate {
+	p1 := dut.Port(t, "port1")
+	i1 := dutPort1.NewInterface(p1.Name())
+	dut.Config().Interface(p1.Name()).Replace(t, i1)
+
+	agg := &attrs.Aggregate{
+		ID:      netutil.NextBundleInterface(t, dut),
+		LagType: telemetry.IfAggregate_AggregationType_STATIC,
+	}
+	for _, id := range memberIDs {
+		agg.Members = append(agg.Members, dut.Port(t, id).Name())
+	}
+	dutAgg.ConfigureAggregate(t, dut, agg)
+	return agg
+}
+
+// configureATE configures port1 and a static LAG of port2 and port3 on
+// the ATE, and waits for the DUT to resolve port1 and to bring the
+// aggregate up.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice, dut *ondatra.DUTDevice, agg *attrs.Aggregate) *ondatra.ATETopology {
+	top := ate.Topology().New()
+	atePort1.AddToATE(top, ate.Port(t, "port1"), &dutPort1)
+	var aps []*ondatra.Port
+	for _, id := range memberIDs {
+		aps = append(aps, ate.Port(t, id))
+	}
+	ateAgg.AddLAGToATE(top, aps, &dutAgg, false)
+	traffic.StartProtocolsAndAwait(t, ate, top, &traffic.Readiness{
+		DUT:       dut,
+		Neighbors: map[string]*attrs.Attributes{"port1": &atePort1},
+	})
+	if !fptest.Await(t, dut.Telemetry().Interface(agg.ID).OperStatus().Watch, operStatusTimeout, telemetry.Interface_OperStatus_UP) {
+		t.Fatalf("DUT aggregate %s oper-status is not UP within %v", agg.ID, operStatusTimeout)
+	}
+	return top
+}
+
+// verifyMember checks the admin-status and oper-status of the DUT
+// member port, and that it is still a member of the aggregate.
+func verifyMember(t *testing.T, dut *ondatra.DUTDevice, agg *attrs.Aggregate, p *ondatra.Port, enabled bool) {
+	t.Helper()
+	wantAdmin, wantOper := telemetry.Interface_AdminStatus_UP, telemetry.Interface_OperStatus_UP
+	if !enabled {
+		wantAdmin, wantOper = telemetry.Interface_AdminStatus_DOWN, telemetry.Interface_OperStatus_DOWN
+	}
+	i := dut.Telemetry().Interface(p.Name()).Get(t)
+	if got := i.GetAdminStatus(); got != wantAdmin {
+		t.Errorf("DUT member %s admin-status got %v, want %v", p.Name(), got, wantAdmin)
+	}
+	if got := i.GetOperStatus(); got != wantOper {
+		t.Errorf("DUT member %s oper-status got %v, want %v", p.Name(), got, wantOper)
+	}
+	if got := i.GetEthernet().GetAggregateId(); got != agg.ID {
+		t.Errorf("DUT member %s aggregate-id got %q, want %q", p.Name(), got, agg.ID)
+	}
+}
+
+// awaitAggregate waits for the oper-status of the DUT aggregate to be
+// UP if up is set, and DOWN or LOWER_LAYER_DOWN otherwise.
+func awaitAggregate(t *testing.T, dut *ondatra.DUTDevice, agg *attrs.Aggregate, up bool) {
+	t.Helper()
+	if up {
+		if !fptest.Await(t, dut.Telemetry().Interface(agg.ID).OperStatus().Watch, operStatusTimeout, telemetry.Interface_OperStatus_UP) {
+			t.Errorf("DUT aggregate %s oper-status is not UP within %v", agg.ID, operStatusTimeout)
+		}
+		return
+	}
+	fptest.AwaitFunc[telemetry.E_Interface_OperStatus](t, dut.Telemetry().Interface(agg.ID).OperStatus().Watch, operStatusTimeout, "aggregate down", func(val *telemetry.QualifiedE_Interface_OperStatus) bool {
+		if !val.IsPresent() {
+			return false
+		}
+		s := val.Val(t)
+		return s == telemetry.Interface_OperStatus_DOWN || s == telemetry.Interface_OperStatus_LOWER_LAYER_DOWN
+	})
+}
+
+func TestAggregateStatic(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	agg := configureDUT(t, dut)
+
+	ate := ondatra.ATE(t, "ate")
+	top := configureATE(t, ate, dut, agg)
+	defer top.StopProtocols(t)
+
+	dp2, dp3 := dut.Port(t, "port2"), dut.Port(t, "port3")
+
+	t.Run("Members", func(t *testing.T) {
+		aggPath := dut.Telemetry().Interface(agg.ID)
+		if got, want := aggPath.Type().Get(t), telemetry.IETFInterfaces_InterfaceType_ieee8023adLag; got != want {
+			t.Errorf("DUT aggregate %s type got %v, want %v", agg.ID, got, want)
+		}
+		if got, want := aggPath.Aggregation().LagType().Get(t), telemetry.IfAggregate_AggregationType_STATIC; got != want {
+			t.Errorf("DUT aggregate %s lag-type got %v, want %v", agg.ID, got, want)
+		}
+		for _, dp := range []*ondatra.Port{dp2, dp3} {
+			verifyMember(t, dut, agg, dp, true)
+		}
+	})
+
+	flow := traffic.NewEntropyFlow(t, ate, top, &traffic.FlowParams{
+		Name: "Static",
+		Src:  &atePort1,
+		Dst:  &ateAgg,
+	}, entropy)
+	bg := traffic.StartBackground(t, ate, flow, traffic.MinEntropyFrameRate)
+
+	t.Run("Balanced", func(t *testing.T) {
+		traffic.CheckDistribution(t, ate, memberIDs, []uint64{1, 1}, balanceTolerancePct, distributionOpts)
+	})
+
+	// dut:port3 is disabled by the whole test rather than a subtest, so
+	// that it is enabled again when the test ends should MemberEnabled
+	// fail to.
+	link.DisableDUTPort(t, dut, dp3)
+	t.Run("MemberDisabled", func(t *testing.T) {
+		verifyMember(t, dut, agg, dp3, false)
+		awaitAggregate(t, dut, agg, true)
+		traffic.CheckDistribution(t, ate, memberIDs, []uint64{1, 0}, balanceTolerancePct, distributionOpts)
+	})
+
+	t.Run("MemberEnabled", func(t *testing.T) {
+		link.EnableDUTPort(t, dut, dp3)
+		verifyMember(t, dut, agg, dp3, true)
+		awaitAggregate(t, dut, agg, true)
+		traffic.CheckDistribution(t, ate, memberIDs, []uint64{1, 1}, balanceTolerancePct, distributionOpts)
+	})
+
+	outage := bg.Stop(t)
+	t.Logf("Disabling and enabling DUT member port3 lost %d packets, i.e. %v outage", outage.LostPkts, outage.Duration)
+	if outage.Duration > *maxMemberOutage {
+		t.Errorf("Outage when disabling or enabling DUT member port3 got %v, want at most %v", outage.Duration, *maxMemberOutage)
+	}
+
+	t.Run("AllMembersDisabled", func(t *testing.T) {
+		for _, dp := range []*ondatra.Port{dp2, dp3} {
+			link.DisableDUTPort(t, dut, dp)
+		}
+		awaitAggregate(t, dut, agg, false)
+		traffic.ValidateFlow(t, ate, flow, &traffic.Options{WantLoss: true})
+	})
+
+	t.Run("Restored", func(t *testing.T) {
+		awaitAggregate(t, dut, agg, true)
+		traffic.ValidateFlow(t, ate, flow, nil)
+	})
+}
@@ -0,0 +1,245 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interface_mtu_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/ondatra"
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1 and
+// dut:port2 -> ate:port2.
+//
+//   - ate:port1 -> dut:port1 subnet 192.0.2.0/30 2001:db8::0/126
+//   - ate:port2 -> dut:port2 subnet 192.0.2.4/30 2001:db8::4/126
+//
+// The DUT ports are configured with the IP MTU of each test.  The ATE
+// ports accept the largest jumbo frames, so that frames larger than the
+// DUT MTU can be sent.
+const (
+	ipv4PrefixLen = 30
+	ipv6PrefixLen = 126
+
+	// dutMTU is the IP MTU of the DUT ports in TestInterfaceMTU.
+	dutMTU = 9000
+	// ateMTU is the IP MTU of the ATE ports, that of the largest
+	// frames less the Ethernet header and FCS.
+	ateMTU = traffic.MaxFrameSize - 18
+	// mtuFrameSize is the size of the frames carrying dutMTU byte
+	// packets.
+	mtuFrameSize = dutMTU + 18
+
+	// initialMTU and raisedMTU are the IP MTUs of the DUT ports in
+	// TestMTUEnforcement before and after the MTU is raised.
+	initialMTU = 1500
+	raisedMTU  = 9100
+	// smallFrameSize carries packets that fit initialMTU, and
+	// largeFrameSize packets that only fit raisedMTU.
+	smallFrameSize = 1400
+	largeFrameSize = 1600
+	// raisedMTUFrameSize is the size of the frames carrying raisedMTU
+	// byte packets.
+	raisedMTUFrameSize = raisedMTU + 18
+
+	// mtuTimeout is how long to wait for the DUT to report a changed
+	// MTU.
+	mtuTimeout = time.Minute
+)
+
+var (
+	dutPort1 = attrs.Attributes{
+		Desc:    "dutPort1",
+		IPv4:    "192.0.2.1",
+		IPv6:    "2001:db8::1",
+		IPv4Len: ipv4PrefixLen,
+		IPv6Len: ipv6PrefixLen,
+	}
+
+	atePort1 = attrs.Attributes{
+		Name:    "atePort1",
+		IPv4:    "192.0.2.2",
+		IPv6:    "2001:db8::2",
+		IPv4Len: ipv4PrefixLen,
+		IPv6Len: ipv6PrefixLen,
+		MTU:     ateMTU,
+	}
+
+	dutPort2 = attrs.Attributes{
+		Desc:    "dutPort2",
+		IPv4:    "192.0.2.5",
+		IPv6:    "2001:db8::5",
+		IPv4Len: ipv4PrefixLen,
+		IPv6Len: ipv6PrefixLen,
+	}
+
+	atePort2 = attrs.Attributes{
+		Name:    "atePort2",
+		IPv4:    "192.0.2.6",
+		IPv6:    "2001:db8::6",
+		IPv4Len: ipv4PrefixLen,
+		IPv6Len: ipv6PrefixLen,
+		MTU:     ateMTU,
+	}
+)
+
+// configureDUT configures port1 and port2 on the DUT with the given IP
+// MTU, and waits for the DUT to report it.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice, mtu uint16) {
+	d := dut.Config()
+	for _, p := range []struct {
+		id    string
+		attrs *attrs.Attributes
+	}{
+		{"port1", &dutPort1},
+		{"port2", &dutPort2},
+	} {
+		dp := dut.Port(t, p.id)
+		a := *p.attrs
+		a.MTU = mtu
+		i := a.NewInterface(dp.Name())
+		d.Interface(dp.Name()).Replace(t, i)
+		fptest.LogYgot(t, dp.String(), d.Interface(dp.Name()), i)
+	}
+	for _, id := range []string{"port1", "port2"} {
+		intf := dut.Telemetry().Interface(dut.Port(t, id).Name())
+		fptest.Await(t, intf.Subinterface(0).Ipv4().Mtu().Watch, mtuTimeout, mtu)
+		if !*deviations.OmitL2MTU {
+			fptest.Await(t, intf.Mtu().Watch, mtuTimeout, mtu+14)
+		}
+	}
+}
+
+// configureATE configures port1 and port2 on the ATE, and waits for the
+// DUT to resolve them.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice, dut *ondatra.DUTDevice) *ondatra.ATETopology {
+	top := ate.Topology().New()
+	atePort1.AddToATE(top, ate.Port(t, "port1"), &dutPort1)
+	atePort2.AddToATE(top, ate.Port(t, "port2"), &dutPort2)
+	traffic.StartProtocolsAndAwait(t, ate, top, &traffic.Readiness{
+		DUT: dut,
+		Neighbors: map[string]*attrs.Attributes{
+			"port1": &atePort1,
+			"port2": &atePort2,
+		},
+	})
+	return top
+}
+
+// frameCase is a flow of frames of a size the DUT must forward or drop.
+type frameCase struct {
+	desc string
+	size uint32
+	// oversize is whether the frames carry packets larger than the MTU
+	// of the DUT ports, which the DUT must drop.
+	oversize bool
+}
+
+// testFrames sends IPv4 flows with the DF-bit set and IPv6 flows of
+// each frame size from ate:port1 to ate:port2.  Frames that fit the MTU
+// must be received with no loss and without the DUT counting drops,
+// while oversize frames must be dropped and counted.
+func testFrames(t *testing.T, dut *ondatra.DUTDevice, ate *ondatra.ATEDevice, top *ondatra.ATETopology, mtu uint16, cases []frameCase) {
+	dp1, dp2 := dut.Port(t, "port1"), dut.Port(t, "port2")
+	newFlows := map[string]func(*traffic.FlowParams) *ondatra.Flow{
+		"IPv4": func(p *traffic.FlowParams) *ondatra.Flow { return traffic.NewIPv4Flow(t, ate, top, p) },
+		"IPv6": func(p *traffic.FlowParams) *ondatra.Flow { return traffic.NewIPv6Flow(t, ate, top, p) },
+	}
+	for _, ip := range []string{"IPv4", "IPv6"} {
+		t.Run(ip, func(t *testing.T) {
+			for _, c := range cases {
+				t.Run(c.desc, func(t *testing.T) {
+					flow := newFlows[ip](&traffic.FlowParams{
+						Name:         fmt.Sprintf("%s-MTU%d-%d", ip, mtu, c.size),
+						Src:          &atePort1,
+						Dst:          &atePort2,
+						DontFragment: true,
+						DUT:          dut,
+						SrcPort:      dp1,
+						DstPort:      dp2,
+						Frame:        traffic.Frame{Size: c.size, Oversize: c.oversize},
+					})
+					if c.oversize {
+						traffic.ValidateDrops(t, ate, dut, flow, []*ondatra.Port{dp1, dp2}, nil)
+						return
+					}
+					before := traffic.SnapshotCounters(t, dut, "port1", "port2")
+					r := traffic.ValidateFlow(t, ate, flow, nil)
+					diff := traffic.StableCounters(t, dut, "port1", "port2").Diff(before)
+					t.Logf("DUT counters incremented by:\n%v", diff)
+					if err := diff.NoDrops("port1", "port2"); err != nil {
+						t.Error(err)
+					}
+					if err := diff.MatchesFlow(r, traffic.DefaultCounterSlack, "port1", "port2"); err != nil {
+						t.Error(err)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestInterfaceMTU(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	configureDUT(t, dut, dutMTU)
+
+	ate := ondatra.ATE(t, "ate")
+	top := configureATE(t, ate, dut)
+	defer top.StopProtocols(t)
+
+	testFrames(t, dut, ate, top, dutMTU, []frameCase{
+		{"PacketSmallerThanMTU", mtuFrameSize - 64, false},
+		{"PacketExactlyMTU", mtuFrameSize, false},
+		{"PacketLargerThanMTU", mtuFrameSize + 64, true},
+	})
+}
+
+func TestMTUEnforcement(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	configureDUT(t, dut, initialMTU)
+
+	ate := ondatra.ATE(t, "ate")
+	top := configureATE(t, ate, dut)
+	defer top.StopProtocols(t)
+
+	t.Run(fmt.Sprintf("MTU%d", initialMTU), func(t *testing.T) {
+		testFrames(t, dut, ate, top, initialMTU, []frameCase{
+			{"SmallFrame", smallFrameSize, false},
+			{"LargeFrame", largeFrameSize, true},
+		})
+	})
+
+	t.Run(fmt.Sprintf("MTU%d", raisedMTU), func(t *testing.T) {
+		configureDUT(t, dut, raisedMTU)
+		testFrames(t, dut, ate, top, raisedMTU, []frameCase{
+			{"LargeFrame", largeFrameSize, false},
+			{"PacketExactlyMTU", raisedMTUFrameSize, false},
+			{"PacketLargerThanMTU", raisedMTUFrameSize + 64, true},
+		})
+	})
+}
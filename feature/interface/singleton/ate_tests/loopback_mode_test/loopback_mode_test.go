@@ -0,0 +1,192 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loopback_mode_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+	"github.com/openconfig/testt"
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1 and
+// dut:port2 -> ate:port2.
+//
+//   - ate:port1 -> dut:port1 subnet 192.0.2.0/30
+//   - ate:port2 -> dut:port2 subnet 192.0.2.4/30
+//
+// Loopback is enabled on dut:port2, while traffic is sent from
+// ate:port1 to ate:port2.
+const (
+	ipv4PrefixLen = 30
+
+	// loopbackTimeout is how long to wait for the DUT to report a
+	// changed loopback-mode, and for the ATE to report the link state.
+	loopbackTimeout = time.Minute
+)
+
+var (
+	dutPort1 = attrs.Attributes{
+		Desc:    "dutPort1",
+		IPv4:    "192.0.2.1",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort1 = attrs.Attributes{
+		Name:    "atePort1",
+		IPv4:    "192.0.2.2",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	dutPort2 = attrs.Attributes{
+		Desc:    "dutPort2",
+		IPv4:    "192.0.2.5",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort2 = attrs.Attributes{
+		Name:    "atePort2",
+		IPv4:    "192.0.2.6",
+		IPv4Len: ipv4PrefixLen,
+	}
+)
+
+// configureDUT configures port1 and port2 on the DUT.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	attrs.ConfigureDUTPorts(t, dut, map[string]*attrs.Attributes{
+		"port1": &dutPort1,
+		"port2": &dutPort2,
+	})
+}
+
+// configureATE configures port1 and port2 on the ATE, and waits for the
+// DUT to resolve them.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice, dut *ondatra.DUTDevice) *ondatra.ATETopology {
+	top := ate.Topology().New()
+	atePort1.AddToATE(top, ate.Port(t, "port1"), &dutPort1)
+	atePort2.AddToATE(top, ate.Port(t, "port2"), &dutPort2)
+	traffic.StartProtocolsAndAwait(t, ate, top, &traffic.Readiness{
+		DUT: dut,
+		Neighbors: map[string]*attrs.Attributes{
+			"port1": &atePort1,
+			"port2": &atePort2,
+		},
+	})
+	return top
+}
+
+// setLoopback sets the loopback-mode of the DUT interface and waits for
+// the DUT to report it.
+func setLoopback(t testing.TB, dut *ondatra.DUTDevice, name string, enabled bool) {
+	t.Helper()
+	dut.Config().Interface(name).LoopbackMode().Replace(t, enabled)
+	if !fptest.Await(t, dut.Telemetry().Interface(name).LoopbackMode().Watch, loopbackTimeout, enabled) {
+		t.Fatalf("DUT interface %s loopback-mode is not %t within %v", name, enabled, loopbackTimeout)
+	}
+}
+
+// runFlow runs the flow, expecting it to be received or lost, and
+// checks that the DUT counters attribute the packets the ATE sent to
+// port1 on ingress and to port2 on egress.
+func runFlow(t *testing.T, dut *ondatra.DUTDevice, ate *ondatra.ATEDevice, flow *ondatra.Flow, wantLoss bool) {
+	t.Helper()
+	before := traffic.SnapshotCounters(t, dut, "port1", "port2")
+	r := traffic.ValidateFlow(t, ate, flow, &traffic.Options{WantLoss: wantLoss})
+	diff := traffic.SnapshotCounters(t, dut, "port1", "port2").Diff(before)
+	t.Logf("DUT counters incremented by:\n%v", diff)
+	if *deviations.InterfaceCountersUnreliable {
+		return
+	}
+	if err := diff.ForwardedAtLeast(r.OutPkts, "port1", "port2"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLoopbackMode(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	configureDUT(t, dut)
+
+	ate := ondatra.ATE(t, "ate")
+	top := configureATE(t, ate, dut)
+	defer top.StopProtocols(t)
+
+	dp2 := dut.Port(t, "port2").Name()
+	ap2 := ate.Port(t, "port2").Name()
+	flow := traffic.NewIPv4Flow(t, ate, top, &traffic.FlowParams{
+		Name: "Loopback",
+		Src:  &atePort1,
+		Dst:  &atePort2,
+	})
+
+	t.Run("Baseline", func(t *testing.T) {
+		if got, want := dut.Telemetry().Interface(dp2).LoopbackMode().Get(t), false; got != want {
+			t.Errorf("DUT interface %s loopback-mode got %t, want %t", dp2, got, want)
+		}
+		runFlow(t, dut, ate, flow, false)
+	})
+
+	// The loopback-mode leaf of the interfaces model is a boolean, which
+	// loops the frames the DUT sends out of the interface back into it,
+	// i.e. a TERMINAL loopback.
+	t.Run("TERMINAL", func(t *testing.T) {
+		if errMsg := testt.CaptureFatal(t, func(t testing.TB) {
+			dut.Config().Interface(dp2).LoopbackMode().Replace(t, true)
+		}); errMsg != nil {
+			t.Skipf("DUT does not support TERMINAL loopback on interface %s: %s", dp2, *errMsg)
+		}
+		// A stuck loopback breaks every later test using the port, so it
+		// is disabled however the test ends.
+		t.Cleanup(func() { setLoopback(t, dut, dp2, false) })
+
+		if !fptest.Await(t, dut.Telemetry().Interface(dp2).LoopbackMode().Watch, loopbackTimeout, true) {
+			t.Fatalf("DUT interface %s loopback-mode is not true within %v", dp2, loopbackTimeout)
+		}
+		// The loopback is internal to the DUT, so the link to the ATE
+		// stays up.
+		if !fptest.Await(t, ate.Telemetry().Interface(ap2).OperStatus().Watch, loopbackTimeout, telemetry.Interface_OperStatus_UP) {
+			t.Errorf("ATE port %s oper-status is not UP within %v with TERMINAL loopback on DUT interface %s", ap2, loopbackTimeout, dp2)
+		}
+		// The DUT still forwards the frames out of port2, but they are
+		// looped back rather than sent to the ATE.
+		runFlow(t, dut, ate, flow, true)
+	})
+
+	t.Run("FACILITY", func(t *testing.T) {
+		t.Skip("FACILITY loopback, which reflects the frames received from the ATE, cannot be configured through the boolean loopback-mode of the interfaces model")
+	})
+
+	t.Run("Restored", func(t *testing.T) {
+		if got, want := dut.Telemetry().Interface(dp2).LoopbackMode().Get(t), false; got != want {
+			t.Fatalf("DUT interface %s loopback-mode got %t, want %t", dp2, got, want)
+		}
+		if !fptest.Await(t, ate.Telemetry().Interface(ap2).OperStatus().Watch, loopbackTimeout, telemetry.Interface_OperStatus_UP) {
+			t.Errorf("ATE port %s oper-status is not UP within %v", ap2, loopbackTimeout)
+		}
+		runFlow(t, dut, ate, flow, false)
+	})
+}
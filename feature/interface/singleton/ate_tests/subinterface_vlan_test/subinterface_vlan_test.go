@@ -0,0 +1,346 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package subinterface_vlan_test
+
+import (
+	"testing"
+
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+	"github.com/openconfig/ygot/ygot"
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1,
+// dut:port2 -> ate:port2 and dut:port3 -> ate:port3.
+//
+//   - ate:port1 -> dut:port1 VLAN 100 subnet 192.0.2.0/30 2001:db8::0/126
+//   - ate:port1 -> dut:port1 VLAN 200 subnet 192.0.2.4/30 2001:db8::4/126
+//   - ate:port2 -> dut:port2 subnet 192.0.2.8/30 2001:db8::8/126
+//   - ate:port3 -> dut:port3 subnet 192.0.2.12/30 2001:db8::c/126
+//
+// dut:port1 is a trunk with a subinterface for each VLAN and none for
+// untagged frames.  ate:port2 is the destination network of VLAN 100,
+// and ate:port3 that of VLAN 200.  ate:port1 also has an untagged
+// interface in subnet 192.0.2.16/30, which the DUT has no address in.
+const (
+	ipv4PrefixLen = 30
+	ipv6PrefixLen = 126
+
+	vlan100 = 100
+	vlan200 = 200
+)
+
+var (
+	dutVLAN100 = attrs.Attributes{
+		IPv4:    "192.0.2.1",
+		IPv6:    "2001:db8::1",
+		IPv4Len: ipv4PrefixLen,
+		IPv6Len: ipv6PrefixLen,
+		VLANID:  vlan100,
+	}
+
+	ateVLAN100 = attrs.Attributes{
+		Name:    "ateVLAN100",
+		IPv4:    "192.0.2.2",
+		IPv6:    "2001:db8::2",
+		IPv4Len: ipv4PrefixLen,
+		IPv6Len: ipv6PrefixLen,
+		VLANID:  vlan100,
+	}
+
+	dutVLAN200 = attrs.Attributes{
+		IPv4:    "192.0.2.5",
+		IPv6:    "2001:db8::5",
+		IPv4Len: ipv4PrefixLen,
+		IPv6Len: ipv6PrefixLen,
+		VLANID:  vlan200,
+	}
+
+	ateVLAN200 = attrs.Attributes{
+		Name:    "ateVLAN200",
+		IPv4:    "192.0.2.6",
+		IPv6:    "2001:db8::6",
+		IPv4Len: ipv4PrefixLen,
+		IPv6Len: ipv6PrefixLen,
+		VLANID:  vlan200,
+	}
+
+	dutPort2 = attrs.Attributes{
+		Desc:    "dutPort2",
+		IPv4:    "192.0.2.9",
+		IPv6:    "2001:db8::9",
+		IPv4Len: ipv4PrefixLen,
+		IPv6Len: ipv6PrefixLen,
+	}
+
+	atePort2 = attrs.Attributes{
+		Name:    "atePort2",
+		IPv4:    "192.0.2.10",
+		IPv6:    "2001:db8::a",
+		IPv4Len: ipv4PrefixLen,
+		IPv6Len: ipv6PrefixLen,
+	}
+
+	dutPort3 = attrs.Attributes{
+		Desc:    "dutPort3",
+		IPv4:    "192.0.2.13",
+		IPv6:    "2001:db8::d",
+		IPv4Len: ipv4PrefixLen,
+		IPv6Len: ipv6PrefixLen,
+	}
+
+	atePort3 = attrs.Attributes{
+		Name:    "atePort3",
+		IPv4:    "192.0.2.14",
+		IPv6:    "2001:db8::e",
+		IPv4Len: ipv4PrefixLen,
+		IPv6Len: ipv6PrefixLen,
+	}
+
+	// ateUntagged is the untagged interface of ate:port1, whose gateway
+	// dutUntagged is not configured on the DUT.
+	ateUntagged = attrs.Attributes{
+		Name:    "ateUntagged",
+		IPv4:    "192.0.2.18",
+		IPv4Len: ipv4PrefixLen,
+	}
+	dutUntagged = attrs.Attributes{
+		IPv4: "192.0.2.17",
+	}
+)
+
+// vlanCase is a VLAN of the trunk and its destination network.
+type vlanCase struct {
+	desc     string
+	dut, ate *attrs.Attributes
+	// dst is the ATE interface of the destination network of the VLAN,
+	// and dstPort its port.
+	dst     *attrs.Attributes
+	dstPort string
+}
+
+var vlanCases = []vlanCase{
+	{"VLAN100", &dutVLAN100, &ateVLAN100, &atePort2, "port2"},
+	{"VLAN200", &dutVLAN200, &ateVLAN200, &atePort3, "port3"},
+}
+
+// configureDUT configures port1 as a trunk with a subinterface for each
+// VLAN, and port2 and port3 as its destination networks, on the DUT.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	d := dut.Config()
+
+	dp1 := dut.Port(t, "port1")
+	i1 := &telemetry.Interface{
+		Name:        ygot.String(dp1.Name()),
+		Description: ygot.String("dutPort1"),
+		Type:        telemetry.IETFInterfaces_InterfaceType_ethernetCsmacd,
+	}
+	if *deviations.InterfaceEnabled {
+		i1.Enabled = ygot.Bool(true)
+	}
+	for _, c := range vlanCases {
+		i1.AppendSubinterface(c.dut.NewSubinterface())
+	}
+	d.Interface(dp1.Name()).Replace(t, i1)
+	fptest.LogYgot(t, dp1.String(), d.Interface(dp1.Name()), i1)
+
+	for _, p := range []struct {
+		id    string
+		attrs *attrs.Attributes
+	}{
+		{"port2", &dutPort2},
+		{"port3", &dutPort3},
+	} {
+		dp := dut.Port(t, p.id)
+		i := p.attrs.NewInterface(dp.Name())
+		d.Interface(dp.Name()).Replace(t, i)
+		fptest.LogYgot(t, dp.String(), d.Interface(dp.Name()), i)
+	}
+}
+
+// configureATE configures the tagged and untagged interfaces of port1,
+// and port2 and port3, on the ATE, and waits for the DUT to resolve
+// them.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice, dut *ondatra.DUTDevice) *ondatra.ATETopology {
+	top := ate.Topology().New()
+	ap1 := ate.Port(t, "port1")
+	for _, c := range vlanCases {
+		c.ate.AddToATE(top, ap1, c.dut)
+	}
+	ateUntagged.AddToATE(top, ap1, &dutUntagged)
+	atePort2.AddToATE(top, ate.Port(t, "port2"), &dutPort2)
+	atePort3.AddToATE(top, ate.Port(t, "port3"), &dutPort3)
+	traffic.StartProtocolsAndAwait(t, ate, top, &traffic.Readiness{
+		DUT: dut,
+		Neighbors: map[string]*attrs.Attributes{
+			"port2": &atePort2,
+			"port3": &atePort3,
+		},
+		TaggedNeighbors: map[string][]*attrs.Attributes{
+			"port1": {&ateVLAN100, &ateVLAN200},
+		},
+	})
+	return top
+}
+
+// verifySubinterface checks the state of the subinterface of dut:port1
+// for the VLAN: its VLAN ID and its IPv4 and IPv6 addresses.
+func verifySubinterface(t *testing.T, dut *ondatra.DUTDevice, a *attrs.Attributes) {
+	t.Helper()
+	name := dut.Port(t, "port1").Name()
+	s := dut.Telemetry().Interface(name).Subinterface(a.SubinterfaceIndex())
+	if *deviations.DeprecatedVlanID {
+		if got, want := s.Vlan().VlanId().Get(t), telemetry.UnionUint16(a.VLANID); got != want {
+			t.Errorf("DUT subinterface %s.%d vlan-id got %v, want %v", name, a.SubinterfaceIndex(), got, want)
+		}
+	} else {
+		if got := s.Vlan().Match().SingleTagged().VlanId().Get(t); got != a.VLANID {
+			t.Errorf("DUT subinterface %s.%d single-tagged vlan-id got %d, want %d", name, a.SubinterfaceIndex(), got, a.VLANID)
+		}
+	}
+	if got := s.Ipv4().Address(a.IPv4).PrefixLength().Get(t); got != a.IPv4Len {
+		t.Errorf("DUT subinterface %s.%d address %s prefix-length got %d, want %d", name, a.SubinterfaceIndex(), a.IPv4, got, a.IPv4Len)
+	}
+	if got := s.Ipv6().Address(a.IPv6).PrefixLength().Get(t); got != a.IPv6Len {
+		t.Errorf("DUT subinterface %s.%d address %s prefix-length got %d, want %d", name, a.SubinterfaceIndex(), a.IPv6, got, a.IPv6Len)
+	}
+}
+
+// subinterfaceInPkts reads the in-unicast-pkts counter of the
+// subinterfaces of dut:port1 for each VLAN, by VLAN ID.  It returns nil
+// if the DUT does not report the counter of any of them.
+func subinterfaceInPkts(t *testing.T, dut *ondatra.DUTDevice) map[uint16]uint64 {
+	t.Helper()
+	intf := dut.Telemetry().Interface(dut.Port(t, "port1").Name())
+	pkts := make(map[uint16]uint64)
+	for _, c := range vlanCases {
+		q := intf.Subinterface(c.dut.SubinterfaceIndex()).Counters().InUnicastPkts().Lookup(t)
+		if !q.IsPresent() {
+			t.Logf("DUT does not report in-unicast-pkts of subinterface %d", c.dut.SubinterfaceIndex())
+			return nil
+		}
+		pkts[c.dut.VLANID] = q.Val(t)
+	}
+	return pkts
+}
+
+// otherPorts returns the destination ports of the VLANs other than c.
+func otherPorts(c vlanCase) []string {
+	var ports []string
+	for _, o := range vlanCases {
+		if o.dstPort != c.dstPort {
+			ports = append(ports, o.dstPort)
+		}
+	}
+	return ports
+}
+
+// testVLAN sends IPv4 and IPv6 flows tagged with the VLAN from ate:port1
+// to the destination network of the VLAN.  They must be received with no
+// loss, none of their packets may be received by the destination
+// networks of the other VLANs, and the DUT must count them on the
+// subinterface of the VLAN only.
+func testVLAN(t *testing.T, dut *ondatra.DUTDevice, ate *ondatra.ATEDevice, top *ondatra.ATETopology, c vlanCase) {
+	v4, v6 := traffic.NewDualStackFlows(t, ate, top, &traffic.FlowParams{
+		Name:    c.desc,
+		Src:     c.ate,
+		Dst:     c.dst,
+		DUT:     dut,
+		SrcPort: dut.Port(t, "port1"),
+		DstPort: dut.Port(t, c.dstPort),
+	})
+
+	before := subinterfaceInPkts(t, dut)
+	var rs []*traffic.Result
+	traffic.CheckNoTraffic(t, ate, otherPorts(c), 0, func() {
+		rs = traffic.ValidateFlows(t, ate, []*ondatra.Flow{v4, v6}, nil)
+	})
+	after := subinterfaceInPkts(t, dut)
+	if before == nil || after == nil {
+		t.Log("Skipping per-VLAN counter checks")
+		return
+	}
+
+	var outPkts uint64
+	for _, r := range rs {
+		outPkts += r.OutPkts
+	}
+	for _, o := range vlanCases {
+		vid := o.dut.VLANID
+		got := after[vid] - before[vid]
+		t.Logf("DUT subinterface %d in-unicast-pkts incremented by %d", vid, got)
+		switch {
+		case vid == c.dut.VLANID && got < outPkts:
+			t.Errorf("DUT subinterface %d in-unicast-pkts got %d, want at least %d", vid, got, outPkts)
+		case vid != c.dut.VLANID && got > traffic.DefaultCounterSlack:
+			t.Errorf("DUT subinterface %d in-unicast-pkts got %d, want at most %d", vid, got, traffic.DefaultCounterSlack)
+		}
+	}
+}
+
+// testUntagged sends untagged IPv4 frames from ate:port1 to the
+// destination network of VLAN 100, addressed to the MAC address of
+// dut:port1.  With no native VLAN, i.e. no untagged subinterface, on
+// the trunk, the DUT must drop them.
+func testUntagged(t *testing.T, dut *ondatra.DUTDevice, ate *ondatra.ATEDevice, top *ondatra.ATETopology) {
+	mac := dut.Telemetry().Interface(dut.Port(t, "port1").Name()).Ethernet().MacAddress().Get(t)
+	flow := ate.Traffic().NewFlow("Untagged").
+		WithSrcEndpoints(top.Interfaces()[ateUntagged.Name]).
+		WithDstEndpoints(top.Interfaces()[atePort2.Name]).
+		WithHeaders(ondatra.NewEthernetHeader().WithDstAddress(mac), ondatra.NewIPv4Header())
+	var ports []string
+	for _, c := range vlanCases {
+		ports = append(ports, c.dstPort)
+	}
+	traffic.CheckNoTraffic(t, ate, ports, 0, func() {
+		traffic.ValidateFlow(t, ate, flow, &traffic.Options{WantLoss: true})
+	})
+}
+
+func TestSubinterfaceVLAN(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	configureDUT(t, dut)
+
+	ate := ondatra.ATE(t, "ate")
+	top := configureATE(t, ate, dut)
+	defer top.StopProtocols(t)
+
+	t.Run("State", func(t *testing.T) {
+		for _, c := range vlanCases {
+			verifySubinterface(t, dut, c.dut)
+		}
+	})
+
+	for _, c := range vlanCases {
+		t.Run(c.desc, func(t *testing.T) {
+			testVLAN(t, dut, ate, top, c)
+		})
+	}
+
+	t.Run("Untagged", func(t *testing.T) {
+		testUntagged(t, dut, ate, top)
+	})
+}
@@ -0,0 +1,239 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipv6_nd_ra_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+	"github.com/openconfig/ygot/ygot"
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// The testbed consists of ate:port1 -> dut:port1, in subnet
+// 2001:db8:1::/64.  The ATE sends neighbor solicitations for the DUT
+// address once per second while its traffic runs, and the packets the
+// DUT sends to ate:port1 are captured.
+const (
+	ipv6PrefixLen = 64
+	// ipv6Prefix is the prefix the DUT advertises in its router
+	// advertisements.
+	ipv6Prefix = "2001:db8:1::/64"
+
+	// raInterval is the router advertisement interval of the DUT in
+	// seconds, and raLifetime the router lifetime it advertises.
+	raInterval = 10
+	raLifetime = 300
+
+	// cadenceSlack is how much longer than raInterval apart two
+	// successive router advertisements may be, allowing for the timer
+	// jitter of the DUT.
+	cadenceSlack = 2 * time.Second
+	// raCaptureTime is how long router advertisements are captured
+	// for, enough for at least two.
+	raCaptureTime = 3*raInterval*time.Second + cadenceSlack
+	// nsCaptureTime is how long to capture the neighbor advertisements
+	// answering the neighbor solicitations of the ATE.
+	nsCaptureTime = 5 * time.Second
+	// neighborTimeout is how long to wait for the DUT to report the
+	// ATE as a reachable neighbor.
+	neighborTimeout = 30 * time.Second
+)
+
+var (
+	dutPort1 = attrs.Attributes{
+		Desc:    "dutPort1",
+		IPv6:    "2001:db8:1::1",
+		IPv6Len: ipv6PrefixLen,
+	}
+
+	atePort1 = attrs.Attributes{
+		Name:    "atePort1",
+		MAC:     "02:00:01:01:01:01",
+		IPv6:    "2001:db8:1::2",
+		IPv6Len: ipv6PrefixLen,
+	}
+)
+
+// configureDUT configures port1 on the DUT to send router
+// advertisements every raInterval seconds.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	dp := dut.Port(t, "port1")
+	i := dutPort1.NewInterface(dp.Name())
+	ra := i.GetSubinterface(0).GetOrCreateIpv6().GetOrCreateRouterAdvertisement()
+	ra.Interval = ygot.Uint32(raInterval)
+	ra.Lifetime = ygot.Uint32(raLifetime)
+	ra.Suppress = ygot.Bool(false)
+	d := dut.Config()
+	fptest.LogYgot(t, dp.String(), d.Interface(dp.Name()), i)
+	d.Interface(dp.Name()).Replace(t, i)
+}
+
+// configureATE configures port1 on the ATE, with a flow of neighbor
+// solicitations for the DUT address and a capture of the packets
+// received, and starts its protocols.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice) *traffic.Capture {
+	otg := ate.OTG()
+	top := otg.NewConfig(t)
+	ap := ate.Port(t, "port1")
+	atePort1.AddToOTG(top, ap, &dutPort1)
+	traffic.AddOTGNSFlow(t, top, ap, &atePort1, dutPort1.IPv6)
+	capture := traffic.NewCapture(t, ate, top, ap)
+	otg.PushConfig(t, top)
+	otg.StartProtocols(t)
+	return capture
+}
+
+// routerAdvertisements returns the router advertisements among the
+// captured packets.
+func routerAdvertisements(pkts []*traffic.Packet) []*traffic.Packet {
+	var ras []*traffic.Packet
+	for _, p := range pkts {
+		if p.ND.IsRA() {
+			ras = append(ras, p)
+		}
+	}
+	return ras
+}
+
+// verifyRA checks that a router advertisement sent by the DUT carries
+// its MAC address, the configured lifetime, a clear managed flag and
+// the prefix of dut:port1.
+func verifyRA(t *testing.T, nd *traffic.ND, dutMAC string) {
+	t.Helper()
+	if nd.LinkLayerAddress != dutMAC {
+		t.Errorf("RA source link-layer address got %q, want %q", nd.LinkLayerAddress, dutMAC)
+	}
+	if got, want := nd.RouterLifetime, raLifetime*time.Second; got != want {
+		t.Errorf("RA router lifetime got %v, want %v", got, want)
+	}
+	if nd.Managed {
+		t.Errorf("RA managed flag got set, want clear")
+	}
+	var found bool
+	for _, p := range nd.Prefixes {
+		found = found || p == ipv6Prefix
+	}
+	if !found {
+		t.Errorf("RA prefixes got %v, want %s", nd.Prefixes, ipv6Prefix)
+	}
+}
+
+func TestIPv6NDRA(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	configureDUT(t, dut)
+
+	ate := ondatra.ATE(t, "ate")
+	capture := configureATE(t, ate)
+	defer ate.OTG().StopProtocols(t)
+
+	intf := dut.Telemetry().Interface(dut.Port(t, "port1").Name())
+	dutMAC := intf.Ethernet().MacAddress().Get(t)
+
+	t.Run("RAConfig", func(t *testing.T) {
+		ra := intf.Subinterface(0).Ipv6().RouterAdvertisement().Get(t)
+		if got := ra.GetInterval(); got != raInterval {
+			t.Errorf("DUT RA interval got %d, want %d", got, raInterval)
+		}
+		if got := ra.GetLifetime(); got != raLifetime {
+			t.Errorf("DUT RA lifetime got %d, want %d", got, raLifetime)
+		}
+		if ra.GetSuppress() {
+			t.Errorf("DUT RA suppress got true, want false")
+		}
+	})
+
+	t.Run("NeighborSolicitation", func(t *testing.T) {
+		var nas []*traffic.ND
+		for _, p := range capture.Run(t, nsCaptureTime) {
+			if p.ND.IsNA() && p.ND.Target == dutPort1.IPv6 {
+				nas = append(nas, p.ND)
+			}
+		}
+		if len(nas) == 0 {
+			t.Fatalf("ATE port1 received no NA for %s within %v of soliciting it", dutPort1.IPv6, nsCaptureTime)
+		}
+		t.Logf("ATE port1 received %d NAs for %s, the first %+v", len(nas), dutPort1.IPv6, *nas[0])
+		var solicited bool
+		for _, na := range nas {
+			solicited = solicited || na.Solicited
+			if na.LinkLayerAddress != dutMAC {
+				t.Errorf("NA target link-layer address got %q, want %q", na.LinkLayerAddress, dutMAC)
+			}
+		}
+		if !solicited {
+			t.Errorf("ATE port1 received no NA for %s with the solicited flag set", dutPort1.IPv6)
+		}
+
+		nbr := intf.Subinterface(0).Ipv6().Neighbor(atePort1.IPv6)
+		fptest.Await(t, nbr.NeighborState().Watch, neighborTimeout, telemetry.Neighbor_NeighborState_REACHABLE)
+		if got := nbr.LinkLayerAddress().Get(t); got != atePort1.MAC {
+			t.Errorf("DUT neighbor %s link-layer-address got %q, want %q", atePort1.IPv6, got, atePort1.MAC)
+		}
+	})
+
+	t.Run("RouterAdvertisement", func(t *testing.T) {
+		ras := routerAdvertisements(capture.Run(t, raCaptureTime))
+		if len(ras) < 2 {
+			t.Fatalf("ATE port1 received %d RAs within %v, want at least 2", len(ras), raCaptureTime)
+		}
+		for i, p := range ras {
+			verifyRA(t, p.ND, dutMAC)
+			if i == 0 {
+				continue
+			}
+			// Unsolicited RAs are sent at random intervals of at most
+			// the configured interval.
+			if gap := p.Time.Sub(ras[i-1].Time); gap > raInterval*time.Second+cadenceSlack {
+				t.Errorf("RAs %d and %d were %v apart, want at most %v", i-1, i, gap, raInterval*time.Second+cadenceSlack)
+			}
+		}
+	})
+
+	t.Run("Suppressed", func(t *testing.T) {
+		suppress := dut.Config().Interface(dut.Port(t, "port1").Name()).Subinterface(0).Ipv6().RouterAdvertisement().Suppress()
+		suppress.Replace(t, true)
+		defer suppress.Replace(t, false)
+
+		// The capture starts right after suppressing RAs, and its
+		// first packet, an NA answering the ATE, gives the time it
+		// started on the ATE clock.  An RA already scheduled may still
+		// be sent within the interval.
+		pkts := capture.Run(t, raCaptureTime)
+		if len(pkts) == 0 {
+			t.Fatalf("ATE port1 received no packets within %v", raCaptureTime)
+		}
+		deadline := pkts[0].Time.Add(raInterval * time.Second)
+		for _, p := range routerAdvertisements(pkts) {
+			if p.Time.After(deadline) {
+				t.Errorf("ATE port1 received an RA %v after the capture started, want none after %v", p.Time.Sub(pkts[0].Time), raInterval*time.Second)
+			}
+		}
+	})
+
+	t.Run("Resumed", func(t *testing.T) {
+		if ras := routerAdvertisements(capture.Run(t, raCaptureTime)); len(ras) == 0 {
+			t.Errorf("ATE port1 received no RAs within %v of no longer suppressing them", raCaptureTime)
+		}
+	})
+}
@@ -0,0 +1,371 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gre_tunnel_test
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/layers"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/setrequest"
+	"github.com/openconfig/featureprofiles/internal/static"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+	"github.com/openconfig/ygot/ygot"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+var tunnelName = flag.String("tunnel_interface", "Tunnel1",
+	"Name of the GRE tunnel interface created on the DUT.")
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1 and
+// dut:port2 -> ate:port2.
+//
+//   - ate:port1 -> dut:port1 subnet 192.0.2.0/30
+//   - dut:port2 -> ate:port2 subnet 192.0.2.4/30, with the GRE tunnel
+//     from dut:port2 to ate:port2.
+//   - The tunnel interface has subnet 192.0.2.8/30, and testPrefix is
+//     routed into the tunnel.
+//
+// The ATE does not terminate the tunnel itself: the GRE packets the DUT
+// sends are captured on ate:port2, and the GRE packets to the DUT are
+// sent by a flow.
+const (
+	ipv4PrefixLen = 30
+
+	// testPrefix is routed into the tunnel, and testHost is the address
+	// of the prefix the traffic is sent to and from.
+	testPrefix = "198.51.100.0/24"
+	testHost   = "198.51.100.1"
+
+	// frameCount is the number of frames of each flow, and frameRate
+	// their rate.
+	frameCount = 1000
+	frameRate  = 100
+
+	// stateTimeout is how long the state of the DUT may take to reflect
+	// the configuration.
+	stateTimeout = 30 * time.Second
+)
+
+var (
+	dutPort1 = attrs.Attributes{
+		Desc:    "dutPort1",
+		IPv4:    "192.0.2.1",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort1 = attrs.Attributes{
+		Name:    "atePort1",
+		MAC:     "02:00:01:01:01:01",
+		IPv4:    "192.0.2.2",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	// dutPort2 is the source of the tunnel.
+	dutPort2 = attrs.Attributes{
+		Desc:    "dutPort2",
+		IPv4:    "192.0.2.5",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	// atePort2 is the destination of the tunnel.
+	atePort2 = attrs.Attributes{
+		Name:    "atePort2",
+		MAC:     "02:00:02:01:01:01",
+		IPv4:    "192.0.2.6",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	// dutTunnel is the tunnel interface, and tunnelPeer the next hop of
+	// testPrefix at the other end of the tunnel.
+	dutTunnel = attrs.Attributes{
+		Desc:    "dutTunnel",
+		IPv4:    "192.0.2.9",
+		IPv4Len: ipv4PrefixLen,
+	}
+	tunnelPeer = "192.0.2.10"
+)
+
+// configureDUT configures port1 and port2 on the DUT.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	d := dut.Config()
+	for id, a := range map[string]*attrs.Attributes{"port1": &dutPort1, "port2": &dutPort2} {
+		dp := dut.Port(t, id)
+		i := a.NewInterface(dp.Name())
+		d.Interface(dp.Name()).Replace(t, i)
+		fptest.LogYgot(t, dp.String(), d.Interface(dp.Name()), i)
+	}
+}
+
+// tunnelConfig returns the JSON of the openconfig-if-tunnel container of
+// the GRE tunnel from dut:port2 to ate:port2, which the telemetry
+// structs do not cover.
+func tunnelConfig() []byte {
+	return []byte(fmt.Sprintf(`{"openconfig-if-tunnel:config":{"src":%q,"dst":%q}}`, dutPort2.IPv4, atePort2.IPv4))
+}
+
+// configureTunnel configures the tunnel interface with openconfig-if-tunnel
+// in a single SetRequest, skipping the test if the DUT rejects it and
+// deviation_gre_tunnel_oc_unsupported is set.
+func configureTunnel(t *testing.T, dut *ondatra.DUTDevice) {
+	i := &telemetry.Interface{
+		Name:        ygot.String(*tunnelName),
+		Description: ygot.String(dutTunnel.Desc),
+		Type:        telemetry.IETFInterfaces_InterfaceType_tunnel,
+		Enabled:     ygot.Bool(true),
+	}
+	dutTunnel.ConfigSubinterface(i.GetOrCreateSubinterface(0))
+	q := dut.Config().Interface(*tunnelName)
+	_, err := setrequest.New().
+		Replace(q, i).
+		UpdateJSON(q, []string{"tunnel"}, tunnelConfig()).
+		Set(t, dut)
+	switch {
+	case err != nil && *deviations.GRETunnelOCUnsupported:
+		t.Skipf("DUT rejected the GRE tunnel configured with openconfig-if-tunnel: %v", err)
+	case err != nil:
+		t.Fatalf("Cannot configure GRE tunnel %s: %v", *tunnelName, err)
+	}
+	fptest.LogYgot(t, "tunnel", q, i)
+}
+
+// tunnelState returns the src and dst state leaves of the tunnel
+// interface, which the telemetry structs do not cover.
+func tunnelState(t *testing.T, dut *ondatra.DUTDevice) (map[string]string, error) {
+	p, err := setrequest.Path(dut.Telemetry().Interface(*tunnelName))
+	if err != nil {
+		return nil, err
+	}
+	p.Elem = append(p.Elem, &gpb.PathElem{Name: "tunnel"}, &gpb.PathElem{Name: "state"})
+	resp, err := dut.RawAPIs().GNMI().Default(t).Get(context.Background(), &gpb.GetRequest{
+		Path:     []*gpb.Path{p},
+		Type:     gpb.GetRequest_STATE,
+		Encoding: gpb.Encoding_JSON_IETF,
+	})
+	if err != nil {
+		return nil, err
+	}
+	state := map[string]string{}
+	for _, n := range resp.GetNotification() {
+		for _, u := range n.GetUpdate() {
+			var leaves map[string]interface{}
+			if err := json.Unmarshal(u.GetVal().GetJsonIetfVal(), &leaves); err != nil {
+				return nil, fmt.Errorf("cannot unmarshal tunnel state: %w", err)
+			}
+			for k, v := range leaves {
+				if i := strings.LastIndex(k, ":"); i >= 0 {
+					k = k[i+1:]
+				}
+				if s, ok := v.(string); ok {
+					state[k] = s
+				}
+			}
+		}
+	}
+	return state, nil
+}
+
+// awaitTunnelState waits for the src and dst state leaves of the tunnel
+// interface to reflect the configuration.
+func awaitTunnelState(t *testing.T, dut *ondatra.DUTDevice) {
+	t.Helper()
+	var (
+		state map[string]string
+		err   error
+	)
+	for start := time.Now(); time.Since(start) < stateTimeout; time.Sleep(time.Second) {
+		state, err = tunnelState(t, dut)
+		if err == nil && state["src"] == dutPort2.IPv4 && state["dst"] == atePort2.IPv4 {
+			t.Logf("GRE tunnel %s state: %v", *tunnelName, state)
+			return
+		}
+	}
+	if err != nil {
+		t.Fatalf("Cannot get state of GRE tunnel %s: %v", *tunnelName, err)
+	}
+	t.Fatalf("GRE tunnel %s state got src %q and dst %q, want %s and %s", *tunnelName, state["src"], state["dst"], dutPort2.IPv4, atePort2.IPv4)
+}
+
+// configureATE configures the interfaces of the ATE, and returns the OTG
+// config after adding the encap flow from ate:port1 to testHost, and
+// the decap flow of GRE packets from ate:port2 to the DUT encapsulating
+// packets from testHost to ate:port1.  The OTG protocols are left
+// started.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice) gosnappi.Config {
+	otg := ate.OTG()
+	top := otg.NewConfig(t)
+	ap1 := ate.Port(t, "port1")
+	ap2 := ate.Port(t, "port2")
+	atePort1.AddToOTG(top, ap1, &dutPort1)
+	atePort2.AddToOTG(top, ap2, &dutPort2)
+	traffic.EnableCapture(top, ap1.ID(), ap2.ID())
+	otg.PushConfig(t, top)
+	otg.StartProtocols(t)
+
+	frame := traffic.Frame{Size: 256, RatePPS: frameRate, Count: frameCount}
+	traffic.AddOTGIPv4Flow(t, ate, top, &traffic.OTGFlowParams{
+		Name:     "encap",
+		Src:      &atePort1,
+		Dst:      &atePort2,
+		SrcPort:  ap1,
+		DstPort:  ap2,
+		Gateway:  &dutPort1,
+		DstStart: testHost,
+		DstCount: 1,
+		Frame:    frame,
+	})
+	decap := traffic.AddOTGIPv4Flow(t, ate, top, &traffic.OTGFlowParams{
+		Name:    "decap",
+		Src:     &atePort2,
+		Dst:     &dutPort2,
+		SrcPort: ap2,
+		DstPort: ap1,
+		Gateway: &dutPort2,
+		Frame:   frame,
+	})
+	decap.Packet().Add().Gre()
+	inner := decap.Packet().Add().Ipv4()
+	inner.Src().SetValue(testHost)
+	inner.Dst().SetValue(atePort1.IPv4)
+	otg.PushConfig(t, top)
+	otg.StartProtocols(t)
+	return top
+}
+
+// runTraffic runs the encap and decap flows together while capturing on
+// both ATE ports, and returns the number of packets each flow sent and
+// the packets captured on each port.
+func runTraffic(t *testing.T, ate *ondatra.ATEDevice, top gosnappi.Config) (map[string]uint64, map[string][]*traffic.Packet) {
+	t.Helper()
+	otg := ate.OTG()
+	ids := map[string]string{}
+	for _, port := range []string{"port1", "port2"} {
+		ids[port] = ate.Port(t, port).ID()
+	}
+	traffic.StartCapture(t, ate, ids["port1"], ids["port2"])
+	r := traffic.RunOTGFlow(t, ate, top, "encap", &traffic.Options{Duration: frameCount/frameRate*time.Second + 2*time.Second})
+	traffic.StopCapture(t, ate, ids["port1"], ids["port2"])
+
+	sent := map[string]uint64{
+		"encap": r.OutPkts,
+		"decap": otg.Telemetry().Flow("decap").Counters().OutPkts().Get(t),
+	}
+	pkts := map[string][]*traffic.Packet{}
+	for port, id := range ids {
+		pkts[port] = traffic.CapturedPackets(t, ate, id)
+	}
+	return sent, pkts
+}
+
+func TestGRETunnel(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	ate := ondatra.ATE(t, "ate")
+	configureDUT(t, dut)
+
+	configureTunnel(t, dut)
+	defer dut.Config().Interface(*tunnelName).Delete(t)
+	static.Configure(t, dut, *deviations.DefaultNetworkInstance, testPrefix, &static.NextHop{
+		Address:   tunnelPeer,
+		Interface: *tunnelName,
+	})
+	defer static.Delete(t, dut, *deviations.DefaultNetworkInstance, testPrefix)
+
+	top := configureATE(t, ate)
+
+	t.Run("State", func(t *testing.T) {
+		state := dut.Telemetry().Interface(*tunnelName)
+		if got, ok := state.OperStatus().Watch(t, stateTimeout, func(v *telemetry.QualifiedE_Interface_OperStatus) bool {
+			return v.IsPresent() && v.Val(t) == telemetry.Interface_OperStatus_UP
+		}).Await(t); !ok {
+			t.Errorf("GRE tunnel %s oper-status got %v, want UP", *tunnelName, got)
+		}
+		if got := state.Type().Get(t); got != telemetry.IETFInterfaces_InterfaceType_tunnel {
+			t.Errorf("GRE tunnel %s type got %v, want tunnel", *tunnelName, got)
+		}
+		awaitTunnelState(t, dut)
+	})
+
+	sent, pkts := runTraffic(t, ate, top)
+
+	t.Run("Encap", func(t *testing.T) {
+		sent := sent["encap"]
+		var encapped, plain int
+		for _, p := range pkts["port2"] {
+			inner := p.Inner()
+			if inner == nil {
+				if h := p.Outer(); h != nil && h.Dst == testHost {
+					plain++
+				}
+				continue
+			}
+			if inner.Src != atePort1.IPv4 || inner.Dst != testHost {
+				continue
+			}
+			if outer := p.Outer(); outer.Protocol != layers.IPProtocolGRE || outer.Src != dutPort2.IPv4 || outer.Dst != atePort2.IPv4 {
+				t.Errorf("Captured packet to %s encapsulated in %+v, want GRE from %s to %s", testHost, *outer, dutPort2.IPv4, atePort2.IPv4)
+				continue
+			}
+			encapped++
+		}
+		t.Logf("Captured %d GRE packets and %d plain packets to %s on ate:port2 of %d sent", encapped, plain, testHost, sent)
+		if uint64(encapped) < sent {
+			t.Errorf("Captured %d GRE packets to %s on ate:port2, want %d", encapped, testHost, sent)
+		}
+		if plain > 0 {
+			t.Errorf("Captured %d packets to %s on ate:port2 not encapsulated, want 0", plain, testHost)
+		}
+	})
+
+	t.Run("Decap", func(t *testing.T) {
+		sent := sent["decap"]
+		var decapped, encapped int
+		for _, p := range pkts["port1"] {
+			h := p.Outer()
+			switch {
+			case h == nil:
+			case h.Protocol == layers.IPProtocolGRE:
+				encapped++
+			case h.Src == testHost && h.Dst == atePort1.IPv4:
+				decapped++
+			}
+		}
+		t.Logf("Captured %d decapsulated packets from %s and %d GRE packets on ate:port1 of %d sent", decapped, testHost, encapped, sent)
+		if uint64(decapped) < sent {
+			t.Errorf("Captured %d decapsulated packets from %s on ate:port1, want %d", decapped, testHost, sent)
+		}
+		if encapped > 0 {
+			t.Errorf("Captured %d GRE packets on ate:port1, want 0", encapped)
+		}
+	})
+}
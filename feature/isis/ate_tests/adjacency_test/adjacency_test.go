@@ -0,0 +1,234 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adjacency_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/isis"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1 and
+// dut:port2 -> ate:port2.
+//
+//   - ate:port1 -> dut:port1 subnet 192.0.2.0/30 and 2001:db8::/126
+//   - ate:port2 -> dut:port2 subnet 192.0.2.4/30 and 2001:db8::4/126
+//
+// The DUT forms a point-to-point Level-2 IS-IS adjacency with
+// ate:port1, which advertises the destination networks, to which
+// traffic is sent from ate:port2.
+const (
+	ipv4PrefixLen = 30
+	ipv6PrefixLen = 126
+
+	ateNet4Name = "isisNet4"
+	ateNet4CIDR = "198.51.100.0/24"
+	ateNet6Name = "isisNet6"
+	ateNet6CIDR = "2001:db8:1::/64"
+
+	dutArea     = "49.0001"
+	dutSystemID = "1920.0000.2001"
+	ateArea     = "49.0001"
+	ateMetric   = 10
+
+	// helloInterval is the hello interval in seconds on both ends, and
+	// helloMultiplier the number of hellos the DUT may miss before the
+	// adjacency times out, i.e. the hold time is their product.
+	helloInterval   = 3
+	helloMultiplier = 3
+	holdTime        = helloInterval * helloMultiplier
+
+	// routeTimeout is how long to wait for the learned routes to be
+	// installed.
+	routeTimeout = time.Minute
+)
+
+var (
+	dutPort1 = attrs.Attributes{
+		Desc:    "dutPort1",
+		IPv4:    "192.0.2.1",
+		IPv4Len: ipv4PrefixLen,
+		IPv6:    "2001:db8::1",
+		IPv6Len: ipv6PrefixLen,
+	}
+
+	atePort1 = attrs.Attributes{
+		Name:    "atePort1",
+		IPv4:    "192.0.2.2",
+		IPv4Len: ipv4PrefixLen,
+		IPv6:    "2001:db8::2",
+		IPv6Len: ipv6PrefixLen,
+	}
+
+	dutPort2 = attrs.Attributes{
+		Desc:    "dutPort2",
+		IPv4:    "192.0.2.5",
+		IPv4Len: ipv4PrefixLen,
+		IPv6:    "2001:db8::5",
+		IPv6Len: ipv6PrefixLen,
+	}
+
+	atePort2 = attrs.Attributes{
+		Name:    "atePort2",
+		IPv4:    "192.0.2.6",
+		IPv4Len: ipv4PrefixLen,
+		IPv6:    "2001:db8::6",
+		IPv6Len: ipv6PrefixLen,
+	}
+
+	// ateRouter is the IS-IS router on ate:port1 advertising the
+	// destination networks.
+	ateRouter = &isis.ATERouter{
+		ATE:           &atePort1,
+		Area:          ateArea,
+		Metric:        ateMetric,
+		NetworkType:   isis.PointToPoint,
+		HelloInterval: helloInterval,
+		HoldTime:      holdTime,
+		Routes: []*isis.Routes{
+			{Name: ateNet4Name, CIDR: ateNet4CIDR},
+			{Name: ateNet6Name, CIDR: ateNet6CIDR},
+		},
+	}
+)
+
+// configureDUT configures port1 and port2 on the DUT, with IS-IS
+// enabled on port1.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	attrs.ConfigureDUTPorts(t, dut, map[string]*attrs.Attributes{
+		"port1": &dutPort1,
+		"port2": &dutPort2,
+	})
+
+	p1 := dut.Port(t, "port1")
+	cfg := isis.DUTConfig(dutArea, dutSystemID, isis.PointToPoint, p1.Name())
+	isis.SetHelloTimers(cfg, p1.Name(), helloInterval, helloMultiplier)
+	isis.ConfigureDUT(t, dut, cfg)
+}
+
+// configureATE configures port1 and port2 on the ATE, with port1
+// advertising the destination networks over IS-IS.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice) *ondatra.ATETopology {
+	top := ate.Topology().New()
+	i1 := atePort1.AddToATE(top, ate.Port(t, "port1"), &dutPort1)
+	ateRouter.AddToATE(t, i1)
+	atePort2.AddToATE(top, ate.Port(t, "port2"), &dutPort2)
+	return top
+}
+
+func TestAdjacency(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	ate := ondatra.ATE(t, "ate")
+
+	configureDUT(t, dut)
+	defer isis.DeleteDUT(t, dut)
+	top := configureATE(t, ate)
+	top.Push(t).StartProtocols(t)
+	defer top.StopProtocols(t)
+
+	p1 := dut.Port(t, "port1").Name()
+	ids := isis.AwaitAdjacency(t, dut, p1)
+	if len(ids) != 1 {
+		t.Fatalf("IS-IS adjacency on DUT interface %s got neighbors %v, want 1", p1, ids)
+	}
+	isisPath := dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).
+		Protocol(telemetry.PolicyTypes_INSTALL_PROTOCOL_TYPE_ISIS, isis.ProtocolName).Isis()
+	levelPath := isisPath.Interface(p1).Level(2)
+	adjPath := levelPath.Adjacency(ids[0])
+
+	t.Run("Parameters", func(t *testing.T) {
+		adj := adjPath.Get(t)
+		if got, want := adj.GetAdjacencyType(), telemetry.IsisTypes_LevelType_LEVEL_2; got != want {
+			t.Errorf("Adjacency adjacency-type got %v, want %v", got, want)
+		}
+		found := false
+		for _, area := range adj.AreaAddress {
+			if area == ateArea {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Adjacency area-address got %v, want %s", adj.AreaAddress, ateArea)
+		}
+		if got, want := adj.GetNeighborIpv4Address(), atePort1.IPv4; got != want {
+			t.Errorf("Adjacency neighbor-ipv4-address got %s, want %s", got, want)
+		}
+		if got, want := isisPath.Level(2).MetricStyle().Get(t), telemetry.IsisTypes_MetricStyle_WIDE_METRIC; got != want {
+			t.Errorf("Level 2 metric-style got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Timers", func(t *testing.T) {
+		timers := levelPath.Timers().Get(t)
+		if got := timers.GetHelloInterval(); got != helloInterval {
+			t.Errorf("Level 2 hello-interval got %d, want %d", got, helloInterval)
+		}
+		if got := timers.GetHelloMultiplier(); got != helloMultiplier {
+			t.Errorf("Level 2 hello-multiplier got %d, want %d", got, helloMultiplier)
+		}
+
+		// The adjacency only stays up past the hold time of either end if
+		// both keep receiving hellos at the configured interval.
+		up := adjPath.UpTimestamp().Get(t)
+		time.Sleep(2 * holdTime * time.Second)
+		if got, want := adjPath.AdjacencyState().Get(t), telemetry.IsisTypes_IsisInterfaceAdjState_UP; got != want {
+			t.Errorf("Adjacency adjacency-state after %ds got %v, want %v", 2*holdTime, got, want)
+		}
+		if got := adjPath.UpTimestamp().Get(t); got != up {
+			t.Errorf("Adjacency up-timestamp after %ds got %d, want %d, i.e. the adjacency flapped", 2*holdTime, got, up)
+		}
+	})
+
+	t.Run("Routes", func(t *testing.T) {
+		afts := dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).Afts()
+		if !fptest.Await(t, afts.Ipv4Entry(ateNet4CIDR).Prefix().Watch, routeTimeout, ateNet4CIDR) {
+			t.Errorf("DUT did not install %s within %v", ateNet4CIDR, routeTimeout)
+		}
+		if !fptest.Await(t, afts.Ipv6Entry(ateNet6CIDR).Prefix().Watch, routeTimeout, ateNet6CIDR) {
+			t.Errorf("DUT did not install %s within %v", ateNet6CIDR, routeTimeout)
+		}
+
+		flow4 := traffic.NewIPv4Flow(t, ate, top, &traffic.FlowParams{
+			Name:       "IPv4",
+			Src:        &atePort2,
+			Dst:        &atePort1,
+			DstNetwork: ateNet4Name,
+		})
+		flow6 := traffic.NewIPv6Flow(t, ate, top, &traffic.FlowParams{
+			Name:       "IPv6",
+			Src:        &atePort2,
+			Dst:        &atePort1,
+			DstNetwork: ateNet6Name,
+			DUT:        dut,
+			SrcPort:    dut.Port(t, "port2"),
+			DstPort:    dut.Port(t, "port1"),
+		})
+		traffic.ValidateFlows(t, ate, []*ondatra.Flow{flow4, flow6}, nil)
+	})
+}
@@ -0,0 +1,284 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hello_auth_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/isis"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+	"github.com/openconfig/ygot/ygot"
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1.
+//
+//   - ate:port1 -> dut:port1 subnet 192.0.2.0/30
+//
+// The DUT forms a point-to-point Level-2 IS-IS adjacency with
+// ate:port1, authenticating the hellos with HMAC-MD5.
+const (
+	ipv4PrefixLen = 30
+
+	ateNetName = "isisNet"
+	ateNetCIDR = "198.51.100.0/24"
+
+	dutArea     = "49.0001"
+	dutSystemID = "1920.0000.2001"
+	ateArea     = "49.0001"
+
+	// helloKey is the key the hellos are authenticated with on both
+	// ends, and wrongKey a key the DUT is not configured with.
+	helloKey = "fp-isis-hello-key"
+	wrongKey = "fp-isis-wrong-key"
+
+	// keychainName is the name of the DUT keychain whose keys, with key
+	// IDs 1 and 2, the DUT accepts in the keychain case.
+	keychainName = "fp-isis-hello"
+
+	// helloInterval is the hello interval in seconds on both ends, and
+	// helloMultiplier the number of hellos the DUT may miss before the
+	// adjacency times out, i.e. the hold time is their product.
+	helloInterval   = 3
+	helloMultiplier = 3
+	holdTime        = helloInterval * helloMultiplier * time.Second
+
+	// dropTimeout is how long to wait for the adjacency to go down after
+	// the ATE key is changed, i.e. the hold time plus the time for the
+	// ATE to apply the change.
+	dropTimeout = holdTime + 10*time.Second
+	// reformTimeout is how long the adjacency must stay down with a
+	// wrong key.
+	reformTimeout = 3 * holdTime
+	// routeTimeout is how long to wait for the learned routes to be
+	// installed.
+	routeTimeout = time.Minute
+)
+
+var (
+	dutPort1 = attrs.Attributes{
+		Desc:    "dutPort1",
+		IPv4:    "192.0.2.1",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort1 = attrs.Attributes{
+		Name:    "atePort1",
+		IPv4:    "192.0.2.2",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	// keychainKeys are the secret keys of the DUT keychain, by key ID.
+	keychainKeys = map[uint64]string{
+		1: "fp-isis-keychain-key-1",
+		2: "fp-isis-keychain-key-2",
+	}
+
+	// ateRouter is the IS-IS router on ate:port1.
+	ateRouter = &isis.ATERouter{
+		ATE:           &atePort1,
+		Area:          ateArea,
+		NetworkType:   isis.PointToPoint,
+		HelloInterval: helloInterval,
+		HoldTime:      uint32(holdTime / time.Second),
+		MD5Key:        helloKey,
+		Routes: []*isis.Routes{
+			{Name: ateNetName, CIDR: ateNetCIDR},
+		},
+	}
+)
+
+// dutISIS returns the IS-IS config of the DUT, with IS-IS enabled on
+// port1 with the given hello authentication.
+func dutISIS(t *testing.T, dut *ondatra.DUTDevice, auth func(*telemetry.NetworkInstance_Protocol_Isis, string)) *telemetry.NetworkInstance_Protocol_Isis {
+	p1 := dut.Port(t, "port1").Name()
+	cfg := isis.DUTConfig(dutArea, dutSystemID, isis.PointToPoint, p1)
+	isis.SetHelloTimers(cfg, p1, helloInterval, helloMultiplier)
+	auth(cfg, p1)
+	return cfg
+}
+
+// helloKeyAuth authenticates the hellos of the interface with helloKey.
+func helloKeyAuth(cfg *telemetry.NetworkInstance_Protocol_Isis, intf string) {
+	isis.SetHelloAuth(cfg, intf, helloKey)
+}
+
+// keychainAuth authenticates the hellos of the interface with the keys
+// of the DUT keychain.
+func keychainAuth(cfg *telemetry.NetworkInstance_Protocol_Isis, intf string) {
+	isis.SetHelloKeychain(cfg, intf, keychainName)
+}
+
+// configureDUT configures port1 on the DUT, with IS-IS enabled on it
+// with hello authentication.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	p1 := dut.Port(t, "port1")
+	dut.Config().Interface(p1.Name()).Replace(t, dutPort1.NewInterface(p1.Name()))
+	isis.ConfigureDUT(t, dut, dutISIS(t, dut, helloKeyAuth))
+}
+
+// configureKeychain configures the DUT keychain with HMAC-MD5 keys.
+func configureKeychain(t *testing.T, dut *ondatra.DUTDevice) {
+	kc := &telemetry.Keychain{Name: ygot.String(keychainName)}
+	for id, secret := range keychainKeys {
+		key := kc.GetOrCreateKey(id)
+		key.SecretKey = ygot.String(secret)
+		key.CryptoAlgorithm = telemetry.KeychainTypes_CRYPTO_TYPE_HMAC_MD5
+	}
+	p := dut.Config().Keychain(keychainName)
+	p.Replace(t, kc)
+	fptest.LogYgot(t, "DUT keychain", p, kc)
+}
+
+// configureATE configures port1 on the ATE as an IS-IS router
+// advertising ateNetCIDR.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice) *ondatra.ATETopology {
+	top := ate.Topology().New()
+	i1 := atePort1.AddToATE(top, ate.Port(t, "port1"), &dutPort1)
+	ateRouter.AddToATE(t, i1)
+	return top
+}
+
+func TestHelloAuthentication(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	ate := ondatra.ATE(t, "ate")
+
+	configureDUT(t, dut)
+	defer isis.DeleteDUT(t, dut)
+	top := configureATE(t, ate)
+	top.Push(t).StartProtocols(t)
+	defer top.StopProtocols(t)
+
+	p1 := dut.Port(t, "port1").Name()
+	ids := isis.AwaitAdjacency(t, dut, p1)
+	if len(ids) != 1 {
+		t.Fatalf("IS-IS adjacency on DUT interface %s got neighbors %v, want 1", p1, ids)
+	}
+	levelPath := dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).
+		Protocol(telemetry.PolicyTypes_INSTALL_PROTOCOL_TYPE_ISIS, isis.ProtocolName).Isis().
+		Interface(p1).Level(2)
+	adjState := levelPath.Adjacency(ids[0]).AdjacencyState()
+	authFails := dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).
+		Protocol(telemetry.PolicyTypes_INSTALL_PROTOCOL_TYPE_ISIS, isis.ProtocolName).Isis().
+		Interface(p1).CircuitCounters().AuthFails()
+
+	t.Run("MatchingKey", func(t *testing.T) {
+		auth := levelPath.HelloAuthentication().Get(t)
+		if !auth.GetEnabled() {
+			t.Errorf("Level 2 hello-authentication enabled got false, want true")
+		}
+		if got, want := auth.GetAuthType(), telemetry.KeychainTypes_AUTH_TYPE_SIMPLE_KEY; got != want {
+			t.Errorf("Level 2 hello-authentication auth-type got %v, want %v", got, want)
+		}
+		if got, want := auth.GetAuthMode(), telemetry.IsisTypes_AUTH_MODE_MD5; got != want {
+			t.Errorf("Level 2 hello-authentication auth-mode got %v, want %v", got, want)
+		}
+		aftPrefix := dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).Afts().Ipv4Entry(ateNetCIDR).Prefix()
+		if !fptest.Await(t, aftPrefix.Watch, routeTimeout, ateNetCIDR) {
+			t.Errorf("DUT did not install %s within %v", ateNetCIDR, routeTimeout)
+		}
+	})
+
+	t.Run("WrongKey", func(t *testing.T) {
+		var failsBefore uint32
+		if q := authFails.Lookup(t); q.IsPresent() {
+			failsBefore = q.Val(t)
+		}
+
+		ateRouter.SetMD5Key(t, top, wrongKey)
+		if _, ok := adjState.Watch(t, dropTimeout, func(q *telemetry.QualifiedE_IsisTypes_IsisInterfaceAdjState) bool {
+			return !q.IsPresent() || q.Val(t) != telemetry.IsisTypes_IsisInterfaceAdjState_UP
+		}).Await(t); !ok {
+			t.Errorf("IS-IS adjacency on DUT interface %s is still UP %v after changing the ATE key", p1, dropTimeout)
+		}
+		if _, ok := adjState.Watch(t, reformTimeout, func(q *telemetry.QualifiedE_IsisTypes_IsisInterfaceAdjState) bool {
+			return q.IsPresent() && q.Val(t) == telemetry.IsisTypes_IsisInterfaceAdjState_UP
+		}).Await(t); ok {
+			t.Errorf("IS-IS adjacency on DUT interface %s came UP within %v with a wrong key", p1, reformTimeout)
+		}
+		if q := adjState.Lookup(t); q.IsPresent() {
+			t.Logf("IS-IS adjacency on DUT interface %s adjacency-state %v with a wrong key", p1, q.Val(t))
+		}
+
+		if q := authFails.Lookup(t); !q.IsPresent() {
+			t.Logf("DUT interface %s does not report IS-IS circuit auth-fails", p1)
+		} else if got := q.Val(t); got <= failsBefore {
+			t.Errorf("DUT interface %s circuit auth-fails got %d with a wrong key, want more than %d", p1, got, failsBefore)
+		}
+
+		ateRouter.SetMD5Key(t, top, helloKey)
+		if _, err := isis.Adjacency(t, dut, p1, isis.AdjacencyTimeout); err != nil {
+			t.Fatalf("After restoring the ATE key: %v", err)
+		}
+	})
+
+	t.Run("Keychain", func(t *testing.T) {
+		if *deviations.ISISKeychainUnsupported {
+			t.Skip("IS-IS hello authentication with a keychain is not supported")
+		}
+		configureKeychain(t, dut)
+		defer dut.Config().Keychain(keychainName).Delete(t)
+		isis.ConfigureDUT(t, dut, dutISIS(t, dut, keychainAuth))
+		defer isis.ConfigureDUT(t, dut, dutISIS(t, dut, helloKeyAuth))
+		defer ateRouter.SetMD5Key(t, top, helloKey)
+
+		auth := levelPath.HelloAuthentication().Get(t)
+		if got, want := auth.GetAuthType(), telemetry.KeychainTypes_AUTH_TYPE_KEYCHAIN; got != want {
+			t.Errorf("Level 2 hello-authentication auth-type got %v, want %v", got, want)
+		}
+		if got := auth.GetKeychain(); got != keychainName {
+			t.Errorf("Level 2 hello-authentication keychain got %q, want %q", got, keychainName)
+		}
+
+		// The DUT signs its hellos with one of the keys only, so the
+		// adjacency is only certain to come UP with the ATE using that
+		// key.  With the other key, the DUT must still accept the hellos
+		// of the ATE, i.e. keep the adjacency in INIT or UP with no new
+		// authentication failures.
+		ateRouter.SetMD5Key(t, top, keychainKeys[1])
+		if _, err := isis.Adjacency(t, dut, p1, isis.AdjacencyTimeout); err != nil {
+			t.Fatalf("With key 1 of the keychain: %v", err)
+		}
+
+		var failsBefore uint32
+		if q := authFails.Lookup(t); q.IsPresent() {
+			failsBefore = q.Val(t)
+		}
+		ateRouter.SetMD5Key(t, top, keychainKeys[2])
+		time.Sleep(dropTimeout + holdTime)
+		switch q := adjState.Lookup(t); {
+		case !q.IsPresent():
+			t.Errorf("IS-IS adjacency on DUT interface %s is missing with key 2 of the keychain", p1)
+		case q.Val(t) == telemetry.IsisTypes_IsisInterfaceAdjState_INIT, q.Val(t) == telemetry.IsisTypes_IsisInterfaceAdjState_UP:
+			t.Logf("IS-IS adjacency on DUT interface %s adjacency-state %v with key 2 of the keychain", p1, q.Val(t))
+		default:
+			t.Errorf("IS-IS adjacency on DUT interface %s adjacency-state got %v with key 2 of the keychain, want INIT or UP", p1, q.Val(t))
+		}
+		if q := authFails.Lookup(t); q.IsPresent() && q.Val(t) > failsBefore {
+			t.Errorf("DUT interface %s circuit auth-fails got %d with key 2 of the keychain, want %d", p1, q.Val(t), failsBefore)
+		}
+	})
+}
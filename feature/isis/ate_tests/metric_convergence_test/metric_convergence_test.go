@@ -0,0 +1,258 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metric_convergence_test
+
+import (
+	"flag"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/aftcheck"
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/isis"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/ondatra"
+)
+
+var (
+	maxDataplaneConvergence = flag.Duration("max_dataplane_convergence", time.Second,
+		"Maximum traffic outage while the traffic shifts to the other path after a metric change.")
+	maxRIBConvergence = flag.Duration("max_rib_convergence", 5*time.Second,
+		"Maximum time from the metric change until the AFT telemetry reports the new next hop.")
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1,
+// dut:port2 -> ate:port2 and dut:port3 -> ate:port3.
+//
+//   - ate:port1 -> dut:port1 subnet 192.0.2.0/30
+//   - ate:port2 -> dut:port2 subnet 192.0.2.4/30
+//   - ate:port3 -> dut:port3 subnet 192.0.2.8/30
+//
+// The DUT forms Level-2 IS-IS adjacencies with ate:port2 and ate:port3,
+// which both advertise the destination network, to which traffic is
+// sent from ate:port1 throughout the test.
+const (
+	ipv4PrefixLen = 30
+	ateDstNetCIDR = "198.51.100.0/24"
+	ateDstNetName = "isisNet"
+
+	dutArea     = "49.0001"
+	dutSystemID = "1920.0000.2001"
+	ateArea     = "49.0001"
+
+	// The metrics the destination network is advertised with from
+	// ate:port2, initially and after the change, and from ate:port3.
+	port2Metric      = 10
+	port2RaiseMetric = 30
+	port3Metric      = 20
+
+	frameRate    = 1000 // frames per second
+	routeTimeout = time.Minute
+	pollInterval = time.Second
+	// settleTime is how long the background traffic runs before a
+	// change, so that the sample intervals around the change are
+	// complete.
+	settleTime = 5 * time.Second
+	// balanceTolerancePct is the maximum deviation in percentage points
+	// of the fraction of traffic received on each port from the
+	// expected fraction.
+	balanceTolerancePct = 1
+)
+
+var (
+	dutPort1 = attrs.Attributes{
+		Desc:    "dutPort1",
+		IPv4:    "192.0.2.1",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort1 = attrs.Attributes{
+		Name:    "atePort1",
+		IPv4:    "192.0.2.2",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	dutPort2 = attrs.Attributes{
+		Desc:    "dutPort2",
+		IPv4:    "192.0.2.5",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort2 = attrs.Attributes{
+		Name:    "atePort2",
+		IPv4:    "192.0.2.6",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	dutPort3 = attrs.Attributes{
+		Desc:    "dutPort3",
+		IPv4:    "192.0.2.9",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort3 = attrs.Attributes{
+		Name:    "atePort3",
+		IPv4:    "192.0.2.10",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	// ateRouter2 and ateRouter3 are the IS-IS routers on ate:port2 and
+	// ate:port3 advertising the destination network.
+	ateRouter2 = &isis.ATERouter{
+		ATE:         &atePort2,
+		Area:        ateArea,
+		NetworkType: isis.PointToPoint,
+		Routes:      []*isis.Routes{{Name: ateDstNetName, CIDR: ateDstNetCIDR, Metric: port2Metric}},
+	}
+	ateRouter3 = &isis.ATERouter{
+		ATE:         &atePort3,
+		Area:        ateArea,
+		NetworkType: isis.PointToPoint,
+		Routes:      []*isis.Routes{{Name: ateDstNetName, CIDR: ateDstNetCIDR, Metric: port3Metric}},
+	}
+
+	// distributionOpts measure the distribution of the traffic across
+	// ports once it settled after a change.
+	distributionOpts = &traffic.DistributionOptions{Settle: true}
+)
+
+// configureDUT configures port1, port2 and port3 on the DUT, with IS-IS
+// enabled on port2 and port3.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	attrs.ConfigureDUTPorts(t, dut, map[string]*attrs.Attributes{
+		"port1": &dutPort1,
+		"port2": &dutPort2,
+		"port3": &dutPort3,
+	})
+
+	p2 := dut.Port(t, "port2")
+	p3 := dut.Port(t, "port3")
+	isis.ConfigureDUT(t, dut, isis.DUTConfig(dutArea, dutSystemID, isis.PointToPoint, p2.Name(), p3.Name()))
+}
+
+// configureATE configures port1, port2 and port3 on the ATE, with port2
+// and port3 advertising the destination network over IS-IS.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice) *ondatra.ATETopology {
+	top := ate.Topology().New()
+	atePort1.AddToATE(top, ate.Port(t, "port1"), &dutPort1)
+	i2 := atePort2.AddToATE(top, ate.Port(t, "port2"), &dutPort2)
+	ateRouter2.AddToATE(t, i2)
+	i3 := atePort3.AddToATE(top, ate.Port(t, "port3"), &dutPort3)
+	ateRouter3.AddToATE(t, i3)
+	return top
+}
+
+// awaitNextHop waits for the AFT entry of the destination network to
+// have a single next hop to the ATE address, and returns when it was
+// observed.
+func awaitNextHop(t *testing.T, dut *ondatra.DUTDevice, want string) (time.Time, bool) {
+	var got []string
+	for deadline := time.Now().Add(routeTimeout); time.Now().Before(deadline); time.Sleep(pollInterval) {
+		got = nil
+		for _, nh := range aftcheck.EntryNextHops(t, dut, *deviations.DefaultNetworkInstance, ateDstNetCIDR) {
+			got = append(got, nh.IPAddress)
+		}
+		if len(got) == 1 && got[0] == want {
+			return time.Now(), true
+		}
+	}
+	t.Errorf("AFT next hops of %s got %v, want [%s]", ateDstNetCIDR, got, want)
+	return time.Now(), false
+}
+
+// sinceEvent returns the time from the event until t, or zero if t is
+// before the event.
+func sinceEvent(event, t time.Time) time.Duration {
+	if t.Before(event) {
+		return 0
+	}
+	return t.Sub(event)
+}
+
+func TestMetricConvergence(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	ate := ondatra.ATE(t, "ate")
+
+	configureDUT(t, dut)
+	defer isis.DeleteDUT(t, dut)
+	top := configureATE(t, ate)
+	top.Push(t).StartProtocols(t)
+	defer top.StopProtocols(t)
+
+	isis.AwaitAdjacency(t, dut, dut.Port(t, "port2").Name())
+	isis.AwaitAdjacency(t, dut, dut.Port(t, "port3").Name())
+
+	flow := traffic.NewIPv4Flow(t, ate, top, &traffic.FlowParams{
+		Name:       "Metric",
+		Src:        &atePort1,
+		Dst:        &atePort2,
+		AltDsts:    []*attrs.Attributes{&atePort3},
+		DstNetwork: ateDstNetName,
+	})
+
+	cases := []struct {
+		desc        string
+		metric      uint32
+		wantNextHop string
+		wantWeights []uint64
+	}{
+		{"Initial", 0, atePort2.IPv4, []uint64{1, 0}},
+		{"Raised", port2RaiseMetric, atePort3.IPv4, []uint64{0, 1}},
+		{"Reverted", port2Metric, atePort2.IPv4, []uint64{1, 0}},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			bg := traffic.StartBackground(t, ate, flow, frameRate)
+			time.Sleep(settleTime)
+
+			var changeTime time.Time
+			if c.metric > 0 {
+				changeTime = ateRouter2.SetRouteMetric(t, top, ateDstNetName, c.metric)
+			}
+			ribTime, ok := awaitNextHop(t, dut, c.wantNextHop)
+			traffic.CheckDistribution(t, ate, []string{"port2", "port3"}, c.wantWeights, balanceTolerancePct, distributionOpts)
+			bg.Stop(t)
+			if !ok || changeTime.IsZero() {
+				return
+			}
+
+			outage, _ := bg.OutageSince(changeTime)
+			conv := &traffic.Convergence{
+				Event:     fmt.Sprintf("IS-IS metric of %s via %s to %d", ateDstNetCIDR, atePort2.Name, c.metric),
+				Time:      changeTime,
+				Dataplane: outage.Duration,
+				RIB:       sinceEvent(changeTime, ribTime),
+			}
+			traffic.RecordConvergence(t, conv)
+			t.Logf("Metric change to %d converged with %v outage in the dataplane and in %v in the RIB", c.metric, conv.Dataplane, conv.RIB)
+			if conv.Dataplane > *maxDataplaneConvergence {
+				t.Errorf("Dataplane convergence after the metric change got %v, want at most %v", conv.Dataplane, *maxDataplaneConvergence)
+			}
+			if conv.RIB > *maxRIBConvergence {
+				t.Errorf("RIB convergence after the metric change got %v, want at most %v", conv.RIB, *maxRIBConvergence)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package route_learning_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/isis"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1 and
+// dut:port2 -> ate:port2.
+//
+//   - ate:port1 -> dut:port1 subnet 192.0.2.0/30
+//   - ate:port2 -> dut:port2 subnet 192.0.2.4/30
+//
+// The DUT forms a Level-2 IS-IS adjacency with ate:port2, which
+// advertises the destination network 198.51.100.0/24.
+const (
+	ipv4PrefixLen = 30
+	ateDstNetCIDR = "198.51.100.0/24"
+	ateDstNetName = "isisNet"
+
+	dutArea     = "49.0001"
+	dutSystemID = "1920.0000.2001"
+	ateArea     = "49.0001"
+
+	// routeTimeout is how long to wait for the learned route to be
+	// installed or removed.
+	routeTimeout = time.Minute
+)
+
+var (
+	dutPort1 = attrs.Attributes{
+		Desc:    "dutPort1",
+		IPv4:    "192.0.2.1",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort1 = attrs.Attributes{
+		Name:    "atePort1",
+		IPv4:    "192.0.2.2",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	dutPort2 = attrs.Attributes{
+		Desc:    "dutPort2",
+		IPv4:    "192.0.2.5",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort2 = attrs.Attributes{
+		Name:    "atePort2",
+		IPv4:    "192.0.2.6",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	// ateRouter is the IS-IS router on ate:port2 advertising the
+	// destination network.
+	ateRouter = &isis.ATERouter{
+		ATE:         &atePort2,
+		Area:        ateArea,
+		Metric:      10,
+		NetworkType: isis.PointToPoint,
+		Routes:      []*isis.Routes{{Name: ateDstNetName, CIDR: ateDstNetCIDR}},
+	}
+)
+
+// configureDUT configures port1 and port2 on the DUT, with IS-IS
+// enabled on port2.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	attrs.ConfigureDUTPorts(t, dut, map[string]*attrs.Attributes{
+		"port1": &dutPort1,
+		"port2": &dutPort2,
+	})
+
+	p2 := dut.Port(t, "port2")
+	isis.ConfigureDUT(t, dut, isis.DUTConfig(dutArea, dutSystemID, isis.PointToPoint, p2.Name()))
+}
+
+// configureATE configures port1 and port2 on the ATE, with port2
+// advertising the destination network over IS-IS.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice) *ondatra.ATETopology {
+	top := ate.Topology().New()
+	atePort1.AddToATE(top, ate.Port(t, "port1"), &dutPort1)
+	i2 := atePort2.AddToATE(top, ate.Port(t, "port2"), &dutPort2)
+	ateRouter.AddToATE(t, i2)
+	return top
+}
+
+// awaitRoute waits for the destination network to be present or
+// absent in the AFT.
+func awaitRoute(t *testing.T, dut *ondatra.DUTDevice, present bool) {
+	t.Helper()
+	ipv4Path := dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).Afts().Ipv4Entry(ateDstNetCIDR)
+	if got, ok := ipv4Path.Prefix().Watch(t, routeTimeout, func(val *telemetry.QualifiedString) bool {
+		if !present {
+			return !val.IsPresent()
+		}
+		return val.IsPresent() && val.Val(t) == ateDstNetCIDR
+	}).Await(t); !ok {
+		t.Fatalf("ipv4-entry/state/prefix got %v, want present %t for %s", got, present, ateDstNetCIDR)
+	}
+}
+
+func TestRouteLearning(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	ate := ondatra.ATE(t, "ate")
+
+	configureDUT(t, dut)
+	defer isis.DeleteDUT(t, dut)
+	top := configureATE(t, ate)
+	top.Push(t).StartProtocols(t)
+	defer top.StopProtocols(t)
+
+	isis.AwaitAdjacency(t, dut, dut.Port(t, "port2").Name())
+
+	flowParams := &traffic.FlowParams{
+		Name:       "Flow",
+		Src:        &atePort1,
+		Dst:        &atePort2,
+		DstNetwork: ateDstNetName,
+	}
+
+	t.Run("Learned", func(t *testing.T) {
+		awaitRoute(t, dut, true)
+		traffic.ValidateFlow(t, ate, traffic.NewIPv4Flow(t, ate, top, flowParams), nil)
+	})
+
+	t.Run("Withdrawn", func(t *testing.T) {
+		ateRouter.SetAdvertised(t, top, ateDstNetName, false)
+		awaitRoute(t, dut, false)
+		traffic.ValidateFlow(t, ate, traffic.NewIPv4Flow(t, ate, top, flowParams), &traffic.Options{WantLoss: true})
+	})
+
+	t.Run("Readvertised", func(t *testing.T) {
+		ateRouter.SetAdvertised(t, top, ateDstNetName, true)
+		awaitRoute(t, dut, true)
+		traffic.ValidateFlow(t, ate, traffic.NewIPv4Flow(t, ate, top, flowParams), nil)
+	})
+}
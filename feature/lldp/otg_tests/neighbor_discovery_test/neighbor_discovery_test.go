@@ -0,0 +1,258 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package neighbor_discovery_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/confirm"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+	"github.com/openconfig/ygot/ygot"
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// The testbed consists of ate:port1 -> dut:port1 and
+// ate:port2 -> dut:port2, with no addresses.  Each ATE port advertises
+// itself to the DUT through LLDP, as described by ateLLDP, and the
+// packets the DUT sends to ate:port1 are captured.
+const (
+	// helloTimer is the LLDP advertisement interval of the DUT in
+	// seconds.
+	helloTimer = 5
+	// ateTTL is the TTL the ATE advertises, after which the DUT ages
+	// the ATE out once it stops advertising.  The ATE advertises once
+	// per second.
+	ateTTL = 20 * time.Second
+
+	// lldpTimeout is how long to wait for the DUT to learn or forget a
+	// neighbor after a change of config.
+	lldpTimeout = time.Minute
+	// ageSlack is how much earlier than ateTTL after the ATE stopped
+	// advertising the DUT may report the neighbor aged out, allowing
+	// for the last advertisement preceding the stop and for telemetry
+	// delays, and how much later than ateTTL it must have.
+	ageSlack = 5 * time.Second
+	// relearnWait is how long the DUT must not learn a neighbor on an
+	// interface with LLDP disabled while the ATE advertises on it.
+	relearnWait = 10 * time.Second
+)
+
+var (
+	portIDs = []string{"port1", "port2"}
+
+	ateLLDP = map[string]*traffic.LLDP{
+		"port1": {
+			ChassisID:  "00:00:5e:00:53:01",
+			PortID:     "ate-port1",
+			SystemName: "ate-lldp",
+			TTL:        uint16(ateTTL / time.Second),
+		},
+		"port2": {
+			ChassisID:  "00:00:5e:00:53:01",
+			PortID:     "ate-port2",
+			SystemName: "ate-lldp",
+			TTL:        uint16(ateTTL / time.Second),
+		},
+	}
+)
+
+// configureDUT enables port1 and port2 on the DUT, and LLDP globally
+// and on both ports.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	d := dut.Config()
+	l := &telemetry.Lldp{
+		Enabled:    ygot.Bool(true),
+		HelloTimer: ygot.Uint64(helloTimer),
+	}
+	for _, id := range portIDs {
+		dp := dut.Port(t, id)
+		i := &telemetry.Interface{
+			Name:        ygot.String(dp.Name()),
+			Description: ygot.String(dp.String()),
+			Type:        telemetry.IETFInterfaces_InterfaceType_ethernetCsmacd,
+			Enabled:     ygot.Bool(true),
+		}
+		d.Interface(dp.Name()).Replace(t, i)
+		l.GetOrCreateInterface(dp.Name()).Enabled = ygot.Bool(true)
+	}
+	fptest.LogYgot(t, "DUT LLDP", d.Lldp(), l)
+	d.Lldp().Replace(t, l)
+}
+
+// configureATE configures port1 and port2 on the ATE to advertise
+// ateLLDP, with a capture of the packets received on port1.  Nothing is
+// advertised until the traffic is started.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice) *traffic.Capture {
+	top := ate.OTG().NewConfig(t)
+	for _, id := range portIDs {
+		ap := ate.Port(t, id)
+		top.Ports().Add().SetName(ap.ID())
+		traffic.AddOTGLLDPFlow(t, top, ap, ateLLDP[id])
+	}
+	capture := traffic.NewCapture(t, ate, top, ate.Port(t, "port1"))
+	ate.OTG().PushConfig(t, top)
+	return capture
+}
+
+// neighbor returns the neighbor of the DUT LLDP interface with the
+// port-id advertised by the ATE, or nil if there is none.
+func neighbor(li *telemetry.Lldp_Interface, l *traffic.LLDP) *telemetry.Lldp_Interface_Neighbor {
+	for _, n := range li.Neighbor {
+		if n.GetPortId() == l.PortID {
+			return n
+		}
+	}
+	return nil
+}
+
+// awaitNeighbor waits for the DUT port to have the neighbor advertised
+// by the ATE port with the same ID if want is set, and not to have it
+// otherwise.  It returns the neighbor, if any, and whether it was as
+// wanted within the timeout.
+func awaitNeighbor(t *testing.T, dut *ondatra.DUTDevice, id string, want bool, timeout time.Duration) (*telemetry.Lldp_Interface_Neighbor, bool) {
+	t.Helper()
+	var nbr *telemetry.Lldp_Interface_Neighbor
+	_, ok := dut.Telemetry().Lldp().Interface(dut.Port(t, id).Name()).Watch(t, timeout, func(val *telemetry.QualifiedLldp_Interface) bool {
+		nbr = nil
+		if val.IsPresent() {
+			nbr = neighbor(val.Val(t), ateLLDP[id])
+		}
+		return (nbr != nil) == want
+	}).Await(t)
+	return nbr, ok
+}
+
+// verifyNeighbor waits for the DUT port to learn the neighbor
+// advertised by the ATE port with the same ID, and checks that it is
+// reported as advertised.
+func verifyNeighbor(t *testing.T, dut *ondatra.DUTDevice, id string) {
+	t.Helper()
+	l := ateLLDP[id]
+	got, ok := awaitNeighbor(t, dut, id, true, lldpTimeout)
+	if !ok {
+		t.Errorf("DUT port %s did not learn LLDP neighbor %s within %v", id, l.PortID, lldpTimeout)
+		return
+	}
+	want := &telemetry.Lldp_Interface_Neighbor{
+		ChassisId:     ygot.String(l.ChassisID),
+		ChassisIdType: telemetry.LldpTypes_ChassisIdType_MAC_ADDRESS,
+		PortId:        ygot.String(l.PortID),
+		PortIdType:    telemetry.LldpTypes_PortIdType_INTERFACE_NAME,
+		SystemName:    ygot.String(l.SystemName),
+		Ttl:           ygot.Uint16(l.TTL),
+	}
+	confirm.State(t, want, got)
+}
+
+func TestNeighborDiscovery(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	configureDUT(t, dut)
+
+	ate := ondatra.ATE(t, "ate")
+	capture := configureATE(t, ate)
+	otg := ate.OTG()
+
+	t.Run("DUTConfig", func(t *testing.T) {
+		lp := dut.Telemetry().Lldp()
+		state := lp.Get(t)
+		fptest.LogYgot(t, "DUT LLDP", lp, state)
+		if !state.GetEnabled() {
+			t.Errorf("DUT LLDP enabled got false, want true")
+		}
+		if got, want := state.GetHelloTimer(), uint64(helloTimer); got != want {
+			t.Errorf("DUT LLDP hello-timer got %d, want %d", got, want)
+		}
+		for _, id := range portIDs {
+			name := dut.Port(t, id).Name()
+			if !state.GetInterface(name).GetEnabled() {
+				t.Errorf("DUT LLDP interface %s enabled got false, want true", name)
+			}
+		}
+	})
+
+	// The capture runs the LLDP traffic of the ATE, and stops it once
+	// done.
+	t.Run("DUTAdvertisement", func(t *testing.T) {
+		wantName := dut.Telemetry().Lldp().SystemName().Get(t)
+		var lldps []*traffic.LLDP
+		for _, p := range capture.Run(t, 2*helloTimer*time.Second+time.Second) {
+			if p.LLDP != nil {
+				lldps = append(lldps, p.LLDP)
+			}
+		}
+		if len(lldps) == 0 {
+			t.Fatalf("ATE port1 received no LLDP frames from the DUT within %d seconds", 2*helloTimer+1)
+		}
+		t.Logf("ATE port1 received %d LLDP frames from the DUT, the first %+v", len(lldps), *lldps[0])
+		for _, l := range lldps {
+			if l.SystemName != wantName {
+				t.Errorf("DUT LLDP system-name received by ATE port1 got %q, want %q", l.SystemName, wantName)
+			}
+		}
+	})
+
+	otg.StartTraffic(t)
+	defer otg.StopTraffic(t)
+
+	t.Run("Neighbors", func(t *testing.T) {
+		for _, id := range portIDs {
+			verifyNeighbor(t, dut, id)
+		}
+	})
+
+	t.Run("InterfaceDisabled", func(t *testing.T) {
+		name := dut.Port(t, "port2").Name()
+		enabled := dut.Config().Lldp().Interface(name).Enabled()
+		enabled.Replace(t, false)
+		defer enabled.Replace(t, true)
+
+		if _, ok := awaitNeighbor(t, dut, "port2", false, lldpTimeout); !ok {
+			t.Fatalf("DUT port2 with LLDP disabled did not forget its neighbor within %v", lldpTimeout)
+		}
+		// The ATE keeps advertising on both ports.
+		time.Sleep(relearnWait)
+		if li := dut.Telemetry().Lldp().Interface(name).Lookup(t); li.IsPresent() && neighbor(li.Val(t), ateLLDP["port2"]) != nil {
+			t.Errorf("DUT port2 with LLDP disabled learned neighbor %s within %v", ateLLDP["port2"].PortID, relearnWait)
+		}
+		verifyNeighbor(t, dut, "port1")
+	})
+
+	t.Run("InterfaceEnabled", func(t *testing.T) {
+		verifyNeighbor(t, dut, "port2")
+	})
+
+	t.Run("AgedOut", func(t *testing.T) {
+		otg.StopTraffic(t)
+		stopped := time.Now()
+		if _, ok := awaitNeighbor(t, dut, "port1", false, ateTTL+ageSlack); !ok {
+			t.Fatalf("DUT port1 did not age out its neighbor within %v of the ATE stopping LLDP", ateTTL+ageSlack)
+		}
+		aged := time.Since(stopped)
+		t.Logf("DUT port1 aged out its neighbor %v after the ATE stopped LLDP", aged)
+		if aged < ateTTL-ageSlack {
+			t.Errorf("DUT port1 aged out its neighbor after %v, want at least %v", aged, ateTTL-ageSlack)
+		}
+		if _, ok := awaitNeighbor(t, dut, "port2", false, ageSlack); !ok {
+			t.Errorf("DUT port2 did not age out its neighbor within %v of the ATE stopping LLDP", ateTTL+ageSlack)
+		}
+	})
+}
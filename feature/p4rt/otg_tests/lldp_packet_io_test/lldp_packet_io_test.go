@@ -0,0 +1,295 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lldp_packet_io_test
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/openconfig/featureprofiles/feature/experimental/p4rt/wbb"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/p4rtutils"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+	"github.com/openconfig/ygot/ygot"
+
+	p4pb "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+var (
+	p4InfoFile     = flag.String("p4info_file_location", "../../../experimental/p4rt/wbb.p4info.pb.txt", "Path to the p4info file.")
+	nodeID         = flag.Uint64("p4rt_node_id", 1, "P4RT node-id configured on the integrated circuit of dut:port1 if it has none.")
+	puntPolicerPPS = flag.Int64("punt_policer_pps", 1000, "Rate in packets per second of the policer of the packets the DUT punts to the controller.")
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// The testbed consists of ate:port1 -> dut:port1 and
+// dut:port2 -> ate:port2, with no addresses.  ate:port1 sends LLDP
+// frames, which the DUT punts to the P4RT client, and the P4RT client
+// sends LLDP frames out of dut:port2, which ate:port2 captures.
+const (
+	// port1ID and port2ID are the P4RT port IDs of the DUT ports.
+	port1ID = 10
+	port2ID = 11
+
+	// electionID is the election ID of the P4RT client, and cookie the
+	// cookie of the forwarding pipeline it installs.
+	electionID = 100
+	cookie     = 159
+
+	// Metadata IDs of the packet_in and packet_out controller headers of
+	// the P4Info.
+	ingressPortMetadata     = 1
+	egressPortMetadata      = 1
+	submitToIngressMetadata = 2
+
+	// lldpPPS is the rate of the LLDP frames sent by the ATE, below the
+	// punt policer, and runTime how long they are sent for.
+	lldpPPS = 10
+	runTime = 10 * time.Second
+	// lossSlack is how many of the frames sent the P4RT client may miss
+	// below the punt policer, e.g. sent as the traffic stops.
+	lossSlack = 2
+	// policerFactor is how many times the rate of the punt policer the
+	// ATE sends at to check the drops.
+	policerFactor = 10
+
+	// packetOutCount is the number of PacketOuts sent out of dut:port2,
+	// and captureWait how long ate:port2 captures after the last one.
+	packetOutCount = 10
+	captureWait    = 2 * time.Second
+
+	arbitrationTimeout = 10 * time.Second
+)
+
+var (
+	// ateLLDP is what ate:port1 advertises, and controllerLLDP what the
+	// P4RT client sends out of dut:port2.
+	ateLLDP = &traffic.LLDP{
+		ChassisID:  "00:00:5e:00:53:01",
+		PortID:     "ate-port1",
+		SystemName: "ate-lldp",
+		TTL:        120,
+	}
+	controllerLLDP = &traffic.LLDP{
+		ChassisID:  "00:00:5e:00:53:02",
+		PortID:     "p4rt-controller",
+		SystemName: "p4rt-controller",
+		TTL:        120,
+	}
+)
+
+// configureDUT enables port1 and port2 on the DUT with their P4RT port
+// IDs, and disables LLDP so that the DUT neither consumes the LLDP
+// frames of the ATE nor sends its own.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	d := dut.Config()
+	for id, portID := range map[string]uint32{"port1": port1ID, "port2": port2ID} {
+		dp := dut.Port(t, id)
+		i := &telemetry.Interface{
+			Name:        ygot.String(dp.Name()),
+			Description: ygot.String(dp.String()),
+			Type:        telemetry.IETFInterfaces_InterfaceType_ethernetCsmacd,
+			Enabled:     ygot.Bool(true),
+			Id:          ygot.Uint32(portID),
+		}
+		d.Interface(dp.Name()).Replace(t, i)
+		fptest.LogYgot(t, dp.String(), d.Interface(dp.Name()), i)
+	}
+	d.Lldp().Enabled().Replace(t, false)
+}
+
+// configureATE configures port1 on the ATE to send ateLLDP, with a
+// capture of the packets received on port2.  Nothing is sent until the
+// traffic is started.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice) (gosnappi.Config, gosnappi.Flow) {
+	top := ate.OTG().NewConfig(t)
+	ap1 := ate.Port(t, "port1")
+	ap2 := ate.Port(t, "port2")
+	top.Ports().Add().SetName(ap1.ID())
+	top.Ports().Add().SetName(ap2.ID())
+	flow := traffic.AddOTGLLDPFlow(t, top, ap1, ateLLDP)
+	traffic.EnableCapture(top, ap2.ID())
+	return top, flow
+}
+
+// lldpEntry returns the update of the ACL entry trapping LLDP frames to
+// the controller.
+func lldpEntry(typ p4pb.Update_Type) []*p4pb.Update {
+	return wbb.ACLWbbIngressTableEntryGet([]*wbb.ACLWbbIngressTableEntryInfo{{
+		Type:          typ,
+		EtherType:     traffic.LLDPEtherType,
+		EtherTypeMask: 0xFFFF,
+		Priority:      1,
+	}})
+}
+
+// sendLLDP sends the LLDP frames of the flow at the rate for runTime,
+// and returns the packets the P4RT client received meanwhile and the
+// number of frames the ATE sent.
+func sendLLDP(t *testing.T, ate *ondatra.ATEDevice, top gosnappi.Config, flow gosnappi.Flow, stream *p4rtutils.Stream, pps int64) ([]*p4pb.PacketIn, uint64) {
+	t.Helper()
+	flow.Rate().SetPps(pps)
+	otg := ate.OTG()
+	otg.PushConfig(t, top)
+	otg.StartTraffic(t)
+	pkts, err := stream.PacketIns(runTime)
+	otg.StopTraffic(t)
+	if err != nil {
+		t.Fatalf("P4RT stream ended receiving packets: %v", err)
+	}
+	// Packets punted as the traffic stopped.
+	more, err := stream.PacketIns(time.Second)
+	if err != nil {
+		t.Fatalf("P4RT stream ended receiving packets: %v", err)
+	}
+	pkts = append(pkts, more...)
+	sent := otg.Telemetry().Flow(flow.Name()).Get(t).GetCounters().GetOutPkts()
+	t.Logf("ATE sent %d LLDP frames at %d pps, P4RT client received %d packets", sent, pps, len(pkts))
+	return pkts, sent
+}
+
+// testPacketIn checks that the LLDP frames sent below the punt policer
+// are received as PacketIns with the ingress port and frame sent.
+func testPacketIn(t *testing.T, ate *ondatra.ATEDevice, top gosnappi.Config, flow gosnappi.Flow, stream *p4rtutils.Stream) {
+	pkts, sent := sendLLDP(t, ate, top, flow, stream, lldpPPS)
+	if got := uint64(len(pkts)); got > sent || got+lossSlack < sent {
+		t.Errorf("P4RT client received %d PacketIns for %d LLDP frames sent, want them all within %d", got, sent, lossSlack)
+	}
+	for i, pkt := range pkts {
+		port, ok := p4rtutils.Metadata(pkt.GetMetadata(), ingressPortMetadata)
+		if want := fmt.Sprint(port1ID); !ok || string(port) != want {
+			t.Errorf("PacketIn %d ingress_port got %q, present %t, want %q", i, port, ok, want)
+		}
+		if diff := cmp.Diff(ateLLDP, traffic.DecodeFrame(pkt.GetPayload()).LLDP); diff != "" {
+			t.Errorf("PacketIn %d LLDP -want,+got:\n%s", i, diff)
+		}
+	}
+}
+
+// testPuntPolicer checks that LLDP frames sent above the punt policer
+// are dropped rather than all punted.
+func testPuntPolicer(t *testing.T, ate *ondatra.ATEDevice, top gosnappi.Config, flow gosnappi.Flow, stream *p4rtutils.Stream) {
+	pkts, sent := sendLLDP(t, ate, top, flow, stream, *puntPolicerPPS*policerFactor)
+	got := uint64(len(pkts))
+	if got >= sent {
+		t.Errorf("P4RT client received %d PacketIns for %d LLDP frames sent at %d times the punt policer, want drops", got, sent, policerFactor)
+		return
+	}
+	t.Logf("Punt policer dropped %d of %d LLDP frames, punting %.0f pps", sent-got, sent, float64(got)/runTime.Seconds())
+}
+
+// testPacketOut checks that the LLDP frames sent by the P4RT client out
+// of port2 are received by the ATE.
+func testPacketOut(t *testing.T, ate *ondatra.ATEDevice, stream *p4rtutils.Stream) {
+	frame, err := traffic.LLDPFrame(controllerLLDP)
+	if err != nil {
+		t.Fatalf("Cannot create the LLDP frame: %v", err)
+	}
+	ap2 := ate.Port(t, "port2")
+	traffic.StartCapture(t, ate, ap2.ID())
+	for i := 0; i < packetOutCount; i++ {
+		if err := stream.PacketOut(&p4pb.PacketOut{
+			Payload: frame,
+			Metadata: []*p4pb.PacketMetadata{
+				{MetadataId: egressPortMetadata, Value: []byte(fmt.Sprint(port2ID))},
+				{MetadataId: submitToIngressMetadata, Value: []byte{0}},
+			},
+		}); err != nil {
+			t.Fatalf("Cannot send PacketOut %d: %v", i, err)
+		}
+	}
+	time.Sleep(captureWait)
+	traffic.StopCapture(t, ate, ap2.ID())
+
+	var got int
+	for _, p := range traffic.CapturedPackets(t, ate, ap2.ID()) {
+		if p.LLDP != nil && cmp.Equal(p.LLDP, controllerLLDP) {
+			got++
+		}
+	}
+	if got != packetOutCount {
+		t.Errorf("ATE port2 captured %d LLDP frames sent with PacketOut, want %d", got, packetOutCount)
+	}
+}
+
+func TestLLDPPacketIO(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	configureDUT(t, dut)
+	ate := ondatra.ATE(t, "ate")
+	top, flow := configureATE(t, ate)
+
+	id := p4rtutils.DeviceID(t, dut, dut.Port(t, "port1"), *nodeID)
+	c := dut.RawAPIs().P4RT(t)
+	stream, err := p4rtutils.NewStream(c)
+	if err != nil {
+		t.Fatalf("Cannot open the P4RT stream channel: %v", err)
+	}
+	defer stream.Close()
+	if err := stream.Arbitrate(id, p4rtutils.ElectionID(electionID)); err != nil {
+		t.Fatalf("Cannot send the arbitration update: %v", err)
+	}
+	u, err := stream.AwaitArbitration(arbitrationTimeout)
+	if err != nil {
+		t.Fatalf("P4RT client got no arbitration response: %v", err)
+	}
+	if !p4rtutils.IsPrimary(u) {
+		t.Fatalf("P4RT client with election ID %d is not the primary: %v", electionID, u)
+	}
+
+	info, err := p4rtutils.LoadP4Info(*p4InfoFile)
+	if err != nil {
+		t.Fatalf("Cannot load the P4Info: %v", err)
+	}
+	if err := p4rtutils.SetPipeline(c, id, p4rtutils.ElectionID(electionID), info, cookie); err != nil {
+		t.Fatalf("Cannot install the forwarding pipeline: %v", err)
+	}
+	write := func(typ p4pb.Update_Type) error {
+		_, err := c.Write(context.Background(), &p4pb.WriteRequest{
+			DeviceId:   id,
+			ElectionId: p4rtutils.ElectionID(electionID),
+			Updates:    lldpEntry(typ),
+			Atomicity:  p4pb.WriteRequest_CONTINUE_ON_ERROR,
+		})
+		return err
+	}
+	if err := write(p4pb.Update_INSERT); err != nil {
+		t.Fatalf("Cannot install the LLDP punt entry: %v", err)
+	}
+	defer func() {
+		if err := write(p4pb.Update_DELETE); err != nil {
+			t.Errorf("Cannot delete the LLDP punt entry: %v", err)
+		}
+	}()
+
+	t.Run("PacketIn", func(t *testing.T) {
+		testPacketIn(t, ate, top, flow, stream)
+	})
+	t.Run("PuntPolicer", func(t *testing.T) {
+		testPuntPolicer(t, ate, top, flow, stream)
+	})
+	t.Run("PacketOut", func(t *testing.T) {
+		testPacketOut(t, ate, stream)
+	})
+}
@@ -0,0 +1,201 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client_arbitration_test
+
+import (
+	"context"
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/feature/experimental/p4rt/wbb"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/p4rtutils"
+	"github.com/openconfig/ondatra"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	p4pb "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+var nodeID = flag.Uint64("p4rt_node_id", 1, "P4RT node-id configured on the integrated circuit of dut:port1 if it has none.")
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// The testbed consists of dut:port1, whose integrated circuit is the
+// P4RT node the clients arbitrate for.
+const (
+	// primaryID and secondaryID are the election IDs the clients connect
+	// with, and bumpedID the one the secondary takes over with.
+	primaryID   = 100
+	secondaryID = 99
+	bumpedID    = 101
+
+	// arbitrationTimeout is how long the DUT may take to answer or send
+	// an arbitration update.
+	arbitrationTimeout = 10 * time.Second
+)
+
+// client is a P4RT client of the test, with its stream channel.
+type client struct {
+	name       string
+	stream     *p4rtutils.Stream
+	electionID uint64
+}
+
+// pipelineCookie returns the cookie of the forwarding pipeline installed
+// on the device, or false if none is.
+func pipelineCookie(t *testing.T, c p4pb.P4RuntimeClient, deviceID uint64) (uint64, bool) {
+	t.Helper()
+	resp, err := c.GetForwardingPipelineConfig(context.Background(), &p4pb.GetForwardingPipelineConfigRequest{
+		DeviceId:     deviceID,
+		ResponseType: p4pb.GetForwardingPipelineConfigRequest_COOKIE_ONLY,
+	})
+	switch status.Code(err) {
+	case codes.OK:
+	case codes.FailedPrecondition, codes.NotFound:
+		return 0, false
+	default:
+		t.Fatalf("GetForwardingPipelineConfig failed: %v", err)
+	}
+	if resp.GetConfig().GetCookie() == nil {
+		return 0, false
+	}
+	return resp.GetConfig().GetCookie().GetCookie(), true
+}
+
+// connect opens the stream channel of a client and sends its
+// arbitration update.
+func connect(t *testing.T, c p4pb.P4RuntimeClient, name string, deviceID, electionID uint64) *client {
+	t.Helper()
+	stream, err := p4rtutils.NewStream(c)
+	if err != nil {
+		t.Fatalf("Cannot open the stream channel of the %s client: %v", name, err)
+	}
+	cl := &client{name: name, stream: stream}
+	arbitrate(t, cl, deviceID, electionID)
+	return cl
+}
+
+// arbitrate sends an arbitration update of the client with the election
+// ID.
+func arbitrate(t *testing.T, cl *client, deviceID, electionID uint64) {
+	t.Helper()
+	t.Logf("Send arbitration update of the %s client with election ID %d", cl.name, electionID)
+	if err := cl.stream.Arbitrate(deviceID, p4rtutils.ElectionID(electionID)); err != nil {
+		t.Fatalf("Cannot send the arbitration update of the %s client: %v", cl.name, err)
+	}
+	cl.electionID = electionID
+}
+
+// checkArbitration waits for an arbitration update of the client
+// reporting the primary election ID, and checks whether it tells the
+// client it is the primary.  Updates reporting an earlier primary are
+// skipped.
+func checkArbitration(t *testing.T, cl *client, deviceID, primaryElectionID uint64, wantPrimary bool) {
+	t.Helper()
+	deadline := time.Now().Add(arbitrationTimeout)
+	for {
+		u, err := cl.stream.AwaitArbitration(time.Until(deadline))
+		if err != nil {
+			t.Fatalf("The %s client got no arbitration update with primary election ID %d: %v", cl.name, primaryElectionID, err)
+		}
+		t.Logf("The %s client got arbitration update: %v", cl.name, u)
+		if u.GetElectionId().GetHigh() != 0 || u.GetElectionId().GetLow() != primaryElectionID {
+			continue
+		}
+		if got := u.GetDeviceId(); got != deviceID {
+			t.Errorf("The %s client arbitration update device ID got %d, want %d", cl.name, got, deviceID)
+		}
+		if u.GetRole() != nil {
+			t.Errorf("The %s client arbitration update role got %v, want the default role", cl.name, u.GetRole())
+		}
+		if got := p4rtutils.IsPrimary(u); got != wantPrimary {
+			t.Errorf("The %s client arbitration update status got %v, primary %t, want primary %t", cl.name, u.GetStatus(), got, wantPrimary)
+		}
+		return
+	}
+}
+
+// write sends a write request of the client deleting an ACL entry, which
+// changes nothing whether the entry exists or not, and returns the
+// error code.
+func write(t *testing.T, c p4pb.P4RuntimeClient, cl *client, deviceID uint64) codes.Code {
+	t.Helper()
+	_, err := c.Write(context.Background(), &p4pb.WriteRequest{
+		DeviceId:   deviceID,
+		ElectionId: p4rtutils.ElectionID(cl.electionID),
+		Updates: wbb.ACLWbbIngressTableEntryGet([]*wbb.ACLWbbIngressTableEntryInfo{{
+			Type:          p4pb.Update_DELETE,
+			EtherType:     0x6007,
+			EtherTypeMask: 0xFFFF,
+			Priority:      1,
+		}}),
+		Atomicity: p4pb.WriteRequest_CONTINUE_ON_ERROR,
+	})
+	t.Logf("Write of the %s client with election ID %d got: %v", cl.name, cl.electionID, err)
+	return status.Code(err)
+}
+
+// checkWrites checks that the write of the secondary is rejected with
+// PERMISSION_DENIED, and that of the primary is not.
+func checkWrites(t *testing.T, c p4pb.P4RuntimeClient, primary, secondary *client, deviceID uint64) {
+	t.Helper()
+	if code := write(t, c, secondary, deviceID); code != codes.PermissionDenied {
+		t.Errorf("Write of the secondary %s client got code %v, want PermissionDenied", secondary.name, code)
+	}
+	if code := write(t, c, primary, deviceID); code == codes.PermissionDenied {
+		t.Errorf("Write of the primary %s client got code PermissionDenied", primary.name)
+	}
+}
+
+func TestClientArbitration(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	id := p4rtutils.DeviceID(t, dut, dut.Port(t, "port1"), *nodeID)
+	c := dut.RawAPIs().P4RT(t)
+
+	// The test installs no forwarding pipeline, and checks that it leaves
+	// the one of the device as it found it.
+	cookie, installed := pipelineCookie(t, c, id)
+	defer func() {
+		gotCookie, gotInstalled := pipelineCookie(t, c, id)
+		if gotInstalled != installed || gotCookie != cookie {
+			t.Errorf("Forwarding pipeline after the test got installed %t, cookie %d, want installed %t, cookie %d as before", gotInstalled, gotCookie, installed, cookie)
+		}
+	}()
+
+	a := connect(t, c, "first", id, primaryID)
+	defer a.stream.Close()
+	checkArbitration(t, a, id, primaryID, true)
+	b := connect(t, c, "second", id, secondaryID)
+	defer b.stream.Close()
+	checkArbitration(t, b, id, primaryID, false)
+
+	t.Run("SecondaryWriteDenied", func(t *testing.T) {
+		checkWrites(t, c, a, b, id)
+	})
+
+	arbitrate(t, b, id, bumpedID)
+	t.Run("RolesFlipped", func(t *testing.T) {
+		checkArbitration(t, b, id, bumpedID, true)
+		checkArbitration(t, a, id, bumpedID, false)
+	})
+
+	t.Run("FormerPrimaryWriteDenied", func(t *testing.T) {
+		checkWrites(t, c, b, a, id)
+	})
+}
@@ -0,0 +1,318 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package port_breakout_test
+
+import (
+	"flag"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+	"github.com/openconfig/ygot/ygot"
+)
+
+var (
+	numBreakouts  = flag.Uint("breakout_count", 4, "Number of child interfaces dut:port1 is broken out into.")
+	breakoutSpeed = flag.String("breakout_speed", "SPEED_25GB", "Speed of the child interfaces of dut:port1, e.g. SPEED_25GB.")
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// dut:port1 is a breakout-capable port, whose first two channels are
+// connected to ate:port1 and ate:port2 with a breakout cable.  Once
+// dut:port1 is broken out, its first two child interfaces are
+// configured as:
+//
+//   - child 1 -> ate:port1 subnet 192.0.2.0/30
+//   - child 2 -> ate:port2 subnet 192.0.2.4/30
+const (
+	ipv4PrefixLen = 30
+
+	// groupIndex is the index of the breakout group of dut:port1.
+	groupIndex = 0
+
+	// breakoutTimeout is how long the DUT may take to create or remove
+	// the child interfaces after the breakout mode changes.
+	breakoutTimeout = 3 * time.Minute
+	// pollInterval is how often the interfaces are polled meanwhile.
+	pollInterval = 5 * time.Second
+
+	flowName = "child1-to-child2"
+)
+
+// speeds are the breakout speeds the test may configure.
+var speeds = map[string]telemetry.E_IfEthernet_ETHERNET_SPEED{
+	"SPEED_10GB":  telemetry.IfEthernet_ETHERNET_SPEED_SPEED_10GB,
+	"SPEED_25GB":  telemetry.IfEthernet_ETHERNET_SPEED_SPEED_25GB,
+	"SPEED_50GB":  telemetry.IfEthernet_ETHERNET_SPEED_SPEED_50GB,
+	"SPEED_100GB": telemetry.IfEthernet_ETHERNET_SPEED_SPEED_100GB,
+}
+
+var (
+	dutChild1 = attrs.Attributes{
+		Desc:    "dutChild1",
+		IPv4:    "192.0.2.1",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort1 = attrs.Attributes{
+		Name:    "atePort1",
+		MAC:     "02:00:01:01:01:01",
+		IPv4:    "192.0.2.2",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	dutChild2 = attrs.Attributes{
+		Desc:    "dutChild2",
+		IPv4:    "192.0.2.5",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort2 = attrs.Attributes{
+		Name:    "atePort2",
+		MAC:     "02:00:02:01:01:01",
+		IPv4:    "192.0.2.6",
+		IPv4Len: ipv4PrefixLen,
+	}
+)
+
+// portComponent returns the name of the component of type PORT of the
+// interface, or "" if its hardware port is not such a component.
+func portComponent(t *testing.T, dut *ondatra.DUTDevice, name string) string {
+	t.Helper()
+	hw := dut.Telemetry().Interface(name).HardwarePort().Lookup(t)
+	if !hw.IsPresent() {
+		return ""
+	}
+	typ := dut.Telemetry().Component(hw.Val(t)).Type().Lookup(t)
+	if !typ.IsPresent() || typ.Val(t) != telemetry.PlatformTypes_OPENCONFIG_HARDWARE_COMPONENT_PORT {
+		return ""
+	}
+	return hw.Val(t)
+}
+
+// portInterfaces returns the names of the interfaces of the hardware
+// port, sorted by name.
+func portInterfaces(t *testing.T, dut *ondatra.DUTDevice, hw string) []string {
+	t.Helper()
+	var names []string
+	for _, i := range dut.Telemetry().InterfaceAny().Get(t) {
+		if i.GetHardwarePort() == hw {
+			names = append(names, i.GetName())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// awaitInterfaces polls the interfaces of the hardware port until done
+// returns true for them, and returns them.  It fails the test after
+// breakoutTimeout.
+func awaitInterfaces(t *testing.T, dut *ondatra.DUTDevice, hw string, what string, done func([]string) bool) []string {
+	t.Helper()
+	start := time.Now()
+	for {
+		names := portInterfaces(t, dut, hw)
+		if done(names) {
+			t.Logf("Interfaces of %s %s after %v: %v", hw, what, time.Since(start).Round(time.Second), names)
+			return names
+		}
+		if time.Since(start) >= breakoutTimeout {
+			t.Fatalf("Interfaces of %s got %v after %v, want %s", hw, names, breakoutTimeout, what)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// breakoutMode returns the breakout mode of dut:port1 the test
+// configures.
+func breakoutMode(speed telemetry.E_IfEthernet_ETHERNET_SPEED) *telemetry.Component_Port_BreakoutMode {
+	m := &telemetry.Component_Port_BreakoutMode{}
+	g := m.GetOrCreateGroup(groupIndex)
+	g.NumBreakouts = ygot.Uint8(uint8(*numBreakouts))
+	g.BreakoutSpeed = speed
+	return m
+}
+
+// restoreBreakoutMode restores the breakout mode of the hardware port
+// to orig, which may be nil, and waits for the parent interface to be
+// the only interface of the port again.
+func restoreBreakoutMode(t *testing.T, dut *ondatra.DUTDevice, hw, parent string, orig *telemetry.Component_Port_BreakoutMode) {
+	t.Helper()
+	config := dut.Config().Component(hw).Port().BreakoutMode()
+	if orig == nil {
+		config.Delete(t)
+	} else {
+		config.Replace(t, orig)
+	}
+	awaitInterfaces(t, dut, hw, "restored to "+parent, func(names []string) bool {
+		return len(names) == 1 && names[0] == parent
+	})
+}
+
+// configureChildren configures the first two child interfaces.
+func configureChildren(t *testing.T, dut *ondatra.DUTDevice, children []string) {
+	d := dut.Config()
+	for name, a := range map[string]*attrs.Attributes{children[0]: &dutChild1, children[1]: &dutChild2} {
+		i := a.NewInterface(name)
+		d.Interface(name).Replace(t, i)
+		fptest.LogYgot(t, name, d.Interface(name), i)
+	}
+}
+
+// deleteChildren deletes the configuration of the first two child
+// interfaces.
+func deleteChildren(t *testing.T, dut *ondatra.DUTDevice, children []string) {
+	for _, name := range children[:2] {
+		dut.Config().Interface(name).Delete(t)
+	}
+}
+
+// testTraffic sends a flow from ate:port1 to ate:port2 through the
+// first two child interfaces.
+func testTraffic(t *testing.T, ate *ondatra.ATEDevice) {
+	otg := ate.OTG()
+	top := otg.NewConfig(t)
+	ap1 := ate.Port(t, "port1")
+	ap2 := ate.Port(t, "port2")
+	atePort1.AddToOTG(top, ap1, &dutChild1)
+	atePort2.AddToOTG(top, ap2, &dutChild2)
+	otg.PushConfig(t, top)
+	otg.StartProtocols(t)
+	defer otg.StopProtocols(t)
+
+	traffic.AddOTGIPv4Flow(t, ate, top, &traffic.OTGFlowParams{
+		Name:    flowName,
+		Src:     &atePort1,
+		Dst:     &atePort2,
+		SrcPort: ap1,
+		DstPort: ap2,
+		Gateway: &dutChild1,
+		Frame:   traffic.Frame{Size: 512, RatePct: 10},
+	})
+	otg.PushConfig(t, top)
+	otg.StartProtocols(t)
+	traffic.ValidateOTGFlow(t, ate, top, flowName, nil)
+}
+
+func TestPortBreakout(t *testing.T) {
+	speed, ok := speeds[*breakoutSpeed]
+	if !ok {
+		t.Fatalf("Unknown --breakout_speed %q", *breakoutSpeed)
+	}
+	if *numBreakouts < 2 {
+		t.Fatalf("--breakout_count got %d, want at least 2 child interfaces to pass traffic between", *numBreakouts)
+	}
+
+	dut := ondatra.DUT(t, "dut")
+	parent := dut.Port(t, "port1").Name()
+	hw := portComponent(t, dut, parent)
+	if hw == "" {
+		t.Skipf("Skipping since dut:port1 %s has no hardware port component of type PORT to break out", parent)
+	}
+	t.Logf("Breaking out %s of component %s into %d x %v", parent, hw, *numBreakouts, speed)
+
+	// The configuration of the parent interface is restored after the
+	// revert, since the first child interface may share its name.
+	var origParent *telemetry.Interface
+	if q := dut.Config().Interface(parent).Lookup(t); q.IsPresent() {
+		origParent = q.Val(t)
+	}
+
+	config := dut.Config().Component(hw).Port().BreakoutMode()
+	var orig *telemetry.Component_Port_BreakoutMode
+	if q := config.Lookup(t); q.IsPresent() {
+		orig = q.Val(t)
+	}
+
+	// Whatever happens from now on, the breakout mode is restored so
+	// that dut:port1 is usable by the next tests.
+	reverted := false
+	defer func() {
+		if !reverted {
+			t.Logf("Rollback: restore the breakout mode of %s", hw)
+			restoreBreakoutMode(t, dut, hw, parent, orig)
+			if origParent != nil {
+				dut.Config().Interface(parent).Replace(t, origParent)
+			}
+		}
+	}()
+
+	m := breakoutMode(speed)
+	fptest.LogYgot(t, hw+" breakout mode", config, m)
+	config.Replace(t, m)
+
+	children := awaitInterfaces(t, dut, hw, "broken out", func(names []string) bool {
+		return len(names) == int(*numBreakouts)
+	})
+
+	t.Run("BreakoutState", func(t *testing.T) {
+		g := dut.Telemetry().Component(hw).Port().BreakoutMode().Group(groupIndex)
+		if got := g.NumBreakouts().Get(t); got != uint8(*numBreakouts) {
+			t.Errorf("Telemetry num-breakouts got %d, want %d", got, *numBreakouts)
+		}
+		if got := g.BreakoutSpeed().Get(t); got != speed {
+			t.Errorf("Telemetry breakout-speed got %v, want %v", got, speed)
+		}
+	})
+
+	t.Run("ChildInterfaces", func(t *testing.T) {
+		for _, name := range children {
+			if got := dut.Telemetry().Interface(name).Ethernet().PortSpeed().Get(t); got != speed {
+				t.Errorf("Interface %s port-speed got %v, want %v", name, got, speed)
+			}
+		}
+		// The parent interface either disappears, or becomes the first
+		// child interface at the breakout speed as checked above.
+		for _, name := range children[1:] {
+			if name == parent {
+				t.Errorf("Parent interface %s is not the first child interface of %v", parent, children)
+			}
+		}
+	})
+
+	configureChildren(t, dut, children)
+	defer func() {
+		if !reverted {
+			deleteChildren(t, dut, children)
+		}
+	}()
+
+	t.Run("Traffic", func(t *testing.T) {
+		testTraffic(t, ondatra.ATE(t, "ate"))
+	})
+
+	t.Run("Revert", func(t *testing.T) {
+		deleteChildren(t, dut, children)
+		restoreBreakoutMode(t, dut, hw, parent, orig)
+		reverted = true
+		if origParent != nil {
+			dut.Config().Interface(parent).Replace(t, origParent)
+		}
+		if got := dut.Telemetry().Interface(parent).HardwarePort().Get(t); got != hw {
+			t.Errorf("Interface %s hardware-port got %q, want %q", parent, got, hw)
+		}
+	})
+}
@@ -0,0 +1,219 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package egress_shaper_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/acl"
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/qos"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1 and
+// dut:port2 -> ate:port2.
+//
+//   - ate:port1 -> dut:port1 subnet 192.0.2.0/30
+//   - ate:port2 -> dut:port2 subnet 192.0.2.4/30
+//
+// The packets received by dut:port1 are classified to a forwarding
+// group, whose queue is shaped on dut:port2.
+const (
+	ipv4PrefixLen = 30
+
+	flowDSCP  = 10
+	groupName = "AF1"
+
+	// shapeRate is the rate the queue of the forwarding group is shaped
+	// to in bits per second, and rateTolerancePct how many percent the
+	// rate the ATE receives the flow at may differ from it.
+	shapeRate        = 100000000
+	rateTolerancePct = 10
+
+	// The flow is sent at flowRatePct of the line rate, well above the
+	// shaped rate on any port of at least 10 Gbps.
+	flowFrameSize = 512
+	flowRatePct   = 10
+	// flowDuration is how long the flow runs, and sampleInterval how
+	// often its counters are sampled to measure the rate it is received
+	// at.
+	flowDuration   = 30 * time.Second
+	sampleInterval = time.Second
+)
+
+var (
+	dutPort1 = attrs.Attributes{
+		Desc:    "dutPort1",
+		IPv4:    "192.0.2.1",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort1 = attrs.Attributes{
+		Name:    "atePort1",
+		IPv4:    "192.0.2.2",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	dutPort2 = attrs.Attributes{
+		Desc:    "dutPort2",
+		IPv4:    "192.0.2.5",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort2 = attrs.Attributes{
+		Name:    "atePort2",
+		IPv4:    "192.0.2.6",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	// classifier assigns the packets of the flow to the forwarding
+	// group, whose queue shaper shapes.
+	classifier = &qos.Classifier{
+		Name:  "CLASSIFY",
+		Type:  telemetry.Qos_Classifier_Type_IPV4,
+		Terms: []*qos.Term{{ID: "1", Match: acl.Match{DSCP: flowDSCP}, TargetGroup: groupName}},
+	}
+	shaper = &qos.Shaper{Name: "SHAPE", Group: groupName, CIR: shapeRate}
+)
+
+// configureDUT configures port1 and port2 on the DUT.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	d := dut.Config()
+	for _, p := range []struct {
+		id    string
+		attrs *attrs.Attributes
+	}{
+		{"port1", &dutPort1},
+		{"port2", &dutPort2},
+	} {
+		dp := dut.Port(t, p.id)
+		i := p.attrs.NewInterface(dp.Name())
+		d.Interface(dp.Name()).Replace(t, i)
+		fptest.LogYgot(t, dp.String(), d.Interface(dp.Name()), i)
+	}
+}
+
+// configureQoS applies the classifier to the packets received by
+// dut:port1, and if shaped, the shaper to those sent by dut:port2.
+func configureQoS(t *testing.T, dut *ondatra.DUTDevice, shaped bool) {
+	q, err := qos.Build([]*qos.ForwardingGroup{{Name: groupName}}, []*qos.Classifier{classifier})
+	if err != nil {
+		t.Fatalf("Cannot build QoS config: %v", err)
+	}
+	qos.AttachInput(q, dut.Port(t, "port1").Name(), classifier)
+	if shaped {
+		if err := qos.AddShaper(q, shaper); err != nil {
+			t.Fatalf("Cannot build QoS config: %v", err)
+		}
+		qos.AttachShaper(q, dut.Port(t, "port2").Name(), shaper)
+	}
+	qos.Configure(t, dut, q)
+}
+
+// configureATE configures port1 and port2 on the ATE.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice, dut *ondatra.DUTDevice) *ondatra.ATETopology {
+	top := ate.Topology().New()
+	atePort1.AddToATE(top, ate.Port(t, "port1"), &dutPort1)
+	atePort2.AddToATE(top, ate.Port(t, "port2"), &dutPort2)
+	traffic.StartProtocolsAndAwait(t, ate, top, &traffic.Readiness{
+		DUT: dut,
+		Neighbors: map[string]*attrs.Attributes{
+			"port1": &atePort1,
+			"port2": &atePort2,
+		},
+	})
+	return top
+}
+
+// runSampled runs the flow for flowDuration while sampling its counters,
+// and returns its result and the median rate in bits per second it was
+// received at.
+func runSampled(t *testing.T, ate *ondatra.ATEDevice, flow *ondatra.Flow) (*traffic.Result, float64) {
+	t.Helper()
+	sampler := traffic.StartSampler(t, ate, flow.Name(), sampleInterval)
+	r := traffic.RunFlow(t, ate, flow, &traffic.Options{Duration: flowDuration})
+	rate := traffic.MedianRxRateBps(traffic.Intervals(sampler.Stop(t)))
+	t.Logf("Flow %s was received at a median rate of %.0f bps", flow.Name(), rate)
+	return r, rate
+}
+
+func TestEgressShaper(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	configureDUT(t, dut)
+	configureQoS(t, dut, true)
+	defer qos.Delete(t, dut)
+
+	ate := ondatra.ATE(t, "ate")
+	top := configureATE(t, ate, dut)
+	defer top.StopProtocols(t)
+
+	flow := traffic.NewIPv4Flow(t, ate, top, &traffic.FlowParams{
+		Name:  "Shaped",
+		Src:   &atePort1,
+		Dst:   &atePort2,
+		DSCP:  flowDSCP,
+		Frame: traffic.Frame{Size: flowFrameSize, RatePct: flowRatePct},
+	})
+	intf := dut.Port(t, "port2").Name()
+
+	t.Run("Shaped", func(t *testing.T) {
+		before := traffic.SnapshotCounters(t, dut, "port1", "port2")
+		queuesBefore := qos.SnapshotOutputQueues(t, dut, intf, groupName)
+		r, rate := runSampled(t, ate, flow)
+		queues := qos.SnapshotOutputQueues(t, dut, intf, groupName).Diff(queuesBefore)
+		t.Logf("DUT counters:\n%v", traffic.SnapshotCounters(t, dut, "port1", "port2").Diff(before))
+
+		traffic.RecordRate(t, &traffic.Rate{Flow: r.Flow, Time: r.End, ConfiguredBps: shapeRate, MeasuredBps: rate})
+		if diff := math.Abs(rate-shapeRate) / shapeRate * 100; diff > rateTolerancePct {
+			t.Errorf("Flow %s received at %.0f bps, want %d bps within %d%%", r.Flow, rate, shapeRate, rateTolerancePct)
+		}
+		if r.LossPct == 0 {
+			t.Errorf("Flow %s got no loss, want the packets above the shaped rate dropped", r.Flow)
+		}
+
+		c, ok := queues[groupName]
+		if !ok {
+			t.Fatalf("DUT does not report the counters of output queue %s of %s", groupName, intf)
+		}
+		t.Logf("DUT output queue %s of %s transmitted %d packets and dropped %d", groupName, intf, c.TransmitPkts, c.DroppedPkts)
+		if c.DroppedPkts == 0 {
+			t.Errorf("DUT output queue %s of %s dropped-pkts did not increment, want the packets above the shaped rate dropped", groupName, intf)
+		}
+	})
+
+	t.Run("Unshaped", func(t *testing.T) {
+		configureQoS(t, dut, false)
+		r, rate := runSampled(t, ate, flow)
+		if r.LossPct > 0 {
+			t.Errorf("Flow %s got loss %.3f%%, want none without the shaper", r.Flow, r.LossPct)
+		}
+		if rate <= shapeRate*(1+rateTolerancePct/100.0) {
+			t.Errorf("Flow %s received at %.0f bps, want above the shaped rate of %d bps without the shaper", r.Flow, rate, shapeRate)
+		}
+	})
+}
@@ -0,0 +1,255 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dscp_remark_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/openconfig/featureprofiles/internal/acl"
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/otgutils"
+	"github.com/openconfig/featureprofiles/internal/qos"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1 and
+// dut:port2 -> ate:port2.
+//
+//   - ate:port1 -> dut:port1 subnet 192.0.2.0/30
+//   - ate:port2 -> dut:port2 subnet 192.0.2.4/30
+//
+// The packets received by dut:port1 are classified, those sent by
+// dut:port2 are remarked, and those received by ate:port2 are captured.
+const (
+	ipv4PrefixLen = 30
+
+	// classifiedDSCP is the DSCP of the packets the classifier assigns
+	// to the forwarding group, and remarkDSCP the DSCP they are
+	// remarked to on egress.
+	classifiedDSCP = 10
+	remarkDSCP     = 32
+	groupName      = "AF1"
+
+	// The classified and control flows are told apart in the capture by
+	// their TTL, since the remark changes their DSCP.
+	classifiedFlow = "Classified"
+	classifiedTTL  = 64
+	controlFlow    = "Control"
+	controlTTL     = 32
+
+	flowPackets = 1000
+	flowPPS     = 100
+	// captureTime is how long the traffic runs while captured, enough
+	// for both flows to send all their packets.
+	captureTime = flowPackets/flowPPS*time.Second + 5*time.Second
+
+	// counterTolerancePct is how many percent the classified-packets
+	// counter of the forwarding group may differ from the packets the
+	// ATE sent that it classifies.
+	counterTolerancePct = 1
+)
+
+var (
+	dutPort1 = attrs.Attributes{
+		Desc:    "dutPort1",
+		IPv4:    "192.0.2.1",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort1 = attrs.Attributes{
+		Name:    "atePort1",
+		MAC:     "02:00:01:01:01:01",
+		IPv4:    "192.0.2.2",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	dutPort2 = attrs.Attributes{
+		Desc:    "dutPort2",
+		IPv4:    "192.0.2.5",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort2 = attrs.Attributes{
+		Name:    "atePort2",
+		MAC:     "02:00:02:01:01:01",
+		IPv4:    "192.0.2.6",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	// classifier assigns the packets marked classifiedDSCP to the
+	// forwarding group, and remark remarks them to remarkDSCP.
+	classifier = &qos.Classifier{
+		Name:  "CLASSIFY",
+		Type:  telemetry.Qos_Classifier_Type_IPV4,
+		Terms: []*qos.Term{{ID: "1", Match: acl.Match{DSCP: classifiedDSCP}, TargetGroup: groupName}},
+	}
+	remark = &qos.Classifier{
+		Name:  "REMARK",
+		Type:  telemetry.Qos_Classifier_Type_IPV4,
+		Terms: []*qos.Term{{ID: "1", Match: acl.Match{DSCP: classifiedDSCP}, TargetGroup: groupName, RemarkDSCP: remarkDSCP}},
+	}
+)
+
+// configureDUT configures port1 and port2 on the DUT.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	d := dut.Config()
+	for _, p := range []struct {
+		id    string
+		attrs *attrs.Attributes
+	}{
+		{"port1", &dutPort1},
+		{"port2", &dutPort2},
+	} {
+		dp := dut.Port(t, p.id)
+		i := p.attrs.NewInterface(dp.Name())
+		d.Interface(dp.Name()).Replace(t, i)
+		fptest.LogYgot(t, dp.String(), d.Interface(dp.Name()), i)
+	}
+}
+
+// configureQoS applies the classifier to the packets received by
+// dut:port1, and unless the DUT cannot remark on egress, the remark to
+// those sent by dut:port2.
+func configureQoS(t *testing.T, dut *ondatra.DUTDevice) {
+	classifiers := []*qos.Classifier{classifier}
+	if !*deviations.QoSEgressRemarkUnsupported {
+		classifiers = append(classifiers, remark)
+	}
+	q, err := qos.Build([]*qos.ForwardingGroup{{Name: groupName}}, classifiers)
+	if err != nil {
+		t.Fatalf("Cannot build QoS config: %v", err)
+	}
+	qos.AttachInput(q, dut.Port(t, "port1").Name(), classifier)
+	if !*deviations.QoSEgressRemarkUnsupported {
+		qos.AttachOutput(q, dut.Port(t, "port2").Name(), remark)
+	}
+	qos.Configure(t, dut, q)
+}
+
+// configureATE configures port1 and port2 on the ATE, with a capture on
+// port2, and the classified and control flows from port1 to port2.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice) (gosnappi.Config, *traffic.Capture) {
+	otg := ate.OTG()
+	top := otg.NewConfig(t)
+	ap1 := ate.Port(t, "port1")
+	ap2 := ate.Port(t, "port2")
+	atePort1.AddToOTG(top, ap1, &dutPort1)
+	atePort2.AddToOTG(top, ap2, &dutPort2)
+	capture := traffic.NewCapture(t, ate, top, ap2)
+	otg.PushConfig(t, top)
+	otg.StartProtocols(t)
+
+	for _, f := range []struct {
+		name      string
+		dscp, ttl uint8
+	}{
+		{classifiedFlow, classifiedDSCP, classifiedTTL},
+		{controlFlow, 0, controlTTL},
+	} {
+		traffic.AddOTGIPv4Flow(t, ate, top, &traffic.OTGFlowParams{
+			Name:    f.name,
+			Src:     &atePort1,
+			Dst:     &atePort2,
+			SrcPort: ap1,
+			DstPort: ap2,
+			Gateway: &dutPort1,
+			DSCP:    f.dscp,
+			TTL:     f.ttl,
+			Frame:   traffic.Frame{RatePPS: flowPPS, Count: flowPackets},
+		})
+	}
+	otg.PushConfig(t, top)
+	otg.StartProtocols(t)
+	return top, capture
+}
+
+// checkCapture checks that the captured packets of the flow sent with
+// the TTL, which the DUT decrements, carry the DSCP.
+func checkCapture(t *testing.T, pkts []*traffic.Packet, flow string, ttl, dscp uint8) {
+	t.Helper()
+	var matched int
+	for _, p := range pkts {
+		hdr := p.Outer()
+		if hdr == nil || hdr.Src != atePort1.IPv4 || hdr.TTL != ttl-1 {
+			continue
+		}
+		matched++
+		if hdr.DSCP != dscp {
+			t.Errorf("Packet of flow %s got DSCP %d, want %d", flow, hdr.DSCP, dscp)
+		}
+	}
+	t.Logf("Captured %d packets of flow %s", matched, flow)
+	if matched == 0 {
+		t.Errorf("Captured no packets of flow %s", flow)
+	}
+}
+
+func TestDSCPRemark(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	configureDUT(t, dut)
+	configureQoS(t, dut)
+	defer qos.Delete(t, dut)
+
+	ate := ondatra.ATE(t, "ate")
+	otg := ate.OTG()
+	top, capture := configureATE(t, ate)
+	defer otg.StopProtocols(t)
+
+	intf := dut.Port(t, "port1").Name()
+	before := qos.InputMatchedPackets(t, dut, intf, classifier)
+	pkts := capture.Run(t, captureTime)
+	after := qos.InputMatchedPackets(t, dut, intf, classifier)
+	otgutils.LogFlowMetrics(t, otg, top)
+
+	t.Run("Classified", func(t *testing.T) {
+		b, okB := before[groupName]
+		a, okA := after[groupName]
+		if !okB || !okA {
+			t.Fatalf("DUT does not report the packets %s classified to forwarding group %s", intf, groupName)
+		}
+		sent := otg.Telemetry().Flow(classifiedFlow).Counters().OutPkts().Get(t)
+		got := a - b
+		t.Logf("Classifier %s assigned %d packets to forwarding group %s", classifier.Name, got, groupName)
+		tolerance := uint64(math.Ceil(float64(sent) * counterTolerancePct / 100))
+		if got+tolerance < sent || got > sent+tolerance {
+			t.Errorf("Forwarding group %s matched-packets incremented by %d, want %d within %d%%", groupName, got, sent, counterTolerancePct)
+		}
+	})
+
+	t.Run("Remarked", func(t *testing.T) {
+		if *deviations.QoSEgressRemarkUnsupported {
+			t.Skip("DUT does not support remarking on egress")
+		}
+		checkCapture(t, pkts, classifiedFlow, classifiedTTL, remarkDSCP)
+	})
+
+	t.Run("ControlUntouched", func(t *testing.T) {
+		checkCapture(t, pkts, controlFlow, controlTTL, 0)
+	})
+}
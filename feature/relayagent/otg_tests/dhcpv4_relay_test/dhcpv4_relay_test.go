@@ -0,0 +1,421 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dhcpv4_relay_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/layers"
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/ondatra"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1 and
+// dut:port2 -> ate:port2.
+//
+//   - ate:port1 -> dut:port1 subnet 192.0.2.0/30, with the DHCPv4 client
+//     on ate:port1 and the relay agent on dut:port1.
+//   - dut:port2 -> ate:port2 subnet 192.0.2.4/30, with the DHCPv4 server
+//     on ate:port2.
+//
+// The ATE does not run DHCPv4 itself: the client and server messages
+// are sent by flows, each once the previous message of the exchange was
+// captured after the DUT relayed it.
+const (
+	ipv4PrefixLen = 30
+
+	// clientMAC is the MAC address of the client, xid the transaction
+	// ID of the exchange, and offeredIP and offeredMask the address the
+	// server offers it.
+	clientMAC   = "02:00:01:01:01:01"
+	xid         = 0x0fe0dcb4
+	offeredIP   = "192.0.2.2"
+	offeredMask = "255.255.255.252"
+
+	// circuitID is the circuit-id of the relay agent information option
+	// the DUT inserts.
+	circuitID = "fp-dhcp-relay"
+
+	// sendTime is how long each message is sent for, once per second.
+	sendTime = 5 * time.Second
+	// stateTimeout is how long the state of the DUT may take to reflect
+	// the configuration.
+	stateTimeout = 30 * time.Second
+)
+
+var (
+	dutPort1 = attrs.Attributes{
+		Desc:    "dutPort1",
+		IPv4:    "192.0.2.1",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	dutPort2 = attrs.Attributes{
+		Desc:    "dutPort2",
+		IPv4:    "192.0.2.5",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	// ateServer is the DHCPv4 server on ate:port2.
+	ateServer = attrs.Attributes{
+		Name:    "ateServer",
+		MAC:     "02:00:02:01:01:01",
+		IPv4:    "192.0.2.6",
+		IPv4Len: ipv4PrefixLen,
+	}
+)
+
+// configureDUT configures port1 and port2 on the DUT.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	d := dut.Config()
+	for id, a := range map[string]*attrs.Attributes{"port1": &dutPort1, "port2": &dutPort2} {
+		dp := dut.Port(t, id)
+		i := a.NewInterface(dp.Name())
+		d.Interface(dp.Name()).Replace(t, i)
+		fptest.LogYgot(t, dp.String(), d.Interface(dp.Name()), i)
+	}
+}
+
+// relayPath returns the gNMI path of the DHCPv4 relay agent, below which
+// the path elements are appended.  The telemetry structs do not cover
+// openconfig-relay-agent.
+func relayPath(elems ...*gpb.PathElem) *gpb.Path {
+	return &gpb.Path{
+		Origin: "openconfig",
+		Elem: append([]*gpb.PathElem{
+			{Name: "relay-agent"},
+			{Name: "dhcp"},
+		}, elems...),
+	}
+}
+
+// relayConfig returns the RFC7951 JSON of the configuration of the
+// DHCPv4 relay agent on dut:port1, relaying to the server with option 82.
+func relayConfig(name string) []byte {
+	return []byte(fmt.Sprintf(`{
+  "openconfig-relay-agent:config": {"enable-relay-agent": true},
+  "openconfig-relay-agent:agent-information-option": {"config": {"enable": true}},
+  "openconfig-relay-agent:interfaces": {"interface": [{
+    "id": %[1]q,
+    "config": {"id": %[1]q, "enable": true, "helper-address": [%[2]q]},
+    "interface-ref": {"config": {"interface": %[1]q, "subinterface": 0}},
+    "agent-information-option": {"config": {"enable": true, "circuit-id": %[3]q}}
+  }]}
+}`, name, ateServer.IPv4, circuitID))
+}
+
+// configureRelay replaces the configuration of the DHCPv4 relay agent.
+func configureRelay(t *testing.T, dut *ondatra.DUTDevice, name string) {
+	t.Helper()
+	config := relayConfig(name)
+	t.Logf("DHCPv4 relay agent config:\n%s", config)
+	_, err := dut.RawAPIs().GNMI().Default(t).Set(context.Background(), &gpb.SetRequest{
+		Replace: []*gpb.Update{{
+			Path: relayPath(),
+			Val:  &gpb.TypedValue{Value: &gpb.TypedValue_JsonIetfVal{JsonIetfVal: config}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Cannot configure DHCPv4 relay agent on %s: %v", name, err)
+	}
+}
+
+// deleteRelay deletes the configuration of the DHCPv4 relay agent.
+func deleteRelay(t *testing.T, dut *ondatra.DUTDevice) {
+	t.Helper()
+	_, err := dut.RawAPIs().GNMI().Default(t).Set(context.Background(), &gpb.SetRequest{
+		Delete: []*gpb.Path{relayPath()},
+	})
+	if err != nil {
+		t.Fatalf("Cannot delete DHCPv4 relay agent: %v", err)
+	}
+}
+
+// relayState is the state of the DHCPv4 relay agent on an interface.
+type relayState struct {
+	Enable   bool
+	Counters map[string]interface{}
+}
+
+// counter returns the named counter, which RFC7951 encodes as a string
+// since it is a uint64.
+func (s *relayState) counter(name string) uint64 {
+	switch v := s.Counters[name].(type) {
+	case string:
+		n, _ := strconv.ParseUint(v, 10, 64)
+		return n
+	case float64:
+		return uint64(v)
+	}
+	return 0
+}
+
+// getRelayState returns the state of the DHCPv4 relay agent on the
+// interface.
+func getRelayState(t *testing.T, dut *ondatra.DUTDevice, name string) (*relayState, error) {
+	p := relayPath(
+		&gpb.PathElem{Name: "interfaces"},
+		&gpb.PathElem{Name: "interface", Key: map[string]string{"id": name}},
+		&gpb.PathElem{Name: "state"},
+	)
+	resp, err := dut.RawAPIs().GNMI().Default(t).Get(context.Background(), &gpb.GetRequest{
+		Path:     []*gpb.Path{p},
+		Type:     gpb.GetRequest_STATE,
+		Encoding: gpb.Encoding_JSON_IETF,
+	})
+	if err != nil {
+		return nil, err
+	}
+	s := &relayState{Counters: map[string]interface{}{}}
+	for _, n := range resp.GetNotification() {
+		for _, u := range n.GetUpdate() {
+			var leaves map[string]json.RawMessage
+			if err := json.Unmarshal(u.GetVal().GetJsonIetfVal(), &leaves); err != nil {
+				return nil, fmt.Errorf("cannot unmarshal relay agent state: %w", err)
+			}
+			for k, v := range leaves {
+				if i := strings.LastIndex(k, ":"); i >= 0 {
+					k = k[i+1:]
+				}
+				var err error
+				switch k {
+				case "enable":
+					err = json.Unmarshal(v, &s.Enable)
+				case "counters":
+					var counters map[string]interface{}
+					err = json.Unmarshal(v, &counters)
+					for c, n := range counters {
+						if i := strings.LastIndex(c, ":"); i >= 0 {
+							c = c[i+1:]
+						}
+						s.Counters[c] = n
+					}
+				}
+				if err != nil {
+					return nil, fmt.Errorf("cannot unmarshal relay agent state %s: %w", k, err)
+				}
+			}
+		}
+	}
+	return s, nil
+}
+
+// awaitRelayEnabled waits for the state of the DHCPv4 relay agent on the
+// interface to be enabled, and returns it.
+func awaitRelayEnabled(t *testing.T, dut *ondatra.DUTDevice, name string) *relayState {
+	t.Helper()
+	var (
+		s   *relayState
+		err error
+	)
+	for start := time.Now(); time.Since(start) < stateTimeout; time.Sleep(time.Second) {
+		s, err = getRelayState(t, dut, name)
+		if err == nil && s.Enable {
+			return s
+		}
+	}
+	if err != nil {
+		t.Fatalf("Cannot get state of DHCPv4 relay agent on %s: %v", name, err)
+	}
+	t.Fatalf("DHCPv4 relay agent enable on %s got false, want true", name)
+	return nil
+}
+
+// exchange sends the message of the flow out of the ATE port for
+// sendTime, and returns the DHCPv4 messages of the exchange captured on
+// the other ATE port.
+func exchange(t *testing.T, ate *ondatra.ATEDevice, tx, rx string, f *traffic.DHCPv4Flow) []*traffic.DHCPv4 {
+	t.Helper()
+	otg := ate.OTG()
+	top := otg.NewConfig(t)
+	top.Ports().Add().SetName(ate.Port(t, "port1").ID())
+	ateServer.AddToOTG(top, ate.Port(t, "port2"), &dutPort2)
+	traffic.AddOTGDHCPv4Flow(t, top, ate.Port(t, tx), f)
+	rxID := ate.Port(t, rx).ID()
+	traffic.EnableFullCapture(top, rxID)
+	otg.PushConfig(t, top)
+	otg.StartProtocols(t)
+	defer otg.StopProtocols(t)
+
+	traffic.StartCapture(t, ate, rxID)
+	otg.StartTraffic(t)
+	time.Sleep(sendTime)
+	otg.StopTraffic(t)
+	traffic.StopCapture(t, ate, rxID)
+
+	var msgs []*traffic.DHCPv4
+	for _, p := range traffic.CapturedPackets(t, ate, rxID) {
+		if p.DHCPv4 == nil || p.DHCPv4.Xid != xid {
+			continue
+		}
+		t.Logf("Captured DHCPv4 message on %s: %+v", rx, *p.DHCPv4)
+		msgs = append(msgs, p.DHCPv4)
+	}
+	return msgs
+}
+
+// ofType returns the first message of the type, or nil if there is
+// none.
+func ofType(msgs []*traffic.DHCPv4, typ layers.DHCPMsgType) *traffic.DHCPv4 {
+	for _, m := range msgs {
+		if m.Type == typ {
+			return m
+		}
+	}
+	return nil
+}
+
+// clientFlow returns the flow of a message of the client.
+func clientFlow(typ layers.DHCPMsgType) *traffic.DHCPv4Flow {
+	m := &traffic.DHCPv4{
+		Type:      typ,
+		Xid:       xid,
+		ClientMAC: clientMAC,
+	}
+	if typ == layers.DHCPMsgTypeRequest {
+		m.RequestedIP = offeredIP
+		m.ServerID = ateServer.IPv4
+	}
+	return &traffic.DHCPv4Flow{Msg: m, SrcMAC: clientMAC}
+}
+
+// serverFlow returns the flow of a message of the server to the relay
+// agent, which echoes the relay agent information option it received.
+func serverFlow(typ layers.DHCPMsgType, dutMAC string, relayAgentInfo []byte) *traffic.DHCPv4Flow {
+	return &traffic.DHCPv4Flow{
+		Msg: &traffic.DHCPv4{
+			Type:           typ,
+			Xid:            xid,
+			ClientMAC:      clientMAC,
+			YourIP:         offeredIP,
+			RelayIP:        dutPort1.IPv4,
+			ServerID:       ateServer.IPv4,
+			SubnetMask:     offeredMask,
+			RelayAgentInfo: relayAgentInfo,
+		},
+		SrcMAC: ateServer.MAC,
+		DstMAC: dutMAC,
+		SrcIP:  ateServer.IPv4,
+		DstIP:  dutPort1.IPv4,
+	}
+}
+
+// checkRelayed checks that the client message was relayed to the server
+// with the relay agent information option, and returns the option.
+func checkRelayed(t *testing.T, msgs []*traffic.DHCPv4, typ layers.DHCPMsgType) []byte {
+	t.Helper()
+	m := ofType(msgs, typ)
+	if m == nil {
+		t.Fatalf("DHCPv4 %v not relayed to the server", typ)
+	}
+	if m.RelayIP != dutPort1.IPv4 {
+		t.Errorf("Relayed %v giaddr got %q, want %q", typ, m.RelayIP, dutPort1.IPv4)
+	}
+	if len(m.RelayAgentInfo) == 0 {
+		t.Errorf("Relayed %v has no relay agent information option", typ)
+	}
+	return m.RelayAgentInfo
+}
+
+// checkDelivered checks that the server message was relayed to the
+// client with the offered address, without the relay agent information
+// option.
+func checkDelivered(t *testing.T, msgs []*traffic.DHCPv4, typ layers.DHCPMsgType) {
+	t.Helper()
+	m := ofType(msgs, typ)
+	if m == nil {
+		t.Fatalf("DHCPv4 %v not relayed to the client", typ)
+	}
+	if m.YourIP != offeredIP {
+		t.Errorf("Relayed %v yiaddr got %q, want %q", typ, m.YourIP, offeredIP)
+	}
+	if len(m.RelayAgentInfo) != 0 {
+		t.Errorf("Relayed %v to the client still has the relay agent information option %x", typ, m.RelayAgentInfo)
+	}
+}
+
+func TestDHCPv4Relay(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	configureDUT(t, dut)
+	ate := ondatra.ATE(t, "ate")
+
+	name := dut.Port(t, "port1").Name()
+	dutMAC := dut.Telemetry().Interface(dut.Port(t, "port2").Name()).Ethernet().MacAddress().Get(t)
+	configureRelay(t, dut, name)
+	defer deleteRelay(t, dut)
+	before := awaitRelayEnabled(t, dut, name)
+
+	var relayAgentInfo []byte
+	t.Run("Discover", func(t *testing.T) {
+		msgs := exchange(t, ate, "port1", "port2", clientFlow(layers.DHCPMsgTypeDiscover))
+		relayAgentInfo = checkRelayed(t, msgs, layers.DHCPMsgTypeDiscover)
+	})
+	t.Run("Offer", func(t *testing.T) {
+		msgs := exchange(t, ate, "port2", "port1", serverFlow(layers.DHCPMsgTypeOffer, dutMAC, relayAgentInfo))
+		checkDelivered(t, msgs, layers.DHCPMsgTypeOffer)
+	})
+	t.Run("Request", func(t *testing.T) {
+		msgs := exchange(t, ate, "port1", "port2", clientFlow(layers.DHCPMsgTypeRequest))
+		checkRelayed(t, msgs, layers.DHCPMsgTypeRequest)
+	})
+	t.Run("Ack", func(t *testing.T) {
+		msgs := exchange(t, ate, "port2", "port1", serverFlow(layers.DHCPMsgTypeAck, dutMAC, relayAgentInfo))
+		checkDelivered(t, msgs, layers.DHCPMsgTypeAck)
+	})
+
+	t.Run("Counters", func(t *testing.T) {
+		after, err := getRelayState(t, dut, name)
+		if err != nil {
+			t.Fatalf("Cannot get state of DHCPv4 relay agent on %s: %v", name, err)
+		}
+		for _, c := range []string{
+			"dhcp-discover-received",
+			"dhcp-request-received",
+			"dhcp-offer-sent",
+			"dhcp-ack-sent",
+			"bootrequest-sent",
+			"bootreply-sent",
+		} {
+			if got, want := after.counter(c), before.counter(c); got <= want {
+				t.Errorf("Telemetry counter %s got %d after the exchange, want more than %d", c, got, want)
+			}
+		}
+	})
+
+	t.Run("NoRelay", func(t *testing.T) {
+		deleteRelay(t, dut)
+		msgs := exchange(t, ate, "port1", "port2", clientFlow(layers.DHCPMsgTypeDiscover))
+		if m := ofType(msgs, layers.DHCPMsgTypeDiscover); m != nil {
+			t.Errorf("DHCPv4 discover reached the server without a relay agent: %+v", *m)
+		}
+	})
+}
@@ -0,0 +1,283 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sflow_sampling_test
+
+import (
+	"flag"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/link"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+	"github.com/openconfig/ygot/ygot"
+)
+
+var samplingRate = flag.Uint("sampling_rate", 1000,
+	"1-in-N ingress sampling rate configured on dut:port1; it is halved at runtime by the RateChange subtest.")
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1 and
+// dut:port2 -> ate:port2.
+//
+//   - ate:port1 -> dut:port1 subnet 192.0.2.0/30, with the sFlow
+//     collector on ate:port1.
+//   - dut:port2 -> ate:port2 subnet 192.0.2.4/30.
+//
+// The traffic is sent from ate:port1 to ate:port2 and sampled on ingress
+// of dut:port1.
+const (
+	ipv4PrefixLen = 30
+
+	// sampleSize is the number of bytes of each sampled packet exported
+	// in its flow sample, enough for its Ethernet and IPv4 headers.
+	sampleSize = 128
+
+	// flowName is the name of the sampled flow, and frameCount and
+	// frameRate the number of frames it sends and its rate.
+	flowName   = "sampled"
+	frameCount = 200000
+	frameRate  = 20000
+
+	// exportTime is how long the DUT may take to export the samples of
+	// the traffic once it stopped.
+	exportTime = 10 * time.Second
+	// stateTimeout is how long the state of the DUT may take to reflect
+	// the configuration.
+	stateTimeout = 30 * time.Second
+)
+
+var (
+	dutPort1 = attrs.Attributes{
+		Desc:    "dutPort1",
+		IPv4:    "192.0.2.1",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	// atePort1 is also the sFlow collector.
+	atePort1 = attrs.Attributes{
+		Name:    "atePort1",
+		MAC:     "02:00:01:01:01:01",
+		IPv4:    "192.0.2.2",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	dutPort2 = attrs.Attributes{
+		Desc:    "dutPort2",
+		IPv4:    "192.0.2.5",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort2 = attrs.Attributes{
+		Name:    "atePort2",
+		MAC:     "02:00:02:01:01:01",
+		IPv4:    "192.0.2.6",
+		IPv4Len: ipv4PrefixLen,
+	}
+)
+
+// configureDUT configures port1 and port2 on the DUT.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	d := dut.Config()
+	for id, a := range map[string]*attrs.Attributes{"port1": &dutPort1, "port2": &dutPort2} {
+		dp := dut.Port(t, id)
+		i := a.NewInterface(dp.Name())
+		d.Interface(dp.Name()).Replace(t, i)
+		fptest.LogYgot(t, dp.String(), d.Interface(dp.Name()), i)
+	}
+}
+
+// sflowConfig returns the sFlow configuration sampling ingress packets
+// of the named interface 1-in-rate, and exporting them to the collector
+// on ate:port1 from dut:port1.
+func sflowConfig(name string, rate uint32) *telemetry.Sampling_Sflow {
+	s := &telemetry.Sampling_Sflow{
+		Enabled:     ygot.Bool(true),
+		AgentIdIpv4: ygot.String(dutPort1.IPv4),
+		SampleSize:  ygot.Uint16(sampleSize),
+	}
+	c := s.GetOrCreateCollector(atePort1.IPv4, traffic.SFlowPort)
+	c.SourceAddress = ygot.String(dutPort1.IPv4)
+	c.NetworkInstance = ygot.String(*deviations.DefaultNetworkInstance)
+	i := s.GetOrCreateInterface(name)
+	i.Enabled = ygot.Bool(true)
+	i.IngressSamplingRate = ygot.Uint32(rate)
+	return s
+}
+
+// configureATE configures the interfaces of the ATE with a capture of
+// the sFlow datagrams on ate:port1, and returns the OTG config after
+// adding the sampled flow from ate:port1 to ate:port2.  The OTG
+// protocols are left started.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice) gosnappi.Config {
+	otg := ate.OTG()
+	top := otg.NewConfig(t)
+	ap1 := ate.Port(t, "port1")
+	ap2 := ate.Port(t, "port2")
+	atePort1.AddToOTG(top, ap1, &dutPort1)
+	atePort2.AddToOTG(top, ap2, &dutPort2)
+	traffic.EnableFullCapture(top, ap1.ID())
+	otg.PushConfig(t, top)
+	otg.StartProtocols(t)
+
+	traffic.AddOTGIPv4Flow(t, ate, top, &traffic.OTGFlowParams{
+		Name:    flowName,
+		Src:     &atePort1,
+		Dst:     &atePort2,
+		SrcPort: ap1,
+		DstPort: ap2,
+		Gateway: &dutPort1,
+		Frame:   traffic.Frame{Size: 256, RatePPS: frameRate, Count: frameCount},
+	})
+	otg.PushConfig(t, top)
+	otg.StartProtocols(t)
+	return top
+}
+
+// sampling is what the collector received from the DUT while the
+// sampled flow ran.
+type sampling struct {
+	// sent is the number of packets of the flow sent.
+	sent uint64
+	// samples is the number of flow samples of the flow on the sampled
+	// interface, and rates the sampling rates they carried.
+	samples int
+	rates   map[uint32]int
+	// datagrams is the number of sFlow datagrams of the DUT.
+	datagrams int
+}
+
+// runSampled runs the sampled flow while capturing on the collector,
+// and returns the flow samples of its packets on the interface with
+// the ifindex.
+func runSampled(t *testing.T, ate *ondatra.ATEDevice, top gosnappi.Config, ifindex uint32) *sampling {
+	t.Helper()
+	id := ate.Port(t, "port1").ID()
+	traffic.StartCapture(t, ate, id)
+	r := traffic.ValidateOTGFlow(t, ate, top, flowName, nil)
+	time.Sleep(exportTime)
+	traffic.StopCapture(t, ate, id)
+
+	s := &sampling{sent: r.OutPkts, rates: map[uint32]int{}}
+	for _, p := range traffic.CapturedPackets(t, ate, id) {
+		if p.SFlow == nil || p.SFlow.Agent != dutPort1.IPv4 {
+			continue
+		}
+		s.datagrams++
+		for _, fs := range p.SFlow.FlowSamples {
+			if fs.InputInterface != ifindex || fs.Packet == nil {
+				continue
+			}
+			if h := fs.Packet.Outer(); h == nil || h.Src != atePort1.IPv4 || h.Dst != atePort2.IPv4 {
+				continue
+			}
+			s.samples++
+			s.rates[fs.SamplingRate]++
+		}
+	}
+	t.Logf("Collector received %d sFlow datagrams with %d samples of %d packets sent, at sampling rates %v", s.datagrams, s.samples, s.sent, s.rates)
+	return s
+}
+
+// verifySampling checks that the samples are consistent with sampling
+// 1-in-rate of the packets sent.  Sampling is random, so the number of
+// samples is binomially distributed around sent/rate, and is allowed
+// to differ from it by 5 standard deviations.
+func verifySampling(t *testing.T, s *sampling, rate uint32) {
+	t.Helper()
+	if s.datagrams == 0 {
+		t.Fatalf("Collector received no sFlow datagrams from agent %s", dutPort1.IPv4)
+	}
+	want := float64(s.sent) / float64(rate)
+	tolerance := 5 * math.Sqrt(want*(1-1/float64(rate)))
+	if got := float64(s.samples); math.Abs(got-want) > tolerance {
+		t.Errorf("Collector received %d samples of %d packets sent at 1-in-%d, want %.0f ± %.0f", s.samples, s.sent, rate, want, tolerance)
+	}
+	for r, n := range s.rates {
+		if r != rate {
+			t.Errorf("Collector received %d samples with sampling rate %d, want %d", n, r, rate)
+		}
+	}
+}
+
+func TestSflowSampling(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	ate := ondatra.ATE(t, "ate")
+	configureDUT(t, dut)
+	dp1 := dut.Port(t, "port1")
+	rate := uint32(*samplingRate)
+
+	sflow := dut.Config().Sampling().Sflow()
+	s := sflowConfig(dp1.Name(), rate)
+	sflow.Replace(t, s)
+	fptest.LogYgot(t, "sFlow", sflow, s)
+	defer sflow.Delete(t)
+
+	top := configureATE(t, ate)
+	link.WatchFlaps(t, dut, "port1", "port2")
+
+	state := dut.Telemetry().Sampling().Sflow()
+	if got, ok := state.Enabled().Watch(t, stateTimeout, func(v *telemetry.QualifiedBool) bool {
+		return v.IsPresent() && v.Val(t)
+	}).Await(t); !ok {
+		t.Fatalf("sFlow enabled state got %v, want true", got)
+	}
+	ifState := state.Interface(dp1.Name())
+	collector := state.Collector(atePort1.IPv4, traffic.SFlowPort)
+	if got := collector.SourceAddress().Get(t); got != dutPort1.IPv4 {
+		t.Errorf("sFlow collector source-address got %s, want %s", got, dutPort1.IPv4)
+	}
+	ifindex := dut.Telemetry().Interface(dp1.Name()).Ifindex().Get(t)
+
+	t.Run("Sampling", func(t *testing.T) {
+		if got := ifState.IngressSamplingRate().Get(t); got != rate {
+			t.Errorf("sFlow ingress-sampling-rate of %s got %d, want %d", dp1.Name(), got, rate)
+		}
+		sampledBefore := ifState.PacketsSampled().Get(t)
+		sentBefore := collector.PacketsSent().Get(t)
+
+		verifySampling(t, runSampled(t, ate, top, ifindex), rate)
+
+		if got := ifState.PacketsSampled().Get(t); got <= sampledBefore {
+			t.Errorf("sFlow packets-sampled of %s got %d, want more than %d", dp1.Name(), got, sampledBefore)
+		}
+		if got := collector.PacketsSent().Get(t); got <= sentBefore {
+			t.Errorf("sFlow collector packets-sent got %d, want more than %d", got, sentBefore)
+		}
+	})
+
+	t.Run("RateChange", func(t *testing.T) {
+		rate := rate / 2
+		sflow.Interface(dp1.Name()).IngressSamplingRate().Replace(t, rate)
+		if got, ok := ifState.IngressSamplingRate().Watch(t, stateTimeout, func(v *telemetry.QualifiedUint32) bool {
+			return v.IsPresent() && v.Val(t) == rate
+		}).Await(t); !ok {
+			t.Fatalf("sFlow ingress-sampling-rate of %s got %v, want %d", dp1.Name(), got, rate)
+		}
+		verifySampling(t, runSampled(t, ate, top, ifindex), rate)
+	})
+}
@@ -0,0 +1,312 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package static_route_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/aftcheck"
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/static"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of ate:port1 -> dut:port1,
+// dut:port2 -> ate:port2 and dut:port3 -> ate:port3.
+//
+//   - ate:port1 -> dut:port1 subnet 192.0.2.0/30 and 2001:db8::/126
+//   - ate:port2 -> dut:port2 subnet 192.0.2.4/30 and 2001:db8::4/126
+//   - ate:port3 -> dut:port3 subnet 192.0.2.8/30 and 2001:db8::8/126
+//
+// The destination networks are routed by static routes to ate:port2,
+// and to ate:port3 as a backup, and traffic is sent to them from
+// ate:port1.
+const (
+	ipv4PrefixLen = 30
+	ipv6PrefixLen = 126
+
+	dstNet4Name = "staticNet4"
+	dstNet4CIDR = "203.0.113.0/24"
+	dstNet6Name = "staticNet6"
+	dstNet6CIDR = "2001:db8:1::/64"
+
+	// primaryIndex and backupIndex are the indexes of the next hops to
+	// ate:port2 and ate:port3 in the preference case, with the primary
+	// next hop preferred for its lower preference.
+	primaryIndex      = "primary"
+	backupIndex       = "backup"
+	primaryPreference = 10
+	backupPreference  = 20
+
+	// routeTimeout is how long to wait for the static routes to be
+	// installed, polling the AFT every pollInterval.
+	routeTimeout = time.Minute
+	pollInterval = time.Second
+	// distributionTolerancePct is how many percentage points the
+	// fraction of the packets received by an ATE port may differ from
+	// the wanted fraction.
+	distributionTolerancePct = 1
+)
+
+var (
+	dutPort1 = attrs.Attributes{
+		Desc:    "dutPort1",
+		IPv4:    "192.0.2.1",
+		IPv4Len: ipv4PrefixLen,
+		IPv6:    "2001:db8::1",
+		IPv6Len: ipv6PrefixLen,
+	}
+
+	atePort1 = attrs.Attributes{
+		Name:    "atePort1",
+		IPv4:    "192.0.2.2",
+		IPv4Len: ipv4PrefixLen,
+		IPv6:    "2001:db8::2",
+		IPv6Len: ipv6PrefixLen,
+	}
+
+	dutPort2 = attrs.Attributes{
+		Desc:    "dutPort2",
+		IPv4:    "192.0.2.5",
+		IPv4Len: ipv4PrefixLen,
+		IPv6:    "2001:db8::5",
+		IPv6Len: ipv6PrefixLen,
+	}
+
+	atePort2 = attrs.Attributes{
+		Name:    "atePort2",
+		IPv4:    "192.0.2.6",
+		IPv4Len: ipv4PrefixLen,
+		IPv6:    "2001:db8::6",
+		IPv6Len: ipv6PrefixLen,
+	}
+
+	dutPort3 = attrs.Attributes{
+		Desc:    "dutPort3",
+		IPv4:    "192.0.2.9",
+		IPv4Len: ipv4PrefixLen,
+		IPv6:    "2001:db8::9",
+		IPv6Len: ipv6PrefixLen,
+	}
+
+	atePort3 = attrs.Attributes{
+		Name:    "atePort3",
+		IPv4:    "192.0.2.10",
+		IPv4Len: ipv4PrefixLen,
+		IPv6:    "2001:db8::a",
+		IPv6Len: ipv6PrefixLen,
+	}
+)
+
+// configureDUT configures port1, port2 and port3 on the DUT.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	attrs.ConfigureDUTPorts(t, dut, map[string]*attrs.Attributes{
+		"port1": &dutPort1,
+		"port2": &dutPort2,
+		"port3": &dutPort3,
+	})
+}
+
+// configureATE configures port1, port2 and port3 on the ATE, with the
+// destination networks added to port2 and port3.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice) *ondatra.ATETopology {
+	top := ate.Topology().New()
+	atePort1.AddToATE(top, ate.Port(t, "port1"), &dutPort1)
+	for _, p := range []struct {
+		id       string
+		ate, dut *attrs.Attributes
+	}{
+		{"port2", &atePort2, &dutPort2},
+		{"port3", &atePort3, &dutPort3},
+	} {
+		i := p.ate.AddToATE(top, ate.Port(t, p.id), p.dut)
+		i.AddNetwork(dstNet4Name).IPv4().WithAddress(dstNet4CIDR)
+		i.AddNetwork(dstNet6Name).IPv6().WithAddress(dstNet6CIDR)
+	}
+	return top
+}
+
+// nextHop returns the next hop with the given index and preference to
+// the IPv4 or IPv6 address of the ATE port, via the DUT port with the
+// same ID.
+func nextHop(t *testing.T, dut *ondatra.DUTDevice, v6 bool, index, portID string, ate *attrs.Attributes, preference uint32) *static.NextHop {
+	addr := ate.IPv4
+	if v6 {
+		addr = ate.IPv6
+	}
+	return &static.NextHop{
+		Index:      index,
+		Address:    addr,
+		Interface:  dut.Port(t, portID).Name(),
+		Preference: preference,
+	}
+}
+
+// checkState checks the static route state of prefix against the wanted
+// next hops, including their interface-ref if the deviation requires it.
+func checkState(t *testing.T, dut *ondatra.DUTDevice, prefix string, want []*static.NextHop) {
+	t.Helper()
+	sr := dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).
+		Protocol(telemetry.PolicyTypes_INSTALL_PROTOCOL_TYPE_STATIC, *deviations.StaticProtocolName).
+		Static(prefix).Get(t)
+	if got := sr.GetPrefix(); got != prefix {
+		t.Errorf("Static route %s prefix got %q, want %q", prefix, got, prefix)
+	}
+	if got, want := len(sr.NextHop), len(want); got != want {
+		t.Errorf("Static route %s got %d next hops, want %d", prefix, got, want)
+	}
+	for _, w := range want {
+		nh := sr.GetNextHop(w.Index)
+		if nh == nil {
+			t.Errorf("Static route %s is missing next hop %q", prefix, w.Index)
+			continue
+		}
+		if got, want := nh.GetNextHop(), telemetry.UnionString(w.Address); got != want {
+			t.Errorf("Static route %s next hop %q next-hop got %v, want %v", prefix, w.Index, got, want)
+		}
+		if w.Preference > 0 {
+			if got := nh.GetPreference(); got != w.Preference {
+				t.Errorf("Static route %s next hop %q preference got %d, want %d", prefix, w.Index, got, w.Preference)
+			}
+		}
+		if *deviations.StaticRouteNextHopInterfaceRef {
+			if got := nh.GetInterfaceRef().GetInterface(); got != w.Interface {
+				t.Errorf("Static route %s next hop %q interface-ref interface got %q, want %q", prefix, w.Index, got, w.Interface)
+			}
+		}
+	}
+}
+
+// awaitNextHop waits for the AFT entry of prefix to have a single next
+// hop with the wanted address.
+func awaitNextHop(t *testing.T, dut *ondatra.DUTDevice, prefix, want string) bool {
+	t.Helper()
+	var got []string
+	for deadline := time.Now().Add(routeTimeout); time.Now().Before(deadline); time.Sleep(pollInterval) {
+		got = nil
+		for _, nh := range aftcheck.EntryNextHops(t, dut, *deviations.DefaultNetworkInstance, prefix) {
+			got = append(got, nh.IPAddress)
+		}
+		if len(got) == 1 && got[0] == want {
+			return true
+		}
+	}
+	t.Errorf("AFT next hops of %s got %v, want [%s]", prefix, got, want)
+	return false
+}
+
+// checkForwarding sends IPv4 and IPv6 traffic from ate:port1 to the
+// destination networks, and checks that the packets received by
+// ate:port2 and ate:port3 are split according to the wanted weights.
+func checkForwarding(t *testing.T, dut *ondatra.DUTDevice, ate *ondatra.ATEDevice, top *ondatra.ATETopology, wantWeights []uint64) {
+	t.Helper()
+	flow4 := traffic.NewIPv4Flow(t, ate, top, &traffic.FlowParams{
+		Name:       "IPv4",
+		Src:        &atePort1,
+		Dst:        &atePort2,
+		AltDsts:    []*attrs.Attributes{&atePort3},
+		DstNetwork: dstNet4Name,
+	})
+	flow6 := traffic.NewIPv6Flow(t, ate, top, &traffic.FlowParams{
+		Name:       "IPv6",
+		Src:        &atePort1,
+		Dst:        &atePort2,
+		AltDsts:    []*attrs.Attributes{&atePort3},
+		DstNetwork: dstNet6Name,
+		DUT:        dut,
+		SrcPort:    dut.Port(t, "port1"),
+		DstPort:    dut.Port(t, "port2"),
+	})
+	traffic.CheckDistribution(t, ate, []string{"port2", "port3"}, wantWeights, distributionTolerancePct, &traffic.DistributionOptions{
+		Run: func() { traffic.ValidateFlows(t, ate, []*ondatra.Flow{flow4, flow6}, nil) },
+	})
+}
+
+func TestStaticRoute(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	ate := ondatra.ATE(t, "ate")
+
+	configureDUT(t, dut)
+	top := configureATE(t, ate)
+	top.Push(t).StartProtocols(t)
+	defer top.StopProtocols(t)
+
+	ni := *deviations.DefaultNetworkInstance
+	defer static.Delete(t, dut, ni, dstNet4CIDR)
+	defer static.Delete(t, dut, ni, dstNet6CIDR)
+
+	primary4 := nextHop(t, dut, false, primaryIndex, "port2", &atePort2, primaryPreference)
+	primary6 := nextHop(t, dut, true, primaryIndex, "port2", &atePort2, primaryPreference)
+	backup4 := nextHop(t, dut, false, backupIndex, "port3", &atePort3, backupPreference)
+	backup6 := nextHop(t, dut, true, backupIndex, "port3", &atePort3, backupPreference)
+
+	t.Run("Route", func(t *testing.T) {
+		nh4 := nextHop(t, dut, false, "0", "port2", &atePort2, 0)
+		nh6 := nextHop(t, dut, true, "0", "port2", &atePort2, 0)
+		static.Configure(t, dut, ni, dstNet4CIDR, nh4)
+		static.Configure(t, dut, ni, dstNet6CIDR, nh6)
+
+		ok4 := awaitNextHop(t, dut, dstNet4CIDR, atePort2.IPv4)
+		ok6 := awaitNextHop(t, dut, dstNet6CIDR, atePort2.IPv6)
+		if !ok4 || !ok6 {
+			t.FailNow()
+		}
+		checkState(t, dut, dstNet4CIDR, []*static.NextHop{nh4})
+		checkState(t, dut, dstNet6CIDR, []*static.NextHop{nh6})
+		checkForwarding(t, dut, ate, top, []uint64{1, 0})
+	})
+
+	t.Run("Preference", func(t *testing.T) {
+		static.Delete(t, dut, ni, dstNet4CIDR)
+		static.Delete(t, dut, ni, dstNet6CIDR)
+		static.Configure(t, dut, ni, dstNet4CIDR, primary4, backup4)
+		static.Configure(t, dut, ni, dstNet6CIDR, primary6, backup6)
+
+		ok4 := awaitNextHop(t, dut, dstNet4CIDR, atePort2.IPv4)
+		ok6 := awaitNextHop(t, dut, dstNet6CIDR, atePort2.IPv6)
+		if !ok4 || !ok6 {
+			t.FailNow()
+		}
+		checkState(t, dut, dstNet4CIDR, []*static.NextHop{primary4, backup4})
+		checkState(t, dut, dstNet6CIDR, []*static.NextHop{primary6, backup6})
+		checkForwarding(t, dut, ate, top, []uint64{1, 0})
+	})
+
+	t.Run("Backup", func(t *testing.T) {
+		static.DeleteNextHop(t, dut, ni, dstNet4CIDR, primaryIndex)
+		static.DeleteNextHop(t, dut, ni, dstNet6CIDR, primaryIndex)
+
+		ok4 := awaitNextHop(t, dut, dstNet4CIDR, atePort3.IPv4)
+		ok6 := awaitNextHop(t, dut, dstNet6CIDR, atePort3.IPv6)
+		if !ok4 || !ok6 {
+			t.FailNow()
+		}
+		checkState(t, dut, dstNet4CIDR, []*static.NextHop{backup4})
+		checkState(t, dut, dstNet6CIDR, []*static.NextHop{backup6})
+		checkForwarding(t, dut, ate, top, []uint64{0, 1})
+	})
+}
@@ -0,0 +1,261 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tacacs_server_test
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+	"github.com/openconfig/ygot/ygot"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+var (
+	mgmtAddress   = flag.String("mgmt_address", "", "Address of the management interface of the DUT the gRPC server listens on. The login attempt is skipped if empty.")
+	grpcPort      = flag.Uint("grpc_port", 9339, "Port of the gRPC server of the DUT.")
+	sourceAddress = flag.String("tacacs_source_address", "", "Address of the DUT the TACACS+ servers are reached from, e.g. the address of its management interface. The source address is not configured if empty.")
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// tacacsServer is a TACACS+ server the test configures. The servers are
+// in the documentation range so that they never answer.
+type tacacsServer struct {
+	address string
+	port    uint16
+}
+
+const (
+	// groupName is the name of the TACACS+ server group the test
+	// configures.
+	groupName = "fp-tacacs"
+	// secretKey is the shared secret of the TACACS+ servers.
+	secretKey = "fp-tacacs-secret"
+	// serverTimeout is the timeout in seconds of the TACACS+ servers.
+	serverTimeout = 3
+
+	// loginUser and loginPassword are the credentials of the login
+	// attempt, unknown to the DUT.
+	loginUser     = "fp-tacacs-user"
+	loginPassword = "fp-tacacs-password"
+
+	// dialTimeout is how long dialing the gRPC server may take.
+	dialTimeout = 10 * time.Second
+	// stateTimeout is how long the state of the DUT may take to reflect
+	// the configuration.
+	stateTimeout = 30 * time.Second
+	// counterTimeout is how long the connection counters of the
+	// TACACS+ servers may take to count the login attempt.
+	counterTimeout = time.Minute
+)
+
+var servers = []tacacsServer{
+	{address: "192.0.2.10", port: 49},
+	{address: "192.0.2.11", port: 4949},
+}
+
+// wantMethods is the authentication method list the test configures:
+// TACACS+ first, then the local users.
+var wantMethods = []telemetry.System_Aaa_Authentication_AuthenticationMethod_Union{
+	telemetry.AaaTypes_AAA_METHOD_TYPE_TACACS_ALL,
+	telemetry.AaaTypes_AAA_METHOD_TYPE_LOCAL,
+}
+
+// serverGroup returns the configuration of the TACACS+ server group.
+func serverGroup() *telemetry.System_Aaa_ServerGroup {
+	g := &telemetry.System_Aaa_ServerGroup{
+		Name: ygot.String(groupName),
+		Type: telemetry.AaaTypes_AAA_SERVER_TYPE_TACACS,
+	}
+	for _, s := range servers {
+		gs := g.GetOrCreateServer(s.address)
+		gs.Timeout = ygot.Uint16(serverTimeout)
+		tacacs := gs.GetOrCreateTacacs()
+		tacacs.Port = ygot.Uint16(s.port)
+		tacacs.SecretKey = ygot.String(secretKey)
+		if *sourceAddress != "" {
+			tacacs.SourceAddress = ygot.String(*sourceAddress)
+		}
+	}
+	return g
+}
+
+// connectionAttempts returns the number of connections to the TACACS+
+// server that timed out or failed.
+func connectionAttempts(t *testing.T, dut *ondatra.DUTDevice, address string) uint64 {
+	t.Helper()
+	s := dut.Telemetry().System().Aaa().ServerGroup(groupName).Server(address).Get(t)
+	return s.GetConnectionTimeouts() + s.GetConnectionFailures()
+}
+
+// attemptLogin sends a gNMI Capabilities request with the credentials
+// of an unknown user, and returns the error of the request.
+func attemptLogin() error {
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout+counterTimeout)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, net.JoinHostPort(*mgmtAddress, fmt.Sprint(*grpcPort)),
+		grpc.WithBlock(),
+		grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+			InsecureSkipVerify: true, // NOLINT
+		})))
+	if err != nil {
+		return fmt.Errorf("cannot dial the gRPC server: %w", err)
+	}
+	defer conn.Close()
+	ctx = metadata.AppendToOutgoingContext(ctx, "username", loginUser, "password", loginPassword)
+	_, err = gpb.NewGNMIClient(conn).Capabilities(ctx, &gpb.CapabilityRequest{})
+	return err
+}
+
+func TestTACACSServers(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	aaa := dut.Config().System().Aaa()
+
+	// The DUT is restored to local authentication only whatever happens,
+	// before the server group the method list refers to is removed.
+	defer func() {
+		t.Log("Restore local-only authentication")
+		aaa.Authentication().AuthenticationMethod().Replace(t, []telemetry.System_Aaa_Authentication_AuthenticationMethod_Union{
+			telemetry.AaaTypes_AAA_METHOD_TYPE_LOCAL,
+		})
+		aaa.ServerGroup(groupName).Delete(t)
+	}()
+
+	g := serverGroup()
+	fptest.LogYgot(t, "TACACS+ server group", aaa.ServerGroup(groupName), g)
+	aaa.ServerGroup(groupName).Replace(t, g)
+	aaa.Authentication().AuthenticationMethod().Replace(t, wantMethods)
+
+	t.Run("ServerGroup", func(t *testing.T) {
+		config := aaa.ServerGroup(groupName).Get(t)
+		if got := config.GetType(); got != telemetry.AaaTypes_AAA_SERVER_TYPE_TACACS {
+			t.Errorf("Config server group type got %v, want %v", got, telemetry.AaaTypes_AAA_SERVER_TYPE_TACACS)
+		}
+		state := dut.Telemetry().System().Aaa().ServerGroup(groupName)
+		if got := state.Type().Await(t, stateTimeout, telemetry.AaaTypes_AAA_SERVER_TYPE_TACACS); got.Val(t) != telemetry.AaaTypes_AAA_SERVER_TYPE_TACACS {
+			t.Errorf("Telemetry server group type got %v, want %v", got, telemetry.AaaTypes_AAA_SERVER_TYPE_TACACS)
+		}
+	})
+
+	for _, s := range servers {
+		t.Run(s.address, func(t *testing.T) {
+			config := aaa.ServerGroup(groupName).Server(s.address).Get(t)
+			if got := config.GetTimeout(); got != serverTimeout {
+				t.Errorf("Config timeout got %d, want %d", got, serverTimeout)
+			}
+			if got := config.GetTacacs().GetPort(); got != s.port {
+				t.Errorf("Config port got %d, want %d", got, s.port)
+			}
+			if got := config.GetTacacs().GetSourceAddress(); got != *sourceAddress {
+				t.Errorf("Config source-address got %q, want %q", got, *sourceAddress)
+			}
+
+			state := dut.Telemetry().System().Aaa().ServerGroup(groupName).Server(s.address)
+			if got := state.Address().Await(t, stateTimeout, s.address); got.Val(t) != s.address {
+				t.Errorf("Telemetry address got %v, want %s", got, s.address)
+			}
+			if got := state.Timeout().Get(t); got != serverTimeout {
+				t.Errorf("Telemetry timeout got %d, want %d", got, serverTimeout)
+			}
+			if got := state.Tacacs().Port().Get(t); got != s.port {
+				t.Errorf("Telemetry port got %d, want %d", got, s.port)
+			}
+			if *sourceAddress != "" {
+				if got := state.Tacacs().SourceAddress().Get(t); got != *sourceAddress {
+					t.Errorf("Telemetry source-address got %q, want %q", got, *sourceAddress)
+				}
+			}
+			// The secret may be reported hashed or not at all, but
+			// never as the plaintext that was configured.
+			if got := state.Tacacs().SecretKey().Lookup(t); got.IsPresent() && got.Val(t) == secretKey {
+				t.Errorf("Telemetry secret-key reports the plaintext secret")
+			}
+		})
+	}
+
+	t.Run("AuthenticationMethod", func(t *testing.T) {
+		config := aaa.Authentication().AuthenticationMethod().Get(t)
+		state := dut.Telemetry().System().Aaa().Authentication().AuthenticationMethod().Get(t)
+		for _, c := range []struct {
+			desc string
+			got  []telemetry.System_Aaa_Authentication_AuthenticationMethod_Union
+		}{
+			{"Config", config},
+			{"Telemetry", state},
+		} {
+			if len(c.got) != len(wantMethods) {
+				t.Errorf("%s authentication-method got %v, want %v", c.desc, c.got, wantMethods)
+				continue
+			}
+			for i, m := range c.got {
+				if m != wantMethods[i] {
+					t.Errorf("%s authentication-method got %v, want %v", c.desc, c.got, wantMethods)
+					break
+				}
+			}
+		}
+	})
+
+	t.Run("LoginAttempt", func(t *testing.T) {
+		if *deviations.TACACSLoginAttemptUnsupported {
+			t.Skip("Skipping since the DUT does not authenticate gRPC requests with TACACS+")
+		}
+		if *mgmtAddress == "" {
+			t.Skip("Skipping since --mgmt_address is not set")
+		}
+		before := make(map[string]uint64)
+		for _, s := range servers {
+			before[s.address] = connectionAttempts(t, dut, s.address)
+		}
+
+		// The servers never answer, so an unknown user must not be let
+		// in, and the attempt must reach at least the preferred server.
+		if err := attemptLogin(); err == nil {
+			t.Errorf("Login of unknown user %q succeeded, want it rejected", loginUser)
+		} else {
+			t.Logf("Login of unknown user %q rejected: %v", loginUser, err)
+		}
+
+		s := servers[0]
+		start := time.Now()
+		for {
+			after := connectionAttempts(t, dut, s.address)
+			if after > before[s.address] {
+				t.Logf("TACACS+ server %s failed or timed out connections %d -> %d after %v", s.address, before[s.address], after, time.Since(start).Round(time.Second))
+				break
+			}
+			if time.Since(start) >= counterTimeout {
+				t.Errorf("TACACS+ server %s failed or timed out connections got %d after %v, want more than %d", s.address, after, counterTimeout, before[s.address])
+				break
+			}
+			time.Sleep(5 * time.Second)
+		}
+	})
+}
@@ -0,0 +1,130 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mixed_origin_set_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/setrequest"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ygot/ygot"
+	"google.golang.org/grpc/status"
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// The testbed consists of dut:port1, whose description is configured
+// with OpenConfig and MTU with CLI in the same SetRequest.
+const (
+	ipv4PrefixLen = 30
+
+	// stateTimeout is how long the state of the DUT may take to reflect
+	// the configuration.
+	stateTimeout = 30 * time.Second
+)
+
+var dutPort1 = attrs.Attributes{
+	Desc:    "dutPort1 baseline",
+	IPv4:    "192.0.2.1",
+	IPv4Len: ipv4PrefixLen,
+}
+
+// cliKnob is the vendor CLI configuring the MTU of an interface, and the
+// MTU the interface then reports.
+type cliKnob struct {
+	config func(intf string) string
+	mtu    uint16
+}
+
+// cliKnobs are the CLI knobs by vendor.  Vendors without an entry skip
+// the test.
+var cliKnobs = map[ondatra.Vendor]cliKnob{
+	ondatra.ARISTA: {
+		config: func(intf string) string {
+			return fmt.Sprintf("interface %s\n   l2 mtu 9214\n", intf)
+		},
+		mtu: 9214,
+	},
+	ondatra.CISCO: {
+		config: func(intf string) string {
+			return fmt.Sprintf("interface %s\n mtu 9014\n", intf)
+		},
+		mtu: 9014,
+	},
+}
+
+// invalidCLI returns CLI configuration of the interface that no vendor
+// accepts.
+func invalidCLI(intf string) string {
+	return fmt.Sprintf("interface %s\n   fp-invalid-command 1\n", intf)
+}
+
+func TestMixedOriginSet(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	knob, ok := cliKnobs[dut.Vendor()]
+	if !ok {
+		t.Skipf("No CLI knob known for DUT vendor %v", dut.Vendor())
+	}
+	d := dut.Config()
+	dp := dut.Port(t, "port1")
+
+	// configureBaseline replaces the configuration of port1 with the
+	// baseline, which also resets the MTU set by CLI.
+	configureBaseline := func(t *testing.T) {
+		t.Helper()
+		i := dutPort1.NewInterface(dp.Name())
+		d.Interface(dp.Name()).Replace(t, i)
+		fptest.LogYgot(t, dp.String(), d.Interface(dp.Name()), i)
+	}
+
+	t.Run("OpenConfigAndCLI", func(t *testing.T) {
+		configureBaseline(t)
+		const desc = "dutPort1 mixed origin"
+		if _, err := setrequest.New().
+			Update(d.Interface(dp.Name()).Description(), ygot.String(desc)).
+			CLI(knob.config(dp.Name())).
+			Set(t, dut); err != nil {
+			t.Fatalf("Mixed origin SetRequest failed: %v", err)
+		}
+		if got := dut.Telemetry().Interface(dp.Name()).Description().Await(t, stateTimeout, desc); got.Val(t) != desc {
+			t.Errorf("DUT %s description got %v, want %q set with OpenConfig", dp, got, desc)
+		}
+		if got := dut.Telemetry().Interface(dp.Name()).Mtu().Await(t, stateTimeout, knob.mtu); got.Val(t) != knob.mtu {
+			t.Errorf("DUT %s MTU got %v, want %d set with CLI", dp, got, knob.mtu)
+		}
+	})
+
+	t.Run("InvalidCLIRejected", func(t *testing.T) {
+		configureBaseline(t)
+		const desc = "dutPort1 rejected mixed origin"
+		_, err := setrequest.New().
+			Update(d.Interface(dp.Name()).Description(), ygot.String(desc)).
+			CLI(invalidCLI(dp.Name())).
+			Set(t, dut)
+		if err == nil {
+			t.Fatalf("Mixed origin SetRequest with invalid CLI succeeded, want it rejected")
+		}
+		t.Logf("Mixed origin SetRequest rejected with code %v: %v", status.Code(err), err)
+		if got := d.Interface(dp.Name()).Description().Get(t); got != dutPort1.Desc {
+			t.Errorf("DUT %s description got %q, want %q unchanged by the rejected SetRequest", dp, got, dutPort1.Desc)
+		}
+	})
+}
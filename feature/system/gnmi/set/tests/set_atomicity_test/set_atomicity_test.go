@@ -0,0 +1,131 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package set_atomicity_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/setrequest"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ygot/ygot"
+	"google.golang.org/grpc/status"
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// The testbed consists of dut:port1 and dut:port2, which are configured
+// with a baseline before each SetRequest.
+const (
+	ipv4PrefixLen = 30
+	// invalidPrefixLen is an IPv4 prefix length out of the range of the
+	// model.
+	invalidPrefixLen = 33
+
+	// stateTimeout is how long the state of the DUT may take to reflect
+	// the configuration.
+	stateTimeout = 30 * time.Second
+)
+
+var (
+	dutPort1 = attrs.Attributes{
+		Desc:    "dutPort1 baseline",
+		IPv4:    "192.0.2.1",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	dutPort2 = attrs.Attributes{
+		Desc:    "dutPort2 baseline",
+		IPv4:    "192.0.2.5",
+		IPv4Len: ipv4PrefixLen,
+	}
+)
+
+// configureBaseline replaces the configuration of port1 and port2 of
+// the DUT with the baseline.
+func configureBaseline(t *testing.T, dut *ondatra.DUTDevice) {
+	t.Helper()
+	d := dut.Config()
+	for id, a := range map[string]*attrs.Attributes{"port1": &dutPort1, "port2": &dutPort2} {
+		dp := dut.Port(t, id)
+		i := a.NewInterface(dp.Name())
+		d.Interface(dp.Name()).Replace(t, i)
+		fptest.LogYgot(t, dp.String(), d.Interface(dp.Name()), i)
+	}
+}
+
+func TestSetAtomicity(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	d := dut.Config()
+	dp1 := dut.Port(t, "port1")
+	dp2 := dut.Port(t, "port2")
+
+	t.Run("InvalidUpdateRejected", func(t *testing.T) {
+		configureBaseline(t, dut)
+		const desc = "dutPort1 partial set"
+		_, err := setrequest.New().
+			Update(d.Interface(dp1.Name()).Description(), ygot.String(desc)).
+			Update(d.Interface(dp2.Name()).Subinterface(0).Ipv4().Address(dutPort2.IPv4).PrefixLength(), ygot.Uint8(invalidPrefixLen)).
+			Set(t, dut)
+		if err == nil {
+			t.Fatalf("SetRequest with prefix-length %d for %s succeeded, want it rejected", invalidPrefixLen, dp2)
+		}
+		t.Logf("SetRequest rejected with code %v: %v", status.Code(err), err)
+		// The error should point at the invalid update rather than the
+		// valid one, by the interface or the leaf.
+		msg := status.Convert(err).Message()
+		if !strings.Contains(msg, dp2.Name()) && !strings.Contains(msg, "prefix-length") {
+			t.Errorf("SetRequest error %q does not identify the failing path of %s prefix-length", msg, dp2)
+		}
+
+		if got := d.Interface(dp1.Name()).Description().Get(t); got != dutPort1.Desc {
+			t.Errorf("DUT %s config description got %q, want %q unchanged by the rejected SetRequest", dp1, got, dutPort1.Desc)
+		}
+		if got := dut.Telemetry().Interface(dp1.Name()).Description().Get(t); got != dutPort1.Desc {
+			t.Errorf("DUT %s state description got %q, want %q unchanged by the rejected SetRequest", dp1, got, dutPort1.Desc)
+		}
+		if got := d.Interface(dp2.Name()).Subinterface(0).Ipv4().Address(dutPort2.IPv4).PrefixLength().Get(t); got != ipv4PrefixLen {
+			t.Errorf("DUT %s prefix-length got %d, want %d unchanged by the rejected SetRequest", dp2, got, ipv4PrefixLen)
+		}
+	})
+
+	t.Run("ValidUpdatesApplied", func(t *testing.T) {
+		configureBaseline(t, dut)
+		want := map[*ondatra.Port]string{
+			dp1: "dutPort1 atomic set",
+			dp2: "dutPort2 atomic set",
+		}
+		b := setrequest.New()
+		for dp, desc := range want {
+			b.Update(d.Interface(dp.Name()).Description(), ygot.String(desc))
+		}
+		if _, err := b.Set(t, dut); err != nil {
+			t.Fatalf("SetRequest with valid updates of %s and %s failed: %v", dp1, dp2, err)
+		}
+		for dp, desc := range want {
+			if got := d.Interface(dp.Name()).Description().Get(t); got != desc {
+				t.Errorf("DUT %s config description got %q, want %q", dp, got, desc)
+			}
+			if got := dut.Telemetry().Interface(dp.Name()).Description().Await(t, stateTimeout, desc); got.Val(t) != desc {
+				t.Errorf("DUT %s state description got %v, want %q", dp, got, desc)
+			}
+		}
+	})
+}
@@ -0,0 +1,321 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package management_server_test
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/setrequest"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+	"github.com/openconfig/ygot/ygot"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+var (
+	mgmtAddress = flag.String("mgmt_address", "", "Address of the management interface of the DUT the gRPC servers listen on. The test is skipped if empty.")
+	grpcPort    = flag.Uint("grpc_port", 9339, "Port of the gRPC server the test connects to initially.")
+	newGRPCPort = flag.Uint("new_grpc_port", 9340, "Port of the gRPC server the test configures.")
+	username    = flag.String("grpc_username", "", "Username of the gRPC metadata authentication, if any.")
+	password    = flag.String("grpc_password", "", "Password of the gRPC metadata authentication, if any.")
+)
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+const (
+	// newServerName is the name of the gRPC server the test configures.
+	newServerName = "fp-management-server-test"
+
+	// sshPort is the port of the SSH server, and sshTimeout and
+	// sshSessionLimit the parameters the test configures.
+	sshPort         = 22
+	sshTimeout      = 600
+	sshSessionLimit = 16
+
+	// dialTimeout is how long a dial of a gRPC server that answers may
+	// take, and listenTimeout how long a gRPC server may take to start
+	// or stop answering after its configuration changes.
+	dialTimeout   = 10 * time.Second
+	listenTimeout = time.Minute
+	// stateTimeout is how long the state of the DUT may take to reflect
+	// the configuration.
+	stateTimeout = 30 * time.Second
+)
+
+// rpcContext returns a context of the RPCs of the test's own gRPC
+// connections, with the metadata authentication if any.
+func rpcContext() context.Context {
+	ctx := context.Background()
+	if *username != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "username", *username, "password", *password)
+	}
+	return ctx
+}
+
+// dial connects to the gNMI service of the gRPC server on the port, and
+// checks it answers a Capabilities request within dialTimeout.
+func dial(port uint) (gpb.GNMIClient, *grpc.ClientConn, error) {
+	ctx, cancel := context.WithTimeout(rpcContext(), dialTimeout)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, net.JoinHostPort(*mgmtAddress, fmt.Sprint(port)),
+		grpc.WithBlock(),
+		grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+			InsecureSkipVerify: true, // NOLINT
+		})))
+	if err != nil {
+		return nil, nil, err
+	}
+	c := gpb.NewGNMIClient(conn)
+	if _, err := c.Capabilities(ctx, &gpb.CapabilityRequest{}); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return c, conn, nil
+}
+
+// awaitListening waits for the gRPC server on the port to answer if
+// want is set, and to stop answering otherwise.
+func awaitListening(t *testing.T, port uint, want bool) error {
+	t.Helper()
+	start := time.Now()
+	for {
+		_, conn, err := dial(port)
+		if err == nil {
+			conn.Close()
+		}
+		if got := err == nil; got == want {
+			t.Logf("gRPC server on port %d answering %t after %v", port, want, time.Since(start).Round(time.Second))
+			return nil
+		}
+		if time.Since(start) >= listenTimeout {
+			return fmt.Errorf("gRPC server on port %d answering %t after %v, want %t: %v", port, !want, listenTimeout, want, err)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// set sends the SetRequest on the gNMI client.
+func set(t *testing.T, c gpb.GNMIClient, b *setrequest.Builder) error {
+	t.Helper()
+	req, err := b.Request()
+	if err != nil {
+		t.Fatalf("Cannot compose SetRequest: %v", err)
+	}
+	t.Logf("Send SetRequest: %v", req)
+	_, err = c.Set(rpcContext(), req)
+	return err
+}
+
+// originalServer returns the configuration of the gRPC server listening
+// on --grpc_port.
+func originalServer(t *testing.T, dut *ondatra.DUTDevice) *telemetry.System_GrpcServer {
+	t.Helper()
+	for _, s := range dut.Telemetry().System().GrpcServerAny().Get(t) {
+		if uint(s.GetPort()) == *grpcPort {
+			return dut.Config().System().GrpcServer(s.GetName()).Get(t)
+		}
+	}
+	t.Fatalf("No gRPC server of the DUT listens on --grpc_port %d", *grpcPort)
+	return nil
+}
+
+// rollback restores the original gRPC server through the test's
+// connection to the new one if it was removed.  It owns that connection
+// and closes it only after the restore: closing it first would leave the
+// DUT without any gRPC server to reach it.
+type rollback struct {
+	// restore is the SetRequest restoring the original server.
+	restore *setrequest.Builder
+	// newClient and newConn are the test's connection to the new server,
+	// set once it is dialed.
+	newClient gpb.GNMIClient
+	newConn   io.Closer
+	// origRemoved is set while the original server is removed.
+	origRemoved bool
+}
+
+// restoreOriginal restores the original server if it was removed, then
+// closes the connection to the new server.
+func (r *rollback) restoreOriginal(t *testing.T) error {
+	t.Helper()
+	if r.newConn != nil {
+		defer r.newConn.Close()
+	}
+	if !r.origRemoved {
+		return nil
+	}
+	return set(t, r.newClient, r.restore)
+}
+
+func testGRPCServer(t *testing.T, dut *ondatra.DUTDevice) {
+	d := dut.Config().System()
+	orig := originalServer(t, dut)
+	origName := orig.GetName()
+	t.Logf("The test connects to gRPC server %q on port %d", origName, orig.GetPort())
+
+	newServer, err := ygot.DeepCopy(orig)
+	if err != nil {
+		t.Fatalf("Cannot copy the configuration of gRPC server %q: %v", origName, err)
+	}
+	ns := newServer.(*telemetry.System_GrpcServer)
+	ns.Name = ygot.String(newServerName)
+	ns.Port = ygot.Uint16(uint16(*newGRPCPort))
+	ns.Enable = ygot.Bool(true)
+
+	// The configuration is rolled back whatever happens: the original
+	// server is restored through the new one if it was removed, and the
+	// new server removed through the original one.
+	rb := &rollback{restore: setrequest.New().Replace(d.GrpcServer(origName), orig)}
+	defer func() {
+		restored := rb.origRemoved
+		if restored {
+			t.Logf("Rollback: restore gRPC server %q", origName)
+		}
+		// The new server is kept unless the original one is back, since
+		// it may then be the only way to reach the DUT.
+		if err := rb.restoreOriginal(t); err != nil {
+			t.Errorf("Rollback cannot restore gRPC server %q through the new server, the DUT may be unreachable: %v", origName, err)
+			return
+		}
+		if restored {
+			if err := awaitListening(t, *grpcPort, true); err != nil {
+				t.Errorf("Rollback: %v", err)
+				return
+			}
+		}
+		t.Logf("Rollback: remove gRPC server %q", newServerName)
+		if err := set(t, dut.RawAPIs().GNMI().New(t), setrequest.New().Delete(d.GrpcServer(newServerName))); err != nil {
+			t.Errorf("Rollback cannot remove gRPC server %q: %v", newServerName, err)
+		}
+	}()
+
+	t.Logf("Add gRPC server %q on port %d", newServerName, *newGRPCPort)
+	fptest.LogYgot(t, "gRPC server", d.GrpcServer(newServerName), ns)
+	d.GrpcServer(newServerName).Replace(t, ns)
+
+	t.Run("NewServerState", func(t *testing.T) {
+		state := dut.Telemetry().System().GrpcServer(newServerName)
+		if got := state.Port().Await(t, stateTimeout, uint16(*newGRPCPort)); got.Val(t) != uint16(*newGRPCPort) {
+			t.Errorf("gRPC server %q port got %v, want %d", newServerName, got, *newGRPCPort)
+		}
+		if got := state.Enable().Await(t, stateTimeout, true); !got.Val(t) {
+			t.Errorf("gRPC server %q enable got %v, want true", newServerName, got)
+		}
+		if got := state.Name().Get(t); got != newServerName {
+			t.Errorf("gRPC server %q name got %q", newServerName, got)
+		}
+	})
+
+	if err := awaitListening(t, *newGRPCPort, true); err != nil {
+		t.Fatalf("New gRPC server not answering: %v", err)
+	}
+	newClient, conn, err := dial(*newGRPCPort)
+	if err != nil {
+		t.Fatalf("Cannot connect to the new gRPC server: %v", err)
+	}
+	// The rollback closes the connection once it no longer needs it.
+	rb.newClient, rb.newConn = newClient, conn
+
+	// From now on the test only configures the DUT through its own
+	// connection to the new server.
+	t.Logf("Remove gRPC server %q through the new server", origName)
+	if err := set(t, newClient, setrequest.New().Delete(d.GrpcServer(origName))); err != nil {
+		t.Fatalf("Cannot remove gRPC server %q through the new server: %v", origName, err)
+	}
+	rb.origRemoved = true
+
+	t.Run("OriginalServerRemoved", func(t *testing.T) {
+		if err := awaitListening(t, *grpcPort, false); err != nil {
+			t.Errorf("Removed gRPC server still answering: %v", err)
+		}
+		if _, conn, err := dial(*newGRPCPort); err != nil {
+			t.Errorf("New gRPC server not answering after the original one is removed: %v", err)
+		} else {
+			conn.Close()
+		}
+	})
+
+	t.Logf("Restore gRPC server %q through the new server", origName)
+	if err := set(t, newClient, rb.restore); err != nil {
+		t.Fatalf("Cannot restore gRPC server %q through the new server: %v", origName, err)
+	}
+	if err := awaitListening(t, *grpcPort, true); err != nil {
+		t.Fatalf("Restored gRPC server not answering: %v", err)
+	}
+	rb.origRemoved = false
+}
+
+func testSSHServer(t *testing.T, dut *ondatra.DUTDevice) {
+	d := dut.Config().System().SshServer()
+	orig := d.Get(t)
+	defer func() {
+		t.Logf("Restore the SSH server configuration")
+		d.Replace(t, orig)
+	}()
+
+	s := &telemetry.System_SshServer{
+		Enable:       ygot.Bool(true),
+		Timeout:      ygot.Uint16(sshTimeout),
+		SessionLimit: ygot.Uint16(sshSessionLimit),
+	}
+	fptest.LogYgot(t, "SSH server", d, s)
+	d.Update(t, s)
+
+	state := dut.Telemetry().System().SshServer()
+	if got := state.Enable().Await(t, stateTimeout, true); !got.Val(t) {
+		t.Errorf("SSH server enable got %v, want true", got)
+	}
+	if got := state.Timeout().Await(t, stateTimeout, sshTimeout); got.Val(t) != sshTimeout {
+		t.Errorf("SSH server timeout got %v, want %d", got, sshTimeout)
+	}
+	if got := state.SessionLimit().Await(t, stateTimeout, sshSessionLimit); got.Val(t) != sshSessionLimit {
+		t.Errorf("SSH server session-limit got %v, want %d", got, sshSessionLimit)
+	}
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(*mgmtAddress, fmt.Sprint(sshPort)), dialTimeout)
+	if err != nil {
+		t.Errorf("SSH server not answering on port %d: %v", sshPort, err)
+		return
+	}
+	conn.Close()
+}
+
+func TestManagementServers(t *testing.T) {
+	if *mgmtAddress == "" {
+		t.Skip("Skipping since --mgmt_address is not set")
+	}
+	dut := ondatra.DUT(t, "dut")
+
+	t.Run("GRPCServer", func(t *testing.T) {
+		testGRPCServer(t, dut)
+	})
+	t.Run("SSHServer", func(t *testing.T) {
+		testSSHServer(t, dut)
+	})
+}
@@ -0,0 +1,244 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote_syslog_test
+
+import (
+	"flag"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/link"
+	"github.com/openconfig/featureprofiles/internal/traffic"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+	"github.com/openconfig/ygot/ygot"
+)
+
+var facilityName = flag.String("syslog_facility", "LOCAL7", "Facility of the syslog messages the DUT logs the interface state changes with, e.g. LOCAL7.")
+
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+// Settings for configuring the baseline testbed with the test
+// topology.
+//
+// The testbed consists of dut:port1 -> ate:port1 and
+// dut:port2 -> ate:port2.
+//
+//   - dut:port1 -> ate:port1 subnet 192.0.2.0/30
+//   - dut:port2 -> ate:port2 subnet 192.0.2.4/30
+//
+// ate:port1 is the remote syslog host, and dut:port2 is disabled and
+// re-enabled to log messages.
+const (
+	ipv4PrefixLen = 30
+
+	// severity is the severity of the selector of the remote host, and
+	// severityCode its numeric code: messages of this severity and the
+	// more severe ones with lower codes are sent.
+	severity     = telemetry.SystemLogging_SyslogSeverity_NOTICE
+	severityCode = 5
+
+	// stateTimeout is how long the state of the DUT may take to reflect
+	// the configuration, and captureWait how long the DUT may take to
+	// send the messages after the last event.
+	stateTimeout = 30 * time.Second
+	captureWait  = 10 * time.Second
+)
+
+// facilities maps the syslog facilities the DUT may log with to their
+// numeric codes, per RFC 5424.
+var facilities = map[string]struct {
+	facility telemetry.E_SystemLogging_SYSLOG_FACILITY
+	code     uint8
+}{
+	"KERNEL":        {telemetry.SystemLogging_SYSLOG_FACILITY_KERNEL, 0},
+	"USER":          {telemetry.SystemLogging_SYSLOG_FACILITY_USER, 1},
+	"SYSTEM_DAEMON": {telemetry.SystemLogging_SYSLOG_FACILITY_SYSTEM_DAEMON, 3},
+	"SYSLOG":        {telemetry.SystemLogging_SYSLOG_FACILITY_SYSLOG, 5},
+	"LOCAL0":        {telemetry.SystemLogging_SYSLOG_FACILITY_LOCAL0, 16},
+	"LOCAL1":        {telemetry.SystemLogging_SYSLOG_FACILITY_LOCAL1, 17},
+	"LOCAL2":        {telemetry.SystemLogging_SYSLOG_FACILITY_LOCAL2, 18},
+	"LOCAL3":        {telemetry.SystemLogging_SYSLOG_FACILITY_LOCAL3, 19},
+	"LOCAL4":        {telemetry.SystemLogging_SYSLOG_FACILITY_LOCAL4, 20},
+	"LOCAL5":        {telemetry.SystemLogging_SYSLOG_FACILITY_LOCAL5, 21},
+	"LOCAL6":        {telemetry.SystemLogging_SYSLOG_FACILITY_LOCAL6, 22},
+	"LOCAL7":        {telemetry.SystemLogging_SYSLOG_FACILITY_LOCAL7, 23},
+}
+
+var (
+	dutPort1 = attrs.Attributes{
+		Desc:    "dutPort1",
+		IPv4:    "192.0.2.1",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort1 = attrs.Attributes{
+		Name:    "atePort1",
+		MAC:     "02:00:01:01:01:01",
+		IPv4:    "192.0.2.2",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	dutPort2 = attrs.Attributes{
+		Desc:    "dutPort2",
+		IPv4:    "192.0.2.5",
+		IPv4Len: ipv4PrefixLen,
+	}
+
+	atePort2 = attrs.Attributes{
+		Name:    "atePort2",
+		MAC:     "02:00:02:01:01:01",
+		IPv4:    "192.0.2.6",
+		IPv4Len: ipv4PrefixLen,
+	}
+)
+
+// configureDUT configures port1 and port2 on the DUT.
+func configureDUT(t *testing.T, dut *ondatra.DUTDevice) {
+	d := dut.Config()
+	for id, a := range map[string]*attrs.Attributes{"port1": &dutPort1, "port2": &dutPort2} {
+		dp := dut.Port(t, id)
+		i := a.NewInterface(dp.Name())
+		d.Interface(dp.Name()).Replace(t, i)
+		fptest.LogYgot(t, dp.String(), d.Interface(dp.Name()), i)
+	}
+}
+
+// configureATE configures port1 and port2 on the ATE, with a capture of
+// the packets received on port1, and starts its protocols.
+func configureATE(t *testing.T, ate *ondatra.ATEDevice) {
+	otg := ate.OTG()
+	top := otg.NewConfig(t)
+	ap1 := ate.Port(t, "port1")
+	atePort1.AddToOTG(top, ap1, &dutPort1)
+	atePort2.AddToOTG(top, ate.Port(t, "port2"), &dutPort2)
+	traffic.EnableCapture(top, ap1.ID())
+	otg.PushConfig(t, top)
+	otg.StartProtocols(t)
+}
+
+// remoteServer returns the configuration of ate:port1 as the remote
+// syslog host, with a selector of the facility.
+func remoteServer(facility telemetry.E_SystemLogging_SYSLOG_FACILITY) *telemetry.System_Logging_RemoteServer {
+	s := &telemetry.System_Logging_RemoteServer{
+		Host:          ygot.String(atePort1.IPv4),
+		RemotePort:    ygot.Uint16(traffic.SyslogPort),
+		SourceAddress: ygot.String(dutPort1.IPv4),
+	}
+	s.GetOrCreateSelector(facility, severity)
+	return s
+}
+
+// flapAndCapture disables and re-enables dut:port2 while ate:port1
+// captures, and returns the syslog messages sent to the remote host.
+func flapAndCapture(t *testing.T, ate *ondatra.ATEDevice, dut *ondatra.DUTDevice) []*traffic.Syslog {
+	t.Helper()
+	ap1 := ate.Port(t, "port1")
+	dp2 := dut.Port(t, "port2")
+
+	traffic.StartCapture(t, ate, ap1.ID())
+	link.DisableDUTPort(t, dut, dp2)
+	link.EnableDUTPort(t, dut, dp2)
+	time.Sleep(captureWait)
+	traffic.StopCapture(t, ate, ap1.ID())
+
+	var msgs []*traffic.Syslog
+	for _, p := range traffic.CapturedPackets(t, ate, ap1.ID()) {
+		if p.Syslog == nil || p.Outer() == nil || p.Outer().Dst != atePort1.IPv4 {
+			continue
+		}
+		t.Logf("Captured syslog message from %s: facility %d, severity %d: %s", p.Outer().Src, p.Syslog.Facility, p.Syslog.Severity, p.Syslog.Message)
+		msgs = append(msgs, p.Syslog)
+	}
+	return msgs
+}
+
+func TestRemoteSyslog(t *testing.T) {
+	f, ok := facilities[strings.ToUpper(*facilityName)]
+	if !ok {
+		var names []string
+		for name := range facilities {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		t.Fatalf("Unknown --syslog_facility %q, want one of %v", *facilityName, names)
+	}
+
+	dut := ondatra.DUT(t, "dut")
+	configureDUT(t, dut)
+
+	ate := ondatra.ATE(t, "ate")
+	configureATE(t, ate)
+	defer ate.OTG().StopProtocols(t)
+
+	host := atePort1.IPv4
+	config := dut.Config().System().Logging().RemoteServer(host)
+	s := remoteServer(f.facility)
+	fptest.LogYgot(t, "remote syslog host", config, s)
+	config.Replace(t, s)
+	defer func() {
+		if config.Lookup(t).IsPresent() {
+			config.Delete(t)
+		}
+	}()
+
+	t.Run("RemoteServerState", func(t *testing.T) {
+		state := dut.Telemetry().System().Logging().RemoteServer(host)
+		if got := state.Host().Await(t, stateTimeout, host); got.Val(t) != host {
+			t.Errorf("Telemetry host got %v, want %s", got, host)
+		}
+		if got := state.RemotePort().Get(t); got != traffic.SyslogPort {
+			t.Errorf("Telemetry remote-port got %d, want %d", got, traffic.SyslogPort)
+		}
+		if got := state.SourceAddress().Get(t); got != dutPort1.IPv4 {
+			t.Errorf("Telemetry source-address got %q, want %q", got, dutPort1.IPv4)
+		}
+		sel := state.Selector(f.facility, severity)
+		if got := sel.Facility().Get(t); got != f.facility {
+			t.Errorf("Telemetry selector facility got %v, want %v", got, f.facility)
+		}
+		if got := sel.Severity().Get(t); got != severity {
+			t.Errorf("Telemetry selector severity got %v, want %v", got, severity)
+		}
+	})
+
+	t.Run("MessagesDelivered", func(t *testing.T) {
+		msgs := flapAndCapture(t, ate, dut)
+		if len(msgs) == 0 {
+			t.Fatalf("No syslog message sent to %s for the state changes of dut:port2", host)
+		}
+		for _, m := range msgs {
+			if m.Facility != f.code {
+				t.Errorf("Syslog message %q facility got %d, want %d", m.Message, m.Facility, f.code)
+			}
+			if m.Severity > severityCode {
+				t.Errorf("Syslog message %q severity got %d, want at most %d", m.Message, m.Severity, severityCode)
+			}
+		}
+	})
+
+	t.Run("MessagesStopAfterRemoval", func(t *testing.T) {
+		config.Delete(t)
+		if msgs := flapAndCapture(t, ate, dut); len(msgs) != 0 {
+			t.Errorf("Got %d syslog messages sent to %s after its removal, want none", len(msgs), host)
+		}
+	})
+}
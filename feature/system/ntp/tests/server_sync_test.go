@@ -0,0 +1,212 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package system_ntp_test
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+	"github.com/openconfig/ygot/ygot"
+)
+
+var (
+	ntpResponder     = flag.String("ntp_responder", "", "Address of an NTP server reachable from the DUT, e.g. on the test host. The synchronization of the DUT is only checked if set.")
+	ntpSourceAddress = flag.String("ntp_source_address", "", "Address of the DUT its NTP requests are sent from, e.g. the address of its management interface. The source address is not configured if empty.")
+)
+
+const (
+	// syncTimeout is how long the DUT may take to synchronize with the
+	// responder once configured.
+	syncTimeout = 5 * time.Minute
+	// ntpStateTimeout is how long the state of the DUT may take to
+	// reflect the configuration.
+	ntpStateTimeout = 30 * time.Second
+
+	ntpKeyID    = 1
+	ntpKeyValue = "fp-ntp-key"
+)
+
+// ntpServer is an NTP server the test configures.
+type ntpServer struct {
+	address string
+	prefer  bool
+}
+
+// ntpServers returns the servers the test configures: the responder if
+// any, preferred over servers in the documentation ranges which never
+// answer.
+func ntpServers() []ntpServer {
+	servers := []ntpServer{
+		{address: "192.0.2.123"},
+		{address: "2001:db8::123"},
+	}
+	if *ntpResponder != "" {
+		servers = append([]ntpServer{{address: *ntpResponder, prefer: true}}, servers...)
+	}
+	return servers
+}
+
+// ntpConfig returns the NTP configuration of the servers.
+func ntpConfig(servers []ntpServer) *telemetry.System_Ntp {
+	n := &telemetry.System_Ntp{
+		Enabled: ygot.Bool(true),
+	}
+	if *ntpSourceAddress != "" {
+		n.NtpSourceAddress = ygot.String(*ntpSourceAddress)
+	}
+	for _, s := range servers {
+		ns := n.GetOrCreateServer(s.address)
+		ns.Port = ygot.Uint16(123)
+		ns.Version = ygot.Uint8(4)
+		ns.AssociationType = telemetry.Server_AssociationType_SERVER
+		ns.Iburst = ygot.Bool(true)
+		ns.Prefer = ygot.Bool(s.prefer)
+	}
+	return n
+}
+
+// currentNtp returns the NTP configuration of the DUT, or nil if it has
+// none.
+func currentNtp(t *testing.T, dut *ondatra.DUTDevice) *telemetry.System_Ntp {
+	t.Helper()
+	if q := dut.Config().System().Ntp().Lookup(t); q.IsPresent() {
+		return q.Val(t)
+	}
+	return nil
+}
+
+// restoreNtp replaces the NTP configuration of the DUT with the one it
+// had before the test, which may be empty.
+func restoreNtp(t *testing.T, dut *ondatra.DUTDevice, orig *telemetry.System_Ntp) {
+	t.Helper()
+	config := dut.Config().System().Ntp()
+	if orig == nil {
+		config.Delete(t)
+		return
+	}
+	config.Replace(t, orig)
+}
+
+// TestNtpServerSync tests the configuration and state of multiple NTP
+// servers with a preferred one, the enabled toggle, and, if a responder
+// is reachable, the synchronization of the DUT with it.
+func TestNtpServerSync(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	config := dut.Config().System().Ntp()
+	state := dut.Telemetry().System().Ntp()
+
+	defer restoreNtp(t, dut, currentNtp(t, dut))
+
+	servers := ntpServers()
+	config.Replace(t, ntpConfig(servers))
+
+	for _, s := range servers {
+		t.Run(s.address, func(t *testing.T) {
+			configGot := config.Server(s.address).Get(t)
+			if got := configGot.GetPrefer(); got != s.prefer {
+				t.Errorf("Config prefer got %t, want %t", got, s.prefer)
+			}
+			if got := configGot.GetAssociationType(); got != telemetry.Server_AssociationType_SERVER {
+				t.Errorf("Config association-type got %v, want %v", got, telemetry.Server_AssociationType_SERVER)
+			}
+
+			ss := state.Server(s.address)
+			if got := ss.Address().Await(t, ntpStateTimeout, s.address); got.Val(t) != s.address {
+				t.Errorf("Telemetry address got %v, want %s", got, s.address)
+			}
+			stateGot := ss.Get(t)
+			if got := stateGot.GetPort(); got != 123 {
+				t.Errorf("Telemetry port got %d, want 123", got)
+			}
+			if got := stateGot.GetVersion(); got != 4 {
+				t.Errorf("Telemetry version got %d, want 4", got)
+			}
+			if got := stateGot.GetIburst(); !got {
+				t.Errorf("Telemetry iburst got %t, want true", got)
+			}
+			if got := stateGot.GetPrefer(); got != s.prefer {
+				t.Errorf("Telemetry prefer got %t, want %t", got, s.prefer)
+			}
+		})
+	}
+
+	t.Run("SourceAddress", func(t *testing.T) {
+		if *ntpSourceAddress == "" {
+			t.Skip("Skipping since --ntp_source_address is not set")
+		}
+		if got := state.NtpSourceAddress().Await(t, ntpStateTimeout, *ntpSourceAddress); got.Val(t) != *ntpSourceAddress {
+			t.Errorf("Telemetry ntp-source-address got %v, want %s", got, *ntpSourceAddress)
+		}
+	})
+
+	t.Run("Synchronization", func(t *testing.T) {
+		if *ntpResponder == "" {
+			t.Skip("Skipping since --ntp_responder is not set")
+		}
+		ss := state.Server(*ntpResponder)
+		stratum, ok := ss.Stratum().Watch(t, syncTimeout, func(v *telemetry.QualifiedUint8) bool {
+			return v.IsPresent() && v.Val(t) >= 1 && v.Val(t) <= 15
+		}).Await(t)
+		if !ok {
+			t.Fatalf("Telemetry stratum of %s got %v after %v, want between 1 and 15", *ntpResponder, stratum, syncTimeout)
+		}
+		t.Logf("Telemetry stratum of %s is %d", *ntpResponder, stratum.Val(t))
+		if got := ss.Offset().Lookup(t); !got.IsPresent() {
+			t.Errorf("Telemetry offset of %s is not populated", *ntpResponder)
+		}
+		if got := ss.PollInterval().Lookup(t); !got.IsPresent() {
+			t.Errorf("Telemetry poll-interval of %s is not populated", *ntpResponder)
+		}
+	})
+
+	t.Run("EnabledToggle", func(t *testing.T) {
+		for _, want := range []bool{false, true} {
+			config.Enabled().Replace(t, want)
+			if got := state.Enabled().Await(t, ntpStateTimeout, want); got.Val(t) != want {
+				t.Errorf("Telemetry enabled got %v, want %t", got, want)
+			}
+		}
+	})
+}
+
+// TestNtpAuthentication tests the configuration of an NTP key. No
+// responder shares the key, so only the configuration is checked.
+func TestNtpAuthentication(t *testing.T) {
+	dut := ondatra.DUT(t, "dut")
+	config := dut.Config().System().Ntp()
+
+	defer restoreNtp(t, dut, currentNtp(t, dut))
+
+	n := ntpConfig(ntpServers())
+	n.EnableNtpAuth = ygot.Bool(true)
+	key := n.GetOrCreateNtpKey(ntpKeyID)
+	key.KeyType = telemetry.System_NTP_AUTH_TYPE_NTP_AUTH_MD5
+	key.KeyValue = ygot.String(ntpKeyValue)
+	config.Replace(t, n)
+
+	if got := config.EnableNtpAuth().Get(t); !got {
+		t.Errorf("Config enable-ntp-auth got %t, want true", got)
+	}
+	keyGot := config.NtpKey(ntpKeyID).Get(t)
+	if got := keyGot.GetKeyId(); got != ntpKeyID {
+		t.Errorf("Config key-id got %d, want %d", got, ntpKeyID)
+	}
+	if got := keyGot.GetKeyType(); got != telemetry.System_NTP_AUTH_TYPE_NTP_AUTH_MD5 {
+		t.Errorf("Config key-type got %v, want %v", got, telemetry.System_NTP_AUTH_TYPE_NTP_AUTH_MD5)
+	}
+}
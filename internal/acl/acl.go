@@ -0,0 +1,197 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package acl builds OpenConfig ACL sets from match criteria and
+// forwarding actions, and attaches them to interfaces, so that tests
+// filtering or classifying traffic configure them the same way.
+package acl
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+	"github.com/openconfig/ygot/ygot"
+)
+
+// Match are the criteria a packet must meet to match an ACL entry or a
+// classifier term.  Criteria left empty match any packet.
+type Match struct {
+	// SrcPrefix and DstPrefix are the IPv4 or IPv6 prefixes of the
+	// source and destination addresses, e.g. "198.51.100.0/24".
+	SrcPrefix string
+	DstPrefix string
+	// DSCP is the DSCP of the packet, matched only if non-zero.
+	DSCP uint8
+}
+
+// IsIPv6 returns whether the prefixes of the match are IPv6, or an
+// error if a prefix is invalid or the prefixes are of both families.
+func (m *Match) IsIPv6() (bool, error) {
+	var v4s, v6s int
+	for _, p := range []string{m.SrcPrefix, m.DstPrefix} {
+		if p == "" {
+			continue
+		}
+		ip, _, err := net.ParseCIDR(p)
+		if err != nil {
+			return false, err
+		}
+		if ip.To4() != nil {
+			v4s++
+		} else {
+			v6s++
+		}
+	}
+	if v4s > 0 && v6s > 0 {
+		return false, fmt.Errorf("prefixes %q and %q are of different families", m.SrcPrefix, m.DstPrefix)
+	}
+	return v6s > 0, nil
+}
+
+// Entry is an entry of an ACL set.
+type Entry struct {
+	// SequenceID is the sequence number of the entry.  If zero, the
+	// entry is numbered after its position in the set: 10, 20 and so on.
+	SequenceID uint32
+	Match      Match
+	// Action is the forwarding action taken on the matching packets.
+	Action telemetry.E_Acl_FORWARDING_ACTION
+}
+
+// Set is a named ACL set, whose entries are evaluated in order.
+type Set struct {
+	Name string
+	// Type is the type of the set, ACL_IPV4 or ACL_IPV6, which the
+	// prefixes of its entries must be of.
+	Type    telemetry.E_Acl_ACL_TYPE
+	Entries []*Entry
+}
+
+// SequenceID returns the sequence number of the entry at index i of the
+// set, as Build numbers it.
+func (s *Set) SequenceID(i int) uint32 {
+	if id := s.Entries[i].SequenceID; id != 0 {
+		return id
+	}
+	return uint32(10 * (i + 1))
+}
+
+// PermitAll returns an entry accepting all packets, e.g. to end a set
+// that denies some packets.
+func PermitAll() *Entry {
+	return &Entry{Action: telemetry.Acl_FORWARDING_ACTION_ACCEPT}
+}
+
+// DenyAll returns an entry dropping all packets, e.g. to end a set
+// explicitly rather than relying on its implicit deny.
+func DenyAll() *Entry {
+	return &Entry{Action: telemetry.Acl_FORWARDING_ACTION_DROP}
+}
+
+// Build builds the ACL with the sets.  It returns an error if a set has
+// an unsupported type, or if a prefix of an entry is invalid or not of
+// the type of its set.
+func Build(sets []*Set) (*telemetry.Acl, error) {
+	a := &telemetry.Acl{}
+	for _, s := range sets {
+		if s.Type != telemetry.Acl_ACL_TYPE_ACL_IPV4 && s.Type != telemetry.Acl_ACL_TYPE_ACL_IPV6 {
+			return nil, fmt.Errorf("ACL set %s: unsupported type %v", s.Name, s.Type)
+		}
+		as := a.GetOrCreateAclSet(s.Name, s.Type)
+		for i, e := range s.Entries {
+			id := s.SequenceID(i)
+			v6, err := e.Match.IsIPv6()
+			if err != nil {
+				return nil, fmt.Errorf("ACL set %s entry %d: %w", s.Name, id, err)
+			}
+			if e.Match.SrcPrefix != "" || e.Match.DstPrefix != "" {
+				if v6 != (s.Type == telemetry.Acl_ACL_TYPE_ACL_IPV6) {
+					return nil, fmt.Errorf("ACL set %s entry %d: prefixes are not of type %v", s.Name, id, s.Type)
+				}
+			}
+			ae := as.GetOrCreateAclEntry(id)
+			ae.GetOrCreateActions().ForwardingAction = e.Action
+			if s.Type == telemetry.Acl_ACL_TYPE_ACL_IPV6 {
+				m := ae.GetOrCreateIpv6()
+				if e.Match.SrcPrefix != "" {
+					m.SourceAddress = ygot.String(e.Match.SrcPrefix)
+				}
+				if e.Match.DstPrefix != "" {
+					m.DestinationAddress = ygot.String(e.Match.DstPrefix)
+				}
+				if e.Match.DSCP != 0 {
+					m.Dscp = ygot.Uint8(e.Match.DSCP)
+				}
+				continue
+			}
+			m := ae.GetOrCreateIpv4()
+			if e.Match.SrcPrefix != "" {
+				m.SourceAddress = ygot.String(e.Match.SrcPrefix)
+			}
+			if e.Match.DstPrefix != "" {
+				m.DestinationAddress = ygot.String(e.Match.DstPrefix)
+			}
+			if e.Match.DSCP != 0 {
+				m.Dscp = ygot.Uint8(e.Match.DSCP)
+			}
+		}
+	}
+	return a, nil
+}
+
+// AttachIngress applies the set to the packets received by the
+// interface with the given name, which also identifies the interface in
+// the ACL config.
+func AttachIngress(a *telemetry.Acl, intf string, s *Set) {
+	ai := a.GetOrCreateInterface(intf)
+	ref := ai.GetOrCreateInterfaceRef()
+	ref.Interface = ygot.String(intf)
+	ref.Subinterface = ygot.Uint32(0)
+	ai.GetOrCreateIngressAclSet(s.Name, s.Type)
+}
+
+// Configure replaces the ACL config of the DUT.
+func Configure(t testing.TB, dut *ondatra.DUTDevice, a *telemetry.Acl) {
+	t.Helper()
+	p := dut.Config().Acl()
+	fptest.LogYgot(t, "DUT ACL", p, a)
+	p.Replace(t, a)
+}
+
+// Delete removes the ACL config from the DUT.
+func Delete(t testing.TB, dut *ondatra.DUTDevice) {
+	t.Helper()
+	dut.Config().Acl().Delete(t)
+}
+
+// IngressMatchedPackets returns the matched-packets counter of each
+// entry of the set applied to the packets received by the interface, by
+// sequence number.  Entries whose counter the DUT does not report are
+// omitted.
+func IngressMatchedPackets(t testing.TB, dut *ondatra.DUTDevice, intf string, s *Set) map[uint32]uint64 {
+	t.Helper()
+	path := dut.Telemetry().Acl().Interface(intf).IngressAclSet(s.Name, s.Type)
+	pkts := make(map[uint32]uint64)
+	for i := range s.Entries {
+		id := s.SequenceID(i)
+		if q := path.AclEntry(id).MatchedPackets().Lookup(t); q.IsPresent() {
+			pkts[id] = q.Val(t)
+		}
+	}
+	return pkts
+}
@@ -0,0 +1,125 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acl
+
+import (
+	"testing"
+
+	"github.com/openconfig/ondatra/telemetry"
+)
+
+func TestBuild(t *testing.T) {
+	v4 := &Set{
+		Name: "FILTER-V4",
+		Type: telemetry.Acl_ACL_TYPE_ACL_IPV4,
+		Entries: []*Entry{
+			{Match: Match{SrcPrefix: "198.51.100.0/24"}, Action: telemetry.Acl_FORWARDING_ACTION_ACCEPT},
+			{SequenceID: 15, Match: Match{SrcPrefix: "203.0.113.0/24", DSCP: 10}, Action: telemetry.Acl_FORWARDING_ACTION_DROP},
+			DenyAll(),
+		},
+	}
+	v6 := &Set{
+		Name:    "FILTER-V6",
+		Type:    telemetry.Acl_ACL_TYPE_ACL_IPV6,
+		Entries: []*Entry{{Match: Match{DstPrefix: "2001:db8:1::/48"}, Action: telemetry.Acl_FORWARDING_ACTION_DROP}, PermitAll()},
+	}
+	a, err := Build([]*Set{v4, v6})
+	if err != nil {
+		t.Fatalf("Build() got error: %v", err)
+	}
+	AttachIngress(a, "Ethernet1", v4)
+
+	as := a.GetAclSet(v4.Name, v4.Type)
+	for _, c := range []struct {
+		id      uint32
+		src     string
+		dscp    uint8
+		action  telemetry.E_Acl_FORWARDING_ACTION
+		noMatch bool
+	}{
+		{id: 10, src: "198.51.100.0/24", action: telemetry.Acl_FORWARDING_ACTION_ACCEPT},
+		{id: 15, src: "203.0.113.0/24", dscp: 10, action: telemetry.Acl_FORWARDING_ACTION_DROP},
+		{id: 30, action: telemetry.Acl_FORWARDING_ACTION_DROP, noMatch: true},
+	} {
+		e := as.GetAclEntry(c.id)
+		if e == nil {
+			t.Errorf("Entry %d is missing", c.id)
+			continue
+		}
+		if got := e.GetActions().GetForwardingAction(); got != c.action {
+			t.Errorf("Entry %d forwarding-action got %v, want %v", c.id, got, c.action)
+		}
+		if got := e.GetIpv4().GetSourceAddress(); got != c.src {
+			t.Errorf("Entry %d source-address got %q, want %q", c.id, got, c.src)
+		}
+		if got := e.GetIpv4().GetDscp(); got != c.dscp {
+			t.Errorf("Entry %d dscp got %d, want %d", c.id, got, c.dscp)
+		}
+		if c.noMatch && e.GetIpv4().GetDestinationAddress() != "" {
+			t.Errorf("Entry %d destination-address got %q, want none", c.id, e.GetIpv4().GetDestinationAddress())
+		}
+	}
+
+	e := a.GetAclSet(v6.Name, v6.Type).GetAclEntry(10)
+	if got, want := e.GetIpv6().GetDestinationAddress(), "2001:db8:1::/48"; got != want {
+		t.Errorf("IPv6 entry destination-address got %q, want %q", got, want)
+	}
+	if e.Ipv4 != nil {
+		t.Errorf("IPv6 entry has IPv4 match %v", e.Ipv4)
+	}
+
+	ai := a.GetInterface("Ethernet1")
+	if got := ai.GetInterfaceRef().GetInterface(); got != "Ethernet1" {
+		t.Errorf("Interface ref got %q, want %q", got, "Ethernet1")
+	}
+	if ai.GetIngressAclSet(v4.Name, v4.Type) == nil {
+		t.Errorf("Ingress ACL set %s is not attached", v4.Name)
+	}
+
+	if got, want := v4.SequenceID(2), uint32(30); got != want {
+		t.Errorf("SequenceID(2) got %d, want %d", got, want)
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	for _, c := range []struct {
+		desc string
+		set  *Set
+	}{{
+		desc: "unsupported type",
+		set:  &Set{Name: "BAD", Type: telemetry.Acl_ACL_TYPE_ACL_L2},
+	}, {
+		desc: "invalid prefix",
+		set: &Set{Name: "BAD", Type: telemetry.Acl_ACL_TYPE_ACL_IPV4, Entries: []*Entry{
+			{Match: Match{SrcPrefix: "198.51.100.0"}},
+		}},
+	}, {
+		desc: "mixed families",
+		set: &Set{Name: "BAD", Type: telemetry.Acl_ACL_TYPE_ACL_IPV4, Entries: []*Entry{
+			{Match: Match{SrcPrefix: "198.51.100.0/24", DstPrefix: "2001:db8::/32"}},
+		}},
+	}, {
+		desc: "prefix not of set type",
+		set: &Set{Name: "BAD", Type: telemetry.Acl_ACL_TYPE_ACL_IPV4, Entries: []*Entry{
+			{Match: Match{SrcPrefix: "2001:db8::/32"}},
+		}},
+	}} {
+		t.Run(c.desc, func(t *testing.T) {
+			if _, err := Build([]*Set{c.set}); err == nil {
+				t.Errorf("Build() got no error, want error")
+			}
+		})
+	}
+}
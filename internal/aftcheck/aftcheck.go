@@ -0,0 +1,424 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package aftcheck verifies the AFT telemetry of the DUT after gRIBI
+// operations.  The AFT telemetry may lag the gRIBI ACK, so rather than
+// reading it once, the checks watch it until the expected state appears
+// or a deadline passes, and then report the state last seen.  A failed
+// check also writes a snapshot of the whole AFT to the outputs
+// directory, named after the test, for triage after the testbed is torn
+// down.
+package aftcheck
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/freshness"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+	"github.com/openconfig/ygot/ygot"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// DefaultTimeout is how long the checks wait for the expected state if
+// Options.Timeout is not set.
+const DefaultTimeout = 30 * time.Second
+
+// probeTimeout is how long the subscription probing whether the DUT
+// supports a scoped AFT query may take.
+const probeTimeout = 10 * time.Second
+
+// minStepTimeout is the least time a later step of a check is given,
+// even if the earlier steps used up the timeout.
+const minStepTimeout = 5 * time.Second
+
+// Options configure the checks.  A nil *Options uses the defaults.
+type Options struct {
+	// Timeout is how long to wait for the expected state, e.g. longer
+	// than DefaultTimeout for devices that only ACK the RIB and may
+	// program the FIB, and report it, later.
+	Timeout time.Duration
+	// Freshness, if set, makes the checks first verify that the AFT
+	// telemetry is not stale with these options, since a device may
+	// serve cached AFT data, e.g. after a restart.
+	Freshness *freshness.Options
+}
+
+// freshnessOpts returns the options of the freshness check, or nil if the
+// AFT freshness is not checked.
+func (o *Options) freshnessOpts() *freshness.Options {
+	if o == nil {
+		return nil
+	}
+	return o.Freshness
+}
+
+func (o *Options) timeout() time.Duration {
+	if o == nil || o.Timeout == 0 {
+		return DefaultTimeout
+	}
+	return o.Timeout
+}
+
+// until returns the options of a later step of a check that must end
+// by the deadline, without the freshness check done by the first step.
+// The step is given at least minStepTimeout to see the current state.
+func (o *Options) until(deadline time.Time) *Options {
+	timeout := time.Until(deadline)
+	if timeout < minStepTimeout {
+		timeout = minStepTimeout
+	}
+	return &Options{Timeout: timeout}
+}
+
+// NextHopGroup returns the next hop group of the AFT with the given
+// gRIBI ID, or nil if there is none.  The next hop group is matched by
+// programmed-id, or by key with --deviation_gribi_nhg_match_by_key.
+func NextHopGroup(afts *telemetry.NetworkInstance_Afts, id uint64) *telemetry.NetworkInstance_Afts_NextHopGroup {
+	return findNHG(nhgList(afts), id, *deviations.GRIBINHGMatchByKey)
+}
+
+// nhgList returns the next hop groups of the AFT.
+func nhgList(afts *telemetry.NetworkInstance_Afts) []*telemetry.NetworkInstance_Afts_NextHopGroup {
+	var nhgs []*telemetry.NetworkInstance_Afts_NextHopGroup
+	for _, nhg := range afts.NextHopGroup {
+		nhgs = append(nhgs, nhg)
+	}
+	return nhgs
+}
+
+// NextHopWeights returns the weights of the next hops of the next hop
+// group of the AFT with the given gRIBI ID in ascending order, or nil
+// if there is no such group.
+func NextHopWeights(afts *telemetry.NetworkInstance_Afts, id uint64) []uint64 {
+	nhg := NextHopGroup(afts, id)
+	if nhg == nil {
+		return nil
+	}
+	return nhgWeights(nhg)
+}
+
+// nhgWeights returns the weights of the next hops of the next hop group
+// in ascending order.  Unlike their IP addresses, the weights are held
+// by the group itself, so the AFT next hops are not needed.
+func nhgWeights(nhg *telemetry.NetworkInstance_Afts_NextHopGroup) []uint64 {
+	weights := []uint64{}
+	for _, nh := range nhg.NextHop {
+		weights = append(weights, nh.GetWeight())
+	}
+	sort.Slice(weights, func(i, j int) bool { return weights[i] < weights[j] })
+	return weights
+}
+
+// ipv4EntryState describes the IPv4 entry of the prefix in the AFT,
+// and returns whether it references the next hop group with the gRIBI
+// ID wantNHG, or exists if wantNHG is zero.
+func ipv4EntryState(afts *telemetry.NetworkInstance_Afts, prefix string, wantNHG uint64) (string, bool) {
+	return entryState(afts, prefix, wantNHG, *deviations.GRIBINHGMatchByKey)
+}
+
+// entryState is ipv4EntryState matching next hop groups by key if byKey.
+func entryState(afts *telemetry.NetworkInstance_Afts, prefix string, wantNHG uint64, byKey bool) (string, bool) {
+	e := afts.GetIpv4Entry(prefix)
+	if e == nil || wantNHG == 0 {
+		return presenceState(e)
+	}
+	nhg := findNHG(nhgList(afts), wantNHG, byKey)
+	if nhg == nil {
+		return fmt.Sprintf("next-hop-group %d, and no next-hop-group with %s", e.GetNextHopGroup(), idLabel(wantNHG, byKey)), false
+	}
+	return refState(e, nhg.GetId())
+}
+
+// presenceState describes whether the IPv4 entry, which is nil if not
+// present, is present, and returns whether it is.
+func presenceState(e *telemetry.NetworkInstance_Afts_Ipv4Entry) (string, bool) {
+	if e == nil {
+		return "not present", false
+	}
+	return "present", true
+}
+
+// refState describes the next hop group the IPv4 entry, which is nil if
+// not present, references, and returns whether it is the one with the
+// AFT key nhgKey.
+func refState(e *telemetry.NetworkInstance_Afts_Ipv4Entry, nhgKey uint64) (string, bool) {
+	if e == nil {
+		return "not present", false
+	}
+	return fmt.Sprintf("next-hop-group %d", e.GetNextHopGroup()), e.GetNextHopGroup() == nhgKey
+}
+
+// nhgWeightsState describes the weights of the next hops of the next
+// hop group with the given gRIBI ID in the AFT, and returns
+// whether they are wantWeights in any order.
+func nhgWeightsState(afts *telemetry.NetworkInstance_Afts, id uint64, wantWeights []uint64) (string, bool) {
+	return weightsState(NextHopGroup(afts, id), wantWeights)
+}
+
+// weightsState describes the weights of the next hops of the next hop
+// group, which is nil if not present, and returns whether they are
+// wantWeights in any order.
+func weightsState(nhg *telemetry.NetworkInstance_Afts_NextHopGroup, wantWeights []uint64) (string, bool) {
+	if nhg == nil {
+		return "next-hop-group not present", false
+	}
+	got := nhgWeights(nhg)
+	want := append([]uint64{}, wantWeights...)
+	sort.Slice(want, func(i, j int) bool { return want[i] < want[j] })
+	return fmt.Sprintf("weights %v", got), cmp.Equal(want, got)
+}
+
+// await watches the AFT of the network instance until state returns
+// true, and reports a test error with the description last returned by
+// state if it does not within the timeout, writing an AFT snapshot.  If
+// opts.Freshness is set, it first checks that the AFT is not stale.
+func await(t testing.TB, dut *ondatra.DUTDevice, ni, what string, opts *Options, state func(*telemetry.NetworkInstance_Afts) (string, bool)) bool {
+	t.Helper()
+	if !fresh(t, dut, ni, opts, dut.Telemetry().NetworkInstance(ni).Afts()) {
+		return false
+	}
+	last := "no AFT telemetry"
+	_, ok := dut.Telemetry().NetworkInstance(ni).Afts().Watch(t, opts.timeout(), func(val *telemetry.QualifiedNetworkInstance_Afts) bool {
+		if !val.IsPresent() {
+			return false
+		}
+		var ok bool
+		last, ok = state(val.Val(t))
+		return ok
+	}).Await(t)
+	if !ok {
+		t.Errorf("Network instance %s %s within %v, last seen: %s", ni, what, opts.timeout(), last)
+		snapshotAFT(t, dut, ni)
+	}
+	return ok
+}
+
+// fresh checks that the path of the AFT of the network instance is not
+// stale if opts.Freshness is set, writing an AFT snapshot if it is, and
+// returns whether it is fresh or not checked.
+func fresh(t testing.TB, dut *ondatra.DUTDevice, ni string, opts *Options, path ygot.PathStruct) bool {
+	t.Helper()
+	if fo := opts.freshnessOpts(); fo != nil && !freshness.Check(t, dut, fo, path) {
+		snapshotAFT(t, dut, ni)
+		return false
+	}
+	return true
+}
+
+// awaitIPv4 is await watching only the IPv4 entry of the prefix rather
+// than the whole AFT.  state is called with the entry, or with nil
+// while it is not present.  If opts.Freshness is set, awaitIPv4 checks
+// that the entry is not stale once it is in the wanted state, since an
+// entry may not be present before, and an absent entry has no
+// timestamp to check.
+func awaitIPv4(t testing.TB, dut *ondatra.DUTDevice, ni, prefix, what string, opts *Options, state func(*telemetry.NetworkInstance_Afts_Ipv4Entry) (string, bool)) bool {
+	t.Helper()
+	path := dut.Telemetry().NetworkInstance(ni).Afts().Ipv4Entry(prefix)
+	last := "not present"
+	present := false
+	_, ok := path.Watch(t, opts.timeout(), func(val *telemetry.QualifiedNetworkInstance_Afts_Ipv4Entry) bool {
+		var e *telemetry.NetworkInstance_Afts_Ipv4Entry
+		if present = val.IsPresent(); present {
+			e = val.Val(t)
+		}
+		var ok bool
+		last, ok = state(e)
+		return ok
+	}).Await(t)
+	if !ok {
+		t.Errorf("Network instance %s %s within %v, last seen: %s", ni, what, opts.timeout(), last)
+		snapshotAFT(t, dut, ni)
+		return false
+	}
+	return !present || fresh(t, dut, ni, opts, path)
+}
+
+// nhgGNMIPath returns the gNMI path of the next hop group with the key
+// id with --deviation_gribi_nhg_match_by_key, or else of the next hop
+// groups.
+func nhgGNMIPath(ni string, id uint64, byKey bool) *gpb.Path {
+	p := aftGNMIPath(ni, &gpb.PathElem{Name: "next-hop-groups"})
+	if byKey {
+		p.Elem = append(p.Elem, &gpb.PathElem{Name: "next-hop-group", Key: map[string]string{"id": strconv.FormatUint(id, 10)}})
+	}
+	return p
+}
+
+// unsupportedQuery returns whether the error of a gNMI query is the DUT
+// rejecting the path, rather than another failure.  A path not present
+// yet is not unsupported, since the watch waits for it.
+func unsupportedQuery(err error) bool {
+	switch status.Code(err) {
+	case codes.Unimplemented, codes.InvalidArgument:
+		return true
+	}
+	return false
+}
+
+// probeNHG subscribes once to the next hop groups that awaitNHG
+// watches with updates_only, so that the DUT only validates the path
+// and sends no updates, and returns the error if the DUT rejects it,
+// or nil otherwise, leaving other failures to the watch.
+func probeNHG(t testing.TB, dut *ondatra.DUTDevice, ni string, id uint64, byKey bool) error {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	sub, err := dut.RawAPIs().GNMI().Default(t).Subscribe(ctx)
+	if err == nil {
+		err = sub.Send(&gpb.SubscribeRequest{
+			Request: &gpb.SubscribeRequest_Subscribe{Subscribe: &gpb.SubscriptionList{
+				Subscription: []*gpb.Subscription{{Path: nhgGNMIPath(ni, id, byKey)}},
+				Mode:         gpb.SubscriptionList_ONCE,
+				Encoding:     gpb.Encoding_PROTO,
+				UpdatesOnly:  true,
+			}},
+		})
+	}
+	for err == nil {
+		var resp *gpb.SubscribeResponse
+		if resp, err = sub.Recv(); resp.GetSyncResponse() {
+			return nil
+		}
+	}
+	if unsupportedQuery(err) {
+		return err
+	}
+	return nil
+}
+
+// awaitNHG is await watching only the next hop group with the given
+// gRIBI ID rather than the whole AFT: the group at that key with
+// --deviation_gribi_nhg_match_by_key, or else the next hop groups, for
+// the one with that programmed-id.  state is only called with that
+// group, once it is present.  If a subscription to the scoped path is
+// rejected as unimplemented or invalid, awaitNHG logs it and returns
+// true for unsupported, without reporting an error; any other failure
+// of the watch fails the test as usual.
+func awaitNHG(t testing.TB, dut *ondatra.DUTDevice, ni string, id uint64, what string, opts *Options, state func(*telemetry.NetworkInstance_Afts_NextHopGroup) (string, bool)) (ok, unsupported bool) {
+	t.Helper()
+	afts := dut.Telemetry().NetworkInstance(ni).Afts()
+	byKey := *deviations.GRIBINHGMatchByKey
+	if err := probeNHG(t, dut, ni, id, byKey); err != nil {
+		t.Logf("Network instance %s next-hop-group query unsupported, falling back to the whole AFT: %v", ni, err)
+		return false, true
+	}
+	var path ygot.PathStruct = afts.NextHopGroupAny()
+	if byKey {
+		path = afts.NextHopGroup(id)
+	}
+	if !fresh(t, dut, ni, opts, path) {
+		return false, false
+	}
+	last := "next-hop-group not present"
+	predicate := func(val *telemetry.QualifiedNetworkInstance_Afts_NextHopGroup) bool {
+		if !val.IsPresent() || gribiID(val.Val(t), byKey) != id {
+			return false
+		}
+		var ok bool
+		last, ok = state(val.Val(t))
+		return ok
+	}
+	if byKey {
+		_, ok = afts.NextHopGroup(id).Watch(t, opts.timeout(), predicate).Await(t)
+	} else {
+		_, ok = afts.NextHopGroupAny().Watch(t, opts.timeout(), predicate).Await(t)
+	}
+	if !ok {
+		t.Errorf("Network instance %s %s within %v, last seen: %s", ni, what, opts.timeout(), last)
+		snapshotAFT(t, dut, ni)
+	}
+	return ok, false
+}
+
+// IPv4Entry waits for the AFT of the network instance to have an IPv4
+// entry for the prefix referencing the next hop group with the
+// gRIBI ID wantNHG, or any next hop group if wantNHG is zero.  It
+// reports a test error with the entry last seen if it does not, and
+// returns whether it does.  Only the IPv4 entry is watched, and, if
+// wantNHG is not zero, first the next hop group as by NHGWeights to
+// find its key, unless the DUT rejects that query, in which case the
+// whole AFT is watched.
+func IPv4Entry(t testing.TB, dut *ondatra.DUTDevice, ni, prefix string, wantNHG uint64, opts *Options) bool {
+	t.Helper()
+	defer fptest.TimeStep(t, "verify AFT")()
+	if wantNHG == 0 {
+		return awaitIPv4(t, dut, ni, prefix, fmt.Sprintf("has no ipv4-entry %s", prefix), opts, presenceState)
+	}
+	what := fmt.Sprintf("has no ipv4-entry %s referencing next-hop-group %d", prefix, wantNHG)
+	deadline := time.Now().Add(opts.timeout())
+	var nhgKey uint64
+	ok, unsupported := awaitNHG(t, dut, ni, wantNHG, what, opts, func(nhg *telemetry.NetworkInstance_Afts_NextHopGroup) (string, bool) {
+		nhgKey = nhg.GetId()
+		return "next-hop-group present", true
+	})
+	if unsupported {
+		return await(t, dut, ni, what, opts, func(afts *telemetry.NetworkInstance_Afts) (string, bool) {
+			return ipv4EntryState(afts, prefix, wantNHG)
+		})
+	}
+	if !ok {
+		return false
+	}
+	return awaitIPv4(t, dut, ni, prefix, what, opts.until(deadline), func(e *telemetry.NetworkInstance_Afts_Ipv4Entry) (string, bool) {
+		return refState(e, nhgKey)
+	})
+}
+
+// NoIPv4Entry waits for the AFT of the network instance to have no IPv4
+// entry for the prefix.  It reports a test error with the entry last
+// seen if it still has one, and returns whether it has none.  Only the
+// IPv4 entry is watched, and opts.Freshness is not checked, since an
+// absent entry has no timestamp.
+func NoIPv4Entry(t testing.TB, dut *ondatra.DUTDevice, ni, prefix string, opts *Options) bool {
+	t.Helper()
+	defer fptest.TimeStep(t, "verify AFT")()
+	return awaitIPv4(t, dut, ni, prefix, fmt.Sprintf("still has ipv4-entry %s", prefix), opts, func(e *telemetry.NetworkInstance_Afts_Ipv4Entry) (string, bool) {
+		state, ok := presenceState(e)
+		return state, !ok
+	})
+}
+
+// NHGWeights waits for the next hops of the next hop group with the
+// gRIBI ID nhgID in the AFT of the network instance to have the
+// wanted weights, in any order.  It reports a test error with the
+// weights last seen if they do not, and returns whether they do.  Only
+// the next hop groups are watched, or just the one with
+// --deviation_gribi_nhg_match_by_key, unless the DUT rejects that
+// query, in which case the whole AFT is watched.
+func NHGWeights(t testing.TB, dut *ondatra.DUTDevice, ni string, nhgID uint64, wantWeights []uint64, opts *Options) bool {
+	t.Helper()
+	defer fptest.TimeStep(t, "verify AFT")()
+	what := fmt.Sprintf("next-hop-group %d has no next hop weights %v", nhgID, wantWeights)
+	ok, unsupported := awaitNHG(t, dut, ni, nhgID, what, opts, func(nhg *telemetry.NetworkInstance_Afts_NextHopGroup) (string, bool) {
+		return weightsState(nhg, wantWeights)
+	})
+	if !unsupported {
+		return ok
+	}
+	return await(t, dut, ni, what, opts, func(afts *telemetry.NetworkInstance_Afts) (string, bool) {
+		return nhgWeightsState(afts, nhgID, wantWeights)
+	})
+}
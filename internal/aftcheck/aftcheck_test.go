@@ -0,0 +1,222 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aftcheck
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/openconfig/ondatra/telemetry"
+	"github.com/openconfig/ygot/ygot"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newAFTs returns an AFT with next hop group 1 programmed as 10, with
+// next hops weighted 3 and 1, and an IPv4 entry for 203.0.113.0/24
+// referencing it.
+func newAFTs() *telemetry.NetworkInstance_Afts {
+	afts := &telemetry.NetworkInstance_Afts{}
+	nhg := afts.GetOrCreateNextHopGroup(1)
+	nhg.ProgrammedId = ygot.Uint64(10)
+	nhg.GetOrCreateNextHop(100).Weight = ygot.Uint64(3)
+	nhg.GetOrCreateNextHop(101).Weight = ygot.Uint64(1)
+	afts.GetOrCreateIpv4Entry("203.0.113.0/24").NextHopGroup = ygot.Uint64(1)
+	return afts
+}
+
+func TestNextHopWeights(t *testing.T) {
+	afts := newAFTs()
+	if diff := cmp.Diff([]uint64{1, 3}, NextHopWeights(afts, 10)); diff != "" {
+		t.Errorf("NextHopWeights(10) -want,+got:\n%s", diff)
+	}
+	if got := NextHopWeights(afts, 1); got != nil {
+		t.Errorf("NextHopWeights(1) got %v, want nil", got)
+	}
+}
+
+func TestIPv4EntryState(t *testing.T) {
+	cases := []struct {
+		desc    string
+		prefix  string
+		wantNHG uint64
+		wantOK  bool
+	}{
+		{"present", "203.0.113.0/24", 0, true},
+		{"references nhg", "203.0.113.0/24", 10, true},
+		{"references other nhg", "203.0.113.0/24", 11, false},
+		{"not present", "198.51.100.0/24", 0, false},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			state, ok := ipv4EntryState(newAFTs(), c.prefix, c.wantNHG)
+			if ok != c.wantOK {
+				t.Errorf("ipv4EntryState(%s, %d) got %t (%s), want %t", c.prefix, c.wantNHG, ok, state, c.wantOK)
+			}
+		})
+	}
+}
+
+func TestNHGWeightsState(t *testing.T) {
+	cases := []struct {
+		desc        string
+		id          uint64
+		wantWeights []uint64
+		wantOK      bool
+	}{
+		{"same order", 10, []uint64{1, 3}, true},
+		{"any order", 10, []uint64{3, 1}, true},
+		{"different weights", 10, []uint64{1, 1}, false},
+		{"missing next hop", 10, []uint64{3}, false},
+		{"not present", 11, []uint64{1, 3}, false},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			want := append([]uint64{}, c.wantWeights...)
+			state, ok := nhgWeightsState(newAFTs(), c.id, c.wantWeights)
+			if ok != c.wantOK {
+				t.Errorf("nhgWeightsState(%d, %v) got %t (%s), want %t", c.id, c.wantWeights, ok, state, c.wantOK)
+			}
+			if diff := cmp.Diff(want, c.wantWeights); diff != "" {
+				t.Errorf("nhgWeightsState() modified wantWeights -want,+got:\n%s", diff)
+			}
+		})
+	}
+}
+
+// newScaleAFTs returns an AFT with n next hop groups, programmed as 10
+// onwards, each with two next hops weighted 3 and 1, and ten IPv4
+// entries referencing each group.
+func newScaleAFTs(n int) *telemetry.NetworkInstance_Afts {
+	afts := &telemetry.NetworkInstance_Afts{}
+	for i := 0; i < n; i++ {
+		id := uint64(i + 1)
+		nhg := afts.GetOrCreateNextHopGroup(id)
+		nhg.ProgrammedId = ygot.Uint64(id + 9)
+		for j, w := range []uint64{3, 1} {
+			idx := uint64(2*i + j + 100)
+			nhg.GetOrCreateNextHop(idx).Weight = ygot.Uint64(w)
+			afts.GetOrCreateNextHop(idx).IpAddress = ygot.String(fmt.Sprintf("192.0.2.%d", idx%256))
+		}
+		for j := 0; j < 10; j++ {
+			k := 10*i + j
+			afts.GetOrCreateIpv4Entry(fmt.Sprintf("10.%d.%d.0/24", k/256, k%256)).NextHopGroup = ygot.Uint64(id)
+		}
+	}
+	return afts
+}
+
+// payloadSize returns the size of the RFC 7951 JSON of the structs, as
+// an estimate of the telemetry payload holding them.
+func payloadSize(tb testing.TB, structs ...ygot.GoStruct) int {
+	tb.Helper()
+	size := 0
+	for _, s := range structs {
+		js, err := ygot.EmitJSON(s, &ygot.EmitJSONConfig{Format: ygot.RFC7951, SkipValidation: true})
+		if err != nil {
+			tb.Fatalf("Cannot emit JSON: %v", err)
+		}
+		size += len(js)
+	}
+	return size
+}
+
+// nhgStructs returns the next hop groups of the AFT as structs.
+func nhgStructs(afts *telemetry.NetworkInstance_Afts) []ygot.GoStruct {
+	var structs []ygot.GoStruct
+	for _, nhg := range nhgList(afts) {
+		structs = append(structs, nhg)
+	}
+	return structs
+}
+
+func TestWeightsStateScoped(t *testing.T) {
+	afts := newScaleAFTs(100)
+	for _, id := range []uint64{10, 59, 109, 110} {
+		wantState, wantOK := nhgWeightsState(afts, id, []uint64{1, 3})
+		state, ok := weightsState(findNHG(nhgList(afts), id, false), []uint64{1, 3})
+		if state != wantState || ok != wantOK {
+			t.Errorf("weightsState(%d) got %t (%s), want %t (%s) as for the whole AFT", id, ok, state, wantOK, wantState)
+		}
+	}
+	whole, scoped := payloadSize(t, afts), payloadSize(t, nhgStructs(afts)...)
+	if scoped >= whole {
+		t.Errorf("Next hop groups payload got %d bytes, want less than the %d bytes of the whole AFT", scoped, whole)
+	}
+}
+
+func TestUnsupportedQuery(t *testing.T) {
+	for _, c := range []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{status.Error(codes.Unimplemented, "unsupported path"), true},
+		{status.Error(codes.InvalidArgument, "invalid path"), true},
+		{status.Error(codes.NotFound, "no next-hop-groups"), false},
+		{status.Error(codes.Unavailable, "connection reset"), false},
+		{status.Error(codes.DeadlineExceeded, "timed out"), false},
+		{errors.New("not a status"), false},
+	} {
+		if got := unsupportedQuery(c.err); got != c.want {
+			t.Errorf("unsupportedQuery(%v) got %t, want %t", c.err, got, c.want)
+		}
+	}
+}
+
+func BenchmarkNHGWeightsState(b *testing.B) {
+	afts := newScaleAFTs(1000)
+	want := []uint64{3, 1}
+	b.Run("whole AFT", func(b *testing.B) {
+		b.ReportMetric(float64(payloadSize(b, afts)), "payload-bytes")
+		for i := 0; i < b.N; i++ {
+			nhgWeightsState(afts, 509, want)
+		}
+	})
+	b.Run("next hop groups", func(b *testing.B) {
+		b.ReportMetric(float64(payloadSize(b, nhgStructs(afts)...)), "payload-bytes")
+		nhgs := nhgList(afts)
+		for i := 0; i < b.N; i++ {
+			weightsState(findNHG(nhgs, 509, false), want)
+		}
+	})
+	b.Run("next hop group by key", func(b *testing.B) {
+		nhg := afts.GetNextHopGroup(500)
+		b.ReportMetric(float64(payloadSize(b, nhg)), "payload-bytes")
+		for i := 0; i < b.N; i++ {
+			weightsState(nhg, want)
+		}
+	})
+}
+
+func TestRefState(t *testing.T) {
+	e := newAFTs().GetIpv4Entry("203.0.113.0/24")
+	for _, c := range []struct {
+		desc   string
+		e      *telemetry.NetworkInstance_Afts_Ipv4Entry
+		nhgKey uint64
+		want   bool
+	}{
+		{"references nhg", e, 1, true},
+		{"references other nhg", e, 2, false},
+		{"not present", nil, 1, false},
+	} {
+		if state, ok := refState(c.e, c.nhgKey); ok != c.want {
+			t.Errorf("%s: refState(%d) got %t (%s), want %t", c.desc, c.nhgKey, ok, state, c.want)
+		}
+	}
+}
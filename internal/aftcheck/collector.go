@@ -0,0 +1,186 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aftcheck
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/onchange"
+	"github.com/openconfig/ondatra"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// Collector is an ON_CHANGE gNMI subscription to the prefixes of the
+// IPv4 entries of the AFT of a network instance, which records when the
+// entries of the registered prefixes first appear.  It lets a test
+// verify the AFT while it is still programming it, rather than
+// afterwards.  Only the registered prefixes are kept, so its memory
+// grows with them and not with the AFT or its churn.
+type Collector struct {
+	sub *onchange.Stream
+	// done is closed once the subscription ended.
+	done <-chan struct{}
+
+	mu sync.Mutex
+	// first maps each registered prefix to when its IPv4 entry was first
+	// received, or the zero time if it was not yet.
+	first   map[string]time.Time
+	missing int
+}
+
+func newCollector(prefixes []string) *Collector {
+	c := &Collector{first: make(map[string]time.Time, len(prefixes))}
+	for _, p := range prefixes {
+		c.first[p] = time.Time{}
+	}
+	c.missing = len(c.first)
+	return c
+}
+
+// handle records the registered prefixes in the notification received
+// at the given time.  Callers must hold c.mu.
+func (c *Collector) handle(n *gpb.Notification, received time.Time) {
+	prefix := n.GetPrefix().GetElem()
+	for _, u := range n.GetUpdate() {
+		elems := append(append([]*gpb.PathElem{}, prefix...), u.GetPath().GetElem()...)
+		i := elemIndex(elems, "ipv4-entry")
+		if i < 0 {
+			continue
+		}
+		pfx := elems[i].GetKey()["prefix"]
+		if first, ok := c.first[pfx]; ok && first.IsZero() {
+			c.first[pfx] = received
+			c.missing--
+		}
+	}
+}
+
+// Collect opens an ON_CHANGE subscription to the prefixes of the IPv4
+// entries of the AFT of the network instance through the raw gNMI
+// client of the DUT, registering the given prefixes, and returns once
+// the DUT has sent the current AFT, or fails the test if it does not
+// within the timeout in opts.  Close the collector when done.
+func Collect(t testing.TB, dut *ondatra.DUTDevice, ni string, prefixes []string, opts *Options) *Collector {
+	t.Helper()
+	c := newCollector(prefixes)
+	c.sub = onchange.Open(t, dut.RawAPIs().GNMI().Default(t), "the AFT prefixes", opts.timeout(),
+		func(n *gpb.Notification, received time.Time, _ bool) error {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.handle(n, received)
+			return nil
+		}, aftGNMIPath(ni,
+			&gpb.PathElem{Name: "ipv4-unicast"},
+			&gpb.PathElem{Name: "ipv4-entry", Key: map[string]string{"prefix": "*"}},
+			&gpb.PathElem{Name: "state"},
+			&gpb.PathElem{Name: "prefix"},
+		))
+	c.done = c.sub.Done()
+	return c
+}
+
+// Close ends the subscription.
+func (c *Collector) Close() {
+	c.sub.Close()
+}
+
+// Err returns the error that ended the subscription before it was
+// closed, if any.
+func (c *Collector) Err() error {
+	return c.sub.Err()
+}
+
+// Seen returns when the IPv4 entry of the registered prefix was first
+// received, and whether it was.
+func (c *Collector) Seen(prefix string) (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	first := c.first[prefix]
+	return first, !first.IsZero()
+}
+
+// Missing returns the registered prefixes whose IPv4 entries were not
+// received yet, in lexical order.
+func (c *Collector) Missing() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var missing []string
+	for p, first := range c.first {
+		if first.IsZero() {
+			missing = append(missing, p)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// Await waits up to the timeout for the IPv4 entries of all the
+// registered prefixes to be received, returning as soon as they are or
+// the subscription ends, and returns the prefixes still missing.
+func (c *Collector) Await(timeout time.Duration) []string {
+	deadline := time.Now().Add(timeout)
+	for {
+		c.mu.Lock()
+		missing := c.missing
+		c.mu.Unlock()
+		if missing == 0 || time.Now().After(deadline) {
+			break
+		}
+		select {
+		case <-c.done:
+			return c.Missing()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	return c.Missing()
+}
+
+// Latencies returns the time from when each of the prefixes was
+// installed to when its IPv4 entry was first received, for the
+// registered prefixes that were received.
+func (c *Collector) Latencies(installed map[string]time.Time) map[string]time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	latencies := make(map[string]time.Duration)
+	for p, at := range installed {
+		if first := c.first[p]; !first.IsZero() {
+			latencies[p] = first.Sub(at)
+		}
+	}
+	return latencies
+}
+
+// Percentile returns the pth percentile, from 0 to 100, of the
+// durations by the nearest-rank method, or zero if there are none.
+func Percentile(ds []time.Duration, p float64) time.Duration {
+	if len(ds) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration{}, ds...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
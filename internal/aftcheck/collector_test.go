@@ -0,0 +1,110 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aftcheck
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// prefixNotification returns a notification of the prefix leaf of the
+// IPv4 entries of the prefixes.
+func prefixNotification(prefixes ...string) *gpb.Notification {
+	n := &gpb.Notification{Prefix: aftPath(&gpb.PathElem{Name: "ipv4-unicast"})}
+	for _, p := range prefixes {
+		n.Update = append(n.Update, &gpb.Update{
+			Path: &gpb.Path{Elem: []*gpb.PathElem{
+				{Name: "ipv4-entry", Key: map[string]string{"prefix": p}},
+				{Name: "state"},
+				{Name: "prefix"},
+			}},
+			Val: &gpb.TypedValue{Value: &gpb.TypedValue_StringVal{StringVal: p}},
+		})
+	}
+	return n
+}
+
+func TestCollectorHandle(t *testing.T) {
+	c := newCollector([]string{"198.18.0.0/32", "198.18.0.1/32", "198.18.0.2/32"})
+	t0 := time.Now()
+	t1 := t0.Add(time.Second)
+	c.handle(prefixNotification("198.18.0.0/32", "203.0.113.0/24"), t0)
+	c.handle(prefixNotification("198.18.0.0/32", "198.18.0.1/32"), t1)
+	c.handle(deleteNotification(), t1)
+
+	if got, ok := c.Seen("198.18.0.0/32"); !ok || !got.Equal(t0) {
+		t.Errorf("Seen(198.18.0.0/32) got %v, %t, want the first time %v", got, ok, t0)
+	}
+	if got, ok := c.Seen("198.18.0.1/32"); !ok || !got.Equal(t1) {
+		t.Errorf("Seen(198.18.0.1/32) got %v, %t, want %v", got, ok, t1)
+	}
+	if _, ok := c.Seen("203.0.113.0/24"); ok {
+		t.Errorf("Seen(203.0.113.0/24) got true for an unregistered prefix, want false")
+	}
+	if got := len(c.first); got != 3 {
+		t.Errorf("Collector holds %d prefixes, want only the 3 registered", got)
+	}
+	if diff := cmp.Diff([]string{"198.18.0.2/32"}, c.Missing()); diff != "" {
+		t.Errorf("Missing() -want,+got:\n%s", diff)
+	}
+	// The subscription is not running, so Await only waits for the
+	// timeout.
+	c.done = make(chan struct{})
+	if diff := cmp.Diff([]string{"198.18.0.2/32"}, c.Await(time.Millisecond)); diff != "" {
+		t.Errorf("Await() -want,+got:\n%s", diff)
+	}
+
+	got := c.Latencies(map[string]time.Time{
+		"198.18.0.0/32": t0.Add(-time.Millisecond),
+		"198.18.0.1/32": t0,
+		"198.18.0.2/32": t0,
+	})
+	want := map[string]time.Duration{
+		"198.18.0.0/32": time.Millisecond,
+		"198.18.0.1/32": time.Second,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Latencies() -want,+got:\n%s", diff)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var ds []time.Duration
+	for i := 10; i >= 1; i-- {
+		ds = append(ds, time.Duration(i)*time.Millisecond)
+	}
+	cases := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{0, time.Millisecond},
+		{50, 5 * time.Millisecond},
+		{90, 9 * time.Millisecond},
+		{99, 10 * time.Millisecond},
+		{100, 10 * time.Millisecond},
+	}
+	for _, c := range cases {
+		if got := Percentile(ds, c.p); got != c.want {
+			t.Errorf("Percentile(%v) got %v, want %v", c.p, got, c.want)
+		}
+	}
+	if got := Percentile(nil, 50); got != 0 {
+		t.Errorf("Percentile(nil, 50) got %v, want 0", got)
+	}
+}
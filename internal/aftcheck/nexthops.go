@@ -0,0 +1,149 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aftcheck
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+	"github.com/openconfig/ondatra/telemetry/networkinstance"
+)
+
+// NextHop is a next hop of a next hop group in the AFT.
+type NextHop struct {
+	// Index is the index of the next hop in the AFT.
+	Index uint64
+	// Weight is the weight of the next hop in the next hop group.
+	Weight uint64
+	// IPAddress is the IPv4 or IPv6 address of the next hop, or empty
+	// if the next hop has none or it is not reported.
+	IPAddress string
+}
+
+// Weights returns the weights of the next hops, in the same order.
+func Weights(nhs []*NextHop) []uint64 {
+	weights := []uint64{}
+	for _, nh := range nhs {
+		weights = append(weights, nh.Weight)
+	}
+	return weights
+}
+
+// gribiID returns the ID the gRIBI client gave the next hop group, i.e.
+// its programmed-id, or its key if byKey.
+func gribiID(nhg *telemetry.NetworkInstance_Afts_NextHopGroup, byKey bool) uint64 {
+	if byKey {
+		return nhg.GetId()
+	}
+	return nhg.GetProgrammedId()
+}
+
+// findNHG returns the next hop group with the given gRIBI ID, matched by
+// programmed-id, or by key if byKey, or nil if there is none.
+func findNHG(nhgs []*telemetry.NetworkInstance_Afts_NextHopGroup, id uint64, byKey bool) *telemetry.NetworkInstance_Afts_NextHopGroup {
+	for _, nhg := range nhgs {
+		if gribiID(nhg, byKey) == id {
+			return nhg
+		}
+	}
+	return nil
+}
+
+// nextHops returns the next hops of the next hop group in index order,
+// with the IP addresses of the AFT next hops nhs, by index.
+func nextHops(nhg *telemetry.NetworkInstance_Afts_NextHopGroup, nhs map[uint64]*telemetry.NetworkInstance_Afts_NextHop) []*NextHop {
+	var got []*NextHop
+	for idx, nh := range nhg.NextHop {
+		got = append(got, &NextHop{Index: idx, Weight: nh.GetWeight(), IPAddress: nhs[idx].GetIpAddress()})
+	}
+	sort.Slice(got, func(i, j int) bool { return got[i].Index < got[j].Index })
+	return got
+}
+
+// NextHops returns the next hops of the next hop group with the given
+// gRIBI ID in the AFT of the network instance, in index order, or nil
+// if there is no such group.  The next hop group is matched by
+// programmed-id, or by key with --deviation_gribi_nhg_match_by_key.
+// Only the next hop groups and the next hops of the group are fetched,
+// rather than the whole AFT.
+func NextHops(t testing.TB, dut *ondatra.DUTDevice, ni string, id uint64) []*NextHop {
+	t.Helper()
+	afts := dut.Telemetry().NetworkInstance(ni).Afts()
+	var nhg *telemetry.NetworkInstance_Afts_NextHopGroup
+	if *deviations.GRIBINHGMatchByKey {
+		if q := afts.NextHopGroup(id).Lookup(t); q.IsPresent() {
+			nhg = q.Val(t)
+		}
+	} else {
+		var nhgs []*telemetry.NetworkInstance_Afts_NextHopGroup
+		for _, q := range afts.NextHopGroupAny().Lookup(t) {
+			if q.IsPresent() {
+				nhgs = append(nhgs, q.Val(t))
+			}
+		}
+		nhg = findNHG(nhgs, id, false)
+	}
+	if nhg == nil {
+		return nil
+	}
+	return resolveNextHops(t, afts, nhg)
+}
+
+// EntryNextHops returns the next hops of the next hop group referenced
+// by the IPv4 or IPv6 entry of the prefix in the AFT of the network
+// instance, in index order, or nil if there is no such entry.  IPv6
+// prefixes are looked up in the IPv6 entries.
+func EntryNextHops(t testing.TB, dut *ondatra.DUTDevice, ni, prefix string) []*NextHop {
+	t.Helper()
+	afts := dut.Telemetry().NetworkInstance(ni).Afts()
+	var q *telemetry.QualifiedUint64
+	if isIPv6(prefix) {
+		q = afts.Ipv6Entry(prefix).NextHopGroup().Lookup(t)
+	} else {
+		q = afts.Ipv4Entry(prefix).NextHopGroup().Lookup(t)
+	}
+	if !q.IsPresent() {
+		return nil
+	}
+	nhgq := afts.NextHopGroup(q.Val(t)).Lookup(t)
+	if !nhgq.IsPresent() {
+		return nil
+	}
+	return resolveNextHops(t, afts, nhgq.Val(t))
+}
+
+// resolveNextHops fetches the AFT next hops of the next hop group to
+// return its next hops with their IP addresses.
+func resolveNextHops(t testing.TB, afts *networkinstance.NetworkInstance_AftsPath, nhg *telemetry.NetworkInstance_Afts_NextHopGroup) []*NextHop {
+	t.Helper()
+	nhs := make(map[uint64]*telemetry.NetworkInstance_Afts_NextHop)
+	for idx := range nhg.NextHop {
+		if q := afts.NextHop(idx).Lookup(t); q.IsPresent() {
+			nhs[idx] = q.Val(t)
+		}
+	}
+	return nextHops(nhg, nhs)
+}
+
+// isIPv6 reports whether the prefix or address is an IPv6 one.
+func isIPv6(prefix string) bool {
+	ip := net.ParseIP(strings.SplitN(prefix, "/", 2)[0])
+	return ip != nil && ip.To4() == nil
+}
@@ -0,0 +1,128 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aftcheck
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/openconfig/ondatra/telemetry"
+	"github.com/openconfig/ygot/ygot"
+)
+
+func TestFindNHG(t *testing.T) {
+	afts := newAFTs()
+	other := afts.GetOrCreateNextHopGroup(10)
+	other.ProgrammedId = ygot.Uint64(20)
+	nhgs := nhgList(afts)
+
+	cases := []struct {
+		desc    string
+		id      uint64
+		byKey   bool
+		wantKey uint64
+	}{
+		{"programmed-id", 10, false, 1},
+		{"other programmed-id", 20, false, 10},
+		{"programmed-id not present", 1, false, 0},
+		{"key", 10, true, 10},
+		{"other key", 1, true, 1},
+		{"key not present", 20, true, 0},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			nhg := findNHG(nhgs, c.id, c.byKey)
+			if c.wantKey == 0 {
+				if nhg != nil {
+					t.Errorf("findNHG(%d, %t) got next-hop-group %d, want nil", c.id, c.byKey, nhg.GetId())
+				}
+				return
+			}
+			if got := nhg.GetId(); got != c.wantKey {
+				t.Errorf("findNHG(%d, %t) got next-hop-group %d, want %d", c.id, c.byKey, got, c.wantKey)
+			}
+		})
+	}
+}
+
+func TestEntryStateByKey(t *testing.T) {
+	cases := []struct {
+		desc    string
+		wantNHG uint64
+		byKey   bool
+		wantOK  bool
+	}{
+		{"programmed-id", 10, false, true},
+		{"key as programmed-id", 1, false, false},
+		{"key", 1, true, true},
+		{"programmed-id as key", 10, true, false},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			state, ok := entryState(newAFTs(), "203.0.113.0/24", c.wantNHG, c.byKey)
+			if ok != c.wantOK {
+				t.Errorf("entryState(%d, %t) got %t (%s), want %t", c.wantNHG, c.byKey, ok, state, c.wantOK)
+			}
+		})
+	}
+}
+
+func TestNextHops(t *testing.T) {
+	afts := newAFTs()
+	afts.GetOrCreateNextHop(100).IpAddress = ygot.String("192.0.2.2")
+	afts.GetOrCreateNextHop(101).IpAddress = ygot.String("2001:db8::2")
+
+	got := nextHops(afts.GetNextHopGroup(1), afts.NextHop)
+	want := []*NextHop{
+		{Index: 100, Weight: 3, IPAddress: "192.0.2.2"},
+		{Index: 101, Weight: 1, IPAddress: "2001:db8::2"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("nextHops() -want,+got:\n%s", diff)
+	}
+	if diff := cmp.Diff([]uint64{3, 1}, Weights(got)); diff != "" {
+		t.Errorf("Weights() -want,+got:\n%s", diff)
+	}
+}
+
+func TestNextHopsUnresolved(t *testing.T) {
+	afts := newAFTs()
+	got := nextHops(afts.GetNextHopGroup(1), map[uint64]*telemetry.NetworkInstance_Afts_NextHop{})
+	want := []*NextHop{
+		{Index: 100, Weight: 3},
+		{Index: 101, Weight: 1},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("nextHops() -want,+got:\n%s", diff)
+	}
+}
+
+func TestIsIPv6(t *testing.T) {
+	cases := []struct {
+		prefix string
+		want   bool
+	}{
+		{"203.0.113.0/24", false},
+		{"192.0.2.1", false},
+		{"2001:db8::/32", true},
+		{"2001:db8::1", true},
+		{"not-an-ip", false},
+	}
+	for _, c := range cases {
+		if got := isIPv6(c.prefix); got != c.want {
+			t.Errorf("isIPv6(%q) got %t, want %t", c.prefix, got, c.want)
+		}
+	}
+}
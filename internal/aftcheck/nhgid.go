@@ -0,0 +1,194 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aftcheck
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+)
+
+// ProgrammedNHG is a next hop group a test programmed through gRIBI in
+// a network instance, with the IPv4 entries it programmed referencing
+// it.
+type ProgrammedNHG struct {
+	NetworkInstance string
+	// ID is the gRIBI ID of the next hop group.
+	ID       uint64
+	Prefixes []string
+	// NextHopAddresses are the IP addresses of the next hops of the
+	// group in any order, or nil not to check them.
+	NextHopAddresses []string
+}
+
+// idLabel describes the gRIBI ID of a next hop group as matched in the
+// AFT, by programmed-id, or by key if byKey.
+func idLabel(id uint64, byKey bool) string {
+	if byKey {
+		return fmt.Sprintf("key %d", id)
+	}
+	return fmt.Sprintf("programmed-id %d", id)
+}
+
+// nextHopAddresses returns the IP addresses of the next hops in order.
+func nextHopAddresses(nhs []*NextHop) []string {
+	addrs := []string{}
+	for _, nh := range nhs {
+		addrs = append(addrs, nh.IPAddress)
+	}
+	return sorted(addrs)
+}
+
+// sorted returns a sorted copy of the strings.
+func sorted(s []string) []string {
+	c := append([]string{}, s...)
+	sort.Strings(c)
+	return c
+}
+
+// programmedNHGErrors checks the AFT of a network instance against the
+// next hop groups programmed in it.  For each of them, exactly one AFT
+// next hop group must have its gRIBI ID, matched by programmed-id or by
+// key if byKey, so that its key is unique, all of its IPv4 entries must
+// reference that key, and its next hops must have the wanted addresses,
+// which are only checked once its entries reference it.  It returns the
+// errors found, and the sorted next hop addresses of the group of each
+// gRIBI ID found.
+func programmedNHGErrors(afts *telemetry.NetworkInstance_Afts, nhgs []*ProgrammedNHG, byKey bool) ([]string, map[uint64][]string) {
+	var errs []string
+	resolved := make(map[uint64][]string)
+	all := nhgList(afts)
+	sort.Slice(all, func(i, j int) bool { return all[i].GetId() < all[j].GetId() })
+	for _, p := range nhgs {
+		var keys []uint64
+		var nhg *telemetry.NetworkInstance_Afts_NextHopGroup
+		for _, g := range all {
+			if gribiID(g, byKey) == p.ID {
+				keys = append(keys, g.GetId())
+				nhg = g
+			}
+		}
+		switch len(keys) {
+		case 0:
+			errs = append(errs, fmt.Sprintf("no next-hop-group with %s", idLabel(p.ID, byKey)))
+			continue
+		case 1:
+		default:
+			errs = append(errs, fmt.Sprintf("next-hop-groups %v all have %s, want one", keys, idLabel(p.ID, byKey)))
+			continue
+		}
+		key := nhg.GetId()
+
+		mapped := true
+		for _, prefix := range p.Prefixes {
+			e := afts.GetIpv4Entry(prefix)
+			switch {
+			case e == nil:
+				errs = append(errs, fmt.Sprintf("ipv4-entry %s not present", prefix))
+			case e.GetNextHopGroup() != key:
+				errs = append(errs, fmt.Sprintf("ipv4-entry %s references next-hop-group %d, want %d with %s", prefix, e.GetNextHopGroup(), key, idLabel(p.ID, byKey)))
+				mapped = false
+			}
+		}
+
+		got := nextHopAddresses(nextHops(nhg, afts.NextHop))
+		resolved[p.ID] = got
+		// The next hops of a group its entries do not reference are
+		// those of another ID, which is already reported above.
+		if mapped && p.NextHopAddresses != nil {
+			if want := sorted(p.NextHopAddresses); !cmp.Equal(want, got) {
+				errs = append(errs, fmt.Sprintf("next-hop-group %d with %s has next hops %v, want %v", key, idLabel(p.ID, byKey), got, want))
+			}
+		}
+	}
+	return errs, resolved
+}
+
+// sharedIDErrors checks the next hop groups programmed with the same
+// gRIBI ID in several network instances, given the next hop addresses
+// they resolve to in the AFT of each network instance.  Groups
+// programmed with different next hops must resolve to different next
+// hops, or else they collided into a single group across the network
+// instances.
+func sharedIDErrors(nhgs []*ProgrammedNHG, resolved map[string]map[uint64][]string) []string {
+	var errs []string
+	for i, a := range nhgs {
+		for _, b := range nhgs[i+1:] {
+			if a.ID != b.ID || a.NetworkInstance == b.NetworkInstance || a.NextHopAddresses == nil || b.NextHopAddresses == nil {
+				continue
+			}
+			if cmp.Equal(sorted(a.NextHopAddresses), sorted(b.NextHopAddresses)) {
+				continue
+			}
+			gotA, okA := resolved[a.NetworkInstance][a.ID]
+			gotB, okB := resolved[b.NetworkInstance][b.ID]
+			if okA && okB && cmp.Equal(gotA, gotB) {
+				errs = append(errs, fmt.Sprintf("next-hop-groups with gRIBI ID %d in network instances %s and %s both have next hops %v", a.ID, a.NetworkInstance, b.NetworkInstance, gotA))
+			}
+		}
+	}
+	return errs
+}
+
+// ProgrammedNHGs waits for the AFT of the network instance of each next
+// hop group to report it consistently with the gRIBI ID it was
+// programmed with: exactly one next hop group has the gRIBI ID as
+// programmed-id, or as key with --deviation_gribi_nhg_match_by_key, all
+// of its IPv4 entries reference the key of that group, and its next
+// hops have the wanted addresses.  A
+// gRIBI ID programmed in several network instances with different next
+// hops must also resolve to different next hops in each of them.  It
+// reports a test error for each inconsistency last seen, and returns
+// whether there are none.
+func ProgrammedNHGs(t testing.TB, dut *ondatra.DUTDevice, nhgs []*ProgrammedNHG, opts *Options) bool {
+	t.Helper()
+	defer fptest.TimeStep(t, "verify AFT")()
+	var nis []string
+	byNI := make(map[string][]*ProgrammedNHG)
+	for _, p := range nhgs {
+		if _, ok := byNI[p.NetworkInstance]; !ok {
+			nis = append(nis, p.NetworkInstance)
+		}
+		byNI[p.NetworkInstance] = append(byNI[p.NetworkInstance], p)
+	}
+
+	ok := true
+	resolved := make(map[string]map[uint64][]string)
+	for _, ni := range nis {
+		ni := ni
+		ok = await(t, dut, ni, "has no consistent next-hop-groups", opts, func(afts *telemetry.NetworkInstance_Afts) (string, bool) {
+			var errs []string
+			errs, resolved[ni] = programmedNHGErrors(afts, byNI[ni], *deviations.GRIBINHGMatchByKey)
+			return strings.Join(errs, "; "), len(errs) == 0
+		}) && ok
+	}
+	if errs := sharedIDErrors(nhgs, resolved); len(errs) > 0 {
+		for _, err := range errs {
+			t.Error(err)
+		}
+		for _, ni := range nis {
+			snapshotAFT(t, dut, ni)
+		}
+		ok = false
+	}
+	return ok
+}
@@ -0,0 +1,172 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aftcheck
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openconfig/ondatra/telemetry"
+	"github.com/openconfig/ygot/ygot"
+)
+
+// newProgrammedAFTs returns an AFT with next hop group 1 of
+// programmed-id 10 and next hop group 2 of programmed-id 20, each with
+// a next hop and an IPv4 entry referencing it.
+func newProgrammedAFTs() *telemetry.NetworkInstance_Afts {
+	afts := &telemetry.NetworkInstance_Afts{}
+	for _, g := range []struct {
+		key, id, nh uint64
+		addr        string
+		prefix      string
+	}{
+		{1, 10, 100, "192.0.2.2", "203.0.113.0/24"},
+		{2, 20, 200, "192.0.2.6", "198.51.100.0/24"},
+	} {
+		nhg := afts.GetOrCreateNextHopGroup(g.key)
+		nhg.ProgrammedId = ygot.Uint64(g.id)
+		nhg.GetOrCreateNextHop(g.nh).Weight = ygot.Uint64(1)
+		afts.GetOrCreateNextHop(g.nh).IpAddress = ygot.String(g.addr)
+		afts.GetOrCreateIpv4Entry(g.prefix).NextHopGroup = ygot.Uint64(g.key)
+	}
+	return afts
+}
+
+func TestProgrammedNHGErrors(t *testing.T) {
+	nhgs := []*ProgrammedNHG{
+		{ID: 10, Prefixes: []string{"203.0.113.0/24"}, NextHopAddresses: []string{"192.0.2.2"}},
+		{ID: 20, Prefixes: []string{"198.51.100.0/24"}},
+	}
+	cases := []struct {
+		desc   string
+		modify func(*telemetry.NetworkInstance_Afts)
+		byKey  bool
+		want   []string // a substring of each error
+	}{{
+		desc:   "consistent",
+		modify: func(*telemetry.NetworkInstance_Afts) {},
+	}, {
+		desc:   "by key",
+		modify: func(*telemetry.NetworkInstance_Afts) {},
+		byKey:  true,
+		want:   []string{"no next-hop-group with key 10", "no next-hop-group with key 20"},
+	}, {
+		desc: "programmed-id missing",
+		modify: func(afts *telemetry.NetworkInstance_Afts) {
+			afts.GetNextHopGroup(2).ProgrammedId = nil
+		},
+		want: []string{"no next-hop-group with programmed-id 20"},
+	}, {
+		desc: "programmed-id duplicated",
+		modify: func(afts *telemetry.NetworkInstance_Afts) {
+			afts.GetOrCreateNextHopGroup(3).ProgrammedId = ygot.Uint64(10)
+		},
+		want: []string{"next-hop-groups [1 3] all have programmed-id 10"},
+	}, {
+		desc: "programmed-ids swapped",
+		modify: func(afts *telemetry.NetworkInstance_Afts) {
+			afts.GetNextHopGroup(1).ProgrammedId = ygot.Uint64(20)
+			afts.GetNextHopGroup(2).ProgrammedId = ygot.Uint64(10)
+		},
+		want: []string{
+			"ipv4-entry 203.0.113.0/24 references next-hop-group 1, want 2 with programmed-id 10",
+			"ipv4-entry 198.51.100.0/24 references next-hop-group 2, want 1 with programmed-id 20",
+		},
+	}, {
+		desc: "entry references other key",
+		modify: func(afts *telemetry.NetworkInstance_Afts) {
+			afts.GetIpv4Entry("203.0.113.0/24").NextHopGroup = ygot.Uint64(2)
+		},
+		want: []string{"ipv4-entry 203.0.113.0/24 references next-hop-group 2, want 1 with programmed-id 10"},
+	}, {
+		desc: "entry missing",
+		modify: func(afts *telemetry.NetworkInstance_Afts) {
+			afts.DeleteIpv4Entry("198.51.100.0/24")
+		},
+		want: []string{"ipv4-entry 198.51.100.0/24 not present"},
+	}, {
+		desc: "wrong next hops",
+		modify: func(afts *telemetry.NetworkInstance_Afts) {
+			afts.GetNextHop(100).IpAddress = ygot.String("192.0.2.6")
+		},
+		want: []string{"next-hop-group 1 with programmed-id 10 has next hops [192.0.2.6], want [192.0.2.2]"},
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			afts := newProgrammedAFTs()
+			c.modify(afts)
+			got, _ := programmedNHGErrors(afts, nhgs, c.byKey)
+			if len(got) != len(c.want) {
+				t.Fatalf("programmedNHGErrors got %q, want %d errors", got, len(c.want))
+			}
+			for i, want := range c.want {
+				if !strings.Contains(got[i], want) {
+					t.Errorf("programmedNHGErrors error %d got %q, want %q", i, got[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestSharedIDErrors(t *testing.T) {
+	nhgs := []*ProgrammedNHG{
+		{NetworkInstance: "DEFAULT", ID: 10, NextHopAddresses: []string{"192.0.2.2"}},
+		{NetworkInstance: "VRF-A", ID: 10, NextHopAddresses: []string{"192.0.2.6"}},
+	}
+	cases := []struct {
+		desc     string
+		nhgs     []*ProgrammedNHG
+		resolved map[string]map[uint64][]string
+		wantErrs int
+	}{{
+		desc: "distinct",
+		nhgs: nhgs,
+		resolved: map[string]map[uint64][]string{
+			"DEFAULT": {10: {"192.0.2.2"}},
+			"VRF-A":   {10: {"192.0.2.6"}},
+		},
+	}, {
+		desc: "collided",
+		nhgs: nhgs,
+		resolved: map[string]map[uint64][]string{
+			"DEFAULT": {10: {"192.0.2.2"}},
+			"VRF-A":   {10: {"192.0.2.2"}},
+		},
+		wantErrs: 1,
+	}, {
+		desc: "not resolved",
+		nhgs: nhgs,
+		resolved: map[string]map[uint64][]string{
+			"DEFAULT": {10: {"192.0.2.2"}},
+		},
+	}, {
+		desc: "same next hops programmed",
+		nhgs: []*ProgrammedNHG{
+			{NetworkInstance: "DEFAULT", ID: 10, NextHopAddresses: []string{"192.0.2.2"}},
+			{NetworkInstance: "VRF-A", ID: 10, NextHopAddresses: []string{"192.0.2.2"}},
+		},
+		resolved: map[string]map[uint64][]string{
+			"DEFAULT": {10: {"192.0.2.2"}},
+			"VRF-A":   {10: {"192.0.2.2"}},
+		},
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			if got := sharedIDErrors(c.nhgs, c.resolved); len(got) != c.wantErrs {
+				t.Errorf("sharedIDErrors got %q, want %d errors", got, c.wantErrs)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aftcheck
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/gnmi/value"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ygot/ygot"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+const (
+	// snapshotSuffix is the suffix of the AFT snapshot outputs.
+	snapshotSuffix = ".aft.json"
+	// maxSnapshotBytes caps the size of an AFT snapshot output, since
+	// the AFT of a scale test may hold a great many entries.
+	maxSnapshotBytes = 64 << 20
+	// truncationMarker ends an AFT snapshot cut at maxSnapshotBytes.
+	truncationMarker = "\n... AFT snapshot truncated to %d of %d bytes\n"
+	// snapshotTimeout is how long to wait for the AFT snapshot.
+	snapshotTimeout = 2 * time.Minute
+)
+
+// snapshotUpdate is an update of an AFT snapshot.
+type snapshotUpdate struct {
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// snapshotFile is the content of an AFT snapshot output.
+type snapshotFile struct {
+	Test            string            `json:"test"`
+	NetworkInstance string            `json:"network_instance"`
+	Time            time.Time         `json:"time"`
+	Updates         []*snapshotUpdate `json:"updates"`
+}
+
+// snapshotUpdates returns the updates of the notifications with their
+// full paths and decoded values.  JSON values are kept as they are.
+func snapshotUpdates(notifs []*gpb.Notification) []*snapshotUpdate {
+	var updates []*snapshotUpdate
+	for _, n := range notifs {
+		for _, u := range n.GetUpdate() {
+			p := &gpb.Path{Elem: append(append([]*gpb.PathElem{}, n.GetPrefix().GetElem()...), u.GetPath().GetElem()...)}
+			path, err := ygot.PathToString(p)
+			if err != nil {
+				path = p.String()
+			}
+			su := &snapshotUpdate{Path: path}
+			switch v := u.GetVal(); {
+			case v.GetJsonIetfVal() != nil:
+				su.Value = jsonValue(v.GetJsonIetfVal())
+			case v.GetJsonVal() != nil:
+				su.Value = jsonValue(v.GetJsonVal())
+			default:
+				if s, err := value.ToScalar(v); err == nil {
+					su.Value = s
+				} else {
+					su.Value = v.String()
+				}
+			}
+			updates = append(updates, su)
+		}
+	}
+	return updates
+}
+
+// jsonValue returns the JSON value to be kept as it is, or as a string
+// if it is not valid JSON.
+func jsonValue(b []byte) interface{} {
+	if !json.Valid(b) {
+		return string(b)
+	}
+	return json.RawMessage(b)
+}
+
+// truncate returns the content cut to max bytes, followed by the
+// truncation marker, if it is longer.
+func truncate(content []byte, max int) []byte {
+	if len(content) <= max {
+		return content
+	}
+	return append(content[:max:max], fmt.Sprintf(truncationMarker, max, len(content))...)
+}
+
+// snapshotted records the AFTs already written by test and network
+// instance, so that a test with several failing checks writes each AFT
+// only once.
+var snapshotted sync.Map
+
+// snapshotAFT gets the whole AFT of the network instance in one gNMI Get
+// and writes it to the outputs directory, named after the test, so that
+// a failed check can be triaged after the testbed is torn down.  It is
+// called by the checks of this package when they fail, at most once per
+// test and network instance.  Errors are logged rather than reported,
+// since the test has already failed.
+func snapshotAFT(t testing.TB, dut *ondatra.DUTDevice, ni string) {
+	t.Helper()
+	if _, done := snapshotted.LoadOrStore(t.Name()+"\x00"+ni, true); done {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), snapshotTimeout)
+	defer cancel()
+	resp, err := dut.RawAPIs().GNMI().Default(t).Get(ctx, &gpb.GetRequest{
+		Path: []*gpb.Path{{Elem: []*gpb.PathElem{
+			{Name: "network-instances"},
+			{Name: "network-instance", Key: map[string]string{"name": ni}},
+			{Name: "afts"},
+		}}},
+		Type:     gpb.GetRequest_STATE,
+		Encoding: gpb.Encoding_JSON_IETF,
+	})
+	if err != nil {
+		t.Logf("Cannot get the AFT snapshot of network instance %s: %v", ni, err)
+		return
+	}
+	content, err := json.MarshalIndent(&snapshotFile{
+		Test:            t.Name(),
+		NetworkInstance: ni,
+		Time:            time.Now(),
+		Updates:         snapshotUpdates(resp.GetNotification()),
+	}, "", "  ")
+	if err != nil {
+		t.Logf("Cannot marshal the AFT snapshot of network instance %s: %v", ni, err)
+		return
+	}
+	name, err := fptest.WriteOutputFile(t.Name(), snapshotSuffix, string(truncate(content, maxSnapshotBytes)))
+	switch {
+	case err != nil:
+		t.Logf("Cannot write the AFT snapshot of network instance %s: %v", ni, err)
+	case name == "":
+		t.Logf("AFT snapshot of network instance %s discarded without -outputs_dir", ni)
+	default:
+		t.Logf("AFT snapshot of network instance %s written to %s", ni, name)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aftcheck
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+func TestTruncate(t *testing.T) {
+	cases := []struct {
+		desc    string
+		content string
+		max     int
+		want    string
+	}{
+		{"shorter", "abc", 4, "abc"},
+		{"equal", "abcd", 4, "abcd"},
+		{"longer", "abcdef", 4, "abcd\n... AFT snapshot truncated to 4 of 6 bytes\n"},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			if got := string(truncate([]byte(c.content), c.max)); got != c.want {
+				t.Errorf("truncate(%q, %d) got %q, want %q", c.content, c.max, got, c.want)
+			}
+		})
+	}
+}
+
+func TestSnapshotUpdates(t *testing.T) {
+	notifs := []*gpb.Notification{{
+		Prefix: &gpb.Path{Elem: []*gpb.PathElem{
+			{Name: "network-instances"},
+			{Name: "network-instance", Key: map[string]string{"name": "DEFAULT"}},
+			{Name: "afts"},
+		}},
+		Update: []*gpb.Update{{
+			Path: &gpb.Path{Elem: []*gpb.PathElem{{Name: "ipv4-unicast"}}},
+			Val:  &gpb.TypedValue{Value: &gpb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`{"ipv4-entry": []}`)}},
+		}, {
+			Path: &gpb.Path{Elem: []*gpb.PathElem{
+				{Name: "next-hop-groups"},
+				{Name: "next-hop-group", Key: map[string]string{"id": "1"}},
+				{Name: "state"},
+				{Name: "programmed-id"},
+			}},
+			Val: &gpb.TypedValue{Value: &gpb.TypedValue_UintVal{UintVal: 10}},
+		}, {
+			Path: &gpb.Path{Elem: []*gpb.PathElem{{Name: "broken"}}},
+			Val:  &gpb.TypedValue{Value: &gpb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`{`)}},
+		}},
+	}}
+	b, err := json.Marshal(snapshotUpdates(notifs))
+	if err != nil {
+		t.Fatalf("json.Marshal got error %v, want nil", err)
+	}
+	var got []map[string]interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("json.Unmarshal got error %v, want nil", err)
+	}
+	want := []map[string]interface{}{{
+		"path":  "/network-instances/network-instance[name=DEFAULT]/afts/ipv4-unicast",
+		"value": map[string]interface{}{"ipv4-entry": []interface{}{}},
+	}, {
+		"path":  "/network-instances/network-instance[name=DEFAULT]/afts/next-hop-groups/next-hop-group[id=1]/state/programmed-id",
+		"value": float64(10),
+	}, {
+		"path":  "/network-instances/network-instance[name=DEFAULT]/afts/broken",
+		"value": "{",
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("snapshotUpdates -want,+got:\n%s", diff)
+	}
+}
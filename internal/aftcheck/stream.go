@@ -0,0 +1,301 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aftcheck
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/onchange"
+	"github.com/openconfig/ondatra"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// EventKind is the kind of change of an IPv4 entry streamed by the AFT.
+type EventKind int
+
+const (
+	// Created is an IPv4 entry appearing in the AFT.
+	Created EventKind = iota
+	// Deleted is an IPv4 entry being removed from the AFT.
+	Deleted
+)
+
+func (k EventKind) String() string {
+	if k == Deleted {
+		return "deletion"
+	}
+	return "creation"
+}
+
+// Event is a change of an IPv4 entry streamed by the AFT.
+type Event struct {
+	Prefix string
+	Kind   EventKind
+	// NHG is the gRIBI ID of the next hop group the created entry
+	// references, or zero if it is not known.
+	NHG uint64
+	// Timestamp is the timestamp of the notification, and Received
+	// when the test received it.
+	Timestamp time.Time
+	Received  time.Time
+
+	// nhgKey is the key of the next hop group the entry references.
+	nhgKey uint64
+}
+
+// Stream is an ON_CHANGE gNMI subscription to the AFT of a network
+// instance, which records the creation and deletion of its IPv4 entries.
+type Stream struct {
+	dut   *ondatra.DUTDevice
+	ni    string
+	byKey bool
+	sub   *onchange.Stream
+
+	mu     sync.Mutex
+	events []*Event
+	// present is the last event of each prefix present in the AFT.
+	present map[string]*Event
+	// gribiIDs are the gRIBI IDs of the next hop groups, by key.
+	gribiIDs map[uint64]uint64
+}
+
+func newStream(ni string, byKey bool) *Stream {
+	return &Stream{
+		ni:       ni,
+		byKey:    byKey,
+		present:  make(map[string]*Event),
+		gribiIDs: make(map[uint64]uint64),
+	}
+}
+
+// elemIndex returns the index of the first path element with the given
+// name, or -1.
+func elemIndex(elems []*gpb.PathElem, name string) int {
+	for i, e := range elems {
+		if e.GetName() == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// leafName returns the name of the leaf of the path below the element at
+// index i, e.g. "state/next-hop-group".
+func leafName(elems []*gpb.PathElem, i int) string {
+	var name string
+	for _, e := range elems[i+1:] {
+		if name != "" {
+			name += "/"
+		}
+		name += e.GetName()
+	}
+	return name
+}
+
+// handle records the changes of the IPv4 entries in the notification,
+// received at the given time.  Callers must hold s.mu.
+func (s *Stream) handle(n *gpb.Notification, received time.Time) {
+	ts := time.Unix(0, n.GetTimestamp())
+	prefix := n.GetPrefix().GetElem()
+	for _, u := range n.GetUpdate() {
+		elems := append(append([]*gpb.PathElem{}, prefix...), u.GetPath().GetElem()...)
+		if i := elemIndex(elems, "next-hop-group"); i >= 0 && leafName(elems, i) == "state/programmed-id" {
+			if key, err := strconv.ParseUint(elems[i].GetKey()["id"], 10, 64); err == nil {
+				s.gribiIDs[key] = u.GetVal().GetUintVal()
+			}
+			continue
+		}
+		i := elemIndex(elems, "ipv4-entry")
+		if i < 0 {
+			continue
+		}
+		pfx := elems[i].GetKey()["prefix"]
+		if pfx == "" {
+			continue
+		}
+		e, ok := s.present[pfx]
+		if !ok {
+			e = &Event{Prefix: pfx, Kind: Created, Timestamp: ts, Received: received}
+			s.present[pfx] = e
+			s.events = append(s.events, e)
+		}
+		if leafName(elems, i) == "state/next-hop-group" {
+			e.nhgKey = u.GetVal().GetUintVal()
+		}
+	}
+	for _, d := range n.GetDelete() {
+		elems := append(append([]*gpb.PathElem{}, prefix...), d.GetElem()...)
+		i := elemIndex(elems, "ipv4-entry")
+		if i < 0 {
+			continue
+		}
+		switch leafName(elems, i) {
+		case "", "state", "state/prefix":
+		default:
+			continue
+		}
+		pfx := elems[i].GetKey()["prefix"]
+		if _, ok := s.present[pfx]; !ok {
+			continue
+		}
+		delete(s.present, pfx)
+		s.events = append(s.events, &Event{Prefix: pfx, Kind: Deleted, Timestamp: ts, Received: received})
+	}
+}
+
+// gribiID returns the gRIBI ID of the next hop group with the given key,
+// or zero if it is not known.
+func (s *Stream) gribiID(key uint64) uint64 {
+	if s.byKey {
+		return key
+	}
+	return s.gribiIDs[key]
+}
+
+// aftGNMIPath returns the gNMI path of the AFT of the network instance,
+// followed by the elements elems.
+func aftGNMIPath(ni string, elems ...*gpb.PathElem) *gpb.Path {
+	return &gpb.Path{Elem: append([]*gpb.PathElem{
+		{Name: "network-instances"},
+		{Name: "network-instance", Key: map[string]string{"name": ni}},
+		{Name: "afts"},
+	}, elems...)}
+}
+
+// Subscribe opens an ON_CHANGE subscription to the AFT of the network
+// instance through the raw gNMI client of the DUT, and returns once the
+// DUT has sent the current AFT, so that the changes made afterwards are
+// recorded as events.  The next hop groups of the IPv4 entries are
+// matched by programmed-id, or by key with
+// --deviation_gribi_nhg_match_by_key.  Close the stream when done.
+func Subscribe(t testing.TB, dut *ondatra.DUTDevice, ni string) *Stream {
+	t.Helper()
+	s := newStream(ni, *deviations.GRIBINHGMatchByKey)
+	s.dut = dut
+	s.sub = onchange.Open(t, dut.RawAPIs().GNMI().Default(t), "the AFT", DefaultTimeout,
+		func(n *gpb.Notification, received time.Time, _ bool) error {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.handle(n, received)
+			return nil
+		}, aftGNMIPath(ni))
+	return s
+}
+
+// Close ends the subscription.
+func (s *Stream) Close() {
+	s.sub.Close()
+}
+
+// Err returns the error that ended the subscription before it was
+// closed, if any.
+func (s *Stream) Err() error {
+	return s.sub.Err()
+}
+
+// Events returns the events recorded so far, in the order received.
+func (s *Stream) Events() []*Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var events []*Event
+	for _, e := range s.events {
+		c := *e
+		if c.nhgKey != 0 {
+			c.NHG = s.gribiID(c.nhgKey)
+		}
+		events = append(events, &c)
+	}
+	return events
+}
+
+// WantEvent is an event expected after a gRIBI operation was ACKed.
+type WantEvent struct {
+	Kind EventKind
+	// NHG is the gRIBI ID of the next hop group a created entry must
+	// reference, or zero for any.
+	NHG uint64
+	// ACK is when the gRIBI client received the ACK of the operation.
+	ACK time.Time
+}
+
+// matchEvents matches the wanted events of the prefix in order against
+// the events, and returns the errors found, and whether a later call
+// with more events may still match them.  An event may be received up
+// to maxDelay after the ACK of its operation.
+func matchEvents(events []*Event, prefix string, want []*WantEvent, maxDelay time.Duration) (errs []error, retry bool) {
+	next := 0
+	for _, w := range want {
+		var got *Event
+		for ; next < len(events); next++ {
+			if e := events[next]; e.Prefix == prefix && e.Kind == w.Kind {
+				got = e
+				next++
+				break
+			}
+		}
+		if got == nil {
+			if w.Kind == Deleted {
+				errs = append(errs, fmt.Errorf("ipv4-entry %s got no delete notification after the delete was ACKed", prefix))
+			} else {
+				errs = append(errs, fmt.Errorf("ipv4-entry %s got no %s notification after the add was ACKed", prefix, w.Kind))
+			}
+			return errs, true
+		}
+		if delay := got.Received.Sub(w.ACK); delay > maxDelay {
+			errs = append(errs, fmt.Errorf("ipv4-entry %s %s notification arrived %v after the gRIBI ACK, want within %v", prefix, w.Kind, delay, maxDelay))
+		}
+		if w.Kind == Created && w.NHG != 0 && got.NHG != w.NHG {
+			errs = append(errs, fmt.Errorf("ipv4-entry %s %s notification got next-hop-group %d, want %d", prefix, w.Kind, got.NHG, w.NHG))
+		}
+	}
+	return errs, false
+}
+
+// Expect waits for the events of the IPv4 entry of the prefix to match
+// the wanted events in order, each received within the timeout in opts
+// of the ACK of its operation.  It reports a test error for each
+// mismatch, distinguishing missing delete notifications, writing an AFT
+// snapshot, and returns whether they all match.
+func (s *Stream) Expect(t testing.TB, prefix string, want []*WantEvent, opts *Options) bool {
+	t.Helper()
+	defer fptest.TimeStep(t, "verify AFT")()
+	var deadline time.Time
+	for _, w := range want {
+		if d := w.ACK.Add(opts.timeout()); d.After(deadline) {
+			deadline = d
+		}
+	}
+	for {
+		errs, retry := matchEvents(s.Events(), prefix, want, opts.timeout())
+		if retry && time.Now().Before(deadline) {
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		for _, err := range errs {
+			t.Errorf("Network instance %s %v", s.ni, err)
+		}
+		if len(errs) > 0 {
+			snapshotAFT(t, s.dut, s.ni)
+		}
+		return len(errs) == 0
+	}
+}
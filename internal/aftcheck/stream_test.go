@@ -0,0 +1,174 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aftcheck
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+const streamPrefix = "203.0.113.0/24"
+
+// aftPath returns the path of the elements below the AFT of the DEFAULT
+// network instance.
+func aftPath(elems ...*gpb.PathElem) *gpb.Path {
+	return &gpb.Path{Elem: append([]*gpb.PathElem{
+		{Name: "network-instances"},
+		{Name: "network-instance", Key: map[string]string{"name": "DEFAULT"}},
+		{Name: "afts"},
+	}, elems...)}
+}
+
+func entryElem() *gpb.PathElem {
+	return &gpb.PathElem{Name: "ipv4-entry", Key: map[string]string{"prefix": streamPrefix}}
+}
+
+func uintUpdate(p *gpb.Path, v uint64) *gpb.Update {
+	return &gpb.Update{Path: p, Val: &gpb.TypedValue{Value: &gpb.TypedValue_UintVal{UintVal: v}}}
+}
+
+// entryNotification returns a notification creating the IPv4 entry
+// referencing the next hop group with key nhgKey.
+func entryNotification(nhgKey uint64) *gpb.Notification {
+	return &gpb.Notification{
+		Prefix: aftPath(&gpb.PathElem{Name: "ipv4-unicast"}, entryElem()),
+		Update: []*gpb.Update{
+			{Path: &gpb.Path{Elem: []*gpb.PathElem{{Name: "state"}, {Name: "prefix"}}}, Val: &gpb.TypedValue{Value: &gpb.TypedValue_StringVal{StringVal: streamPrefix}}},
+			uintUpdate(&gpb.Path{Elem: []*gpb.PathElem{{Name: "state"}, {Name: "next-hop-group"}}}, nhgKey),
+		},
+	}
+}
+
+// nhgNotification returns a notification of the next hop group with the
+// given key and programmed-id.
+func nhgNotification(key, programmed uint64) *gpb.Notification {
+	return &gpb.Notification{Update: []*gpb.Update{uintUpdate(aftPath(
+		&gpb.PathElem{Name: "next-hop-groups"},
+		&gpb.PathElem{Name: "next-hop-group", Key: map[string]string{"id": "1"}},
+		&gpb.PathElem{Name: "state"},
+		&gpb.PathElem{Name: "programmed-id"},
+	), programmed)}}
+}
+
+func deleteNotification() *gpb.Notification {
+	return &gpb.Notification{Delete: []*gpb.Path{aftPath(&gpb.PathElem{Name: "ipv4-unicast"}, entryElem())}}
+}
+
+func TestStreamHandle(t *testing.T) {
+	for _, byKey := range []bool{false, true} {
+		s := newStream("DEFAULT", byKey)
+		now := time.Now()
+		// The next hop group may be reported after the entry.
+		s.handle(entryNotification(1), now)
+		s.handle(nhgNotification(1, 10), now)
+		s.handle(entryNotification(1), now) // Still present, not a new event.
+		s.handle(deleteNotification(), now)
+		s.handle(deleteNotification(), now) // Already deleted.
+		s.handle(entryNotification(1), now)
+
+		events := s.Events()
+		var kinds []string
+		for _, e := range events {
+			kinds = append(kinds, e.Kind.String())
+		}
+		if got, want := strings.Join(kinds, ","), "creation,deletion,creation"; got != want {
+			t.Errorf("Events(byKey=%t) got kinds %s, want %s", byKey, got, want)
+		}
+		wantNHG := uint64(10)
+		if byKey {
+			wantNHG = 1
+		}
+		for _, i := range []int{0, 2} {
+			if i < len(events) && events[i].NHG != wantNHG {
+				t.Errorf("Events(byKey=%t)[%d] got next-hop-group %d, want %d", byKey, i, events[i].NHG, wantNHG)
+			}
+		}
+	}
+}
+
+func TestStreamIgnoresLeafDelete(t *testing.T) {
+	s := newStream("DEFAULT", false)
+	s.handle(entryNotification(1), time.Now())
+	s.handle(&gpb.Notification{Delete: []*gpb.Path{aftPath(
+		&gpb.PathElem{Name: "ipv4-unicast"}, entryElem(), &gpb.PathElem{Name: "state"}, &gpb.PathElem{Name: "metadata"},
+	)}}, time.Now())
+	if got := len(s.Events()); got != 1 {
+		t.Errorf("Events() got %d events, want 1", got)
+	}
+}
+
+func TestMatchEvents(t *testing.T) {
+	ack := time.Unix(1000, 0)
+	at := func(kind EventKind, nhg uint64, after time.Duration) *Event {
+		return &Event{Prefix: streamPrefix, Kind: kind, NHG: nhg, Received: ack.Add(after)}
+	}
+	want := []*WantEvent{
+		{Kind: Created, NHG: 10, ACK: ack},
+		{Kind: Deleted, ACK: ack},
+		{Kind: Created, NHG: 10, ACK: ack},
+	}
+	cases := []struct {
+		desc      string
+		events    []*Event
+		wantErr   string
+		wantRetry bool
+	}{{
+		desc:   "in order",
+		events: []*Event{at(Created, 10, time.Second), at(Deleted, 0, time.Second), at(Created, 10, time.Second)},
+	}, {
+		desc: "before the ACK",
+		events: []*Event{
+			at(Created, 10, -time.Second), at(Deleted, 0, -time.Second), at(Created, 10, -time.Second),
+		},
+	}, {
+		desc:      "missing delete",
+		events:    []*Event{at(Created, 10, time.Second)},
+		wantErr:   "no delete notification",
+		wantRetry: true,
+	}, {
+		desc:      "missing re-creation",
+		events:    []*Event{at(Created, 10, time.Second), at(Deleted, 0, time.Second)},
+		wantErr:   "no creation notification",
+		wantRetry: true,
+	}, {
+		desc:    "wrong next hop group",
+		events:  []*Event{at(Created, 11, time.Second), at(Deleted, 0, time.Second), at(Created, 10, time.Second)},
+		wantErr: "got next-hop-group 11, want 10",
+	}, {
+		desc:    "late",
+		events:  []*Event{at(Created, 10, time.Second), at(Deleted, 0, time.Minute), at(Created, 10, time.Minute)},
+		wantErr: "deletion notification arrived 1m0s after the gRIBI ACK",
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			errs, retry := matchEvents(c.events, streamPrefix, want, 30*time.Second)
+			if retry != c.wantRetry {
+				t.Errorf("matchEvents() got retry %t, want %t", retry, c.wantRetry)
+			}
+			if c.wantErr == "" {
+				if len(errs) != 0 {
+					t.Errorf("matchEvents() got errors %v, want none", errs)
+				}
+				return
+			}
+			if len(errs) == 0 || !strings.Contains(errs[0].Error(), c.wantErr) {
+				t.Errorf("matchEvents() got errors %v, want one containing %q", errs, c.wantErr)
+			}
+		})
+	}
+}
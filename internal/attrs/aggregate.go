@@ -0,0 +1,148 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package attrs
+
+import (
+	"testing"
+
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/setrequest"
+	"github.com/openconfig/ondatra"
+	oc "github.com/openconfig/ondatra/telemetry"
+	"github.com/openconfig/ygot/ygot"
+)
+
+// Aggregate describes an aggregate interface of the DUT and its member
+// ports.  The attributes of the aggregate, e.g. its addresses, are
+// those of the Attributes it is configured with.
+type Aggregate struct {
+	ID       string // Name of the aggregate interface.
+	LagType  oc.E_IfAggregate_AggregationType
+	MinLinks uint16 // Only set if non-zero.
+	// LACPInterval is the LACP period of a LACP aggregate, only set if
+	// not UNSET.
+	LACPInterval oc.E_Lacp_LacpPeriodType
+	Members      []string // Names of the member DUT ports.
+}
+
+// NewAggregateInterface returns a new *oc.Interface for the aggregate
+// configured with these attributes.
+func (a *Attributes) NewAggregateInterface(g *Aggregate) *oc.Interface {
+	intf := a.NewInterface(g.ID)
+	intf.Type = oc.IETFInterfaces_InterfaceType_ieee8023adLag
+	if a.MAC == "" {
+		intf.Ethernet = nil
+	}
+	agg := intf.GetOrCreateAggregation()
+	agg.LagType = g.LagType
+	if g.MinLinks > 0 {
+		agg.MinLinks = ygot.Uint16(g.MinLinks)
+	}
+	return intf
+}
+
+// NewMemberInterface returns a new *oc.Interface for the member port of
+// the aggregate with the given name.
+func (g *Aggregate) NewMemberInterface(name string) *oc.Interface {
+	intf := &oc.Interface{
+		Name: ygot.String(name),
+		Type: oc.IETFInterfaces_InterfaceType_ethernetCsmacd,
+	}
+	if *deviations.InterfaceEnabled {
+		intf.Enabled = ygot.Bool(true)
+	}
+	intf.GetOrCreateEthernet().AggregateId = ygot.String(g.ID)
+	return intf
+}
+
+// NewLACPInterface returns the ACTIVE LACP config of a LACP aggregate,
+// or nil for a static aggregate, which has no LACP config.
+func (g *Aggregate) NewLACPInterface() *oc.Lacp_Interface {
+	if g.LagType != oc.IfAggregate_AggregationType_LACP {
+		return nil
+	}
+	return &oc.Lacp_Interface{
+		Name:     ygot.String(g.ID),
+		LacpMode: oc.Lacp_LacpActivityType_ACTIVE,
+		Interval: g.LACPInterval,
+	}
+}
+
+// AddAggregateToDevice adds the aggregate configured with these
+// attributes, its LACP config if any and its members to d.
+func (a *Attributes) AddAggregateToDevice(d *oc.Device, g *Aggregate) {
+	if l := g.NewLACPInterface(); l != nil {
+		d.GetOrCreateLacp().AppendInterface(l)
+	}
+	d.AppendInterface(a.NewAggregateInterface(g))
+	for _, name := range g.Members {
+		d.AppendInterface(g.NewMemberInterface(name))
+	}
+}
+
+// ConfigureAggregate configures the aggregate with these attributes,
+// its LACP config if any and its members on the DUT, replacing their
+// existing config in a single SetRequest.  If
+// deviations.AggregateAtomicUpdate is set, they are first configured
+// together in a single update of the device root, since the DUT rejects
+// an aggregate without members being configured on its own.
+func (a *Attributes) ConfigureAggregate(t testing.TB, dut *ondatra.DUTDevice, g *Aggregate) {
+	t.Helper()
+	d := &oc.Device{}
+	a.AddAggregateToDevice(d, g)
+	c := dut.Config()
+	if *deviations.AggregateAtomicUpdate {
+		fptest.LogYgot(t, dut.String()+" to Update()", c, d)
+		c.Update(t, d)
+	}
+
+	b := setrequest.NewBatch()
+	if lacp := d.GetLacp().GetInterface(g.ID); lacp != nil {
+		fptest.LogYgot(t, "LACP "+g.ID, c.Lacp().Interface(g.ID), lacp)
+		b.Replace("LACP "+g.ID, c.Lacp().Interface(g.ID), lacp)
+	}
+	for _, name := range append([]string{g.ID}, g.Members...) {
+		i := d.GetInterface(name)
+		fptest.LogYgot(t, name, c.Interface(name), i)
+		b.Replace(name, c.Interface(name), i)
+	}
+	if err := b.Set(t, dut); err != nil {
+		t.Fatalf("Cannot configure aggregate %s: %v", g.ID, err)
+	}
+}
+
+// AddLAGToATE adds a new interface over a LAG of the given ports to an
+// ATETopology with these attributes.  LACP is run over the LAG if lacp
+// is set, and otherwise it is a static LAG.
+func (a *Attributes) AddLAGToATE(top *ondatra.ATETopology, aps []*ondatra.Port, peer *Attributes, lacp bool) *ondatra.Interface {
+	lag := top.AddLAG(a.Name + ".LAG").WithPorts(aps...)
+	lag.LACP().WithEnabled(lacp)
+	i := top.AddInterface(a.Name).WithLAG(lag)
+	if a.MTU > 0 {
+		i.Ethernet().WithMTU(a.MTU)
+	}
+	if a.IPv4 != "" {
+		i.IPv4().
+			WithAddress(a.IPv4CIDR()).
+			WithDefaultGateway(peer.IPv4)
+	}
+	if a.IPv6 != "" {
+		i.IPv6().
+			WithAddress(a.IPv6CIDR()).
+			WithDefaultGateway(peer.IPv6)
+	}
+	return i
+}
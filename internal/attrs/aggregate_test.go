@@ -0,0 +1,108 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package attrs
+
+import (
+	"testing"
+
+	oc "github.com/openconfig/ondatra/telemetry"
+)
+
+func TestAddAggregateToDevice(t *testing.T) {
+	a := &Attributes{
+		Desc:    "agg",
+		IPv4:    "192.0.2.5",
+		IPv4Len: 30,
+	}
+	for _, tc := range []struct {
+		desc     string
+		agg      *Aggregate
+		wantMode oc.E_Lacp_LacpActivityType
+		wantMin  uint16
+	}{{
+		desc: "LACP",
+		agg: &Aggregate{
+			ID:           "Port-Channel1",
+			LagType:      oc.IfAggregate_AggregationType_LACP,
+			MinLinks:     2,
+			LACPInterval: oc.Lacp_LacpPeriodType_FAST,
+			Members:      []string{"Ethernet1", "Ethernet2"},
+		},
+		wantMode: oc.Lacp_LacpActivityType_ACTIVE,
+		wantMin:  2,
+	}, {
+		desc: "STATIC",
+		agg: &Aggregate{
+			ID:      "Port-Channel1",
+			LagType: oc.IfAggregate_AggregationType_STATIC,
+			Members: []string{"Ethernet1", "Ethernet2"},
+		},
+		wantMode: oc.Lacp_LacpActivityType_UNSET,
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			d := &oc.Device{}
+			a.AddAggregateToDevice(d, tc.agg)
+
+			// GetLacpMode returns ACTIVE by default, so check the
+			// field itself.
+			l := d.GetLacp().GetInterface(tc.agg.ID)
+			switch {
+			case tc.wantMode == oc.Lacp_LacpActivityType_UNSET:
+				if l != nil {
+					t.Errorf("static aggregate has LACP config %v, want none", l)
+				}
+			case l == nil:
+				t.Errorf("LACP aggregate has no LACP config")
+			default:
+				if got := l.LacpMode; got != tc.wantMode {
+					t.Errorf("lacp-mode got %v, want %v", got, tc.wantMode)
+				}
+				if got, want := l.GetInterval(), tc.agg.LACPInterval; got != want {
+					t.Errorf("interval got %v, want %v", got, want)
+				}
+			}
+
+			agg := d.GetInterface(tc.agg.ID)
+			if got, want := agg.GetType(), oc.IETFInterfaces_InterfaceType_ieee8023adLag; got != want {
+				t.Errorf("aggregate type got %v, want %v", got, want)
+			}
+			if got := agg.GetAggregation().GetLagType(); got != tc.agg.LagType {
+				t.Errorf("lag-type got %v, want %v", got, tc.agg.LagType)
+			}
+			if got := agg.GetAggregation().GetMinLinks(); got != tc.wantMin {
+				t.Errorf("min-links got %d, want %d", got, tc.wantMin)
+			}
+			if agg.GetSubinterface(0).GetIpv4().GetAddress(a.IPv4) == nil {
+				t.Errorf("aggregate has no address %s", a.IPv4)
+			}
+			if agg.Ethernet != nil {
+				t.Errorf("aggregate ethernet got %v, want nil", agg.Ethernet)
+			}
+
+			for _, name := range tc.agg.Members {
+				m := d.GetInterface(name)
+				if m == nil {
+					t.Fatalf("member %s missing", name)
+				}
+				if got := m.GetEthernet().GetAggregateId(); got != tc.agg.ID {
+					t.Errorf("member %s aggregate-id got %q, want %q", name, got, tc.agg.ID)
+				}
+				if m.Subinterface != nil {
+					t.Errorf("member %s has subinterfaces %v", name, m.Subinterface)
+				}
+			}
+		})
+	}
+}
@@ -21,9 +21,14 @@ package attrs
 
 import (
 	"fmt"
+	"sort"
+	"testing"
+	"time"
 
 	"github.com/open-traffic-generator/snappi/gosnappi"
 	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/setrequest"
 	"github.com/openconfig/ondatra"
 	oc "github.com/openconfig/ondatra/telemetry"
 	"github.com/openconfig/ygot/ygot"
@@ -42,6 +47,10 @@ type Attributes struct {
 	IPv4Len uint8  // Prefix length for IPv4.
 	IPv6Len uint8  // Prefix length for IPv6.
 	MTU     uint16
+	// VLANID is the 802.1Q VLAN ID the interface is tagged with.  If
+	// set, the DUT subinterface is the one of that index, see
+	// SubinterfaceIndex.
+	VLANID uint16
 }
 
 // IPv4CIDR constructs the IPv4 CIDR notation with the given prefix
@@ -73,7 +82,31 @@ func (a *Attributes) ConfigInterface(intf *oc.Interface) *oc.Interface {
 		e.MacAddress = ygot.String(a.MAC)
 	}
 
-	s := intf.GetOrCreateSubinterface(0)
+	a.ConfigSubinterface(intf.GetOrCreateSubinterface(a.SubinterfaceIndex()))
+	return intf
+}
+
+// SubinterfaceIndex returns the index of the DUT subinterface with these
+// attributes, which is the VLAN ID for a tagged interface and 0 for an
+// untagged one.
+func (a *Attributes) SubinterfaceIndex() uint32 {
+	return uint32(a.VLANID)
+}
+
+// ConfigSubinterface configures an OpenConfig subinterface with the
+// addresses of these attributes, and with a single-tagged VLAN match if
+// VLANID is set.
+func (a *Attributes) ConfigSubinterface(s *oc.Interface_Subinterface) *oc.Interface_Subinterface {
+	if a.VLANID > 0 {
+		if *deviations.InterfaceEnabled {
+			s.Enabled = ygot.Bool(true)
+		}
+		if *deviations.DeprecatedVlanID {
+			s.GetOrCreateVlan().VlanId = oc.UnionUint16(a.VLANID)
+		} else {
+			s.GetOrCreateVlan().GetOrCreateMatch().GetOrCreateSingleTagged().VlanId = ygot.Uint16(a.VLANID)
+		}
+	}
 	if a.IPv4 != "" {
 		s4 := s.GetOrCreateIpv4()
 		if *deviations.InterfaceEnabled && !*deviations.IPv4MissingEnabled {
@@ -101,7 +134,7 @@ func (a *Attributes) ConfigInterface(intf *oc.Interface) *oc.Interface {
 			a6.PrefixLength = ygot.Uint8(a.IPv6Len)
 		}
 	}
-	return intf
+	return s
 }
 
 // NewInterface returns a new *oc.Interface configured with these attributes
@@ -109,12 +142,48 @@ func (a *Attributes) NewInterface(name string) *oc.Interface {
 	return a.ConfigInterface(&oc.Interface{Name: ygot.String(name)})
 }
 
+// NewSubinterface returns a new *oc.Interface_Subinterface configured
+// with these attributes, to be added to a trunk port alongside
+// subinterfaces of other VLANs.
+func (a *Attributes) NewSubinterface() *oc.Interface_Subinterface {
+	return a.ConfigSubinterface(&oc.Interface_Subinterface{Index: ygot.Uint32(a.SubinterfaceIndex())})
+}
+
+// ConfigureDUTPorts replaces the config of the interfaces of the DUT
+// ports with the given IDs with their attributes, all in a single
+// SetRequest so that they are committed together.  A failure names the
+// port whose interface the DUT rejected.
+func ConfigureDUTPorts(t testing.TB, dut *ondatra.DUTDevice, ports map[string]*Attributes) {
+	t.Helper()
+	var ids []string
+	for id := range ports {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	start := time.Now()
+	c := dut.Config()
+	b := setrequest.NewBatch()
+	for _, id := range ids {
+		dp := dut.Port(t, id)
+		i := ports[id].NewInterface(dp.Name())
+		fptest.LogYgot(t, dp.String(), c.Interface(dp.Name()), i)
+		b.Replace(dp.String(), c.Interface(dp.Name()), i)
+	}
+	if err := b.Set(t, dut); err != nil {
+		t.Fatalf("Cannot configure DUT ports: %v", err)
+	}
+	t.Logf("DUT ports %v configured in %v", ids, time.Since(start))
+}
+
 // AddToATE adds a new interface to an ATETopology with these attributes.
 func (a *Attributes) AddToATE(top *ondatra.ATETopology, ap *ondatra.Port, peer *Attributes) *ondatra.Interface {
 	i := top.AddInterface(a.Name).WithPort(ap)
 	if a.MTU > 0 {
 		i.Ethernet().WithMTU(a.MTU)
 	}
+	if a.VLANID > 0 {
+		i.Ethernet().WithVLANID(a.VLANID)
+	}
 	if a.IPv4 != "" {
 		i.IPv4().
 			WithAddress(a.IPv4CIDR()).
@@ -138,6 +207,9 @@ func (a *Attributes) AddToOTG(top gosnappi.Config, ap *ondatra.Port, peer *Attri
 	if a.MTU > 0 {
 		eth.SetMtu(int32(a.MTU))
 	}
+	if a.VLANID > 0 {
+		eth.Vlans().Add().SetName(a.Name + ".VLAN").SetId(int32(a.VLANID))
+	}
 	if a.IPv4 != "" {
 		ip := eth.Ipv4Addresses().Add().SetName(dev.Name() + ".IPv4")
 		ip.SetAddress(a.IPv4).SetGateway(peer.IPv4).SetPrefix(int32(a.IPv4Len))
@@ -0,0 +1,103 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package attrs
+
+import (
+	"testing"
+
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	oc "github.com/openconfig/ondatra/telemetry"
+)
+
+func TestNewSubinterface(t *testing.T) {
+	for _, tc := range []struct {
+		desc       string
+		vlanID     uint16
+		deprecated bool
+		wantIndex  uint32
+	}{{
+		desc:      "untagged",
+		wantIndex: 0,
+	}, {
+		desc:      "tagged",
+		vlanID:    100,
+		wantIndex: 100,
+	}, {
+		desc:       "tagged deprecated vlan-id",
+		vlanID:     200,
+		deprecated: true,
+		wantIndex:  200,
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			defer func(v bool) { *deviations.DeprecatedVlanID = v }(*deviations.DeprecatedVlanID)
+			*deviations.DeprecatedVlanID = tc.deprecated
+
+			a := &Attributes{
+				IPv4:    "192.0.2.1",
+				IPv4Len: 30,
+				VLANID:  tc.vlanID,
+			}
+			s := a.NewSubinterface()
+			if got := s.GetIndex(); got != tc.wantIndex {
+				t.Errorf("index got %d, want %d", got, tc.wantIndex)
+			}
+			if s.GetIpv4().GetAddress(a.IPv4) == nil {
+				t.Errorf("subinterface has no address %s", a.IPv4)
+			}
+
+			v := s.GetVlan()
+			switch {
+			case tc.vlanID == 0:
+				if v != nil {
+					t.Errorf("untagged subinterface vlan got %v, want nil", v)
+				}
+			case tc.deprecated:
+				if got, want := v.VlanId, oc.UnionUint16(tc.vlanID); got != want {
+					t.Errorf("vlan-id got %v, want %v", got, want)
+				}
+				if v.Match != nil {
+					t.Errorf("match got %v, want nil", v.Match)
+				}
+			default:
+				if got := v.GetMatch().GetSingleTagged().GetVlanId(); got != tc.vlanID {
+					t.Errorf("single-tagged vlan-id got %d, want %d", got, tc.vlanID)
+				}
+				if v.VlanId != nil {
+					t.Errorf("deprecated vlan-id got %v, want nil", v.VlanId)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigInterfaceVLAN(t *testing.T) {
+	a := &Attributes{
+		Desc:    "trunk",
+		IPv4:    "192.0.2.5",
+		IPv4Len: 30,
+		VLANID:  100,
+	}
+	intf := a.NewInterface("Ethernet1")
+	if intf.GetSubinterface(0) != nil {
+		t.Errorf("tagged interface has subinterface 0")
+	}
+	s := intf.GetSubinterface(100)
+	if s == nil {
+		t.Fatalf("tagged interface has no subinterface 100")
+	}
+	if s.GetIpv4().GetAddress(a.IPv4) == nil {
+		t.Errorf("subinterface 100 has no address %s", a.IPv4)
+	}
+}
@@ -0,0 +1,254 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bgp
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/ixnet"
+	"github.com/openconfig/ondatra/telemetry"
+)
+
+// EstablishTimeout is how long StartATEPeers waits for each session to
+// be established.
+const EstablishTimeout = 2 * time.Minute
+
+// Routes is a set of prefixes advertised by an ATE peer.
+type Routes struct {
+	// Name is the name of the ATE network advertising the routes.
+	Name string
+	// CIDR is the first IPv4 or IPv6 prefix advertised, and Count the
+	// number of consecutive prefixes of the same length advertised.  A
+	// zero Count advertises CIDR only.
+	CIDR  string
+	Count uint32
+}
+
+// isIPv6 reports whether the routes are IPv6 prefixes.
+func (r *Routes) isIPv6() (bool, error) {
+	ip, _, err := net.ParseCIDR(r.CIDR)
+	if err != nil {
+		return false, fmt.Errorf("routes %s: %w", r.Name, err)
+	}
+	return ip.To4() == nil, nil
+}
+
+// count returns the number of prefixes advertised.
+func (r *Routes) count() uint32 {
+	if r.Count == 0 {
+		return 1
+	}
+	return r.Count
+}
+
+// ATEPeer is an eBGP speaker on an ATE interface advertising routes to
+// the DUT.  A session is established for each address family for which
+// both the ATE and the DUT interface have an address, and carries the
+// routes of that address family.
+type ATEPeer struct {
+	// ATE and DUT are the attributes of the ATE interface and of the DUT
+	// interface it is connected to.
+	ATE, DUT *attrs.Attributes
+	// AS is the AS of the ATE.
+	AS uint32
+	// Routes are the routes advertised to the DUT.
+	Routes []*Routes
+	// MD5Key, if set, is the TCP MD5 password of the sessions, which the
+	// DUT neighbors are configured with.
+	MD5Key string
+
+	peers    []*ixnet.BGPPeer
+	networks map[string]*ixnet.Network
+}
+
+// Neighbors returns the DUT neighbors of the sessions of the peer, to
+// be configured with DUTConfig.
+func (p *ATEPeer) Neighbors() []*Neighbor {
+	var nbrs []*Neighbor
+	if p.ATE.IPv4 != "" && p.DUT.IPv4 != "" {
+		nbrs = append(nbrs, &Neighbor{Address: p.ATE.IPv4, PeerAS: p.AS, AuthPassword: p.MD5Key})
+	}
+	if p.ATE.IPv6 != "" && p.DUT.IPv6 != "" {
+		nbrs = append(nbrs, &Neighbor{Address: p.ATE.IPv6, PeerAS: p.AS, AuthPassword: p.MD5Key})
+	}
+	return nbrs
+}
+
+// nextHop returns the next hop of the routes, i.e. the ATE address of
+// their address family, and whether they are IPv6.  It returns an error
+// if the peer has no session for that address family.
+func (p *ATEPeer) nextHop(r *Routes) (string, bool, error) {
+	v6, err := r.isIPv6()
+	if err != nil {
+		return "", false, err
+	}
+	nh, dut := p.ATE.IPv4, p.DUT.IPv4
+	if v6 {
+		nh, dut = p.ATE.IPv6, p.DUT.IPv6
+	}
+	if nh == "" || dut == "" {
+		return "", false, fmt.Errorf("routes %s: no session for the address family of %s", r.Name, r.CIDR)
+	}
+	return nh, v6, nil
+}
+
+// AddToATE adds the BGP peers and the networks advertising the routes
+// to the ATE interface, which must have been added from p.ATE.
+func (p *ATEPeer) AddToATE(t testing.TB, i *ondatra.Interface) {
+	t.Helper()
+	p.peers = nil
+	for _, nbr := range p.Neighbors() {
+		dutAddr := p.DUT.IPv4
+		if nbr.isIPv6() {
+			dutAddr = p.DUT.IPv6
+		}
+		peer := AddATEPeer(i, dutAddr, p.AS)
+		if p.MD5Key != "" {
+			peer.WithMD5Key(p.MD5Key)
+		}
+		p.peers = append(p.peers, peer)
+	}
+	p.networks = map[string]*ixnet.Network{}
+	for _, r := range p.Routes {
+		nh, v6, err := p.nextHop(r)
+		if err != nil {
+			t.Fatalf("Cannot advertise routes from ATE interface %s: %v", p.ATE.Name, err)
+		}
+		n := i.AddNetwork(r.Name)
+		if v6 {
+			n.IPv6().WithAddress(r.CIDR).WithCount(r.count())
+		} else {
+			n.IPv4().WithAddress(r.CIDR).WithCount(r.count())
+		}
+		n.BGP().WithNextHopAddress(nh)
+		p.networks[r.Name] = n
+	}
+}
+
+// SetAdvertised withdraws or re-advertises the named routes of the peer
+// while the protocols are running.
+func (p *ATEPeer) SetAdvertised(t testing.TB, top *ondatra.ATETopology, name string, advertised bool) {
+	t.Helper()
+	n, ok := p.networks[name]
+	if !ok {
+		t.Fatalf("ATE interface %s has no routes %s", p.ATE.Name, name)
+	}
+	t.Logf("Setting routes %s of ATE interface %s advertised to %t", name, p.ATE.Name, advertised)
+	n.BGP().WithActive(advertised)
+	top.UpdateNetworks(t)
+}
+
+// WithdrawRoutes withdraws the named routes of the peer while the
+// protocols are running, and returns the time the withdrawal was
+// pushed to the ATE, from which to measure the DUT convergence.
+func (p *ATEPeer) WithdrawRoutes(t testing.TB, top *ondatra.ATETopology, name string) time.Time {
+	t.Helper()
+	start := time.Now()
+	p.SetAdvertised(t, top, name, false)
+	return start
+}
+
+// AdvertiseRoutes re-advertises the named routes of the peer after
+// WithdrawRoutes, and returns the time the advertisement was pushed to
+// the ATE.
+func (p *ATEPeer) AdvertiseRoutes(t testing.TB, top *ondatra.ATETopology, name string) time.Time {
+	t.Helper()
+	start := time.Now()
+	p.SetAdvertised(t, top, name, true)
+	return start
+}
+
+// setSessionsActive activates or deactivates the BGP peers of the ATE
+// interface while the protocols are running, and returns the time the
+// change was pushed to the ATE.
+func (p *ATEPeer) setSessionsActive(t testing.TB, top *ondatra.ATETopology, active bool) time.Time {
+	t.Helper()
+	if len(p.peers) == 0 {
+		t.Fatalf("ATE interface %s has no BGP peers", p.ATE.Name)
+	}
+	t.Logf("Setting BGP sessions of ATE interface %s active to %t", p.ATE.Name, active)
+	for _, peer := range p.peers {
+		peer.WithActive(active)
+	}
+	start := time.Now()
+	top.UpdateBGPPeerStates(t)
+	return start
+}
+
+// StopSessions closes the sessions of the peer without withdrawing its
+// routes, and returns the time they were stopped.
+func (p *ATEPeer) StopSessions(t testing.TB, top *ondatra.ATETopology) time.Time {
+	t.Helper()
+	return p.setSessionsActive(t, top, false)
+}
+
+// StartSessions reopens the sessions of the peer after StopSessions, and
+// returns the time they were started.  The peer advertises its routes
+// again once the sessions are established.
+func (p *ATEPeer) StartSessions(t testing.TB, top *ondatra.ATETopology) time.Time {
+	t.Helper()
+	return p.setSessionsActive(t, top, true)
+}
+
+// SetMD5Key changes the TCP MD5 password of the sessions of the peer
+// while the protocols are running.  The sessions are not restarted, so
+// a session only uses the new password for the segments sent after the
+// change, or once restarted with StopSessions and StartSessions.
+func (p *ATEPeer) SetMD5Key(t testing.TB, top *ondatra.ATETopology, key string) {
+	t.Helper()
+	if len(p.peers) == 0 {
+		t.Fatalf("ATE interface %s has no BGP peers", p.ATE.Name)
+	}
+	t.Logf("Changing the MD5 password of the BGP sessions of ATE interface %s", p.ATE.Name)
+	p.MD5Key = key
+	for _, peer := range p.peers {
+		peer.WithMD5Key(key)
+	}
+	top.Update(t)
+}
+
+// StartATEPeers pushes the ATE topology, starts its protocols, and waits
+// for the DUT sessions to the peers to be established, failing the test
+// with the DUT neighbor session-state if one is not within
+// EstablishTimeout.
+func StartATEPeers(t testing.TB, dut *ondatra.DUTDevice, top *ondatra.ATETopology, peers ...*ATEPeer) {
+	t.Helper()
+	top.Push(t).StartProtocols(t)
+	for _, p := range peers {
+		for _, nbr := range p.Neighbors() {
+			if !AwaitEstablished(t, dut, nbr.Address, EstablishTimeout) {
+				t.Fatalf("BGP session to %s is not established within %v, DUT neighbor session-state %v",
+					nbr.Address, EstablishTimeout, sessionState(t, dut, nbr.Address))
+			}
+		}
+	}
+}
+
+// sessionState returns the session-state of the DUT neighbor.
+func sessionState(t testing.TB, dut *ondatra.DUTDevice, addr string) string {
+	t.Helper()
+	q := dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).
+		Protocol(telemetry.PolicyTypes_INSTALL_PROTOCOL_TYPE_BGP, ProtocolName).Bgp().Neighbor(addr).SessionState().Lookup(t)
+	if !q.IsPresent() {
+		return "not present"
+	}
+	return q.Val(t).String()
+}
@@ -0,0 +1,157 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bgp provides helpers to set up eBGP sessions between the DUT
+// and the ATE, for tests that need BGP-learned routes but are not
+// testing BGP itself.
+package bgp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/ixnet"
+	"github.com/openconfig/ondatra/telemetry"
+	"github.com/openconfig/ygot/ygot"
+)
+
+const (
+	// ProtocolName is the name of the BGP protocol instance on the DUT.
+	ProtocolName = "BGP"
+	// PeerGroup is the name of the peer group the neighbors belong to.
+	PeerGroup = "BGP-PEER-GROUP"
+)
+
+// Neighbor is a DUT BGP neighbor.
+type Neighbor struct {
+	// Address is the neighbor address, i.e. the ATE address.
+	Address string
+	// PeerAS is the AS of the neighbor.
+	PeerAS uint32
+	// AuthPassword, if set, is the TCP MD5 password of the session.
+	AuthPassword string
+	// ImportPolicy are the names of the routing policies applied to the
+	// routes received from the neighbor, if any.
+	ImportPolicy []string
+}
+
+// isIPv6 reports whether the neighbor address is IPv6.
+func (n *Neighbor) isIPv6() bool {
+	ip := net.ParseIP(n.Address)
+	return ip != nil && ip.To4() == nil
+}
+
+// DUTConfig builds the DUT BGP config with the given router ID and local
+// AS.  Each neighbor is placed in PeerGroup with the address family
+// matching its address enabled, and its import policies applied.
+func DUTConfig(routerID string, localAS uint32, nbrs ...*Neighbor) *telemetry.NetworkInstance_Protocol_Bgp {
+	bgp := &telemetry.NetworkInstance_Protocol_Bgp{}
+	global := bgp.GetOrCreateGlobal()
+	global.As = ygot.Uint32(localAS)
+	global.RouterId = ygot.String(routerID)
+
+	// The peer group must be defined even without any policy, because it
+	// is invalid OC for a neighbor to be part of a peer group that does
+	// not exist.
+	pg := bgp.GetOrCreatePeerGroup(PeerGroup)
+	pg.PeerGroupName = ygot.String(PeerGroup)
+
+	for _, nbr := range nbrs {
+		n := bgp.GetOrCreateNeighbor(nbr.Address)
+		n.PeerGroup = ygot.String(PeerGroup)
+		n.PeerAs = ygot.Uint32(nbr.PeerAS)
+		n.Enabled = ygot.Bool(true)
+		if nbr.AuthPassword != "" {
+			n.AuthPassword = ygot.String(nbr.AuthPassword)
+		}
+		afisafi := telemetry.BgpTypes_AFI_SAFI_TYPE_IPV4_UNICAST
+		if nbr.isIPv6() {
+			afisafi = telemetry.BgpTypes_AFI_SAFI_TYPE_IPV6_UNICAST
+		}
+		n.GetOrCreateAfiSafi(afisafi).Enabled = ygot.Bool(true)
+		if len(nbr.ImportPolicy) > 0 {
+			n.GetOrCreateApplyPolicy().ImportPolicy = nbr.ImportPolicy
+		}
+	}
+	return bgp
+}
+
+// EnableMultipath enables eBGP multipath in the DUT BGP config built by
+// DUTConfig, so that the DUT installs up to maxPaths paths with equal
+// attributes for each prefix, received from the neighbors of PeerGroup.
+func EnableMultipath(bgp *telemetry.NetworkInstance_Protocol_Bgp, maxPaths uint32) {
+	global := bgp.GetOrCreateGlobal().GetOrCreateUseMultiplePaths()
+	global.Enabled = ygot.Bool(true)
+	global.GetOrCreateEbgp().MaximumPaths = ygot.Uint32(maxPaths)
+	bgp.GetOrCreatePeerGroup(PeerGroup).GetOrCreateUseMultiplePaths().Enabled = ygot.Bool(true)
+}
+
+// ConfigureDUT replaces the BGP config of the DUT.
+func ConfigureDUT(t testing.TB, dut *ondatra.DUTDevice, bgp *telemetry.NetworkInstance_Protocol_Bgp) {
+	t.Helper()
+	p := dut.Config().NetworkInstance(*deviations.DefaultNetworkInstance).
+		Protocol(telemetry.PolicyTypes_INSTALL_PROTOCOL_TYPE_BGP, ProtocolName).Bgp()
+	p.Replace(t, bgp)
+	fptest.LogYgot(t, "DUT BGP", p, bgp)
+}
+
+// DeleteDUT removes the BGP config from the DUT.
+func DeleteDUT(t testing.TB, dut *ondatra.DUTDevice) {
+	t.Helper()
+	dut.Config().NetworkInstance(*deviations.DefaultNetworkInstance).
+		Protocol(telemetry.PolicyTypes_INSTALL_PROTOCOL_TYPE_BGP, ProtocolName).Bgp().Delete(t)
+}
+
+// AwaitEstablished waits for the session to the DUT neighbor to become
+// ESTABLISHED, and reports whether it did before the timeout.  The
+// neighbor telemetry is logged if it did not.
+func AwaitEstablished(t testing.TB, dut *ondatra.DUTDevice, addr string, timeout time.Duration) bool {
+	t.Helper()
+	nbrPath := dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).
+		Protocol(telemetry.PolicyTypes_INSTALL_PROTOCOL_TYPE_BGP, ProtocolName).Bgp().Neighbor(addr)
+	_, ok := nbrPath.SessionState().Watch(t, timeout, func(val *telemetry.QualifiedE_Bgp_Neighbor_SessionState) bool {
+		return val.IsPresent() && val.Val(t) == telemetry.Bgp_Neighbor_SessionState_ESTABLISHED
+	}).Await(t)
+	if !ok {
+		if q := nbrPath.Lookup(t); q.IsPresent() {
+			fptest.LogYgot(t, "BGP neighbor "+addr, nbrPath, q.Val(t))
+		} else {
+			t.Logf("BGP neighbor %s not present in telemetry", addr)
+		}
+	}
+	return ok
+}
+
+// AddATEPeer adds an eBGP peer toward the DUT address on the ATE
+// interface.
+func AddATEPeer(i *ondatra.Interface, dutAddr string, ateAS uint32) *ixnet.BGPPeer {
+	return i.BGP().AddPeer().
+		WithPeerAddress(dutAddr).
+		WithLocalASN(ateAS).
+		WithTypeExternal()
+}
+
+// AdvertiseIPv4 adds a network on the ATE interface that advertises
+// count IPv4 prefixes starting at cidr over BGP with the given next
+// hop, which is typically the address of the ATE interface.
+func AdvertiseIPv4(i *ondatra.Interface, name, cidr string, count uint32, nextHop string) *ixnet.Network {
+	n := i.AddNetwork(name)
+	n.IPv4().WithAddress(cidr).WithCount(count)
+	n.BGP().WithNextHopAddress(nextHop)
+	return n
+}
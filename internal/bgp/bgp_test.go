@@ -0,0 +1,205 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bgp
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/ondatra/telemetry"
+)
+
+func TestDUTConfig(t *testing.T) {
+	bgp := DUTConfig("192.0.2.1", 64500,
+		&Neighbor{Address: "192.0.2.2", PeerAS: 64501, AuthPassword: "key"},
+		&Neighbor{Address: "2001:db8::2", PeerAS: 64501, ImportPolicy: []string{"IMPORT"}},
+	)
+
+	if got, want := bgp.GetGlobal().GetAs(), uint32(64500); got != want {
+		t.Errorf("Global AS got %d, want %d", got, want)
+	}
+	if bgp.GetPeerGroup(PeerGroup) == nil {
+		t.Errorf("Peer group %s is missing", PeerGroup)
+	}
+
+	cases := []struct {
+		addr       string
+		afisafi    telemetry.E_BgpTypes_AFI_SAFI_TYPE
+		other      telemetry.E_BgpTypes_AFI_SAFI_TYPE
+		wantPeer   uint32
+		wantImport []string
+		wantAuth   string
+	}{
+		{"192.0.2.2", telemetry.BgpTypes_AFI_SAFI_TYPE_IPV4_UNICAST, telemetry.BgpTypes_AFI_SAFI_TYPE_IPV6_UNICAST, 64501, nil, "key"},
+		{"2001:db8::2", telemetry.BgpTypes_AFI_SAFI_TYPE_IPV6_UNICAST, telemetry.BgpTypes_AFI_SAFI_TYPE_IPV4_UNICAST, 64501, []string{"IMPORT"}, ""},
+	}
+	for _, c := range cases {
+		n := bgp.GetNeighbor(c.addr)
+		if n == nil {
+			t.Errorf("Neighbor %s is missing", c.addr)
+			continue
+		}
+		if got := n.GetPeerAs(); got != c.wantPeer {
+			t.Errorf("Neighbor %s peer AS got %d, want %d", c.addr, got, c.wantPeer)
+		}
+		if got := n.GetPeerGroup(); got != PeerGroup {
+			t.Errorf("Neighbor %s peer group got %q, want %q", c.addr, got, PeerGroup)
+		}
+		if !n.GetAfiSafi(c.afisafi).GetEnabled() {
+			t.Errorf("Neighbor %s AFI-SAFI %v is not enabled", c.addr, c.afisafi)
+		}
+		if n.GetAfiSafi(c.other) != nil {
+			t.Errorf("Neighbor %s AFI-SAFI %v got configured, want absent", c.addr, c.other)
+		}
+		if got := n.GetAuthPassword(); got != c.wantAuth {
+			t.Errorf("Neighbor %s auth-password got %q, want %q", c.addr, got, c.wantAuth)
+		}
+		if diff := cmp.Diff(c.wantImport, n.GetApplyPolicy().GetImportPolicy()); diff != "" {
+			t.Errorf("Neighbor %s import policy -want,+got:\n%s", c.addr, diff)
+		}
+	}
+}
+
+func TestEnableMultipath(t *testing.T) {
+	bgp := DUTConfig("192.0.2.1", 64500, &Neighbor{Address: "192.0.2.2", PeerAS: 64501})
+	EnableMultipath(bgp, 2)
+
+	mp := bgp.GetGlobal().GetUseMultiplePaths()
+	if !mp.GetEnabled() {
+		t.Errorf("Global use-multiple-paths is not enabled")
+	}
+	if got, want := mp.GetEbgp().GetMaximumPaths(), uint32(2); got != want {
+		t.Errorf("Global eBGP maximum-paths got %d, want %d", got, want)
+	}
+	if !bgp.GetPeerGroup(PeerGroup).GetUseMultiplePaths().GetEnabled() {
+		t.Errorf("Peer group %s use-multiple-paths is not enabled", PeerGroup)
+	}
+}
+
+func TestATEPeerNeighbors(t *testing.T) {
+	ate := &attrs.Attributes{IPv4: "192.0.2.2", IPv6: "2001:db8::2"}
+	cases := []struct {
+		desc   string
+		dut    *attrs.Attributes
+		md5Key string
+		want   []*Neighbor
+	}{{
+		desc: "IPv4",
+		dut:  &attrs.Attributes{IPv4: "192.0.2.1"},
+		want: []*Neighbor{{Address: "192.0.2.2", PeerAS: 64501}},
+	}, {
+		desc: "dual stack",
+		dut:  &attrs.Attributes{IPv4: "192.0.2.1", IPv6: "2001:db8::1"},
+		want: []*Neighbor{
+			{Address: "192.0.2.2", PeerAS: 64501},
+			{Address: "2001:db8::2", PeerAS: 64501},
+		},
+	}, {
+		desc:   "MD5",
+		dut:    &attrs.Attributes{IPv4: "192.0.2.1"},
+		md5Key: "key",
+		want:   []*Neighbor{{Address: "192.0.2.2", PeerAS: 64501, AuthPassword: "key"}},
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			p := &ATEPeer{ATE: ate, DUT: c.dut, AS: 64501, MD5Key: c.md5Key}
+			if diff := cmp.Diff(c.want, p.Neighbors()); diff != "" {
+				t.Errorf("Neighbors() -want,+got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestATEPeerNextHop(t *testing.T) {
+	p := &ATEPeer{
+		ATE: &attrs.Attributes{IPv4: "192.0.2.2", IPv6: "2001:db8::2"},
+		DUT: &attrs.Attributes{IPv4: "192.0.2.1"},
+	}
+	cases := []struct {
+		routes  *Routes
+		want    string
+		wantErr bool
+	}{
+		{routes: &Routes{Name: "v4", CIDR: "203.0.113.0/24"}, want: "192.0.2.2"},
+		{routes: &Routes{Name: "v6", CIDR: "2001:db8:1::/64"}, wantErr: true},
+		{routes: &Routes{Name: "bad", CIDR: "203.0.113.0"}, wantErr: true},
+	}
+	for _, c := range cases {
+		got, _, err := p.nextHop(c.routes)
+		if gotErr := err != nil; gotErr != c.wantErr {
+			t.Errorf("nextHop(%s) got error %v, want error %t", c.routes.Name, err, c.wantErr)
+		}
+		if got != c.want {
+			t.Errorf("nextHop(%s) got %q, want %q", c.routes.Name, got, c.want)
+		}
+	}
+}
+
+func TestOTGPeerAddToOTG(t *testing.T) {
+	for _, twoOctet := range []bool{false, true} {
+		top := gosnappi.NewConfig()
+		top.Devices().Add().SetName("atePort1").Ethernets().Add().SetName("atePort1.Eth").
+			Ipv4Addresses().Add().SetName("atePort1.IPv4").SetAddress("192.0.2.2").SetGateway("192.0.2.1").SetPrefix(30)
+		p := &OTGPeer{
+			ATE:        &attrs.Attributes{Name: "atePort1", IPv4: "192.0.2.2"},
+			DUT:        &attrs.Attributes{IPv4: "192.0.2.1"},
+			AS:         65538,
+			Routes:     []*Routes{{Name: "net", CIDR: "203.0.113.0/24", Count: 4}},
+			TwoOctetAS: twoOctet,
+		}
+		p.AddToOTG(t, top)
+
+		bgp := top.Devices().Items()[0].Bgp()
+		if got, want := bgp.RouterId(), "192.0.2.2"; got != want {
+			t.Errorf("TwoOctetAS %t: router ID got %q, want %q", twoOctet, got, want)
+		}
+		intfs := bgp.Ipv4Interfaces().Items()
+		if len(intfs) != 1 || len(intfs[0].Peers().Items()) != 1 {
+			t.Fatalf("TwoOctetAS %t: got %d BGP interfaces, want 1 with 1 peer", twoOctet, len(intfs))
+		}
+		if got, want := intfs[0].Ipv4Name(), "atePort1.IPv4"; got != want {
+			t.Errorf("TwoOctetAS %t: BGP interface got %q, want %q", twoOctet, got, want)
+		}
+		peer := intfs[0].Peers().Items()[0]
+		if got, want := peer.Name(), p.Name(); got != want {
+			t.Errorf("TwoOctetAS %t: peer name got %q, want %q", twoOctet, got, want)
+		}
+		if got, want := peer.AsNumber(), int32(65538); got != want {
+			t.Errorf("TwoOctetAS %t: peer AS got %d, want %d", twoOctet, got, want)
+		}
+		wantWidth := gosnappi.BgpV4PeerAsNumberWidth.FOUR
+		if twoOctet {
+			wantWidth = gosnappi.BgpV4PeerAsNumberWidth.TWO
+		}
+		if got := peer.AsNumberWidth(); got != wantWidth {
+			t.Errorf("TwoOctetAS %t: peer AS number width got %q, want %q", twoOctet, got, wantWidth)
+		}
+
+		rrs := peer.V4Routes().Items()
+		if len(rrs) != 1 || len(rrs[0].Addresses().Items()) != 1 {
+			t.Fatalf("TwoOctetAS %t: got %d route ranges, want 1 with 1 address", twoOctet, len(rrs))
+		}
+		if got, want := rrs[0].NextHopIpv4Address(), "192.0.2.2"; got != want {
+			t.Errorf("TwoOctetAS %t: next hop got %q, want %q", twoOctet, got, want)
+		}
+		addr := rrs[0].Addresses().Items()[0]
+		if got, want := fmt.Sprintf("%s/%d x%d", addr.Address(), addr.Prefix(), addr.Count()), "203.0.113.0/24 x4"; got != want {
+			t.Errorf("TwoOctetAS %t: routes got %q, want %q", twoOctet, got, want)
+		}
+	}
+}
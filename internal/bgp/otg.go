@@ -0,0 +1,137 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bgp
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"testing"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/ondatra"
+)
+
+// OTGPeer is an eBGP speaker on an OTG device advertising IPv4 routes to
+// the DUT, the OTG counterpart of ATEPeer.  Only IPv4 sessions are
+// supported.
+type OTGPeer struct {
+	// ATE and DUT are the attributes of the OTG interface and of the DUT
+	// interface it is connected to.
+	ATE, DUT *attrs.Attributes
+	// AS is the AS of the OTG.  OTG limits it to math.MaxInt32.
+	AS uint32
+	// Routes are the IPv4 routes advertised to the DUT.
+	Routes []*Routes
+	// TwoOctetAS disables the four-octet AS capability, so that the peer
+	// behaves as a speaker supporting 2-byte AS numbers only: it sees a
+	// DUT with a 4-byte AS as AS_TRANS, and receives 4-byte AS numbers
+	// in the AS path as AS_TRANS.
+	TwoOctetAS bool
+}
+
+// Name returns the name of the OTG BGP peer, from which its telemetry
+// is reported.
+func (p *OTGPeer) Name() string {
+	return p.ATE.Name + ".BGP4.peer"
+}
+
+// Neighbors returns the DUT neighbor of the session of the peer, to be
+// configured with DUTConfig.
+func (p *OTGPeer) Neighbors() []*Neighbor {
+	return []*Neighbor{{Address: p.ATE.IPv4, PeerAS: p.AS}}
+}
+
+// otgIPv4 returns the IPv4 address of the OTG device named name, added
+// by attrs.Attributes AddToOTG.
+func otgIPv4(top gosnappi.Config, name string) (gosnappi.Device, gosnappi.DeviceIpv4, error) {
+	for _, dev := range top.Devices().Items() {
+		if dev.Name() != name {
+			continue
+		}
+		for _, eth := range dev.Ethernets().Items() {
+			if ips := eth.Ipv4Addresses().Items(); len(ips) > 0 {
+				return dev, ips[0], nil
+			}
+		}
+		return nil, nil, fmt.Errorf("OTG device %s has no IPv4 address", name)
+	}
+	return nil, nil, fmt.Errorf("OTG device %s not found", name)
+}
+
+// AddToOTG adds the BGP peer and the route ranges advertising the routes
+// to the OTG device, which must have been added from p.ATE.  The local
+// AS is included in the AS path of the routes.
+func (p *OTGPeer) AddToOTG(t testing.TB, top gosnappi.Config) {
+	t.Helper()
+	if p.AS > math.MaxInt32 {
+		t.Fatalf("Cannot add BGP peer to OTG device %s: AS %d is above the OTG limit of %d", p.ATE.Name, p.AS, math.MaxInt32)
+	}
+	dev, ip, err := otgIPv4(top, p.ATE.Name)
+	if err != nil {
+		t.Fatalf("Cannot add BGP peer to OTG device %s: %v", p.ATE.Name, err)
+	}
+
+	width := gosnappi.BgpV4PeerAsNumberWidth.FOUR
+	if p.TwoOctetAS {
+		width = gosnappi.BgpV4PeerAsNumberWidth.TWO
+	}
+	peer := dev.Bgp().SetRouterId(p.ATE.IPv4).Ipv4Interfaces().Add().SetIpv4Name(ip.Name()).
+		Peers().Add().SetName(p.Name()).
+		SetPeerAddress(p.DUT.IPv4).
+		SetAsNumber(int32(p.AS)).
+		SetAsType(gosnappi.BgpV4PeerAsType.EBGP).
+		SetAsNumberWidth(width)
+
+	for _, r := range p.Routes {
+		v6, err := r.isIPv6()
+		if err == nil && v6 {
+			err = fmt.Errorf("routes %s: IPv6 is not supported", r.Name)
+		}
+		if err != nil {
+			t.Fatalf("Cannot advertise routes from OTG device %s: %v", p.ATE.Name, err)
+		}
+		_, ipnet, _ := net.ParseCIDR(r.CIDR)
+		plen, _ := ipnet.Mask.Size()
+		rr := peer.V4Routes().Add().SetName(r.Name).
+			SetNextHopIpv4Address(p.ATE.IPv4).
+			SetNextHopAddressType(gosnappi.BgpV4RouteRangeNextHopAddressType.IPV4).
+			SetNextHopMode(gosnappi.BgpV4RouteRangeNextHopMode.MANUAL)
+		rr.AsPath().SetAsSetMode(gosnappi.BgpAsPathAsSetMode.INCLUDE_AS_SEQ)
+		rr.Addresses().Add().
+			SetAddress(ipnet.IP.String()).
+			SetPrefix(int32(plen)).
+			SetCount(int32(r.count()))
+	}
+}
+
+// StartOTGPeers pushes the OTG config, starts its protocols, and waits
+// for the DUT sessions to the peers to be established as StartATEPeers
+// does.
+func StartOTGPeers(t testing.TB, dut *ondatra.DUTDevice, ate *ondatra.ATEDevice, top gosnappi.Config, peers ...*OTGPeer) {
+	t.Helper()
+	otg := ate.OTG()
+	otg.PushConfig(t, top)
+	otg.StartProtocols(t)
+	for _, p := range peers {
+		for _, nbr := range p.Neighbors() {
+			if !AwaitEstablished(t, dut, nbr.Address, EstablishTimeout) {
+				t.Fatalf("BGP session to %s is not established within %v, DUT neighbor session-state %v",
+					nbr.Address, EstablishTimeout, sessionState(t, dut, nbr.Address))
+			}
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package certs generates the keys and certificates tests install on
+// the DUT, and inspects the certificate the DUT presents in the TLS
+// handshake of a gRPC connection.
+package certs
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/testt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+const (
+	// keyBits is the size of the RSA keys generated, which devices
+	// commonly accept.
+	keyBits = 2048
+	// rpcTimeout is how long the RPC ServerCertificate sends may take.
+	rpcTimeout = 10 * time.Second
+)
+
+// KeyPair is a private key and the certificate of its public key.
+type KeyPair struct {
+	Cert *x509.Certificate
+	Key  *rsa.PrivateKey
+}
+
+// NewCA returns a self-signed CA certificate and its key, valid from
+// now for the given duration.
+func NewCA(commonName string, validity time.Duration) (*KeyPair, error) {
+	tmpl, err := template(commonName, validity)
+	if err != nil {
+		return nil, err
+	}
+	tmpl.IsCA = true
+	tmpl.BasicConstraintsValid = true
+	tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	return create(tmpl, nil)
+}
+
+// Issue returns a server certificate for the DNS names and IP addresses
+// signed by the CA, and its key, valid from now for the given duration.
+func (ca *KeyPair) Issue(commonName string, dnsNames []string, ips []net.IP, validity time.Duration) (*KeyPair, error) {
+	tmpl, err := template(commonName, validity)
+	if err != nil {
+		return nil, err
+	}
+	tmpl.DNSNames = dnsNames
+	tmpl.IPAddresses = ips
+	tmpl.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
+	tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	return create(tmpl, ca)
+}
+
+// template returns a certificate template with a random serial number.
+func template(commonName string, validity time.Duration) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("cannot generate serial number: %w", err)
+	}
+	now := time.Now()
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    now.Add(-time.Minute), // Allow for clock skew.
+		NotAfter:     now.Add(validity),
+	}, nil
+}
+
+// create generates a key and its certificate from the template, signed
+// by the CA, or self-signed if the CA is nil.
+func create(tmpl *x509.Certificate, ca *KeyPair) (*KeyPair, error) {
+	key, err := rsa.GenerateKey(rand.Reader, keyBits)
+	if err != nil {
+		return nil, fmt.Errorf("cannot generate key: %w", err)
+	}
+	parent, signer := tmpl, key
+	if ca != nil {
+		parent, signer = ca.Cert, ca.Key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, signer)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create certificate %q: %w", tmpl.Subject.CommonName, err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyPair{Cert: cert, Key: key}, nil
+}
+
+// CertPEM returns the certificate PEM encoded.
+func (k *KeyPair) CertPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: k.Cert.Raw})
+}
+
+// PrivateKeyPEM returns the private key PEM encoded in PKCS #1 form.
+func (k *KeyPair) PrivateKeyPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k.Key)})
+}
+
+// PublicKeyPEM returns the public key PEM encoded in PKIX form.
+func (k *KeyPair) PublicKeyPEM() ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(&k.Key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// ParseCertPEM parses the first PEM encoded certificate in b.
+func ParseCertPEM(b []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("no PEM encoded certificate found")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// PeerCertificate returns the certificate the server presented in the
+// TLS handshake of the connection of an RPC, whose peer is retrieved
+// with the grpc.Peer call option.
+func PeerCertificate(p *peer.Peer) (*x509.Certificate, error) {
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return nil, fmt.Errorf("connection to %v does not use TLS: got auth info %T", p.Addr, p.AuthInfo)
+	}
+	certs := info.State.PeerCertificates
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("server %v presented no certificate", p.Addr)
+	}
+	return certs[0], nil
+}
+
+// ServerCertificate returns the certificate the gRPC server of the DUT
+// presents in the TLS handshake of a new connection, on which it sends
+// a gNMI Capabilities RPC.
+func ServerCertificate(t testing.TB, dut *ondatra.DUTDevice) (*x509.Certificate, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout)
+	defer cancel()
+	var p peer.Peer
+	var err error
+	if msg := testt.CaptureFatal(t, func(t testing.TB) {
+		_, err = dut.RawAPIs().GNMI().New(t).Capabilities(ctx, &gpb.CapabilityRequest{}, grpc.Peer(&p))
+	}); msg != nil {
+		return nil, fmt.Errorf("cannot connect to gNMI: %s", *msg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("gNMI Capabilities failed: %w", err)
+	}
+	return PeerCertificate(&p)
+}
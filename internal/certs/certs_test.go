@@ -0,0 +1,113 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+func TestIssue(t *testing.T) {
+	ca, err := NewCA("Test CA", time.Hour)
+	if err != nil {
+		t.Fatalf("NewCA() got error: %v", err)
+	}
+	if !ca.Cert.IsCA {
+		t.Errorf("NewCA() got a certificate that is not a CA")
+	}
+	kp, err := ca.Issue("dut", []string{"dut.example.com"}, []net.IP{net.ParseIP("192.0.2.1")}, time.Hour)
+	if err != nil {
+		t.Fatalf("Issue() got error: %v", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Cert)
+	for _, name := range []string{"dut.example.com", "192.0.2.1"} {
+		if _, err := kp.Cert.Verify(x509.VerifyOptions{DNSName: name, Roots: roots}); err != nil {
+			t.Errorf("Issue() got certificate not valid for %s: %v", name, err)
+		}
+	}
+	if _, err := kp.Cert.Verify(x509.VerifyOptions{DNSName: "other.example.com", Roots: roots}); err == nil {
+		t.Errorf("Issue() got certificate valid for other.example.com, want invalid")
+	}
+
+	got, err := ParseCertPEM(kp.CertPEM())
+	if err != nil {
+		t.Fatalf("ParseCertPEM() got error: %v", err)
+	}
+	if !got.Equal(kp.Cert) {
+		t.Errorf("ParseCertPEM() got %v, want %v", got.Subject, kp.Cert.Subject)
+	}
+	if _, err := tls.X509KeyPair(kp.CertPEM(), kp.PrivateKeyPEM()); err != nil {
+		t.Errorf("PrivateKeyPEM() got a key not matching the certificate: %v", err)
+	}
+	pub, err := kp.PublicKeyPEM()
+	if err != nil {
+		t.Fatalf("PublicKeyPEM() got error: %v", err)
+	}
+	if len(pub) == 0 {
+		t.Errorf("PublicKeyPEM() got empty key")
+	}
+}
+
+func TestParseCertPEMError(t *testing.T) {
+	if _, err := ParseCertPEM([]byte("not a certificate")); err == nil {
+		t.Errorf("ParseCertPEM() got no error, want error")
+	}
+}
+
+func TestPeerCertificate(t *testing.T) {
+	ca, err := NewCA("Test CA", time.Hour)
+	if err != nil {
+		t.Fatalf("NewCA() got error: %v", err)
+	}
+	addr := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 9339}
+	for _, tc := range []struct {
+		desc    string
+		peer    *peer.Peer
+		want    *x509.Certificate
+		wantErr bool
+	}{{
+		desc: "tls",
+		peer: &peer.Peer{Addr: addr, AuthInfo: credentials.TLSInfo{
+			State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{ca.Cert}},
+		}},
+		want: ca.Cert,
+	}, {
+		desc:    "no certificate",
+		peer:    &peer.Peer{Addr: addr, AuthInfo: credentials.TLSInfo{}},
+		wantErr: true,
+	}, {
+		desc:    "insecure",
+		peer:    &peer.Peer{Addr: addr},
+		wantErr: true,
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := PeerCertificate(tc.peer)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("PeerCertificate() got error %v, want error %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("PeerCertificate() got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
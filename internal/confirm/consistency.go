@@ -0,0 +1,164 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package confirm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/openconfig/goyang/pkg/yang"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+	"github.com/openconfig/ygot/ygot"
+	"github.com/openconfig/ygot/ytypes"
+)
+
+// compareView returns a description of each leaf set in want whose value
+// differs in the view got, e.g. "config" or "state", and of each leaf
+// missing from it if reportMissing.
+func compareView(view string, want, got ygot.ValidatedGoStruct, reportMissing bool) ([]string, error) {
+	diff, err := ygot.Diff(want, got, &ygot.IgnoreAdditions{})
+	if err != nil {
+		return nil, fmt.Errorf("ygot.Diff failure: %v", err)
+	}
+	changes, err := ExtractChanges(diff, want, got)
+	if err != nil {
+		return nil, err
+	}
+	var diffs []string
+	for _, c := range changes {
+		switch {
+		case !c.Missing:
+			diffs = append(diffs, fmt.Sprintf("%v: %s got %v, want %v", PathLabel(c.Path), view, Readable(c.Got), Readable(c.Want)))
+		case reportMissing:
+			diffs = append(diffs, fmt.Sprintf("%v: %s missing, want %v", PathLabel(c.Path), view, Readable(c.Want)))
+		}
+	}
+	return diffs, nil
+}
+
+// trimPath returns the elements of p below the path base, or false if p
+// is not base or below it.
+func trimPath(p []*gnmipb.PathElem, base *gnmipb.Path) ([]*gnmipb.PathElem, bool) {
+	if len(p) < len(base.GetElem()) {
+		return nil, false
+	}
+	for i, e := range base.GetElem() {
+		if p[i].GetName() != e.GetName() || !reflect.DeepEqual(p[i].GetKey(), e.GetKey()) {
+			return nil, false
+		}
+	}
+	return p[len(base.GetElem()):], true
+}
+
+// unmarshalView unmarshals the notifications of a gNMI Get of the path
+// into dst, the struct of the path.
+func unmarshalView(schema *yang.Entry, pth *gnmipb.Path, notifs []*gnmipb.Notification, dst ygot.ValidatedGoStruct) error {
+	for _, n := range notifs {
+		for _, u := range n.GetUpdate() {
+			elems := append(append([]*gnmipb.PathElem{}, n.GetPrefix().GetElem()...), u.GetPath().GetElem()...)
+			rel, ok := trimPath(elems, pth)
+			if !ok {
+				continue
+			}
+			if len(rel) == 0 {
+				if err := telemetry.Unmarshal(u.GetVal().GetJsonIetfVal(), dst, &ytypes.IgnoreExtraFields{}); err != nil {
+					return fmt.Errorf("cannot unmarshal %v: %v", PathLabel(pth), err)
+				}
+				continue
+			}
+			relPath := &gnmipb.Path{Elem: rel}
+			if err := ytypes.SetNode(schema, dst, relPath, u.GetVal(), &ytypes.InitMissingElements{}, &ytypes.IgnoreExtraFields{}); err != nil {
+				return fmt.Errorf("cannot set %v below %v: %v", PathLabel(relPath), PathLabel(pth), err)
+			}
+		}
+	}
+	return nil
+}
+
+// getView fetches the view of the path of the given data type through a
+// gNMI Get, into a new struct of the type of want.
+func getView(t testing.TB, dut *ondatra.DUTDevice, pth *gnmipb.Path, dataType gnmipb.GetRequest_DataType, want ygot.ValidatedGoStruct) (ygot.ValidatedGoStruct, error) {
+	schema, err := getSchema(want)
+	if err != nil {
+		return nil, fmt.Errorf("schema lookup failure: %v", err)
+	}
+	resp, err := dut.RawAPIs().GNMI().Default(t).Get(context.Background(), &gnmipb.GetRequest{
+		Path:     []*gnmipb.Path{pth},
+		Type:     dataType,
+		Encoding: gnmipb.Encoding_JSON_IETF,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("gNMI Get of %v failed: %v", PathLabel(pth), err)
+	}
+	got := reflect.New(reflect.TypeOf(want).Elem()).Interface().(ygot.ValidatedGoStruct)
+	if err := unmarshalView(schema, pth, resp.GetNotification(), got); err != nil {
+		return nil, err
+	}
+	return got, nil
+}
+
+// ConfigAndState checks that the DUT reflects the intended config of the
+// path, e.g. after a gNMI Replace, by fetching both its CONFIG and STATE
+// views through gNMI Get.  It reports a test error for each leaf set in
+// intent whose value differs in either view, or which is missing from
+// the config view.  Leaves missing from the state view are not reported,
+// since config-only leaves need not appear there.  The views are fetched
+// again until they are consistent or the timeout passes, since the state
+// may lag the config.  It returns whether they are consistent.
+func ConfigAndState(t testing.TB, dut *ondatra.DUTDevice, path ygot.PathStruct, intent ygot.ValidatedGoStruct, timeout time.Duration) bool {
+	t.Helper()
+	pth, _, errs := ygot.ResolvePath(path)
+	if len(errs) > 0 {
+		t.Errorf("Cannot resolve path: %v", errs)
+		return false
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		var diffs []string
+		for _, v := range []struct {
+			name          string
+			dataType      gnmipb.GetRequest_DataType
+			reportMissing bool
+		}{
+			{"config", gnmipb.GetRequest_CONFIG, true},
+			{"state", gnmipb.GetRequest_STATE, false},
+		} {
+			got, err := getView(t, dut, pth, v.dataType, intent)
+			if err == nil {
+				var d []string
+				d, err = compareView(v.name, intent, got, v.reportMissing)
+				diffs = append(diffs, d...)
+			}
+			if err != nil {
+				diffs = append(diffs, fmt.Sprintf("%v: %s: %v", PathLabel(pth), v.name, err))
+			}
+		}
+		if len(diffs) == 0 {
+			return true
+		}
+		if time.Now().After(deadline) {
+			for _, d := range diffs {
+				t.Error(d)
+			}
+			return false
+		}
+		time.Sleep(time.Second)
+	}
+}
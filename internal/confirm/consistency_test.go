@@ -0,0 +1,120 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package confirm
+
+import (
+	"strings"
+	"testing"
+
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/openconfig/ondatra/telemetry"
+	"github.com/openconfig/ygot/ygot"
+)
+
+func TestCompareView(t *testing.T) {
+	want := &telemetry.Interface{
+		Name:        ygot.String("eth0"),
+		Description: ygot.String("src"),
+		Mtu:         ygot.Uint16(1500),
+	}
+	cases := []struct {
+		desc          string
+		got           *telemetry.Interface
+		reportMissing bool
+		want          []string
+	}{{
+		desc: "equal",
+		got: &telemetry.Interface{
+			Name:        ygot.String("eth0"),
+			Description: ygot.String("src"),
+			Mtu:         ygot.Uint16(1500),
+		},
+	}, {
+		desc: "additions ignored",
+		got: &telemetry.Interface{
+			Name:        ygot.String("eth0"),
+			Description: ygot.String("src"),
+			Mtu:         ygot.Uint16(1500),
+			Enabled:     ygot.Bool(true),
+		},
+	}, {
+		desc: "differing",
+		got: &telemetry.Interface{
+			Name:        ygot.String("eth0"),
+			Description: ygot.String("dst"),
+			Mtu:         ygot.Uint16(1500),
+		},
+		want: []string{"description"},
+	}, {
+		desc: "missing not reported",
+		got: &telemetry.Interface{
+			Name:        ygot.String("eth0"),
+			Description: ygot.String("src"),
+		},
+	}, {
+		desc: "missing reported",
+		got: &telemetry.Interface{
+			Name:        ygot.String("eth0"),
+			Description: ygot.String("src"),
+		},
+		reportMissing: true,
+		want:          []string{"mtu"},
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			got, err := compareView("state", want, c.got, c.reportMissing)
+			if err != nil {
+				t.Fatalf("compareView got error %v, want nil", err)
+			}
+			if len(got) != len(c.want) {
+				t.Fatalf("compareView got %q, want %d differences", got, len(c.want))
+			}
+			for i, leaf := range c.want {
+				if !strings.Contains(got[i], leaf) {
+					t.Errorf("compareView difference %d got %q, want mention of %s", i, got[i], leaf)
+				}
+			}
+		})
+	}
+}
+
+func TestTrimPath(t *testing.T) {
+	base := &gnmipb.Path{Elem: []*gnmipb.PathElem{
+		{Name: "interfaces"},
+		{Name: "interface", Key: map[string]string{"name": "eth0"}},
+	}}
+	intf := func(name string) *gnmipb.PathElem {
+		return &gnmipb.PathElem{Name: "interface", Key: map[string]string{"name": name}}
+	}
+	cases := []struct {
+		desc    string
+		elems   []*gnmipb.PathElem
+		wantLen int
+		wantOK  bool
+	}{
+		{"same", []*gnmipb.PathElem{{Name: "interfaces"}, intf("eth0")}, 0, true},
+		{"below", []*gnmipb.PathElem{{Name: "interfaces"}, intf("eth0"), {Name: "state"}, {Name: "mtu"}}, 2, true},
+		{"other key", []*gnmipb.PathElem{{Name: "interfaces"}, intf("eth1"), {Name: "state"}}, 0, false},
+		{"above", []*gnmipb.PathElem{{Name: "interfaces"}}, 0, false},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			got, ok := trimPath(c.elems, base)
+			if ok != c.wantOK || len(got) != c.wantLen {
+				t.Errorf("trimPath got %d elements, %t, want %d elements, %t", len(got), ok, c.wantLen, c.wantOK)
+			}
+		})
+	}
+}
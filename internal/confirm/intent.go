@@ -0,0 +1,175 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package confirm
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/openconfig/goyang/pkg/yang"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ygot/ygot"
+	"github.com/openconfig/ygot/ytypes"
+)
+
+// String describes the change for the test log.
+func (c *Change) String() string {
+	if c.Missing {
+		return fmt.Sprintf("%v: missing, want %v", PathLabel(c.Path), Readable(c.Want))
+	}
+	return fmt.Sprintf("%v: got %v, want %v", PathLabel(c.Path), Readable(c.Got), Readable(c.Want))
+}
+
+// stateOnly returns whether the schema entry is a leaf under a state
+// container without a counterpart under the config container next to
+// it, such as a counter, which an intended config cannot set.
+func stateOnly(e *yang.Entry) bool {
+	var rel []string
+	for p := e; p.Parent != nil; p = p.Parent {
+		if p.Name != "state" {
+			rel = append(rel, p.Name)
+			continue
+		}
+		c := p.Parent.Dir["config"]
+		for i := len(rel) - 1; c != nil && i >= 0; i-- {
+			c = c.Dir[rel[i]]
+		}
+		return c == nil
+	}
+	return false
+}
+
+// nodeSchema returns the schema entry of the single node of the path in
+// root.
+func nodeSchema(schema *yang.Entry, root ygot.ValidatedGoStruct, pth *gnmipb.Path) (*yang.Entry, error) {
+	nodes, err := ytypes.GetNode(schema, root, pth)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) != 1 {
+		return nil, fmt.Errorf("expected exactly one node, found %v", len(nodes))
+	}
+	return nodes[0].Schema, nil
+}
+
+// missingListEntry returns the path of the outermost list entry of the
+// path that is absent from root, or nil if there is none.
+func missingListEntry(schema *yang.Entry, root ygot.ValidatedGoStruct, pth *gnmipb.Path) *gnmipb.Path {
+	for i, e := range pth.GetElem() {
+		if len(e.GetKey()) == 0 {
+			continue
+		}
+		entry := &gnmipb.Path{Elem: pth.GetElem()[:i+1]}
+		if nodes, err := ytypes.GetNode(schema, root, entry); err != nil || len(nodes) == 0 {
+			return entry
+		}
+	}
+	return nil
+}
+
+// intentChanges compares the intended config with the state got.  Leaves
+// got reports beyond the intent are ignored, as are intended leaves that
+// are state-only.  Lists are compared entry by entry by their keys, so
+// the order of their entries does not matter, and an intended list entry
+// missing from got is a single change of the entry rather than one per
+// leaf.  The changes are sorted by path.
+func intentChanges(intent, got ygot.ValidatedGoStruct) ([]*Change, error) {
+	schema, err := getSchema(intent)
+	if err != nil {
+		return nil, fmt.Errorf("schema lookup failure: %v", err)
+	}
+	diff, err := ygot.Diff(intent, got, &ygot.IgnoreAdditions{})
+	if err != nil {
+		return nil, fmt.Errorf("ygot.Diff failure: %v", err)
+	}
+	changes, err := ExtractChanges(diff, intent, got)
+	if err != nil {
+		return nil, err
+	}
+
+	var kept []*Change
+	missingEntries := make(map[string]bool)
+	for _, c := range changes {
+		e, err := nodeSchema(schema, intent, c.Path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot find schema of %v: %v", PathLabel(c.Path), err)
+		}
+		if stateOnly(e) {
+			continue
+		}
+		if c.Missing {
+			if entry := missingListEntry(schema, got, c.Path); entry != nil {
+				label := PathLabel(entry)
+				if !missingEntries[label] {
+					missingEntries[label] = true
+					kept = append(kept, &Change{Path: entry, Want: "list entry", Missing: true})
+				}
+				continue
+			}
+		}
+		kept = append(kept, c)
+	}
+	sort.Slice(kept, func(i, j int) bool { return PathLabel(kept[i].Path) < PathLabel(kept[j].Path) })
+	return kept, nil
+}
+
+// IntentChanges fetches the state of the path through a gNMI Get and
+// compares it with intent, the config the test built for the path, to
+// tell whether the DUT accepted all of it.  It returns the intended
+// leaves the state is missing or has a different value of, ignoring
+// state-only leaves and the order of list entries.
+func IntentChanges(t testing.TB, dut *ondatra.DUTDevice, path ygot.PathStruct, intent ygot.ValidatedGoStruct) ([]*Change, error) {
+	t.Helper()
+	pth, _, errs := ygot.ResolvePath(path)
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("cannot resolve path: %v", errs)
+	}
+	got, err := getView(t, dut, pth, gnmipb.GetRequest_STATE, intent)
+	if err != nil {
+		return nil, err
+	}
+	return intentChanges(intent, got)
+}
+
+// Intent reports a test error for each change IntentChanges returns,
+// and returns whether there are none.
+func Intent(t testing.TB, dut *ondatra.DUTDevice, path ygot.PathStruct, intent ygot.ValidatedGoStruct) bool {
+	t.Helper()
+	changes, err := IntentChanges(t, dut, path, intent)
+	if err != nil {
+		t.Errorf("Cannot compare the state with the intended config: %v", err)
+		return false
+	}
+	for _, c := range changes {
+		t.Errorf("State does not match the intended config: %v", c)
+	}
+	return len(changes) == 0
+}
+
+// LogIntent logs each change IntentChanges returns, for tests that only
+// want to know which intended config the DUT did not accept.
+func LogIntent(t testing.TB, dut *ondatra.DUTDevice, path ygot.PathStruct, intent ygot.ValidatedGoStruct) {
+	t.Helper()
+	changes, err := IntentChanges(t, dut, path, intent)
+	if err != nil {
+		t.Logf("Cannot compare the state with the intended config: %v", err)
+		return
+	}
+	for _, c := range changes {
+		t.Logf("State does not match the intended config: %v", c)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package confirm
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/openconfig/goyang/pkg/yang"
+	"github.com/openconfig/ondatra/telemetry"
+	"github.com/openconfig/ygot/ygot"
+)
+
+// newIntent returns an intended interface config with two subinterfaces
+// added in the given order.
+func newIntent(indexes ...uint32) *telemetry.Interface {
+	i := &telemetry.Interface{
+		Name:        ygot.String("eth0"),
+		Description: ygot.String("src"),
+		Mtu:         ygot.Uint16(1500),
+	}
+	for _, idx := range indexes {
+		s := i.GetOrCreateSubinterface(idx)
+		s.GetOrCreateIpv4().GetOrCreateAddress("192.0.2.1").PrefixLength = ygot.Uint8(30)
+	}
+	return i
+}
+
+func TestIntentChanges(t *testing.T) {
+	cases := []struct {
+		desc      string
+		intent    func() *telemetry.Interface
+		got       func() *telemetry.Interface
+		wantPaths []string
+	}{{
+		desc:   "equal",
+		intent: func() *telemetry.Interface { return newIntent(0, 1) },
+		got:    func() *telemetry.Interface { return newIntent(0, 1) },
+	}, {
+		desc:   "list entries reordered",
+		intent: func() *telemetry.Interface { return newIntent(0, 1) },
+		got:    func() *telemetry.Interface { return newIntent(1, 0) },
+	}, {
+		desc:   "additions ignored",
+		intent: func() *telemetry.Interface { return newIntent(0) },
+		got: func() *telemetry.Interface {
+			i := newIntent(0, 1)
+			i.Enabled = ygot.Bool(true)
+			i.GetOrCreateCounters().InOctets = ygot.Uint64(42)
+			return i
+		},
+	}, {
+		desc: "state-only leaves ignored",
+		intent: func() *telemetry.Interface {
+			i := newIntent(0)
+			i.GetOrCreateCounters().InOctets = ygot.Uint64(42)
+			return i
+		},
+		got: func() *telemetry.Interface { return newIntent(0) },
+	}, {
+		desc:   "differing and missing leaves",
+		intent: func() *telemetry.Interface { return newIntent(0) },
+		got: func() *telemetry.Interface {
+			i := newIntent(0)
+			i.Description = ygot.String("dst")
+			i.Mtu = nil
+			return i
+		},
+		wantPaths: []string{"/state/description", "/state/mtu"},
+	}, {
+		desc:   "missing list entry",
+		intent: func() *telemetry.Interface { return newIntent(0, 1) },
+		got:    func() *telemetry.Interface { return newIntent(0) },
+		wantPaths: []string{
+			"/subinterfaces/subinterface[index=1]",
+		},
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			changes, err := intentChanges(c.intent(), c.got())
+			if err != nil {
+				t.Fatalf("intentChanges got error %v, want nil", err)
+			}
+			var gotPaths []string
+			for _, ch := range changes {
+				gotPaths = append(gotPaths, PathLabel(ch.Path))
+			}
+			if diff := cmp.Diff(c.wantPaths, gotPaths); diff != "" {
+				t.Errorf("intentChanges paths -want,+got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestStateOnly(t *testing.T) {
+	root := &yang.Entry{Name: "interface", Dir: map[string]*yang.Entry{}}
+	config := &yang.Entry{Name: "config", Parent: root, Dir: map[string]*yang.Entry{}}
+	state := &yang.Entry{Name: "state", Parent: root, Dir: map[string]*yang.Entry{}}
+	root.Dir["config"], root.Dir["state"] = config, state
+	config.Dir["mtu"] = &yang.Entry{Name: "mtu", Parent: config}
+	stateMTU := &yang.Entry{Name: "mtu", Parent: state}
+	counters := &yang.Entry{Name: "counters", Parent: state, Dir: map[string]*yang.Entry{}}
+	inOctets := &yang.Entry{Name: "in-octets", Parent: counters}
+	state.Dir["mtu"], state.Dir["counters"], counters.Dir["in-octets"] = stateMTU, counters, inOctets
+	name := &yang.Entry{Name: "name", Parent: root}
+
+	cases := []struct {
+		desc string
+		e    *yang.Entry
+		want bool
+	}{
+		{"config leaf", config.Dir["mtu"], false},
+		{"state leaf with config", stateMTU, false},
+		{"state-only leaf", inOctets, true},
+		{"leaf outside config and state", name, false},
+	}
+	for _, c := range cases {
+		if got := stateOnly(c.e); got != c.want {
+			t.Errorf("%s: stateOnly got %t, want %t", c.desc, got, c.want)
+		}
+	}
+}
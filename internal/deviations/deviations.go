@@ -63,7 +63,10 @@
 //     go test my_test.go --deviation_interface_enabled=true
 package deviations
 
-import "flag"
+import (
+	"flag"
+	"strings"
+)
 
 // Vendor deviation flags.
 var (
@@ -99,4 +102,45 @@ var (
 	StaticProtocolName = flag.String("deviation_static_protocol_name", "DEFAULT", "The name used for the static routing protocol.  The default name in OpenConfig is \"DEFAULT\" but some devices use other names.")
 
 	DeprecatedVlanID = flag.Bool("deviation_deprecated_vlan_id", false, "Device requires using the deprecated openconfig-vlan:vlan/config/vlan-id or openconfig-vlan:vlan/state/vlan-id leaves.")
+
+	GRIBIUnresolvedNextHopFailed = flag.Bool("deviation_gribi_unresolved_next_hop_failed", false, "Device reports FAILED instead of FIB_FAILED for an entry whose next hop cannot be resolved.")
+
+	StaticRouteNextHopInterfaceRef = flag.Bool("deviation_static_route_next_hop_interface_ref", false, "Device requires a static route next hop to reference its egress interface via interface-ref in addition to the next hop address.  Full OpenConfig compliant devices should pass both with and without this deviation.")
+
+	TrafficLossTolerance = flag.Float64("deviation_traffic_loss_tolerance", 0, "Percentage of packets the device may lose in any traffic validation that expects no loss, i.e. on a stable path as well as during a change that is expected to be hitless, such as adding a next hop to a NextHopGroup, unless the test sets its own tolerance.  Full compliant devices should pass with the default of 0.")
+
+	GRIBIProcessName = flag.String("deviation_gribi_process_name", "", "Name of the process implementing gRIBI on the device, used by tests that restart it.  Overrides the process name the test uses for the vendor of the device.")
+
+	GRIBIProcessRestartUnsupported = flag.Bool("deviation_gribi_process_restart_unsupported", false, "Device cannot restart the process implementing gRIBI in isolation via gNOI KillProcess, so tests that restart it are skipped.")
+
+	GNMIProcessName = flag.String("deviation_gnmi_process_name", "", "Name of the process implementing gNMI on the device, used by tests that restart it.  Overrides the process name the test uses for the vendor of the device.")
+
+	GNMIProcessRestartUnsupported = flag.Bool("deviation_gnmi_process_restart_unsupported", false, "Device cannot restart the process implementing gNMI in isolation via gNOI KillProcess, so tests that restart it are skipped.")
+
+	GRIBIEncapNextHopUnsupported = flag.Bool("deviation_gribi_encap_next_hop_unsupported", false, "Device does not support gRIBI next hops that encapsulate packets in an IPv4 header, so tests that program them are skipped.")
+
+	GRIBINHGMatchByKey = flag.Bool("deviation_gribi_nhg_match_by_key", false, "Device does not report next-hop-group/state/programmed-id in the AFT, but keys its next hop groups by the gRIBI next hop group ID, so tests match next hop groups by key instead.")
+
+	GRETunnelOCUnsupported = flag.Bool("deviation_gre_tunnel_oc_unsupported", false, "Device does not support configuring GRE tunnel interfaces with openconfig-if-tunnel, so tests of GRE tunnels are skipped once the OpenConfig configuration is rejected.")
+
+	InterfaceCountersUnreliable = flag.Bool("deviation_interface_counters_unreliable", false, "Device does not count forwarded packets accurately in its per-interface unicast packet counters, so tests skip cross-checking them against the packets the ATE sent and received.")
+
+	ISISKeychainUnsupported = flag.Bool("deviation_isis_keychain_unsupported", false, "Device does not support authenticating IS-IS hellos with the keys of an OpenConfig keychain, so tests that use keychains are skipped.")
+
+	QoSEgressRemarkUnsupported = flag.Bool("deviation_qos_egress_remark_unsupported", false, "Device does not support remarking the DSCP of packets with a classifier applied to the packets an interface sends, so tests that remark on egress are skipped.")
+
+	TACACSLoginAttemptUnsupported = flag.Bool("deviation_tacacs_login_attempt_unsupported", false, "Device does not authenticate the username and password in the metadata of gRPC requests with its TACACS+ servers, so tests skip checking that a login attempt counts connections to unreachable TACACS+ servers.")
 )
+
+// Active returns the deviation flags set to a value other than their
+// default, mapped to their value, e.g. to record the context of test
+// results.
+func Active() map[string]string {
+	m := make(map[string]string)
+	flag.VisitAll(func(f *flag.Flag) {
+		if strings.HasPrefix(f.Name, "deviation_") && f.Value.String() != f.DefValue {
+			m[f.Name] = f.Value.String()
+		}
+	})
+	return m
+}
@@ -0,0 +1,137 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fptest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// maxObservations is how many of the last values of a leaf Await and
+// AwaitFunc report when they time out.
+const maxObservations = 50
+
+// Qualified is implemented by the qualified values of the generated
+// telemetry leaves, e.g. *telemetry.QualifiedString.
+type Qualified[T any] interface {
+	IsPresent() bool
+	Val(testing.TB) T
+}
+
+// Awaiter is implemented by the watchers of the generated telemetry
+// leaves, e.g. *telemetry.StringWatcher.
+type Awaiter[Q any] interface {
+	Await(testing.TB) (Q, bool)
+}
+
+// WatchFunc is the Watch method of a generated telemetry leaf path,
+// e.g. dut.Telemetry().Interface(name).OperStatus().Watch.
+type WatchFunc[Q any, W Awaiter[Q]] func(t testing.TB, timeout time.Duration, predicate func(Q) bool) W
+
+// observation is a value of a leaf seen by a watch.
+type observation[T any] struct {
+	at      time.Time
+	present bool
+	val     T
+}
+
+// observations records the last maxObservations values of a leaf.
+type observations[T any] struct {
+	start       time.Time
+	all         []observation[T]
+	dropped     int
+	everPresent bool
+}
+
+// add records a value seen at the given time.
+func (o *observations[T]) add(at time.Time, present bool, val T) {
+	o.everPresent = o.everPresent || present
+	o.all = append(o.all, observation[T]{at: at, present: present, val: val})
+	if n := len(o.all) - maxObservations; n > 0 {
+		o.all = o.all[n:]
+		o.dropped += n
+	}
+}
+
+// String describes the values seen, with their time since the start of
+// the watch.
+func (o *observations[T]) String() string {
+	if len(o.all) == 0 {
+		return "no updates received"
+	}
+	var b strings.Builder
+	if o.everPresent {
+		b.WriteString("leaf was present")
+	} else {
+		b.WriteString("leaf was never present")
+	}
+	if o.dropped > 0 {
+		fmt.Fprintf(&b, "; last %d of %d updates:", len(o.all), len(o.all)+o.dropped)
+	} else {
+		fmt.Fprintf(&b, "; %d updates:", len(o.all))
+	}
+	for _, ob := range o.all {
+		fmt.Fprintf(&b, "\n  +%v: ", ob.at.Sub(o.start).Round(time.Millisecond))
+		if ob.present {
+			fmt.Fprintf(&b, "%v", ob.val)
+		} else {
+			b.WriteString("not present")
+		}
+	}
+	return b.String()
+}
+
+// AwaitFunc watches a telemetry leaf until predicate returns true, and
+// reports a test error describing what was awaited and the last values
+// of the leaf if it does not within the timeout.  It returns the last
+// value and whether the predicate returned true.  The value type of the
+// leaf must be given, e.g.
+//
+//	fptest.AwaitFunc[string](t, path.Prefix().Watch, time.Minute, "no prefix", func(q *telemetry.QualifiedString) bool {
+//		return !q.IsPresent()
+//	})
+func AwaitFunc[T any, Q Qualified[T], W Awaiter[Q]](t testing.TB, watch WatchFunc[Q, W], timeout time.Duration, what string, predicate func(Q) bool) (Q, bool) {
+	t.Helper()
+	obs := &observations[T]{start: time.Now()}
+	q, ok := watch(t, timeout, func(q Q) bool {
+		var val T
+		present := q.IsPresent()
+		if present {
+			val = q.Val(t)
+		}
+		obs.add(time.Now(), present, val)
+		return predicate(q)
+	}).Await(t)
+	if !ok {
+		t.Errorf("Telemetry did not get %s within %v, %v", what, timeout, obs)
+	}
+	return q, ok
+}
+
+// Await watches a telemetry leaf until it has the wanted value, and
+// reports a test error with the last values of the leaf and whether it
+// was ever present if it does not within the timeout.  It works for any
+// leaf with a comparable value, e.g.
+//
+//	fptest.Await(t, dut.Telemetry().Interface(name).OperStatus().Watch, time.Minute, telemetry.Interface_OperStatus_UP)
+func Await[T comparable, Q Qualified[T], W Awaiter[Q]](t testing.TB, watch WatchFunc[Q, W], timeout time.Duration, want T) bool {
+	t.Helper()
+	_, ok := AwaitFunc[T](t, watch, timeout, fmt.Sprintf("value %v", want), func(q Q) bool {
+		return q.IsPresent() && q.Val(t) == want
+	})
+	return ok
+}
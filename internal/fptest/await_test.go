@@ -0,0 +1,131 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fptest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeQualified is a qualified value of a fakeLeaf.
+type fakeQualified struct {
+	present bool
+	val     string
+}
+
+func (q *fakeQualified) IsPresent() bool       { return q.present }
+func (q *fakeQualified) Val(testing.TB) string { return q.val }
+
+// fakeWatcher is the watcher of a fakeLeaf.
+type fakeWatcher struct {
+	last *fakeQualified
+	ok   bool
+}
+
+func (w *fakeWatcher) Await(testing.TB) (*fakeQualified, bool) { return w.last, w.ok }
+
+// fakeLeaf is a leaf path whose watch sees the given values in order.
+type fakeLeaf []*fakeQualified
+
+func (l fakeLeaf) Watch(t testing.TB, timeout time.Duration, predicate func(*fakeQualified) bool) *fakeWatcher {
+	w := &fakeWatcher{}
+	for _, q := range l {
+		w.last = q
+		if predicate(q) {
+			w.ok = true
+			break
+		}
+	}
+	return w
+}
+
+// errorRecorder records the test errors instead of reporting them.
+type errorRecorder struct {
+	testing.TB
+	errs []string
+}
+
+func (r *errorRecorder) Helper() {}
+
+func (r *errorRecorder) Errorf(format string, args ...interface{}) {
+	r.errs = append(r.errs, fmt.Sprintf(format, args...))
+}
+
+func TestAwait(t *testing.T) {
+	leaf := fakeLeaf{{}, {present: true, val: "DOWN"}, {present: true, val: "UP"}}
+	r := &errorRecorder{TB: t}
+	if !Await(r, leaf.Watch, time.Minute, "UP") {
+		t.Errorf("Await(UP) got false, want true")
+	}
+	if len(r.errs) != 0 {
+		t.Errorf("Await(UP) got errors %v, want none", r.errs)
+	}
+}
+
+func TestAwaitTimeout(t *testing.T) {
+	leaf := fakeLeaf{{}, {present: true, val: "DOWN"}, {}}
+	r := &errorRecorder{TB: t}
+	if Await(r, leaf.Watch, time.Minute, "UP") {
+		t.Errorf("Await(UP) got true, want false")
+	}
+	if len(r.errs) != 1 {
+		t.Fatalf("Await(UP) got errors %v, want one", r.errs)
+	}
+	for _, want := range []string{"value UP", "leaf was present", "3 updates", "DOWN", "not present"} {
+		if !strings.Contains(r.errs[0], want) {
+			t.Errorf("Await(UP) got error %q, want it to contain %q", r.errs[0], want)
+		}
+	}
+}
+
+func TestAwaitFuncNeverPresent(t *testing.T) {
+	leaf := fakeLeaf{{}, {}}
+	r := &errorRecorder{TB: t}
+	q, ok := AwaitFunc[string](r, leaf.Watch, time.Minute, "any value", func(q *fakeQualified) bool {
+		return q.IsPresent()
+	})
+	if ok || q != leaf[1] {
+		t.Errorf("AwaitFunc() got %v, %t, want %v, false", q, ok, leaf[1])
+	}
+	if len(r.errs) != 1 || !strings.Contains(r.errs[0], "leaf was never present") {
+		t.Errorf("AwaitFunc() got errors %v, want one saying the leaf was never present", r.errs)
+	}
+}
+
+func TestObservationsBounded(t *testing.T) {
+	start := time.Now()
+	o := &observations[int]{start: start}
+	if got, want := o.String(), "no updates received"; got != want {
+		t.Errorf("String() got %q, want %q", got, want)
+	}
+	for i := 0; i < maxObservations+10; i++ {
+		o.add(start.Add(time.Duration(i)*time.Second), true, i)
+	}
+	if got, want := len(o.all), maxObservations; got != want {
+		t.Errorf("len(observations) got %d, want %d", got, want)
+	}
+	if got, want := o.all[0].val, 10; got != want {
+		t.Errorf("first observation got %d, want %d", got, want)
+	}
+	s := o.String()
+	if want := fmt.Sprintf("last %d of %d updates", maxObservations, maxObservations+10); !strings.Contains(s, want) {
+		t.Errorf("String() got %q, want it to contain %q", s, want)
+	}
+	if want := "+59s: 59"; !strings.Contains(s, want) {
+		t.Errorf("String() got %q, want it to contain %q", s, want)
+	}
+}
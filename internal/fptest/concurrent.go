@@ -0,0 +1,161 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fptest
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Step is a named step of a test prelude, e.g. configuring the DUT or
+// pushing the ATE topology.
+type Step struct {
+	Name string
+	Run  func(t testing.TB)
+}
+
+// RunConcurrently runs independent steps of a test prelude concurrently
+// and logs how long each took. Steps that depend on others must follow it:
+//
+//	fptest.RunConcurrently(t,
+//	  fptest.Step{Name: "configure DUT", Run: configureDUT},
+//	  fptest.Step{Name: "push ATE topology", Run: pushTopology},
+//	)
+//	traffic.StartPushedProtocolsAndAwait(t, ate, top, readiness)
+//
+// Fatal and skip calls of a step only end that step; once all steps are
+// done, the test fails naming the failed steps, or else is skipped if a
+// step skipped. Each step is timed with TimeStep.
+func RunConcurrently(t testing.TB, steps ...Step) {
+	t.Helper()
+	failures, skips := runConcurrently(t, t.Name(), steps)
+	if len(failures) > 0 {
+		t.Fatalf("Concurrent setup steps failed:\n%s", strings.Join(failures, "\n"))
+	}
+	if len(skips) > 0 {
+		t.Skipf("Concurrent setup steps skipped:\n%s", strings.Join(skips, "\n"))
+	}
+}
+
+// runConcurrently runs the steps under the given test name and returns
+// their failures and skips, so it can be tested with a fake testing.TB.
+func runConcurrently(t testing.TB, name string, steps []Step) (failures, skips []string) {
+	t.Helper()
+	start := time.Now()
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		took = make([]time.Duration, len(steps))
+	)
+	for i, s := range steps {
+		wg.Add(1)
+		go func(i int, s Step) {
+			defer wg.Done()
+			st := &stepTB{TB: t, name: name + "/" + s.Name}
+			stepStart := time.Now()
+			defer func() {
+				took[i] = time.Since(stepStart)
+				mu.Lock()
+				defer mu.Unlock()
+				switch {
+				case st.failed:
+					failures = append(failures, s.Name+": "+st.msg)
+				case st.skipped:
+					skips = append(skips, s.Name+": "+st.msg)
+				}
+			}()
+			defer TimeStep(st, s.Name)()
+			s.Run(st)
+		}(i, s)
+	}
+	wg.Wait()
+
+	var sum time.Duration
+	var durations []string
+	for i, s := range steps {
+		sum += took[i]
+		durations = append(durations, s.Name+" "+took[i].String())
+	}
+	t.Logf("Ran %s concurrently in %v rather than %v serially", strings.Join(durations, ", "), time.Since(start), sum)
+	return failures, skips
+}
+
+// stepTB is the testing.TB of a step, named as a subtest of the test.
+// Fatal and skip calls end only the step's goroutine.
+type stepTB struct {
+	testing.TB
+	name    string
+	failed  bool
+	skipped bool
+	msg     string
+}
+
+// Name returns the name of the step as a subtest.
+func (t *stepTB) Name() string {
+	return t.name
+}
+
+// Fatal records the failure of the step and ends it.
+func (t *stepTB) Fatal(args ...interface{}) {
+	t.fail(fmt.Sprint(args...))
+}
+
+// Fatalf records the failure of the step and ends it.
+func (t *stepTB) Fatalf(format string, args ...interface{}) {
+	t.fail(fmt.Sprintf(format, args...))
+}
+
+// FailNow records the failure of the step and ends it.
+func (t *stepTB) FailNow() {
+	t.fail("FailNow called")
+}
+
+// Skip records the skip of the step and ends it.
+func (t *stepTB) Skip(args ...interface{}) {
+	t.skip(fmt.Sprint(args...))
+}
+
+// Skipf records the skip of the step and ends it.
+func (t *stepTB) Skipf(format string, args ...interface{}) {
+	t.skip(fmt.Sprintf(format, args...))
+}
+
+// SkipNow records the skip of the step and ends it.
+func (t *stepTB) SkipNow() {
+	t.skip("SkipNow called")
+}
+
+// Skipped returns whether the step was skipped, rather than the test.
+func (t *stepTB) Skipped() bool {
+	return t.skipped
+}
+
+// fail records the failure of the step and ends its goroutine.
+func (t *stepTB) fail(msg string) {
+	t.failed = true
+	t.msg = msg
+	runtime.Goexit()
+}
+
+// skip records the skip of the step and ends its goroutine.
+func (t *stepTB) skip(msg string) {
+	t.skipped = true
+	t.msg = msg
+	runtime.Goexit()
+}
@@ -0,0 +1,99 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fptest
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/openconfig/testt"
+)
+
+func TestRunConcurrently(t *testing.T) {
+	const d = 100 * time.Millisecond
+	var ran int32
+	step := func(t testing.TB) {
+		time.Sleep(d)
+		atomic.AddInt32(&ran, 1)
+	}
+	start := time.Now()
+	RunConcurrently(t, Step{Name: "first", Run: step}, Step{Name: "second", Run: step})
+	if got := atomic.LoadInt32(&ran); got != 2 {
+		t.Errorf("RunConcurrently ran %d steps, want 2", got)
+	}
+	if elapsed := time.Since(start); elapsed >= 2*d {
+		t.Errorf("RunConcurrently took %v, want less than %v for steps of %v run concurrently", elapsed, 2*d, d)
+	}
+}
+
+func TestRunConcurrentlyFatal(t *testing.T) {
+	var completed int32
+	failures, _ := runConcurrently(t, t.Name(), []Step{
+		{Name: "good", Run: func(t testing.TB) {
+			time.Sleep(50 * time.Millisecond)
+			atomic.AddInt32(&completed, 1)
+		}},
+		{Name: "bad", Run: func(t testing.TB) {
+			t.Fatalf("boom")
+			atomic.AddInt32(&completed, 1)
+		}},
+	})
+	if diff := cmp.Diff([]string{"bad: boom"}, failures); diff != "" {
+		t.Errorf("runConcurrently failures -want,+got:\n%s", diff)
+	}
+	if got := atomic.LoadInt32(&completed); got != 1 {
+		t.Errorf("runConcurrently completed %d steps, want 1: the good step completes and the bad one ends at its fatal call", got)
+	}
+}
+
+func TestRunConcurrentlyError(t *testing.T) {
+	errs := testt.ExpectError(t, func(t testing.TB) {
+		runConcurrently(t, "TestRunConcurrentlyError", []Step{
+			{Name: "soft", Run: func(t testing.TB) {
+				t.Errorf("diff")
+			}},
+		})
+	})
+	if diff := cmp.Diff([]string{"diff"}, errs); diff != "" {
+		t.Errorf("runConcurrently errors -want,+got:\n%s", diff)
+	}
+}
+
+func TestRunConcurrentlySkip(t *testing.T) {
+	var completed int32
+	failures, skips := runConcurrently(t, t.Name(), []Step{
+		{Name: "good", Run: func(t testing.TB) {
+			atomic.AddInt32(&completed, 1)
+		}},
+		{Name: "unsupported", Run: func(t testing.TB) {
+			t.Skipf("not supported")
+			atomic.AddInt32(&completed, 1)
+		}},
+	})
+	if len(failures) != 0 {
+		t.Errorf("runConcurrently got failures %v, want none", failures)
+	}
+	if diff := cmp.Diff([]string{"unsupported: not supported"}, skips); diff != "" {
+		t.Errorf("runConcurrently skips -want,+got:\n%s", diff)
+	}
+	if got := atomic.LoadInt32(&completed); got != 1 {
+		t.Errorf("runConcurrently completed %d steps, want 1: the skipped step ends at its skip call", got)
+	}
+	if t.Skipped() {
+		t.Errorf("runConcurrently skipped the test from the goroutine of a step")
+	}
+}
@@ -19,7 +19,9 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"reflect"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
@@ -60,9 +62,17 @@ func sanitizeFilename(filename string) string {
 // WriteOutput writes content to a file in the specified outputs
 // directory, after sanitizing the filename and making it unique.
 func WriteOutput(filename, suffix string, content string) error {
+	_, err := WriteOutputFile(filename, suffix, content)
+	return err
+}
+
+// WriteOutputFile is like WriteOutput, but also returns the name of the
+// file written, or an empty name if the output is discarded, so that a
+// test can point to it in its log.
+func WriteOutputFile(filename, suffix string, content string) (string, error) {
 	if *outputsDir == "" {
 		log.Printf("Test output %q is discarded without -outputs_dir.  Please specify -outputs_dir to keep it.", filename)
-		return nil
+		return "", nil
 	}
 	template := fmt.Sprintf(
 		"%s.%s%s%s",
@@ -72,12 +82,39 @@ func WriteOutput(filename, suffix string, content string) error {
 		suffix)
 	f, err := os.CreateTemp(*outputsDir, template)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer f.Close()
 	_, err = f.Write([]byte(content))
 	log.Printf("Test output written: %s", f.Name())
-	return err
+	return f.Name(), err
+}
+
+// ReplaceOutput writes content to a file in the specified outputs
+// directory named after the sanitized filename, replacing the content
+// written by a previous call with the same filename.  Unlike
+// WriteOutput, it lets a test keep a single output file up to date.
+func ReplaceOutput(filename, suffix string, content string) error {
+	if *outputsDir == "" {
+		log.Printf("Test output %q is discarded without -outputs_dir.  Please specify -outputs_dir to keep it.", filename)
+		return nil
+	}
+	name := filepath.Join(*outputsDir, sanitizeFilename(filename)+suffix)
+	if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+		return err
+	}
+	log.Printf("Test output written: %s", name)
+	return nil
+}
+
+// redactedLeaf matches the JSON members of the leaves whose values must
+// not appear in the test log or outputs, e.g. the BGP auth-password or
+// the keychain secret-key, with or without a module name prefix.
+var redactedLeaf = regexp.MustCompile(`("(?:[\w-]+:)?(?:auth-password|secret-key)"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+
+// redact replaces the values of the redacted leaves in the JSON text.
+func redact(text string) string {
+	return redactedLeaf.ReplaceAllString(text, `${1}"<redacted>"`)
 }
 
 // ygotToText serializes any validatable ygot struct to a JSON string.
@@ -126,7 +163,8 @@ func isConfig(path ygot.PathStruct) bool {
 
 // LogYgot logs a ygot GoStruct at path as either config or telemetry,
 // depending on the path.  It also writes a copy to a *.json file in
-// the directory specified by the -outputs_dir flag.
+// the directory specified by the -outputs_dir flag.  Secrets such as
+// passwords are redacted from both.
 //
 // Ondatra has separate paths for config (dut.Config()) and telemetry
 // (dut.Telemetry()), but both share the same GoStruct defined in
@@ -162,6 +200,7 @@ func logYgot(t testing.TB, what string, path ygot.PathStruct, obj ygot.Validated
 	if err != nil {
 		t.Errorf("%s render error: %v", header, err)
 	}
+	text = redact(text)
 	if shouldLog {
 		t.Logf("%s:\n%s", header, text)
 	}
@@ -15,6 +15,8 @@
 package fptest
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/openconfig/ondatra"
@@ -41,6 +43,43 @@ func TestWriteOutput(t *testing.T) {
 	}
 }
 
+func TestReplaceOutput(t *testing.T) {
+	defer func(dir string) { *outputsDir = dir }(*outputsDir)
+	*outputsDir = t.TempDir()
+	for _, content := range []string{`{"a": 1}`, `{"b": 2}`} {
+		if err := ReplaceOutput("TestReplaceOutput results", ".json", content); err != nil {
+			t.Fatalf("ReplaceOutput got error: %v", err)
+		}
+		got, err := os.ReadFile(filepath.Join(*outputsDir, "TestReplaceOutput_results.json"))
+		if err != nil {
+			t.Fatalf("Cannot read output: %v", err)
+		}
+		if string(got) != content {
+			t.Errorf("Output got %q, want %q", got, content)
+		}
+	}
+}
+
+func TestRedact(t *testing.T) {
+	text := `{
+  "openconfig-network-instance:neighbor-address": "192.0.2.2",
+  "openconfig-network-instance:auth-password": "s3cr\"et",
+  "auth-password" : "plain",
+  "openconfig-keychain:secret-key": "key1",
+  "description": "auth-password"
+}`
+	want := `{
+  "openconfig-network-instance:neighbor-address": "192.0.2.2",
+  "openconfig-network-instance:auth-password": "<redacted>",
+  "auth-password" : "<redacted>",
+  "openconfig-keychain:secret-key": "<redacted>",
+  "description": "auth-password"
+}`
+	if got := redact(text); got != want {
+		t.Errorf("redact() got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
 func TestIsConfig(t *testing.T) {
 	cases := []struct {
 		path ygot.PathStruct
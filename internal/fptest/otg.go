@@ -0,0 +1,36 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fptest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/openconfig/featureprofiles/topologies/binding"
+	"github.com/openconfig/ondatra"
+)
+
+// RawOTG returns the raw OTG API of the ATE, for the OTG operations
+// that Ondatra does not expose, such as packet captures and latency
+// metrics.  It is only available to tests run with RunTests, whose
+// binding records the ATEs of the reservation.
+func RawOTG(ctx context.Context, ate *ondatra.ATEDevice) (gosnappi.GosnappiApi, error) {
+	api, err := binding.OTG(ctx, ate.Name())
+	if err != nil {
+		return nil, fmt.Errorf("no raw OTG API for %s, which requires the test to run with fptest.RunTests in its TestMain: %w", ate.Name(), err)
+	}
+	return api, nil
+}
@@ -31,6 +31,9 @@ import (
 //	func TestMain(m *testing.M) {
 //	  fptest.RunTests(m)
 //	}
+//
+// Once the tests end, it reports the steps they timed with TimeStep.
 func RunTests(m *testing.M) {
 	ondatra.RunTests(m, binding.New)
+	reportSteps()
 }
@@ -0,0 +1,245 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fptest
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	// stepsSuffix is the suffix of the step report of a test in the
+	// outputs directory.
+	stepsSuffix = ".steps.json"
+	// maxLoggedSteps caps the number of steps in the table logged by
+	// RunTests; the JSON report lists all of them.
+	maxLoggedSteps = 20
+)
+
+// stepStats aggregate the calls of a step of a test with the same path,
+// i.e. the names of the steps it ran in joined by "/", then its name.
+type stepStats struct {
+	Path  string
+	Count int
+	// Total is the time spent in the calls, and Self the part of it not
+	// spent in nested steps.  Nested steps run concurrently may add up
+	// to more than their parent, in which case Self is zero.
+	Total time.Duration
+	Self  time.Duration
+	Max   time.Duration
+}
+
+// stepJSON is the JSON form of stepStats.
+type stepJSON struct {
+	Path    string  `json:"path"`
+	Count   int     `json:"count"`
+	TotalMs float64 `json:"total_ms"`
+	SelfMs  float64 `json:"self_ms"`
+	MaxMs   float64 `json:"max_ms"`
+}
+
+// MarshalJSON serializes the stats for the step report, with durations
+// in milliseconds.
+func (s *stepStats) MarshalJSON() ([]byte, error) {
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	return json.Marshal(stepJSON{
+		Path:    s.Path,
+		Count:   s.Count,
+		TotalMs: ms(s.Total),
+		SelfMs:  ms(s.Self),
+		MaxMs:   ms(s.Max),
+	})
+}
+
+// openStep is a step that has not ended yet.
+type openStep struct {
+	path  string
+	start time.Time
+	// nested is the time spent in the nested steps that ended.
+	nested time.Duration
+}
+
+// testSteps are the steps of a top-level test and its subtests.
+type testSteps struct {
+	// open holds the stack of open steps of each test or subtest, by
+	// name.  A step nests under the innermost open step of its test,
+	// or else of the closest parent test.
+	open  map[string][]*openStep
+	stats map[string]*stepStats
+}
+
+// parent returns the innermost open step of the named test or of its
+// closest parent test, or nil if there is none.
+func (ts *testSteps) parent(name string) *openStep {
+	for {
+		if stack := ts.open[name]; len(stack) > 0 {
+			return stack[len(stack)-1]
+		}
+		i := strings.LastIndex(name, "/")
+		if i < 0 {
+			return nil
+		}
+		name = name[:i]
+	}
+}
+
+// stepTracker holds the steps of the tests, by top-level test name.
+type stepTracker struct {
+	mu    sync.Mutex
+	tests map[string]*testSteps
+}
+
+// start opens the step of the named test at the given time, and returns
+// a function that ends it at the time it is called with.
+func (tr *stepTracker) start(test, step string, now time.Time) func(end time.Time) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if tr.tests == nil {
+		tr.tests = make(map[string]*testSteps)
+	}
+	top := strings.SplitN(test, "/", 2)[0]
+	ts, ok := tr.tests[top]
+	if !ok {
+		ts = &testSteps{open: make(map[string][]*openStep), stats: make(map[string]*stepStats)}
+		tr.tests[top] = ts
+	}
+	parent := ts.parent(test)
+	s := &openStep{path: step, start: now}
+	if parent != nil {
+		s.path = parent.path + "/" + step
+	}
+	ts.open[test] = append(ts.open[test], s)
+
+	return func(end time.Time) {
+		tr.mu.Lock()
+		defer tr.mu.Unlock()
+		stack := ts.open[test]
+		for i := len(stack) - 1; i >= 0; i-- {
+			if stack[i] == s {
+				ts.open[test] = append(stack[:i], stack[i+1:]...)
+				break
+			}
+		}
+		d := end.Sub(s.start)
+		if parent != nil {
+			parent.nested += d
+		}
+		st, ok := ts.stats[s.path]
+		if !ok {
+			st = &stepStats{Path: s.path}
+			ts.stats[s.path] = st
+		}
+		st.Count++
+		st.Total += d
+		if self := d - s.nested; self > 0 {
+			st.Self += self
+		}
+		if d > st.Max {
+			st.Max = d
+		}
+	}
+}
+
+// slowest returns the stats of the steps of the top-level test, from
+// the longest total time.
+func (tr *stepTracker) slowest(test string) []*stepStats {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	ts, ok := tr.tests[test]
+	if !ok {
+		return nil
+	}
+	var stats []*stepStats
+	for _, st := range ts.stats {
+		c := *st
+		stats = append(stats, &c)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Total != stats[j].Total {
+			return stats[i].Total > stats[j].Total
+		}
+		return stats[i].Path < stats[j].Path
+	})
+	return stats
+}
+
+// testNames returns the names of the top-level tests that have steps.
+func (tr *stepTracker) testNames() []string {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	var names []string
+	for name := range tr.tests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// timedSteps holds the steps timed by TimeStep.
+var timedSteps stepTracker
+
+// TimeStep starts timing a step of the test, e.g. a config push or a
+// traffic run, and returns a function that ends the step, to be
+// deferred:
+//
+//	defer fptest.TimeStep(t, "push config")()
+//
+// A step started while another is open in the same test or a parent
+// test is nested under it, so its time is also part of its parent's.
+// The steps are reported by RunTests when the tests end.
+func TimeStep(t testing.TB, name string) func() {
+	end := timedSteps.start(t.Name(), name, time.Now())
+	return func() { end(time.Now()) }
+}
+
+// stepTable formats the stats as a table, in order.
+func stepTable(stats []*stepStats) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%12s %12s %12s %6s  %s\n", "total", "self", "max", "count", "step")
+	for _, st := range stats {
+		fmt.Fprintf(&b, "%12v %12v %12v %6d  %s\n",
+			st.Total.Round(time.Millisecond), st.Self.Round(time.Millisecond), st.Max.Round(time.Millisecond), st.Count, st.Path)
+	}
+	return b.String()
+}
+
+// reportSteps logs the slowest steps of each top-level test, and writes
+// all of them as JSON to the outputs directory.
+func reportSteps() {
+	for _, test := range timedSteps.testNames() {
+		stats := timedSteps.slowest(test)
+		logged := stats
+		if len(logged) > maxLoggedSteps {
+			logged = logged[:maxLoggedSteps]
+		}
+		log.Printf("Slowest steps of %s:\n%s", test, stepTable(logged))
+		content, err := json.MarshalIndent(struct {
+			Test  string       `json:"test"`
+			Steps []*stepStats `json:"steps"`
+		}{test, stats}, "", "  ")
+		if err == nil {
+			err = ReplaceOutput(test, stepsSuffix, string(content))
+		}
+		if err != nil {
+			log.Printf("Could not write the steps of %s: %v", test, err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fptest
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestStepTracker(t *testing.T) {
+	var tr stepTracker
+	t0 := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	at := func(s int) time.Time { return t0.Add(time.Duration(s) * time.Second) }
+
+	endTest := tr.start("TestA", "test", at(0))
+	endSetup := tr.start("TestA", "setup", at(0))
+	// Concurrent steps of RunConcurrently are named as subtests, so they
+	// nest under setup rather than under each other.
+	endDUT := tr.start("TestA/configure DUT", "configure DUT", at(0))
+	endATE := tr.start("TestA/push ATE topology", "push ATE topology", at(0))
+	endPush := tr.start("TestA/configure DUT", "push config", at(1))
+	endPush(at(3))
+	endATE(at(4))
+	endDUT(at(5))
+	endSetup(at(6))
+	// Steps of a subtest nest under the open steps of its parent test.
+	for i := 0; i < 2; i++ {
+		end := tr.start("TestA/case", "await", at(10+i*10))
+		end(at(10 + i*10 + 3*(i+1)))
+	}
+	endTest(at(40))
+	tr.start("TestB", "other", at(0))(at(50))
+
+	want := []*stepStats{
+		{Path: "test", Count: 1, Total: 40 * time.Second, Self: 25 * time.Second, Max: 40 * time.Second},
+		{Path: "test/await", Count: 2, Total: 9 * time.Second, Self: 9 * time.Second, Max: 6 * time.Second},
+		{Path: "test/setup", Count: 1, Total: 6 * time.Second, Max: 6 * time.Second},
+		{Path: "test/setup/configure DUT", Count: 1, Total: 5 * time.Second, Self: 3 * time.Second, Max: 5 * time.Second},
+		{Path: "test/setup/push ATE topology", Count: 1, Total: 4 * time.Second, Self: 4 * time.Second, Max: 4 * time.Second},
+		{Path: "test/setup/configure DUT/push config", Count: 1, Total: 2 * time.Second, Self: 2 * time.Second, Max: 2 * time.Second},
+	}
+	if diff := cmp.Diff(want, tr.slowest("TestA")); diff != "" {
+		t.Errorf("slowest(TestA) -want,+got:\n%s", diff)
+	}
+	if got, want := tr.testNames(), []string{"TestA", "TestB"}; !cmp.Equal(got, want) {
+		t.Errorf("testNames() got %v, want %v", got, want)
+	}
+	if got := tr.slowest("TestB"); len(got) != 1 || got[0].Path != "other" {
+		t.Errorf("slowest(TestB) got %v, want only the other step", got)
+	}
+}
+
+func TestStepStatsMarshalJSON(t *testing.T) {
+	s := &stepStats{Path: "a/b", Count: 2, Total: 1500 * time.Microsecond, Self: time.Millisecond, Max: time.Second}
+	b, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("json.Marshal() got error: %v", err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("json.Unmarshal() got error: %v", err)
+	}
+	want := map[string]interface{}{
+		"path":     "a/b",
+		"count":    2.0,
+		"total_ms": 1.5,
+		"self_ms":  1.0,
+		"max_ms":   1000.0,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("MarshalJSON() -want,+got:\n%s", diff)
+	}
+}
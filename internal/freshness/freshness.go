@@ -0,0 +1,191 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package freshness detects stale telemetry, such as cached data a
+// device serves with hours-old timestamps after a restart, which would
+// make verifying it meaningless.  The timestamps of the notifications
+// are compared with the system time of the DUT itself rather than with
+// the clock of the test host, so that skew between the two clocks does
+// not cause false positives.
+package freshness
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ygot/ygot"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+	spb "github.com/openconfig/gnoi/system"
+)
+
+// DefaultMaxAge is how much older than the DUT time the notifications may
+// be if Options.MaxAge is not set.
+const DefaultMaxAge = time.Minute
+
+// Options configure Check.  A nil *Options uses the defaults.
+type Options struct {
+	// MaxAge is how much older than the DUT time when the Get was sent
+	// the notifications may be, and how much newer than the DUT time
+	// when the Get returned.
+	MaxAge time.Duration
+}
+
+func (o *Options) maxAge() time.Duration {
+	if o == nil || o.MaxAge == 0 {
+		return DefaultMaxAge
+	}
+	return o.MaxAge
+}
+
+// Stale is the stalest notification of a Get.
+type Stale struct {
+	Path string
+	// Age is how much older the notification is than the DUT time when
+	// the Get was sent, or negative if it is newer than the DUT time when
+	// the Get returned.
+	Age time.Duration
+}
+
+func (s *Stale) String() string {
+	if s.Age < 0 {
+		return fmt.Sprintf("%s is timestamped %v in the future of the DUT time", s.Path, -s.Age)
+	}
+	return fmt.Sprintf("%s is %v old by the DUT time", s.Path, s.Age)
+}
+
+// notificationPath returns the path of the notification for the
+// report, i.e. its prefix and its first update.
+func notificationPath(n *gpb.Notification) string {
+	elems := append([]*gpb.PathElem{}, n.GetPrefix().GetElem()...)
+	if us := n.GetUpdate(); len(us) > 0 {
+		elems = append(elems, us[0].GetPath().GetElem()...)
+	}
+	s, err := ygot.PathToString(&gpb.Path{Elem: elems})
+	if err != nil {
+		return fmt.Sprintf("<unstringable path: %v>", err)
+	}
+	if len(n.GetUpdate()) > 1 {
+		s += fmt.Sprintf(" and %d more updates", len(n.GetUpdate())-1)
+	}
+	return s
+}
+
+// stalest returns the notification whose timestamp is furthest outside
+// the DUT times from sent to received widened by maxAge, or nil if they
+// are all within them.
+func stalest(notifs []*gpb.Notification, sent, received time.Time, maxAge time.Duration) *Stale {
+	var worst *Stale
+	var worstBy time.Duration
+	for _, n := range notifs {
+		ts := time.Unix(0, n.GetTimestamp())
+		var s *Stale
+		var by time.Duration
+		switch {
+		case ts.Before(sent.Add(-maxAge)):
+			s = &Stale{Age: sent.Sub(ts)}
+			by = s.Age - maxAge
+		case ts.After(received.Add(maxAge)):
+			s = &Stale{Age: received.Sub(ts)}
+			by = -s.Age - maxAge
+		default:
+			continue
+		}
+		if worst == nil || by > worstBy {
+			s.Path = notificationPath(n)
+			worst, worstBy = s, by
+		}
+	}
+	return worst
+}
+
+// DeviceTime returns the system time of the DUT, from gNOI System.Time,
+// or from /system/state/current-datetime through gNMI if the DUT does
+// not support it.
+func DeviceTime(ctx context.Context, t testing.TB, dut *ondatra.DUTDevice) (time.Time, error) {
+	t.Helper()
+	resp, err := dut.RawAPIs().GNOI().Default(t).System().Time(ctx, &spb.TimeRequest{})
+	if err == nil {
+		return time.Unix(0, int64(resp.GetTime())), nil
+	}
+	gresp, gerr := dut.RawAPIs().GNMI().Default(t).Get(ctx, &gpb.GetRequest{
+		Path: []*gpb.Path{{Elem: []*gpb.PathElem{
+			{Name: "system"}, {Name: "state"}, {Name: "current-datetime"},
+		}}},
+		Type:     gpb.GetRequest_STATE,
+		Encoding: gpb.Encoding_JSON_IETF,
+	})
+	if gerr != nil {
+		return time.Time{}, fmt.Errorf("gNOI System.Time failed: %v; gNMI Get of current-datetime failed: %v", err, gerr)
+	}
+	for _, n := range gresp.GetNotification() {
+		for _, u := range n.GetUpdate() {
+			v := u.GetVal().GetStringVal()
+			if b := u.GetVal().GetJsonIetfVal(); b != nil {
+				v = strings.Trim(string(b), `"`)
+			}
+			if ts, err := time.Parse(time.RFC3339, v); err == nil {
+				return ts, nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("gNOI System.Time failed: %v; no current-datetime in gNMI Get", err)
+}
+
+// Check gets the paths through gNMI, and reports a test error with the
+// stalest notification and its age if any notification is timestamped
+// more than opts.MaxAge before the DUT time when the Get was sent, or
+// after the DUT time when it returned.  It returns whether all the
+// notifications are fresh.  opts may be nil.
+func Check(t testing.TB, dut *ondatra.DUTDevice, opts *Options, paths ...ygot.PathStruct) bool {
+	t.Helper()
+	var gpaths []*gpb.Path
+	for _, p := range paths {
+		gp, _, errs := ygot.ResolvePath(p)
+		if len(errs) > 0 {
+			t.Errorf("Cannot resolve path: %v", errs)
+			return false
+		}
+		gpaths = append(gpaths, gp)
+	}
+	ctx := context.Background()
+	sent, err := DeviceTime(ctx, t, dut)
+	if err != nil {
+		t.Errorf("Cannot get the DUT time: %v", err)
+		return false
+	}
+	resp, err := dut.RawAPIs().GNMI().Default(t).Get(ctx, &gpb.GetRequest{
+		Path:     gpaths,
+		Type:     gpb.GetRequest_STATE,
+		Encoding: gpb.Encoding_JSON_IETF,
+	})
+	if err != nil {
+		t.Errorf("gNMI Get failed: %v", err)
+		return false
+	}
+	received, err := DeviceTime(ctx, t, dut)
+	if err != nil {
+		t.Errorf("Cannot get the DUT time: %v", err)
+		return false
+	}
+	if s := stalest(resp.GetNotification(), sent, received, opts.maxAge()); s != nil {
+		t.Errorf("Telemetry is stale, want timestamps within %v of the DUT time: %v", opts.maxAge(), s)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,103 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package freshness
+
+import (
+	"testing"
+	"time"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// notification returns a notification of the leaf at the time.
+func notification(leaf string, ts time.Time) *gpb.Notification {
+	return &gpb.Notification{
+		Timestamp: ts.UnixNano(),
+		Prefix:    &gpb.Path{Elem: []*gpb.PathElem{{Name: "afts"}}},
+		Update: []*gpb.Update{{
+			Path: &gpb.Path{Elem: []*gpb.PathElem{{Name: leaf}}},
+			Val:  &gpb.TypedValue{Value: &gpb.TypedValue_UintVal{UintVal: 1}},
+		}},
+	}
+}
+
+func TestStalest(t *testing.T) {
+	// The DUT clock is hours off the test host clock, which must not
+	// matter.
+	sent := time.Date(2022, 10, 1, 3, 0, 0, 0, time.UTC)
+	received := sent.Add(2 * time.Second)
+	cases := []struct {
+		desc     string
+		notifs   []*gpb.Notification
+		wantPath string
+		wantAge  time.Duration
+	}{{
+		desc: "fresh",
+		notifs: []*gpb.Notification{
+			notification("a", sent.Add(-30*time.Second)),
+			notification("b", received),
+			notification("c", received.Add(30*time.Second)),
+		},
+	}, {
+		desc: "old",
+		notifs: []*gpb.Notification{
+			notification("a", sent.Add(-2*time.Minute)),
+			notification("b", sent.Add(-3*time.Hour)),
+			notification("c", sent),
+		},
+		wantPath: "/afts/b",
+		wantAge:  3 * time.Hour,
+	}, {
+		desc: "future",
+		notifs: []*gpb.Notification{
+			notification("a", received.Add(2*time.Minute)),
+		},
+		wantPath: "/afts/a",
+		wantAge:  -2 * time.Minute,
+	}, {
+		desc: "old beats future by how far outside",
+		notifs: []*gpb.Notification{
+			notification("a", received.Add(2*time.Minute)),
+			notification("b", sent.Add(-3*time.Minute)),
+		},
+		wantPath: "/afts/b",
+		wantAge:  3 * time.Minute,
+	}, {
+		desc:   "no notifications",
+		notifs: nil,
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			got := stalest(c.notifs, sent, received, time.Minute)
+			if c.wantPath == "" {
+				if got != nil {
+					t.Errorf("stalest got %v, want nil", got)
+				}
+				return
+			}
+			if got == nil || got.Path != c.wantPath || got.Age != c.wantAge {
+				t.Errorf("stalest got %v, want %s aged %v", got, c.wantPath, c.wantAge)
+			}
+		})
+	}
+}
+
+func TestNotificationPath(t *testing.T) {
+	n := notification("a", time.Time{})
+	n.Update = append(n.Update, &gpb.Update{Path: &gpb.Path{Elem: []*gpb.PathElem{{Name: "b"}}}})
+	if got, want := notificationPath(n), "/afts/a and 1 more updates"; got != want {
+		t.Errorf("notificationPath got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gribi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	spb "github.com/openconfig/gribi/v1/proto/service"
+	"github.com/openconfig/gribigo/client"
+	"github.com/openconfig/gribigo/fluent"
+)
+
+const (
+	// DefaultAwaitBase is how long Await waits regardless of the number
+	// of operations if AwaitOptions.Base is not set.  It is the longest
+	// deadline the callers used before it grew with the number of
+	// operations, so that none of them waits less.
+	DefaultAwaitBase = 2 * time.Minute
+	// DefaultAwaitPerOp is how much longer Await waits for each
+	// operation if AwaitOptions.PerOp is not set.
+	DefaultAwaitPerOp = 50 * time.Millisecond
+	// maxReportedOps caps the number of operations an Await error
+	// describes, since a scale batch may leave many unacknowledged.
+	maxReportedOps = 10
+)
+
+// AwaitOptions configure Await.  A nil *AwaitOptions uses the defaults.
+type AwaitOptions struct {
+	// Base is how long to wait regardless of the number of operations,
+	// e.g. for the session negotiation.
+	Base time.Duration
+	// PerOp is how much longer to wait for each operation.
+	PerOp time.Duration
+	// FIBACK is whether the session asked for FIB ACKs, so that a
+	// RIB_PROGRAMMED result is not final and is reported by Await as
+	// unacknowledged.  In a session with RIB ACKs only, it is the
+	// expected result.
+	FIBACK bool
+}
+
+// Timeout returns how long Await waits for the results of n operations.
+func (o *AwaitOptions) Timeout(n int) time.Duration {
+	base, perOp := DefaultAwaitBase, DefaultAwaitPerOp
+	if o != nil && o.Base != 0 {
+		base = o.Base
+	}
+	if o != nil && o.PerOp != 0 {
+		perOp = o.PerOp
+	}
+	return base + time.Duration(n)*perOp
+}
+
+// OpIDs tracks the IDs that a fluent client assigns to the operations
+// it sends, 1 for its first operation and one more for each next one,
+// so that the results of the operations a caller sent can be awaited
+// and checked by ID.
+type OpIDs struct {
+	last uint64
+}
+
+// Next returns the IDs of the next n operations sent.
+func (o *OpIDs) Next(n int) []uint64 {
+	ids := make([]uint64, n)
+	for i := range ids {
+		o.last++
+		ids[i] = o.last
+	}
+	return ids
+}
+
+// Seen skips the IDs of the operations that have results, e.g. sent
+// with the fluent client directly rather than counted by Next.
+func (o *OpIDs) Seen(results []*client.OpResult) {
+	for _, r := range results {
+		if r.OperationID > o.last {
+			o.last = r.OperationID
+		}
+	}
+}
+
+// Await waits for the fluent client to converge, i.e. to have the
+// results of all the operations it sent, returning as soon as it does.
+// The deadline grows with the number of operations opIDs the caller
+// awaits, so that a small request fails fast and a scale batch is given
+// enough time.  If the client does not converge in time, the error
+// describes each of the operations opIDs that has no result yet, or
+// only the RIB one if opts.FIBACK is set.
+func Await(ctx context.Context, t testing.TB, c *fluent.GRIBIClient, opts *AwaitOptions, opIDs ...uint64) error {
+	t.Helper()
+	defer fptest.TimeStep(t, "await gRIBI")()
+	timeout := opts.Timeout(len(opIDs))
+	subctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := c.Await(subctx, t)
+	if err == nil {
+		return nil
+	}
+	if desc := unacknowledged(c.Results(t), opIDs, opts != nil && opts.FIBACK); desc != "" {
+		return fmt.Errorf("%w after %v, %s", err, timeout, desc)
+	}
+	return fmt.Errorf("%w after %v", err, timeout)
+}
+
+// unacknowledged describes the operations opIDs that have no results,
+// or, if the session asked for FIB ACKs, only a RIB_PROGRAMMED one,
+// which is not final then.  It returns the empty string if there are
+// none.
+func unacknowledged(results []*client.OpResult, opIDs []uint64, fibACK bool) string {
+	last := make(map[uint64]spb.AFTResult_Status)
+	for _, r := range results {
+		if r.OperationID != 0 {
+			last[r.OperationID] = r.ProgrammingResult
+		}
+	}
+	var descs []string
+	for _, id := range opIDs {
+		switch status, ok := last[id]; {
+		case !ok:
+			descs = append(descs, fmt.Sprintf("operation %d: no result", id))
+		case fibACK && status == spb.AFTResult_RIB_PROGRAMMED:
+			descs = append(descs, fmt.Sprintf("operation %d: %s only", id, status))
+		}
+	}
+	if len(descs) == 0 {
+		return ""
+	}
+	if n := len(descs); n > maxReportedOps {
+		descs = append(descs[:maxReportedOps], fmt.Sprintf("and %d more", n-maxReportedOps))
+	}
+	return strings.Join(descs, "; ")
+}
@@ -0,0 +1,99 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gribi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	spb "github.com/openconfig/gribi/v1/proto/service"
+	"github.com/openconfig/gribigo/client"
+)
+
+func TestAwaitTimeout(t *testing.T) {
+	cases := []struct {
+		desc string
+		opts *AwaitOptions
+		n    int
+		want time.Duration
+	}{
+		{"defaults", nil, 3, DefaultAwaitBase + 3*DefaultAwaitPerOp},
+		{"no operations", nil, 0, DefaultAwaitBase},
+		{"base", &AwaitOptions{Base: time.Second}, 2, time.Second + 2*DefaultAwaitPerOp},
+		{"per op", &AwaitOptions{PerOp: time.Second}, 10000, DefaultAwaitBase + 10000*time.Second},
+		{"both", &AwaitOptions{Base: time.Second, PerOp: time.Millisecond}, 5, 1005 * time.Millisecond},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			if got := c.opts.Timeout(c.n); got != c.want {
+				t.Errorf("Timeout(%d) got %v, want %v", c.n, got, c.want)
+			}
+		})
+	}
+}
+
+func TestUnacknowledged(t *testing.T) {
+	results := []*client.OpResult{
+		{CurrentServerElectionID: &spb.Uint128{Low: 1}},
+		{OperationID: 1, ProgrammingResult: spb.AFTResult_RIB_PROGRAMMED},
+		{OperationID: 1, ProgrammingResult: spb.AFTResult_FIB_PROGRAMMED},
+		{OperationID: 2, ProgrammingResult: spb.AFTResult_RIB_PROGRAMMED},
+		{OperationID: 3, ProgrammingResult: spb.AFTResult_FAILED},
+	}
+	cases := []struct {
+		desc   string
+		opIDs  []uint64
+		fibACK bool
+		want   string
+	}{
+		{"none", nil, true, ""},
+		{"acknowledged", []uint64{1, 3}, true, ""},
+		{"rib only", []uint64{1, 2}, true, "operation 2: RIB_PROGRAMMED only"},
+		{"rib ack session", []uint64{1, 2}, false, ""},
+		{"missing", []uint64{3, 4}, false, "operation 4: no result"},
+		{"capped", []uint64{2, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19}, true,
+			"operation 2: RIB_PROGRAMMED only; operation 10: no result; operation 11: no result; " +
+				"operation 12: no result; operation 13: no result; operation 14: no result; " +
+				"operation 15: no result; operation 16: no result; operation 17: no result; " +
+				"operation 18: no result; and 1 more"},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			if got := unacknowledged(results, c.opIDs, c.fibACK); got != c.want {
+				t.Errorf("unacknowledged(%v, %t) got %q, want %q", c.opIDs, c.fibACK, got, c.want)
+			}
+		})
+	}
+}
+
+func TestOpIDs(t *testing.T) {
+	var o OpIDs
+	if got, want := o.Next(2), []uint64{1, 2}; !cmp.Equal(got, want) {
+		t.Errorf("Next(2) got %v, want %v", got, want)
+	}
+	o.Seen([]*client.OpResult{
+		{CurrentServerElectionID: &spb.Uint128{Low: 1}},
+		{OperationID: 2},
+		{OperationID: 5},
+	})
+	if got, want := o.Next(1), []uint64{6}; !cmp.Equal(got, want) {
+		t.Errorf("Next(1) after results up to 5 got %v, want %v", got, want)
+	}
+	o.Seen([]*client.OpResult{{OperationID: 3}})
+	if got, want := o.Next(1), []uint64{7}; !cmp.Equal(got, want) {
+		t.Errorf("Next(1) after older results got %v, want %v", got, want)
+	}
+}
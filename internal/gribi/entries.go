@@ -0,0 +1,147 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gribi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/openconfig/gribigo/fluent"
+)
+
+// IPv4Prefixes returns count consecutive IPv4 host prefixes (/32)
+// starting at the start address.
+func IPv4Prefixes(start string, count int) ([]string, error) {
+	ip := net.ParseIP(start).To4()
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IPv4 start address %q", start)
+	}
+	first := binary.BigEndian.Uint32(ip)
+	if uint64(first)+uint64(count) > 1<<32 {
+		return nil, fmt.Errorf("%d prefixes starting at %s overflow the IPv4 address space", count, start)
+	}
+	prefixes := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		addr := make(net.IP, net.IPv4len)
+		binary.BigEndian.PutUint32(addr, first+uint32(i))
+		prefixes = append(prefixes, addr.String()+"/32")
+	}
+	return prefixes, nil
+}
+
+// EntryParams describes a set of gRIBI entries to generate.
+//
+// One next hop is created per address in NHAddresses.  NHGCount
+// next-hop-groups are created, assigned to the next hops round-robin,
+// and PrefixCount IPv4 host prefixes starting at StartPrefix are
+// assigned to the next-hop-groups round-robin.
+type EntryParams struct {
+	NetworkInstance string
+	NHAddresses     []string
+	NHIndexStart    uint64
+	NHGCount        int
+	NHGIndexStart   uint64
+	StartPrefix     string
+	PrefixCount     int
+}
+
+// Entries is a set of generated gRIBI entries.  The slices are in
+// the order they should be programmed, so that no entry references
+// another that has not been installed yet.
+type Entries struct {
+	NHs   []fluent.GRIBIEntry
+	NHGs  []fluent.GRIBIEntry
+	IPv4s []fluent.GRIBIEntry
+
+	// Prefixes lists the IPv4 prefixes in the order of IPv4s.
+	Prefixes []string
+	// PrefixNHG maps each IPv4 prefix to its next-hop-group index.
+	PrefixNHG map[string]uint64
+	// NHGNH maps each next-hop-group index to its next hop index.
+	NHGNH map[uint64]uint64
+}
+
+// All returns all the entries in programming order.
+func (e *Entries) All() []fluent.GRIBIEntry {
+	all := make([]fluent.GRIBIEntry, 0, len(e.NHs)+len(e.NHGs)+len(e.IPv4s))
+	all = append(all, e.NHs...)
+	all = append(all, e.NHGs...)
+	all = append(all, e.IPv4s...)
+	return all
+}
+
+// GenerateEntries generates the next hops, next-hop-groups, and IPv4
+// entries described by p.
+func GenerateEntries(p *EntryParams) (*Entries, error) {
+	if len(p.NHAddresses) == 0 {
+		return nil, fmt.Errorf("no next hop addresses")
+	}
+	if p.NHGCount <= 0 {
+		return nil, fmt.Errorf("invalid next-hop-group count %d", p.NHGCount)
+	}
+	prefixes, err := IPv4Prefixes(p.StartPrefix, p.PrefixCount)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Entries{
+		Prefixes:  prefixes,
+		PrefixNHG: make(map[string]uint64),
+		NHGNH:     make(map[uint64]uint64),
+	}
+	for i, addr := range p.NHAddresses {
+		e.NHs = append(e.NHs, fluent.NextHopEntry().
+			WithNetworkInstance(p.NetworkInstance).
+			WithIndex(p.NHIndexStart+uint64(i)).
+			WithIPAddress(addr))
+	}
+	for i := 0; i < p.NHGCount; i++ {
+		nhgIndex := p.NHGIndexStart + uint64(i)
+		nhIndex := p.NHIndexStart + uint64(i%len(p.NHAddresses))
+		e.NHGNH[nhgIndex] = nhIndex
+		e.NHGs = append(e.NHGs, fluent.NextHopGroupEntry().
+			WithNetworkInstance(p.NetworkInstance).
+			WithID(nhgIndex).
+			AddNextHop(nhIndex, 1))
+	}
+	for i, prefix := range prefixes {
+		nhgIndex := p.NHGIndexStart + uint64(i%p.NHGCount)
+		e.PrefixNHG[prefix] = nhgIndex
+		e.IPv4s = append(e.IPv4s, fluent.IPv4Entry().
+			WithNetworkInstance(p.NetworkInstance).
+			WithPrefix(prefix).
+			WithNextHopGroup(nhgIndex))
+	}
+	return e, nil
+}
+
+// Batches splits entries into consecutive batches of at most size
+// entries each.
+func Batches(entries []fluent.GRIBIEntry, size int) [][]fluent.GRIBIEntry {
+	if size <= 0 {
+		size = len(entries)
+	}
+	var batches [][]fluent.GRIBIEntry
+	for len(entries) > 0 {
+		n := size
+		if n > len(entries) {
+			n = len(entries)
+		}
+		batches = append(batches, entries[:n])
+		entries = entries[n:]
+	}
+	return batches
+}
@@ -0,0 +1,146 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gribi
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestIPv4Prefixes(t *testing.T) {
+	cases := []struct {
+		desc    string
+		start   string
+		count   int
+		want    []string
+		wantErr bool
+	}{{
+		desc:  "octet carry",
+		start: "198.18.0.254",
+		count: 4,
+		want:  []string{"198.18.0.254/32", "198.18.0.255/32", "198.18.1.0/32", "198.18.1.1/32"},
+	}, {
+		desc:  "empty",
+		start: "198.18.0.1",
+		count: 0,
+		want:  []string{},
+	}, {
+		desc:    "invalid address",
+		start:   "2001:db8::1",
+		count:   1,
+		wantErr: true,
+	}, {
+		desc:    "overflow",
+		start:   "255.255.255.255",
+		count:   2,
+		wantErr: true,
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			got, err := IPv4Prefixes(c.start, c.count)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("IPv4Prefixes(%q, %d) got error %v, want error: %v", c.start, c.count, err, c.wantErr)
+			}
+			if diff := cmp.Diff(c.want, got); diff != "" {
+				t.Errorf("IPv4Prefixes(%q, %d) returned unexpected diff (-want +got):\n%s", c.start, c.count, diff)
+			}
+		})
+	}
+}
+
+func TestGenerateEntries(t *testing.T) {
+	p := &EntryParams{
+		NetworkInstance: "DEFAULT",
+		NHAddresses:     []string{"192.0.2.2", "192.0.2.6"},
+		NHIndexStart:    1,
+		NHGCount:        3,
+		NHGIndexStart:   10,
+		StartPrefix:     "198.18.0.0",
+		PrefixCount:     5,
+	}
+	e, err := GenerateEntries(p)
+	if err != nil {
+		t.Fatalf("GenerateEntries() got error: %v", err)
+	}
+	if got, want := len(e.NHs), 2; got != want {
+		t.Errorf("len(NHs) got %d, want %d", got, want)
+	}
+	if got, want := len(e.NHGs), 3; got != want {
+		t.Errorf("len(NHGs) got %d, want %d", got, want)
+	}
+	if got, want := len(e.IPv4s), 5; got != want {
+		t.Errorf("len(IPv4s) got %d, want %d", got, want)
+	}
+	if got, want := len(e.All()), 10; got != want {
+		t.Errorf("len(All()) got %d, want %d", got, want)
+	}
+	wantNHGNH := map[uint64]uint64{10: 1, 11: 2, 12: 1}
+	if diff := cmp.Diff(wantNHGNH, e.NHGNH); diff != "" {
+		t.Errorf("NHGNH returned unexpected diff (-want +got):\n%s", diff)
+	}
+	wantPrefixNHG := map[string]uint64{
+		"198.18.0.0/32": 10,
+		"198.18.0.1/32": 11,
+		"198.18.0.2/32": 12,
+		"198.18.0.3/32": 10,
+		"198.18.0.4/32": 11,
+	}
+	if diff := cmp.Diff(wantPrefixNHG, e.PrefixNHG); diff != "" {
+		t.Errorf("PrefixNHG returned unexpected diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestGenerateEntriesErrors(t *testing.T) {
+	cases := []struct {
+		desc string
+		p    *EntryParams
+	}{{
+		desc: "no next hops",
+		p:    &EntryParams{NHGCount: 1, StartPrefix: "198.18.0.0", PrefixCount: 1},
+	}, {
+		desc: "no next-hop-groups",
+		p:    &EntryParams{NHAddresses: []string{"192.0.2.2"}, StartPrefix: "198.18.0.0", PrefixCount: 1},
+	}, {
+		desc: "bad prefix",
+		p:    &EntryParams{NHAddresses: []string{"192.0.2.2"}, NHGCount: 1, StartPrefix: "bad", PrefixCount: 1},
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			if _, err := GenerateEntries(c.p); err == nil {
+				t.Errorf("GenerateEntries() got nil error, want error")
+			}
+		})
+	}
+}
+
+func TestBatches(t *testing.T) {
+	e, err := GenerateEntries(&EntryParams{
+		NHAddresses: []string{"192.0.2.2"},
+		NHGCount:    1,
+		StartPrefix: "198.18.0.0",
+		PrefixCount: 5,
+	})
+	if err != nil {
+		t.Fatalf("GenerateEntries() got error: %v", err)
+	}
+	var got []int
+	for _, b := range Batches(e.All(), 3) {
+		got = append(got, len(b))
+	}
+	if diff := cmp.Diff([]int{3, 3, 1}, got); diff != "" {
+		t.Errorf("Batches() sizes returned unexpected diff (-want +got):\n%s", diff)
+	}
+}
@@ -21,19 +21,18 @@ package gribi
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"testing"
 	"time"
 
+	spb "github.com/openconfig/gribi/v1/proto/service"
 	"github.com/openconfig/gribigo/chk"
 	"github.com/openconfig/gribigo/constants"
 	"github.com/openconfig/gribigo/fluent"
 	"github.com/openconfig/ondatra"
 )
 
-const (
-	timeout = time.Minute
-)
-
 // Client provides access to GRIBI APIs of the DUT.
 //
 // Usage:
@@ -56,6 +55,7 @@ type Client struct {
 
 	// Unexport fields below.
 	fluentC *fluent.GRIBIClient
+	ops     OpIDs
 }
 
 // Fluent resturns the fluent client that can be used to directly call the gribi fluent APIs
@@ -77,6 +77,7 @@ func (c *Client) Start(t testing.TB) error {
 	t.Logf("Starting GRIBI connection for dut: %s", c.DUT.Name())
 	gribiC := c.DUT.RawAPIs().GRIBI().Default(t)
 	c.fluentC = fluent.NewClient()
+	c.ops = OpIDs{}
 	c.fluentC.Connection().WithStub(gribiC)
 	if c.Persistence {
 		c.fluentC.Connection().WithInitialElectionID(c.InitialElectionIDLow, c.InitialElectionIDHigh).
@@ -91,8 +92,7 @@ func (c *Client) Start(t testing.TB) error {
 	ctx := context.Background()
 	c.fluentC.Start(ctx, t)
 	c.fluentC.StartSending(ctx, t)
-	err := c.AwaitTimeout(ctx, t, timeout)
-	return err
+	return Await(ctx, t, c.fluentC, &AwaitOptions{FIBACK: c.FibACK})
 }
 
 // Close function closes the gribi session with the dut by stopping the fluent client.
@@ -105,11 +105,66 @@ func (c *Client) Close(t testing.TB) {
 	}
 }
 
-// AwaitTimeout calls a fluent client Await by adding a timeout to the context.
-func (c *Client) AwaitTimeout(ctx context.Context, t testing.TB, timeout time.Duration) error {
-	subctx, cancel := context.WithTimeout(ctx, timeout)
+// Reconnect closes the current session, if any, waits up to the given
+// timeout for the gRIBI server of the DUT to answer a Get RPC, and then
+// starts a new session with the same parameters.  It is meant to be used
+// after an event that restarts the gRIBI server, such as a process
+// restart or a control processor switchover.
+func (c *Client) Reconnect(t testing.TB, timeout time.Duration) error {
+	t.Helper()
+	c.Close(t)
+	deadline := time.Now().Add(timeout)
+	for {
+		err := c.probe(t)
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("gRIBI server on dut %s not reachable after %v: %w", c.DUT.Name(), timeout, err)
+		}
+		t.Logf("gRIBI server on dut %s not reachable yet: %v", c.DUT.Name(), err)
+		time.Sleep(5 * time.Second)
+	}
+	return c.Start(t)
+}
+
+// probe sends a Get RPC for all network instances, and returns an error
+// if it does not succeed.
+func (c *Client) probe(t testing.TB) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	return c.fluentC.Await(subctx, t)
+	stream, err := c.DUT.RawAPIs().GRIBI().Default(t).Get(ctx, &spb.GetRequest{
+		NetworkInstance: &spb.GetRequest_All{All: &spb.Empty{}},
+		Aft:             spb.AFTType_ALL,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := stream.Recv(); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// AwaitTimeout calls Await with the fluent client, waiting up to the
+// given timeout regardless of the number of operations.
+func (c *Client) AwaitTimeout(ctx context.Context, t testing.TB, timeout time.Duration) error {
+	t.Helper()
+	return Await(ctx, t, c.fluentC, &AwaitOptions{Base: timeout, FIBACK: c.FibACK})
+}
+
+// nextOpIDs returns the IDs of the next n operations the client sends,
+// after those it has results for.
+func (c *Client) nextOpIDs(t testing.TB, n int) []uint64 {
+	c.ops.Seen(c.fluentC.Results(t))
+	return c.ops.Next(n)
+}
+
+// await calls Await with the fluent client for the operations opIDs,
+// so that the deadline grows with the number of operations.
+func (c *Client) await(t testing.TB, opIDs ...uint64) error {
+	t.Helper()
+	return Await(context.Background(), t, c.fluentC, &AwaitOptions{FIBACK: c.FibACK}, opIDs...)
 }
 
 // learnElectionID learns the current server election id by sending
@@ -118,7 +173,7 @@ func (c *Client) learnElectionID(t testing.TB) (low, high uint64) {
 	t.Helper()
 	t.Logf("Learn GRIBI Election ID from dut: %s", c.DUT.Name())
 	c.fluentC.Modify().UpdateElectionID(t, 1, 0)
-	if err := c.AwaitTimeout(context.Background(), t, timeout); err != nil {
+	if err := c.await(t); err != nil {
 		t.Fatalf("Error waiting to update Election ID: %v", err)
 	}
 	results := c.fluentC.Results(t)
@@ -132,7 +187,7 @@ func (c *Client) UpdateElectionID(t testing.TB, lowElecID, highElecID uint64) {
 	t.Helper()
 	t.Logf("Setting GRIBI Election ID for dut: %s to low=%d, high=%d", c.DUT.Name(), lowElecID, highElecID)
 	c.fluentC.Modify().UpdateElectionID(t, lowElecID, highElecID)
-	if err := c.AwaitTimeout(context.Background(), t, timeout); err != nil {
+	if err := c.await(t); err != nil {
 		t.Fatalf("Error waiting to update Election ID: %v", err)
 	}
 	chk.HasResult(t, c.fluentC.Results(t),
@@ -167,8 +222,9 @@ func (c *Client) AddNHG(t testing.TB, nhgIndex uint64, nhWeights map[uint64]uint
 			nhg.WithBackupNHG(opt.BackupNHG)
 		}
 	}
+	opIDs := c.nextOpIDs(t, 1)
 	c.fluentC.Modify().AddEntry(t, nhg)
-	if err := c.AwaitTimeout(context.Background(), t, timeout); err != nil {
+	if err := c.await(t, opIDs...); err != nil {
 		t.Fatalf("Error waiting to add NHG: %v", err)
 	}
 	chk.HasResult(t, c.fluentC.Results(t),
@@ -184,12 +240,13 @@ func (c *Client) AddNHG(t testing.TB, nhgIndex uint64, nhWeights map[uint64]uint
 // AddNH adds a NextHopEntry with a given index to an address within a given network instance.
 func (c *Client) AddNH(t testing.TB, nhIndex uint64, address, instance string, expectedResult fluent.ProgrammingResult) {
 	t.Helper()
+	opIDs := c.nextOpIDs(t, 1)
 	c.fluentC.Modify().AddEntry(t,
 		fluent.NextHopEntry().
 			WithNetworkInstance(instance).
 			WithIndex(nhIndex).
 			WithIPAddress(address))
-	if err := c.AwaitTimeout(context.Background(), t, timeout); err != nil {
+	if err := c.await(t, opIDs...); err != nil {
 		t.Fatalf("Error waiting to add NH: %v", err)
 	}
 	chk.HasResult(t, c.fluentC.Results(t),
@@ -211,8 +268,9 @@ func (c *Client) AddIPv4(t testing.TB, prefix string, nhgIndex uint64, instance,
 	if nhgInstance != "" && nhgInstance != instance {
 		ipv4Entry.WithNextHopGroupNetworkInstance(nhgInstance)
 	}
+	opIDs := c.nextOpIDs(t, 1)
 	c.fluentC.Modify().AddEntry(t, ipv4Entry)
-	if err := c.AwaitTimeout(context.Background(), t, timeout); err != nil {
+	if err := c.await(t, opIDs...); err != nil {
 		t.Fatalf("Error waiting to add IPv4: %v", err)
 	}
 	chk.HasResult(t, c.fluentC.Results(t),
@@ -229,8 +287,9 @@ func (c *Client) AddIPv4(t testing.TB, prefix string, nhgIndex uint64, instance,
 func (c *Client) DeleteIPv4(t testing.TB, prefix string, instance string, expectedResult fluent.ProgrammingResult) {
 	t.Helper()
 	ipv4Entry := fluent.IPv4Entry().WithPrefix(prefix).WithNetworkInstance(instance)
+	opIDs := c.nextOpIDs(t, 1)
 	c.fluentC.Modify().DeleteEntry(t, ipv4Entry)
-	if err := c.AwaitTimeout(context.Background(), t, timeout); err != nil {
+	if err := c.await(t, opIDs...); err != nil {
 		t.Fatalf("Error waiting to delete IPv4: %v", err)
 	}
 	chk.HasResult(t, c.fluentC.Results(t),
@@ -243,6 +302,35 @@ func (c *Client) DeleteIPv4(t testing.TB, prefix string, instance string, expect
 	)
 }
 
+// AddIPv6 adds an IPv6Entry mapping a prefix to a given next hop group index within a given network instance.
+func (c *Client) AddIPv6(t testing.TB, prefix string, nhgIndex uint64, instance, nhgInstance string, expectedResult fluent.ProgrammingResult) {
+	t.Helper()
+	ipv6Entry := NewIPv6Entry().WithPrefix(prefix).
+		WithNetworkInstance(instance).
+		WithNextHopGroup(nhgIndex)
+	if nhgInstance != "" && nhgInstance != instance {
+		ipv6Entry.WithNextHopGroupNetworkInstance(nhgInstance)
+	}
+	opIDs := c.nextOpIDs(t, 1)
+	c.fluentC.Modify().AddEntry(t, ipv6Entry)
+	if err := c.await(t, opIDs...); err != nil {
+		t.Fatalf("Error waiting to add IPv6: %v", err)
+	}
+	HasIPv6Result(t, c.fluentC.Results(t), opIDs[0], prefix, constants.Add, expectedResult)
+}
+
+// DeleteIPv6 deletes an IPv6Entry within a network instance, given the route's prefix
+func (c *Client) DeleteIPv6(t testing.TB, prefix string, instance string, expectedResult fluent.ProgrammingResult) {
+	t.Helper()
+	ipv6Entry := NewIPv6Entry().WithPrefix(prefix).WithNetworkInstance(instance)
+	opIDs := c.nextOpIDs(t, 1)
+	c.fluentC.Modify().DeleteEntry(t, ipv6Entry)
+	if err := c.await(t, opIDs...); err != nil {
+		t.Fatalf("Error waiting to delete IPv6: %v", err)
+	}
+	HasIPv6Result(t, c.fluentC.Results(t), opIDs[0], prefix, constants.Delete, expectedResult)
+}
+
 // Flush flushes all the gribi entries
 func (c *Client) Flush(t testing.TB) {
 	t.Logf("Flush Entries in All Network Instances.")
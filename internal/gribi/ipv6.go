@@ -0,0 +1,113 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gribi
+
+import (
+	"testing"
+
+	"github.com/openconfig/gribigo/client"
+	"github.com/openconfig/gribigo/constants"
+	"github.com/openconfig/gribigo/fluent"
+	"google.golang.org/protobuf/proto"
+
+	aftpb "github.com/openconfig/gribi/v1/proto/gribi_aft"
+	spb "github.com/openconfig/gribi/v1/proto/service"
+	wpb "github.com/openconfig/ygot/proto/ywrapper"
+)
+
+// IPv6Entry builds a gRIBI IPv6Entry like fluent.IPv4Entry does for an
+// IPv4Entry, since the fluent API has no IPv6Entry builder.  It
+// implements fluent.GRIBIEntry, so it can be added or deleted with the
+// fluent Modify API.
+type IPv6Entry struct {
+	ni string
+	pb *aftpb.Afts_Ipv6EntryKey
+}
+
+// NewIPv6Entry returns a new gRIBI IPv6Entry builder.
+func NewIPv6Entry() *IPv6Entry {
+	return &IPv6Entry{
+		pb: &aftpb.Afts_Ipv6EntryKey{
+			Ipv6Entry: &aftpb.Afts_Ipv6Entry{},
+		},
+	}
+}
+
+// WithPrefix sets the prefix of the IPv6Entry.
+func (e *IPv6Entry) WithPrefix(p string) *IPv6Entry {
+	e.pb.Prefix = p
+	return e
+}
+
+// WithNetworkInstance sets the network instance of the IPv6Entry.
+func (e *IPv6Entry) WithNetworkInstance(n string) *IPv6Entry {
+	e.ni = n
+	return e
+}
+
+// WithNextHopGroup sets the next hop group that the IPv6Entry points to.
+func (e *IPv6Entry) WithNextHopGroup(u uint64) *IPv6Entry {
+	e.pb.Ipv6Entry.NextHopGroup = &wpb.UintValue{Value: u}
+	return e
+}
+
+// WithNextHopGroupNetworkInstance sets the network instance in which
+// the next hop group of the IPv6Entry is resolved.
+func (e *IPv6Entry) WithNextHopGroupNetworkInstance(n string) *IPv6Entry {
+	e.pb.Ipv6Entry.NextHopGroupNetworkInstance = &wpb.StringValue{Value: n}
+	return e
+}
+
+// OpProto implements fluent.GRIBIEntry, leaving the ID of the operation
+// to the fluent client.
+func (e *IPv6Entry) OpProto() (*spb.AFTOperation, error) {
+	return &spb.AFTOperation{
+		NetworkInstance: e.ni,
+		Entry: &spb.AFTOperation_Ipv6{
+			Ipv6: proto.Clone(e.pb).(*aftpb.Afts_Ipv6EntryKey),
+		},
+	}, nil
+}
+
+// EntryProto implements fluent.GRIBIEntry.
+func (e *IPv6Entry) EntryProto() (*spb.AFTEntry, error) {
+	return &spb.AFTEntry{
+		NetworkInstance: e.ni,
+		Entry: &spb.AFTEntry_Ipv6{
+			Ipv6: proto.Clone(e.pb).(*aftpb.Afts_Ipv6EntryKey),
+		},
+	}, nil
+}
+
+// HasIPv6Result checks that the results have a result of the operation
+// opID, of type op on the IPv6Entry for the prefix, with the wanted
+// programming result, failing the test if not.  The fluent client does
+// not report the prefix of an IPv6 operation in its results, so they
+// are matched by operation ID and type, and the prefix names the
+// operation in the error.
+func HasIPv6Result(t testing.TB, results []*client.OpResult, opID uint64, prefix string, op constants.OpType, want fluent.ProgrammingResult) {
+	t.Helper()
+	w := fluent.OperationResult().
+		WithOperationID(opID).
+		WithOperationType(op).
+		WithProgrammingResult(want).
+		AsResult()
+	for _, r := range results {
+		if r.OperationID == opID && r.ProgrammingResult == w.ProgrammingResult && r.Details != nil && r.Details.Type == op {
+			return
+		}
+	}
+	t.Fatalf("Results have no %v result of %v of ipv6-entry %s as operation %d, got: %v", w.ProgrammingResult, op, prefix, opID, results)
+}
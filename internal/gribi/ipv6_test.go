@@ -0,0 +1,78 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gribi
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openconfig/gribigo/client"
+	"github.com/openconfig/gribigo/constants"
+	"github.com/openconfig/gribigo/fluent"
+	"github.com/openconfig/testt"
+
+	spb "github.com/openconfig/gribi/v1/proto/service"
+)
+
+func TestIPv6Entry(t *testing.T) {
+	op, err := NewIPv6Entry().
+		WithNetworkInstance("DEFAULT").
+		WithPrefix("2001:db8:1::/64").
+		WithNextHopGroup(10).
+		WithNextHopGroupNetworkInstance("VRF-A").
+		OpProto()
+	if err != nil {
+		t.Fatalf("OpProto got error: %v", err)
+	}
+	if got, want := op.GetNetworkInstance(), "DEFAULT"; got != want {
+		t.Errorf("network instance got %q, want %q", got, want)
+	}
+	e := op.GetIpv6()
+	if got, want := e.GetPrefix(), "2001:db8:1::/64"; got != want {
+		t.Errorf("prefix got %q, want %q", got, want)
+	}
+	if got := e.GetIpv6Entry().GetNextHopGroup().GetValue(); got != 10 {
+		t.Errorf("next hop group got %d, want 10", got)
+	}
+	if got, want := e.GetIpv6Entry().GetNextHopGroupNetworkInstance().GetValue(), "VRF-A"; got != want {
+		t.Errorf("next hop group network instance got %q, want %q", got, want)
+	}
+}
+
+func TestHasIPv6Result(t *testing.T) {
+	results := []*client.OpResult{
+		{OperationID: 1, ProgrammingResult: spb.AFTResult_FIB_PROGRAMMED, Details: &client.OpDetailsResults{Type: constants.Add, NextHopIndex: 1}},
+		{OperationID: 2, ProgrammingResult: spb.AFTResult_FIB_PROGRAMMED, Details: &client.OpDetailsResults{Type: constants.Add}},
+	}
+	HasIPv6Result(t, results, 2, "2001:db8:1::/64", constants.Add, fluent.InstalledInFIB)
+
+	for _, c := range []struct {
+		desc string
+		opID uint64
+		op   constants.OpType
+	}{
+		{"other operation", 3, constants.Add},
+		{"other type", 2, constants.Delete},
+	} {
+		t.Run(c.desc, func(t *testing.T) {
+			msg := testt.ExpectFatal(t, func(t testing.TB) {
+				HasIPv6Result(t, results, c.opID, "2001:db8:1::/64", c.op, fluent.InstalledInFIB)
+			})
+			if !strings.Contains(msg, "ipv6-entry 2001:db8:1::/64") {
+				t.Errorf("HasIPv6Result got error %q, want it to name the prefix", msg)
+			}
+		})
+	}
+}
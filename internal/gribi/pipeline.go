@@ -0,0 +1,572 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gribi
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	spb "github.com/openconfig/gribi/v1/proto/service"
+	"github.com/openconfig/gribigo/client"
+	"github.com/openconfig/gribigo/constants"
+	"github.com/openconfig/gribigo/fluent"
+	"github.com/openconfig/testt"
+)
+
+const (
+	// DefaultPipelineBatchSize is the number of entries per
+	// ModifyRequest if PipelineOptions.BatchSize is not set.
+	DefaultPipelineBatchSize = 200
+	// DefaultPipelineWorkers is the number of goroutines sending batches
+	// if PipelineOptions.Workers is not set.
+	DefaultPipelineWorkers = 4
+	// DefaultMaxInFlight is the number of operations that may be sent
+	// without a final result if PipelineOptions.MaxInFlight is not set.
+	DefaultMaxInFlight = 2000
+	// resultPollInterval is how often the results of the client are
+	// collected while programming.
+	resultPollInterval = 50 * time.Millisecond
+)
+
+// PipelineOptions configure Program.  A nil *PipelineOptions uses the
+// defaults.
+type PipelineOptions struct {
+	// BatchSize is the maximum number of entries per ModifyRequest.
+	BatchSize int
+	// Workers is the number of goroutines composing and sending the
+	// batches.
+	Workers int
+	// MaxInFlight caps the number of operations sent that have no final
+	// result yet.  Batches are cut down to it if they are larger.
+	MaxInFlight int
+	// FIBACK is whether the client requested FIB ACKs, so that every
+	// operation must be FIB_PROGRAMMED rather than RIB_PROGRAMMED.
+	FIBACK bool
+	// Await sizes the deadline of each phase by its number of
+	// operations.
+	Await *AwaitOptions
+}
+
+func (o *PipelineOptions) maxInFlight() int {
+	if o == nil || o.MaxInFlight <= 0 {
+		return DefaultMaxInFlight
+	}
+	return o.MaxInFlight
+}
+
+func (o *PipelineOptions) batchSize() int {
+	size := DefaultPipelineBatchSize
+	if o != nil && o.BatchSize > 0 {
+		size = o.BatchSize
+	}
+	if max := o.maxInFlight(); size > max {
+		size = max
+	}
+	return size
+}
+
+func (o *PipelineOptions) workers() int {
+	if o == nil || o.Workers <= 0 {
+		return DefaultPipelineWorkers
+	}
+	return o.Workers
+}
+
+func (o *PipelineOptions) fibACK() bool {
+	return o != nil && o.FIBACK
+}
+
+func (o *PipelineOptions) await() *AwaitOptions {
+	if o == nil {
+		return nil
+	}
+	return o.Await
+}
+
+// PhaseStats are the statistics of a phase of Program.
+type PhaseStats struct {
+	// Name names the kind of entries of the phase.
+	Name string
+	// Ops is the number of operations of the phase.
+	Ops int
+	// Duration is the time from sending the first operation of the phase
+	// to receiving the final result of the last one.
+	Duration time.Duration
+	// Batches are the statistics of the batches of the phase whose
+	// operations all got their final result, in the order of the
+	// entries.
+	Batches []*BatchStats
+}
+
+// BatchStats are the statistics of a ModifyRequest sent by Program.
+type BatchStats struct {
+	// Ops is the number of operations of the batch.
+	Ops int
+	// Duration is the time from sending the batch to collecting the
+	// final result of the last of its operations, so it is rounded up
+	// to the interval at which the results are collected.
+	Duration time.Duration
+}
+
+// OpsPerSecond returns the throughput of the phase.
+func (s *PhaseStats) OpsPerSecond() float64 {
+	return opsPerSecond(s.Ops, s.Duration)
+}
+
+// PipelineStats are the statistics of Program.
+type PipelineStats struct {
+	// Ops is the number of operations with a final result.
+	Ops int
+	// Duration is the time taken by all the phases.
+	Duration time.Duration
+	// Phases are the statistics of the phases run, in order.
+	Phases []*PhaseStats
+}
+
+// OpsPerSecond returns the throughput of the programming.
+func (s *PipelineStats) OpsPerSecond() float64 {
+	return opsPerSecond(s.Ops, s.Duration)
+}
+
+// opsPerSecond returns the rate of n operations over d, or 0 if d is
+// not positive.
+func opsPerSecond(n int, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(n) / d.Seconds()
+}
+
+// Program adds the entries through the fluent client, which must be
+// leader, and verifies that each of them gets exactly one final result,
+// FIB_PROGRAMMED if opts.FIBACK or else RIB_PROGRAMMED.
+//
+// Rather than awaiting each batch before sending the next one, a pool
+// of workers sends the batches while a collector matches the results,
+// in any order, to the entries they are for, and up to opts.MaxInFlight
+// operations may await their results at any time.  The next hops, the
+// next-hop-groups and the IPv4 entries are programmed in that order,
+// each phase starting once the previous one is fully acknowledged, so
+// that no entry is sent before those it references are installed.
+//
+// The statistics of the phases run are returned even on error.
+func Program(t testing.TB, c *fluent.GRIBIClient, e *Entries, opts *PipelineOptions) (*PipelineStats, error) {
+	t.Helper()
+	defer fptest.TimeStep(t, "program gRIBI")()
+	p := &pipeline{
+		opts: opts,
+		send: func(batch []fluent.GRIBIEntry) error {
+			if msg := testt.CaptureFatal(t, func(t testing.TB) {
+				c.Modify().AddEntry(t, batch...)
+			}); msg != nil {
+				return errors.New(*msg)
+			}
+			return nil
+		},
+		results: func() (res []*client.OpResult, err error) {
+			if msg := testt.CaptureFatal(t, func(t testing.TB) {
+				res = c.Results(t)
+			}); msg != nil {
+				return nil, errors.New(*msg)
+			}
+			return res, nil
+		},
+		poll: resultPollInterval,
+	}
+	return p.run([]*phase{
+		{"next hops", e.NHs},
+		{"next-hop-groups", e.NHGs},
+		{"IPv4 entries", e.IPv4s},
+	})
+}
+
+// phase is a set of entries that may be programmed in any order.
+type phase struct {
+	name    string
+	entries []fluent.GRIBIEntry
+}
+
+// pipeline programs phases of entries with the functions sending a
+// batch and returning all the results of the client so far.
+type pipeline struct {
+	opts    *PipelineOptions
+	send    func([]fluent.GRIBIEntry) error
+	results func() ([]*client.OpResult, error)
+	poll    time.Duration
+}
+
+// run programs the phases in order, stopping at the first that fails.
+func (p *pipeline) run(phases []*phase) (*PipelineStats, error) {
+	res, err := p.results()
+	if err != nil {
+		return &PipelineStats{}, fmt.Errorf("cannot get the results: %w", err)
+	}
+	// Only the results of the operations sent from now on are collected.
+	col := newCollector(p.opts.fibACK(), len(res))
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		col.run(p.results, p.poll, stop)
+	}()
+	defer func() {
+		close(stop)
+		wg.Wait()
+	}()
+
+	stats := &PipelineStats{}
+	start := time.Now()
+	defer func() { stats.Duration = time.Since(start) }()
+	for _, ph := range phases {
+		ps, err := p.runPhase(col, ph)
+		stats.Phases = append(stats.Phases, ps)
+		if err != nil {
+			return stats, fmt.Errorf("%s: %w", ph.name, err)
+		}
+		stats.Ops += ps.Ops
+	}
+	return stats, nil
+}
+
+// runPhase sends the entries of the phase from the pool of workers and
+// waits for all of them to have a final result.
+func (p *pipeline) runPhase(col *collector, ph *phase) (*PhaseStats, error) {
+	ps := &PhaseStats{Name: ph.name, Ops: len(ph.entries)}
+	start := time.Now()
+	defer func() { ps.Duration = time.Since(start) }()
+
+	var keys []opKey
+	for _, e := range ph.entries {
+		k, err := entryKey(e)
+		if err != nil {
+			return ps, err
+		}
+		keys = append(keys, k)
+	}
+	if err := col.expect(keys); err != nil {
+		return ps, err
+	}
+	deadline := start.Add(p.opts.await().Timeout(len(keys)))
+
+	batches := Batches(ph.entries, p.opts.batchSize())
+	// sentAt holds when each batch was sent, each written by a single
+	// worker.
+	sentAt := make([]time.Time, len(batches))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < p.opts.workers(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				b := batches[i]
+				if err := col.acquire(len(b), p.opts.maxInFlight(), deadline); err != nil {
+					col.fail(err)
+					continue
+				}
+				sentAt[i] = time.Now()
+				if err := p.send(b); err != nil {
+					col.fail(fmt.Errorf("cannot send a batch: %w", err))
+				}
+			}
+		}()
+	}
+	for i := range batches {
+		if col.failed() {
+			break
+		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	err := col.await(keys, deadline)
+
+	// The batches are cut from the entries in order, so their keys are
+	// consecutive.
+	off := 0
+	for i, b := range batches {
+		bkeys := keys[off : off+len(b)]
+		off += len(b)
+		if sentAt[i].IsZero() {
+			continue
+		}
+		if done, ok := col.lastDone(bkeys); ok {
+			ps.Batches = append(ps.Batches, &BatchStats{Ops: len(b), Duration: done.Sub(sentAt[i])})
+		}
+	}
+	return ps, err
+}
+
+// opKey identifies the entry of an operation.
+type opKey struct {
+	kind   string
+	id     uint64
+	prefix string
+}
+
+func (k opKey) String() string {
+	if k.kind == "ipv4-entry" {
+		return fmt.Sprintf("%s %s", k.kind, k.prefix)
+	}
+	return fmt.Sprintf("%s %d", k.kind, k.id)
+}
+
+// entryKey returns the key of a next hop, next-hop-group or IPv4 entry.
+func entryKey(e fluent.GRIBIEntry) (opKey, error) {
+	p, err := e.EntryProto()
+	if err != nil {
+		return opKey{}, err
+	}
+	switch {
+	case p.GetNextHop() != nil:
+		return opKey{kind: "next-hop", id: p.GetNextHop().GetIndex()}, nil
+	case p.GetNextHopGroup() != nil:
+		return opKey{kind: "next-hop-group", id: p.GetNextHopGroup().GetId()}, nil
+	case p.GetIpv4() != nil:
+		return opKey{kind: "ipv4-entry", prefix: p.GetIpv4().GetPrefix()}, nil
+	}
+	return opKey{}, fmt.Errorf("unsupported entry %v", p)
+}
+
+// resultKey returns the key of the entry of an operation result.  As
+// the details do not say which kind of entry they are for, a result
+// with an IPv4 prefix is for an IPv4 entry, one with a next-hop-group
+// ID for a next-hop-group, and any other for a next hop.
+func resultKey(d *client.OpDetailsResults) opKey {
+	switch {
+	case d.IPv4Prefix != "":
+		return opKey{kind: "ipv4-entry", prefix: d.IPv4Prefix}
+	case d.NextHopGroupID != 0:
+		return opKey{kind: "next-hop-group", id: d.NextHopGroupID}
+	}
+	return opKey{kind: "next-hop", id: d.NextHopIndex}
+}
+
+// isFinal returns whether a result with the status is the last one of
+// its operation, given whether the client requested FIB ACKs.
+func isFinal(status spb.AFTResult_Status, fibACK bool) bool {
+	switch status {
+	case spb.AFTResult_UNSET:
+		return false
+	case spb.AFTResult_RIB_PROGRAMMED:
+		return !fibACK
+	}
+	return true
+}
+
+// collector matches the results of the operations to the entries they
+// are for, and accounts for the operations in flight.
+type collector struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	want     spb.AFTResult_Status
+	fibACK   bool
+	seen     int
+	inFlight int
+	// expected holds the keys of the entries sent, mapped to whether
+	// they have their final result.
+	expected map[opKey]bool
+	// doneAt holds when the final result of each entry was collected.
+	doneAt map[opKey]time.Time
+	// errs are the unexpected results.
+	errs []string
+	// err stops the programming.
+	err error
+}
+
+// newCollector returns a collector skipping the first seen results.
+func newCollector(fibACK bool, seen int) *collector {
+	c := &collector{
+		want:     spb.AFTResult_RIB_PROGRAMMED,
+		fibACK:   fibACK,
+		seen:     seen,
+		expected: make(map[opKey]bool),
+		doneAt:   make(map[opKey]time.Time),
+	}
+	if fibACK {
+		c.want = spb.AFTResult_FIB_PROGRAMMED
+	}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// run collects the results every interval until stop is closed.
+func (c *collector) run(results func() ([]*client.OpResult, error), interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		res, err := results()
+		c.mu.Lock()
+		if err != nil {
+			c.failLocked(fmt.Errorf("cannot get the results: %w", err))
+		} else {
+			c.updateLocked(res)
+		}
+		// Waiters are woken up at every interval to check their deadline.
+		c.cond.Broadcast()
+		c.mu.Unlock()
+	}
+}
+
+// updateLocked matches the results not seen yet.
+func (c *collector) updateLocked(res []*client.OpResult) {
+	for ; c.seen < len(res); c.seen++ {
+		r := res[c.seen]
+		if r.Details == nil || r.Details.Type != constants.Add || !isFinal(r.ProgrammingResult, c.fibACK) {
+			continue
+		}
+		k := resultKey(r.Details)
+		done, ok := c.expected[k]
+		switch {
+		case !ok:
+			c.errs = append(c.errs, fmt.Sprintf("%v: unexpected %s result for operation %d", k, r.ProgrammingResult, r.OperationID))
+			continue
+		case done:
+			c.errs = append(c.errs, fmt.Sprintf("%v: duplicate %s result for operation %d", k, r.ProgrammingResult, r.OperationID))
+			continue
+		}
+		c.expected[k] = true
+		c.doneAt[k] = time.Now()
+		c.inFlight--
+		if r.ProgrammingResult != c.want {
+			c.errs = append(c.errs, fmt.Sprintf("%v: got %s, want %s", k, r.ProgrammingResult, c.want))
+		}
+	}
+}
+
+// expect registers the keys of the entries about to be sent.
+func (c *collector) expect(keys []opKey) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, k := range keys {
+		if _, ok := c.expected[k]; ok {
+			return fmt.Errorf("%v is programmed more than once", k)
+		}
+		c.expected[k] = false
+	}
+	return nil
+}
+
+// acquire waits for n more operations to be allowed in flight.
+func (c *collector) acquire(n, max int, deadline time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.inFlight+n > max {
+		if c.err != nil {
+			return c.err
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%d operations still in flight at the deadline", c.inFlight)
+		}
+		c.cond.Wait()
+	}
+	c.inFlight += n
+	return nil
+}
+
+// lastDone returns when the last of the keys got its final result, and
+// whether they all did.
+func (c *collector) lastDone(keys []opKey) (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var last time.Time
+	for _, k := range keys {
+		at, ok := c.doneAt[k]
+		if !ok {
+			return time.Time{}, false
+		}
+		if at.After(last) {
+			last = at
+		}
+	}
+	return last, true
+}
+
+// fail stops the programming with the error, unless already stopped.
+func (c *collector) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failLocked(err)
+}
+
+func (c *collector) failLocked(err error) {
+	if c.err == nil {
+		c.err = err
+		c.cond.Broadcast()
+	}
+}
+
+// failed returns whether the programming is stopped.
+func (c *collector) failed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err != nil
+}
+
+// await waits until the deadline, or the programming is stopped, for
+// all the keys to have their final result, and returns an error
+// describing why it stopped and the operations missing a result or
+// with an unexpected one.
+func (c *collector) await(keys []opKey, deadline time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var missing []string
+	for {
+		missing = missing[:0]
+		for _, k := range keys {
+			if !c.expected[k] {
+				missing = append(missing, k.String())
+			}
+		}
+		if len(missing) == 0 || c.err != nil || time.Now().After(deadline) {
+			break
+		}
+		c.cond.Wait()
+	}
+	var descs []string
+	if c.err != nil {
+		descs = append(descs, c.err.Error())
+	}
+	if len(missing) > 0 {
+		descs = append(descs, fmt.Sprintf("%d of %d operations have no final result: %s", len(missing), len(keys), capList(missing)))
+	}
+	if len(c.errs) > 0 {
+		descs = append(descs, fmt.Sprintf("%d unexpected results: %s", len(c.errs), capList(c.errs)))
+	}
+	if len(descs) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(descs, "; "))
+}
+
+// capList joins up to maxReportedOps of the descriptions.
+func capList(descs []string) string {
+	if n := len(descs); n > maxReportedOps {
+		return strings.Join(descs[:maxReportedOps], ", ") + fmt.Sprintf(" and %d more", n-maxReportedOps)
+	}
+	return strings.Join(descs, ", ")
+}
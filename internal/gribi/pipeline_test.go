@@ -0,0 +1,266 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gribi
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	spb "github.com/openconfig/gribi/v1/proto/service"
+	"github.com/openconfig/gribigo/client"
+	"github.com/openconfig/gribigo/constants"
+	"github.com/openconfig/gribigo/fluent"
+)
+
+// fakeServer answers the batches of a pipeline, delivering the results
+// of each poll in reverse order.
+type fakeServer struct {
+	t      *testing.T
+	fibACK bool
+	// status returns the final status of the entry, FIB_PROGRAMMED or
+	// RIB_PROGRAMMED by default.
+	status func(k opKey) spb.AFTResult_Status
+	// drop returns whether the entry gets no result.
+	drop func(k opKey) bool
+	// duplicate returns whether the entry gets its final result twice.
+	duplicate func(k opKey) bool
+
+	mu          sync.Mutex
+	nextID      uint64
+	delivered   []*client.OpResult
+	pending     []*client.OpResult
+	inFlight    int
+	maxInFlight int
+	kinds       map[string]int
+}
+
+func newFakeServer(t *testing.T, fibACK bool) *fakeServer {
+	return &fakeServer{t: t, fibACK: fibACK, kinds: make(map[string]int)}
+}
+
+func (s *fakeServer) result(k opKey, status spb.AFTResult_Status) *client.OpResult {
+	d := &client.OpDetailsResults{Type: constants.Add}
+	switch k.kind {
+	case "next-hop":
+		d.NextHopIndex = k.id
+	case "next-hop-group":
+		d.NextHopGroupID = k.id
+	default:
+		d.IPv4Prefix = k.prefix
+	}
+	return &client.OpResult{OperationID: s.nextID, ProgrammingResult: status, Details: d}
+}
+
+func (s *fakeServer) send(batch []fluent.GRIBIEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range batch {
+		k, err := entryKey(e)
+		if err != nil {
+			return err
+		}
+		// Entries must only be sent once those they reference are
+		// acknowledged.
+		if k.kind == "next-hop-group" && s.kinds["next-hop"] > 0 ||
+			k.kind == "ipv4-entry" && s.kinds["next-hop-group"] > 0 {
+			s.t.Errorf("%v sent before the entries it references are acknowledged", k)
+		}
+		s.nextID++
+		s.inFlight++
+		if s.inFlight > s.maxInFlight {
+			s.maxInFlight = s.inFlight
+		}
+		if s.drop != nil && s.drop(k) {
+			continue
+		}
+		s.kinds[k.kind]++
+		status := spb.AFTResult_RIB_PROGRAMMED
+		if s.fibACK {
+			s.pending = append(s.pending, s.result(k, status))
+			status = spb.AFTResult_FIB_PROGRAMMED
+		}
+		if s.status != nil {
+			status = s.status(k)
+		}
+		s.pending = append(s.pending, s.result(k, status))
+		if s.duplicate != nil && s.duplicate(k) {
+			s.pending = append(s.pending, s.result(k, status))
+		}
+	}
+	return nil
+}
+
+func (s *fakeServer) results() ([]*client.OpResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.pending) - 1; i >= 0; i-- {
+		r := s.pending[i]
+		if isFinal(r.ProgrammingResult, s.fibACK) {
+			k := resultKey(r.Details)
+			s.kinds[k.kind]--
+			s.inFlight--
+		}
+		s.delivered = append(s.delivered, r)
+	}
+	s.pending = nil
+	return append([]*client.OpResult{}, s.delivered...), nil
+}
+
+// testEntries returns 3 next hops, 5 next-hop-groups and 50 IPv4
+// entries.
+func testEntries(t *testing.T) *Entries {
+	t.Helper()
+	e, err := GenerateEntries(&EntryParams{
+		NetworkInstance: "DEFAULT",
+		NHAddresses:     []string{"192.0.2.1", "192.0.2.5", "192.0.2.9"},
+		NHIndexStart:    1,
+		NHGCount:        5,
+		NHGIndexStart:   1,
+		StartPrefix:     "198.18.0.0",
+		PrefixCount:     50,
+	})
+	if err != nil {
+		t.Fatalf("GenerateEntries() got error: %v", err)
+	}
+	return e
+}
+
+// runPipeline programs the entries through the fake server.
+func runPipeline(s *fakeServer, e *Entries, opts *PipelineOptions) (*PipelineStats, error) {
+	p := &pipeline{opts: opts, send: s.send, results: s.results, poll: time.Millisecond}
+	return p.run([]*phase{
+		{"next hops", e.NHs},
+		{"next-hop-groups", e.NHGs},
+		{"IPv4 entries", e.IPv4s},
+	})
+}
+
+func TestProgram(t *testing.T) {
+	for _, fibACK := range []bool{false, true} {
+		t.Run(fmt.Sprintf("FIBACK=%t", fibACK), func(t *testing.T) {
+			s := newFakeServer(t, fibACK)
+			opts := &PipelineOptions{BatchSize: 4, Workers: 3, MaxInFlight: 8, FIBACK: fibACK}
+			stats, err := runPipeline(s, testEntries(t), opts)
+			if err != nil {
+				t.Fatalf("Program() got error: %v", err)
+			}
+			if got, want := stats.Ops, 58; got != want {
+				t.Errorf("Program() got %d operations, want %d", got, want)
+			}
+			var gotOps []int
+			for _, ps := range stats.Phases {
+				gotOps = append(gotOps, ps.Ops)
+			}
+			if got, want := fmt.Sprint(gotOps), "[3 5 50]"; got != want {
+				t.Errorf("Program() got phase operations %s, want %s", got, want)
+			}
+			var gotBatches []int
+			for _, ps := range stats.Phases {
+				for _, bs := range ps.Batches {
+					gotBatches = append(gotBatches, bs.Ops)
+				}
+			}
+			if got, want := len(gotBatches), 1+2+13; got != want {
+				t.Errorf("Program() got %d batches %v, want %d", got, gotBatches, want)
+			}
+			if s.maxInFlight > opts.MaxInFlight {
+				t.Errorf("Program() got %d operations in flight, want at most %d", s.maxInFlight, opts.MaxInFlight)
+			}
+			if stats.OpsPerSecond() <= 0 {
+				t.Errorf("OpsPerSecond() got %v, want > 0", stats.OpsPerSecond())
+			}
+		})
+	}
+}
+
+func TestProgramErrors(t *testing.T) {
+	isPrefix := func(prefix string) func(opKey) bool {
+		return func(k opKey) bool { return k.prefix == prefix }
+	}
+	cases := []struct {
+		desc      string
+		server    func(s *fakeServer)
+		serverFIB bool
+		wantErr   string
+	}{{
+		desc: "failed",
+		server: func(s *fakeServer) {
+			s.status = func(k opKey) spb.AFTResult_Status {
+				if k.prefix == "198.18.0.3/32" {
+					return spb.AFTResult_FIB_FAILED
+				}
+				return spb.AFTResult_FIB_PROGRAMMED
+			}
+		},
+		serverFIB: true,
+		wantErr:   "IPv4 entries: 1 unexpected results: ipv4-entry 198.18.0.3/32: got FIB_FAILED, want FIB_PROGRAMMED",
+	}, {
+		desc:      "missing",
+		server:    func(s *fakeServer) { s.drop = isPrefix("198.18.0.7/32") },
+		serverFIB: true,
+		wantErr:   "IPv4 entries: 1 of 50 operations have no final result: ipv4-entry 198.18.0.7/32",
+	}, {
+		desc:      "duplicate",
+		server:    func(s *fakeServer) { s.duplicate = isPrefix("198.18.0.9/32") },
+		serverFIB: true,
+		wantErr:   "IPv4 entries: 1 unexpected results: ipv4-entry 198.18.0.9/32: duplicate FIB_PROGRAMMED result",
+	}, {
+		desc:    "rib only",
+		wantErr: "next hops: 3 of 3 operations have no final result: next-hop 1, next-hop 2, next-hop 3",
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			s := newFakeServer(t, c.serverFIB)
+			if c.server != nil {
+				c.server(s)
+			}
+			opts := &PipelineOptions{
+				BatchSize: 4,
+				FIBACK:    true,
+				Await:     &AwaitOptions{Base: 200 * time.Millisecond, PerOp: time.Millisecond},
+			}
+			_, err := runPipeline(s, testEntries(t), opts)
+			if err == nil {
+				t.Fatalf("Program() got no error, want %q", c.wantErr)
+			}
+			if !strings.HasPrefix(err.Error(), c.wantErr) {
+				t.Errorf("Program() got error %q, want prefix %q", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestIsFinal(t *testing.T) {
+	cases := []struct {
+		status spb.AFTResult_Status
+		fibACK bool
+		want   bool
+	}{
+		{spb.AFTResult_UNSET, false, false},
+		{spb.AFTResult_RIB_PROGRAMMED, false, true},
+		{spb.AFTResult_RIB_PROGRAMMED, true, false},
+		{spb.AFTResult_FIB_PROGRAMMED, true, true},
+		{spb.AFTResult_FIB_FAILED, true, true},
+		{spb.AFTResult_FAILED, false, true},
+	}
+	for _, c := range cases {
+		if got := isFinal(c.status, c.fibACK); got != c.want {
+			t.Errorf("isFinal(%s, %t) got %t, want %t", c.status, c.fibACK, got, c.want)
+		}
+	}
+}
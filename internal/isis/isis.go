@@ -0,0 +1,321 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package isis provides helpers to form Level-2 IS-IS adjacencies
+// between the DUT and the ATE, for tests that need IS-IS learned routes
+// but are not testing IS-IS itself.
+package isis
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/ixnet"
+	"github.com/openconfig/ondatra/telemetry"
+	"github.com/openconfig/ygot/ygot"
+)
+
+const (
+	// ProtocolName is the name of the IS-IS protocol instance on the DUT.
+	ProtocolName = "ISIS"
+	// AdjacencyTimeout is how long to wait for an adjacency to come up.
+	AdjacencyTimeout = 2 * time.Minute
+)
+
+// NetworkType is the network type of an IS-IS interface.
+type NetworkType int
+
+const (
+	// PointToPoint is a point-to-point IS-IS interface.
+	PointToPoint NetworkType = iota
+	// Broadcast is a broadcast IS-IS interface, electing a DIS.
+	Broadcast
+)
+
+// circuitType returns the OpenConfig circuit type of the network type.
+func (n NetworkType) circuitType() telemetry.E_IsisTypes_CircuitType {
+	if n == Broadcast {
+		return telemetry.IsisTypes_CircuitType_BROADCAST
+	}
+	return telemetry.IsisTypes_CircuitType_POINT_TO_POINT
+}
+
+// DUTConfig builds the DUT IS-IS config with the given area and system
+// ID, e.g. "49.0001" and "1920.0000.2001", with wide metrics and the
+// IPv4 and IPv6 unicast address families enabled.  The named DUT
+// interfaces are enabled at Level 2 with the given network type.
+func DUTConfig(area, systemID string, nt NetworkType, intfs ...string) *telemetry.NetworkInstance_Protocol_Isis {
+	isis := &telemetry.NetworkInstance_Protocol_Isis{}
+	global := isis.GetOrCreateGlobal()
+	global.Net = []string{fmt.Sprintf("%s.%s.00", area, systemID)}
+	global.LevelCapability = telemetry.IsisTypes_LevelType_LEVEL_2
+	global.GetOrCreateAf(telemetry.IsisTypes_AFI_TYPE_IPV4, telemetry.IsisTypes_SAFI_TYPE_UNICAST).Enabled = ygot.Bool(true)
+	global.GetOrCreateAf(telemetry.IsisTypes_AFI_TYPE_IPV6, telemetry.IsisTypes_SAFI_TYPE_UNICAST).Enabled = ygot.Bool(true)
+	isis.GetOrCreateLevel(2).MetricStyle = telemetry.IsisTypes_MetricStyle_WIDE_METRIC
+
+	for _, name := range intfs {
+		intf := isis.GetOrCreateInterface(name)
+		intf.Enabled = ygot.Bool(true)
+		intf.CircuitType = nt.circuitType()
+		intf.GetOrCreateLevel(2).Enabled = ygot.Bool(true)
+	}
+	return isis
+}
+
+// SetHelloTimers sets the Level-2 hello interval in seconds and the hello
+// multiplier of the named DUT interface in the IS-IS config built by
+// DUTConfig.  The hold time advertised to the neighbors is their
+// product.
+func SetHelloTimers(isis *telemetry.NetworkInstance_Protocol_Isis, intf string, interval uint32, multiplier uint8) {
+	timers := isis.GetOrCreateInterface(intf).GetOrCreateLevel(2).GetOrCreateTimers()
+	timers.HelloInterval = ygot.Uint32(interval)
+	timers.HelloMultiplier = ygot.Uint8(multiplier)
+}
+
+// SetHelloAuth enables HMAC-MD5 authentication of the Level-2 hellos of
+// the named DUT interface in the IS-IS config built by DUTConfig, with
+// the given key.
+func SetHelloAuth(isis *telemetry.NetworkInstance_Protocol_Isis, intf, key string) {
+	auth := isis.GetOrCreateInterface(intf).GetOrCreateLevel(2).GetOrCreateHelloAuthentication()
+	auth.Enabled = ygot.Bool(true)
+	auth.AuthType = telemetry.KeychainTypes_AUTH_TYPE_SIMPLE_KEY
+	auth.AuthMode = telemetry.IsisTypes_AUTH_MODE_MD5
+	auth.AuthPassword = ygot.String(key)
+}
+
+// SetHelloKeychain enables authentication of the Level-2 hellos of the
+// named DUT interface in the IS-IS config built by DUTConfig, with the
+// keys of the named keychain, which must be configured on the DUT.
+func SetHelloKeychain(isis *telemetry.NetworkInstance_Protocol_Isis, intf, keychain string) {
+	auth := isis.GetOrCreateInterface(intf).GetOrCreateLevel(2).GetOrCreateHelloAuthentication()
+	auth.Enabled = ygot.Bool(true)
+	auth.AuthType = telemetry.KeychainTypes_AUTH_TYPE_KEYCHAIN
+	auth.Keychain = ygot.String(keychain)
+}
+
+// ConfigureDUT replaces the IS-IS config of the DUT.
+func ConfigureDUT(t testing.TB, dut *ondatra.DUTDevice, isis *telemetry.NetworkInstance_Protocol_Isis) {
+	t.Helper()
+	p := dut.Config().NetworkInstance(*deviations.DefaultNetworkInstance).
+		Protocol(telemetry.PolicyTypes_INSTALL_PROTOCOL_TYPE_ISIS, ProtocolName).Isis()
+	p.Replace(t, isis)
+	fptest.LogYgot(t, "DUT IS-IS", p, isis)
+}
+
+// DeleteDUT removes the IS-IS config from the DUT.
+func DeleteDUT(t testing.TB, dut *ondatra.DUTDevice) {
+	t.Helper()
+	dut.Config().NetworkInstance(*deviations.DefaultNetworkInstance).
+		Protocol(telemetry.PolicyTypes_INSTALL_PROTOCOL_TYPE_ISIS, ProtocolName).Isis().Delete(t)
+}
+
+// Routes is a set of prefixes advertised by an ATE router.
+type Routes struct {
+	// Name is the name of the ATE network advertising the routes.
+	Name string
+	// CIDR is the first IPv4 or IPv6 prefix advertised, and Count the
+	// number of consecutive prefixes of the same length advertised.  A
+	// zero Count advertises CIDR only.
+	CIDR  string
+	Count uint32
+	// Metric is the metric the routes are advertised with.  If zero, the
+	// ATE default is used.
+	Metric uint32
+}
+
+// ATERouter is a Level-2 IS-IS router on an ATE interface, with wide
+// metrics enabled.  The ATE picks its own system ID.
+type ATERouter struct {
+	// ATE is the attributes of the ATE interface.
+	ATE *attrs.Attributes
+	// Area is the area of the router, e.g. "49.0002", and RouterID its
+	// TE router ID, which defaults to the IPv4 address of ATE.
+	Area     string
+	RouterID string
+	// Metric is the metric of the interface.  If zero, the ATE default
+	// is used.
+	Metric uint32
+	// NetworkType is the network type of the interface.
+	NetworkType NetworkType
+	// HelloInterval and HoldTime are the hello interval and the hold
+	// time in seconds of the interface.  If zero, the ATE defaults are
+	// used.
+	HelloInterval, HoldTime uint32
+	// MD5Key, if set, is the key the hellos of the interface are
+	// authenticated with using HMAC-MD5.
+	MD5Key string
+	// Routes are the routes advertised to the DUT.
+	Routes []*Routes
+
+	isis     *ixnet.ISIS
+	networks map[string]*ixnet.Network
+}
+
+// AddToATE adds the IS-IS router and the networks advertising its routes
+// to the ATE interface, which must have been added from r.ATE.
+func (r *ATERouter) AddToATE(t testing.TB, i *ondatra.Interface) {
+	t.Helper()
+	routerID := r.RouterID
+	if routerID == "" {
+		routerID = r.ATE.IPv4
+	}
+	r.isis = i.ISIS().
+		WithAreaID(r.Area).
+		WithTERouterID(routerID).
+		WithWideMetricEnabled(true).
+		WithLevelL2()
+	if r.NetworkType == Broadcast {
+		r.isis.WithNetworkTypeBroadcast()
+	} else {
+		r.isis.WithNetworkTypePointToPoint()
+	}
+	if r.Metric > 0 {
+		r.isis.WithMetric(r.Metric)
+	}
+	if r.HelloInterval > 0 {
+		r.isis.WithHelloInterval(r.HelloInterval)
+	}
+	if r.HoldTime > 0 {
+		r.isis.WithDeadInterval(r.HoldTime)
+	}
+	if r.MD5Key != "" {
+		r.isis.WithAuthMD5(r.MD5Key)
+	}
+
+	r.networks = map[string]*ixnet.Network{}
+	for _, rt := range r.Routes {
+		ip, _, err := net.ParseCIDR(rt.CIDR)
+		if err != nil {
+			t.Fatalf("Cannot advertise routes %s from ATE interface %s: %v", rt.Name, r.ATE.Name, err)
+		}
+		count := rt.Count
+		if count == 0 {
+			count = 1
+		}
+		n := i.AddNetwork(rt.Name)
+		if ip.To4() == nil {
+			n.IPv6().WithAddress(rt.CIDR).WithCount(count)
+		} else {
+			n.IPv4().WithAddress(rt.CIDR).WithCount(count)
+		}
+		n.ISIS().WithActive(true)
+		if rt.Metric > 0 {
+			n.ISIS().WithIPReachabilityMetric(rt.Metric)
+		}
+		r.networks[rt.Name] = n
+	}
+}
+
+// SetMetric changes the metric of the ATE interface while the protocols
+// are running, i.e. the cost of the link from the ATE to the DUT.
+func (r *ATERouter) SetMetric(t testing.TB, top *ondatra.ATETopology, metric uint32) {
+	t.Helper()
+	t.Logf("Setting IS-IS metric of ATE interface %s to %d", r.ATE.Name, metric)
+	r.Metric = metric
+	r.isis.WithMetric(metric)
+	top.Update(t)
+}
+
+// SetMD5Key changes the key the hellos of the ATE interface are
+// authenticated with while the protocols are running.  An empty key
+// disables the authentication.
+func (r *ATERouter) SetMD5Key(t testing.TB, top *ondatra.ATETopology, key string) {
+	t.Helper()
+	t.Logf("Changing the IS-IS hello authentication key of ATE interface %s", r.ATE.Name)
+	r.MD5Key = key
+	if key == "" {
+		r.isis.WithAuthDisabled()
+	} else {
+		r.isis.WithAuthMD5(key)
+	}
+	top.Update(t)
+}
+
+// SetAdvertised withdraws or re-advertises the named routes of the
+// router while the protocols are running.
+func (r *ATERouter) SetAdvertised(t testing.TB, top *ondatra.ATETopology, name string, advertised bool) {
+	t.Helper()
+	n, ok := r.networks[name]
+	if !ok {
+		t.Fatalf("ATE interface %s has no routes %s", r.ATE.Name, name)
+	}
+	t.Logf("Setting IS-IS routes %s of ATE interface %s advertised to %t", name, r.ATE.Name, advertised)
+	n.ISIS().WithActive(advertised)
+	top.UpdateNetworks(t)
+}
+
+// SetRouteMetric changes the metric the named routes of the router are
+// advertised with while the protocols are running, and returns the time
+// the change was pushed to the ATE, from which to measure the DUT
+// convergence.  Unlike SetMetric, it changes the cost of the paths from
+// the DUT to the routes.
+func (r *ATERouter) SetRouteMetric(t testing.TB, top *ondatra.ATETopology, name string, metric uint32) time.Time {
+	t.Helper()
+	n, ok := r.networks[name]
+	if !ok {
+		t.Fatalf("ATE interface %s has no routes %s", r.ATE.Name, name)
+	}
+	for _, rt := range r.Routes {
+		if rt.Name == name {
+			rt.Metric = metric
+		}
+	}
+	t.Logf("Setting IS-IS metric of routes %s of ATE interface %s to %d", name, r.ATE.Name, metric)
+	n.ISIS().WithIPReachabilityMetric(metric)
+	start := time.Now()
+	top.UpdateNetworks(t)
+	return start
+}
+
+// AwaitAdjacency waits for a Level-2 adjacency on the DUT interface to
+// be UP within AdjacencyTimeout, and returns the system IDs of its
+// neighbors.  It fails the test with the adjacency state if the
+// adjacency is not UP in time.
+func AwaitAdjacency(t testing.TB, dut *ondatra.DUTDevice, intf string) []string {
+	t.Helper()
+	ids, err := Adjacency(t, dut, intf, AdjacencyTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+// Adjacency waits for a Level-2 adjacency on the DUT interface to be UP
+// within the timeout, and returns the system IDs of its neighbors, or an
+// error with the adjacency state if the adjacency is not UP in time.
+func Adjacency(t testing.TB, dut *ondatra.DUTDevice, intf string, timeout time.Duration) ([]string, error) {
+	t.Helper()
+	adjPath := dut.Telemetry().NetworkInstance(*deviations.DefaultNetworkInstance).
+		Protocol(telemetry.PolicyTypes_INSTALL_PROTOCOL_TYPE_ISIS, ProtocolName).Isis().
+		Interface(intf).Level(2).AdjacencyAny()
+	_, ok := adjPath.AdjacencyState().Watch(t, timeout, func(val *telemetry.QualifiedE_IsisTypes_IsisInterfaceAdjState) bool {
+		return val.IsPresent() && val.Val(t) == telemetry.IsisTypes_IsisInterfaceAdjState_UP
+	}).Await(t)
+	if !ok {
+		var states []telemetry.E_IsisTypes_IsisInterfaceAdjState
+		for _, q := range adjPath.AdjacencyState().Lookup(t) {
+			states = append(states, q.Val(t))
+		}
+		return nil, fmt.Errorf("IS-IS adjacency on DUT interface %s is not UP within %v, adjacency-state %v", intf, timeout, states)
+	}
+	ids := adjPath.SystemId().Get(t)
+	t.Logf("IS-IS adjacency on DUT interface %s is UP with %v", intf, ids)
+	return ids, nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package isis
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/openconfig/ondatra/telemetry"
+	"github.com/openconfig/ygot/ygot"
+)
+
+func TestDUTConfig(t *testing.T) {
+	cases := []struct {
+		desc string
+		nt   NetworkType
+		want telemetry.E_IsisTypes_CircuitType
+	}{
+		{"point-to-point", PointToPoint, telemetry.IsisTypes_CircuitType_POINT_TO_POINT},
+		{"broadcast", Broadcast, telemetry.IsisTypes_CircuitType_BROADCAST},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			isis := DUTConfig("49.0001", "1920.0000.2001", c.nt, "eth1", "eth2")
+
+			global := isis.GetGlobal()
+			if diff := cmp.Diff([]string{"49.0001.1920.0000.2001.00"}, global.Net); diff != "" {
+				t.Errorf("Net -want,+got:\n%s", diff)
+			}
+			if got, want := global.GetLevelCapability(), telemetry.IsisTypes_LevelType_LEVEL_2; got != want {
+				t.Errorf("Level capability got %v, want %v", got, want)
+			}
+			for _, afi := range []telemetry.E_IsisTypes_AFI_TYPE{telemetry.IsisTypes_AFI_TYPE_IPV4, telemetry.IsisTypes_AFI_TYPE_IPV6} {
+				if !global.GetAf(afi, telemetry.IsisTypes_SAFI_TYPE_UNICAST).GetEnabled() {
+					t.Errorf("AFI %v unicast is not enabled", afi)
+				}
+			}
+			if got, want := isis.GetLevel(2).GetMetricStyle(), telemetry.IsisTypes_MetricStyle_WIDE_METRIC; got != want {
+				t.Errorf("Level 2 metric style got %v, want %v", got, want)
+			}
+			for _, name := range []string{"eth1", "eth2"} {
+				intf := isis.GetInterface(name)
+				if intf == nil {
+					t.Errorf("Interface %s is missing", name)
+					continue
+				}
+				if got := intf.GetCircuitType(); got != c.want {
+					t.Errorf("Interface %s circuit type got %v, want %v", name, got, c.want)
+				}
+				if !intf.GetLevel(2).GetEnabled() {
+					t.Errorf("Interface %s level 2 is not enabled", name)
+				}
+			}
+		})
+	}
+}
+
+func TestSetHelloTimers(t *testing.T) {
+	isis := DUTConfig("49.0001", "1920.0000.2001", PointToPoint, "eth1")
+	SetHelloTimers(isis, "eth1", 3, 4)
+
+	timers := isis.GetInterface("eth1").GetLevel(2).GetTimers()
+	if got, want := timers.GetHelloInterval(), uint32(3); got != want {
+		t.Errorf("Hello interval got %d, want %d", got, want)
+	}
+	if got, want := timers.GetHelloMultiplier(), uint8(4); got != want {
+		t.Errorf("Hello multiplier got %d, want %d", got, want)
+	}
+	if !isis.GetInterface("eth1").GetLevel(2).GetEnabled() {
+		t.Errorf("Interface eth1 level 2 is not enabled")
+	}
+}
+
+func TestSetHelloAuth(t *testing.T) {
+	isis := DUTConfig("49.0001", "1920.0000.2001", PointToPoint, "eth1", "eth2")
+	SetHelloAuth(isis, "eth1", "key1")
+	SetHelloKeychain(isis, "eth2", "keychain1")
+
+	want := map[string]*telemetry.NetworkInstance_Protocol_Isis_Interface_Level_HelloAuthentication{
+		"eth1": {
+			Enabled:      ygot.Bool(true),
+			AuthType:     telemetry.KeychainTypes_AUTH_TYPE_SIMPLE_KEY,
+			AuthMode:     telemetry.IsisTypes_AUTH_MODE_MD5,
+			AuthPassword: ygot.String("key1"),
+		},
+		"eth2": {
+			Enabled:  ygot.Bool(true),
+			AuthType: telemetry.KeychainTypes_AUTH_TYPE_KEYCHAIN,
+			Keychain: ygot.String("keychain1"),
+		},
+	}
+	for name, w := range want {
+		got := isis.GetInterface(name).GetLevel(2).GetHelloAuthentication()
+		if diff := cmp.Diff(w, got); diff != "" {
+			t.Errorf("Interface %s hello authentication -want,+got:\n%s", name, diff)
+		}
+	}
+}
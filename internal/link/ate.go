@@ -0,0 +1,85 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package link
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+)
+
+// Transition is the timing of a change of the state of an ATE port, as
+// observed by the DUT.
+type Transition struct {
+	// Requested is the time the change of the ATE port was requested.
+	Requested time.Time
+	// Observed is the time the oper-status of the DUT port reflected
+	// the change.
+	Observed time.Time
+}
+
+// Delay returns how long the DUT took to observe the change.
+func (tr Transition) Delay() time.Duration {
+	return tr.Observed.Sub(tr.Requested)
+}
+
+// SetATEPort enables or disables the ATE port with the given ID via the
+// ATE API, so that the DUT sees the link come up or go down, and waits
+// for the oper-status of the DUT port with the same ID to become UP or
+// DOWN accordingly.
+func SetATEPort(t *testing.T, ate *ondatra.ATEDevice, dut *ondatra.DUTDevice, id string, enabled bool) Transition {
+	t.Helper()
+	ap, dp := ate.Port(t, id), dut.Port(t, id)
+	t.Logf("Setting ATE port %s enabled to %t", ap.Name(), enabled)
+	var tr Transition
+	tr.Requested = time.Now()
+	ate.Actions().NewSetPortState().WithPort(ap).WithEnabled(enabled).Send(t)
+	tr.Observed = awaitOperStatus(t, dut, dp, enabled, tr.Requested)
+	return tr
+}
+
+// DisableATEPort disables the ATE port with the given ID, and registers
+// a cleanup re-enabling it when the test ends, even if the test fails
+// before re-enabling it.  As with DisableDUTPort, t should be the test
+// owning the port rather than a subtest.
+func DisableATEPort(t *testing.T, ate *ondatra.ATEDevice, dut *ondatra.DUTDevice, id string) Transition {
+	t.Helper()
+	t.Cleanup(func() {
+		if dut.Telemetry().Interface(dut.Port(t, id).Name()).OperStatus().Get(t) != telemetry.Interface_OperStatus_UP {
+			SetATEPort(t, ate, dut, id, true)
+		}
+	})
+	return SetATEPort(t, ate, dut, id, false)
+}
+
+// EnableATEPort enables the ATE port with the given ID.
+func EnableATEPort(t *testing.T, ate *ondatra.ATEDevice, dut *ondatra.DUTDevice, id string) Transition {
+	t.Helper()
+	return SetATEPort(t, ate, dut, id, true)
+}
+
+// FlapATEPort disables the ATE port with the given ID, keeps it down for
+// d after the DUT observed the link go down, and enables it again.  It
+// returns the transitions down and up.
+func FlapATEPort(t *testing.T, ate *ondatra.ATEDevice, dut *ondatra.DUTDevice, id string, d time.Duration) (down, up Transition) {
+	t.Helper()
+	down = DisableATEPort(t, ate, dut, id)
+	time.Sleep(d)
+	up = EnableATEPort(t, ate, dut, id)
+	t.Logf("ATE port %s flapped: DUT saw it down after %v and up after %v", id, down.Delay(), up.Delay())
+	return down, up
+}
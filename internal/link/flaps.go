@@ -0,0 +1,222 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package link
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/onchange"
+	"github.com/openconfig/ondatra"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+var flapWarnOnly = flag.Bool("link_flap_warn_only", false,
+	"Log unexpected DUT port flaps detected by WatchFlaps instead of failing the test.")
+
+// operUp is the oper-status of an interface that is up.
+const operUp = "UP"
+
+// StatusChange is an oper-status update of a DUT port.
+type StatusChange struct {
+	// Port is the ID of the DUT port.
+	Port string
+	Time time.Time
+	// Status is the oper-status, e.g. "UP" or "DOWN".
+	Status string
+	// Initial is set for the oper-status of the port when the watch
+	// started, which is not a transition.
+	Initial bool
+}
+
+func (c *StatusChange) String() string {
+	s := fmt.Sprintf("%s port %s %s", c.Time.Format("15:04:05.000"), c.Port, c.Status)
+	if c.Initial {
+		s += " (initial)"
+	}
+	return s
+}
+
+// FlapWatcher records the oper-status transitions of DUT ports.
+type FlapWatcher struct {
+	ports map[string]string // port IDs by interface name
+	sub   *onchange.Stream
+
+	mu      sync.Mutex
+	changes []*StatusChange
+	allowed map[string]bool
+}
+
+// operStatus returns the interface name and the oper-status of an
+// update of an interface oper-status, or false if it is not one.
+func operStatus(prefix *gpb.Path, u *gpb.Update) (string, string, bool) {
+	elems := append(append([]*gpb.PathElem{}, prefix.GetElem()...), u.GetPath().GetElem()...)
+	if len(elems) != 4 || elems[1].GetName() != "interface" || elems[3].GetName() != "oper-status" {
+		return "", "", false
+	}
+	name := elems[1].GetKey()["name"]
+	v := u.GetVal()
+	status := v.GetStringVal()
+	if b := v.GetJsonIetfVal(); b != nil {
+		status = strings.Trim(string(b), `"`)
+	}
+	// Enums may be qualified with their module name in JSON_IETF.
+	if i := strings.LastIndex(status, ":"); i >= 0 {
+		status = status[i+1:]
+	}
+	return name, status, name != "" && status != ""
+}
+
+// handle records the oper-status updates of the notification, received
+// at the given time.
+func (w *FlapWatcher) handle(n *gpb.Notification, received time.Time, initial bool) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	ts := time.Unix(0, n.GetTimestamp())
+	if n.GetTimestamp() == 0 {
+		ts = received
+	}
+	for _, u := range n.GetUpdate() {
+		name, status, ok := operStatus(n.GetPrefix(), u)
+		if !ok {
+			continue
+		}
+		port, ok := w.ports[name]
+		if !ok {
+			continue
+		}
+		w.changes = append(w.changes, &StatusChange{Port: port, Time: ts, Status: status, Initial: initial})
+	}
+	return nil
+}
+
+// unexpectedDowns returns the changes of the ports, other than the
+// allowed ones, from UP to another oper-status after the watch started.
+// Repeated updates of the same oper-status are not transitions.
+func unexpectedDowns(changes []*StatusChange, allowed map[string]bool) []*StatusChange {
+	last := make(map[string]string)
+	var downs []*StatusChange
+	for _, c := range changes {
+		prev, seen := last[c.Port]
+		last[c.Port] = c.Status
+		if c.Initial || allowed[c.Port] || !seen || prev != operUp || c.Status == operUp {
+			continue
+		}
+		downs = append(downs, c)
+	}
+	return downs
+}
+
+// history lists the changes by port, in time order.
+func history(changes []*StatusChange) string {
+	sorted := append([]*StatusChange{}, changes...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Port != sorted[j].Port {
+			return sorted[i].Port < sorted[j].Port
+		}
+		return sorted[i].Time.Before(sorted[j].Time)
+	})
+	var lines []string
+	for _, c := range sorted {
+		lines = append(lines, "  "+c.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// WatchFlaps starts an ON_CHANGE subscription to the oper-status of the
+// DUT ports with the given IDs, which records every transition until
+// the test ends.  The test then fails if a port went from UP to another
+// oper-status, unless the port was passed to Allow because the test
+// takes it down itself, listing the oper-status history of the ports.
+// With -link_flap_warn_only, the flaps are only logged.  The ports
+// should be up when the watch starts, e.g. after the ATE protocols are
+// started.
+func WatchFlaps(t testing.TB, dut *ondatra.DUTDevice, ids ...string) *FlapWatcher {
+	t.Helper()
+	w := &FlapWatcher{
+		ports:   make(map[string]string),
+		allowed: make(map[string]bool),
+	}
+	var paths []*gpb.Path
+	for _, id := range ids {
+		name := dut.Port(t, id).Name()
+		w.ports[name] = id
+		paths = append(paths, &gpb.Path{Elem: []*gpb.PathElem{
+			{Name: "interfaces"},
+			{Name: "interface", Key: map[string]string{"name": name}},
+			{Name: "state"},
+			{Name: "oper-status"},
+		}})
+	}
+	w.sub = onchange.Open(t, dut.RawAPIs().GNMI().Default(t), fmt.Sprintf("the oper-status of ports %v", ids),
+		OperStatusTimeout, w.handle, paths...)
+
+	t.Cleanup(func() {
+		w.stop()
+		w.check(t)
+	})
+	return w
+}
+
+// Allow excludes the DUT ports with the given IDs from the check, since
+// the test takes them down on purpose.
+func (w *FlapWatcher) Allow(ids ...string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, id := range ids {
+		w.allowed[id] = true
+	}
+}
+
+// Changes returns the oper-status changes recorded so far, in the order
+// received.
+func (w *FlapWatcher) Changes() []*StatusChange {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]*StatusChange{}, w.changes...)
+}
+
+// stop ends the subscription.
+func (w *FlapWatcher) stop() {
+	w.sub.Close()
+}
+
+// check reports the unexpected flaps, with the oper-status history.
+func (w *FlapWatcher) check(t testing.TB) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.sub.Err(); err != nil {
+		t.Logf("Oper-status subscription ended early, later flaps are not detected: %v", err)
+	}
+	downs := unexpectedDowns(w.changes, w.allowed)
+	if len(downs) == 0 {
+		return
+	}
+	var ds []string
+	for _, d := range downs {
+		ds = append(ds, d.String())
+	}
+	report := t.Errorf
+	if *flapWarnOnly {
+		report = t.Logf
+	}
+	report("DUT ports went down unexpectedly: %s\nOper-status history:\n%s", strings.Join(ds, ", "), history(w.changes))
+}
@@ -0,0 +1,127 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package link
+
+import (
+	"testing"
+	"time"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+func TestOperStatus(t *testing.T) {
+	intf := &gpb.Path{Elem: []*gpb.PathElem{
+		{Name: "interfaces"},
+		{Name: "interface", Key: map[string]string{"name": "Ethernet1"}},
+	}}
+	leaf := func(name string) *gpb.Path {
+		return &gpb.Path{Elem: []*gpb.PathElem{{Name: "state"}, {Name: name}}}
+	}
+	cases := []struct {
+		desc       string
+		prefix     *gpb.Path
+		update     *gpb.Update
+		wantStatus string
+		wantOK     bool
+	}{{
+		desc:       "string",
+		prefix:     intf,
+		update:     &gpb.Update{Path: leaf("oper-status"), Val: &gpb.TypedValue{Value: &gpb.TypedValue_StringVal{StringVal: "DOWN"}}},
+		wantStatus: "DOWN",
+		wantOK:     true,
+	}, {
+		desc:       "json ietf",
+		prefix:     intf,
+		update:     &gpb.Update{Path: leaf("oper-status"), Val: &gpb.TypedValue{Value: &gpb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`"openconfig-interfaces:UP"`)}}},
+		wantStatus: "UP",
+		wantOK:     true,
+	}, {
+		desc: "no prefix",
+		update: &gpb.Update{Path: &gpb.Path{Elem: append(append([]*gpb.PathElem{}, intf.Elem...), leaf("oper-status").Elem...)},
+			Val: &gpb.TypedValue{Value: &gpb.TypedValue_StringVal{StringVal: "UP"}}},
+		wantStatus: "UP",
+		wantOK:     true,
+	}, {
+		desc:   "other leaf",
+		prefix: intf,
+		update: &gpb.Update{Path: leaf("admin-status"), Val: &gpb.TypedValue{Value: &gpb.TypedValue_StringVal{StringVal: "UP"}}},
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			name, status, ok := operStatus(c.prefix, c.update)
+			if ok != c.wantOK || status != c.wantStatus {
+				t.Errorf("operStatus got %q, %t, want %q, %t", status, ok, c.wantStatus, c.wantOK)
+			}
+			if ok && name != "Ethernet1" {
+				t.Errorf("operStatus interface got %q, want Ethernet1", name)
+			}
+		})
+	}
+}
+
+func TestUnexpectedDowns(t *testing.T) {
+	t0 := time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)
+	change := func(s int, port, status string, initial bool) *StatusChange {
+		return &StatusChange{Port: port, Time: t0.Add(time.Duration(s) * time.Second), Status: status, Initial: initial}
+	}
+	cases := []struct {
+		desc    string
+		changes []*StatusChange
+		allowed map[string]bool
+		want    int
+	}{{
+		desc: "stable",
+		changes: []*StatusChange{
+			change(0, "port1", "UP", true),
+			change(0, "port2", "UP", true),
+			change(5, "port1", "UP", false),
+		},
+	}, {
+		desc: "flap",
+		changes: []*StatusChange{
+			change(0, "port1", "UP", true),
+			change(5, "port1", "DOWN", false),
+			change(6, "port1", "UP", false),
+			change(7, "port1", "LOWER_LAYER_DOWN", false),
+			change(8, "port1", "DOWN", false),
+		},
+		want: 2,
+	}, {
+		desc: "allowed",
+		changes: []*StatusChange{
+			change(0, "port1", "UP", true),
+			change(5, "port1", "DOWN", false),
+		},
+		allowed: map[string]bool{"port1": true},
+	}, {
+		desc: "initially down comes up",
+		changes: []*StatusChange{
+			change(0, "port1", "DOWN", true),
+			change(5, "port1", "UP", false),
+		},
+	}, {
+		desc: "first update after the watch started",
+		changes: []*StatusChange{
+			change(5, "port1", "DOWN", false),
+		},
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			if got := unexpectedDowns(c.changes, c.allowed); len(got) != c.want {
+				t.Errorf("unexpectedDowns got %v, want %d", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package link provides helpers to take links down and bring them back
+// up in failover tests, making sure that they are restored when the
+// test ends, and to detect links that flap when they should not.
+package link
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+)
+
+// OperStatusTimeout is how long to wait for the oper-status of an
+// interface to reflect a change of its admin state.
+const OperStatusTimeout = time.Minute
+
+// SetDUTPort administratively enables or disables the DUT port via gNMI
+// and waits for its oper-status to become UP or DOWN accordingly.  It
+// returns the time the change was requested.
+func SetDUTPort(t testing.TB, dut *ondatra.DUTDevice, p *ondatra.Port, enabled bool) time.Time {
+	t.Helper()
+	t.Logf("Setting DUT port %s enabled to %t", p.Name(), enabled)
+	start := time.Now()
+	dut.Config().Interface(p.Name()).Enabled().Replace(t, enabled)
+	awaitOperStatus(t, dut, p, enabled, start)
+	return start
+}
+
+// awaitOperStatus waits for the oper-status of the DUT port to become UP
+// if up is set, and DOWN otherwise, and returns the time it was
+// observed.  start is the time the change was requested.
+func awaitOperStatus(t testing.TB, dut *ondatra.DUTDevice, p *ondatra.Port, up bool, start time.Time) time.Time {
+	t.Helper()
+	want := telemetry.Interface_OperStatus_DOWN
+	if up {
+		want = telemetry.Interface_OperStatus_UP
+	}
+	got, ok := dut.Telemetry().Interface(p.Name()).OperStatus().Watch(
+		t, OperStatusTimeout, func(val *telemetry.QualifiedE_Interface_OperStatus) bool {
+			return val.IsPresent() && val.Val(t) == want
+		}).Await(t)
+	if !ok {
+		t.Fatalf("DUT port %s oper-status got %v, want %v", p.Name(), got, want)
+	}
+	observed := time.Now()
+	t.Logf("DUT port %s is %v after %v", p.Name(), want, observed.Sub(start))
+	return observed
+}
+
+// DisableDUTPort administratively disables the DUT port, and registers a
+// cleanup re-enabling it when the test ends, even if the test fails
+// before re-enabling it.  The cleanup runs when t ends, so it should be
+// the test owning the port rather than a subtest.
+func DisableDUTPort(t testing.TB, dut *ondatra.DUTDevice, p *ondatra.Port) time.Time {
+	t.Helper()
+	t.Cleanup(func() {
+		if dut.Telemetry().Interface(p.Name()).OperStatus().Get(t) != telemetry.Interface_OperStatus_UP {
+			SetDUTPort(t, dut, p, true)
+		}
+	})
+	return SetDUTPort(t, dut, p, false)
+}
+
+// EnableDUTPort administratively enables the DUT port.
+func EnableDUTPort(t testing.TB, dut *ondatra.DUTDevice, p *ondatra.Port) time.Time {
+	t.Helper()
+	return SetDUTPort(t, dut, p, true)
+}
@@ -0,0 +1,329 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package onchange collects the updates of an ON_CHANGE gNMI
+// subscription to leaves of the DUT, so that tests can assert on the
+// initial sync, on the updates that follow a change they make, and on
+// the absence of spurious updates.  Its Stream is the ON_CHANGE
+// subscription machinery the more specific collectors build on.
+package onchange
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openconfig/gnmi/value"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ygot/ygot"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// DefaultSyncTimeout is how long Subscribe waits for the sync response.
+const DefaultSyncTimeout = time.Minute
+
+// Update is an update of a leaf received on the subscription.
+type Update struct {
+	// Path is the full path of the leaf, e.g.
+	// "/interfaces/interface[name=Ethernet1]/state/oper-status".
+	Path string
+	Val  *gpb.TypedValue
+	// Timestamp is the timestamp of the notification, and Received
+	// when the test received it.
+	Timestamp time.Time
+	Received  time.Time
+	// Initial is set for the updates received before the sync
+	// response, which report the current values rather than changes.
+	Initial bool
+}
+
+// String returns the value of the update as a string, unquoting JSON
+// strings and dropping the module name qualifying an enum.
+func (u *Update) String() string {
+	return StringVal(u.Val)
+}
+
+// StringVal returns a scalar value as a string, unquoting JSON strings
+// and dropping the module name qualifying an enum, e.g.
+// "openconfig-interfaces:UP" becomes "UP".
+func StringVal(v *gpb.TypedValue) string {
+	var s string
+	switch {
+	case v.GetJsonIetfVal() != nil:
+		s = strings.Trim(string(v.GetJsonIetfVal()), `"`)
+	case v.GetJsonVal() != nil:
+		s = strings.Trim(string(v.GetJsonVal()), `"`)
+	case v.GetStringVal() != "":
+		s = v.GetStringVal()
+	default:
+		// Other scalars, e.g. a uint, are formatted from the value
+		// they hold rather than from their oneof wrapper.
+		val, err := value.ToScalar(v)
+		if err != nil {
+			return v.String()
+		}
+		s = fmt.Sprint(val)
+	}
+	// Enums may be qualified with their module name in JSON_IETF, which
+	// unlike an IPv6 address has a single colon after a hyphenated name.
+	if i := strings.Index(s, ":"); i >= 0 && strings.Count(s, ":") == 1 && strings.Contains(s[:i], "-") {
+		s = s[i+1:]
+	}
+	return s
+}
+
+// Handler handles a notification of a subscription received at the
+// given time.  Initial is set for the notifications received before the
+// sync response, which report the current values rather than changes.
+// An error ends the subscription.
+type Handler func(n *gpb.Notification, received time.Time, initial bool) error
+
+// Stream is an ON_CHANGE gNMI subscription passing the notifications it
+// receives to a Handler.  It is the subscription machinery shared by the
+// collectors of the updates of particular leaves.
+type Stream struct {
+	handle   Handler
+	cancel   context.CancelFunc
+	done     chan struct{}
+	stopOnce sync.Once
+
+	mu     sync.Mutex
+	synced bool
+	syncC  chan struct{}
+	err    error
+}
+
+func newStream(h Handler) *Stream {
+	return &Stream{handle: h, done: make(chan struct{}), syncC: make(chan struct{})}
+}
+
+// receive records the sync response, or passes the notification of the
+// response received at the given time to the handler, with s.mu held.
+func (s *Stream) receive(resp *gpb.SubscribeResponse, received time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if resp.GetSyncResponse() {
+		if !s.synced {
+			s.synced = true
+			close(s.syncC)
+		}
+		return nil
+	}
+	n := resp.GetUpdate()
+	if n == nil {
+		return nil
+	}
+	return s.handle(n, received, !s.synced)
+}
+
+// Open opens an ON_CHANGE subscription to the paths on the gNMI client,
+// passing the notifications it receives to the handler from a
+// goroutine, and returns once the sync response is received, so that
+// the notifications received afterwards are changes.  It fails the test
+// if the subscription cannot be opened or ends before the sync
+// response, or if the sync response is not received within the
+// timeout.  Desc describes the subscribed leaves in the failures, e.g.
+// "the AFT".  Close the stream when done.
+func Open(t testing.TB, c gpb.GNMIClient, desc string, timeout time.Duration, h Handler, paths ...*gpb.Path) *Stream {
+	t.Helper()
+	var subs []*gpb.Subscription
+	for _, p := range paths {
+		subs = append(subs, &gpb.Subscription{Path: p, Mode: gpb.SubscriptionMode_ON_CHANGE})
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	sub, err := c.Subscribe(ctx)
+	if err != nil {
+		cancel()
+		t.Fatalf("Cannot subscribe to %s: %v", desc, err)
+	}
+	if err := sub.Send(&gpb.SubscribeRequest{
+		Request: &gpb.SubscribeRequest_Subscribe{
+			Subscribe: &gpb.SubscriptionList{
+				Subscription: subs,
+				Mode:         gpb.SubscriptionList_STREAM,
+				Encoding:     gpb.Encoding_PROTO,
+			},
+		},
+	}); err != nil {
+		cancel()
+		t.Fatalf("Cannot send the subscribe request of %s: %v", desc, err)
+	}
+
+	s := newStream(h)
+	s.cancel = cancel
+	go func() {
+		defer close(s.done)
+		for {
+			resp, err := sub.Recv()
+			if err == nil {
+				err = s.receive(resp, time.Now())
+			}
+			if err != nil {
+				s.mu.Lock()
+				if ctx.Err() == nil {
+					s.err = err
+				}
+				s.mu.Unlock()
+				return
+			}
+		}
+	}()
+	select {
+	case <-s.syncC:
+	case <-s.done:
+		cancel()
+		t.Fatalf("Subscription to %s ended before the sync response: %v", desc, s.Err())
+	case <-time.After(timeout):
+		s.Close()
+		t.Fatalf("Subscription to %s got no sync response within %v", desc, timeout)
+	}
+	return s
+}
+
+// Close ends the subscription.
+func (s *Stream) Close() {
+	s.stopOnce.Do(s.cancel)
+	<-s.done
+}
+
+// Done returns a channel closed once the subscription ended.
+func (s *Stream) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns the error that ended the subscription before it was
+// closed, if any.
+func (s *Stream) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Collector is an ON_CHANGE gNMI subscription recording the updates it
+// receives.
+type Collector struct {
+	*Stream
+
+	mu      sync.Mutex
+	updates []*Update
+}
+
+// handle records the updates of the notification, received at the given
+// time.
+func (c *Collector) handle(n *gpb.Notification, received time.Time, initial bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ts := time.Unix(0, n.GetTimestamp())
+	if n.GetTimestamp() == 0 {
+		ts = received
+	}
+	for _, u := range n.GetUpdate() {
+		elems := append(append([]*gpb.PathElem{}, n.GetPrefix().GetElem()...), u.GetPath().GetElem()...)
+		path, err := ygot.PathToString(&gpb.Path{Elem: elems})
+		if err != nil {
+			return err
+		}
+		c.updates = append(c.updates, &Update{
+			Path:      path,
+			Val:       u.GetVal(),
+			Timestamp: ts,
+			Received:  received,
+			Initial:   initial,
+		})
+	}
+	return nil
+}
+
+// Subscribe opens an ON_CHANGE subscription to the paths on a new gNMI
+// connection to the DUT, and returns once the DUT has sent the sync
+// response, so that the updates received afterwards are changes.  Close
+// the collector when done.
+func Subscribe(t testing.TB, dut *ondatra.DUTDevice, paths ...*gpb.Path) *Collector {
+	t.Helper()
+	c := &Collector{}
+	c.Stream = Open(t, dut.RawAPIs().GNMI().New(t), fmt.Sprintf("%d paths", len(paths)), DefaultSyncTimeout, c.handle, paths...)
+	return c
+}
+
+// Updates returns the updates received so far, in the order received.
+func (c *Collector) Updates() []*Update {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*Update{}, c.updates...)
+}
+
+// Initial returns the updates received before the sync response, by
+// path.  The last one of a path is its value when the subscription was
+// opened.
+func (c *Collector) Initial() map[string]*Update {
+	m := make(map[string]*Update)
+	for _, u := range c.Updates() {
+		if u.Initial {
+			m[u.Path] = u
+		}
+	}
+	return m
+}
+
+// Changes returns the updates of the path received after the sync
+// response and after the given time, in the order received.
+func (c *Collector) Changes(path string, after time.Time) []*Update {
+	var changes []*Update
+	for _, u := range c.Updates() {
+		if !u.Initial && u.Path == path && u.Received.After(after) {
+			changes = append(changes, u)
+		}
+	}
+	return changes
+}
+
+// Await waits for an update of the path with the given value received
+// after the sync response and after the given time, and returns it, or
+// an error if none is received within the timeout or the subscription
+// ends.
+func (c *Collector) Await(path, val string, after time.Time, timeout time.Duration) (*Update, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		for _, u := range c.Changes(path, after) {
+			if u.String() == val {
+				return u, nil
+			}
+		}
+		if err := c.Err(); err != nil {
+			return nil, fmt.Errorf("subscription ended waiting for %s to be %s: %w", path, val, err)
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s got no update to %s within %v", path, val, timeout)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// NonMonotonic returns an error for each update whose timestamp is
+// before the one of the previous update of the same path.
+func NonMonotonic(updates []*Update) []error {
+	last := make(map[string]time.Time)
+	var errs []error
+	for _, u := range updates {
+		if prev, ok := last[u.Path]; ok && u.Timestamp.Before(prev) {
+			errs = append(errs, fmt.Errorf("%s update to %s timestamped %v, before the previous update at %v", u.Path, u.String(), u.Timestamp, prev))
+		}
+		last[u.Path] = u.Timestamp
+	}
+	return errs
+}
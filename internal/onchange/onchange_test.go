@@ -0,0 +1,138 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package onchange
+
+import (
+	"testing"
+	"time"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+func TestStringVal(t *testing.T) {
+	cases := []struct {
+		desc string
+		val  *gpb.TypedValue
+		want string
+	}{{
+		desc: "string",
+		val:  &gpb.TypedValue{Value: &gpb.TypedValue_StringVal{StringVal: "DOWN"}},
+		want: "DOWN",
+	}, {
+		desc: "json ietf enum",
+		val:  &gpb.TypedValue{Value: &gpb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`"openconfig-interfaces:UP"`)}},
+		want: "UP",
+	}, {
+		desc: "json",
+		val:  &gpb.TypedValue{Value: &gpb.TypedValue_JsonVal{JsonVal: []byte(`"UP"`)}},
+		want: "UP",
+	}, {
+		desc: "ipv6 address",
+		val:  &gpb.TypedValue{Value: &gpb.TypedValue_StringVal{StringVal: "2001:db8::1"}},
+		want: "2001:db8::1",
+	}, {
+		desc: "uint",
+		val:  &gpb.TypedValue{Value: &gpb.TypedValue_UintVal{UintVal: 9000}},
+		want: "9000",
+	}, {
+		desc: "bool",
+		val:  &gpb.TypedValue{Value: &gpb.TypedValue_BoolVal{BoolVal: true}},
+		want: "true",
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			if got := StringVal(c.val); got != c.want {
+				t.Errorf("StringVal got %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestHandle(t *testing.T) {
+	t0 := time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)
+	intf := func(name string) *gpb.Path {
+		return &gpb.Path{Elem: []*gpb.PathElem{
+			{Name: "interfaces"},
+			{Name: "interface", Key: map[string]string{"name": name}},
+		}}
+	}
+	operStatus := &gpb.Path{Elem: []*gpb.PathElem{{Name: "state"}, {Name: "oper-status"}}}
+	update := func(s int, name, status string) *gpb.SubscribeResponse {
+		return &gpb.SubscribeResponse{Response: &gpb.SubscribeResponse_Update{Update: &gpb.Notification{
+			Timestamp: t0.Add(time.Duration(s) * time.Second).UnixNano(),
+			Prefix:    intf(name),
+			Update: []*gpb.Update{{
+				Path: operStatus,
+				Val:  &gpb.TypedValue{Value: &gpb.TypedValue_StringVal{StringVal: status}},
+			}},
+		}}}
+	}
+	syncResp := &gpb.SubscribeResponse{Response: &gpb.SubscribeResponse_SyncResponse{SyncResponse: true}}
+
+	c := &Collector{}
+	c.Stream = newStream(c.handle)
+	for _, resp := range []*gpb.SubscribeResponse{
+		update(0, "Ethernet1", "UP"),
+		update(0, "Ethernet2", "UP"),
+		syncResp,
+		update(1, "Ethernet1", "DOWN"),
+		syncResp,
+		update(2, "Ethernet1", "UP"),
+	} {
+		if err := c.receive(resp, t0); err != nil {
+			t.Fatalf("receive got error: %v", err)
+		}
+	}
+	select {
+	case <-c.syncC:
+	default:
+		t.Errorf("receive did not signal the sync response")
+	}
+
+	const path1 = "/interfaces/interface[name=Ethernet1]/state/oper-status"
+	const path2 = "/interfaces/interface[name=Ethernet2]/state/oper-status"
+	initial := c.Initial()
+	if len(initial) != 2 || initial[path1].String() != "UP" || initial[path2].String() != "UP" {
+		t.Errorf("Initial got %v, want UP for %s and %s", initial, path1, path2)
+	}
+	var got []string
+	for _, u := range c.Changes(path1, time.Time{}) {
+		got = append(got, u.String())
+	}
+	if len(got) != 2 || got[0] != "DOWN" || got[1] != "UP" {
+		t.Errorf("Changes of %s got %v, want [DOWN UP]", path1, got)
+	}
+	if changes := c.Changes(path2, time.Time{}); len(changes) != 0 {
+		t.Errorf("Changes of %s got %d updates, want none", path2, len(changes))
+	}
+	if errs := NonMonotonic(c.Updates()); len(errs) != 0 {
+		t.Errorf("NonMonotonic got %v, want none", errs)
+	}
+}
+
+func TestNonMonotonic(t *testing.T) {
+	t0 := time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)
+	up := &gpb.TypedValue{Value: &gpb.TypedValue_StringVal{StringVal: "UP"}}
+	updates := []*Update{
+		{Path: "/a", Val: up, Timestamp: t0.Add(2 * time.Second)},
+		{Path: "/b", Val: up, Timestamp: t0},
+		{Path: "/a", Val: up, Timestamp: t0.Add(time.Second)},
+		{Path: "/a", Val: up, Timestamp: t0.Add(time.Second)},
+		{Path: "/b", Val: up, Timestamp: t0.Add(time.Second)},
+	}
+	if errs := NonMonotonic(updates); len(errs) != 1 {
+		t.Errorf("NonMonotonic got %v, want 1 error for the third update", errs)
+	}
+}
@@ -0,0 +1,294 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package p4rtutils provides helpers for P4Runtime tests: discovering
+// the P4RT node of a DUT port from the components telemetry, installing
+// a forwarding pipeline, and driving the stream channel of a P4RT
+// client for primary arbitration and packet IO.
+package p4rtutils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+	"github.com/openconfig/ygot/ygot"
+	"google.golang.org/grpc/codes"
+
+	p4infopb "github.com/p4lang/p4runtime/go/p4/config/v1"
+	p4pb "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+// NodeIDTimeout is how long the DUT may take to report a node-id
+// configured by DeviceID.
+const NodeIDTimeout = 30 * time.Second
+
+// maxDepth bounds the walk from the component of a port up to its
+// integrated circuit, in case the parents of the components loop.
+const maxDepth = 10
+
+// ancestor returns the first of the component and its ancestors that
+// matches, following parent.
+func ancestor(name string, parent func(string) (string, bool), match func(string) bool) (string, error) {
+	start := name
+	for i := 0; i < maxDepth; i++ {
+		if match(name) {
+			return name, nil
+		}
+		p, ok := parent(name)
+		if !ok {
+			return "", fmt.Errorf("no integrated circuit above component %q, %q has no parent", start, name)
+		}
+		name = p
+	}
+	return "", fmt.Errorf("no integrated circuit within %d levels above component %q", maxDepth, start)
+}
+
+// IntegratedCircuit returns the name of the integrated circuit component
+// the DUT port belongs to, the P4RT node of the port, found from the
+// hardware port of the interface up through the component parents.
+func IntegratedCircuit(t testing.TB, dut *ondatra.DUTDevice, p *ondatra.Port) string {
+	t.Helper()
+	hwPort := dut.Telemetry().Interface(p.Name()).HardwarePort().Get(t)
+	parent := func(c string) (string, bool) {
+		v := dut.Telemetry().Component(c).Parent().Lookup(t)
+		if !v.IsPresent() {
+			return "", false
+		}
+		return v.Val(t), true
+	}
+	isIC := func(c string) bool {
+		v := dut.Telemetry().Component(c).Type().Lookup(t)
+		return v.IsPresent() && v.Val(t) == telemetry.PlatformTypes_OPENCONFIG_HARDWARE_COMPONENT_INTEGRATED_CIRCUIT
+	}
+	ic, err := ancestor(hwPort, parent, isIC)
+	if err != nil {
+		t.Fatalf("Cannot find the P4RT node of DUT port %s: %v", p.Name(), err)
+	}
+	return ic
+}
+
+// NodeID returns the P4RT node-id of the integrated circuit, the device
+// ID of P4RT requests to it, or false if it has none.
+func NodeID(t testing.TB, dut *ondatra.DUTDevice, ic string) (uint64, bool) {
+	t.Helper()
+	v := dut.Telemetry().Component(ic).IntegratedCircuit().NodeId().Lookup(t)
+	if !v.IsPresent() {
+		return 0, false
+	}
+	return v.Val(t), true
+}
+
+// DeviceID returns the node-id of the integrated circuit of the DUT
+// port, the device ID of P4RT requests for the port, configuring the
+// fallback node-id on it if it has none.
+func DeviceID(t testing.TB, dut *ondatra.DUTDevice, p *ondatra.Port, fallback uint64) uint64 {
+	t.Helper()
+	ic := IntegratedCircuit(t, dut, p)
+	if id, ok := NodeID(t, dut, ic); ok {
+		t.Logf("DUT port %s is on P4RT node %s with node-id %d", p.Name(), ic, id)
+		return id
+	}
+	t.Logf("Configure node-id %d on P4RT node %s of DUT port %s", fallback, ic, p.Name())
+	c := &telemetry.Component{Name: ygot.String(ic)}
+	c.GetOrCreateIntegratedCircuit().NodeId = ygot.Uint64(fallback)
+	dut.Config().Component(ic).Update(t, c)
+	dut.Telemetry().Component(ic).IntegratedCircuit().NodeId().Await(t, NodeIDTimeout, fallback)
+	return fallback
+}
+
+// LoadP4Info reads a P4Info in text format.
+func LoadP4Info(path string) (*p4infopb.P4Info, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	info := &p4infopb.P4Info{}
+	// P4Info is a golang/protobuf message, so it is parsed with the
+	// golang/protobuf text parser.
+	if err := proto.UnmarshalText(string(b), info); err != nil {
+		return nil, fmt.Errorf("cannot parse P4Info %s: %w", path, err)
+	}
+	return info, nil
+}
+
+// SetPipeline verifies and commits the P4Info as the forwarding pipeline
+// of the device, with the cookie.  The client must be the primary with
+// the election ID.
+func SetPipeline(c p4pb.P4RuntimeClient, deviceID uint64, electionID *p4pb.Uint128, info *p4infopb.P4Info, cookie uint64) error {
+	_, err := c.SetForwardingPipelineConfig(context.Background(), &p4pb.SetForwardingPipelineConfigRequest{
+		DeviceId:   deviceID,
+		ElectionId: electionID,
+		Action:     p4pb.SetForwardingPipelineConfigRequest_VERIFY_AND_COMMIT,
+		Config: &p4pb.ForwardingPipelineConfig{
+			P4Info: info,
+			Cookie: &p4pb.ForwardingPipelineConfig_Cookie{Cookie: cookie},
+		},
+	})
+	return err
+}
+
+// Metadata returns the value of the packet metadata with the ID, or
+// false if there is none.
+func Metadata(md []*p4pb.PacketMetadata, id uint32) ([]byte, bool) {
+	for _, m := range md {
+		if m.GetMetadataId() == id {
+			return m.GetValue(), true
+		}
+	}
+	return nil, false
+}
+
+// ElectionID returns the election ID of the low 64 bits.
+func ElectionID(low uint64) *p4pb.Uint128 {
+	return &p4pb.Uint128{High: 0, Low: low}
+}
+
+// IsPrimary reports whether the arbitration update tells the client it
+// is the primary.
+func IsPrimary(u *p4pb.MasterArbitrationUpdate) bool {
+	return codes.Code(u.GetStatus().GetCode()) == codes.OK
+}
+
+// Stream is the stream channel of a P4RT client, whose messages are
+// received in the background.
+type Stream struct {
+	stream p4pb.P4Runtime_StreamChannelClient
+	cancel context.CancelFunc
+	msgs   chan *p4pb.StreamMessageResponse
+	done   chan struct{}
+	err    error // set before done is closed
+}
+
+// NewStream opens the stream channel of the P4RT client.  Close the
+// stream when done, which ends the arbitration of the client.
+func NewStream(c p4pb.P4RuntimeClient) (*Stream, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := c.StreamChannel(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	s := &Stream{
+		stream: stream,
+		cancel: cancel,
+		msgs:   make(chan *p4pb.StreamMessageResponse, 1000),
+		done:   make(chan struct{}),
+	}
+	go func() {
+		defer close(s.done)
+		for {
+			msg, err := stream.Recv()
+			if err != nil {
+				s.err = err
+				return
+			}
+			select {
+			case s.msgs <- msg:
+			case <-ctx.Done():
+				s.err = ctx.Err()
+				return
+			}
+		}
+	}()
+	return s, nil
+}
+
+// Close ends the stream.
+func (s *Stream) Close() {
+	s.cancel()
+	<-s.done
+}
+
+// Arbitrate sends an arbitration update for the device with the
+// election ID and the default role.
+func (s *Stream) Arbitrate(deviceID uint64, electionID *p4pb.Uint128) error {
+	return s.stream.Send(&p4pb.StreamMessageRequest{
+		Update: &p4pb.StreamMessageRequest_Arbitration{
+			Arbitration: &p4pb.MasterArbitrationUpdate{
+				DeviceId:   deviceID,
+				ElectionId: electionID,
+			},
+		},
+	})
+}
+
+// Recv returns the next message received on the stream, or an error if
+// none is received within the timeout or the stream ended.
+func (s *Stream) Recv(timeout time.Duration) (*p4pb.StreamMessageResponse, error) {
+	select {
+	case msg := <-s.msgs:
+		return msg, nil
+	case <-s.done:
+		select {
+		case msg := <-s.msgs:
+			return msg, nil
+		default:
+		}
+		return nil, fmt.Errorf("stream ended: %w", s.err)
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("no message received within %v", timeout)
+	}
+}
+
+// AwaitArbitration returns the next arbitration update received on the
+// stream within the timeout, discarding the other messages, e.g. packets.
+func (s *Stream) AwaitArbitration(timeout time.Duration) (*p4pb.MasterArbitrationUpdate, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		msg, err := s.Recv(time.Until(deadline))
+		if err != nil {
+			return nil, fmt.Errorf("no arbitration update: %w", err)
+		}
+		if u := msg.GetArbitration(); u != nil {
+			return u, nil
+		}
+	}
+}
+
+// PacketOut sends the packet out of the device.
+func (s *Stream) PacketOut(pkt *p4pb.PacketOut) error {
+	return s.stream.Send(&p4pb.StreamMessageRequest{
+		Update: &p4pb.StreamMessageRequest_Packet{Packet: pkt},
+	})
+}
+
+// PacketIns returns the packets received on the stream for the
+// duration, discarding the other messages, e.g. arbitration updates.
+// It returns the packets received so far and an error if the stream
+// ends.
+func (s *Stream) PacketIns(d time.Duration) ([]*p4pb.PacketIn, error) {
+	var pkts []*p4pb.PacketIn
+	deadline := time.Now().Add(d)
+	for {
+		select {
+		case msg := <-s.msgs:
+			if pkt := msg.GetPacket(); pkt != nil {
+				pkts = append(pkts, pkt)
+			}
+		case <-s.done:
+			if len(s.msgs) > 0 {
+				continue
+			}
+			return pkts, fmt.Errorf("stream ended: %w", s.err)
+		case <-time.After(time.Until(deadline)):
+			return pkts, nil
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package p4rtutils
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/codes"
+
+	p4pb "github.com/p4lang/p4runtime/go/p4/v1"
+	statuspb "google.golang.org/genproto/googleapis/rpc/status"
+)
+
+func TestAncestor(t *testing.T) {
+	parents := map[string]string{
+		"Ethernet1":   "Linecard1",
+		"Linecard1":   "Chassis",
+		"Ethernet2":   "SwitchChip2",
+		"SwitchChip2": "Linecard1",
+		"LoopA":       "LoopB",
+		"LoopB":       "LoopA",
+	}
+	parent := func(c string) (string, bool) {
+		p, ok := parents[c]
+		return p, ok
+	}
+	isIC := func(c string) bool { return c == "SwitchChip2" }
+
+	cases := []struct {
+		desc    string
+		start   string
+		want    string
+		wantErr bool
+	}{{
+		desc:  "parent",
+		start: "Ethernet2",
+		want:  "SwitchChip2",
+	}, {
+		desc:  "itself",
+		start: "SwitchChip2",
+		want:  "SwitchChip2",
+	}, {
+		desc:    "no integrated circuit",
+		start:   "Ethernet1",
+		wantErr: true,
+	}, {
+		desc:    "loop",
+		start:   "LoopA",
+		wantErr: true,
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			got, err := ancestor(c.start, parent, isIC)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("ancestor got error %v, want error %t", err, c.wantErr)
+			}
+			if got != c.want {
+				t.Errorf("ancestor got %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestIsPrimary(t *testing.T) {
+	cases := []struct {
+		desc string
+		code codes.Code
+		want bool
+	}{
+		{desc: "primary", code: codes.OK, want: true},
+		{desc: "other primary", code: codes.AlreadyExists},
+		{desc: "no primary", code: codes.NotFound},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			u := &p4pb.MasterArbitrationUpdate{Status: &statuspb.Status{Code: int32(c.code)}}
+			if got := IsPrimary(u); got != c.want {
+				t.Errorf("IsPrimary got %t, want %t", got, c.want)
+			}
+		})
+	}
+}
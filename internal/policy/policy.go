@@ -0,0 +1,169 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package policy builds OpenConfig routing policies from prefix sets
+// and policy definitions, so that tests applying policies to routing
+// protocols configure them the same way.
+package policy
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+	"github.com/openconfig/ygot/ygot"
+)
+
+// ExactMaskLength is the mask length range of a prefix that only
+// matches prefixes of its own length.
+const ExactMaskLength = "exact"
+
+// PrefixSet is a named set of prefixes matched by policy statements.
+type PrefixSet struct {
+	Name string
+	// Prefixes are the IPv4 or IPv6 prefixes of the set.
+	Prefixes []string
+	// MaskLengthRange is the range of lengths of the prefixes matched by
+	// each prefix of the set, e.g. "24..28".  If empty, ExactMaskLength
+	// is used.
+	MaskLengthRange string
+}
+
+// mode returns the mode of the prefix set from the address families of
+// its prefixes.
+func (s *PrefixSet) mode() (telemetry.E_PrefixSet_Mode, error) {
+	var v4, v6 bool
+	for _, p := range s.Prefixes {
+		ip, _, err := net.ParseCIDR(p)
+		if err != nil {
+			return telemetry.PrefixSet_Mode_UNSET, fmt.Errorf("prefix set %s: %w", s.Name, err)
+		}
+		if ip.To4() != nil {
+			v4 = true
+		} else {
+			v6 = true
+		}
+	}
+	switch {
+	case v4 && v6:
+		return telemetry.PrefixSet_Mode_MIXED, nil
+	case v6:
+		return telemetry.PrefixSet_Mode_IPV6, nil
+	default:
+		return telemetry.PrefixSet_Mode_IPV4, nil
+	}
+}
+
+// Statement is a statement of a policy definition.
+type Statement struct {
+	// Name is the name of the statement.  If empty, the statement is
+	// named after its position in the definition: "10", "20" and so on.
+	Name string
+	// PrefixSet is the name of the prefix set the routes must match for
+	// the statement to apply, or all routes match if empty.  Invert
+	// applies the statement to the routes not in the prefix set instead.
+	PrefixSet string
+	Invert    bool
+	// Result is the action taken on the matching routes.
+	Result telemetry.E_RoutingPolicy_PolicyResultType
+}
+
+// Definition is a named policy definition, whose statements are
+// evaluated in order.
+type Definition struct {
+	Name       string
+	Statements []*Statement
+}
+
+// AcceptAll returns a definition accepting all routes.
+func AcceptAll(name string) *Definition {
+	return &Definition{
+		Name:       name,
+		Statements: []*Statement{{Result: telemetry.RoutingPolicy_PolicyResultType_ACCEPT_ROUTE}},
+	}
+}
+
+// RejectAll returns a definition rejecting all routes.
+func RejectAll(name string) *Definition {
+	return &Definition{
+		Name:       name,
+		Statements: []*Statement{{Result: telemetry.RoutingPolicy_PolicyResultType_REJECT_ROUTE}},
+	}
+}
+
+// Build builds the routing policy with the prefix sets and the policy
+// definitions.  It returns an error if a prefix is invalid, or if a
+// statement matches a prefix set that is not defined.
+func Build(sets []*PrefixSet, defs []*Definition) (*telemetry.RoutingPolicy, error) {
+	rp := &telemetry.RoutingPolicy{}
+	ds := rp.GetOrCreateDefinedSets()
+	for _, s := range sets {
+		mode, err := s.mode()
+		if err != nil {
+			return nil, err
+		}
+		ps := ds.GetOrCreatePrefixSet(s.Name)
+		ps.Mode = mode
+		mlr := s.MaskLengthRange
+		if mlr == "" {
+			mlr = ExactMaskLength
+		}
+		for _, p := range s.Prefixes {
+			ps.GetOrCreatePrefix(p, mlr)
+		}
+	}
+
+	for _, d := range defs {
+		pd := rp.GetOrCreatePolicyDefinition(d.Name)
+		for i, s := range d.Statements {
+			name := s.Name
+			if name == "" {
+				name = strconv.Itoa(10 * (i + 1))
+			}
+			st := pd.GetOrCreateStatement(name)
+			st.GetOrCreateActions().PolicyResult = s.Result
+			if s.PrefixSet == "" {
+				continue
+			}
+			if ds.GetPrefixSet(s.PrefixSet) == nil {
+				return nil, fmt.Errorf("policy %s statement %s: prefix set %s is not defined", d.Name, name, s.PrefixSet)
+			}
+			mps := st.GetOrCreateConditions().GetOrCreateMatchPrefixSet()
+			mps.PrefixSet = ygot.String(s.PrefixSet)
+			mps.MatchSetOptions = telemetry.PolicyTypes_MatchSetOptionsRestrictedType_ANY
+			if s.Invert {
+				mps.MatchSetOptions = telemetry.PolicyTypes_MatchSetOptionsRestrictedType_INVERT
+			}
+		}
+	}
+	return rp, nil
+}
+
+// Configure replaces the routing policy of the DUT.
+func Configure(t testing.TB, dut *ondatra.DUTDevice, rp *telemetry.RoutingPolicy) {
+	t.Helper()
+	p := dut.Config().RoutingPolicy()
+	p.Replace(t, rp)
+	fptest.LogYgot(t, "DUT routing policy", p, rp)
+}
+
+// Delete removes the routing policy from the DUT.
+func Delete(t testing.TB, dut *ondatra.DUTDevice) {
+	t.Helper()
+	dut.Config().RoutingPolicy().Delete(t)
+}
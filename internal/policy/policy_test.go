@@ -0,0 +1,128 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"testing"
+
+	"github.com/openconfig/ondatra/telemetry"
+)
+
+func TestBuild(t *testing.T) {
+	sets := []*PrefixSet{
+		{Name: "V4", Prefixes: []string{"203.0.113.0/26"}, MaskLengthRange: "26..28"},
+		{Name: "V6", Prefixes: []string{"2001:db8:1::/48"}},
+		{Name: "MIXED", Prefixes: []string{"203.0.113.0/24", "2001:db8::/32"}},
+	}
+	defs := []*Definition{{
+		Name: "IMPORT",
+		Statements: []*Statement{
+			{PrefixSet: "V4", Result: telemetry.RoutingPolicy_PolicyResultType_ACCEPT_ROUTE},
+			{Name: "v6", PrefixSet: "V6", Invert: true, Result: telemetry.RoutingPolicy_PolicyResultType_REJECT_ROUTE},
+			{Result: telemetry.RoutingPolicy_PolicyResultType_REJECT_ROUTE},
+		},
+	}, AcceptAll("PERMIT-ALL")}
+
+	rp, err := Build(sets, defs)
+	if err != nil {
+		t.Fatalf("Build() got error: %v", err)
+	}
+
+	for _, c := range []struct {
+		name, prefix, mlr string
+		mode              telemetry.E_PrefixSet_Mode
+	}{
+		{"V4", "203.0.113.0/26", "26..28", telemetry.PrefixSet_Mode_IPV4},
+		{"V6", "2001:db8:1::/48", ExactMaskLength, telemetry.PrefixSet_Mode_IPV6},
+		{"MIXED", "2001:db8::/32", ExactMaskLength, telemetry.PrefixSet_Mode_MIXED},
+	} {
+		ps := rp.GetDefinedSets().GetPrefixSet(c.name)
+		if ps == nil {
+			t.Errorf("Prefix set %s is missing", c.name)
+			continue
+		}
+		if got := ps.GetMode(); got != c.mode {
+			t.Errorf("Prefix set %s mode got %v, want %v", c.name, got, c.mode)
+		}
+		if ps.GetPrefix(c.prefix, c.mlr) == nil {
+			t.Errorf("Prefix set %s is missing prefix %s %s", c.name, c.prefix, c.mlr)
+		}
+	}
+
+	pd := rp.GetPolicyDefinition("IMPORT")
+	for _, c := range []struct {
+		name      string
+		prefixSet string
+		options   telemetry.E_PolicyTypes_MatchSetOptionsRestrictedType
+		result    telemetry.E_RoutingPolicy_PolicyResultType
+	}{
+		{"10", "V4", telemetry.PolicyTypes_MatchSetOptionsRestrictedType_ANY, telemetry.RoutingPolicy_PolicyResultType_ACCEPT_ROUTE},
+		{"v6", "V6", telemetry.PolicyTypes_MatchSetOptionsRestrictedType_INVERT, telemetry.RoutingPolicy_PolicyResultType_REJECT_ROUTE},
+		{"30", "", telemetry.PolicyTypes_MatchSetOptionsRestrictedType_UNSET, telemetry.RoutingPolicy_PolicyResultType_REJECT_ROUTE},
+	} {
+		st := pd.GetStatement(c.name)
+		if st == nil {
+			t.Errorf("Statement %s is missing", c.name)
+			continue
+		}
+		mps := st.GetConditions().GetMatchPrefixSet()
+		switch {
+		case c.prefixSet == "":
+			// The getter of match-set-options returns its default,
+			// ANY, so a statement without a prefix set is checked to
+			// have no match-prefix-set at all.
+			if mps != nil {
+				t.Errorf("Statement %s got match-prefix-set %v, want none", c.name, mps)
+			}
+		case mps == nil:
+			t.Errorf("Statement %s is missing match-prefix-set %s", c.name, c.prefixSet)
+		default:
+			if got := mps.GetPrefixSet(); got != c.prefixSet {
+				t.Errorf("Statement %s prefix set got %q, want %q", c.name, got, c.prefixSet)
+			}
+			if got := mps.MatchSetOptions; got != c.options {
+				t.Errorf("Statement %s match-set-options got %v, want %v", c.name, got, c.options)
+			}
+		}
+		if got := st.GetActions().GetPolicyResult(); got != c.result {
+			t.Errorf("Statement %s policy result got %v, want %v", c.name, got, c.result)
+		}
+	}
+
+	if got := rp.GetPolicyDefinition("PERMIT-ALL").GetStatement("10").GetActions().GetPolicyResult(); got != telemetry.RoutingPolicy_PolicyResultType_ACCEPT_ROUTE {
+		t.Errorf("PERMIT-ALL policy result got %v, want ACCEPT_ROUTE", got)
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	cases := []struct {
+		desc string
+		sets []*PrefixSet
+		defs []*Definition
+	}{{
+		desc: "invalid prefix",
+		sets: []*PrefixSet{{Name: "BAD", Prefixes: []string{"203.0.113.0"}}},
+	}, {
+		desc: "undefined prefix set",
+		defs: []*Definition{{Name: "IMPORT", Statements: []*Statement{{PrefixSet: "MISSING"}}}},
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			if _, err := Build(c.sets, c.defs); err == nil {
+				t.Errorf("Build() got no error, want error")
+			}
+		})
+	}
+}
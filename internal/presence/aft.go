@@ -0,0 +1,54 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package presence
+
+import (
+	"github.com/openconfig/ondatra/telemetry/networkinstance"
+)
+
+// AFTLeaves returns the annotated leaves of the AFT subtree of an IPv4
+// entry installed through gRIBI: the entry of the prefix, the next hop
+// group it references by key nhgKey, and the next hops of the group with
+// the given indexes.
+func AFTLeaves(afts *networkinstance.NetworkInstance_AftsPath, prefix string, nhgKey uint64, nhIndexes []uint64) []*Leaf {
+	e := afts.Ipv4Entry(prefix)
+	nhg := afts.NextHopGroup(nhgKey)
+	leaves := []*Leaf{
+		{e.Prefix(), Required},
+		{e.NextHopGroup(), Required},
+		{e.OriginProtocol(), Optional},
+		{e.EntryMetadata(), Optional},
+		{e.Counters().PacketsForwarded(), Optional},
+		{e.Counters().OctetsForwarded(), Optional},
+		{nhg.Id(), Required},
+		{nhg.ProgrammedId(), RequiredUnlessDeviation + "gribi_nhg_match_by_key"},
+		{nhg.BackupNextHopGroup(), Optional},
+	}
+	for _, idx := range nhIndexes {
+		nh := afts.NextHop(idx)
+		leaves = append(leaves,
+			&Leaf{nhg.NextHop(idx).Index(), Required},
+			&Leaf{nhg.NextHop(idx).Weight(), Required},
+			&Leaf{nh.Index(), Required},
+			&Leaf{nh.IpAddress(), Required},
+			&Leaf{nh.MacAddress(), Optional},
+			&Leaf{nh.EncapsulateHeader(), Optional},
+			&Leaf{nh.InterfaceRef().Interface(), Optional},
+			&Leaf{nh.InterfaceRef().Subinterface(), Optional},
+			&Leaf{nh.Counters().PacketsForwarded(), Optional},
+		)
+	}
+	return leaves
+}
@@ -0,0 +1,176 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package presence checks which telemetry leaves the DUT reports, for
+// cross-vendor conformance.  Each leaf is annotated with whether it is
+// required, optional, or required unless a deviation is set, and only
+// required leaves that are missing fail the test, while the rest are
+// logged and written to a JSON report for conformance tracking.
+package presence
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/tcheck"
+	"github.com/openconfig/ygot/ygot"
+)
+
+// Annotations of the leaves.
+const (
+	// Required leaves must be reported.
+	Required = "required"
+	// Optional leaves may be reported.
+	Optional = "optional"
+	// RequiredUnlessDeviation is the prefix of the annotation of leaves
+	// that must be reported unless the deviation flag named after the
+	// rest of the annotation, with the "deviation_" prefix, is set, e.g.
+	// "required-unless-deviation-gribi_nhg_match_by_key".
+	RequiredUnlessDeviation = "required-unless-deviation-"
+)
+
+// Leaf is a telemetry leaf whose presence is checked.
+type Leaf struct {
+	Path       ygot.PathStruct
+	Annotation string
+}
+
+// Result is the result of checking a leaf.
+type Result struct {
+	Path       string `json:"path"`
+	Annotation string `json:"annotation"`
+	Required   bool   `json:"required"`
+	Present    bool   `json:"present"`
+}
+
+// Failed reports whether the leaf is required and missing.
+func (r *Result) Failed() bool {
+	return r.Required && !r.Present
+}
+
+// Report is the result of checking a list of leaves.
+type Report struct {
+	Test       string            `json:"test"`
+	Deviations map[string]string `json:"deviations"`
+	Results    []*Result         `json:"results"`
+}
+
+// isRequired returns whether a leaf with the annotation is required when
+// the active deviations, as returned by deviations.Active, are set.
+func isRequired(annotation string, active map[string]string) (bool, error) {
+	switch {
+	case annotation == Required:
+		return true, nil
+	case annotation == Optional:
+		return false, nil
+	case strings.HasPrefix(annotation, RequiredUnlessDeviation):
+		name := "deviation_" + strings.TrimPrefix(annotation, RequiredUnlessDeviation)
+		if flag.Lookup(name) == nil {
+			return false, fmt.Errorf("annotation %q names unknown deviation flag --%s", annotation, name)
+		}
+		_, set := active[name]
+		return !set, nil
+	default:
+		return false, fmt.Errorf("unknown annotation %q, want %q, %q or %q followed by a deviation", annotation, Required, Optional, RequiredUnlessDeviation)
+	}
+}
+
+// check returns the results of the leaves, whose paths are formatted by
+// path and whose presence is reported by present, and the errors of
+// their annotations.
+func check(leaves []*Leaf, active map[string]string, path func(*Leaf) string, present func(*Leaf) bool) ([]*Result, []error) {
+	var results []*Result
+	var errs []error
+	for _, l := range leaves {
+		req, err := isRequired(l.Annotation, active)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", path(l), err))
+			continue
+		}
+		results = append(results, &Result{
+			Path:       path(l),
+			Annotation: l.Annotation,
+			Required:   req,
+			Present:    present(l),
+		})
+	}
+	return results, errs
+}
+
+// String summarizes the report for the test log, listing the missing
+// leaves, required ones first.
+func (r *Report) String() string {
+	var present, failed, missing []string
+	for _, res := range r.Results {
+		switch {
+		case res.Present:
+			present = append(present, res.Path)
+		case res.Failed():
+			failed = append(failed, fmt.Sprintf("  %s (%s)", res.Path, res.Annotation))
+		default:
+			missing = append(missing, fmt.Sprintf("  %s (%s)", res.Path, res.Annotation))
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d of %d leaves present", len(present), len(r.Results))
+	if len(failed) > 0 {
+		fmt.Fprintf(&b, "\nrequired leaves missing:\n%s", strings.Join(failed, "\n"))
+	}
+	if len(missing) > 0 {
+		fmt.Fprintf(&b, "\nnon-required leaves missing:\n%s", strings.Join(missing, "\n"))
+	}
+	return b.String()
+}
+
+// Check looks up each leaf, logs the report, and reports a single test
+// error listing the required leaves that are missing, if any.  The
+// report is also written to the directory specified by the -outputs_dir
+// flag as JSON.
+func Check(t testing.TB, leaves []*Leaf) *Report {
+	t.Helper()
+	r := &Report{Test: t.Name(), Deviations: deviations.Active()}
+	results, errs := check(leaves, r.Deviations,
+		func(l *Leaf) string { return tcheck.Present(l.Path).Path() },
+		func(l *Leaf) bool { return tcheck.Present(l.Path).Check(t) == nil })
+	for _, err := range errs {
+		t.Errorf("Bad leaf annotation: %v", err)
+	}
+	r.Results = results
+
+	t.Logf("Leaf presence: %v", r)
+	var failed []string
+	for _, res := range r.Results {
+		if res.Failed() {
+			failed = append(failed, res.Path)
+		}
+	}
+	if len(failed) > 0 {
+		t.Errorf("Required leaves missing:\n%s", strings.Join(failed, "\n"))
+	}
+
+	content, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		t.Logf("Could not marshal leaf presence report: %v", err)
+		return r
+	}
+	if err := fptest.WriteOutput(t.Name(), ".presence.json", string(content)); err != nil {
+		t.Logf("Could not write leaf presence report: %v", err)
+	}
+	return r
+}
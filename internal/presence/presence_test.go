@@ -0,0 +1,90 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package presence
+
+import (
+	"strings"
+	"testing"
+)
+
+const byKey = RequiredUnlessDeviation + "gribi_nhg_match_by_key"
+
+func TestIsRequired(t *testing.T) {
+	set := map[string]string{"deviation_gribi_nhg_match_by_key": "true"}
+	cases := []struct {
+		annotation string
+		active     map[string]string
+		want       bool
+		wantErr    bool
+	}{
+		{Required, nil, true, false},
+		{Optional, nil, false, false},
+		{byKey, nil, true, false},
+		{byKey, set, false, false},
+		{RequiredUnlessDeviation + "no_such_deviation", nil, false, true},
+		{"mandatory", nil, false, true},
+	}
+	for _, c := range cases {
+		got, err := isRequired(c.annotation, c.active)
+		if (err != nil) != c.wantErr {
+			t.Errorf("isRequired(%q, %v) got error %v, want error %t", c.annotation, c.active, err, c.wantErr)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("isRequired(%q, %v) got %t, want %t", c.annotation, c.active, got, c.want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	// Leaves are identified by their annotation and index for the test.
+	leaves := []*Leaf{
+		{Annotation: Required},
+		{Annotation: Required},
+		{Annotation: Optional},
+		{Annotation: byKey},
+		{Annotation: "bogus"},
+	}
+	names := map[*Leaf]string{}
+	for i, l := range leaves {
+		names[l] = string(rune('a' + i))
+	}
+	missing := map[string]bool{"b": true, "c": true, "d": true}
+	path := func(l *Leaf) string { return "/" + names[l] }
+	present := func(l *Leaf) bool { return !missing[names[l]] }
+
+	results, errs := check(leaves, nil, path, present)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "/e") {
+		t.Errorf("check() got errors %v, want one for /e", errs)
+	}
+	var failed []string
+	for _, r := range results {
+		if r.Failed() {
+			failed = append(failed, r.Path)
+		}
+	}
+	if got, want := strings.Join(failed, ","), "/b,/d"; got != want {
+		t.Errorf("check() got failed leaves %s, want %s", got, want)
+	}
+
+	results, _ = check(leaves, map[string]string{"deviation_gribi_nhg_match_by_key": "true"}, path, present)
+	r := &Report{Results: results}
+	want := "1 of 4 leaves present\n" +
+		"required leaves missing:\n  /b (required)\n" +
+		"non-required leaves missing:\n  /c (optional)\n  /d (" + byKey + ")"
+	if got := r.String(); got != want {
+		t.Errorf("String() got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package process finds the processes implementing the gRPC services of
+// the DUT by vendor, and restarts them with gNOI KillProcess.
+package process
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	spb "github.com/openconfig/gnoi/system"
+)
+
+// Daemon is a service of the DUT implemented by a process.
+type Daemon string
+
+// The daemons whose process names are known.
+const (
+	GNMI  Daemon = "gNMI"
+	GRIBI Daemon = "gRIBI"
+)
+
+// names are the names of the processes implementing each daemon for
+// each vendor.  They can be overridden by the process name deviation of
+// the daemon.
+var names = map[Daemon]map[ondatra.Vendor]string{
+	GNMI: {
+		ondatra.ARISTA:  "Octa",
+		ondatra.CISCO:   "emsd",
+		ondatra.JUNIPER: "na-grpcd",
+	},
+	GRIBI: {
+		ondatra.ARISTA:  "Gribi",
+		ondatra.CISCO:   "emsd",
+		ondatra.JUNIPER: "rpd",
+	},
+}
+
+// overrides are the deviations overriding the process names of each
+// daemon.
+var overrides = map[Daemon]*string{
+	GNMI:  deviations.GNMIProcessName,
+	GRIBI: deviations.GRIBIProcessName,
+}
+
+// Name returns the name of the process implementing the daemon on the
+// DUT.
+func Name(t testing.TB, dut *ondatra.DUTDevice, d Daemon) string {
+	t.Helper()
+	if name := overrides[d]; name != nil && *name != "" {
+		return *name
+	}
+	name, ok := names[d][dut.Vendor()]
+	if !ok {
+		t.Fatalf("No %s process name for vendor %v, please set the %s process name deviation", d, dut.Vendor(), d)
+	}
+	return name
+}
+
+// PID returns the PID of the named process from telemetry.
+func PID(t testing.TB, dut *ondatra.DUTDevice, name string) uint64 {
+	t.Helper()
+	for _, proc := range dut.Telemetry().System().ProcessAny().Get(t) {
+		if proc.GetName() == name {
+			return proc.GetPid()
+		}
+	}
+	t.Fatalf("Process %s not found in telemetry", name)
+	return 0
+}
+
+// Restart terminates the named process using gNOI KillProcess, asking
+// the DUT to restart it, and returns the PID it had.  If the process
+// also implements gNOI, the DUT may become unavailable before it
+// answers, which is not an error.
+func Restart(t testing.TB, dut *ondatra.DUTDevice, name string) uint64 {
+	t.Helper()
+	pid := PID(t, dut, name)
+	t.Logf("Restart process %s with PID %d.", name, pid)
+	// The PID is uint64 in OpenConfig but uint32 in gNOI.
+	req := &spb.KillProcessRequest{
+		Name:    name,
+		Pid:     uint32(pid),
+		Signal:  spb.KillProcessRequest_SIGNAL_TERM,
+		Restart: true,
+	}
+	_, err := dut.RawAPIs().GNOI().Default(t).System().KillProcess(context.Background(), req)
+	switch {
+	case status.Code(err) == codes.Unavailable:
+		t.Logf("gNOI KillProcess for %s did not answer before the DUT became unavailable: %v", name, err)
+	case err != nil:
+		t.Fatalf("gNOI KillProcess for %s got error: %v", name, err)
+	}
+	return pid
+}
+
+// AwaitRestarted watches the processes of the DUT until the named
+// process runs with a PID other than the given one, i.e. until the DUT
+// restarted it, and returns the new PID.  It fails the test if the
+// process does not restart within the timeout.
+func AwaitRestarted(t testing.TB, dut *ondatra.DUTDevice, name string, pid uint64, timeout time.Duration) uint64 {
+	t.Helper()
+	var newPID uint64
+	_, ok := dut.Telemetry().System().ProcessAny().Watch(t, timeout, func(q *telemetry.QualifiedSystem_Process) bool {
+		if !q.IsPresent() {
+			return false
+		}
+		proc := q.Val(t)
+		if proc.GetName() != name || proc.GetPid() == pid {
+			return false
+		}
+		newPID = proc.GetPid()
+		return true
+	}).Await(t)
+	if !ok {
+		t.Fatalf("Process %s still has PID %d or is not running after %v", name, pid, timeout)
+	}
+	return newPID
+}
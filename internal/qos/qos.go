@@ -0,0 +1,285 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package qos builds OpenConfig QoS queues, forwarding groups,
+// classifiers and schedulers, and applies them to interfaces, so that
+// tests classifying, remarking or shaping traffic configure them the
+// same way.
+package qos
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/openconfig/featureprofiles/internal/acl"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+	"github.com/openconfig/ygot/ygot"
+)
+
+// ForwardingGroup is a forwarding group, whose packets are sent to the
+// output queue of the same name.
+type ForwardingGroup struct {
+	Name string
+}
+
+// Term is a term of a classifier.
+type Term struct {
+	// ID is the identifier of the term, which orders it among the terms
+	// of its classifier.
+	ID    string
+	Match acl.Match
+	// TargetGroup is the forwarding group the matching packets are
+	// assigned to.
+	TargetGroup string
+	// RemarkDSCP is the DSCP the matching packets are remarked to, if
+	// non-zero.
+	RemarkDSCP uint8
+}
+
+// Classifier is a named classifier, whose terms are evaluated in order.
+type Classifier struct {
+	Name string
+	// Type is the type of the classifier, IPV4 or IPV6, which the
+	// prefixes of its terms must be of.
+	Type  telemetry.E_Qos_Classifier_Type
+	Terms []*Term
+}
+
+// inputType returns the type of the classifier as it is applied to an
+// interface.
+func (c *Classifier) inputType() telemetry.E_Input_Classifier_Type {
+	if c.Type == telemetry.Qos_Classifier_Type_IPV6 {
+		return telemetry.Input_Classifier_Type_IPV6
+	}
+	return telemetry.Input_Classifier_Type_IPV4
+}
+
+// Build builds the QoS config with the forwarding groups and their
+// queues, and the classifiers.  It returns an error if a classifier has
+// an unsupported type, or if a term has no ID, targets a forwarding
+// group not among groups, or has a prefix that is invalid or not of the
+// type of its classifier.
+func Build(groups []*ForwardingGroup, classifiers []*Classifier) (*telemetry.Qos, error) {
+	q := &telemetry.Qos{}
+	for _, g := range groups {
+		q.GetOrCreateQueue(g.Name)
+		q.GetOrCreateForwardingGroup(g.Name).OutputQueue = ygot.String(g.Name)
+	}
+	for _, c := range classifiers {
+		if c.Type != telemetry.Qos_Classifier_Type_IPV4 && c.Type != telemetry.Qos_Classifier_Type_IPV6 {
+			return nil, fmt.Errorf("classifier %s: unsupported type %v", c.Name, c.Type)
+		}
+		qc := q.GetOrCreateClassifier(c.Name)
+		qc.Type = c.Type
+		for _, term := range c.Terms {
+			if term.ID == "" {
+				return nil, fmt.Errorf("classifier %s: term has no ID", c.Name)
+			}
+			if q.GetForwardingGroup(term.TargetGroup) == nil {
+				return nil, fmt.Errorf("classifier %s term %s: unknown forwarding group %q", c.Name, term.ID, term.TargetGroup)
+			}
+			v6, err := term.Match.IsIPv6()
+			if err != nil {
+				return nil, fmt.Errorf("classifier %s term %s: %w", c.Name, term.ID, err)
+			}
+			if term.Match.SrcPrefix != "" || term.Match.DstPrefix != "" {
+				if v6 != (c.Type == telemetry.Qos_Classifier_Type_IPV6) {
+					return nil, fmt.Errorf("classifier %s term %s: prefixes are not of type %v", c.Name, term.ID, c.Type)
+				}
+			}
+			qt := qc.GetOrCreateTerm(term.ID)
+			a := qt.GetOrCreateActions()
+			a.TargetGroup = ygot.String(term.TargetGroup)
+			if term.RemarkDSCP != 0 {
+				a.GetOrCreateRemark().SetDscp = ygot.Uint8(term.RemarkDSCP)
+			}
+			if c.Type == telemetry.Qos_Classifier_Type_IPV6 {
+				m := qt.GetOrCreateConditions().GetOrCreateIpv6()
+				if term.Match.SrcPrefix != "" {
+					m.SourceAddress = ygot.String(term.Match.SrcPrefix)
+				}
+				if term.Match.DstPrefix != "" {
+					m.DestinationAddress = ygot.String(term.Match.DstPrefix)
+				}
+				if term.Match.DSCP != 0 {
+					m.Dscp = ygot.Uint8(term.Match.DSCP)
+				}
+				continue
+			}
+			m := qt.GetOrCreateConditions().GetOrCreateIpv4()
+			if term.Match.SrcPrefix != "" {
+				m.SourceAddress = ygot.String(term.Match.SrcPrefix)
+			}
+			if term.Match.DstPrefix != "" {
+				m.DestinationAddress = ygot.String(term.Match.DstPrefix)
+			}
+			if term.Match.DSCP != 0 {
+				m.Dscp = ygot.Uint8(term.Match.DSCP)
+			}
+		}
+	}
+	return q, nil
+}
+
+// Shaper is a scheduler policy shaping the queue of a forwarding group
+// to a rate.
+type Shaper struct {
+	// Name is the name of the scheduler policy.
+	Name string
+	// Group is the forwarding group whose queue is shaped.
+	Group string
+	// CIR is the rate the queue is shaped to in bits per second.
+	CIR uint64
+	// BC is the committed burst size in bytes, or the DUT default if
+	// zero.
+	BC uint32
+}
+
+// AddShaper adds the scheduler policy of the shaper to the QoS config.
+// It returns an error if the forwarding group of the shaper is not in
+// the config.
+func AddShaper(q *telemetry.Qos, s *Shaper) error {
+	if q.GetForwardingGroup(s.Group) == nil {
+		return fmt.Errorf("shaper %s: unknown forwarding group %q", s.Name, s.Group)
+	}
+	sched := q.GetOrCreateSchedulerPolicy(s.Name).GetOrCreateScheduler(0)
+	sched.Type = telemetry.QosTypes_QOS_SCHEDULER_TYPE_ONE_RATE_TWO_COLOR
+	in := sched.GetOrCreateInput(s.Group)
+	in.InputType = telemetry.Input_InputType_QUEUE
+	in.Queue = ygot.String(s.Group)
+	rate := sched.GetOrCreateOneRateTwoColor()
+	rate.Cir = ygot.Uint64(s.CIR)
+	if s.BC != 0 {
+		rate.Bc = ygot.Uint32(s.BC)
+	}
+	rate.QueuingBehavior = telemetry.QosTypes_QueueBehavior_SHAPE
+	return nil
+}
+
+// qosInterface returns the QoS config of the interface with the given
+// name, which also identifies the interface in the QoS config.
+func qosInterface(q *telemetry.Qos, intf string) *telemetry.Qos_Interface {
+	qi := q.GetOrCreateInterface(intf)
+	ref := qi.GetOrCreateInterfaceRef()
+	ref.Interface = ygot.String(intf)
+	ref.Subinterface = ygot.Uint32(0)
+	return qi
+}
+
+// AttachInput applies the classifier to the packets received by the
+// interface with the given name.
+func AttachInput(q *telemetry.Qos, intf string, c *Classifier) {
+	qosInterface(q, intf).GetOrCreateInput().GetOrCreateClassifier(c.inputType()).Name = ygot.String(c.Name)
+}
+
+// AttachOutput applies the classifier to the packets sent by the
+// interface with the given name, e.g. to remark them on egress.
+func AttachOutput(q *telemetry.Qos, intf string, c *Classifier) {
+	qosInterface(q, intf).GetOrCreateOutput().GetOrCreateClassifier(c.inputType()).Name = ygot.String(c.Name)
+}
+
+// AttachShaper applies the scheduler policy of the shaper to the
+// packets sent by the interface with the given name, along with the
+// queue of its forwarding group.
+func AttachShaper(q *telemetry.Qos, intf string, s *Shaper) {
+	out := qosInterface(q, intf).GetOrCreateOutput()
+	out.GetOrCreateSchedulerPolicy().Name = ygot.String(s.Name)
+	out.GetOrCreateQueue(s.Group)
+}
+
+// Configure replaces the QoS config of the DUT.
+func Configure(t testing.TB, dut *ondatra.DUTDevice, q *telemetry.Qos) {
+	t.Helper()
+	p := dut.Config().Qos()
+	fptest.LogYgot(t, "DUT QoS", p, q)
+	p.Replace(t, q)
+}
+
+// Delete removes the QoS config from the DUT.
+func Delete(t testing.TB, dut *ondatra.DUTDevice) {
+	t.Helper()
+	dut.Config().Qos().Delete(t)
+}
+
+// InputMatchedPackets returns the packets received by the interface
+// that the classifier assigned to each forwarding group, by name, as
+// the sum of the matched-packets counters of the terms targeting it.
+// Terms whose counter the DUT does not report are omitted, and a group
+// none of whose terms' counters are reported is absent.
+func InputMatchedPackets(t testing.TB, dut *ondatra.DUTDevice, intf string, c *Classifier) map[string]uint64 {
+	t.Helper()
+	path := dut.Telemetry().Qos().Interface(intf).Input().Classifier(c.inputType())
+	pkts := make(map[string]uint64)
+	for _, term := range c.Terms {
+		if q := path.Term(term.ID).MatchedPackets().Lookup(t); q.IsPresent() {
+			pkts[term.TargetGroup] += q.Val(t)
+		}
+	}
+	return pkts
+}
+
+// QueueCounters are the counters of an output queue of a DUT interface.
+type QueueCounters struct {
+	TransmitPkts uint64
+	DroppedPkts  uint64
+}
+
+// QueueSnapshot holds the counters of the output queues of a DUT
+// interface, by queue name.  Queues whose counters the DUT does not
+// report are omitted.
+type QueueSnapshot map[string]*QueueCounters
+
+// SnapshotOutputQueues reads the counters of the named output queues
+// of the interface.  Take a snapshot before and after running traffic,
+// and use Diff to get what the traffic incremented.
+func SnapshotOutputQueues(t testing.TB, dut *ondatra.DUTDevice, intf string, queues ...string) QueueSnapshot {
+	t.Helper()
+	s := QueueSnapshot{}
+	for _, name := range queues {
+		if q := dut.Telemetry().Qos().Interface(intf).Output().Queue(name).Lookup(t); q.IsPresent() {
+			v := q.Val(t)
+			s[name] = &QueueCounters{TransmitPkts: v.GetTransmitPkts(), DroppedPkts: v.GetDroppedPkts()}
+		}
+	}
+	return s
+}
+
+// Diff returns how much the counters of the queues in both snapshots
+// incremented since the before snapshot.  Counters that went backwards,
+// e.g. because they were cleared, are treated as not incremented.
+func (s QueueSnapshot) Diff(before QueueSnapshot) QueueSnapshot {
+	d := QueueSnapshot{}
+	for name, a := range s {
+		b, ok := before[name]
+		if !ok {
+			continue
+		}
+		d[name] = &QueueCounters{
+			TransmitPkts: increment(b.TransmitPkts, a.TransmitPkts),
+			DroppedPkts:  increment(b.DroppedPkts, a.DroppedPkts),
+		}
+	}
+	return d
+}
+
+// increment returns how much a counter incremented from before to
+// after, or 0 if it went backwards.
+func increment(before, after uint64) uint64 {
+	if after < before {
+		return 0
+	}
+	return after - before
+}
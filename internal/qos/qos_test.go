@@ -0,0 +1,184 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package qos
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/openconfig/featureprofiles/internal/acl"
+	"github.com/openconfig/ondatra/telemetry"
+)
+
+func TestBuild(t *testing.T) {
+	groups := []*ForwardingGroup{{Name: "AF1"}, {Name: "BE"}}
+	in := &Classifier{
+		Name: "CLASSIFY",
+		Type: telemetry.Qos_Classifier_Type_IPV4,
+		Terms: []*Term{
+			{ID: "1", Match: acl.Match{DSCP: 10}, TargetGroup: "AF1"},
+			{ID: "2", Match: acl.Match{SrcPrefix: "198.51.100.0/24"}, TargetGroup: "BE"},
+		},
+	}
+	out := &Classifier{
+		Name:  "REMARK",
+		Type:  telemetry.Qos_Classifier_Type_IPV6,
+		Terms: []*Term{{ID: "1", Match: acl.Match{DSCP: 10}, TargetGroup: "AF1", RemarkDSCP: 32}},
+	}
+	q, err := Build(groups, []*Classifier{in, out})
+	if err != nil {
+		t.Fatalf("Build() got error: %v", err)
+	}
+	AttachInput(q, "Ethernet1", in)
+	AttachOutput(q, "Ethernet2", out)
+
+	for _, g := range groups {
+		if got := q.GetForwardingGroup(g.Name).GetOutputQueue(); got != g.Name {
+			t.Errorf("Forwarding group %s output-queue got %q, want %q", g.Name, got, g.Name)
+		}
+		if q.GetQueue(g.Name) == nil {
+			t.Errorf("Queue %s is missing", g.Name)
+		}
+	}
+
+	qc := q.GetClassifier(in.Name)
+	if got := qc.GetType(); got != in.Type {
+		t.Errorf("Classifier %s type got %v, want %v", in.Name, got, in.Type)
+	}
+	t1 := qc.GetTerm("1")
+	if got := t1.GetConditions().GetIpv4().GetDscp(); got != 10 {
+		t.Errorf("Term 1 dscp got %d, want 10", got)
+	}
+	if got := t1.GetActions().GetTargetGroup(); got != "AF1" {
+		t.Errorf("Term 1 target-group got %q, want %q", got, "AF1")
+	}
+	if r := t1.GetActions().GetRemark(); r != nil {
+		t.Errorf("Term 1 remark got %v, want none", r)
+	}
+	if got := qc.GetTerm("2").GetConditions().GetIpv4().GetSourceAddress(); got != "198.51.100.0/24" {
+		t.Errorf("Term 2 source-address got %q, want %q", got, "198.51.100.0/24")
+	}
+
+	rt := q.GetClassifier(out.Name).GetTerm("1")
+	if got := rt.GetActions().GetRemark().GetSetDscp(); got != 32 {
+		t.Errorf("Remark term set-dscp got %d, want 32", got)
+	}
+	if got := rt.GetConditions().GetIpv6().GetDscp(); got != 10 {
+		t.Errorf("Remark term IPv6 dscp got %d, want 10", got)
+	}
+
+	qi := q.GetInterface("Ethernet1")
+	if got := qi.GetInterfaceRef().GetInterface(); got != "Ethernet1" {
+		t.Errorf("Interface ref got %q, want %q", got, "Ethernet1")
+	}
+	if got := qi.GetInput().GetClassifier(telemetry.Input_Classifier_Type_IPV4).GetName(); got != in.Name {
+		t.Errorf("Input classifier got %q, want %q", got, in.Name)
+	}
+	if got := q.GetInterface("Ethernet2").GetOutput().GetClassifier(telemetry.Input_Classifier_Type_IPV6).GetName(); got != out.Name {
+		t.Errorf("Output classifier got %q, want %q", got, out.Name)
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	groups := []*ForwardingGroup{{Name: "AF1"}}
+	for _, c := range []struct {
+		desc       string
+		classifier *Classifier
+	}{{
+		desc:       "unsupported type",
+		classifier: &Classifier{Name: "BAD", Type: telemetry.Qos_Classifier_Type_ETHERNET},
+	}, {
+		desc: "no term ID",
+		classifier: &Classifier{Name: "BAD", Type: telemetry.Qos_Classifier_Type_IPV4, Terms: []*Term{
+			{Match: acl.Match{DSCP: 10}, TargetGroup: "AF1"},
+		}},
+	}, {
+		desc: "unknown forwarding group",
+		classifier: &Classifier{Name: "BAD", Type: telemetry.Qos_Classifier_Type_IPV4, Terms: []*Term{
+			{ID: "1", Match: acl.Match{DSCP: 10}, TargetGroup: "AF2"},
+		}},
+	}, {
+		desc: "prefix not of classifier type",
+		classifier: &Classifier{Name: "BAD", Type: telemetry.Qos_Classifier_Type_IPV4, Terms: []*Term{
+			{ID: "1", Match: acl.Match{SrcPrefix: "2001:db8::/32"}, TargetGroup: "AF1"},
+		}},
+	}} {
+		t.Run(c.desc, func(t *testing.T) {
+			if _, err := Build(groups, []*Classifier{c.classifier}); err == nil {
+				t.Errorf("Build() got no error, want error")
+			}
+		})
+	}
+}
+
+func TestShaper(t *testing.T) {
+	q, err := Build([]*ForwardingGroup{{Name: "AF1"}}, nil)
+	if err != nil {
+		t.Fatalf("Build() got error: %v", err)
+	}
+	s := &Shaper{Name: "SHAPE", Group: "AF1", CIR: 100000000}
+	if err := AddShaper(q, s); err != nil {
+		t.Fatalf("AddShaper() got error: %v", err)
+	}
+	AttachShaper(q, "Ethernet2", s)
+
+	sched := q.GetSchedulerPolicy(s.Name).GetScheduler(0)
+	if got := sched.GetType(); got != telemetry.QosTypes_QOS_SCHEDULER_TYPE_ONE_RATE_TWO_COLOR {
+		t.Errorf("Scheduler type got %v, want ONE_RATE_TWO_COLOR", got)
+	}
+	if got := sched.GetInput("AF1").GetQueue(); got != "AF1" {
+		t.Errorf("Scheduler input queue got %q, want %q", got, "AF1")
+	}
+	rate := sched.GetOneRateTwoColor()
+	if got := rate.GetCir(); got != s.CIR {
+		t.Errorf("Scheduler cir got %d, want %d", got, s.CIR)
+	}
+	if rate.Bc != nil {
+		t.Errorf("Scheduler bc got %d, want unset", rate.GetBc())
+	}
+	if got := rate.GetQueuingBehavior(); got != telemetry.QosTypes_QueueBehavior_SHAPE {
+		t.Errorf("Scheduler queuing-behavior got %v, want SHAPE", got)
+	}
+	out := q.GetInterface("Ethernet2").GetOutput()
+	if got := out.GetSchedulerPolicy().GetName(); got != s.Name {
+		t.Errorf("Output scheduler-policy got %q, want %q", got, s.Name)
+	}
+	if out.GetQueue("AF1") == nil {
+		t.Errorf("Output queue AF1 is missing")
+	}
+
+	if err := AddShaper(q, &Shaper{Name: "BAD", Group: "AF2"}); err == nil {
+		t.Errorf("AddShaper() with unknown forwarding group got no error, want error")
+	}
+}
+
+func TestQueueSnapshotDiff(t *testing.T) {
+	before := QueueSnapshot{
+		"AF1": {TransmitPkts: 100, DroppedPkts: 10},
+		"BE":  {TransmitPkts: 500, DroppedPkts: 50},
+	}
+	after := QueueSnapshot{
+		"AF1": {TransmitPkts: 300, DroppedPkts: 70},
+		"BE":  {TransmitPkts: 20, DroppedPkts: 50},
+		"AF2": {TransmitPkts: 10},
+	}
+	want := QueueSnapshot{
+		"AF1": {TransmitPkts: 200, DroppedPkts: 60},
+		"BE":  {},
+	}
+	if diff := cmp.Diff(want, after.Diff(before)); diff != "" {
+		t.Errorf("Diff() -want,+got:\n%s", diff)
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package samplecheck verifies that the DUT streams the updates of
+// SAMPLE mode gNMI subscriptions at the requested sample interval.
+//
+// A subscribed path counts as sampled by every notification with an
+// update under it, or with no update and a prefix under it.  This way
+// devices that coalesce unchanged values, sending only the changed
+// leaves or only the notification timestamp, still deliver a sample,
+// and updates with identical values are counted as delivered too.
+// Notifications of a path with the same timestamp are one sample.
+package samplecheck
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ygot/ygot"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// Options configure Verify.  A nil *Options uses the defaults.
+type Options struct {
+	// Window is how long to collect the updates for, by default six
+	// sample intervals.
+	Window time.Duration
+	// Tolerance is how far apart from the sample interval consecutive
+	// samples may be, by default a fifth of the sample interval.
+	Tolerance time.Duration
+}
+
+func (o *Options) window(interval time.Duration) time.Duration {
+	if o == nil || o.Window == 0 {
+		return 6 * interval
+	}
+	return o.Window
+}
+
+func (o *Options) tolerance(interval time.Duration) time.Duration {
+	if o == nil || o.Tolerance == 0 {
+		return interval / 5
+	}
+	return o.Tolerance
+}
+
+// fullPath returns the path of the update or delete p of a notification
+// with the given prefix.
+func fullPath(prefix, p *gpb.Path) []*gpb.PathElem {
+	elems := append([]*gpb.PathElem{}, prefix.GetElem()...)
+	return append(elems, p.GetElem()...)
+}
+
+// under reports whether the path elems are the subscribed path sub or
+// below it.  Keys missing from sub or with value "*" match any value.
+func under(elems []*gpb.PathElem, sub *gpb.Path) bool {
+	if len(elems) < len(sub.GetElem()) {
+		return false
+	}
+	for i, se := range sub.GetElem() {
+		e := elems[i]
+		if e.GetName() != se.GetName() {
+			return false
+		}
+		for k, v := range se.GetKey() {
+			if v != "*" && e.GetKey()[k] != v {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// sampler collects the timestamps of the samples of subscribed paths.
+type sampler struct {
+	paths []*gpb.Path
+	// samples are the sample timestamps of each path, in the order
+	// received.
+	samples [][]time.Time
+}
+
+func newSampler(paths []*gpb.Path) *sampler {
+	return &sampler{paths: paths, samples: make([][]time.Time, len(paths))}
+}
+
+// sampled reports whether the notification samples the path.
+func sampled(n *gpb.Notification, sub *gpb.Path) bool {
+	if len(n.GetUpdate()) == 0 {
+		return len(n.GetDelete()) == 0 && under(n.GetPrefix().GetElem(), sub)
+	}
+	for _, u := range n.GetUpdate() {
+		if under(fullPath(n.GetPrefix(), u.GetPath()), sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// add records the notification as a sample of the paths it samples.
+func (s *sampler) add(n *gpb.Notification) {
+	ts := time.Unix(0, n.GetTimestamp())
+	for i, p := range s.paths {
+		if !sampled(n, p) {
+			continue
+		}
+		if last := len(s.samples[i]) - 1; last >= 0 && s.samples[i][last].Equal(ts) {
+			continue
+		}
+		s.samples[i] = append(s.samples[i], ts)
+	}
+}
+
+// checkCadence returns the errors found in the sample timestamps of a
+// path collected for the window: timestamps that do not increase,
+// intervals between samples that are not the sample interval within
+// the tolerance, and too few samples for the window.
+func checkCadence(samples []time.Time, interval, tolerance, window time.Duration) []error {
+	var errs []error
+	want := int(window / (interval + tolerance))
+	if want < 2 {
+		want = 2
+	}
+	if len(samples) < want {
+		errs = append(errs, fmt.Errorf("got %d samples in %v, want at least %d", len(samples), window, want))
+	}
+	for i := 1; i < len(samples); i++ {
+		gap := samples[i].Sub(samples[i-1])
+		switch {
+		case gap <= 0:
+			errs = append(errs, fmt.Errorf("sample %d timestamp %v does not increase from %v", i, samples[i].Format(time.RFC3339Nano), samples[i-1].Format(time.RFC3339Nano)))
+		case gap < interval-tolerance || gap > interval+tolerance:
+			errs = append(errs, fmt.Errorf("sample %d came %v after the previous one, want %v ± %v", i, gap, interval, tolerance))
+		}
+	}
+	return errs
+}
+
+// subscribeRequest returns the request to subscribe to the paths in
+// SAMPLE mode with the given sample interval.
+func subscribeRequest(paths []*gpb.Path, interval time.Duration) *gpb.SubscribeRequest {
+	var subs []*gpb.Subscription
+	for _, p := range paths {
+		subs = append(subs, &gpb.Subscription{
+			Path:           p,
+			Mode:           gpb.SubscriptionMode_SAMPLE,
+			SampleInterval: uint64(interval.Nanoseconds()),
+		})
+	}
+	return &gpb.SubscribeRequest{
+		Request: &gpb.SubscribeRequest_Subscribe{
+			Subscribe: &gpb.SubscriptionList{
+				Subscription: subs,
+				Mode:         gpb.SubscriptionList_STREAM,
+				Encoding:     gpb.Encoding_PROTO,
+			},
+		},
+	}
+}
+
+// Verify subscribes to the paths, e.g.
+// "/interfaces/interface[name=Ethernet1]/state/counters", in SAMPLE mode
+// with the sample interval through the raw gNMI client of the DUT, and
+// collects the updates for the window in opts.  It reports a test error
+// for each path whose samples do not come at the interval within the
+// tolerance in opts, or whose timestamps do not increase, and returns
+// whether all paths were sampled at the interval.  opts may be nil.
+func Verify(t testing.TB, dut *ondatra.DUTDevice, paths []string, interval time.Duration, opts *Options) bool {
+	t.Helper()
+	var gpaths []*gpb.Path
+	for _, p := range paths {
+		gp, err := ygot.StringToStructuredPath(p)
+		if err != nil {
+			t.Fatalf("Cannot parse path %q: %v", p, err)
+		}
+		gpaths = append(gpaths, gp)
+	}
+	window, tolerance := opts.window(interval), opts.tolerance(interval)
+
+	ctx, cancel := context.WithTimeout(context.Background(), window)
+	defer cancel()
+	sub, err := dut.RawAPIs().GNMI().Default(t).Subscribe(ctx)
+	if err != nil {
+		t.Fatalf("Cannot subscribe: %v", err)
+	}
+	if err := sub.Send(subscribeRequest(gpaths, interval)); err != nil {
+		t.Fatalf("Cannot send subscribe request: %v", err)
+	}
+	ok := true
+	s := newSampler(gpaths)
+	for {
+		resp, err := sub.Recv()
+		if err != nil {
+			if ctx.Err() == nil {
+				t.Errorf("Subscription ended before %v: %v", window, err)
+				ok = false
+			}
+			break
+		}
+		if n := resp.GetUpdate(); n != nil {
+			s.add(n)
+		}
+	}
+
+	for i, p := range paths {
+		t.Logf("Path %s got %d samples at interval %v in %v", p, len(s.samples[i]), interval, window)
+		for _, err := range checkCadence(s.samples[i], interval, tolerance, window) {
+			t.Errorf("Path %s %v", p, err)
+			ok = false
+		}
+	}
+	return ok
+}
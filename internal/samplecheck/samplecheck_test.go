@@ -0,0 +1,154 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package samplecheck
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openconfig/ygot/ygot"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+func mustPath(t *testing.T, s string) *gpb.Path {
+	t.Helper()
+	p, err := ygot.StringToStructuredPath(s)
+	if err != nil {
+		t.Fatalf("Cannot parse path %q: %v", s, err)
+	}
+	return p
+}
+
+func TestSampled(t *testing.T) {
+	counters := mustPath(t, "/interfaces/interface[name=*]/state/counters")
+	eth1 := &gpb.Path{Elem: []*gpb.PathElem{{Name: "interfaces"}, {Name: "interface", Key: map[string]string{"name": "Ethernet1"}}}}
+	cases := []struct {
+		desc string
+		n    *gpb.Notification
+		want bool
+	}{{
+		desc: "leaf update",
+		n: &gpb.Notification{
+			Prefix: eth1,
+			Update: []*gpb.Update{{Path: mustPath(t, "/state/counters/in-octets")}},
+		},
+		want: true,
+	}, {
+		desc: "update of other container",
+		n: &gpb.Notification{
+			Prefix: eth1,
+			Update: []*gpb.Update{{Path: mustPath(t, "/state/oper-status")}},
+		},
+	}, {
+		desc: "coalesced to prefix only",
+		n:    &gpb.Notification{Prefix: mustPath(t, "/interfaces/interface[name=Ethernet1]/state/counters")},
+		want: true,
+	}, {
+		desc: "delete only",
+		n: &gpb.Notification{
+			Prefix: eth1,
+			Delete: []*gpb.Path{mustPath(t, "/state/counters")},
+		},
+	}, {
+		desc: "prefix above subscription",
+		n:    &gpb.Notification{Prefix: eth1},
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			if got := sampled(c.n, counters); got != c.want {
+				t.Errorf("sampled() got %t, want %t", got, c.want)
+			}
+		})
+	}
+}
+
+func TestUnderKeys(t *testing.T) {
+	eth1 := mustPath(t, "/interfaces/interface[name=Ethernet1]/state/counters/in-octets").GetElem()
+	cases := []struct {
+		sub  string
+		want bool
+	}{
+		{"/interfaces/interface[name=Ethernet1]/state/counters", true},
+		{"/interfaces/interface[name=Ethernet2]/state/counters", false},
+		{"/interfaces/interface[name=*]/state/counters", true},
+		{"/interfaces/interface/state/counters", true},
+		{"/interfaces/interface[name=Ethernet1]/state/counters/in-octets/extra", false},
+	}
+	for _, c := range cases {
+		if got := under(eth1, mustPath(t, c.sub)); got != c.want {
+			t.Errorf("under(%s) got %t, want %t", c.sub, got, c.want)
+		}
+	}
+}
+
+func TestSamplerCoalesces(t *testing.T) {
+	counters := mustPath(t, "/interfaces/interface[name=Ethernet1]/state/counters")
+	s := newSampler([]*gpb.Path{counters})
+	notify := func(sec int64, leaf string) {
+		s.add(&gpb.Notification{
+			Timestamp: sec * int64(time.Second),
+			Prefix:    mustPath(t, "/interfaces/interface[name=Ethernet1]"),
+			Update: []*gpb.Update{{
+				Path: mustPath(t, "/state/counters/"+leaf),
+				Val:  &gpb.TypedValue{Value: &gpb.TypedValue_UintVal{UintVal: 0}},
+			}},
+		})
+	}
+	notify(0, "in-octets")
+	notify(0, "out-octets") // Same sample, split across notifications.
+	notify(10, "in-octets") // Identical value, still a sample.
+	notify(20, "out-octets")
+	if got, want := len(s.samples[0]), 3; got != want {
+		t.Errorf("sampler got %d samples, want %d", got, want)
+	}
+}
+
+func TestCheckCadence(t *testing.T) {
+	start := time.Unix(1000, 0)
+	at := func(secs ...float64) []time.Time {
+		var ts []time.Time
+		for _, s := range secs {
+			ts = append(ts, start.Add(time.Duration(s*float64(time.Second))))
+		}
+		return ts
+	}
+	const (
+		interval  = 10 * time.Second
+		tolerance = 2 * time.Second
+		window    = 60 * time.Second
+	)
+	cases := []struct {
+		desc     string
+		samples  []time.Time
+		wantErrs int
+	}{
+		{"on time", at(0, 10, 20, 30, 40, 50, 60), 0},
+		{"within tolerance", at(0, 11, 20, 31.5, 40, 49, 60), 0},
+		{"too slow", at(0, 15, 30, 45, 60), 4},
+		{"too fast", at(0, 5, 10, 15, 20, 25, 30, 35, 40, 45, 50, 55, 60), 12},
+		{"missed sample", at(0, 10, 30, 40, 50, 60), 1},
+		{"timestamp goes back", at(0, 10, 20, 15, 30, 40, 50), 2},
+		{"too few", at(0, 10), 1},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			errs := checkCadence(c.samples, interval, tolerance, window)
+			if len(errs) != c.wantErrs {
+				t.Errorf("checkCadence() got errors %v, want %d errors", errs, c.wantErrs)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sanity checks that the gRPC services of a DUT answer RPCs
+// again after an event that restarts them, such as a reboot or a
+// process restart.  Each service is probed with a new connection and a
+// read-only RPC, so that a connection made before the event is not
+// mistaken for the service being back.  A Subscription tells whether
+// the event broke the gNMI sessions established before it.
+package sanity
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/testt"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+	spb "github.com/openconfig/gnoi/system"
+	rpb "github.com/openconfig/gribi/v1/proto/service"
+)
+
+// Service is a gRPC service of the DUT.
+type Service string
+
+// The services probed by Probe.
+const (
+	GNMI  Service = "gNMI"
+	GRIBI Service = "gRIBI"
+	GNOI  Service = "gNOI"
+)
+
+// AllServices are all the services probed by Probe, in the order
+// AwaitServices probes them by default.
+var AllServices = []Service{GNMI, GRIBI, GNOI}
+
+const (
+	// rpcTimeout is how long a probe RPC may take.
+	rpcTimeout = 10 * time.Second
+	// pollInterval is how often AwaitServices probes the services that
+	// are not answering yet.
+	pollInterval = 10 * time.Second
+)
+
+// Probe returns an error unless the service of the DUT answers a
+// read-only RPC on a new connection: a gNMI Capabilities RPC, a gRIBI
+// Get RPC, or a gNOI System.Time RPC.
+func Probe(t testing.TB, dut *ondatra.DUTDevice, svc Service) error {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout)
+	defer cancel()
+	var err error
+	if msg := testt.CaptureFatal(t, func(t testing.TB) {
+		switch svc {
+		case GNMI:
+			_, err = dut.RawAPIs().GNMI().New(t).Capabilities(ctx, &gpb.CapabilityRequest{})
+		case GRIBI:
+			err = probeGRIBI(ctx, dut.RawAPIs().GRIBI().New(t))
+		case GNOI:
+			_, err = dut.RawAPIs().GNOI().New(t).System().Time(ctx, &spb.TimeRequest{})
+		default:
+			err = fmt.Errorf("unknown service %q", svc)
+		}
+	}); msg != nil {
+		return fmt.Errorf("cannot connect to %s: %s", svc, *msg)
+	}
+	if err != nil {
+		return fmt.Errorf("%s RPC failed: %w", svc, err)
+	}
+	return nil
+}
+
+// probeGRIBI sends a gRIBI Get RPC for all network instances, and
+// returns an error if it does not succeed.
+func probeGRIBI(ctx context.Context, c rpb.GRIBIClient) error {
+	stream, err := c.Get(ctx, &rpb.GetRequest{
+		NetworkInstance: &rpb.GetRequest_All{All: &rpb.Empty{}},
+		Aft:             rpb.AFTType_ALL,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := stream.Recv(); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// AwaitServices probes the services of the DUT until they have all
+// answered, or returns an error naming those that did not answer within
+// the timeout.  If no services are given, AllServices are probed.
+func AwaitServices(t testing.TB, dut *ondatra.DUTDevice, timeout time.Duration, services ...Service) error {
+	t.Helper()
+	if len(services) == 0 {
+		services = AllServices
+	}
+	return await(services, timeout, pollInterval, func(svc Service) error {
+		return Probe(t, dut, svc)
+	}, t.Logf)
+}
+
+// await calls probe for each service not answering yet every interval,
+// until they have all answered or the timeout elapses.  A service that
+// answered once is not probed again.
+func await(services []Service, timeout, interval time.Duration, probe func(Service) error, logf func(string, ...interface{})) error {
+	start := time.Now()
+	pending := append([]Service(nil), services...)
+	for {
+		var errs []error
+		var failed []Service
+		for _, svc := range pending {
+			if err := probe(svc); err != nil {
+				errs = append(errs, err)
+				failed = append(failed, svc)
+				continue
+			}
+			logf("%s is answering after %v", svc, time.Since(start).Round(time.Second))
+		}
+		pending = failed
+		if len(pending) == 0 {
+			return nil
+		}
+		if time.Since(start) >= timeout {
+			return fmt.Errorf("%v not answering after %v: %v", pending, timeout, errs)
+		}
+		logf("%v not answering yet: %v", pending, errs)
+		time.Sleep(interval)
+	}
+}
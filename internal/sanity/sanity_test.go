@@ -0,0 +1,60 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sanity
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAwait(t *testing.T) {
+	// gNMI answers right away, and gRIBI after two failed probes.
+	failures := map[Service]int{GRIBI: 2}
+	var probed []Service
+	probe := func(svc Service) error {
+		probed = append(probed, svc)
+		if failures[svc] > 0 {
+			failures[svc]--
+			return errors.New("unavailable")
+		}
+		return nil
+	}
+	if err := await([]Service{GNMI, GRIBI}, time.Minute, time.Millisecond, probe, t.Logf); err != nil {
+		t.Fatalf("await() got error: %v", err)
+	}
+	if diff := cmp.Diff([]Service{GNMI, GRIBI, GRIBI, GRIBI}, probed); diff != "" {
+		t.Errorf("await() probes -want,+got:\n%s", diff)
+	}
+}
+
+func TestAwaitTimeout(t *testing.T) {
+	probe := func(svc Service) error {
+		if svc == GNOI {
+			return errors.New("unavailable")
+		}
+		return nil
+	}
+	err := await([]Service{GNMI, GNOI}, 5*time.Millisecond, time.Millisecond, probe, t.Logf)
+	if err == nil {
+		t.Fatalf("await() got no error, want error")
+	}
+	if !strings.Contains(err.Error(), string(GNOI)) || strings.Contains(err.Error(), "["+string(GNMI)) {
+		t.Errorf("await() got error %q, want it to name only %s", err, GNOI)
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sanity
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/testt"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// Subscription is a long-lived gNMI subscription on a new connection,
+// sampling /system/state/current-datetime of the DUT, which tells
+// whether an event broke the sessions established before it.
+type Subscription struct {
+	cancel   context.CancelFunc
+	interval time.Duration
+
+	mu   sync.Mutex
+	last time.Time
+	err  error
+}
+
+// currentDatetime is the path of the leaf sampled by a Subscription,
+// which changes with every sample.
+var currentDatetime = &gpb.Path{
+	Origin: "openconfig",
+	Elem:   []*gpb.PathElem{{Name: "system"}, {Name: "state"}, {Name: "current-datetime"}},
+}
+
+// Subscribe opens a Subscription sampling every interval.  It receives
+// the updates in the background until it is closed or ends with an
+// error.
+func Subscribe(t testing.TB, dut *ondatra.DUTDevice, interval time.Duration) (*Subscription, error) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	var stream gpb.GNMI_SubscribeClient
+	var err error
+	if msg := testt.CaptureFatal(t, func(t testing.TB) {
+		stream, err = dut.RawAPIs().GNMI().New(t).Subscribe(ctx)
+	}); msg != nil {
+		cancel()
+		return nil, fmt.Errorf("cannot connect to gNMI: %s", *msg)
+	}
+	if err == nil {
+		err = stream.Send(&gpb.SubscribeRequest{
+			Request: &gpb.SubscribeRequest_Subscribe{
+				Subscribe: &gpb.SubscriptionList{
+					Mode:     gpb.SubscriptionList_STREAM,
+					Encoding: gpb.Encoding_JSON_IETF,
+					Subscription: []*gpb.Subscription{{
+						Path:           currentDatetime,
+						Mode:           gpb.SubscriptionMode_SAMPLE,
+						SampleInterval: uint64(interval.Nanoseconds()),
+					}},
+				},
+			},
+		})
+	}
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("gNMI Subscribe failed: %w", err)
+	}
+	s := &Subscription{cancel: cancel, interval: interval}
+	go s.receive(stream.Recv)
+	return s, nil
+}
+
+// receive records the timestamp of each update received, until recv
+// returns an error.
+func (s *Subscription) receive(recv func() (*gpb.SubscribeResponse, error)) {
+	for {
+		resp, err := recv()
+		s.mu.Lock()
+		if err != nil {
+			s.err = err
+			s.mu.Unlock()
+			return
+		}
+		if n := resp.GetUpdate(); n != nil {
+			s.last = time.Unix(0, n.GetTimestamp())
+		}
+		s.mu.Unlock()
+	}
+}
+
+// State returns the timestamp of the last update received, and the
+// error that ended the subscription, if any.  The timestamp is set by
+// the DUT, so it is only comparable with other update timestamps.
+func (s *Subscription) State() (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last, s.err
+}
+
+// AwaitUpdate waits for an update timestamped after the given time,
+// and returns an error if the subscription ends or none is received
+// within the timeout.
+func (s *Subscription) AwaitUpdate(after time.Time, timeout time.Duration) error {
+	start := time.Now()
+	for {
+		last, err := s.State()
+		switch {
+		case err != nil:
+			return fmt.Errorf("subscription ended: %w", err)
+		case last.After(after):
+			return nil
+		case time.Since(start) >= timeout:
+			return fmt.Errorf("no update after %v within %v", after, timeout)
+		}
+		time.Sleep(s.interval)
+	}
+}
+
+// AwaitError waits for the subscription to end, and returns the error
+// it ended with, or nil if it is still open after the timeout.
+func (s *Subscription) AwaitError(timeout time.Duration) error {
+	start := time.Now()
+	for {
+		if _, err := s.State(); err != nil {
+			return err
+		}
+		if time.Since(start) >= timeout {
+			return nil
+		}
+		time.Sleep(s.interval)
+	}
+}
+
+// Close ends the subscription.
+func (s *Subscription) Close() {
+	s.cancel()
+}
@@ -0,0 +1,76 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sanity
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// fakeStream returns the responses sent to it, and then the error once
+// the responses channel is closed.
+type fakeStream struct {
+	resps chan *gpb.SubscribeResponse
+	err   error
+}
+
+func (f *fakeStream) recv() (*gpb.SubscribeResponse, error) {
+	if r, ok := <-f.resps; ok {
+		return r, nil
+	}
+	return nil, f.err
+}
+
+func update(ts time.Time) *gpb.SubscribeResponse {
+	return &gpb.SubscribeResponse{
+		Response: &gpb.SubscribeResponse_Update{
+			Update: &gpb.Notification{Timestamp: ts.UnixNano()},
+		},
+	}
+}
+
+func TestSubscription(t *testing.T) {
+	f := &fakeStream{resps: make(chan *gpb.SubscribeResponse), err: errors.New("stream ended")}
+	s := &Subscription{cancel: func() {}, interval: time.Millisecond}
+	go s.receive(f.recv)
+
+	t0 := time.Unix(1000, 0)
+	f.resps <- update(t0)
+	if err := s.AwaitUpdate(time.Time{}, time.Second); err != nil {
+		t.Fatalf("AwaitUpdate() got error: %v", err)
+	}
+	if err := s.AwaitUpdate(t0, 10*time.Millisecond); err == nil {
+		t.Errorf("AwaitUpdate() got no error without a later update, want error")
+	}
+	f.resps <- &gpb.SubscribeResponse{Response: &gpb.SubscribeResponse_SyncResponse{SyncResponse: true}}
+	f.resps <- update(t0.Add(time.Second))
+	if err := s.AwaitUpdate(t0, time.Second); err != nil {
+		t.Errorf("AwaitUpdate() got error after a later update: %v", err)
+	}
+	if err := s.AwaitError(10 * time.Millisecond); err != nil {
+		t.Errorf("AwaitError() got error %v while the subscription is open, want nil", err)
+	}
+
+	close(f.resps)
+	if err := s.AwaitError(time.Second); err != f.err {
+		t.Errorf("AwaitError() got %v, want %v", err, f.err)
+	}
+	if err := s.AwaitUpdate(time.Time{}, time.Second); err == nil {
+		t.Errorf("AwaitUpdate() got no error after the subscription ended, want error")
+	}
+}
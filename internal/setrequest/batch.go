@@ -0,0 +1,191 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setrequest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ygot/ygot"
+	"google.golang.org/grpc/status"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// Batch accumulates the replaces and updates of several paths, e.g. of
+// the interfaces of the DUT ports, and sends them in a single
+// SetRequest, so that they are committed together rather than one
+// commit per path.  Each is described, e.g. by the port it configures,
+// so that an error still names the path that caused it.
+type Batch struct {
+	ops []*batchOp
+}
+
+// batchOp is a replace or an update of a Batch.
+type batchOp struct {
+	desc    string
+	q       ygot.PathStruct
+	val     interface{}
+	replace bool
+}
+
+// String describes the op for errors.
+func (o *batchOp) String() string {
+	if o.replace {
+		return "replace of " + o.desc
+	}
+	return "update of " + o.desc
+}
+
+// request returns the SetRequest of the op alone.
+func (o *batchOp) request() (*gpb.SetRequest, error) {
+	b := New()
+	if o.replace {
+		b.Replace(o.q, o.val)
+	} else {
+		b.Update(o.q, o.val)
+	}
+	req, err := b.Request()
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", o, err)
+	}
+	return req, nil
+}
+
+// NewBatch returns an empty Batch.
+func NewBatch() *Batch {
+	return &Batch{}
+}
+
+// Replace adds a replace of the path with the value, described by desc.
+func (b *Batch) Replace(desc string, q ygot.PathStruct, val interface{}) *Batch {
+	b.ops = append(b.ops, &batchOp{desc: desc, q: q, val: val, replace: true})
+	return b
+}
+
+// Update adds an update of the path with the value, described by desc.
+func (b *Batch) Update(desc string, q ygot.PathStruct, val interface{}) *Batch {
+	b.ops = append(b.ops, &batchOp{desc: desc, q: q, val: val})
+	return b
+}
+
+// Len returns the number of paths in the batch.
+func (b *Batch) Len() int {
+	return len(b.ops)
+}
+
+// Request returns the single SetRequest of the paths in the batch, or
+// the first error composing it, naming the path that caused it.
+func (b *Batch) Request() (*gpb.SetRequest, error) {
+	req := &gpb.SetRequest{}
+	for _, o := range b.ops {
+		r, err := o.request()
+		if err != nil {
+			return nil, err
+		}
+		req.Replace = append(req.Replace, r.GetReplace()...)
+		req.Update = append(req.Update, r.GetUpdate()...)
+	}
+	return req, nil
+}
+
+// Set sends the batch to the DUT in a single SetRequest, logging how
+// long the DUT took to apply it.  If the DUT rejects it, none of its
+// paths are committed, and the error names the paths the DUT reports
+// in its error status, from the paths of the details or else from the
+// message, rather than sending any path again.
+func (b *Batch) Set(t testing.TB, dut *ondatra.DUTDevice) error {
+	t.Helper()
+	defer fptest.TimeStep(t, "push config")()
+	c := dut.RawAPIs().GNMI().Default(t)
+	return b.set(t, func(req *gpb.SetRequest) error {
+		_, err := c.Set(context.Background(), req)
+		return err
+	})
+}
+
+// set sends the batch with send, as Set does.
+func (b *Batch) set(t testing.TB, send func(*gpb.SetRequest) error) error {
+	t.Helper()
+	if len(b.ops) == 0 {
+		return nil
+	}
+	req, err := b.Request()
+	if err != nil {
+		return err
+	}
+	var descs []string
+	for _, o := range b.ops {
+		descs = append(descs, o.desc)
+	}
+	start := time.Now()
+	err = send(req)
+	elapsed := time.Since(start)
+	if err == nil {
+		t.Logf("DUT applied SetRequest of %d paths (%s) in %v", len(b.ops), strings.Join(descs, ", "), elapsed)
+		return nil
+	}
+	if len(b.ops) == 1 {
+		return fmt.Errorf("%v: %w", b.ops[0], err)
+	}
+	t.Logf("DUT rejected SetRequest of %d paths (%s) after %v: %v", len(b.ops), strings.Join(descs, ", "), elapsed, err)
+	rejected := b.rejected(err)
+	if len(rejected) == 0 {
+		return fmt.Errorf("SetRequest of %d paths (%s) failed without naming a path: %w", len(b.ops), strings.Join(descs, ", "), err)
+	}
+	return fmt.Errorf("SetRequest of %d paths failed, rejecting %s: %w", len(b.ops), strings.Join(rejected, ", "), err)
+}
+
+// rejected returns the ops of the batch that the error status of a
+// rejected SetRequest names: those whose path is or contains a path of
+// its UpdateResult details, or else those whose path is in its message.
+func (b *Batch) rejected(err error) []string {
+	st := status.Convert(err)
+	var errPaths []string
+	for _, d := range st.Details() {
+		if r, ok := d.(*gpb.UpdateResult); ok && r.GetPath() != nil {
+			if s, err := ygot.PathToString(r.GetPath()); err == nil {
+				errPaths = append(errPaths, s)
+			}
+		}
+	}
+	var rejected []string
+	for _, o := range b.ops {
+		p, err := Path(o.q)
+		if err != nil {
+			continue
+		}
+		s, err := ygot.PathToString(p)
+		if err != nil {
+			continue
+		}
+		named := false
+		for _, e := range errPaths {
+			named = named || strings.HasPrefix(e+"/", s+"/")
+		}
+		if len(errPaths) == 0 {
+			named = strings.Contains(st.Message(), s)
+		}
+		if named {
+			rejected = append(rejected, o.String())
+		}
+	}
+	return rejected
+}
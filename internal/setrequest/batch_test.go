@@ -0,0 +1,129 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setrequest
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/openconfig/featureprofiles/yang/fpoc"
+	"github.com/openconfig/ygot/ygot"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// testBatch returns a batch replacing the interfaces of port1 and port2
+// and updating the description of port3.
+func testBatch() *Batch {
+	root := fpoc.DeviceRoot("dut")
+	return NewBatch().
+		Replace("port1", root.Interface("Ethernet1"), &fpoc.Interface{Name: ygot.String("Ethernet1")}).
+		Replace("port2", root.Interface("Ethernet2"), &fpoc.Interface{Name: ygot.String("Ethernet2")}).
+		Update("port3", root.Interface("Ethernet3").Description(), ygot.String("port3"))
+}
+
+func TestBatchRequest(t *testing.T) {
+	b := testBatch()
+	if got := b.Len(); got != 3 {
+		t.Errorf("Len got %d, want 3", got)
+	}
+	req, err := b.Request()
+	if err != nil {
+		t.Fatalf("Request got error: %v", err)
+	}
+	if got := len(req.GetReplace()); got != 2 {
+		t.Errorf("Request got %d replaces, want 2", got)
+	}
+	if got := len(req.GetUpdate()); got != 1 {
+		t.Errorf("Request got %d updates, want 1", got)
+	}
+}
+
+// ethernet2 is the path the batch replaces for port2.
+const ethernet2 = "/interfaces/interface[name=Ethernet2]"
+
+// reject is a send func rejecting every SetRequest with err, counting
+// the requests sent.
+func reject(sent *int, err error) func(*gpb.SetRequest) error {
+	return func(*gpb.SetRequest) error {
+		*sent++
+		return err
+	}
+}
+
+// rejectWithDetails returns an error status whose UpdateResult details
+// name the path.
+func rejectWithDetails(t *testing.T, path string) error {
+	p, err := ygot.StringToStructuredPath(path)
+	if err != nil {
+		t.Fatalf("Cannot parse path %s: %v", path, err)
+	}
+	st, err := status.New(codes.InvalidArgument, "invalid interface").WithDetails(&gpb.UpdateResult{Path: p, Op: gpb.UpdateResult_REPLACE})
+	if err != nil {
+		t.Fatalf("Cannot add details: %v", err)
+	}
+	return st.Err()
+}
+
+func TestBatchSet(t *testing.T) {
+	var sent int
+	if err := testBatch().set(t, reject(&sent, nil)); err != nil {
+		t.Errorf("set got error: %v", err)
+	}
+	if sent != 1 {
+		t.Errorf("set sent %d SetRequests, want 1", sent)
+	}
+}
+
+func TestBatchSetRejected(t *testing.T) {
+	cases := []struct {
+		desc string
+		err  error
+		want string
+	}{{
+		desc: "details",
+		err:  rejectWithDetails(t, ethernet2+"/config/mtu"),
+		want: "rejecting replace of port2:",
+	}, {
+		desc: "message",
+		err:  status.Error(codes.InvalidArgument, "invalid value at "+ethernet2),
+		want: "rejecting replace of port2:",
+	}, {
+		desc: "no path",
+		err:  errors.New("commit failed"),
+		want: "failed without naming a path",
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			var sent int
+			err := testBatch().set(t, reject(&sent, c.err))
+			if err == nil {
+				t.Fatalf("set got no error, want an error")
+			}
+			if !strings.Contains(err.Error(), c.want) {
+				t.Errorf("set got error %v, want %q", err, c.want)
+			}
+			if strings.Contains(err.Error(), "replace of port1") || strings.Contains(err.Error(), "update of port3") {
+				t.Errorf("set got error %v, want it to name only the replace of port2", err)
+			}
+			if sent != 1 {
+				t.Errorf("set sent %d SetRequests, want only the batch", sent)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package setrequest composes gNMI SetRequests of several paths from
+// ygot path structs and GoStructs, and of vendor CLI configuration, for
+// tests that need the changes to be applied in a single transaction
+// rather than one Set per path as with the generated per-path API.
+package setrequest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ygot/ygot"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// Path returns the gNMI path of the path struct, with the openconfig
+// origin and no target, which the raw gNMI client sets in the prefix.
+func Path(q ygot.PathStruct) (*gpb.Path, error) {
+	p, _, errs := ygot.ResolvePath(q)
+	if errs != nil {
+		return nil, fmt.Errorf("cannot resolve path: %v", errs)
+	}
+	p.Target = ""
+	p.Origin = "openconfig"
+	return p, nil
+}
+
+// JSONVal returns the value, a GoStruct or a leaf value of one, as an
+// RFC7951 JSON typed value.
+func JSONVal(val interface{}) (*gpb.TypedValue, error) {
+	b, err := ygot.Marshal7951(val, &ygot.RFC7951JSONConfig{AppendModuleName: true})
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal %v: %w", val, err)
+	}
+	return &gpb.TypedValue{Value: &gpb.TypedValue_JsonIetfVal{JsonIetfVal: b}}, nil
+}
+
+// Builder composes a SetRequest.  The paths are deleted, replaced and
+// updated in this order, as gNMI specifies, regardless of the order
+// they are added in.  The first error composing the request is
+// returned by Request.
+type Builder struct {
+	req *gpb.SetRequest
+	err error
+}
+
+// New returns a Builder of an empty SetRequest.
+func New() *Builder {
+	return &Builder{req: &gpb.SetRequest{}}
+}
+
+// update returns the update of the path to the value.
+func (b *Builder) update(q ygot.PathStruct, val interface{}) *gpb.Update {
+	p, err := Path(q)
+	if err == nil {
+		var v *gpb.TypedValue
+		if v, err = JSONVal(val); err == nil {
+			return &gpb.Update{Path: p, Val: v}
+		}
+	}
+	if b.err == nil {
+		b.err = err
+	}
+	return nil
+}
+
+// Delete adds a delete of the path.
+func (b *Builder) Delete(q ygot.PathStruct) *Builder {
+	p, err := Path(q)
+	if err != nil {
+		if b.err == nil {
+			b.err = err
+		}
+		return b
+	}
+	b.req.Delete = append(b.req.Delete, p)
+	return b
+}
+
+// Replace adds a replace of the path with the value.
+func (b *Builder) Replace(q ygot.PathStruct, val interface{}) *Builder {
+	if u := b.update(q, val); u != nil {
+		b.req.Replace = append(b.req.Replace, u)
+	}
+	return b
+}
+
+// Update adds an update of the path with the value.
+func (b *Builder) Update(q ygot.PathStruct, val interface{}) *Builder {
+	if u := b.update(q, val); u != nil {
+		b.req.Update = append(b.req.Update, u)
+	}
+	return b
+}
+
+// UpdateJSON adds an update of the path below the path struct by the
+// element names with the RFC7951 JSON value, for containers of models
+// the path structs do not cover, e.g. openconfig-if-tunnel below an
+// interface.
+func (b *Builder) UpdateJSON(q ygot.PathStruct, elems []string, json []byte) *Builder {
+	p, err := Path(q)
+	if err != nil {
+		if b.err == nil {
+			b.err = err
+		}
+		return b
+	}
+	for _, e := range elems {
+		p.Elem = append(p.Elem, &gpb.PathElem{Name: e})
+	}
+	b.req.Update = append(b.req.Update, &gpb.Update{
+		Path: p,
+		Val:  &gpb.TypedValue{Value: &gpb.TypedValue_JsonIetfVal{JsonIetfVal: json}},
+	})
+	return b
+}
+
+// CLI adds an update of the root of the cli origin with the vendor CLI
+// configuration, for devices that take some configuration as CLI
+// alongside OpenConfig in the same transaction.
+func (b *Builder) CLI(config string) *Builder {
+	b.req.Update = append(b.req.Update, &gpb.Update{
+		Path: &gpb.Path{Origin: "cli", Elem: []*gpb.PathElem{}},
+		Val:  &gpb.TypedValue{Value: &gpb.TypedValue_AsciiVal{AsciiVal: config}},
+	})
+	return b
+}
+
+// Request returns the SetRequest composed.
+func (b *Builder) Request() (*gpb.SetRequest, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	return b.req, nil
+}
+
+// Set sends the SetRequest composed to the DUT, and returns the response
+// or the error of the RPC.  It fails the test if the request cannot be
+// composed.
+func (b *Builder) Set(t testing.TB, dut *ondatra.DUTDevice) (*gpb.SetResponse, error) {
+	t.Helper()
+	req, err := b.Request()
+	if err != nil {
+		t.Fatalf("Cannot compose SetRequest: %v", err)
+	}
+	t.Logf("Send SetRequest: %s", describe(req))
+	return dut.RawAPIs().GNMI().Default(t).Set(context.Background(), req)
+}
+
+// describe lists the operations of the SetRequest by path, without their
+// values, which may hold passwords and other secrets.
+func describe(req *gpb.SetRequest) string {
+	var ops []string
+	add := func(op string, p *gpb.Path) {
+		s, err := ygot.PathToString(p)
+		if err != nil {
+			s = p.String()
+		}
+		if o := p.GetOrigin(); o != "" && o != "openconfig" {
+			s = o + ":" + s
+		}
+		ops = append(ops, op+" "+s)
+	}
+	for _, p := range req.GetDelete() {
+		add("delete", p)
+	}
+	for _, u := range req.GetReplace() {
+		add("replace", u.GetPath())
+	}
+	for _, u := range req.GetUpdate() {
+		add("update", u.GetPath())
+	}
+	return strings.Join(ops, ", ")
+}
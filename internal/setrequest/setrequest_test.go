@@ -0,0 +1,158 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setrequest
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/openconfig/featureprofiles/yang/fpoc"
+	"github.com/openconfig/ygot/ygot"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+func TestPath(t *testing.T) {
+	got, err := Path(fpoc.DeviceRoot("dut").Interface("Ethernet1").Description())
+	if err != nil {
+		t.Fatalf("Path got error: %v", err)
+	}
+	want := &gpb.Path{
+		Origin: "openconfig",
+		Elem: []*gpb.PathElem{
+			{Name: "interfaces"},
+			{Name: "interface", Key: map[string]string{"name": "Ethernet1"}},
+			{Name: "config"},
+			{Name: "description"},
+		},
+	}
+	if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+		t.Errorf("Path got diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestJSONVal(t *testing.T) {
+	cases := []struct {
+		desc string
+		val  interface{}
+		want []string
+	}{{
+		desc: "leaf",
+		val:  ygot.String("port1"),
+		want: []string{`"port1"`},
+	}, {
+		desc: "struct",
+		val:  &fpoc.Interface{Name: ygot.String("Ethernet1"), Description: ygot.String("port1")},
+		want: []string{`"openconfig-interfaces:`, `"description":"port1"`, `"Ethernet1"`},
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			v, err := JSONVal(c.val)
+			if err != nil {
+				t.Fatalf("JSONVal got error: %v", err)
+			}
+			got := string(v.GetJsonIetfVal())
+			if !json.Valid([]byte(got)) {
+				t.Fatalf("JSONVal got invalid JSON %s", got)
+			}
+			for _, want := range c.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("JSONVal got %s, want it to contain %s", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	root := fpoc.DeviceRoot("dut")
+	req, err := New().
+		Update(root.Interface("Ethernet1").Description(), ygot.String("port1")).
+		Replace(root.Interface("Ethernet2"), &fpoc.Interface{Name: ygot.String("Ethernet2")}).
+		Delete(root.Interface("Ethernet3")).
+		Update(root.Interface("Ethernet4").Enabled(), ygot.Bool(true)).
+		CLI("interface Ethernet5\n  shutdown\n").
+		Request()
+	if err != nil {
+		t.Fatalf("Request got error: %v", err)
+	}
+	if got := len(req.GetDelete()); got != 1 {
+		t.Errorf("Request got %d deletes, want 1", got)
+	}
+	if got := len(req.GetReplace()); got != 1 {
+		t.Errorf("Request got %d replaces, want 1", got)
+	}
+	if got := len(req.GetUpdate()); got != 3 {
+		t.Fatalf("Request got %d updates, want 3", got)
+	}
+	cli := req.GetUpdate()[2]
+	if got := cli.GetPath().GetOrigin(); got != "cli" {
+		t.Errorf("CLI update origin got %q, want cli", got)
+	}
+	if got := cli.GetVal().GetAsciiVal(); got != "interface Ethernet5\n  shutdown\n" {
+		t.Errorf("CLI update value got %q, want the CLI configuration", got)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	root := fpoc.DeviceRoot("dut")
+	req, err := New().
+		Update(root.Interface("Ethernet1").Description(), ygot.String("port1")).
+		Delete(root.Interface("Ethernet3")).
+		CLI("username admin secret s3cr3t\n").
+		Request()
+	if err != nil {
+		t.Fatalf("Request got error: %v", err)
+	}
+	got := describe(req)
+	want := "delete /interfaces/interface[name=Ethernet3], update /interfaces/interface[name=Ethernet1]/config/description, update cli:/"
+	if got != want {
+		t.Errorf("describe got %q, want %q", got, want)
+	}
+	if strings.Contains(got, "s3cr3t") || strings.Contains(got, "port1") {
+		t.Errorf("describe got %q, want no values", got)
+	}
+}
+
+func TestUpdateJSON(t *testing.T) {
+	root := fpoc.DeviceRoot("dut")
+	req, err := New().
+		UpdateJSON(root.Interface("tunnel1"), []string{"tunnel", "config"}, []byte(`{"src":"192.0.2.5"}`)).
+		Request()
+	if err != nil {
+		t.Fatalf("Request got error: %v", err)
+	}
+	if got := len(req.GetUpdate()); got != 1 {
+		t.Fatalf("Request got %d updates, want 1", got)
+	}
+	want := &gpb.Update{
+		Path: &gpb.Path{
+			Origin: "openconfig",
+			Elem: []*gpb.PathElem{
+				{Name: "interfaces"},
+				{Name: "interface", Key: map[string]string{"name": "tunnel1"}},
+				{Name: "tunnel"},
+				{Name: "config"},
+			},
+		},
+		Val: &gpb.TypedValue{Value: &gpb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`{"src":"192.0.2.5"}`)}},
+	}
+	if diff := cmp.Diff(want, req.GetUpdate()[0], protocmp.Transform()); diff != "" {
+		t.Errorf("UpdateJSON got diff (-want +got):\n%s", diff)
+	}
+}
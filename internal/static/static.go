@@ -0,0 +1,104 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package static provides helpers to configure static routes on the DUT,
+// so that tests exercising the interaction with other protocols
+// configure them the same way.
+package static
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+	"github.com/openconfig/ygot/ygot"
+)
+
+// NextHop is a next hop of a static route.
+type NextHop struct {
+	// Index is the key of the next hop.  If empty, the position of the
+	// next hop in the route starting from 0 is used.
+	Index string
+	// Address is the IP address of the next hop.
+	Address string
+	// Interface and Subinterface identify the egress interface.  They
+	// are only used by interface-ref when the
+	// deviation_static_route_next_hop_interface_ref is set.
+	Interface    string
+	Subinterface uint32
+	// Preference is the preference of the next hop, the lowest being
+	// preferred over the other next hops of the route.  If zero, the
+	// device default is used.
+	Preference uint32
+}
+
+// Protocol builds the static routing protocol containing a route to
+// prefix via the next hops.
+func Protocol(prefix string, nhs ...*NextHop) *telemetry.NetworkInstance_Protocol {
+	p := &telemetry.NetworkInstance_Protocol{
+		Identifier: telemetry.PolicyTypes_INSTALL_PROTOCOL_TYPE_STATIC,
+		Name:       ygot.String(*deviations.StaticProtocolName),
+	}
+	sr := p.GetOrCreateStatic(prefix)
+	for i, nh := range nhs {
+		index := nh.Index
+		if index == "" {
+			index = strconv.Itoa(i)
+		}
+		n := sr.GetOrCreateNextHop(index)
+		n.NextHop = telemetry.UnionString(nh.Address)
+		if *deviations.StaticRouteNextHopInterfaceRef && nh.Interface != "" {
+			ref := n.GetOrCreateInterfaceRef()
+			ref.Interface = ygot.String(nh.Interface)
+			ref.Subinterface = ygot.Uint32(nh.Subinterface)
+		}
+		if nh.Preference > 0 {
+			n.Preference = ygot.Uint32(nh.Preference)
+		}
+	}
+	return p
+}
+
+// Configure adds a static route to prefix via the next hops in the
+// network instance of the DUT.
+func Configure(t testing.TB, dut *ondatra.DUTDevice, instance, prefix string, nhs ...*NextHop) {
+	t.Helper()
+	dut.Config().NetworkInstance(instance).
+		Protocol(telemetry.PolicyTypes_INSTALL_PROTOCOL_TYPE_STATIC, *deviations.StaticProtocolName).
+		Update(t, Protocol(prefix, nhs...))
+}
+
+// Delete removes the static route to prefix from the network instance
+// of the DUT.
+func Delete(t testing.TB, dut *ondatra.DUTDevice, instance, prefix string) {
+	t.Helper()
+	dut.Config().NetworkInstance(instance).
+		Protocol(telemetry.PolicyTypes_INSTALL_PROTOCOL_TYPE_STATIC, *deviations.StaticProtocolName).
+		Static(prefix).
+		Delete(t)
+}
+
+// DeleteNextHop removes the next hop with the given index from the
+// static route to prefix in the network instance of the DUT, leaving
+// the other next hops of the route.
+func DeleteNextHop(t testing.TB, dut *ondatra.DUTDevice, instance, prefix, index string) {
+	t.Helper()
+	dut.Config().NetworkInstance(instance).
+		Protocol(telemetry.PolicyTypes_INSTALL_PROTOCOL_TYPE_STATIC, *deviations.StaticProtocolName).
+		Static(prefix).
+		NextHop(index).
+		Delete(t)
+}
@@ -0,0 +1,71 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package static
+
+import (
+	"testing"
+
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/ondatra/telemetry"
+)
+
+func TestProtocol(t *testing.T) {
+	const prefix = "203.0.113.0/24"
+	nhs := []*NextHop{
+		{Address: "192.0.2.6", Interface: "Ethernet2"},
+		{Index: "backup", Address: "192.0.2.10", Interface: "Ethernet3", Subinterface: 1, Preference: 20},
+	}
+
+	for _, interfaceRef := range []bool{false, true} {
+		*deviations.StaticRouteNextHopInterfaceRef = interfaceRef
+		p := Protocol(prefix, nhs...)
+		sr := p.GetStatic(prefix)
+		if sr == nil {
+			t.Fatalf("Protocol(%s) with interface-ref=%v is missing the static route", prefix, interfaceRef)
+		}
+		for _, c := range []struct {
+			index, addr, intf   string
+			subintf, preference uint32
+		}{
+			{"0", "192.0.2.6", "Ethernet2", 0, 0},
+			{"backup", "192.0.2.10", "Ethernet3", 1, 20},
+		} {
+			nh := sr.GetNextHop(c.index)
+			if nh == nil {
+				t.Fatalf("Protocol(%s) with interface-ref=%v is missing next hop %q", prefix, interfaceRef, c.index)
+			}
+			if got, want := nh.GetNextHop(), telemetry.UnionString(c.addr); got != want {
+				t.Errorf("Next hop %q address got %v, want %v", c.index, got, want)
+			}
+			if got := nh.GetPreference(); got != c.preference {
+				t.Errorf("Next hop %q preference got %d, want %d", c.index, got, c.preference)
+			}
+			ref := nh.GetInterfaceRef()
+			if !interfaceRef {
+				if ref != nil {
+					t.Errorf("Next hop %q got interface-ref %v, want none", c.index, ref)
+				}
+				continue
+			}
+			if got := ref.GetInterface(); got != c.intf {
+				t.Errorf("Next hop %q interface-ref interface got %q, want %q", c.index, got, c.intf)
+			}
+			if got := ref.GetSubinterface(); got != c.subintf {
+				t.Errorf("Next hop %q interface-ref subinterface got %d, want %d", c.index, got, c.subintf)
+			}
+		}
+	}
+	*deviations.StaticRouteNextHopInterfaceRef = false
+}
@@ -67,9 +67,12 @@ tcheck also provides a number of shorthands for common cases:
 These helpers all have prewritten testFuncs that return sensible errors of the
 form "<path>: <got>, <want>" - for example,
 
-	/system/some/path: no value, want 12
+	/system/some/path: path not present, want 12
 	/system/hostname: got "wrongname", want "node1" or nil
 
+An error for a path that is not present wraps ErrNotPresent, so that
+errors.Is can tell a missing leaf from one with the wrong value.
+
 All of these validation functions (except Present/NonPresent) are generic, but
 are designed to work with Ondatra's non-generic ygot-generated types. The
 Predicate API differs from Ondatra's own generated Watch methods in that
@@ -131,24 +134,48 @@ When writing custom validators, use tcheck.Failed(got Value[T], wantMsg) to
 standardize the error text (and the handling of nonpresent values). For
 example, tcheck.Failed(Value[int]{7, true}, "want a multiple of four") will
 return an error of "got 7, want a multiple of four".
+
+# Checking several paths
+
+Rather than reading leaves with Get, which fails the test at the first
+missing leaf and so skips the checks of its siblings, use CheckAll to
+check each of the validators and report all of their failures at once:
+
+	if err := tcheck.CheckAll(t,
+	    tcheck.Equal(dut.Some().Path(), someValue),
+	    tcheck.Equal(dut.Some().OtherPath(), otherValue),
+	); err != nil {
+	    t.Error(err)
+	}
+
+The error lists each failure on its own line after a summary, e.g.
+
+	1 of 2 checks failed:
+	  /some/other/path: path not present, want 7
 */
 package tcheck
 
 import (
+	"errors"
 	"fmt"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/openconfig/ygot/ygot"
 )
 
+// ErrNotPresent is wrapped by the errors of validators whose path is not
+// present.
+var ErrNotPresent = errors.New("path not present")
+
 // Failed returns a sensible error message for getting this value at this path.
 func Failed[T any](got Value[T], wantMsg string) error {
 	val, present := got.Val()
 	if !present {
-		return fmt.Errorf("no value, %s", wantMsg)
+		return fmt.Errorf("%w, %s", ErrNotPresent, wantMsg)
 	}
 	return fmt.Errorf("got %#v, %s", val, wantMsg)
 }
@@ -411,3 +438,51 @@ func NotPresent(path ygot.PathStruct) *PresentValidation {
 		wantPresent: false,
 	}
 }
+
+// Failures is the error of CheckAll, holding the errors of the
+// validators that failed.
+type Failures struct {
+	// Checked is the number of validators checked.
+	Checked int
+	// Errs are the errors of the validators that failed, in order.
+	Errs []error
+}
+
+// Error summarizes the failures, listing each on its own line.
+func (f *Failures) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d of %d checks failed:", len(f.Errs), f.Checked)
+	for _, err := range f.Errs {
+		fmt.Fprintf(&b, "\n  %v", err)
+	}
+	return b.String()
+}
+
+// NotPresent returns the errors of the validators that failed because
+// their path was not present.
+func (f *Failures) NotPresent() []error {
+	var errs []error
+	for _, err := range f.Errs {
+		if errors.Is(err, ErrNotPresent) {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// CheckAll checks each of the validators immediately, carrying on past
+// failures, and returns a *Failures error with all of their errors, or
+// nil if they all pass.
+func CheckAll(t testing.TB, validators ...Validator) error {
+	t.Helper()
+	f := &Failures{Checked: len(validators)}
+	for _, vd := range validators {
+		if err := vd.Check(t); err != nil {
+			f.Errs = append(f.Errs, err)
+		}
+	}
+	if len(f.Errs) == 0 {
+		return nil
+	}
+	return f
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package tcheck
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
@@ -349,3 +350,86 @@ func TestPresentValidation(t *testing.T) {
 		t.Errorf("Present(x/y/z).RelPath(x/y/z/a/b): got %#v, want %#v", got, want)
 	}
 }
+
+func TestCheckMessages(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		validator      Validator
+		want           string
+		wantNotPresent bool
+	}{{
+		desc:           "Equal/Missing",
+		validator:      Equal(HostName(), "thehost"),
+		want:           `/system/hostname: path not present, want "thehost"`,
+		wantNotPresent: true,
+	}, {
+		desc:      "Equal/Incorrect",
+		validator: Equal(HostName().WithValue("wronghost"), "thehost"),
+		want:      `/system/hostname: got "wronghost", want "thehost"`,
+	}, {
+		desc:           "NotEqual/Missing",
+		validator:      NotEqual(HostName(), "thehost"),
+		want:           `/system/hostname: path not present, want != "thehost"`,
+		wantNotPresent: true,
+	}, {
+		desc:      "EqualOrNil/Incorrect",
+		validator: EqualOrNil(HostName().WithValue("notthehost"), "thehost"),
+		want:      `/system/hostname: got "notthehost", want "thehost" or nil`,
+	}, {
+		desc:           "Present/Missing",
+		validator:      Present(HostName()),
+		want:           `/system/hostname: path not present, want any value`,
+		wantNotPresent: true,
+	}, {
+		desc:      "NotPresent/Present",
+		validator: NotPresent(HostName().WithValue("thehost")),
+		want:      `/system/hostname: got "thehost", want no value`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := tc.validator.Check(t)
+			if err == nil {
+				t.Fatalf("Got no error, want %q", tc.want)
+			}
+			if got := err.Error(); got != tc.want {
+				t.Errorf("Check() got error %q, want %q", got, tc.want)
+			}
+			if got := errors.Is(err, ErrNotPresent); got != tc.wantNotPresent {
+				t.Errorf("errors.Is(%v, ErrNotPresent) got %t, want %t", err, got, tc.wantNotPresent)
+			}
+		})
+	}
+}
+
+func TestCheckAll(t *testing.T) {
+	if err := CheckAll(t,
+		Equal(HostName().WithValue("thehost"), "thehost"),
+		Present(HostName().WithValue("thehost")),
+	); err != nil {
+		t.Errorf("CheckAll() got error %v, want nil", err)
+	}
+
+	err := CheckAll(t,
+		Equal(HostName(), "thehost"),
+		Equal(NewPath[int]("system/mtu").WithValue(1500), 9000),
+		Equal(HostName().WithValue("thehost"), "thehost"),
+		Present(NewPath[string]("system/domain-name")),
+	)
+	want := `3 of 4 checks failed:
+  /system/hostname: path not present, want "thehost"
+  /system/mtu: got 1500, want 9000
+  /system/domain-name: path not present, want any value`
+	if err == nil {
+		t.Fatalf("CheckAll() got no error, want %q", want)
+	}
+	if got := err.Error(); got != want {
+		t.Errorf("CheckAll() got error:\n%s\nwant:\n%s", got, want)
+	}
+	var f *Failures
+	if !errors.As(err, &f) {
+		t.Fatalf("CheckAll() got error of type %T, want *Failures", err)
+	}
+	if got, want := len(f.NotPresent()), 2; got != want {
+		t.Errorf("Failures.NotPresent() got %d errors, want %d", got, want)
+	}
+}
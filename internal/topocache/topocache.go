@@ -0,0 +1,149 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package topocache skips redundant pushes of an ATE topology and
+// restarts of its protocols, e.g. by each test of a package pushing
+// the same topology, which take time and reset the ARP state of the
+// ATE.  It remembers the topology object last pushed to each ATE and a
+// content hash of it, covering its interfaces, LAGs, networks and
+// protocol config, so the same topology is not pushed again unless it
+// was modified since.  Another topology object is always pushed, even
+// if built identically, since the flows of a test hold handles of the
+// interfaces of the topology object they were created from, which only
+// a push of that object binds; tests sharing a topology must share the
+// object.
+//
+// The cache only knows of the pushes and protocol starts it makes, so
+// a test using it must call Invalidate after changing the state of the
+// ATE otherwise, e.g. pushing a topology with Push, stopping its
+// protocols, or disabling its ports.
+package topocache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"testing"
+
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/ondatra"
+)
+
+// entry is the state of an ATE known to the cache: the topology object
+// last pushed, the hash of its content when pushed, and whether its
+// protocols were started since.
+type entry struct {
+	top     interface{}
+	hash    string
+	started bool
+}
+
+// cache maps the names of the ATEs to their state.
+type cache struct {
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+var topologies = &cache{entries: make(map[string]*entry)}
+
+// Hash returns the content hash of the topology, from its description
+// by String, which includes the config of each interface and LAG in the
+// order they were added, so a topology must be built in the same order
+// to hash the same.
+func Hash(top *ondatra.ATETopology) string {
+	sum := sha256.Sum256([]byte(top.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// pushLocked calls push unless the topology was the last pushed to the
+// ATE and still has the same hash, and returns its entry and whether it
+// pushed.  The caller holds c.mu.
+func (c *cache) pushLocked(name string, top interface{}, hash string, push func()) (*entry, bool) {
+	if e := c.entries[name]; e != nil && e.top == top && e.hash == hash {
+		return e, false
+	}
+	delete(c.entries, name)
+	push()
+	e := &entry{top: top, hash: hash}
+	c.entries[name] = e
+	return e, true
+}
+
+// ensurePushed calls push unless the topology was the last pushed to
+// the ATE and still has the same hash, and returns whether it pushed.
+func (c *cache) ensurePushed(name string, top interface{}, hash string, push func()) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, pushed := c.pushLocked(name, top, hash, push)
+	return pushed
+}
+
+// ensureStarted pushes the topology as ensurePushed does, then calls
+// start unless the protocols were already started since the topology
+// was pushed, and returns whether it started them.
+func (c *cache) ensureStarted(name string, top interface{}, hash string, push, start func()) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, _ := c.pushLocked(name, top, hash, push)
+	if e.started {
+		return false
+	}
+	start()
+	e.started = true
+	return true
+}
+
+// invalidate forgets the state of the ATE.
+func (c *cache) invalidate(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, name)
+}
+
+// EnsurePushed pushes the topology to the ATE, unless the same topology
+// object, unmodified, was the last pushed to the ATE by the cache since
+// Invalidate, and returns whether it pushed.  It is safe to call
+// concurrently, e.g. from a step of fptest.RunConcurrently.
+func EnsurePushed(t testing.TB, ate *ondatra.ATEDevice, top *ondatra.ATETopology) bool {
+	t.Helper()
+	defer fptest.TimeStep(t, "push ATE topology")()
+	hash := Hash(top)
+	pushed := topologies.ensurePushed(ate.Name(), top, hash, func() { top.Push(t) })
+	if !pushed {
+		t.Logf("Topology %.12s is already pushed to ATE %s, not pushing it again", hash, ate.Name())
+	}
+	return pushed
+}
+
+// EnsureStarted pushes the topology as EnsurePushed does, then starts
+// its protocols unless they were already started since it was pushed,
+// and returns whether it started them.  It replaces
+// top.Push(t).StartProtocols(t).
+func EnsureStarted(t testing.TB, ate *ondatra.ATEDevice, top *ondatra.ATETopology) bool {
+	t.Helper()
+	defer fptest.TimeStep(t, "start ATE topology")()
+	hash := Hash(top)
+	started := topologies.ensureStarted(ate.Name(), top, hash, func() { top.Push(t) }, func() { top.StartProtocols(t) })
+	if !started {
+		t.Logf("Protocols of topology %.12s are already started on ATE %s, not starting them again", hash, ate.Name())
+	}
+	return started
+}
+
+// Invalidate forgets the topology pushed to the ATE, so that the next
+// EnsurePushed or EnsureStarted pushes again, e.g. after the test
+// disabled ATE ports or stopped the protocols.
+func Invalidate(ate *ondatra.ATEDevice) {
+	topologies.invalidate(ate.Name())
+}
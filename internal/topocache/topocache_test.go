@@ -0,0 +1,113 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topocache
+
+import (
+	"testing"
+)
+
+// counter counts the pushes and protocol starts of the cache.
+type counter struct {
+	pushes, starts int
+}
+
+func (c *counter) push()  { c.pushes++ }
+func (c *counter) start() { c.starts++ }
+
+func TestEnsureStarted(t *testing.T) {
+	c := &cache{entries: make(map[string]*entry)}
+	var n counter
+	// top1 and top2 are distinct topology objects.
+	top1, top2 := new(int), new(int)
+	steps := []struct {
+		desc        string
+		name, hash  string
+		top         interface{}
+		invalidate  bool
+		wantStarted bool
+		want        counter
+	}{{
+		desc:        "first push",
+		name:        "ate",
+		top:         top1,
+		hash:        "a",
+		wantStarted: true,
+		want:        counter{pushes: 1, starts: 1},
+	}, {
+		desc: "same topology",
+		name: "ate",
+		top:  top1,
+		hash: "a",
+		want: counter{pushes: 1, starts: 1},
+	}, {
+		desc:        "other ATE",
+		name:        "ate2",
+		top:         top1,
+		hash:        "a",
+		wantStarted: true,
+		want:        counter{pushes: 2, starts: 2},
+	}, {
+		desc:        "modified topology",
+		name:        "ate",
+		top:         top1,
+		hash:        "b",
+		wantStarted: true,
+		want:        counter{pushes: 3, starts: 3},
+	}, {
+		desc:        "other topology with the same content",
+		name:        "ate",
+		top:         top2,
+		hash:        "b",
+		wantStarted: true,
+		want:        counter{pushes: 4, starts: 4},
+	}, {
+		desc:        "invalidated",
+		name:        "ate",
+		top:         top2,
+		hash:        "b",
+		invalidate:  true,
+		wantStarted: true,
+		want:        counter{pushes: 5, starts: 5},
+	}}
+	for _, s := range steps {
+		if s.invalidate {
+			c.invalidate(s.name)
+		}
+		if got := c.ensureStarted(s.name, s.top, s.hash, n.push, n.start); got != s.wantStarted {
+			t.Errorf("%s: ensureStarted got %v, want %v", s.desc, got, s.wantStarted)
+		}
+		if n != s.want {
+			t.Errorf("%s: got %+v, want %+v", s.desc, n, s.want)
+		}
+	}
+}
+
+func TestEnsurePushedThenStarted(t *testing.T) {
+	c := &cache{entries: make(map[string]*entry)}
+	var n counter
+	top := new(int)
+	if !c.ensurePushed("ate", top, "a", n.push) {
+		t.Errorf("ensurePushed of a new topology got false, want true")
+	}
+	if c.ensurePushed("ate", top, "a", n.push) {
+		t.Errorf("ensurePushed of the same topology got true, want false")
+	}
+	if !c.ensureStarted("ate", top, "a", n.push, n.start) {
+		t.Errorf("ensureStarted of the pushed topology got false, want true")
+	}
+	if want := (counter{pushes: 1, starts: 1}); n != want {
+		t.Errorf("got %+v, want %+v: the topology pushed once and its protocols started once", n, want)
+	}
+}
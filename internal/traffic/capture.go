@@ -0,0 +1,353 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/ondatra"
+)
+
+const (
+	// captureName is the name of the capture added to the OTG config by
+	// EnableCapture.
+	captureName = "capture"
+	// CapturePacketSize is the number of bytes captured of each packet,
+	// enough for the Ethernet, MPLS, IP and L4 headers of an
+	// encapsulated packet.
+	CapturePacketSize = 256
+	// FullCapturePacketSize is the number of bytes captured of each
+	// packet by EnableFullCapture, enough for a whole Ethernet frame.
+	FullCapturePacketSize = 1518
+	// MaxCapturedPackets is the maximum number of captured packets
+	// decoded by CapturedPackets.
+	MaxCapturedPackets = 10000
+)
+
+// IPHeader is the decoded view of an IPv4 or IPv6 header in a captured
+// packet.  For IPv6, TTL is the hop limit, DSCP is taken from the
+// traffic class, and Protocol is the next header.
+type IPHeader struct {
+	Version  uint8
+	Src      string
+	Dst      string
+	TTL      uint8
+	DSCP     uint8
+	Protocol layers.IPProtocol
+}
+
+// MPLSLabel is the decoded view of an MPLS label stack entry in a
+// captured packet.
+type MPLSLabel struct {
+	Label         uint32
+	TC            uint8
+	TTL           uint8
+	BottomOfStack bool
+}
+
+// Packet is the decoded view of a captured packet.  MPLS holds the
+// label stack from the top, and IP holds the IP headers from the
+// outermost to the innermost, so an IP-in-IP packet has two.  LLDP is
+// only set for an LLDP frame, ND for an IPv6 neighbor discovery
+// message, Syslog for a syslog message sent to SyslogPort, and DHCPv4
+// and SFlow for a DHCPv4 message and an sFlow datagram captured whole,
+// e.g. by EnableFullCapture.  Time is when the packet was captured.
+type Packet struct {
+	Time    time.Time
+	MPLS    []*MPLSLabel
+	IP      []*IPHeader
+	LLDP    *LLDP
+	ND      *ND
+	Syslog  *Syslog
+	DHCPv4  *DHCPv4
+	SFlow   *SFlow
+	Payload []byte
+}
+
+// Outer returns the outermost IP header, or nil if there is none.
+func (p *Packet) Outer() *IPHeader {
+	if len(p.IP) == 0 {
+		return nil
+	}
+	return p.IP[0]
+}
+
+// Inner returns the IP header encapsulated by the outermost one, or
+// nil if the packet is not encapsulated.
+func (p *Packet) Inner() *IPHeader {
+	if len(p.IP) < 2 {
+		return nil
+	}
+	return p.IP[1]
+}
+
+// Labels returns the labels of the MPLS label stack of the packet from
+// the top, e.g. to verify that a label was swapped or popped.
+func (p *Packet) Labels() []uint32 {
+	var labels []uint32
+	for _, l := range p.MPLS {
+		labels = append(labels, l.Label)
+	}
+	return labels
+}
+
+// DecodePCAP decodes the Ethernet packets in a PCAP file.
+func DecodePCAP(b []byte) ([]*Packet, error) {
+	return decodePCAP(b, 0)
+}
+
+// decodePCAP decodes at most max Ethernet packets in a PCAP file, or
+// all of them if max is zero.
+func decodePCAP(b []byte, max int) ([]*Packet, error) {
+	r, err := pcapgo.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read pcap: %w", err)
+	}
+	var pkts []*Packet
+	for max == 0 || len(pkts) < max {
+		data, ci, err := r.ReadPacketData()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read packet %d: %w", len(pkts), err)
+		}
+		p := decodePacket(data)
+		p.Time = ci.Timestamp
+		pkts = append(pkts, p)
+	}
+	return pkts, nil
+}
+
+// DecodeFrame decodes an Ethernet frame received other than in a
+// capture, e.g. the payload of a P4RT PacketIn.
+func DecodeFrame(data []byte) *Packet {
+	return decodePacket(data)
+}
+
+// decodePacket decodes an Ethernet frame.
+func decodePacket(data []byte) *Packet {
+	p := &Packet{}
+	pkt := gopacket.NewPacket(data, layers.LayerTypeEthernet, gopacket.Default)
+	for _, l := range pkt.Layers() {
+		switch l := l.(type) {
+		case *layers.Ethernet:
+			// The LLDPDU is decoded from the Ethernet payload, since
+			// a truncated capture fails to decode as an LLDP layer.
+			if l.EthernetType == LLDPEtherType {
+				p.LLDP = decodeLLDP(l.Payload)
+			}
+		case *layers.MPLS:
+			p.MPLS = append(p.MPLS, &MPLSLabel{
+				Label:         l.Label,
+				TC:            l.TrafficClass,
+				TTL:           l.TTL,
+				BottomOfStack: l.StackBottom,
+			})
+		case *layers.IPv4:
+			p.IP = append(p.IP, &IPHeader{
+				Version:  4,
+				Src:      l.SrcIP.String(),
+				Dst:      l.DstIP.String(),
+				TTL:      l.TTL,
+				DSCP:     l.TOS >> 2,
+				Protocol: l.Protocol,
+			})
+		case *layers.IPv6:
+			p.IP = append(p.IP, &IPHeader{
+				Version:  6,
+				Src:      l.SrcIP.String(),
+				Dst:      l.DstIP.String(),
+				TTL:      l.HopLimit,
+				DSCP:     l.TrafficClass >> 2,
+				Protocol: l.NextHeader,
+			})
+		case *layers.ICMPv6NeighborSolicitation, *layers.ICMPv6NeighborAdvertisement, *layers.ICMPv6RouterAdvertisement:
+			p.ND = decodeND(l)
+		case *layers.DHCPv4:
+			p.DHCPv4 = decodeDHCPv4(l)
+		case *layers.SFlowDatagram:
+			p.SFlow = decodeSFlow(l)
+		case *layers.UDP:
+			if l.DstPort == SyslogPort {
+				p.Syslog = decodeSyslog(l.Payload)
+			}
+		}
+	}
+	if app := pkt.ApplicationLayer(); app != nil {
+		p.Payload = app.Payload()
+	}
+	return p
+}
+
+// EnableCapture adds a capture on the named ports to the OTG config.  It
+// must be called before the config is pushed.
+func EnableCapture(top gosnappi.Config, ports ...string) {
+	addCapture(top, captureName, ports, CapturePacketSize)
+}
+
+// EnableFullCapture adds a capture of whole packets on the named ports to
+// the OTG config, for the messages that do not fit CapturePacketSize,
+// e.g. DHCPv4 and sFlow.  As with EnableCapture, it must be called
+// before the config is pushed.
+func EnableFullCapture(top gosnappi.Config, ports ...string) {
+	addCapture(top, captureName, ports, FullCapturePacketSize)
+}
+
+// addCapture adds a capture of the first size bytes of each packet
+// received on the ports to the OTG config.  The capture buffer wraps
+// around rather than growing without bound.
+func addCapture(top gosnappi.Config, name string, ports []string, size int32) {
+	top.Captures().Add().
+		SetName(name).
+		SetPortNames(ports).
+		SetFormat(gosnappi.CaptureFormat.PCAP).
+		SetPacketSize(size).
+		SetOverwrite(true)
+}
+
+// removeCapture removes the named capture from the OTG config.
+func removeCapture(top gosnappi.Config, name string) {
+	var keep []gosnappi.Capture
+	for _, c := range top.Captures().Items() {
+		if c.Name() != name {
+			keep = append(keep, c)
+		}
+	}
+	top.Captures().Clear().Append(keep...)
+}
+
+// captureAPI is the part of the raw OTG API that runs packet captures.
+type captureAPI interface {
+	SetCaptureState(gosnappi.CaptureState) (gosnappi.ResponseWarning, error)
+	GetCapture(gosnappi.CaptureRequest) ([]byte, error)
+}
+
+// otgAPI returns the raw OTG API of the ATE, which runs the captures
+// that the Ondatra OTG API does not.  It is only available to tests run
+// with fptest.RunTests, so StartCapture, StopCapture and
+// CapturedPackets fail the test otherwise.
+func otgAPI(t testing.TB, ate *ondatra.ATEDevice) captureAPI {
+	t.Helper()
+	api, err := fptest.RawOTG(context.Background(), ate)
+	if err != nil {
+		t.Fatalf("Cannot get the OTG API of %s: %v", ate.Name(), err)
+	}
+	return api
+}
+
+// StartCapture starts capturing packets on the named ports of the ATE.
+func StartCapture(t testing.TB, ate *ondatra.ATEDevice, ports ...string) {
+	t.Helper()
+	setCaptureState(t, otgAPI(t, ate), ports, gosnappi.CaptureStateState.START)
+}
+
+// StopCapture stops capturing packets on the named ports of the ATE.
+func StopCapture(t testing.TB, ate *ondatra.ATEDevice, ports ...string) {
+	t.Helper()
+	setCaptureState(t, otgAPI(t, ate), ports, gosnappi.CaptureStateState.STOP)
+}
+
+// setCaptureState starts or stops capturing packets on the ports.
+func setCaptureState(t testing.TB, api captureAPI, ports []string, state gosnappi.CaptureStateStateEnum) {
+	t.Helper()
+	cs := gosnappi.NewCaptureState().SetPortNames(ports).SetState(state)
+	w, err := api.SetCaptureState(cs)
+	if err != nil {
+		t.Fatalf("Cannot set capture state %s on ports %v: %v", state, ports, err)
+	}
+	for _, warning := range w.Warnings() {
+		t.Logf("Capture state %s on ports %v: %s", state, ports, warning)
+	}
+}
+
+// CapturedPackets retrieves and decodes the first MaxCapturedPackets
+// packets captured on the named port of the ATE.
+func CapturedPackets(t testing.TB, ate *ondatra.ATEDevice, port string) []*Packet {
+	t.Helper()
+	return capturedPackets(t, otgAPI(t, ate), port)
+}
+
+// capturedPackets retrieves and decodes the packets captured on the
+// port, as CapturedPackets does.
+func capturedPackets(t testing.TB, api captureAPI, port string) []*Packet {
+	t.Helper()
+	b, err := api.GetCapture(gosnappi.NewCaptureRequest().SetPortName(port))
+	if err != nil {
+		t.Fatalf("Cannot get capture on port %s: %v", port, err)
+	}
+	pkts, err := decodePCAP(b, MaxCapturedPackets)
+	if err != nil {
+		t.Fatalf("Cannot decode capture on port %s: %v", port, err)
+	}
+	t.Logf("Captured %d packets on port %s", len(pkts), port)
+	return pkts
+}
+
+// Capture captures the packets received on an ATE port while traffic
+// is running.
+type Capture struct {
+	ate     *ondatra.ATEDevice
+	top     gosnappi.Config
+	name    string
+	port    string
+	running bool
+}
+
+// NewCapture adds a capture on the ATE port to the OTG config.  It must
+// be called before the config is pushed.  When the test ends, the
+// capture is stopped if it is still running and removed from the
+// config, so that it is not pushed again.
+func NewCapture(t testing.TB, ate *ondatra.ATEDevice, top gosnappi.Config, ap *ondatra.Port) *Capture {
+	t.Helper()
+	c := &Capture{
+		ate:  ate,
+		top:  top,
+		name: captureName + "-" + ap.ID(),
+		port: ap.ID(),
+	}
+	addCapture(top, c.name, []string{c.port}, CapturePacketSize)
+	t.Cleanup(func() {
+		if c.running {
+			StopCapture(t, c.ate, c.port)
+		}
+		removeCapture(c.top, c.name)
+	})
+	return c
+}
+
+// Run starts the capture, runs the traffic in the pushed config for d,
+// stops the capture and returns the packets captured.
+func (c *Capture) Run(t testing.TB, d time.Duration) []*Packet {
+	t.Helper()
+	StartCapture(t, c.ate, c.port)
+	c.running = true
+	c.ate.OTG().StartTraffic(t)
+	time.Sleep(d)
+	c.ate.OTG().StopTraffic(t)
+	StopCapture(t, c.ate, c.port)
+	c.running = false
+	return CapturedPackets(t, c.ate, c.port)
+}
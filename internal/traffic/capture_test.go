@@ -0,0 +1,248 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+// ignoreTime ignores the capture time of the packets, which is checked
+// separately.
+var ignoreTime = cmpopts.IgnoreFields(Packet{}, "Time")
+
+// serialize returns an Ethernet frame with the given layers after the
+// Ethernet header.
+func serialize(t *testing.T, ls ...gopacket.SerializableLayer) []byte {
+	t.Helper()
+	eth := &layers.Ethernet{
+		SrcMAC:       net.HardwareAddr{0x02, 0, 0, 0, 0, 1},
+		DstMAC:       net.HardwareAddr{0x02, 0, 0, 0, 0, 2},
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	switch ls[0].(type) {
+	case *layers.MPLS:
+		eth.EthernetType = layers.EthernetTypeMPLSUnicast
+	case *layers.IPv6:
+		eth.EthernetType = layers.EthernetTypeIPv6
+	case *layers.LinkLayerDiscovery:
+		eth.EthernetType = layers.EthernetTypeLinkLayerDiscovery
+	}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, append([]gopacket.SerializableLayer{eth}, ls...)...); err != nil {
+		t.Fatalf("Cannot serialize packet: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// pcap returns a PCAP file with the given frames.
+func pcap(t *testing.T, frames ...[]byte) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	w := pcapgo.NewWriter(&b)
+	if err := w.WriteFileHeader(65536, layers.LinkTypeEthernet); err != nil {
+		t.Fatalf("Cannot write pcap header: %v", err)
+	}
+	for _, f := range frames {
+		ci := gopacket.CaptureInfo{Timestamp: time.Unix(0, 0), CaptureLength: len(f), Length: len(f)}
+		if err := w.WritePacket(ci, f); err != nil {
+			t.Fatalf("Cannot write packet: %v", err)
+		}
+	}
+	return b.Bytes()
+}
+
+func TestDecodePCAP(t *testing.T) {
+	payload := gopacket.Payload("hello")
+	outer := &layers.IPv4{
+		Version:  4,
+		TTL:      64,
+		TOS:      10 << 2,
+		Protocol: layers.IPProtocolIPv4,
+		SrcIP:    net.ParseIP("192.0.2.5"),
+		DstIP:    net.ParseIP("192.0.2.6"),
+	}
+	inner := &layers.IPv4{
+		Version:  4,
+		TTL:      63,
+		Protocol: layers.IPProtocolUDP,
+		SrcIP:    net.ParseIP("192.0.2.1"),
+		DstIP:    net.ParseIP("198.51.100.1"),
+	}
+	udp := &layers.UDP{SrcPort: 1024, DstPort: 2048}
+	udp.SetNetworkLayerForChecksum(inner)
+
+	b := pcap(t,
+		serialize(t, outer, inner, udp, payload),
+		serialize(t, inner, udp, payload),
+	)
+	got, err := DecodePCAP(b)
+	if err != nil {
+		t.Fatalf("DecodePCAP() got error: %v", err)
+	}
+
+	outerHdr := &IPHeader{Version: 4, Src: "192.0.2.5", Dst: "192.0.2.6", TTL: 64, DSCP: 10, Protocol: layers.IPProtocolIPv4}
+	innerHdr := &IPHeader{Version: 4, Src: "192.0.2.1", Dst: "198.51.100.1", TTL: 63, Protocol: layers.IPProtocolUDP}
+	want := []*Packet{{
+		IP:      []*IPHeader{outerHdr, innerHdr},
+		Payload: []byte("hello"),
+	}, {
+		IP:      []*IPHeader{innerHdr},
+		Payload: []byte("hello"),
+	}}
+	if diff := cmp.Diff(want, got, ignoreTime); diff != "" {
+		t.Errorf("DecodePCAP() -want,+got:\n%s", diff)
+	}
+
+	if got := got[0].Inner(); !cmp.Equal(got, innerHdr) {
+		t.Errorf("Inner() got %+v, want %+v", got, innerHdr)
+	}
+	if got := got[1].Inner(); got != nil {
+		t.Errorf("Inner() of unencapsulated packet got %+v, want nil", got)
+	}
+}
+
+func TestDecodePCAPMPLSIPv6(t *testing.T) {
+	payload := gopacket.Payload("hello")
+	top := &layers.MPLS{Label: 100, TrafficClass: 3, TTL: 64}
+	bottom := &layers.MPLS{Label: 200, TTL: 64, StackBottom: true}
+	v6 := &layers.IPv6{
+		Version:      6,
+		TrafficClass: 46 << 2,
+		HopLimit:     63,
+		NextHeader:   layers.IPProtocolUDP,
+		SrcIP:        net.ParseIP("2001:db8::1"),
+		DstIP:        net.ParseIP("2001:db8:1::1"),
+	}
+	udp := &layers.UDP{SrcPort: 1024, DstPort: 2048}
+	udp.SetNetworkLayerForChecksum(v6)
+
+	b := pcap(t,
+		serialize(t, top, bottom, v6, udp, payload),
+		serialize(t, v6, udp, payload),
+	)
+	got, err := DecodePCAP(b)
+	if err != nil {
+		t.Fatalf("DecodePCAP() got error: %v", err)
+	}
+
+	v6Hdr := &IPHeader{Version: 6, Src: "2001:db8::1", Dst: "2001:db8:1::1", TTL: 63, DSCP: 46, Protocol: layers.IPProtocolUDP}
+	want := []*Packet{{
+		MPLS: []*MPLSLabel{
+			{Label: 100, TC: 3, TTL: 64},
+			{Label: 200, TTL: 64, BottomOfStack: true},
+		},
+		IP:      []*IPHeader{v6Hdr},
+		Payload: []byte("hello"),
+	}, {
+		IP:      []*IPHeader{v6Hdr},
+		Payload: []byte("hello"),
+	}}
+	if diff := cmp.Diff(want, got, ignoreTime); diff != "" {
+		t.Errorf("DecodePCAP() -want,+got:\n%s", diff)
+	}
+}
+
+func TestDecodePCAPMax(t *testing.T) {
+	ip := &layers.IPv4{
+		Version:  4,
+		TTL:      64,
+		Protocol: layers.IPProtocolUDP,
+		SrcIP:    net.ParseIP("192.0.2.1"),
+		DstIP:    net.ParseIP("198.51.100.1"),
+	}
+	udp := &layers.UDP{SrcPort: 1024, DstPort: 2048}
+	udp.SetNetworkLayerForChecksum(ip)
+	frame := serialize(t, ip, udp, gopacket.Payload("hello"))
+
+	got, err := decodePCAP(pcap(t, frame, frame, frame), 2)
+	if err != nil {
+		t.Fatalf("decodePCAP() got error: %v", err)
+	}
+	if len(got) != 2 {
+		t.Errorf("decodePCAP() got %d packets, want 2", len(got))
+	}
+}
+
+func TestDecodePCAPError(t *testing.T) {
+	if _, err := DecodePCAP([]byte("not a pcap")); err == nil {
+		t.Errorf("DecodePCAP() got no error, want error")
+	}
+}
+
+// fakeCaptureAPI records the capture states set and returns a PCAP
+// file for every capture retrieved.
+type fakeCaptureAPI struct {
+	states []gosnappi.CaptureState
+	reqs   []gosnappi.CaptureRequest
+	pcap   []byte
+}
+
+func (f *fakeCaptureAPI) SetCaptureState(cs gosnappi.CaptureState) (gosnappi.ResponseWarning, error) {
+	f.states = append(f.states, cs)
+	return gosnappi.NewResponseWarning(), nil
+}
+
+func (f *fakeCaptureAPI) GetCapture(req gosnappi.CaptureRequest) ([]byte, error) {
+	f.reqs = append(f.reqs, req)
+	return f.pcap, nil
+}
+
+var _ captureAPI = gosnappi.NewApi()
+
+func TestCaptureAPI(t *testing.T) {
+	ip := &layers.IPv4{
+		Version:  4,
+		TTL:      64,
+		Protocol: layers.IPProtocolUDP,
+		SrcIP:    net.ParseIP("192.0.2.1"),
+		DstIP:    net.ParseIP("198.51.100.1"),
+	}
+	udp := &layers.UDP{SrcPort: 1024, DstPort: 2048}
+	udp.SetNetworkLayerForChecksum(ip)
+	api := &fakeCaptureAPI{pcap: pcap(t, serialize(t, ip, udp, gopacket.Payload("hello")))}
+
+	setCaptureState(t, api, []string{"port1", "port2"}, gosnappi.CaptureStateState.START)
+	setCaptureState(t, api, []string{"port1", "port2"}, gosnappi.CaptureStateState.STOP)
+	pkts := capturedPackets(t, api, "port2")
+
+	var states []gosnappi.CaptureStateStateEnum
+	for _, cs := range api.states {
+		if diff := cmp.Diff([]string{"port1", "port2"}, cs.PortNames()); diff != "" {
+			t.Errorf("SetCaptureState() port names -want,+got:\n%s", diff)
+		}
+		states = append(states, cs.State())
+	}
+	wantStates := []gosnappi.CaptureStateStateEnum{gosnappi.CaptureStateState.START, gosnappi.CaptureStateState.STOP}
+	if diff := cmp.Diff(wantStates, states); diff != "" {
+		t.Errorf("SetCaptureState() states -want,+got:\n%s", diff)
+	}
+	if len(api.reqs) != 1 || api.reqs[0].PortName() != "port2" {
+		t.Errorf("GetCapture() got requests %v, want one for port2", api.reqs)
+	}
+	if len(pkts) != 1 || string(pkts[0].Payload) != "hello" {
+		t.Errorf("capturedPackets() got %v, want one packet with payload hello", pkts)
+	}
+}
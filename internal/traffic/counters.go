@@ -0,0 +1,294 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+)
+
+// counterPollInterval is how often StableCounters reads the DUT
+// counters.  It is longer than stablePollInterval, since DUTs refresh
+// their interface counters less often than the ATE.
+const counterPollInterval = 5 * time.Second
+
+// DefaultCounterSlack is how many more packets than the ATE measured
+// the DUT counters may count by default in MatchesFlow, for the
+// control-plane packets sent and received over the same ports.
+const DefaultCounterSlack = 100
+
+// Names of the interface counters read by SnapshotCounters.
+const (
+	InUnicastPkts  = "in-unicast-pkts"
+	OutUnicastPkts = "out-unicast-pkts"
+	InOctets       = "in-octets"
+	OutOctets      = "out-octets"
+	InDiscards     = "in-discards"
+	InErrors       = "in-errors"
+)
+
+// snapshotCounters are the counters read by SnapshotCounters, in the
+// order they are reported.
+var snapshotCounters = []string{InUnicastPkts, OutUnicastPkts, InOctets, OutOctets, InDiscards, InErrors}
+
+// PortCounters are the interface counters of a DUT port, by name.
+// Counters the DUT does not report are omitted.
+type PortCounters map[string]uint64
+
+// portCounters returns the counters of snapshotCounters that c reports.
+func portCounters(c *telemetry.Interface_Counters) PortCounters {
+	vals := map[string]*uint64{
+		InUnicastPkts:  c.InUnicastPkts,
+		OutUnicastPkts: c.OutUnicastPkts,
+		InOctets:       c.InOctets,
+		OutOctets:      c.OutOctets,
+		InDiscards:     c.InDiscards,
+		InErrors:       c.InErrors,
+	}
+	pc := PortCounters{}
+	for name, v := range vals {
+		if v != nil {
+			pc[name] = *v
+		}
+	}
+	return pc
+}
+
+// CounterSnapshot holds the interface counters of DUT ports, by port
+// ID.
+type CounterSnapshot map[string]PortCounters
+
+// SnapshotCounters reads the unicast packet, octet, discard and error
+// counters of the DUT ports with the given IDs.  Take a snapshot before
+// and after running traffic, and use Diff to get what the traffic
+// incremented.
+func SnapshotCounters(t testing.TB, dut *ondatra.DUTDevice, portIDs ...string) CounterSnapshot {
+	t.Helper()
+	s := CounterSnapshot{}
+	for _, id := range portIDs {
+		s[id] = PortCounters{}
+		if q := dut.Telemetry().Interface(dut.Port(t, id).Name()).Counters().Lookup(t); q.IsPresent() {
+			s[id] = portCounters(q.Val(t))
+		}
+	}
+	return s
+}
+
+// StableCounters reads the counters of the DUT ports as
+// SnapshotCounters does, until two reads in a row are the same or
+// stableTimeout elapses.  DUTs may update their interface counters some
+// time after traffic stops, so take the snapshot after traffic with
+// StableCounters to include all of it.
+func StableCounters(t testing.TB, dut *ondatra.DUTDevice, portIDs ...string) CounterSnapshot {
+	t.Helper()
+	var last CounterSnapshot
+	if !pollUntil(counterPollInterval, stableTimeout, func() bool {
+		s := SnapshotCounters(t, dut, portIDs...)
+		same := last != nil && reflect.DeepEqual(s, last)
+		last = s
+		return same
+	}) {
+		t.Logf("Counters of DUT ports %v did not stabilize within %v", portIDs, stableTimeout)
+	}
+	return last
+}
+
+// Diff returns how much the counters incremented since the before
+// snapshot.  Counters missing from either snapshot are unsupported.
+func (s CounterSnapshot) Diff(before CounterSnapshot) *CounterDiff {
+	d := &CounterDiff{
+		Deltas:      map[string]PortCounters{},
+		Unsupported: map[string][]string{},
+	}
+	for id, after := range s {
+		d.Deltas[id] = PortCounters{}
+		for _, name := range snapshotCounters {
+			a, aok := after[name]
+			b, bok := before[id][name]
+			if !aok || !bok {
+				d.Unsupported[id] = append(d.Unsupported[id], name)
+				continue
+			}
+			d.Deltas[id][name] = increment(b, a)
+		}
+	}
+	return d
+}
+
+// CounterDiff holds how much the interface counters of DUT ports
+// incremented between two snapshots.
+type CounterDiff struct {
+	// Deltas are the increments of the counters, by port ID and name.
+	Deltas map[string]PortCounters
+	// Unsupported are the names of the counters the DUT did not report,
+	// by port ID.  They are not in Deltas, rather than being zero.
+	Unsupported map[string][]string
+}
+
+// counter returns the increment of the named counter of the port, or an
+// error if the DUT does not report it.
+func (d *CounterDiff) counter(id, name string) (uint64, error) {
+	v, ok := d.Deltas[id][name]
+	if !ok {
+		return 0, fmt.Errorf("DUT port %s does not report %s", id, name)
+	}
+	return v, nil
+}
+
+// NoDrops returns an error if the in-discards or in-errors counter of
+// any of the ports incremented.  Unsupported counters are skipped, but
+// it is an error if a port supports neither.
+func (d *CounterDiff) NoDrops(portIDs ...string) error {
+	var errs []string
+	for _, id := range portIDs {
+		supported := false
+		for _, name := range []string{InDiscards, InErrors} {
+			v, err := d.counter(id, name)
+			if err != nil {
+				continue
+			}
+			supported = true
+			if v != 0 {
+				errs = append(errs, fmt.Sprintf("DUT port %s %s got %d, want 0", id, name, v))
+			}
+		}
+		if !supported {
+			errs = append(errs, fmt.Sprintf("DUT port %s reports neither %s nor %s", id, InDiscards, InErrors))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// ForwardedAtLeast returns an error unless the in-unicast-pkts counter
+// of the ingress port and the sum of the out-unicast-pkts counters of
+// the egress ports incremented by at least n, e.g. the packets the ATE
+// sent.  Other traffic may increment the counters as well.
+func (d *CounterDiff) ForwardedAtLeast(n uint64, in string, outs ...string) error {
+	rx, err := d.counter(in, InUnicastPkts)
+	if err != nil {
+		return err
+	}
+	if rx < n {
+		return fmt.Errorf("DUT port %s %s got %d, want at least %d", in, InUnicastPkts, rx, n)
+	}
+	var tx uint64
+	for _, id := range outs {
+		v, err := d.counter(id, OutUnicastPkts)
+		if err != nil {
+			return err
+		}
+		tx += v
+	}
+	if tx < n {
+		return fmt.Errorf("DUT ports %s %s got %d, want at least %d", strings.Join(outs, ", "), OutUnicastPkts, tx, n)
+	}
+	return nil
+}
+
+// within returns an error unless the counter of the ports got at least
+// want and at most slack more.
+func within(ports, name string, got, want, slack uint64) error {
+	if got < want || got-want > slack {
+		return fmt.Errorf("DUT %s %s got %d, want %d up to %d more", ports, name, got, want, slack)
+	}
+	return nil
+}
+
+// MatchesFlow returns an error unless the DUT counters agree with the
+// ATE statistics of the flow result r: the in-unicast-pkts counter of
+// the ingress port must have incremented by the packets the ATE sent,
+// and the sum of the out-unicast-pkts counters of the egress ports by
+// the packets the ATE received, each up to slack more for control-plane
+// packets.  The counters must be snapshotted right before and after the
+// flow, with no other traffic on the ports.  If the flow was retried,
+// the counters must agree with both attempts, and the egress ports may
+// count up to the packets lost in the first attempt more, since the ATE
+// may have miscounted them.  It returns nil with
+// --deviation_interface_counters_unreliable.
+func (d *CounterDiff) MatchesFlow(r *Result, slack uint64, in string, outs ...string) error {
+	if *deviations.InterfaceCountersUnreliable {
+		return nil
+	}
+	sent, received, outSlack := r.OutPkts, r.InPkts, slack
+	if f := r.FirstAttempt; f != nil {
+		sent += f.OutPkts
+		received += f.InPkts
+		if f.InPkts < f.OutPkts {
+			outSlack += f.OutPkts - f.InPkts
+		}
+	}
+	var errs []string
+	if rx, err := d.counter(in, InUnicastPkts); err != nil {
+		errs = append(errs, err.Error())
+	} else if err := within("port "+in, InUnicastPkts, rx, sent, slack); err != nil {
+		errs = append(errs, fmt.Sprintf("%v, the packets the ATE sent in flow %s", err, r.Flow))
+	}
+	var tx uint64
+	supported := true
+	for _, id := range outs {
+		v, err := d.counter(id, OutUnicastPkts)
+		if err != nil {
+			errs = append(errs, err.Error())
+			supported = false
+			continue
+		}
+		tx += v
+	}
+	if supported {
+		if err := within("ports "+strings.Join(outs, ", "), OutUnicastPkts, tx, received, outSlack); err != nil {
+			errs = append(errs, fmt.Sprintf("%v, the packets the ATE received in flow %s", err, r.Flow))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// String lists the increments and the unsupported counters of each port
+// in port ID order for the test log.
+func (d *CounterDiff) String() string {
+	ids := make([]string, 0, len(d.Deltas))
+	for id := range d.Deltas {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	var lines []string
+	for _, id := range ids {
+		var parts []string
+		for _, name := range snapshotCounters {
+			if v, ok := d.Deltas[id][name]; ok {
+				parts = append(parts, fmt.Sprintf("%s %d", name, v))
+			}
+		}
+		if u := d.Unsupported[id]; len(u) > 0 {
+			parts = append(parts, "unsupported: "+strings.Join(u, ", "))
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", id, strings.Join(parts, ", ")))
+	}
+	return strings.Join(lines, "\n")
+}
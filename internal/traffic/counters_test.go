@@ -0,0 +1,147 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/openconfig/ondatra/telemetry"
+	"github.com/openconfig/ygot/ygot"
+)
+
+func TestPortCounters(t *testing.T) {
+	c := &telemetry.Interface_Counters{
+		InUnicastPkts: ygot.Uint64(100),
+		InDiscards:    ygot.Uint64(0),
+	}
+	want := PortCounters{InUnicastPkts: 100, InDiscards: 0}
+	if diff := cmp.Diff(want, portCounters(c)); diff != "" {
+		t.Errorf("portCounters() -want,+got:\n%s", diff)
+	}
+}
+
+// newCounterDiff returns the diff of 1000 packets forwarded from port1
+// to port2, where port2 does not report in-errors.
+func newCounterDiff() *CounterDiff {
+	before := CounterSnapshot{
+		"port1": {InUnicastPkts: 10, OutUnicastPkts: 5, InOctets: 640, OutOctets: 320, InDiscards: 1, InErrors: 0},
+		"port2": {InUnicastPkts: 5, OutUnicastPkts: 10, InOctets: 320, OutOctets: 640, InDiscards: 0},
+	}
+	after := CounterSnapshot{
+		"port1": {InUnicastPkts: 1010, OutUnicastPkts: 5, InOctets: 64640, OutOctets: 320, InDiscards: 1, InErrors: 0},
+		"port2": {InUnicastPkts: 5, OutUnicastPkts: 1010, InOctets: 320, OutOctets: 64640, InDiscards: 0},
+	}
+	return after.Diff(before)
+}
+
+func TestCounterDiff(t *testing.T) {
+	d := newCounterDiff()
+	wantDeltas := map[string]PortCounters{
+		"port1": {InUnicastPkts: 1000, OutUnicastPkts: 0, InOctets: 64000, OutOctets: 0, InDiscards: 0, InErrors: 0},
+		"port2": {InUnicastPkts: 0, OutUnicastPkts: 1000, InOctets: 0, OutOctets: 64000, InDiscards: 0},
+	}
+	if diff := cmp.Diff(wantDeltas, d.Deltas); diff != "" {
+		t.Errorf("Diff() Deltas -want,+got:\n%s", diff)
+	}
+	wantUnsupported := map[string][]string{"port2": {InErrors}}
+	if diff := cmp.Diff(wantUnsupported, d.Unsupported); diff != "" {
+		t.Errorf("Diff() Unsupported -want,+got:\n%s", diff)
+	}
+	want := "port1: in-unicast-pkts 1000, out-unicast-pkts 0, in-octets 64000, out-octets 0, in-discards 0, in-errors 0\n" +
+		"port2: in-unicast-pkts 0, out-unicast-pkts 1000, in-octets 0, out-octets 64000, in-discards 0, unsupported: in-errors"
+	if got := d.String(); got != want {
+		t.Errorf("String() got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCounterDiffMissingPort(t *testing.T) {
+	after := CounterSnapshot{"port1": {InDiscards: 5}}
+	d := after.Diff(CounterSnapshot{})
+	if got := len(d.Deltas["port1"]); got != 0 {
+		t.Errorf("Diff() got %d deltas for port1, want 0", got)
+	}
+	if got, want := len(d.Unsupported["port1"]), len(snapshotCounters); got != want {
+		t.Errorf("Diff() got %d unsupported counters for port1, want %d", got, want)
+	}
+}
+
+func TestNoDrops(t *testing.T) {
+	d := newCounterDiff()
+	if err := d.NoDrops("port1", "port2"); err != nil {
+		t.Errorf("NoDrops() got error %v, want nil", err)
+	}
+	d.Deltas["port1"][InErrors] = 3
+	if err := d.NoDrops("port1"); err == nil {
+		t.Errorf("NoDrops() with in-errors got nil error, want error")
+	}
+	if err := d.NoDrops("port3"); err == nil {
+		t.Errorf("NoDrops() with unsupported counters got nil error, want error")
+	}
+}
+
+func TestForwardedAtLeast(t *testing.T) {
+	cases := []struct {
+		desc    string
+		n       uint64
+		in      string
+		outs    []string
+		wantErr bool
+	}{
+		{"forwarded", 1000, "port1", []string{"port2"}, false},
+		{"fewer received", 1001, "port1", []string{"port2"}, true},
+		{"fewer transmitted", 1000, "port1", []string{"port1"}, true},
+		{"several egress ports", 1000, "port1", []string{"port1", "port2"}, false},
+		{"unsupported", 1, "port3", []string{"port2"}, true},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			err := newCounterDiff().ForwardedAtLeast(c.n, c.in, c.outs...)
+			if gotErr := err != nil; gotErr != c.wantErr {
+				t.Errorf("ForwardedAtLeast(%d, %s, %v) got error %v, want error %t", c.n, c.in, c.outs, err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestMatchesFlow(t *testing.T) {
+	cases := []struct {
+		desc    string
+		r       *Result
+		slack   uint64
+		in      string
+		outs    []string
+		wantErr bool
+	}{
+		{"match", &Result{Flow: "f", OutPkts: 1000, InPkts: 1000}, 0, "port1", []string{"port2"}, false},
+		{"control plane within slack", &Result{Flow: "f", OutPkts: 990, InPkts: 995}, 10, "port1", []string{"port2"}, false},
+		{"control plane above slack", &Result{Flow: "f", OutPkts: 990, InPkts: 995}, 5, "port1", []string{"port2"}, true},
+		{"DUT counted fewer received", &Result{Flow: "f", OutPkts: 1001, InPkts: 1000}, 10, "port1", []string{"port2"}, true},
+		{"DUT counted fewer transmitted", &Result{Flow: "f", OutPkts: 1000, InPkts: 1001}, 10, "port1", []string{"port2"}, true},
+		{"ATE lost packets", &Result{Flow: "f", OutPkts: 1000, InPkts: 900}, 10, "port1", []string{"port2"}, true},
+		{"several egress ports", &Result{Flow: "f", OutPkts: 1000, InPkts: 1000}, 0, "port1", []string{"port1", "port2"}, false},
+		{"unsupported", &Result{Flow: "f", OutPkts: 1000, InPkts: 1000}, 0, "port1", []string{"port3"}, true},
+		{"retried", &Result{Flow: "f", OutPkts: 500, InPkts: 500, FirstAttempt: &Result{OutPkts: 500, InPkts: 495}}, 0, "port1", []string{"port2"}, false},
+		{"retried with second attempt loss", &Result{Flow: "f", OutPkts: 500, InPkts: 495, FirstAttempt: &Result{OutPkts: 500, InPkts: 498}}, 0, "port1", []string{"port2"}, true},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			err := newCounterDiff().MatchesFlow(c.r, c.slack, c.in, c.outs...)
+			if gotErr := err != nil; gotErr != c.wantErr {
+				t.Errorf("MatchesFlow(%d sent, %d received, %d, %s, %v) got error %v, want error %t", c.r.OutPkts, c.r.InPkts, c.slack, c.in, c.outs, err, c.wantErr)
+			}
+		})
+	}
+}
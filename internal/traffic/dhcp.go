@@ -0,0 +1,278 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/openconfig/ondatra"
+)
+
+const (
+	// DHCPv4ServerPort and DHCPv4ClientPort are the UDP ports of DHCPv4
+	// servers and relay agents, and of DHCPv4 clients.
+	DHCPv4ServerPort = 67
+	DHCPv4ClientPort = 68
+
+	// dhcpOptRelayAgentInfo is the relay agent information option,
+	// a.k.a. option 82, per RFC 3046.
+	dhcpOptRelayAgentInfo = layers.DHCPOpt(82)
+	// dhcpBroadcast is the broadcast flag of a DHCPv4 message, which
+	// asks the server or relay agent to broadcast its replies.
+	dhcpBroadcast = 0x8000
+	// dhcpLeaseTime is the lease time in seconds of the addresses
+	// offered.
+	dhcpLeaseTime = 3600
+)
+
+// DHCPv4 is the decoded view of a DHCPv4 message in a captured packet,
+// per RFC 2131, and what AddOTGDHCPv4Flow sends.  The addresses are
+// empty rather than 0.0.0.0 when unset.
+type DHCPv4 struct {
+	Type      layers.DHCPMsgType
+	Xid       uint32
+	ClientMAC string
+	// YourIP is the address offered to the client, and RelayIP the
+	// address of the relay agent the message went through.
+	YourIP  string
+	RelayIP string
+	// ServerID, RequestedIP and SubnetMask are the values of the
+	// options of the same name.
+	ServerID    string
+	RequestedIP string
+	SubnetMask  string
+	// RelayAgentInfo is the value of the relay agent information
+	// option, which a relay agent inserts into the requests it relays
+	// to the server, and a server echoes in its replies.
+	RelayAgentInfo []byte
+}
+
+// isRequest returns whether the message is sent by a client.
+func (d *DHCPv4) isRequest() bool {
+	switch d.Type {
+	case layers.DHCPMsgTypeOffer, layers.DHCPMsgTypeAck, layers.DHCPMsgTypeNak:
+		return false
+	}
+	return true
+}
+
+// dhcpIP returns the string of the address, or "" if it is unset.
+func dhcpIP(ip net.IP) string {
+	if ip == nil || ip.IsUnspecified() {
+		return ""
+	}
+	return ip.String()
+}
+
+// decodeDHCPv4 decodes a DHCPv4 message.
+func decodeDHCPv4(l *layers.DHCPv4) *DHCPv4 {
+	d := &DHCPv4{
+		Xid:     l.Xid,
+		YourIP:  dhcpIP(l.YourClientIP),
+		RelayIP: dhcpIP(l.RelayAgentIP),
+	}
+	if len(l.ClientHWAddr) >= 6 {
+		d.ClientMAC = l.ClientHWAddr[:6].String()
+	}
+	for _, o := range l.Options {
+		switch o.Type {
+		case layers.DHCPOptMessageType:
+			if len(o.Data) == 1 {
+				d.Type = layers.DHCPMsgType(o.Data[0])
+			}
+		case layers.DHCPOptServerID:
+			d.ServerID = dhcpIP(o.Data)
+		case layers.DHCPOptRequestIP:
+			d.RequestedIP = dhcpIP(o.Data)
+		case layers.DHCPOptSubnetMask:
+			d.SubnetMask = dhcpIP(o.Data)
+		case dhcpOptRelayAgentInfo:
+			d.RelayAgentInfo = append([]byte(nil), o.Data...)
+		}
+	}
+	return d
+}
+
+// dhcpIPOption returns the option of the given type carrying the IPv4
+// address, and whether it is valid.
+func dhcpIPOption(typ layers.DHCPOpt, addr string) (layers.DHCPOption, bool) {
+	ip := net.ParseIP(addr).To4()
+	return layers.NewDHCPOption(typ, ip), ip != nil
+}
+
+// layer returns the DHCPv4 layer of the message.
+func (d *DHCPv4) layer() (*layers.DHCPv4, error) {
+	mac, err := net.ParseMAC(d.ClientMAC)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DHCPv4 client MAC: %w", err)
+	}
+	l := &layers.DHCPv4{
+		Operation:    layers.DHCPOpReply,
+		HardwareType: layers.LinkTypeEthernet,
+		Xid:          d.Xid,
+		ClientIP:     net.IPv4zero,
+		YourClientIP: net.IPv4zero,
+		NextServerIP: net.IPv4zero,
+		RelayAgentIP: net.IPv4zero,
+		ClientHWAddr: mac,
+		Options:      layers.DHCPOptions{layers.NewDHCPOption(layers.DHCPOptMessageType, []byte{byte(d.Type)})},
+	}
+	if d.isRequest() {
+		l.Operation = layers.DHCPOpRequest
+		l.Flags = dhcpBroadcast
+	}
+	for _, a := range []struct {
+		addr string
+		ip   *net.IP
+		what string
+	}{
+		{d.YourIP, &l.YourClientIP, "your IP"},
+		{d.RelayIP, &l.RelayAgentIP, "relay IP"},
+	} {
+		if a.addr == "" {
+			continue
+		}
+		if *a.ip = net.ParseIP(a.addr).To4(); *a.ip == nil {
+			return nil, fmt.Errorf("invalid DHCPv4 %s %q", a.what, a.addr)
+		}
+	}
+	for _, o := range []struct {
+		typ  layers.DHCPOpt
+		addr string
+	}{
+		{layers.DHCPOptRequestIP, d.RequestedIP},
+		{layers.DHCPOptServerID, d.ServerID},
+		{layers.DHCPOptSubnetMask, d.SubnetMask},
+	} {
+		if o.addr == "" {
+			continue
+		}
+		opt, ok := dhcpIPOption(o.typ, o.addr)
+		if !ok {
+			return nil, fmt.Errorf("invalid DHCPv4 option %v %q", o.typ, o.addr)
+		}
+		l.Options = append(l.Options, opt)
+	}
+	if !d.isRequest() {
+		lease := binary.BigEndian.AppendUint32(nil, dhcpLeaseTime)
+		l.Options = append(l.Options, layers.NewDHCPOption(layers.DHCPOptLeaseTime, lease))
+	}
+	if len(d.RelayAgentInfo) > 0 {
+		l.Options = append(l.Options, layers.NewDHCPOption(dhcpOptRelayAgentInfo, d.RelayAgentInfo))
+	}
+	return l, nil
+}
+
+// DHCPv4Flow describes a flow sending a DHCPv4 message, with which the
+// ATE emulates a DHCPv4 client or server.  The ATE does not answer the
+// messages it receives, so a test scripts the exchange, e.g. checking
+// in a capture that the DUT relayed a discover before sending the
+// offer of the server.
+type DHCPv4Flow struct {
+	// Msg is the DHCPv4 message sent.  A client message is sent from
+	// DHCPv4ClientPort, and a server message from DHCPv4ServerPort.
+	// A server message is sent to DHCPv4ServerPort if it has a
+	// RelayIP, and to DHCPv4ClientPort otherwise.
+	Msg *DHCPv4
+	// SrcMAC and DstMAC are the MAC addresses of the Ethernet header.
+	// If DstMAC is empty, the message is broadcast.
+	SrcMAC, DstMAC string
+	// SrcIP and DstIP are the addresses of the IPv4 header.  If empty,
+	// they are 0.0.0.0 and 255.255.255.255 respectively, as for the
+	// messages of a client without an address.
+	SrcIP, DstIP string
+}
+
+// marshal encodes the IPv4 packet carrying the message.
+func (f *DHCPv4Flow) marshal() ([]byte, error) {
+	src, dst := net.IPv4zero, net.IPv4bcast
+	if f.SrcIP != "" {
+		if src = net.ParseIP(f.SrcIP).To4(); src == nil {
+			return nil, fmt.Errorf("invalid source IPv4 address %q", f.SrcIP)
+		}
+	}
+	if f.DstIP != "" {
+		if dst = net.ParseIP(f.DstIP).To4(); dst == nil {
+			return nil, fmt.Errorf("invalid destination IPv4 address %q", f.DstIP)
+		}
+	}
+	dhcp, err := f.Msg.layer()
+	if err != nil {
+		return nil, err
+	}
+	udp := &layers.UDP{SrcPort: DHCPv4ClientPort, DstPort: DHCPv4ServerPort}
+	if !f.Msg.isRequest() {
+		udp.SrcPort = DHCPv4ServerPort
+		if f.Msg.RelayIP == "" {
+			udp.DstPort = DHCPv4ClientPort
+		}
+	}
+	ip := &layers.IPv4{
+		Version:  4,
+		TTL:      64,
+		Protocol: layers.IPProtocolUDP,
+		SrcIP:    src,
+		DstIP:    dst,
+	}
+	if err := udp.SetNetworkLayerForChecksum(ip); err != nil {
+		return nil, err
+	}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ip, udp, dhcp); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// AddOTGDHCPv4Flow adds a flow to the OTG config sending the DHCPv4
+// message of f out of the ATE port once per second.  As with
+// AddOTGLLDPFlow, the flow runs with the traffic of the config.
+func AddOTGDHCPv4Flow(t testing.TB, top gosnappi.Config, ap *ondatra.Port, f *DHCPv4Flow) gosnappi.Flow {
+	t.Helper()
+	name := fmt.Sprintf("DHCPv4-%s-%v", ap.ID(), f.Msg.Type)
+	pkt, err := f.marshal()
+	if err != nil {
+		t.Fatalf("Cannot create flow %s: %v", name, err)
+	}
+	dstMAC := f.DstMAC
+	if dstMAC == "" {
+		dstMAC = "ff:ff:ff:ff:ff:ff"
+	}
+
+	flow := top.Flows().Add().SetName(name)
+	flow.Metrics().SetEnable(true)
+	flow.TxRx().Port().SetTxName(ap.ID())
+	eth := flow.Packet().Add().Ethernet()
+	eth.Src().SetValue(f.SrcMAC)
+	eth.Dst().SetValue(dstMAC)
+	eth.EtherType().SetValue(int32(layers.EthernetTypeIPv4))
+	flow.Packet().Add().Custom().SetBytes(hex.EncodeToString(pkt))
+
+	// The frame carries the Ethernet header, the IPv4 packet and the
+	// FCS.
+	flow.Size().SetFixed(int32(14 + len(pkt) + 4))
+	flow.Rate().SetPps(1)
+	flow.Duration().SetChoice(gosnappi.FlowDurationChoice.CONTINUOUS)
+	t.Logf("Flow %s: %+v", name, *f.Msg)
+	return flow
+}
@@ -0,0 +1,137 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func TestDHCPv4FlowRoundTrip(t *testing.T) {
+	tests := []struct {
+		desc             string
+		flow             *DHCPv4Flow
+		wantSrc, wantDst string
+		wantPorts        [2]layers.UDPPort
+	}{{
+		desc: "discover",
+		flow: &DHCPv4Flow{
+			Msg: &DHCPv4{
+				Type:      layers.DHCPMsgTypeDiscover,
+				Xid:       0x1234,
+				ClientMAC: "02:00:01:01:01:01",
+			},
+		},
+		wantSrc:   "0.0.0.0",
+		wantDst:   "255.255.255.255",
+		wantPorts: [2]layers.UDPPort{DHCPv4ClientPort, DHCPv4ServerPort},
+	}, {
+		desc: "offer to relay",
+		flow: &DHCPv4Flow{
+			Msg: &DHCPv4{
+				Type:           layers.DHCPMsgTypeOffer,
+				Xid:            0x1234,
+				ClientMAC:      "02:00:01:01:01:01",
+				YourIP:         "192.0.2.2",
+				RelayIP:        "192.0.2.1",
+				ServerID:       "192.0.2.6",
+				SubnetMask:     "255.255.255.252",
+				RelayAgentInfo: []byte{1, 3, 'f', 'p', '1'},
+			},
+			SrcIP: "192.0.2.6",
+			DstIP: "192.0.2.1",
+		},
+		wantSrc:   "192.0.2.6",
+		wantDst:   "192.0.2.1",
+		wantPorts: [2]layers.UDPPort{DHCPv4ServerPort, DHCPv4ServerPort},
+	}, {
+		desc: "request",
+		flow: &DHCPv4Flow{
+			Msg: &DHCPv4{
+				Type:        layers.DHCPMsgTypeRequest,
+				Xid:         0x1234,
+				ClientMAC:   "02:00:01:01:01:01",
+				RequestedIP: "192.0.2.2",
+				ServerID:    "192.0.2.6",
+			},
+		},
+		wantSrc:   "0.0.0.0",
+		wantDst:   "255.255.255.255",
+		wantPorts: [2]layers.UDPPort{DHCPv4ClientPort, DHCPv4ServerPort},
+	}, {
+		desc: "ack to client",
+		flow: &DHCPv4Flow{
+			Msg: &DHCPv4{
+				Type:      layers.DHCPMsgTypeAck,
+				Xid:       0x1234,
+				ClientMAC: "02:00:01:01:01:01",
+				YourIP:    "192.0.2.2",
+				ServerID:  "192.0.2.6",
+			},
+			SrcIP: "192.0.2.6",
+		},
+		wantSrc:   "192.0.2.6",
+		wantDst:   "255.255.255.255",
+		wantPorts: [2]layers.UDPPort{DHCPv4ServerPort, DHCPv4ClientPort},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			pkt, err := tt.flow.marshal()
+			if err != nil {
+				t.Fatalf("marshal() got error: %v", err)
+			}
+
+			p := gopacket.NewPacket(pkt, layers.LayerTypeIPv4, gopacket.Default)
+			if err := p.ErrorLayer(); err != nil {
+				t.Fatalf("Cannot decode packet: %v", err.Error())
+			}
+			ip := p.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+			if got := ip.SrcIP.String(); got != tt.wantSrc {
+				t.Errorf("source IP got %s, want %s", got, tt.wantSrc)
+			}
+			if got := ip.DstIP.String(); got != tt.wantDst {
+				t.Errorf("destination IP got %s, want %s", got, tt.wantDst)
+			}
+			udp := p.Layer(layers.LayerTypeUDP).(*layers.UDP)
+			if got := [2]layers.UDPPort{udp.SrcPort, udp.DstPort}; got != tt.wantPorts {
+				t.Errorf("UDP ports got %v, want %v", got, tt.wantPorts)
+			}
+
+			pkts, err := DecodePCAP(pcap(t, serialize(t, gopacket.Payload(pkt))))
+			if err != nil {
+				t.Fatalf("DecodePCAP() got error: %v", err)
+			}
+			if diff := cmp.Diff(tt.flow.Msg, pkts[0].DHCPv4); diff != "" {
+				t.Errorf("DHCPv4 message -want,+got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDHCPv4FlowInvalid(t *testing.T) {
+	for _, f := range []*DHCPv4Flow{
+		{Msg: &DHCPv4{Type: layers.DHCPMsgTypeDiscover, ClientMAC: "not-a-mac"}},
+		{Msg: &DHCPv4{Type: layers.DHCPMsgTypeOffer, ClientMAC: "02:00:01:01:01:01", YourIP: "2001:db8::1"}},
+		{Msg: &DHCPv4{Type: layers.DHCPMsgTypeRequest, ClientMAC: "02:00:01:01:01:01", ServerID: "server"}},
+		{Msg: &DHCPv4{Type: layers.DHCPMsgTypeDiscover, ClientMAC: "02:00:01:01:01:01"}, SrcIP: "source"},
+	} {
+		if _, err := f.marshal(); err == nil {
+			t.Errorf("marshal() of %+v got no error, want error", *f.Msg)
+		}
+	}
+}
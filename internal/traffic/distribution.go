@@ -0,0 +1,219 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openconfig/ondatra"
+)
+
+// zeroWeightMaxFraction is the maximum fraction of the frames that a
+// port with a zero wanted weight may receive, allowing for stray
+// protocol frames.
+const zeroWeightMaxFraction = 0.001
+
+// DistributionOptions configure how CheckDistribution measures the
+// distribution of the traffic.  The zero value measures traffic that is
+// already running until the ports received DefaultMinTxPkts frames or
+// DefaultMaxDuration elapses.
+type DistributionOptions struct {
+	// Run, if set, runs or waits for the traffic whose distribution is
+	// measured, e.g. with ValidateFlows, instead of measuring traffic
+	// that is already running.
+	Run func()
+	// Settle makes CheckDistribution first wait for the running traffic
+	// to settle after a change, e.g. a next hop added or removed: until
+	// in an interval between two reads of the port counters the ports
+	// with a zero wanted weight receive almost no frames and the other
+	// ports all receive frames, or stableTimeout elapses.  It is
+	// ignored with Run.
+	Settle bool
+	// MinFrames is the number of frames the ports must receive while
+	// the running traffic is measured, e.g. Entropy.MinFrames.
+	MinFrames uint64
+	// MaxDuration is the maximum time the running traffic is measured
+	// while waiting for MinFrames frames.
+	MaxDuration time.Duration
+}
+
+func (o *DistributionOptions) minFrames() uint64 {
+	if o == nil || o.MinFrames == 0 {
+		return DefaultMinTxPkts
+	}
+	return o.MinFrames
+}
+
+func (o *DistributionOptions) maxDuration() time.Duration {
+	if o == nil || o.MaxDuration == 0 {
+		return DefaultMaxDuration
+	}
+	return o.MaxDuration
+}
+
+// CheckDistribution reads the frames received by the named ATE ports
+// over the traffic measured as configured by opts, and checks that the
+// fraction of the frames received by each port is within tolerancePct
+// percentage points of its fraction of the wanted weights.  Ports with
+// a zero wanted weight must receive almost no frames.  It logs a table
+// of the wanted and observed fractions, and returns the observed
+// fractions.  opts may be nil.
+func CheckDistribution(t testing.TB, ate *ondatra.ATEDevice, ports []string, wantWeights []uint64, tolerancePct float64, opts *DistributionOptions) []float64 {
+	t.Helper()
+	if len(ports) != len(wantWeights) {
+		t.Fatalf("CheckDistribution got %d ports and %d weights", len(ports), len(wantWeights))
+	}
+	aps := make([]*ondatra.Port, len(ports))
+	for i, id := range ports {
+		aps[i] = ate.Port(t, id)
+	}
+	read := func() []uint64 { return PortInPkts(t, ate, aps) }
+
+	if opts != nil && opts.Run != nil {
+		before := read()
+		opts.Run()
+		return ReportDistribution(t, ports, before, read(), wantWeights, tolerancePct)
+	}
+	if opts != nil && opts.Settle {
+		if !pollUntil(stablePollInterval, stableTimeout, settled(read, wantWeights)) {
+			t.Logf("Traffic to ports %v did not settle to weights %v within %v", ports, wantWeights, stableTimeout)
+		}
+	}
+	before := read()
+	after := before
+	if !pollUntil(txPollInterval, opts.maxDuration(), func() bool {
+		after = read()
+		return received(before, after) >= opts.minFrames()
+	}) {
+		t.Logf("Ports %v received %d frames within %v, want %d", ports, received(before, after), opts.maxDuration(), opts.minFrames())
+	}
+	return ReportDistribution(t, ports, before, after, wantWeights, tolerancePct)
+}
+
+// ReportDistribution checks the frames received by the named ports
+// between the before and after counters as CheckDistribution does, for
+// tests that read the counters themselves, e.g. from OTG.  A nil before
+// counts all the frames of after.
+func ReportDistribution(t testing.TB, ports []string, before, after, wantWeights []uint64, tolerancePct float64) []float64 {
+	t.Helper()
+	if before == nil {
+		before = make([]uint64, len(after))
+	}
+	rows, errs := compareDistribution(ports, before, after, wantWeights, tolerancePct)
+	t.Log(distributionTable(rows))
+	for _, err := range errs {
+		t.Error(err)
+	}
+	got := make([]float64, len(rows))
+	for i, r := range rows {
+		got[i] = r.got
+	}
+	return got
+}
+
+// received returns the frames the ports received between the before
+// and after counters.
+func received(before, after []uint64) uint64 {
+	var total uint64
+	for i := range after {
+		if i < len(before) && after[i] > before[i] {
+			total += after[i] - before[i]
+		}
+	}
+	return total
+}
+
+// settled returns a poll function reading the port counters with read,
+// which returns true once in the interval since its previous call the
+// ports with a zero wanted weight received almost no frames and the
+// other ports all received frames.
+func settled(read func() []uint64, wantWeights []uint64) func() bool {
+	var last []uint64
+	return func() bool {
+		cur := read()
+		prev := last
+		last = cur
+		if prev == nil || received(prev, cur) == 0 {
+			return false
+		}
+		for i, frac := range Distribution(prev, cur) {
+			if (wantWeights[i] == 0) != (frac <= zeroWeightMaxFraction) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// distributionRow is the wanted and observed distribution of one port.
+type distributionRow struct {
+	port   string
+	frames uint64
+	want   float64
+	got    float64
+}
+
+// compareDistribution compares the distribution of the frames received
+// by the ports between the before and after counters to the wanted
+// weights, and returns the distribution of each port and the errors
+// found.
+func compareDistribution(ports []string, before, after, wantWeights []uint64, tolerancePct float64) ([]distributionRow, []error) {
+	var wantSum uint64
+	for _, w := range wantWeights {
+		wantSum += w
+	}
+	got := Distribution(before, after)
+
+	var rows []distributionRow
+	var errs []error
+	var total uint64
+	for i, p := range ports {
+		r := distributionRow{port: p, got: got[i]}
+		if after[i] > before[i] {
+			r.frames = after[i] - before[i]
+		}
+		if wantSum > 0 {
+			r.want = float64(wantWeights[i]) / float64(wantSum)
+		}
+		total += r.frames
+		rows = append(rows, r)
+	}
+	if total == 0 {
+		return rows, []error{fmt.Errorf("ports %v received no frames", ports)}
+	}
+	for i, r := range rows {
+		switch {
+		case wantWeights[i] == 0 && r.got > zeroWeightMaxFraction:
+			errs = append(errs, fmt.Errorf("port %s received %d frames (%.3f), want none", r.port, r.frames, r.got))
+		case wantWeights[i] > 0 && math.Abs(r.got-r.want) > tolerancePct/100:
+			errs = append(errs, fmt.Errorf("port %s received %d frames (%.3f), want %.3f +/- %.3f", r.port, r.frames, r.got, r.want, tolerancePct/100))
+		}
+	}
+	return rows, errs
+}
+
+// distributionTable formats the rows as a table for the test log.
+func distributionTable(rows []distributionRow) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n%-15s%-15s%-15s%-15s\n", "Port", "Frames", "Want", "Got")
+	for _, r := range rows {
+		fmt.Fprintf(&b, "%-15s%-15d%-15.3f%-15.3f\n", r.port, r.frames, r.want, r.got)
+	}
+	return b.String()
+}
@@ -0,0 +1,96 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompareDistribution(t *testing.T) {
+	ports := []string{"port2", "port3"}
+	cases := []struct {
+		desc    string
+		before  []uint64
+		after   []uint64
+		weights []uint64
+		wantErr int
+	}{{
+		desc:    "1:3 within tolerance",
+		before:  []uint64{1000, 1000},
+		after:   []uint64{3600, 8400},
+		weights: []uint64{1, 3},
+	}, {
+		desc:    "1:3 out of tolerance",
+		before:  []uint64{0, 0},
+		after:   []uint64{5000, 5000},
+		weights: []uint64{1, 3},
+		wantErr: 2,
+	}, {
+		desc:    "zero weight receives nothing",
+		before:  []uint64{0, 0},
+		after:   []uint64{10000, 5},
+		weights: []uint64{1, 0},
+	}, {
+		desc:    "zero weight receives frames",
+		before:  []uint64{0, 0},
+		after:   []uint64{9000, 1000},
+		weights: []uint64{1, 0},
+		wantErr: 1,
+	}, {
+		desc:    "no frames",
+		before:  []uint64{10, 10},
+		after:   []uint64{10, 10},
+		weights: []uint64{1, 1},
+		wantErr: 1,
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			_, errs := compareDistribution(ports, c.before, c.after, c.weights, 15)
+			if len(errs) != c.wantErr {
+				t.Errorf("compareDistribution() got errors %v, want %d errors", errs, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestDistributionTable(t *testing.T) {
+	rows, _ := compareDistribution([]string{"port2", "port3"}, []uint64{0, 0}, []uint64{250, 750}, []uint64{1, 3}, 15)
+	got := distributionTable(rows)
+	for _, want := range []string{"port2", "250", "0.250", "port3", "750", "0.750"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("distributionTable() got %q, want it to contain %q", got, want)
+		}
+	}
+}
+
+func TestSettled(t *testing.T) {
+	reads := [][]uint64{
+		{0, 0},
+		{1000, 1000}, // Both ports still receive frames.
+		{2000, 1000}, // Only port2 receives frames.
+	}
+	i := 0
+	poll := settled(func() []uint64 {
+		r := reads[i]
+		i++
+		return r
+	}, []uint64{1, 0})
+	for n, want := range []bool{false, false, true} {
+		if got := poll(); got != want {
+			t.Errorf("settled() poll %d got %t, want %t", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/openconfig/ondatra"
+)
+
+// dropCounters are the counters of a DUT port that count dropped
+// frames, by name.
+type dropCounters map[string]uint64
+
+// readDropCounters reads the drop counters of the DUT port.  Counters
+// the DUT does not report are omitted.
+func readDropCounters(t testing.TB, dut *ondatra.DUTDevice, p *ondatra.Port) dropCounters {
+	t.Helper()
+	c := dropCounters{}
+	intf := dut.Telemetry().Interface(p.Name())
+	if q := intf.Counters().Lookup(t); q.IsPresent() {
+		v := q.Val(t)
+		c["in-discards"] = v.GetInDiscards()
+		c["in-errors"] = v.GetInErrors()
+		c["out-discards"] = v.GetOutDiscards()
+		c["out-errors"] = v.GetOutErrors()
+	}
+	if q := intf.Ethernet().Counters().Lookup(t); q.IsPresent() {
+		c["in-oversize-frames"] = q.Val(t).GetInOversizeFrames()
+	}
+	return c
+}
+
+// sub returns how much each counter incremented since before.  Counters
+// that were reset count from zero.
+func (c dropCounters) sub(before dropCounters) dropCounters {
+	d := dropCounters{}
+	for name, v := range c {
+		d[name] = increment(before[name], v)
+	}
+	return d
+}
+
+// increment returns how much a counter incremented from before to after.
+// A counter that was reset counts from zero.
+func increment(before, after uint64) uint64 {
+	if after >= before {
+		return after - before
+	}
+	return after
+}
+
+// total returns the sum of the counters.
+func (c dropCounters) total() uint64 {
+	var n uint64
+	for _, v := range c {
+		n += v
+	}
+	return n
+}
+
+// String lists the counters in name order for the test log.
+func (c dropCounters) String() string {
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s %d", name, c[name]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// validateDrops returns the errors found in the result of a flow whose
+// packets should all be dropped by the DUT, given how much the drop
+// counters of the DUT ports incremented, by port name.  Up to the loss
+// tolerance of the packets may still be received.
+func (r *Result) validateDrops(opts *Options, deltas map[string]dropCounters) []error {
+	var errs []error
+	if min := opts.minOutPkts(); r.OutPkts < min {
+		errs = append(errs, fmt.Errorf("flow %s sent %d packets and received %d packets, want at least %d sent", r.Flow, r.OutPkts, r.InPkts, min))
+	}
+	if want := 100 - opts.lossTolerance(); r.LossPct < want {
+		errs = append(errs, fmt.Errorf("flow %s sent %d packets and received %d packets, LossPct got %g, want at least %g", r.Flow, r.OutPkts, r.InPkts, r.LossPct, want))
+	}
+	var dropped uint64
+	for _, d := range deltas {
+		dropped += d.total()
+	}
+	if dropped == 0 {
+		errs = append(errs, fmt.Errorf("flow %s lost %d packets, but no drop counter of the DUT ports incremented", r.Flow, r.OutPkts-r.InPkts))
+	}
+	return errs
+}
+
+// ValidateDrops runs the flow as RunFlow does, and reports an error if
+// it transmitted fewer than opts.MinOutPkts packets, if more than the
+// loss tolerance in opts of its packets were received, or if none of
+// the discard, error and oversize frame counters of the DUT ports
+// incremented.  It is meant for flows of Frame.Oversize frames, or
+// other frames the DUT must drop.  opts may be nil.
+func ValidateDrops(t testing.TB, ate *ondatra.ATEDevice, dut *ondatra.DUTDevice, flow *ondatra.Flow, ports []*ondatra.Port, opts *Options) *Result {
+	t.Helper()
+	before := make(map[string]dropCounters)
+	for _, p := range ports {
+		before[p.Name()] = readDropCounters(t, dut, p)
+	}
+	r := RunFlow(t, ate, flow, opts)
+	deltas := make(map[string]dropCounters)
+	for _, p := range ports {
+		deltas[p.Name()] = readDropCounters(t, dut, p).sub(before[p.Name()])
+		t.Logf("DUT port %s drop counters incremented by: %v", p.Name(), deltas[p.Name()])
+	}
+	for _, err := range r.validateDrops(opts, deltas) {
+		t.Error(err)
+	}
+	return r
+}
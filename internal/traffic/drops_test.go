@@ -0,0 +1,80 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/openconfig/ygot/ygot"
+)
+
+func TestDropCountersSub(t *testing.T) {
+	before := dropCounters{"in-discards": 10, "out-discards": 5, "in-oversize-frames": 100}
+	after := dropCounters{"in-discards": 1010, "out-discards": 5, "in-oversize-frames": 20}
+	want := dropCounters{"in-discards": 1000, "out-discards": 0, "in-oversize-frames": 20}
+	got := after.sub(before)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("sub() -want,+got:\n%s", diff)
+	}
+	if got, want := got.total(), uint64(1020); got != want {
+		t.Errorf("total() got %d, want %d", got, want)
+	}
+	if got, want := got.String(), "in-discards 1000, in-oversize-frames 20, out-discards 0"; got != want {
+		t.Errorf("String() got %q, want %q", got, want)
+	}
+}
+
+func TestValidateDrops(t *testing.T) {
+	opts := &Options{MinOutPkts: 1000, LossTolerance: ygot.Float64(1)}
+	dropped := map[string]dropCounters{"port1": {"in-oversize-frames": 10000}, "port2": {}}
+	cases := []struct {
+		desc     string
+		result   *Result
+		deltas   map[string]dropCounters
+		wantErrs int
+	}{{
+		desc:   "all dropped",
+		result: newResult("f", 10000, 0, 0),
+		deltas: dropped,
+	}, {
+		desc:   "within tolerance",
+		result: newResult("f", 10000, 50, 0),
+		deltas: dropped,
+	}, {
+		desc:     "forwarded",
+		result:   newResult("f", 10000, 10000, 0),
+		deltas:   dropped,
+		wantErrs: 1,
+	}, {
+		desc:     "no counter incremented",
+		result:   newResult("f", 10000, 0, 0),
+		deltas:   map[string]dropCounters{"port1": {"in-discards": 0}},
+		wantErrs: 1,
+	}, {
+		desc:     "too few sent",
+		result:   newResult("f", 10, 0, 0),
+		deltas:   dropped,
+		wantErrs: 1,
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			errs := c.result.validateDrops(opts, c.deltas)
+			if len(errs) != c.wantErrs {
+				t.Errorf("validateDrops() got errors %v, want %d errors", errs, c.wantErrs)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"math"
+	"testing"
+
+	"github.com/openconfig/ondatra"
+)
+
+// L4Protocol is the transport protocol of an entropy flow.
+type L4Protocol int
+
+const (
+	// TCP sends TCP segments.
+	TCP L4Protocol = iota
+	// UDP sends UDP datagrams.
+	UDP
+)
+
+const (
+	// DefaultSrcPortMin and DefaultSrcPortCount are the L4 source ports
+	// of an entropy flow if Entropy.SrcPortCount is not set, i.e.
+	// 1024 to 2024.
+	DefaultSrcPortMin   = 1024
+	DefaultSrcPortCount = 1001
+	// MinEntropyFrameRate is the minimum frame rate in frames per second
+	// of an entropy flow.
+	MinEntropyFrameRate = 1000
+	// framesPerTuple is the minimum number of frames sent for each
+	// distinct combination of the varied fields, so that each of them
+	// contributes to the distribution the same way.
+	framesPerTuple = 10
+)
+
+// Entropy describes the fields varied across the packets of a flow, so
+// that the DUT hashes it across multiple paths.
+type Entropy struct {
+	// Protocol is the L4 protocol of the flow.
+	Protocol L4Protocol
+	// SrcPortMin and SrcPortCount are the range the L4 source port is
+	// incremented over.
+	SrcPortMin, SrcPortCount uint16
+	// SrcAddrMin and SrcAddrCount, if set, are the range the IPv4 source
+	// address is incremented over.
+	SrcAddrMin   string
+	SrcAddrCount uint32
+	// FrameRate is the frame rate in frames per second.  It is raised to
+	// MinEntropyFrameRate if lower.
+	FrameRate uint64
+}
+
+func (e *Entropy) srcPorts() (min, count uint16) {
+	if e.SrcPortCount == 0 {
+		return DefaultSrcPortMin, DefaultSrcPortCount
+	}
+	return e.SrcPortMin, e.SrcPortCount
+}
+
+func (e *Entropy) frameRate() uint64 {
+	if e.FrameRate < MinEntropyFrameRate {
+		return MinEntropyFrameRate
+	}
+	return e.FrameRate
+}
+
+// tuples returns the number of distinct combinations of the varied
+// fields.
+func (e *Entropy) tuples() uint64 {
+	_, n := e.srcPorts()
+	tuples := uint64(n)
+	if e.SrcAddrCount > 1 {
+		tuples *= uint64(e.SrcAddrCount)
+	}
+	return tuples
+}
+
+// MinFrames returns the number of frames the flow should transmit for
+// the fraction of frames received by each path to be within tolerance
+// of its expected value.  It is the larger of the frames needed for the
+// tolerance to be four standard deviations of the fraction, and the
+// frames needed to send each combination of the varied fields several
+// times.  Use it as Options.MinTxPkts.
+func (e *Entropy) MinFrames(tolerance float64) uint64 {
+	// The variance of a fraction p of n frames is p(1-p)/n, which is at
+	// most 1/(4n).
+	statistical := uint64(math.Ceil(4 / (tolerance * tolerance)))
+	coverage := framesPerTuple * e.tuples()
+	if statistical > coverage {
+		return statistical
+	}
+	return coverage
+}
+
+// NewEntropyFlow creates an IPv4 flow as described by p, with the L4
+// source port and optionally the IPv4 source address varied as
+// described by e.  The frame rate of p is replaced by that of e.
+func NewEntropyFlow(t testing.TB, ate *ondatra.ATEDevice, top *ondatra.ATETopology, p *FlowParams, e *Entropy) *ondatra.Flow {
+	t.Helper()
+	v4 := ipv4Header(p)
+	if e.SrcAddrCount > 1 {
+		v4.SrcAddressRange().
+			WithMin(e.SrcAddrMin).
+			WithCount(e.SrcAddrCount)
+	}
+
+	min, count := e.srcPorts()
+	var l4 ondatra.Header
+	switch e.Protocol {
+	case UDP:
+		udp := ondatra.NewUDPHeader()
+		udp.SrcPortRange().WithMin(uint32(min)).WithCount(uint32(count))
+		l4 = udp
+	default:
+		tcp := ondatra.NewTCPHeader()
+		tcp.SrcPortRange().WithMin(uint32(min)).WithCount(uint32(count))
+		l4 = tcp
+	}
+
+	fp := *p
+	fp.Frame.RatePPS = e.frameRate()
+	return newFlow(t, ate, top, &fp, p.Name, v4, l4)
+}
+
+// PortInPkts returns the number of packets received by each of the ATE
+// ports.
+func PortInPkts(t testing.TB, ate *ondatra.ATEDevice, ports []*ondatra.Port) []uint64 {
+	t.Helper()
+	pkts := make([]uint64, len(ports))
+	for i, ap := range ports {
+		pkts[i] = ate.Telemetry().Interface(ap.Name()).Counters().InPkts().Get(t)
+	}
+	return pkts
+}
+
+// Distribution returns the fraction of the packets received by each
+// port between two reads of PortInPkts, or all zeros if no packets were
+// received.
+func Distribution(before, after []uint64) []float64 {
+	deltas := make([]uint64, len(after))
+	var total uint64
+	for i := range after {
+		if i < len(before) && after[i] >= before[i] {
+			deltas[i] = after[i] - before[i]
+		}
+		total += deltas[i]
+	}
+	dist := make([]float64, len(deltas))
+	if total == 0 {
+		return dist
+	}
+	for i, d := range deltas {
+		dist[i] = float64(d) / float64(total)
+	}
+	return dist
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMinFrames(t *testing.T) {
+	cases := []struct {
+		desc      string
+		entropy   *Entropy
+		tolerance float64
+		want      uint64
+	}{{
+		desc:      "default ports",
+		entropy:   &Entropy{},
+		tolerance: 0.15,
+		want:      framesPerTuple * DefaultSrcPortCount,
+	}, {
+		desc:      "ports and addresses",
+		entropy:   &Entropy{SrcPortMin: 1, SrcPortCount: 100, SrcAddrMin: "198.51.100.1", SrcAddrCount: 10},
+		tolerance: 0.15,
+		want:      framesPerTuple * 100 * 10,
+	}, {
+		desc:      "tight tolerance",
+		entropy:   &Entropy{SrcPortMin: 1, SrcPortCount: 10},
+		tolerance: 0.01,
+		want:      40000,
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			if got := c.entropy.MinFrames(c.tolerance); got != c.want {
+				t.Errorf("MinFrames(%g) got %d, want %d", c.tolerance, got, c.want)
+			}
+		})
+	}
+}
+
+func TestEntropyFrameRate(t *testing.T) {
+	if got := (&Entropy{FrameRate: 10}).frameRate(); got != MinEntropyFrameRate {
+		t.Errorf("frameRate() got %d, want %d", got, MinEntropyFrameRate)
+	}
+	if got := (&Entropy{FrameRate: 5000}).frameRate(); got != 5000 {
+		t.Errorf("frameRate() got %d, want 5000", got)
+	}
+}
+
+func TestDistribution(t *testing.T) {
+	cases := []struct {
+		desc   string
+		before []uint64
+		after  []uint64
+		want   []float64
+	}{{
+		desc:   "even",
+		before: []uint64{100, 200},
+		after:  []uint64{600, 700},
+		want:   []float64{0.5, 0.5},
+	}, {
+		desc:   "weighted",
+		before: []uint64{0, 0, 0},
+		after:  []uint64{100, 300, 0},
+		want:   []float64{0.25, 0.75, 0},
+	}, {
+		desc:   "none received",
+		before: []uint64{10, 10},
+		after:  []uint64{10, 10},
+		want:   []float64{0, 0},
+	}, {
+		desc:   "counter reset",
+		before: []uint64{1000, 0},
+		after:  []uint64{10, 100},
+		want:   []float64{0, 1},
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			got := Distribution(c.before, c.after)
+			if diff := cmp.Diff(c.want, got); diff != "" {
+				t.Errorf("Distribution() -want,+got:\n%s", diff)
+			}
+		})
+	}
+}
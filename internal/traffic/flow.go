@@ -0,0 +1,214 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+)
+
+const (
+	// NeighborTimeout is how long to wait for the DUT to resolve the IPv6
+	// neighbors of a flow.
+	NeighborTimeout = time.Minute
+	// l2Overhead is the difference between the L2 MTU of an interface
+	// and the IP MTU, as configured by attrs.Attributes.
+	l2Overhead = 14
+)
+
+// FlowParams describe a flow between ATE interfaces configured from
+// attrs.Attributes with AddToATE.
+type FlowParams struct {
+	// Name is the name of the flow.  For dual-stack flows, it is
+	// suffixed with "-IPv4" and "-IPv6".
+	Name string
+	// Src and Dst are the attributes of the ATE interfaces the flow is
+	// sent from and to.
+	Src, Dst *attrs.Attributes
+	// SrcNetwork is the name of a network added to the Src interface.
+	// If set, the flow is sent from the addresses of the network rather
+	// than from the Src interface, e.g. to match a source prefix.
+	SrcNetwork string
+	// DstNetwork is the name of a network added to the Dst interface.
+	// If set, the flow is sent to the addresses of the network rather
+	// than to the Dst interface.
+	DstNetwork string
+	// AltDsts are other ATE interfaces the flow may be received on, e.g.
+	// the other next hops of an ECMP route.  If DstNetwork is set, the
+	// flow is sent to the network of that name of each of them too.
+	AltDsts []*attrs.Attributes
+	// DSCP is the DSCP of the IPv4 header, or of the traffic class of
+	// the IPv6 header.
+	DSCP uint8
+	// TTL is the TTL of the IPv4 header, or the hop limit of the IPv6
+	// header.  If zero, the ATE default is used.
+	TTL uint8
+	// DontFragment sets the DF bit of the IPv4 header, so that the DUT
+	// drops rather than fragments packets larger than its MTU.
+	DontFragment bool
+	// MPLS is the MPLS label stack sent between the Ethernet and IP
+	// headers, from the top.  The bottom of stack bit is set on the last
+	// entry, whatever BottomOfStack is set to, and a zero TTL uses the
+	// ATE default.
+	MPLS []*MPLSLabel
+	// DUT, SrcPort and DstPort are the DUT and its ports connected to
+	// the Src and Dst interfaces.  If set, NewIPv6Flow waits for the
+	// DUT to resolve the IPv6 neighbors of the flow, and the frame size
+	// is checked against the MTU of the DUT ports.
+	DUT              *ondatra.DUTDevice
+	SrcPort, DstPort *ondatra.Port
+	// Frame configures the frame size, rate and count of the flow.
+	// Frames larger than the MTU of the Src or Dst interface or, unless
+	// Frame.Oversize is set, of the DUT ports fail the test before the
+	// flow is created.
+	Frame Frame
+}
+
+// newFlow creates a flow from the Src interface to the Dst interface or
+// network with the given headers following the Ethernet header and the
+// MPLS label stack, if any.
+func newFlow(t testing.TB, ate *ondatra.ATEDevice, top *ondatra.ATETopology, p *FlowParams, name string, hdrs ...ondatra.Header) *ondatra.Flow {
+	t.Helper()
+	checkFrame(t, p, name)
+	var dsts []ondatra.Endpoint
+	for _, d := range append([]*attrs.Attributes{p.Dst}, p.AltDsts...) {
+		if p.DstNetwork != "" {
+			dsts = append(dsts, top.Interfaces()[d.Name].Networks()[p.DstNetwork])
+		} else {
+			dsts = append(dsts, top.Interfaces()[d.Name])
+		}
+	}
+	var src ondatra.Endpoint = top.Interfaces()[p.Src.Name]
+	if p.SrcNetwork != "" {
+		src = top.Interfaces()[p.Src.Name].Networks()[p.SrcNetwork]
+	}
+	l2 := append([]ondatra.Header{ondatra.NewEthernetHeader()}, mplsHeaders(t, name, p.MPLS)...)
+	flow := ate.Traffic().NewFlow(name).
+		WithSrcEndpoints(src).
+		WithDstEndpoints(dsts...).
+		WithHeaders(append(l2, hdrs...)...)
+	p.Frame.apply(flow)
+	setFlowFrame(name, p.Frame)
+	t.Logf("Flow %s: %v", name, p.Frame)
+	return flow
+}
+
+// checkFrame fails the test if the frames of the flow are larger than
+// MaxFrameSize, or than the MTU of the Src or Dst interface, or of the
+// DUT ports unless the frames are expected to be oversize, as read from
+// the DUT telemetry.
+func checkFrame(t testing.TB, p *FlowParams, name string) {
+	t.Helper()
+	if p.Frame.maxSize() == 0 {
+		return
+	}
+	mtus := []portMTU{
+		{mtu: p.Src.MTU, where: "ATE interface " + p.Src.Name},
+		{mtu: p.Dst.MTU, where: "ATE interface " + p.Dst.Name},
+	}
+	for _, d := range p.AltDsts {
+		mtus = append(mtus, portMTU{mtu: d.MTU, where: "ATE interface " + d.Name})
+	}
+	if p.DUT != nil {
+		for _, port := range []*ondatra.Port{p.SrcPort, p.DstPort} {
+			if port == nil {
+				continue
+			}
+			m := portMTU{where: "DUT port " + port.Name(), dut: true}
+			if q := p.DUT.Telemetry().Interface(port.Name()).Mtu().Lookup(t); q.IsPresent() && q.Val(t) > l2Overhead {
+				m.mtu = q.Val(t) - l2Overhead
+			}
+			mtus = append(mtus, m)
+		}
+	}
+	warning, err := p.Frame.checkMTUs(mtus)
+	if err != nil {
+		t.Fatalf("Cannot create flow %s: %v", name, err)
+	}
+	if warning != "" {
+		t.Logf("Warning: flow %s: %s", name, warning)
+	}
+}
+
+// NewIPv4Flow creates an IPv4 flow as described by p.
+func NewIPv4Flow(t testing.TB, ate *ondatra.ATEDevice, top *ondatra.ATETopology, p *FlowParams) *ondatra.Flow {
+	t.Helper()
+	return newFlow(t, ate, top, p, p.Name, ipv4Header(p))
+}
+
+// NewIPv6Flow creates an IPv6 flow as described by p.  If p identifies
+// the DUT ports, it waits for the DUT to resolve the IPv6 neighbors of
+// the flow, so that the flow is ready to be sent when it returns.
+func NewIPv6Flow(t testing.TB, ate *ondatra.ATEDevice, top *ondatra.ATETopology, p *FlowParams) *ondatra.Flow {
+	t.Helper()
+	awaitIPv6Neighbors(t, p)
+	return newFlow(t, ate, top, p, p.Name, ipv6Header(p))
+}
+
+// NewDualStackFlows creates an IPv4 and an IPv6 flow as described by p.
+func NewDualStackFlows(t testing.TB, ate *ondatra.ATEDevice, top *ondatra.ATETopology, p *FlowParams) (v4, v6 *ondatra.Flow) {
+	t.Helper()
+	awaitIPv6Neighbors(t, p)
+	v4 = newFlow(t, ate, top, p, p.Name+"-IPv4", ipv4Header(p))
+	v6 = newFlow(t, ate, top, p, p.Name+"-IPv6", ipv6Header(p))
+	return v4, v6
+}
+
+func ipv4Header(p *FlowParams) *ondatra.IPv4Header {
+	h := ondatra.NewIPv4Header().WithDSCP(p.DSCP)
+	if p.TTL > 0 {
+		h.WithTTL(p.TTL)
+	}
+	if p.DontFragment {
+		h.WithDontFragment(true)
+	}
+	return h
+}
+
+func ipv6Header(p *FlowParams) *ondatra.IPv6Header {
+	h := ondatra.NewIPv6Header().WithDSCP(p.DSCP)
+	if p.TTL > 0 {
+		h.WithHopLimit(p.TTL)
+	}
+	return h
+}
+
+// awaitIPv6Neighbors waits for the DUT to have resolved the IPv6
+// addresses of the Src and Dst interfaces, if p identifies the DUT
+// ports.
+func awaitIPv6Neighbors(t testing.TB, p *FlowParams) {
+	t.Helper()
+	if p.DUT == nil {
+		return
+	}
+	for _, n := range []struct {
+		port *ondatra.Port
+		ate  *attrs.Attributes
+	}{{p.SrcPort, p.Src}, {p.DstPort, p.Dst}} {
+		if n.port == nil || n.ate.IPv6 == "" {
+			continue
+		}
+		llAddr := p.DUT.Telemetry().Interface(n.port.Name()).Subinterface(n.ate.SubinterfaceIndex()).Ipv6().Neighbor(n.ate.IPv6).LinkLayerAddress()
+		if _, ok := llAddr.Watch(t, NeighborTimeout, func(val *telemetry.QualifiedString) bool {
+			return val.IsPresent() && val.Val(t) != ""
+		}).Await(t); !ok {
+			t.Fatalf("DUT port %s did not resolve IPv6 neighbor %s within %v", n.port.Name(), n.ate.IPv6, NeighborTimeout)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/openconfig/ondatra"
+)
+
+// DefaultMaxStrayPkts is the number of frames a port that must not
+// receive traffic may receive anyway, to absorb control-plane chatter
+// such as ARP and LLDP.
+const DefaultMaxStrayPkts = 10
+
+// CheckNoTraffic reads the frames received by the named ATE ports
+// before and after calling run, which should run or wait for traffic,
+// and checks that none of the ports received more than maxStrayPkts
+// frames.  If maxStrayPkts is 0, DefaultMaxStrayPkts is used.  It
+// returns the frames received by each port.
+func CheckNoTraffic(t testing.TB, ate *ondatra.ATEDevice, ports []string, maxStrayPkts uint64, run func()) []uint64 {
+	t.Helper()
+	if maxStrayPkts == 0 {
+		maxStrayPkts = DefaultMaxStrayPkts
+	}
+	aps := make([]*ondatra.Port, len(ports))
+	for i, id := range ports {
+		aps[i] = ate.Port(t, id)
+	}
+
+	before := PortInPkts(t, ate, aps)
+	run()
+	after := PortInPkts(t, ate, aps)
+
+	deltas, errs := strayPkts(ports, before, after, maxStrayPkts)
+	for i, p := range ports {
+		t.Logf("Port %s received %d frames, want at most %d", p, deltas[i], maxStrayPkts)
+	}
+	for _, err := range errs {
+		t.Error(err)
+	}
+	return deltas
+}
+
+// strayPkts returns the frames received by each port between the
+// before and after counters, and an error for each port that received
+// more than maxStrayPkts frames.
+func strayPkts(ports []string, before, after []uint64, maxStrayPkts uint64) ([]uint64, []error) {
+	deltas := make([]uint64, len(ports))
+	var errs []error
+	for i, p := range ports {
+		if after[i] > before[i] {
+			deltas[i] = after[i] - before[i]
+		}
+		if deltas[i] > maxStrayPkts {
+			errs = append(errs, fmt.Errorf("port %s received %d frames, want at most %d", p, deltas[i], maxStrayPkts))
+		}
+	}
+	return deltas, errs
+}
@@ -0,0 +1,70 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestStrayPkts(t *testing.T) {
+	ports := []string{"port2", "port3"}
+	cases := []struct {
+		desc       string
+		before     []uint64
+		after      []uint64
+		want       []uint64
+		wantErrFor []string
+	}{{
+		desc:   "no frames",
+		before: []uint64{100, 200},
+		after:  []uint64{100, 200},
+		want:   []uint64{0, 0},
+	}, {
+		desc:   "chatter",
+		before: []uint64{100, 200},
+		after:  []uint64{110, 203},
+		want:   []uint64{10, 3},
+	}, {
+		desc:       "traffic",
+		before:     []uint64{100, 200},
+		after:      []uint64{100, 5200},
+		want:       []uint64{0, 5000},
+		wantErrFor: []string{"port3"},
+	}, {
+		desc:   "counter reset",
+		before: []uint64{100, 200},
+		after:  []uint64{0, 0},
+		want:   []uint64{0, 0},
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			got, errs := strayPkts(ports, c.before, c.after, DefaultMaxStrayPkts)
+			if diff := cmp.Diff(c.want, got); diff != "" {
+				t.Errorf("strayPkts() deltas -want,+got:\n%s", diff)
+			}
+			if len(errs) != len(c.wantErrFor) {
+				t.Fatalf("strayPkts() got errors %v, want errors for %v", errs, c.wantErrFor)
+			}
+			for i, err := range errs {
+				if !strings.Contains(err.Error(), c.wantErrFor[i]) {
+					t.Errorf("strayPkts() got error %v, want it to name %s", err, c.wantErrFor[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/openconfig/ondatra"
+)
+
+const (
+	// ethernetOverhead is the size of the Ethernet header and FCS, i.e.
+	// the difference between a frame size and the size of the IP packet
+	// it carries.
+	ethernetOverhead = 18
+	// imixMaxFrameSize is the largest frame size of the default IMIX.
+	imixMaxFrameSize = 1518
+	// MaxFrameSize is the largest frame size supported by the flow
+	// builders, that of jumbo frames.
+	MaxFrameSize = 9216
+)
+
+// Frame configures the size, rate and count of the frames of a flow.
+// The zero value uses the ATE defaults.
+type Frame struct {
+	// Size is the fixed frame size in bytes, including the FCS.
+	Size uint32
+	// IMIX sends the default IMIX of frame sizes instead of Size.
+	IMIX bool
+	// RatePct is the frame rate as a percentage of the line rate.
+	RatePct float64
+	// RatePPS is the frame rate in frames per second.  It takes
+	// precedence over RatePct.
+	RatePPS uint64
+	// Count, if set, makes the ATE transmit exactly Count frames, and
+	// RunFlow wait for them to be transmitted.
+	Count uint32
+	// Oversize expects the frames to carry packets larger than the MTU
+	// of a DUT port, to test that the DUT drops them with ValidateDrops.
+	Oversize bool
+}
+
+// maxSize returns the size of the largest frame, or zero if the ATE
+// default is used.
+func (f Frame) maxSize() uint32 {
+	if f.IMIX {
+		return imixMaxFrameSize
+	}
+	return f.Size
+}
+
+// String describes the frame parameters for the test log.
+func (f Frame) String() string {
+	var parts []string
+	switch {
+	case f.IMIX:
+		parts = append(parts, "IMIX")
+	case f.Size > 0:
+		parts = append(parts, fmt.Sprintf("%d bytes", f.Size))
+	default:
+		parts = append(parts, "default size")
+	}
+	switch {
+	case f.RatePPS > 0:
+		parts = append(parts, fmt.Sprintf("%d fps", f.RatePPS))
+	case f.RatePct > 0:
+		parts = append(parts, fmt.Sprintf("%g%% line rate", f.RatePct))
+	default:
+		parts = append(parts, "default rate")
+	}
+	if f.Count > 0 {
+		parts = append(parts, fmt.Sprintf("%d frames", f.Count))
+	}
+	if f.Oversize {
+		parts = append(parts, "oversize")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// checkMTU returns an error if the largest frame carries an IP packet
+// larger than mtu.  A zero mtu is not checked.
+func (f Frame) checkMTU(mtu uint16, where string) error {
+	size := f.maxSize()
+	if mtu == 0 || size <= ethernetOverhead {
+		return nil
+	}
+	if pkt := size - ethernetOverhead; pkt > uint32(mtu) {
+		return fmt.Errorf("frame size %d carries %d byte packets, larger than the MTU %d of %s", size, pkt, mtu, where)
+	}
+	return nil
+}
+
+// portMTU is the IP MTU of an ATE interface or DUT port the frames of a
+// flow go through.
+type portMTU struct {
+	mtu   uint16
+	where string
+	dut   bool
+}
+
+// checkMTUs returns an error if the frames are larger than MaxFrameSize,
+// or carry packets larger than the MTU of an ATE interface, or of a DUT
+// port unless f.Oversize.  If f.Oversize, it returns an error if the
+// packets fit the MTU of every DUT port, since they would not be
+// dropped, or a warning if the MTU of no DUT port is known.
+func (f Frame) checkMTUs(mtus []portMTU) (warning string, err error) {
+	if f.Size > MaxFrameSize {
+		return "", fmt.Errorf("frame size %d is larger than %d", f.Size, MaxFrameSize)
+	}
+	var dutKnown, dutExceeded bool
+	for _, m := range mtus {
+		err := f.checkMTU(m.mtu, m.where)
+		if !m.dut || !f.Oversize {
+			if err != nil {
+				return "", err
+			}
+			continue
+		}
+		if m.mtu > 0 {
+			dutKnown = true
+		}
+		if err != nil {
+			dutExceeded = true
+		}
+	}
+	switch {
+	case !f.Oversize || dutExceeded:
+		return "", nil
+	case dutKnown:
+		return "", fmt.Errorf("oversize frame size %d carries packets that fit the MTU of every DUT port", f.maxSize())
+	default:
+		return fmt.Sprintf("the MTU of the DUT ports is unknown, oversize frame size %d may not be dropped", f.maxSize()), nil
+	}
+}
+
+// apply configures the flow with the frame parameters.
+func (f Frame) apply(flow *ondatra.Flow) {
+	switch {
+	case f.IMIX:
+		flow.WithFrameSizeIMIXDefault()
+	case f.Size > 0:
+		flow.WithFrameSize(f.Size)
+	}
+	switch {
+	case f.RatePPS > 0:
+		flow.WithFrameRateFPS(f.RatePPS)
+	case f.RatePct > 0:
+		flow.WithFrameRatePct(f.RatePct)
+	}
+	if f.Count > 0 {
+		flow.Transmission().WithPatternFixedPacketCount(f.Count)
+	}
+}
+
+// flowFrames holds the frame parameters of the flows created by the
+// flow builders, by flow name, for RunFlow to report.
+var flowFrames sync.Map
+
+func setFlowFrame(name string, f Frame) {
+	flowFrames.Store(name, f)
+}
+
+func flowFrame(name string) Frame {
+	if f, ok := flowFrames.Load(name); ok {
+		return f.(Frame)
+	}
+	return Frame{}
+}
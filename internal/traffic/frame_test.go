@@ -0,0 +1,158 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"testing"
+)
+
+func TestFrameString(t *testing.T) {
+	cases := []struct {
+		desc  string
+		frame Frame
+		want  string
+	}{{
+		desc: "defaults",
+		want: "default size, default rate",
+	}, {
+		desc:  "fixed size and pct",
+		frame: Frame{Size: 512, RatePct: 10},
+		want:  "512 bytes, 10% line rate",
+	}, {
+		desc:  "imix pps count",
+		frame: Frame{Size: 512, IMIX: true, RatePct: 10, RatePPS: 1000, Count: 5000},
+		want:  "IMIX, 1000 fps, 5000 frames",
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			if got := c.frame.String(); got != c.want {
+				t.Errorf("String() got %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestFrameCheckMTU(t *testing.T) {
+	cases := []struct {
+		desc    string
+		frame   Frame
+		mtu     uint16
+		wantErr bool
+	}{{
+		desc:  "default size",
+		frame: Frame{},
+		mtu:   1500,
+	}, {
+		desc:  "no mtu",
+		frame: Frame{Size: 9000},
+	}, {
+		desc:  "fits",
+		frame: Frame{Size: 1518},
+		mtu:   1500,
+	}, {
+		desc:    "jumbo",
+		frame:   Frame{Size: 9018},
+		mtu:     1500,
+		wantErr: true,
+	}, {
+		desc:  "imix fits",
+		frame: Frame{IMIX: true},
+		mtu:   1500,
+	}, {
+		desc:    "imix too large",
+		frame:   Frame{IMIX: true},
+		mtu:     1400,
+		wantErr: true,
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			err := c.frame.checkMTU(c.mtu, "port1")
+			if gotErr := err != nil; gotErr != c.wantErr {
+				t.Errorf("checkMTU(%d) got error %v, want error %v", c.mtu, err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestFlowFrame(t *testing.T) {
+	want := Frame{Size: 1024, Count: 100}
+	setFlowFrame("TestFlowFrame", want)
+	if got := flowFrame("TestFlowFrame"); got != want {
+		t.Errorf("flowFrame() got %+v, want %+v", got, want)
+	}
+	if got := flowFrame("unknown"); got != (Frame{}) {
+		t.Errorf("flowFrame() of unknown flow got %+v, want zero value", got)
+	}
+}
+
+func TestCheckMTUs(t *testing.T) {
+	ate := []portMTU{{mtu: 9000, where: "ATE interface src"}, {mtu: 9000, where: "ATE interface dst"}}
+	cases := []struct {
+		desc        string
+		frame       Frame
+		mtus        []portMTU
+		wantWarning bool
+		wantErr     bool
+	}{{
+		desc:  "jumbo fits",
+		frame: Frame{Size: 9018},
+		mtus:  append(ate, portMTU{mtu: 9000, where: "DUT port port2", dut: true}),
+	}, {
+		desc:    "larger than max",
+		frame:   Frame{Size: MaxFrameSize + 1},
+		mtus:    ate,
+		wantErr: true,
+	}, {
+		desc:    "larger than ATE interface",
+		frame:   Frame{Size: 9018, Oversize: true},
+		mtus:    []portMTU{{mtu: 1500, where: "ATE interface src"}},
+		wantErr: true,
+	}, {
+		desc:    "larger than DUT port",
+		frame:   Frame{Size: 9018},
+		mtus:    append(ate, portMTU{mtu: 1500, where: "DUT port port2", dut: true}),
+		wantErr: true,
+	}, {
+		desc:  "oversize larger than DUT port",
+		frame: Frame{Size: 9018, Oversize: true},
+		mtus:  append(ate, portMTU{mtu: 9000, where: "DUT port port1", dut: true}, portMTU{mtu: 1500, where: "DUT port port2", dut: true}),
+	}, {
+		desc:    "oversize fits DUT ports",
+		frame:   Frame{Size: 1518, Oversize: true},
+		mtus:    append(ate, portMTU{mtu: 1500, where: "DUT port port2", dut: true}),
+		wantErr: true,
+	}, {
+		desc:        "oversize DUT MTU unknown",
+		frame:       Frame{Size: 9018, Oversize: true},
+		mtus:        append(ate, portMTU{where: "DUT port port2", dut: true}),
+		wantWarning: true,
+	}, {
+		desc:        "oversize without DUT ports",
+		frame:       Frame{Size: 9018, Oversize: true},
+		mtus:        ate,
+		wantWarning: true,
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			warning, err := c.frame.checkMTUs(c.mtus)
+			if gotErr := err != nil; gotErr != c.wantErr {
+				t.Errorf("checkMTUs() got error %v, want error %v", err, c.wantErr)
+			}
+			if gotWarning := warning != ""; gotWarning != c.wantWarning {
+				t.Errorf("checkMTUs() got warning %q, want warning %v", warning, c.wantWarning)
+			}
+		})
+	}
+}
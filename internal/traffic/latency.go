@@ -0,0 +1,99 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/ondatra"
+)
+
+// Latency is the forwarding latency of the received packets of a flow.
+// Jitter is the delay variation, i.e. the difference between the
+// maximum and minimum latency.
+type Latency struct {
+	Min, Avg, Max time.Duration
+	Jitter        time.Duration
+}
+
+// newLatency returns the latency from the minimum, average and maximum
+// in nanoseconds reported by the ATE.
+func newLatency(minNs, avgNs, maxNs float64) *Latency {
+	l := &Latency{
+		Min: time.Duration(minNs),
+		Avg: time.Duration(avgNs),
+		Max: time.Duration(maxNs),
+	}
+	l.Jitter = l.Max - l.Min
+	return l
+}
+
+// wantLatency returns whether opts requests latency assertions.
+func (o *Options) wantLatency() bool {
+	return o != nil && (o.MaxAvgLatency > 0 || o.MaxJitter > 0)
+}
+
+// enableOTGLatency enables latency tracking on the named flow of the
+// OTG config, and returns whether it was not already enabled.
+func enableOTGLatency(t testing.TB, top gosnappi.Config, name string) bool {
+	t.Helper()
+	for _, f := range top.Flows().Items() {
+		if f.Name() != name {
+			continue
+		}
+		if f.Metrics().Latency().Enable() {
+			return false
+		}
+		f.Metrics().SetEnable(true)
+		f.Metrics().Latency().
+			SetEnable(true).
+			SetMode(gosnappi.FlowLatencyMetricsMode.CUT_THROUGH)
+		f.Metrics().SetTimestamps(true)
+		return true
+	}
+	t.Fatalf("Flow %s is not in the OTG config", name)
+	return false
+}
+
+// otgFlowLatency returns the latency of the named flow from the OTG
+// flow metrics, or false if the OTG did not measure it or its raw API
+// is not available, i.e. the test was not run with fptest.RunTests.
+func otgFlowLatency(t testing.TB, ate *ondatra.ATEDevice, name string) (*Latency, bool) {
+	t.Helper()
+	req := gosnappi.NewMetricsRequest()
+	req.Flow().SetFlowNames([]string{name})
+	api, err := fptest.RawOTG(context.Background(), ate)
+	if err != nil {
+		t.Logf("Cannot get the OTG API of %s: %v", ate.Name(), err)
+		return nil, false
+	}
+	res, err := api.GetMetrics(req)
+	if err != nil {
+		t.Logf("Cannot get metrics of flow %s: %v", name, err)
+		return nil, false
+	}
+	for _, m := range res.FlowMetrics().Items() {
+		if m.Name() != name || !m.HasLatency() {
+			continue
+		}
+		l := m.Latency()
+		return newLatency(l.MinimumNs(), l.AverageNs(), l.MaximumNs()), true
+	}
+	return nil, false
+}
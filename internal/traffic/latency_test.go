@@ -0,0 +1,55 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewLatency(t *testing.T) {
+	got := newLatency(1500, 2000.4, 4000)
+	want := &Latency{
+		Min:    1500 * time.Nanosecond,
+		Avg:    2000 * time.Nanosecond,
+		Max:    4000 * time.Nanosecond,
+		Jitter: 2500 * time.Nanosecond,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("newLatency() -want,+got:\n%s", diff)
+	}
+}
+
+func TestWantLatency(t *testing.T) {
+	cases := []struct {
+		desc string
+		opts *Options
+		want bool
+	}{
+		{desc: "nil", opts: nil, want: false},
+		{desc: "no bounds", opts: &Options{}, want: false},
+		{desc: "avg latency", opts: &Options{MaxAvgLatency: time.Millisecond}, want: true},
+		{desc: "jitter", opts: &Options{MaxJitter: time.Millisecond}, want: true},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			if got := c.opts.wantLatency(); got != c.want {
+				t.Errorf("wantLatency() got %v, want %v", got, c.want)
+			}
+		})
+	}
+}
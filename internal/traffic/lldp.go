@@ -0,0 +1,201 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/openconfig/ondatra"
+)
+
+const (
+	// LLDPDstMAC is the nearest bridge group address LLDPDUs are sent
+	// to, and LLDPEtherType their EtherType.
+	LLDPDstMAC    = "01:80:c2:00:00:0e"
+	LLDPEtherType = 0x88cc
+
+	// TLV types and subtypes of an LLDPDU, per IEEE 802.1AB.
+	lldpTLVEnd        = 0
+	lldpTLVChassisID  = 1
+	lldpTLVPortID     = 2
+	lldpTLVTTL        = 3
+	lldpTLVSystemName = 5
+	lldpChassisIDMAC  = 4
+	lldpPortIDMAC     = 3
+	lldpPortIDName    = 5
+	// lldpMaxString is the maximum length of the port-id and the
+	// system-name.
+	lldpMaxString = 255
+)
+
+// LLDP is what an LLDP agent advertises in an LLDPDU: its chassis-id,
+// which is a MAC address, the port-id, which is the name of the port it
+// is sent from, the system-name, and the TTL in seconds after which the
+// receiver ages the information out.
+type LLDP struct {
+	ChassisID  string
+	PortID     string
+	SystemName string
+	TTL        uint16
+}
+
+// lldpTLV encodes a TLV of an LLDPDU.
+func lldpTLV(typ uint8, value []byte) []byte {
+	b := make([]byte, 2, 2+len(value))
+	binary.BigEndian.PutUint16(b, uint16(typ)<<9|uint16(len(value)))
+	return append(b, value...)
+}
+
+// marshal encodes the LLDPDU advertising l, with the chassis-id,
+// port-id, TTL, system-name if set, and end TLVs.
+func (l *LLDP) marshal() ([]byte, error) {
+	mac, err := net.ParseMAC(l.ChassisID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LLDP chassis-id: %w", err)
+	}
+	if n := len(l.PortID); n == 0 || n > lldpMaxString {
+		return nil, fmt.Errorf("invalid LLDP port-id %q: length %d not in [1, %d]", l.PortID, n, lldpMaxString)
+	}
+	if n := len(l.SystemName); n > lldpMaxString {
+		return nil, fmt.Errorf("invalid LLDP system-name %q: length %d exceeds %d", l.SystemName, n, lldpMaxString)
+	}
+
+	var b []byte
+	b = append(b, lldpTLV(lldpTLVChassisID, append([]byte{lldpChassisIDMAC}, mac...))...)
+	b = append(b, lldpTLV(lldpTLVPortID, append([]byte{lldpPortIDName}, l.PortID...))...)
+	ttl := make([]byte, 2)
+	binary.BigEndian.PutUint16(ttl, l.TTL)
+	b = append(b, lldpTLV(lldpTLVTTL, ttl)...)
+	if l.SystemName != "" {
+		b = append(b, lldpTLV(lldpTLVSystemName, []byte(l.SystemName))...)
+	}
+	return append(b, lldpTLV(lldpTLVEnd, nil)...), nil
+}
+
+// LLDPFrame returns the Ethernet frame, without FCS, of the LLDPDU
+// advertising l, sent from its chassis-id to LLDPDstMAC, e.g. for a
+// P4RT PacketOut.
+func LLDPFrame(l *LLDP) ([]byte, error) {
+	pdu, err := l.marshal()
+	if err != nil {
+		return nil, err
+	}
+	dst, err := net.ParseMAC(LLDPDstMAC)
+	if err != nil {
+		return nil, err
+	}
+	src, err := net.ParseMAC(l.ChassisID)
+	if err != nil {
+		return nil, err
+	}
+	b := append(append([]byte{}, dst...), src...)
+	b = binary.BigEndian.AppendUint16(b, LLDPEtherType)
+	return append(b, pdu...), nil
+}
+
+// decodeLLDP decodes the chassis-id, port-id, TTL and system-name of an
+// LLDPDU.  A chassis-id or port-id that is a MAC address is formatted as
+// such, and any other as a string.  Decoding stops at the end TLV or a
+// truncated TLV, since captured packets may be truncated.  It returns
+// nil if the LLDPDU has no chassis-id.
+func decodeLLDP(b []byte) *LLDP {
+	l := &LLDP{}
+	var hasChassisID bool
+	for len(b) >= 2 {
+		typ := b[0] >> 1
+		n := int(binary.BigEndian.Uint16(b) & 0x1ff)
+		if typ == lldpTLVEnd || len(b) < 2+n {
+			break
+		}
+		v := b[2 : 2+n]
+		b = b[2+n:]
+		switch typ {
+		case lldpTLVChassisID:
+			if n < 2 {
+				continue
+			}
+			hasChassisID = true
+			l.ChassisID = lldpID(v, lldpChassisIDMAC)
+		case lldpTLVPortID:
+			if n < 2 {
+				continue
+			}
+			l.PortID = lldpID(v, lldpPortIDMAC)
+		case lldpTLVTTL:
+			if n < 2 {
+				continue
+			}
+			l.TTL = binary.BigEndian.Uint16(v)
+		case lldpTLVSystemName:
+			l.SystemName = string(v)
+		}
+	}
+	if !hasChassisID {
+		return nil
+	}
+	return l
+}
+
+// lldpID formats the value of a chassis-id or port-id TLV, whose first
+// byte is its subtype, as a MAC address if the subtype is macSubtype,
+// and as a string otherwise.
+func lldpID(v []byte, macSubtype byte) string {
+	if v[0] == macSubtype && len(v) == 7 {
+		return net.HardwareAddr(v[1:]).String()
+	}
+	return string(v[1:])
+}
+
+// AddOTGLLDPFlow adds a flow to the OTG config sending the LLDPDU
+// advertising l out of the ATE port once per second, which makes the
+// ATE port look like an LLDP agent to the DUT.  The frames are sent
+// from the chassis-id of l.  The flow runs with the traffic of the
+// config, so the config must be pushed again and the traffic started
+// for the ATE to advertise l, and the ATE stops advertising it when the
+// traffic is stopped.
+func AddOTGLLDPFlow(t testing.TB, top gosnappi.Config, ap *ondatra.Port, l *LLDP) gosnappi.Flow {
+	t.Helper()
+	name := "LLDP-" + ap.ID()
+	pdu, err := l.marshal()
+	if err != nil {
+		t.Fatalf("Cannot create flow %s: %v", name, err)
+	}
+
+	flow := top.Flows().Add().SetName(name)
+	flow.Metrics().SetEnable(true)
+	flow.TxRx().Port().SetTxName(ap.ID())
+	eth := flow.Packet().Add().Ethernet()
+	eth.Src().SetValue(l.ChassisID)
+	eth.Dst().SetValue(LLDPDstMAC)
+	eth.EtherType().SetValue(LLDPEtherType)
+	flow.Packet().Add().Custom().SetBytes(hex.EncodeToString(pdu))
+
+	// The frame carries the Ethernet header, the LLDPDU and the FCS,
+	// padded to the minimum frame size.
+	size := 14 + len(pdu) + 4
+	if size < 64 {
+		size = 64
+	}
+	flow.Size().SetFixed(int32(size))
+	flow.Rate().SetPps(1)
+	flow.Duration().SetChoice(gosnappi.FlowDurationChoice.CONTINUOUS)
+	t.Logf("Flow %s: LLDP %+v", name, *l)
+	return flow
+}
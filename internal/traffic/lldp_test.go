@@ -0,0 +1,174 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func TestLLDPMarshal(t *testing.T) {
+	l := &LLDP{
+		ChassisID:  "02:00:00:00:00:01",
+		PortID:     "port1",
+		SystemName: "ate",
+		TTL:        20,
+	}
+	pdu, err := l.marshal()
+	if err != nil {
+		t.Fatalf("marshal() got error: %v", err)
+	}
+
+	// The LLDPDU must decode as such on the wire.
+	pkt := gopacket.NewPacket(pdu, layers.LayerTypeLinkLayerDiscovery, gopacket.Default)
+	if err := pkt.ErrorLayer(); err != nil {
+		t.Fatalf("Cannot decode LLDPDU: %v", err.Error())
+	}
+	lld := pkt.Layer(layers.LayerTypeLinkLayerDiscovery).(*layers.LinkLayerDiscovery)
+	if got, want := lld.ChassisID.Subtype, layers.LLDPChassisIDSubTypeMACAddr; got != want {
+		t.Errorf("chassis-id subtype got %v, want %v", got, want)
+	}
+	if got, want := net.HardwareAddr(lld.ChassisID.ID).String(), l.ChassisID; got != want {
+		t.Errorf("chassis-id got %s, want %s", got, want)
+	}
+	if got, want := lld.PortID.Subtype, layers.LLDPPortIDSubtypeIfaceName; got != want {
+		t.Errorf("port-id subtype got %v, want %v", got, want)
+	}
+	if got, want := string(lld.PortID.ID), l.PortID; got != want {
+		t.Errorf("port-id got %s, want %s", got, want)
+	}
+	if got, want := lld.TTL, l.TTL; got != want {
+		t.Errorf("TTL got %d, want %d", got, want)
+	}
+	info := pkt.Layer(layers.LayerTypeLinkLayerDiscoveryInfo).(*layers.LinkLayerDiscoveryInfo)
+	if got, want := info.SysName, l.SystemName; got != want {
+		t.Errorf("system-name got %s, want %s", got, want)
+	}
+
+	if diff := cmp.Diff(l, decodeLLDP(pdu)); diff != "" {
+		t.Errorf("decodeLLDP(marshal()) -want,+got:\n%s", diff)
+	}
+}
+
+func TestLLDPMarshalErrors(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		lldp *LLDP
+	}{{
+		desc: "chassis-id not a MAC",
+		lldp: &LLDP{ChassisID: "ate", PortID: "port1"},
+	}, {
+		desc: "no port-id",
+		lldp: &LLDP{ChassisID: "02:00:00:00:00:01"},
+	}, {
+		desc: "port-id too long",
+		lldp: &LLDP{ChassisID: "02:00:00:00:00:01", PortID: strings.Repeat("p", 256)},
+	}, {
+		desc: "system-name too long",
+		lldp: &LLDP{ChassisID: "02:00:00:00:00:01", PortID: "port1", SystemName: strings.Repeat("s", 256)},
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			if _, err := tc.lldp.marshal(); err == nil {
+				t.Errorf("marshal() got no error, want error")
+			}
+		})
+	}
+}
+
+func TestDecodePCAPLLDP(t *testing.T) {
+	lld := &layers.LinkLayerDiscovery{
+		ChassisID: layers.LLDPChassisID{Subtype: layers.LLDPChassisIDSubTypeLocal, ID: []byte("dut")},
+		PortID:    layers.LLDPPortID{Subtype: layers.LLDPPortIDSubtypeIfaceName, ID: []byte("Ethernet1")},
+		TTL:       120,
+		Values: []layers.LinkLayerDiscoveryValue{{
+			Type:   layers.LLDPTLVPortDescription,
+			Length: 8,
+			Value:  []byte("to ate:1"),
+		}, {
+			Type:   layers.LLDPTLVSysName,
+			Length: 9,
+			Value:  []byte("dut.lab-1"),
+		}},
+	}
+	frame := serialize(t, lld)
+	want := &LLDP{ChassisID: "dut", PortID: "Ethernet1", SystemName: "dut.lab-1", TTL: 120}
+
+	for _, tc := range []struct {
+		desc  string
+		frame []byte
+		want  *LLDP
+	}{{
+		desc:  "full",
+		frame: frame,
+		want:  want,
+	}, {
+		// A capture truncated within the system-name TLV still has the
+		// TLVs before it.
+		desc:  "truncated",
+		frame: frame[:len(frame)-6],
+		want:  &LLDP{ChassisID: "dut", PortID: "Ethernet1", TTL: 120},
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			pkts, err := DecodePCAP(pcap(t, tc.frame))
+			if err != nil {
+				t.Fatalf("DecodePCAP() got error: %v", err)
+			}
+			if len(pkts) != 1 {
+				t.Fatalf("DecodePCAP() got %d packets, want 1", len(pkts))
+			}
+			if diff := cmp.Diff(tc.want, pkts[0].LLDP); diff != "" {
+				t.Errorf("LLDP -want,+got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLLDPFrame(t *testing.T) {
+	l := &LLDP{
+		ChassisID:  "02:00:00:00:00:01",
+		PortID:     "port1",
+		SystemName: "controller",
+		TTL:        20,
+	}
+	frame, err := LLDPFrame(l)
+	if err != nil {
+		t.Fatalf("LLDPFrame() got error: %v", err)
+	}
+	eth := gopacket.NewPacket(frame, layers.LayerTypeEthernet, gopacket.Default).Layer(layers.LayerTypeEthernet).(*layers.Ethernet)
+	if got := eth.DstMAC.String(); got != LLDPDstMAC {
+		t.Errorf("destination MAC got %s, want %s", got, LLDPDstMAC)
+	}
+	if got := eth.SrcMAC.String(); got != l.ChassisID {
+		t.Errorf("source MAC got %s, want %s", got, l.ChassisID)
+	}
+	if diff := cmp.Diff(l, DecodeFrame(frame).LLDP); diff != "" {
+		t.Errorf("DecodeFrame(LLDPFrame()) LLDP -want,+got:\n%s", diff)
+	}
+
+	if _, err := LLDPFrame(&LLDP{ChassisID: "ate", PortID: "port1"}); err == nil {
+		t.Errorf("LLDPFrame() of an invalid chassis-id got no error, want error")
+	}
+}
+
+func TestDecodeLLDPNotLLDP(t *testing.T) {
+	if got := decodeLLDP([]byte{0, 0}); got != nil {
+		t.Errorf("decodeLLDP(end TLV) got %+v, want nil", got)
+	}
+}
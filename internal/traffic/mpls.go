@@ -0,0 +1,86 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/openconfig/ondatra"
+)
+
+// maxMPLSLabel is the largest 20-bit MPLS label.
+const maxMPLSLabel = 1<<20 - 1
+
+// labelStack returns a copy of the MPLS label stack of a flow, from the
+// top, with only the last entry marked as the bottom of the stack.  It
+// returns an error if a label or traffic class does not fit its field.
+func labelStack(labels []*MPLSLabel) ([]*MPLSLabel, error) {
+	var stack []*MPLSLabel
+	for i, l := range labels {
+		if l.Label > maxMPLSLabel {
+			return nil, fmt.Errorf("MPLS label %d of entry %d is larger than %d", l.Label, i, maxMPLSLabel)
+		}
+		if l.TC > 7 {
+			return nil, fmt.Errorf("MPLS traffic class %d of entry %d is larger than 7", l.TC, i)
+		}
+		e := *l
+		e.BottomOfStack = i == len(labels)-1
+		stack = append(stack, &e)
+	}
+	return stack, nil
+}
+
+// mplsHeaders returns the ATE headers of the MPLS label stack of a
+// flow.  The ATE sets the bottom of stack bit of the last header.
+func mplsHeaders(t testing.TB, name string, labels []*MPLSLabel) []ondatra.Header {
+	t.Helper()
+	stack, err := labelStack(labels)
+	if err != nil {
+		t.Fatalf("Cannot create flow %s: %v", name, err)
+	}
+	var hdrs []ondatra.Header
+	for _, l := range stack {
+		h := ondatra.NewMPLSHeader().WithLabel(l.Label).WithEXP(l.TC)
+		if l.TTL > 0 {
+			h.WithTTL(l.TTL)
+		}
+		hdrs = append(hdrs, h)
+	}
+	return hdrs
+}
+
+// addOTGMPLS adds the MPLS label stack of a flow to its OTG packet.
+func addOTGMPLS(t testing.TB, flow gosnappi.Flow, labels []*MPLSLabel) {
+	t.Helper()
+	stack, err := labelStack(labels)
+	if err != nil {
+		t.Fatalf("Cannot create flow %s: %v", flow.Name(), err)
+	}
+	for _, l := range stack {
+		m := flow.Packet().Add().Mpls()
+		m.Label().SetValue(int32(l.Label))
+		m.TrafficClass().SetValue(int32(l.TC))
+		if l.TTL > 0 {
+			m.TimeToLive().SetValue(int32(l.TTL))
+		}
+		if l.BottomOfStack {
+			m.BottomOfStack().SetValue(1)
+		} else {
+			m.BottomOfStack().SetValue(0)
+		}
+	}
+}
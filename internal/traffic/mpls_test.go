@@ -0,0 +1,107 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// TestLabelStackRoundTrip checks that the label stack of a flow is
+// decoded from a captured packet as it was sent.
+func TestLabelStackRoundTrip(t *testing.T) {
+	cases := []struct {
+		desc   string
+		labels []*MPLSLabel
+	}{{
+		desc:   "single",
+		labels: []*MPLSLabel{{Label: 100, TC: 5, TTL: 64}},
+	}, {
+		desc: "multiple",
+		labels: []*MPLSLabel{
+			{Label: 100, TC: 1, TTL: 64},
+			{Label: 200, TC: 2, TTL: 32},
+			{Label: maxMPLSLabel, TC: 7, TTL: 1},
+		},
+	}, {
+		desc: "bottom of stack ignored",
+		labels: []*MPLSLabel{
+			{Label: 100, TTL: 64, BottomOfStack: true},
+			{Label: 200, TTL: 64},
+		},
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			stack, err := labelStack(c.labels)
+			if err != nil {
+				t.Fatalf("labelStack() got error: %v", err)
+			}
+			var ls []gopacket.SerializableLayer
+			for _, l := range stack {
+				ls = append(ls, &layers.MPLS{Label: l.Label, TrafficClass: l.TC, TTL: l.TTL, StackBottom: l.BottomOfStack})
+			}
+			ls = append(ls, &layers.IPv4{
+				Version:  4,
+				TTL:      64,
+				Protocol: layers.IPProtocolNoNextHeader,
+				SrcIP:    net.ParseIP("192.0.2.2"),
+				DstIP:    net.ParseIP("198.51.100.1"),
+			})
+			pkts, err := DecodePCAP(pcap(t, serialize(t, ls...)))
+			if err != nil {
+				t.Fatalf("DecodePCAP() got error: %v", err)
+			}
+			if len(pkts) != 1 {
+				t.Fatalf("DecodePCAP() got %d packets, want 1", len(pkts))
+			}
+			if diff := cmp.Diff(stack, pkts[0].MPLS); diff != "" {
+				t.Errorf("Decoded label stack -want,+got:\n%s", diff)
+			}
+			for i, l := range pkts[0].MPLS {
+				if got, want := l.BottomOfStack, i == len(c.labels)-1; got != want {
+					t.Errorf("Label %d bottom of stack got %t, want %t", l.Label, got, want)
+				}
+				if got, want := l.Label, c.labels[i].Label; got != want {
+					t.Errorf("Label %d got %d, want %d", i, got, want)
+				}
+			}
+			if pkts[0].Outer() == nil {
+				t.Errorf("Decoded packet has no IP header under the label stack")
+			}
+		})
+	}
+}
+
+func TestLabelStackError(t *testing.T) {
+	for _, l := range []*MPLSLabel{{Label: maxMPLSLabel + 1}, {Label: 100, TC: 8}} {
+		if _, err := labelStack([]*MPLSLabel{l}); err == nil {
+			t.Errorf("labelStack(%+v) got no error, want error", l)
+		}
+	}
+}
+
+func TestPacketLabels(t *testing.T) {
+	p := &Packet{MPLS: []*MPLSLabel{{Label: 100}, {Label: 200, BottomOfStack: true}}}
+	if diff := cmp.Diff([]uint32{100, 200}, p.Labels()); diff != "" {
+		t.Errorf("Labels() -want,+got:\n%s", diff)
+	}
+	if got := (&Packet{}).Labels(); got != nil {
+		t.Errorf("Labels() of unlabelled packet got %v, want nil", got)
+	}
+}
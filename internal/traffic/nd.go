@@ -0,0 +1,205 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/ondatra"
+)
+
+// ND is the decoded view of an IPv6 neighbor discovery message in a
+// captured packet, per RFC 4861.  Type is the ICMPv6 type of the
+// message, and the other fields are only set for the messages that
+// carry them.
+type ND struct {
+	Type uint8
+	// Target is the target address of a neighbor solicitation or
+	// advertisement.
+	Target string
+	// LinkLayerAddress is the source link-layer address option of a
+	// solicitation or router advertisement, or the target link-layer
+	// address option of a neighbor advertisement.
+	LinkLayerAddress string
+	// Router, Solicited and Override are the flags of a neighbor
+	// advertisement.
+	Router, Solicited, Override bool
+	// RouterLifetime is the router lifetime of a router advertisement,
+	// Managed and Other its managed and other configuration flags, and
+	// Prefixes the prefixes of its prefix information options, e.g.
+	// "2001:db8::/64".
+	RouterLifetime time.Duration
+	Managed, Other bool
+	Prefixes       []string
+}
+
+// IsRA returns whether the message is a router advertisement.
+func (n *ND) IsRA() bool {
+	return n != nil && n.Type == layers.ICMPv6TypeRouterAdvertisement
+}
+
+// IsNA returns whether the message is a neighbor advertisement.
+func (n *ND) IsNA() bool {
+	return n != nil && n.Type == layers.ICMPv6TypeNeighborAdvertisement
+}
+
+// ndLinkLayerAddress returns the link-layer address of the option of
+// the given type, or "" if there is none.
+func ndLinkLayerAddress(opts layers.ICMPv6Options, typ layers.ICMPv6Opt) string {
+	for _, o := range opts {
+		if o.Type == typ && len(o.Data) >= 6 {
+			return net.HardwareAddr(o.Data[:6]).String()
+		}
+	}
+	return ""
+}
+
+// ndPrefixes returns the prefixes of the prefix information options.
+// The data of an option follows its type and length: the prefix length,
+// flags, valid and preferred lifetimes and a reserved field precede the
+// prefix.
+func ndPrefixes(opts layers.ICMPv6Options) []string {
+	var prefixes []string
+	for _, o := range opts {
+		if o.Type != layers.ICMPv6OptPrefixInfo || len(o.Data) < 30 {
+			continue
+		}
+		prefixes = append(prefixes, fmt.Sprintf("%s/%d", net.IP(o.Data[14:30]), o.Data[0]))
+	}
+	return prefixes
+}
+
+// decodeND decodes a neighbor discovery message layer, or returns nil
+// if the layer is not one.
+func decodeND(l gopacket.Layer) *ND {
+	switch l := l.(type) {
+	case *layers.ICMPv6NeighborSolicitation:
+		return &ND{
+			Type:             layers.ICMPv6TypeNeighborSolicitation,
+			Target:           l.TargetAddress.String(),
+			LinkLayerAddress: ndLinkLayerAddress(l.Options, layers.ICMPv6OptSourceAddress),
+		}
+	case *layers.ICMPv6NeighborAdvertisement:
+		return &ND{
+			Type:             layers.ICMPv6TypeNeighborAdvertisement,
+			Target:           l.TargetAddress.String(),
+			LinkLayerAddress: ndLinkLayerAddress(l.Options, layers.ICMPv6OptTargetAddress),
+			Router:           l.Router(),
+			Solicited:        l.Solicited(),
+			Override:         l.Override(),
+		}
+	case *layers.ICMPv6RouterAdvertisement:
+		return &ND{
+			Type:             layers.ICMPv6TypeRouterAdvertisement,
+			LinkLayerAddress: ndLinkLayerAddress(l.Options, layers.ICMPv6OptSourceAddress),
+			RouterLifetime:   time.Duration(l.RouterLifetime) * time.Second,
+			Managed:          l.ManagedAddressConfig(),
+			Other:            l.OtherConfig(),
+			Prefixes:         ndPrefixes(l.Options),
+		}
+	}
+	return nil
+}
+
+// solicitedNode returns the solicited-node multicast address of the
+// IPv6 address, which is ff02::1:ff00:0/104 followed by its last 24
+// bits, and the MAC address it maps to.
+func solicitedNode(ip net.IP) (net.IP, net.HardwareAddr) {
+	addr := net.IP{0xff, 0x02, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01, 0xff, ip[13], ip[14], ip[15]}
+	mac := net.HardwareAddr{0x33, 0x33, 0xff, ip[13], ip[14], ip[15]}
+	return addr, mac
+}
+
+// marshalNS encodes the IPv6 packet of a neighbor solicitation for the
+// target address sent from the IPv6 and MAC addresses of src, and the
+// destination MAC address of its frame.
+func marshalNS(src *attrs.Attributes, target string) ([]byte, net.HardwareAddr, error) {
+	srcIP, targetIP := net.ParseIP(src.IPv6), net.ParseIP(target)
+	if srcIP == nil || srcIP.To4() != nil {
+		return nil, nil, fmt.Errorf("source %q is not an IPv6 address", src.IPv6)
+	}
+	if targetIP == nil || targetIP.To4() != nil {
+		return nil, nil, fmt.Errorf("target %q is not an IPv6 address", target)
+	}
+	srcMAC, err := net.ParseMAC(src.MAC)
+	if err != nil {
+		return nil, nil, fmt.Errorf("source MAC: %w", err)
+	}
+	dstIP, dstMAC := solicitedNode(targetIP)
+
+	ip := &layers.IPv6{
+		Version:    6,
+		HopLimit:   255,
+		NextHeader: layers.IPProtocolICMPv6,
+		SrcIP:      srcIP,
+		DstIP:      dstIP,
+	}
+	icmp := &layers.ICMPv6{TypeCode: layers.CreateICMPv6TypeCode(layers.ICMPv6TypeNeighborSolicitation, 0)}
+	if err := icmp.SetNetworkLayerForChecksum(ip); err != nil {
+		return nil, nil, err
+	}
+	ns := &layers.ICMPv6NeighborSolicitation{
+		TargetAddress: targetIP,
+		Options: layers.ICMPv6Options{{
+			Type: layers.ICMPv6OptSourceAddress,
+			Data: srcMAC,
+		}},
+	}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ip, icmp, ns); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), dstMAC, nil
+}
+
+// AddOTGNSFlow adds a flow to the OTG config sending a neighbor
+// solicitation for the target address out of the ATE port once per
+// second, from the IPv6 and MAC addresses of src.  The DUT owning the
+// target address answers each with a solicited neighbor advertisement.
+// As with AddOTGLLDPFlow, the flow runs with the traffic of the config.
+func AddOTGNSFlow(t testing.TB, top gosnappi.Config, ap *ondatra.Port, src *attrs.Attributes, target string) gosnappi.Flow {
+	t.Helper()
+	name := "NS-" + ap.ID()
+	pkt, dstMAC, err := marshalNS(src, target)
+	if err != nil {
+		t.Fatalf("Cannot create flow %s: %v", name, err)
+	}
+
+	flow := top.Flows().Add().SetName(name)
+	flow.Metrics().SetEnable(true)
+	flow.TxRx().Port().SetTxName(ap.ID())
+	eth := flow.Packet().Add().Ethernet()
+	eth.Src().SetValue(src.MAC)
+	eth.Dst().SetValue(dstMAC.String())
+	eth.EtherType().SetValue(int32(layers.EthernetTypeIPv6))
+	flow.Packet().Add().Custom().SetBytes(hex.EncodeToString(pkt))
+
+	// The frame carries the Ethernet header, the IPv6 packet and the
+	// FCS.
+	flow.Size().SetFixed(int32(14 + len(pkt) + 4))
+	flow.Rate().SetPps(1)
+	flow.Duration().SetChoice(gosnappi.FlowDurationChoice.CONTINUOUS)
+	t.Logf("Flow %s: neighbor solicitation for %s from %s", name, target, src.IPv6)
+	return flow
+}
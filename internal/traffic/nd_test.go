@@ -0,0 +1,163 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/openconfig/featureprofiles/internal/attrs"
+)
+
+// ndFrame returns an Ethernet frame carrying the ICMPv6 neighbor
+// discovery message msg from src to dst.
+func ndFrame(t *testing.T, src, dst string, typ uint8, msg gopacket.SerializableLayer) []byte {
+	t.Helper()
+	ip := &layers.IPv6{
+		Version:    6,
+		HopLimit:   255,
+		NextHeader: layers.IPProtocolICMPv6,
+		SrcIP:      net.ParseIP(src),
+		DstIP:      net.ParseIP(dst),
+	}
+	icmp := &layers.ICMPv6{TypeCode: layers.CreateICMPv6TypeCode(typ, 0)}
+	if err := icmp.SetNetworkLayerForChecksum(ip); err != nil {
+		t.Fatalf("Cannot set checksum layer: %v", err)
+	}
+	return serialize(t, ip, icmp, msg)
+}
+
+func TestDecodePCAPND(t *testing.T) {
+	mac := net.HardwareAddr{0x02, 0, 0, 0, 0, 1}
+	prefix := make([]byte, 30)
+	prefix[0] = 64
+	copy(prefix[14:], net.ParseIP("2001:db8:1::"))
+
+	ra := ndFrame(t, "fe80::1", "ff02::1", layers.ICMPv6TypeRouterAdvertisement, &layers.ICMPv6RouterAdvertisement{
+		HopLimit:       64,
+		Flags:          0x80,
+		RouterLifetime: 1800,
+		Options: layers.ICMPv6Options{
+			{Type: layers.ICMPv6OptSourceAddress, Data: mac},
+			{Type: layers.ICMPv6OptPrefixInfo, Data: prefix},
+		},
+	})
+	na := ndFrame(t, "2001:db8:1::1", "2001:db8:1::2", layers.ICMPv6TypeNeighborAdvertisement, &layers.ICMPv6NeighborAdvertisement{
+		Flags:         0xe0,
+		TargetAddress: net.ParseIP("2001:db8:1::1"),
+		Options: layers.ICMPv6Options{
+			{Type: layers.ICMPv6OptTargetAddress, Data: mac},
+		},
+	})
+
+	pkts, err := DecodePCAP(pcap(t, ra, na))
+	if err != nil {
+		t.Fatalf("DecodePCAP() got error: %v", err)
+	}
+	if len(pkts) != 2 {
+		t.Fatalf("DecodePCAP() got %d packets, want 2", len(pkts))
+	}
+	if got, want := pkts[0].Time, time.Unix(0, 0); !got.Equal(want) {
+		t.Errorf("capture time got %v, want %v", got, want)
+	}
+
+	wantRA := &ND{
+		Type:             layers.ICMPv6TypeRouterAdvertisement,
+		LinkLayerAddress: mac.String(),
+		RouterLifetime:   30 * time.Minute,
+		Managed:          true,
+		Prefixes:         []string{"2001:db8:1::/64"},
+	}
+	if diff := cmp.Diff(wantRA, pkts[0].ND); diff != "" {
+		t.Errorf("RA -want,+got:\n%s", diff)
+	}
+	if !pkts[0].ND.IsRA() || pkts[0].ND.IsNA() {
+		t.Errorf("RA IsRA() got %v, IsNA() got %v, want true, false", pkts[0].ND.IsRA(), pkts[0].ND.IsNA())
+	}
+
+	wantNA := &ND{
+		Type:             layers.ICMPv6TypeNeighborAdvertisement,
+		Target:           "2001:db8:1::1",
+		LinkLayerAddress: mac.String(),
+		Router:           true,
+		Solicited:        true,
+		Override:         true,
+	}
+	if diff := cmp.Diff(wantNA, pkts[1].ND); diff != "" {
+		t.Errorf("NA -want,+got:\n%s", diff)
+	}
+}
+
+func TestMarshalNS(t *testing.T) {
+	src := &attrs.Attributes{IPv6: "2001:db8:1::2", MAC: "02:00:00:00:00:02"}
+	pkt, dstMAC, err := marshalNS(src, "2001:db8:1::abcd:1")
+	if err != nil {
+		t.Fatalf("marshalNS() got error: %v", err)
+	}
+	if got, want := dstMAC.String(), "33:33:ff:cd:00:01"; got != want {
+		t.Errorf("destination MAC got %s, want %s", got, want)
+	}
+
+	p := gopacket.NewPacket(pkt, layers.LayerTypeIPv6, gopacket.Default)
+	if err := p.ErrorLayer(); err != nil {
+		t.Fatalf("Cannot decode neighbor solicitation: %v", err.Error())
+	}
+	ip := p.Layer(layers.LayerTypeIPv6).(*layers.IPv6)
+	wantDst := net.IP{0xff, 0x02, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01, 0xff, 0xcd, 0x00, 0x01}
+	if !ip.DstIP.Equal(wantDst) {
+		t.Errorf("destination address got %s, want %s", ip.DstIP, wantDst)
+	}
+	if got, want := ip.HopLimit, uint8(255); got != want {
+		t.Errorf("hop limit got %d, want %d", got, want)
+	}
+	want := &ND{
+		Type:             layers.ICMPv6TypeNeighborSolicitation,
+		Target:           "2001:db8:1::abcd:1",
+		LinkLayerAddress: src.MAC,
+	}
+	if diff := cmp.Diff(want, decodeND(p.Layer(layers.LayerTypeICMPv6NeighborSolicitation))); diff != "" {
+		t.Errorf("neighbor solicitation -want,+got:\n%s", diff)
+	}
+}
+
+func TestMarshalNSErrors(t *testing.T) {
+	for _, tc := range []struct {
+		desc   string
+		src    *attrs.Attributes
+		target string
+	}{{
+		desc:   "IPv4 source",
+		src:    &attrs.Attributes{IPv4: "192.0.2.2", MAC: "02:00:00:00:00:02"},
+		target: "2001:db8:1::1",
+	}, {
+		desc:   "IPv4 target",
+		src:    &attrs.Attributes{IPv6: "2001:db8:1::2", MAC: "02:00:00:00:00:02"},
+		target: "192.0.2.1",
+	}, {
+		desc:   "no MAC",
+		src:    &attrs.Attributes{IPv6: "2001:db8:1::2"},
+		target: "2001:db8:1::1",
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			if _, _, err := marshalNS(tc.src, tc.target); err == nil {
+				t.Errorf("marshalNS() got no error, want error")
+			}
+		})
+	}
+}
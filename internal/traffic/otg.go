@@ -0,0 +1,213 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/internal/otgutils"
+	"github.com/openconfig/ondatra"
+	otgtelemetry "github.com/openconfig/ondatra/telemetry/otg"
+)
+
+// OTGFlowParams describe an IPv4 flow between OTG interfaces configured
+// from attrs.Attributes with AddToOTG.
+type OTGFlowParams struct {
+	// Name is the name of the flow.
+	Name string
+	// Src and Dst are the attributes of the OTG interfaces the flow is
+	// sent from and to, and SrcPort and DstPort their ATE ports.
+	Src, Dst         *attrs.Attributes
+	SrcPort, DstPort *ondatra.Port
+	// Gateway is the attributes of the DUT interface connected to Src.
+	// The flow is sent to the MAC address its IPv4 address resolves to.
+	Gateway *attrs.Attributes
+	// DstStart and DstCount are the range of IPv4 destination addresses
+	// of the flow.  If DstStart is not set, the flow is sent to the
+	// IPv4 address of Dst.
+	DstStart string
+	DstCount uint32
+	// DSCP is the DSCP of the IPv4 header.
+	DSCP uint8
+	// TTL is the TTL of the IPv4 header.  If zero, the OTG default is
+	// used.
+	TTL uint8
+	// MPLS is the MPLS label stack sent between the Ethernet and IPv4
+	// headers, from the top, as for FlowParams.
+	MPLS []*MPLSLabel
+	// Frame configures the frame size, rate and count of the flow.  IMIX
+	// is not supported.
+	Frame Frame
+}
+
+// AddOTGIPv4Flow adds an IPv4 flow with metrics enabled to the OTG
+// config as described by p.  It waits for the Src interface to resolve
+// the Gateway, so the OTG protocols must already be started.  The
+// config must be pushed again for the flow to take effect.
+func AddOTGIPv4Flow(t testing.TB, ate *ondatra.ATEDevice, top gosnappi.Config, p *OTGFlowParams) gosnappi.Flow {
+	t.Helper()
+	if p.Frame.IMIX {
+		t.Fatalf("Cannot create flow %s: IMIX is not supported on OTG", p.Name)
+	}
+	if _, err := p.Frame.checkMTUs([]portMTU{
+		{mtu: p.Src.MTU, where: "OTG interface " + p.Src.Name},
+		{mtu: p.Dst.MTU, where: "OTG interface " + p.Dst.Name},
+	}); err != nil {
+		t.Fatalf("Cannot create flow %s: %v", p.Name, err)
+	}
+
+	mac, ok := ate.OTG().Telemetry().Interface(p.Src.Name+".Eth").Ipv4Neighbor(p.Gateway.IPv4).LinkLayerAddress().Watch(
+		t, NeighborTimeout, func(val *otgtelemetry.QualifiedString) bool {
+			return val.IsPresent() && val.Val(t) != ""
+		}).Await(t)
+	if !ok {
+		t.Fatalf("OTG interface %s did not resolve %s within %v", p.Src.Name, p.Gateway.IPv4, NeighborTimeout)
+	}
+
+	flow := top.Flows().Add().SetName(p.Name)
+	flow.Metrics().SetEnable(true)
+	flow.TxRx().Port().
+		SetTxName(p.SrcPort.ID()).
+		SetRxName(p.DstPort.ID())
+	eth := flow.Packet().Add().Ethernet()
+	eth.Src().SetValue(p.Src.MAC)
+	eth.Dst().SetValue(mac.Val(t))
+	addOTGMPLS(t, flow, p.MPLS)
+	v4 := flow.Packet().Add().Ipv4()
+	v4.Src().SetValue(p.Src.IPv4)
+	if p.DstStart != "" {
+		v4.Dst().Increment().SetStart(p.DstStart).SetCount(int32(p.DstCount))
+	} else {
+		v4.Dst().SetValue(p.Dst.IPv4)
+	}
+	v4.Priority().Dscp().Phb().SetValue(int32(p.DSCP))
+	if p.TTL > 0 {
+		v4.TimeToLive().SetValue(int32(p.TTL))
+	}
+
+	f := p.Frame
+	if f.Size > 0 {
+		flow.Size().SetFixed(int32(f.Size))
+	}
+	switch {
+	case f.RatePPS > 0:
+		flow.Rate().SetPps(int64(f.RatePPS))
+	case f.RatePct > 0:
+		flow.Rate().SetPercentage(float32(f.RatePct))
+	}
+	if f.Count > 0 {
+		flow.Duration().FixedPackets().SetPackets(int32(f.Count))
+	} else {
+		flow.Duration().SetChoice(gosnappi.FlowDurationChoice.CONTINUOUS)
+	}
+	setFlowFrame(p.Name, f)
+	t.Logf("Flow %s: %v", p.Name, f)
+	return flow
+}
+
+// RunOTGFlow runs the traffic of the pushed OTG config and returns the
+// result of the named flow without validating it.  As with RunFlow, the
+// traffic runs for opts.Duration if set, and otherwise until the flow
+// has transmitted opts.MinTxPkts packets, or the Frame.Count it was
+// created with, or opts.MaxDuration elapses.  The flow counters are
+// then allowed to stabilize.  If opts requests latency checks, latency
+// tracking is enabled on the flow, pushing the config again if needed,
+// and the latency is included in the result.  The result is recorded
+// with RecordResult.  opts may be nil.
+func RunOTGFlow(t testing.TB, ate *ondatra.ATEDevice, top gosnappi.Config, name string, opts *Options) *Result {
+	t.Helper()
+	defer fptest.TimeStep(t, "run traffic")()
+	r := runOTGFlow(t, ate, top, name, opts)
+	RecordResult(t, r)
+	return r
+}
+
+// runOTGFlow runs the traffic as RunOTGFlow does, without recording the
+// result.  Starting the traffic clears the OTG flow metrics.
+func runOTGFlow(t testing.TB, ate *ondatra.ATEDevice, top gosnappi.Config, name string, opts *Options) *Result {
+	t.Helper()
+	otg := ate.OTG()
+	if opts.wantLatency() && enableOTGLatency(t, top, name) {
+		t.Logf("Enabling latency tracking on flow %s", name)
+		otg.PushConfig(t, top)
+		otg.StartProtocols(t)
+	}
+	counters := otg.Telemetry().Flow(name).Counters()
+	frame := flowFrame(name)
+	start := time.Now()
+	otg.StartTraffic(t)
+	if opts != nil && opts.Duration > 0 {
+		time.Sleep(opts.Duration)
+	} else {
+		n := opts.minTxPkts()
+		if frame.Count > 0 {
+			n = uint64(frame.Count)
+		}
+		if !pollUntil(txPollInterval, opts.maxDuration(), func() bool {
+			q := counters.OutPkts().Lookup(t)
+			return q.IsPresent() && q.Val(t) >= n
+		}) {
+			t.Logf("Flow %s did not transmit %d packets within %v", name, n, opts.maxDuration())
+		}
+	}
+	otg.StopTraffic(t)
+	elapsed := time.Since(start)
+
+	if !pollUntil(stablePollInterval, stableTimeout, stable(func() (uint64, uint64) {
+		c := counters.Get(t)
+		return c.GetOutPkts(), c.GetInPkts()
+	})) {
+		t.Logf("Counters of flow %s did not stabilize within %v after it was stopped", name, stableTimeout)
+	}
+	otgutils.LogFlowMetrics(t, otg, top)
+
+	c := counters.Get(t)
+	r := newResult(name, c.GetOutPkts(), c.GetInPkts(), elapsed)
+	r.Frame = frame
+	r.End = start.Add(elapsed)
+	t.Logf("Flow %s (%v) sent %d packets (%.1f pps) and received %d packets (%.1f pps), loss %.3f%%",
+		r.Flow, r.Frame, r.OutPkts, r.TxRate(), r.InPkts, r.RxRate(), r.LossPct)
+	if opts.wantLatency() {
+		if l, ok := otgFlowLatency(t, ate, name); ok {
+			r.Latency = l
+			t.Logf("Flow %s latency min %v, avg %v, max %v, jitter %v", name, l.Min, l.Avg, l.Max, l.Jitter)
+		}
+	}
+	return r
+}
+
+// ValidateOTGFlow runs the traffic as RunOTGFlow does, and validates the
+// named flow from its OTG flow metrics as ValidateFlow does, including
+// its retry with opts.Retry.  opts may be nil.
+func ValidateOTGFlow(t testing.TB, ate *ondatra.ATEDevice, top gosnappi.Config, name string, opts *Options) *Result {
+	t.Helper()
+	defer fptest.TimeStep(t, "validate traffic")()
+	r := runOTGFlow(t, ate, top, name, opts)
+	if r.suspect(opts) {
+		logSuspect(t, r, opts)
+		time.Sleep(retrySettle)
+		r = retried(t, r, runOTGFlow(t, ate, top, name, opts))
+	}
+	RecordResult(t, r)
+	warnLatency(t, r, opts)
+	for _, err := range r.validate(opts) {
+		t.Error(err)
+	}
+	return r
+}
@@ -0,0 +1,231 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openconfig/ondatra"
+)
+
+const (
+	// BackgroundSampleInterval is how often the counters of background
+	// traffic are sampled.
+	BackgroundSampleInterval = time.Second
+	// degradedLossPct is the loss percentage above which an interval
+	// counts towards an outage.
+	degradedLossPct = 1
+)
+
+// Outage is the longest window of consecutive sample intervals in which
+// traffic was not, or only partially, delivered.
+type Outage struct {
+	// Start and End are the start of the first and the end of the last
+	// degraded interval.  They are zero if there was no outage.
+	Start, End time.Time
+	// LostPkts is the number of packets lost in the window.
+	LostPkts uint64
+	// Duration is the time it takes to send LostPkts at the rate of the
+	// flow, i.e. the estimated duration of the outage.
+	Duration time.Duration
+}
+
+// smooth returns a copy of the intervals in which packets received in
+// excess of those sent in an interval are credited to the previous and
+// then the next interval, up to the packets lost in those.  This undoes
+// the jitter of the ATE stat polling, which can count packets in the
+// interval after they were sent.
+func smooth(intervals []*Interval) []*Interval {
+	out := make([]*Interval, len(intervals))
+	for i, iv := range intervals {
+		c := *iv
+		out[i] = &c
+	}
+	credit := func(from, to *Interval) {
+		if from.InPkts <= from.OutPkts || to.InPkts >= to.OutPkts {
+			return
+		}
+		n := from.InPkts - from.OutPkts
+		if d := to.OutPkts - to.InPkts; d < n {
+			n = d
+		}
+		from.InPkts -= n
+		to.InPkts += n
+	}
+	for i, iv := range out {
+		if i > 0 {
+			credit(iv, out[i-1])
+		}
+		if i < len(out)-1 {
+			credit(iv, out[i+1])
+		}
+	}
+	return out
+}
+
+// LongestOutage returns the longest outage in the intervals of a flow
+// sent at pps packets per second, after smoothing the intervals over
+// one sample.  It returns the zero Outage if no interval lost more than
+// degradedLossPct of its packets.
+func LongestOutage(intervals []*Interval, pps uint64) Outage {
+	var longest, cur Outage
+	for _, iv := range smooth(intervals) {
+		if iv.LossPct() <= degradedLossPct {
+			cur = Outage{}
+			continue
+		}
+		if cur.Start.IsZero() {
+			cur.Start = iv.Start
+		}
+		cur.End = iv.End
+		cur.LostPkts += iv.OutPkts - iv.InPkts
+		if pps > 0 {
+			cur.Duration = time.Duration(cur.LostPkts) * time.Second / time.Duration(pps)
+		}
+		if cur.LostPkts > longest.LostPkts {
+			longest = cur
+		}
+	}
+	return longest
+}
+
+// Background is a flow running at a fixed rate in the background, e.g.
+// across a convergence event, whose counters are sampled to measure the
+// outage of its traffic.
+type Background struct {
+	ate      *ondatra.ATEDevice
+	flow     *ondatra.Flow
+	pps      uint64
+	sampler  *Sampler
+	stopOnce sync.Once
+	samples  []*Sample
+}
+
+// StartBackground starts the flow at pps packets per second, sampling
+// its counters every BackgroundSampleInterval until Stop is called.
+// The flow and the sampling are also stopped when the test ends, so
+// they do not outlive a failed test.
+func StartBackground(t testing.TB, ate *ondatra.ATEDevice, flow *ondatra.Flow, pps uint64) *Background {
+	t.Helper()
+	if pps == 0 {
+		t.Fatalf("Cannot start background flow %s without a rate", flow.Name())
+	}
+	b := &Background{ate: ate, flow: flow.WithFrameRateFPS(pps), pps: pps}
+	t.Logf("Starting background flow %s at %d fps", flow.Name(), pps)
+	ate.Traffic().Start(t, b.flow)
+	b.sampler = StartSampler(t, ate, flow.Name(), BackgroundSampleInterval)
+	t.Cleanup(func() { b.stop(t) })
+	return b
+}
+
+// stop stops the sampling and the flow once.
+func (b *Background) stop(t testing.TB) {
+	b.stopOnce.Do(func() {
+		b.samples = b.sampler.Stop(t)
+		b.ate.Traffic().Stop(t)
+	})
+}
+
+// Stop stops the flow and returns the longest outage of its traffic
+// since it was started, which is also recorded with RecordResult.  It
+// is an error if the counters of the flow could not be sampled.
+func (b *Background) Stop(t testing.TB) Outage {
+	t.Helper()
+	b.stop(t)
+	intervals := Intervals(b.samples)
+	if len(intervals) == 0 {
+		t.Errorf("Background flow %s has no counter samples", b.flow.Name())
+	}
+	o := LongestOutage(intervals, b.pps)
+	t.Logf("Background flow %s longest outage %v (%d packets lost)", b.flow.Name(), o.Duration, o.LostPkts)
+	RecordResult(t, b.result(o))
+	return o
+}
+
+// result returns the result of the flow from its first and last
+// samples, with its longest outage o.
+func (b *Background) result(o Outage) *Result {
+	r := newResult(b.flow.Name(), 0, 0, 0)
+	if n := len(b.samples); n > 0 {
+		first, last := b.samples[0], b.samples[n-1]
+		r = newResult(b.flow.Name(), last.OutPkts, last.InPkts, last.Time.Sub(first.Time))
+		r.End = last.Time
+	}
+	r.Frame = Frame{RatePPS: b.pps}
+	r.Outage = &o
+	return r
+}
+
+// OutageBetween returns the longest outage of the traffic in the sample
+// intervals that overlap the time range from start to end.  It must be
+// called after Stop.
+func (b *Background) OutageBetween(start, end time.Time) Outage {
+	var intervals []*Interval
+	for _, iv := range Intervals(b.samples) {
+		if iv.End.Before(start) || iv.Start.After(end) {
+			continue
+		}
+		intervals = append(intervals, iv)
+	}
+	return LongestOutage(intervals, b.pps)
+}
+
+// outageSince returns the longest outage in the intervals of a flow
+// sent at pps packets per second that end after the event, and the
+// estimated time its traffic stopped being delivered.  The packets
+// received in the first degraded interval are assumed to have been
+// delivered at the flow rate before the traffic stopped, and the
+// estimate is no earlier than the event.  The time is zero if there is
+// no outage.
+func outageSince(intervals []*Interval, pps uint64, event time.Time) (Outage, time.Time) {
+	var after []*Interval
+	for _, iv := range intervals {
+		if iv.End.After(event) {
+			after = append(after, iv)
+		}
+	}
+	o := LongestOutage(after, pps)
+	if o.Start.IsZero() {
+		return o, time.Time{}
+	}
+	stopped := o.Start
+	if pps > 0 {
+		for _, iv := range smooth(after) {
+			if !iv.Start.Equal(o.Start) {
+				continue
+			}
+			stopped = iv.Start.Add(time.Duration(iv.InPkts) * time.Second / time.Duration(pps))
+			if stopped.After(iv.End) {
+				stopped = iv.End
+			}
+			break
+		}
+	}
+	if stopped.Before(event) {
+		stopped = event
+	}
+	return o, stopped
+}
+
+// OutageSince returns the longest outage of the traffic in the sample
+// intervals that end after the event, e.g. a route withdrawal, and the
+// estimated time the traffic stopped being delivered, which is no
+// earlier than the event.  The time is zero if there was no outage.
+// It must be called after Stop.
+func (b *Background) OutageSince(event time.Time) (Outage, time.Time) {
+	return outageSince(Intervals(b.samples), b.pps, event)
+}
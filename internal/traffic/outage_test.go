@@ -0,0 +1,143 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLongestOutage(t *testing.T) {
+	t0 := time.Unix(0, 0)
+	at := func(sec int) time.Time { return t0.Add(time.Duration(sec) * time.Second) }
+	// intervals returns one-second intervals with 1000 packets sent
+	// and the given packets received.
+	intervals := func(in ...uint64) []*Interval {
+		var ivs []*Interval
+		for i, n := range in {
+			ivs = append(ivs, &Interval{Start: at(i), End: at(i + 1), OutPkts: 1000, InPkts: n})
+		}
+		return ivs
+	}
+
+	cases := []struct {
+		desc      string
+		intervals []*Interval
+		want      Outage
+	}{{
+		desc: "none",
+	}, {
+		desc:      "no loss",
+		intervals: intervals(1000, 1000, 1000),
+	}, {
+		desc:      "within degraded threshold",
+		intervals: intervals(1000, 995, 1000),
+	}, {
+		desc:      "single interval",
+		intervals: intervals(1000, 700, 1000),
+		want:      Outage{Start: at(1), End: at(2), LostPkts: 300, Duration: 300 * time.Millisecond},
+	}, {
+		desc:      "consecutive intervals",
+		intervals: intervals(1000, 600, 0, 800, 1000),
+		want:      Outage{Start: at(1), End: at(4), LostPkts: 1600, Duration: 1600 * time.Millisecond},
+	}, {
+		desc:      "longest of two",
+		intervals: intervals(900, 1000, 500, 500, 1000),
+		want:      Outage{Start: at(2), End: at(4), LostPkts: 1000, Duration: time.Second},
+	}, {
+		desc:      "polling jitter smoothed",
+		intervals: intervals(1000, 800, 1200, 1000),
+	}, {
+		desc:      "polling jitter smoothed into next",
+		intervals: intervals(1000, 1150, 850, 1000),
+	}, {
+		desc:      "jitter partly offsets loss",
+		intervals: intervals(1000, 500, 1100, 1000),
+		want:      Outage{Start: at(1), End: at(2), LostPkts: 400, Duration: 400 * time.Millisecond},
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			got := LongestOutage(c.intervals, 1000)
+			if diff := cmp.Diff(c.want, got); diff != "" {
+				t.Errorf("LongestOutage() -want,+got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestOutageSince(t *testing.T) {
+	t0 := time.Unix(0, 0)
+	at := func(ms int) time.Time { return t0.Add(time.Duration(ms) * time.Millisecond) }
+	// intervals returns one-second intervals with 1000 packets sent
+	// and the given packets received.
+	intervals := func(in ...uint64) []*Interval {
+		var ivs []*Interval
+		for i, n := range in {
+			ivs = append(ivs, &Interval{Start: at(1000 * i), End: at(1000 * (i + 1)), OutPkts: 1000, InPkts: n})
+		}
+		return ivs
+	}
+
+	cases := []struct {
+		desc        string
+		intervals   []*Interval
+		event       time.Time
+		wantOutage  Outage
+		wantStopped time.Time
+	}{{
+		desc:      "no outage",
+		intervals: intervals(1000, 1000, 1000),
+		event:     at(500),
+	}, {
+		desc:        "stopped within interval",
+		intervals:   intervals(1000, 250, 0, 0),
+		event:       at(1100),
+		wantOutage:  Outage{Start: at(1000), End: at(4000), LostPkts: 2750, Duration: 2750 * time.Millisecond},
+		wantStopped: at(1250),
+	}, {
+		desc:        "not before event",
+		intervals:   intervals(1000, 250, 0),
+		event:       at(1400),
+		wantOutage:  Outage{Start: at(1000), End: at(3000), LostPkts: 1750, Duration: 1750 * time.Millisecond},
+		wantStopped: at(1400),
+	}, {
+		desc:        "outage before event ignored",
+		intervals:   intervals(0, 0, 1000, 1000, 500, 1000),
+		event:       at(2500),
+		wantOutage:  Outage{Start: at(4000), End: at(5000), LostPkts: 500, Duration: 500 * time.Millisecond},
+		wantStopped: at(4500),
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			gotOutage, gotStopped := outageSince(c.intervals, 1000, c.event)
+			if diff := cmp.Diff(c.wantOutage, gotOutage); diff != "" {
+				t.Errorf("outageSince() outage -want,+got:\n%s", diff)
+			}
+			if !gotStopped.Equal(c.wantStopped) {
+				t.Errorf("outageSince() stopped got %v, want %v", gotStopped, c.wantStopped)
+			}
+		})
+	}
+}
+
+func TestSmoothDoesNotModifyIntervals(t *testing.T) {
+	ivs := []*Interval{{OutPkts: 1000, InPkts: 800}, {OutPkts: 1000, InPkts: 1200}}
+	smooth(ivs)
+	if ivs[0].InPkts != 800 || ivs[1].InPkts != 1200 {
+		t.Errorf("smooth() modified its input: %+v, %+v", ivs[0], ivs[1])
+	}
+}
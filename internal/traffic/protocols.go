@@ -0,0 +1,211 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/bgp"
+	"github.com/openconfig/featureprofiles/internal/isis"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+)
+
+const (
+	// DefaultBGPTimeout is how long StartProtocolsAndAwait waits for
+	// each BGP session by default.
+	DefaultBGPTimeout = bgp.EstablishTimeout
+	// DefaultISISTimeout is how long StartProtocolsAndAwait waits for
+	// each IS-IS adjacency by default.
+	DefaultISISTimeout = isis.AdjacencyTimeout
+	// DefaultProtocolDeadline is how long StartProtocolsAndAwait waits
+	// for the whole control plane by default.
+	DefaultProtocolDeadline = 5 * time.Minute
+)
+
+// Readiness describes the control plane that StartProtocolsAndAwait
+// waits for after starting the ATE protocols.  The DUT telemetry is used
+// to decide whether each element has converged.
+type Readiness struct {
+	// DUT is the DUT the ATE is connected to.
+	DUT *ondatra.DUTDevice
+	// Neighbors maps the IDs of the DUT ports to the attributes of the
+	// ATE interfaces connected to them, whose IPv4 and IPv6 addresses
+	// the DUT must resolve.
+	Neighbors map[string]*attrs.Attributes
+	// TaggedNeighbors maps the IDs of the DUT ports to further ATE
+	// interfaces tagged with a VLAN on them, whose addresses the DUT
+	// must resolve on the subinterface of that VLAN.
+	TaggedNeighbors map[string][]*attrs.Attributes
+	// BGPPeers are the ATE BGP peers whose sessions must be established.
+	BGPPeers []*bgp.ATEPeer
+	// ISISPorts are the IDs of the DUT ports whose Level-2 IS-IS
+	// adjacency must be up.
+	ISISPorts []string
+
+	// NeighborTimeout, BGPTimeout and ISISTimeout are how long to wait
+	// for each neighbor, BGP session and IS-IS adjacency, and Deadline
+	// how long to wait for all of them.  If zero, NeighborTimeout,
+	// DefaultBGPTimeout, DefaultISISTimeout and DefaultProtocolDeadline
+	// are used respectively.
+	NeighborTimeout time.Duration
+	BGPTimeout      time.Duration
+	ISISTimeout     time.Duration
+	Deadline        time.Duration
+}
+
+// orDefault returns d, or def if d is zero.
+func orDefault(d, def time.Duration) time.Duration {
+	if d == 0 {
+		return def
+	}
+	return d
+}
+
+// waitTimeout returns how long to wait for an element with the given
+// timeout so as not to exceed the deadline at now, or zero if the
+// deadline has passed.
+func waitTimeout(timeout time.Duration, deadline, now time.Time) time.Duration {
+	left := deadline.Sub(now)
+	if left <= 0 {
+		return 0
+	}
+	if timeout < left {
+		return timeout
+	}
+	return left
+}
+
+// StartProtocolsAndAwait pushes the ATE topology, starts its protocols,
+// and waits for the DUT to resolve the neighbors, establish the BGP
+// sessions and bring up the IS-IS adjacencies of r.  Elements that do
+// not converge within their timeout or before the deadline fail the
+// test, which reports each of them.  With only Neighbors set, it only
+// waits for ARP and ND.
+func StartProtocolsAndAwait(t testing.TB, ate *ondatra.ATEDevice, top *ondatra.ATETopology, r *Readiness) {
+	t.Helper()
+	top.Push(t)
+	StartPushedProtocolsAndAwait(t, ate, top, r)
+}
+
+// StartPushedProtocolsAndAwait starts the protocols of an ATE topology
+// that was already pushed, e.g. concurrently with configuring the DUT
+// with fptest.RunConcurrently, and waits for them to converge as
+// StartProtocolsAndAwait does.
+func StartPushedProtocolsAndAwait(t testing.TB, ate *ondatra.ATEDevice, top *ondatra.ATETopology, r *Readiness) {
+	t.Helper()
+	top.StartProtocols(t)
+	AwaitProtocols(t, ate, top, r)
+}
+
+// AwaitProtocols waits for the protocols of an ATE topology already
+// started, e.g. by topocache.EnsureStarted, to converge as
+// StartProtocolsAndAwait does.
+func AwaitProtocols(t testing.TB, ate *ondatra.ATEDevice, top *ondatra.ATETopology, r *Readiness) {
+	t.Helper()
+	start := time.Now()
+	deadline := start.Add(orDefault(r.Deadline, DefaultProtocolDeadline))
+	var failed []string
+	wait := func(what string, timeout time.Duration, converged func(time.Duration) error) {
+		d := waitTimeout(timeout, deadline, time.Now())
+		if d == 0 {
+			failed = append(failed, what+": deadline exceeded before waiting")
+			return
+		}
+		if err := converged(d); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", what, err))
+		}
+	}
+
+	neighbors := make(map[string][]*attrs.Attributes)
+	for id, a := range r.Neighbors {
+		neighbors[id] = append(neighbors[id], a)
+	}
+	for id, as := range r.TaggedNeighbors {
+		neighbors[id] = append(neighbors[id], as...)
+	}
+	ports := make([]string, 0, len(neighbors))
+	for id := range neighbors {
+		ports = append(ports, id)
+	}
+	sort.Strings(ports)
+	for _, id := range ports {
+		dp := r.DUT.Port(t, id)
+		for _, a := range neighbors[id] {
+			index, where := a.SubinterfaceIndex(), id
+			if index > 0 {
+				where = fmt.Sprintf("%s.%d", id, index)
+			}
+			for _, addr := range []string{a.IPv4, a.IPv6} {
+				if addr == "" {
+					continue
+				}
+				addr := addr
+				wait(fmt.Sprintf("DUT port %s neighbor %s", where, addr), orDefault(r.NeighborTimeout, NeighborTimeout), func(d time.Duration) error {
+					return awaitNeighbor(t, r.DUT, dp, index, addr, d)
+				})
+			}
+		}
+	}
+	for _, p := range r.BGPPeers {
+		for _, nbr := range p.Neighbors() {
+			addr := nbr.Address
+			wait("BGP neighbor "+addr, orDefault(r.BGPTimeout, DefaultBGPTimeout), func(d time.Duration) error {
+				if !bgp.AwaitEstablished(t, r.DUT, addr, d) {
+					return fmt.Errorf("session not established within %v", d)
+				}
+				return nil
+			})
+		}
+	}
+	for _, id := range r.ISISPorts {
+		intf := r.DUT.Port(t, id).Name()
+		wait("IS-IS adjacency on DUT port "+id, orDefault(r.ISISTimeout, DefaultISISTimeout), func(d time.Duration) error {
+			_, err := isis.Adjacency(t, r.DUT, intf, d)
+			return err
+		})
+	}
+
+	if len(failed) > 0 {
+		t.Fatalf("Control plane did not converge:\n%s", strings.Join(failed, "\n"))
+	}
+	t.Logf("Control plane converged after %v", time.Since(start))
+}
+
+// awaitNeighbor waits for the subinterface of the DUT port with the
+// given index to resolve the IPv4 or IPv6 neighbor address within the
+// timeout.
+func awaitNeighbor(t testing.TB, dut *ondatra.DUTDevice, p *ondatra.Port, index uint32, addr string, timeout time.Duration) error {
+	t.Helper()
+	pred := func(val *telemetry.QualifiedString) bool {
+		return val.IsPresent() && val.Val(t) != ""
+	}
+	subintf := dut.Telemetry().Interface(p.Name()).Subinterface(index)
+	var ok bool
+	if strings.Contains(addr, ":") {
+		_, ok = subintf.Ipv6().Neighbor(addr).LinkLayerAddress().Watch(t, timeout, pred).Await(t)
+	} else {
+		_, ok = subintf.Ipv4().Neighbor(addr).LinkLayerAddress().Watch(t, timeout, pred).Await(t)
+	}
+	if !ok {
+		return fmt.Errorf("not resolved within %v", timeout)
+	}
+	return nil
+}
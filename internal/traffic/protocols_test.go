@@ -0,0 +1,51 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWaitTimeout(t *testing.T) {
+	now := time.Unix(100, 0)
+	cases := []struct {
+		desc     string
+		timeout  time.Duration
+		deadline time.Time
+		want     time.Duration
+	}{
+		{"timeout first", time.Minute, now.Add(5 * time.Minute), time.Minute},
+		{"deadline first", 5 * time.Minute, now.Add(time.Minute), time.Minute},
+		{"deadline passed", time.Minute, now.Add(-time.Second), 0},
+		{"deadline now", time.Minute, now, 0},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			if got := waitTimeout(c.timeout, c.deadline, now); got != c.want {
+				t.Errorf("waitTimeout(%v) got %v, want %v", c.timeout, got, c.want)
+			}
+		})
+	}
+}
+
+func TestOrDefault(t *testing.T) {
+	if got := orDefault(0, time.Minute); got != time.Minute {
+		t.Errorf("orDefault(0) got %v, want %v", got, time.Minute)
+	}
+	if got := orDefault(time.Second, time.Minute); got != time.Second {
+		t.Errorf("orDefault(1s) got %v, want %v", got, time.Second)
+	}
+}
@@ -0,0 +1,293 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"encoding/json"
+	"flag"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/ondatra"
+)
+
+// resultsSuffix is the suffix of the traffic results file of a test in
+// the outputs directory.
+const resultsSuffix = ".traffic.json"
+
+// latencyJSON is the JSON form of a Latency.
+type latencyJSON struct {
+	MinNs    int64 `json:"min_ns"`
+	AvgNs    int64 `json:"avg_ns"`
+	MaxNs    int64 `json:"max_ns"`
+	JitterNs int64 `json:"jitter_ns"`
+}
+
+// attemptJSON is the JSON form of the first attempt of a retried
+// Result.
+type attemptJSON struct {
+	Timestamp time.Time `json:"timestamp"`
+	TxPkts    uint64    `json:"tx_pkts"`
+	RxPkts    uint64    `json:"rx_pkts"`
+	LossPct   float64   `json:"loss_pct"`
+}
+
+// resultJSON is the JSON form of a Result.
+type resultJSON struct {
+	Flow      string       `json:"flow"`
+	Timestamp time.Time    `json:"timestamp"`
+	Frame     string       `json:"frame"`
+	TxPkts    uint64       `json:"tx_pkts"`
+	RxPkts    uint64       `json:"rx_pkts"`
+	LossPct   float64      `json:"loss_pct"`
+	TxRatePPS float64      `json:"tx_rate_pps"`
+	RxRatePPS float64      `json:"rx_rate_pps"`
+	Latency   *latencyJSON `json:"latency,omitempty"`
+	OutageMs  *float64     `json:"outage_ms,omitempty"`
+	// Retried flags the flows rerun because of Options.Retry, so that
+	// the rate of the flakes they hide can be tracked.
+	Retried      bool         `json:"retried,omitempty"`
+	FirstAttempt *attemptJSON `json:"first_attempt,omitempty"`
+}
+
+// MarshalJSON serializes the result for the traffic results file, with
+// durations in nanoseconds or milliseconds as their name tells.
+func (r *Result) MarshalJSON() ([]byte, error) {
+	j := resultJSON{
+		Flow:      r.Flow,
+		Timestamp: r.End,
+		Frame:     r.Frame.String(),
+		TxPkts:    r.OutPkts,
+		RxPkts:    r.InPkts,
+		LossPct:   r.LossPct,
+		TxRatePPS: r.TxRate(),
+		RxRatePPS: r.RxRate(),
+	}
+	if l := r.Latency; l != nil {
+		j.Latency = &latencyJSON{
+			MinNs:    l.Min.Nanoseconds(),
+			AvgNs:    l.Avg.Nanoseconds(),
+			MaxNs:    l.Max.Nanoseconds(),
+			JitterNs: l.Jitter.Nanoseconds(),
+		}
+	}
+	if r.Outage != nil {
+		ms := float64(r.Outage.Duration) / float64(time.Millisecond)
+		j.OutageMs = &ms
+	}
+	if f := r.FirstAttempt; f != nil {
+		j.Retried = true
+		j.FirstAttempt = &attemptJSON{
+			Timestamp: f.End,
+			TxPkts:    f.OutPkts,
+			RxPkts:    f.InPkts,
+			LossPct:   f.LossPct,
+		}
+	}
+	return json.Marshal(j)
+}
+
+// Convergence is the time the DUT took to converge after an event,
+// e.g. a route withdrawal, as seen in the dataplane and in the RIB.
+type Convergence struct {
+	// Event describes the event, and Time is when it was triggered.
+	Event string
+	Time  time.Time
+	// Dataplane is the time from the event until the traffic was
+	// affected, and RIB the time until the RIB telemetry reflected it.
+	Dataplane time.Duration
+	RIB       time.Duration
+}
+
+// convergenceJSON is the JSON form of a Convergence.
+type convergenceJSON struct {
+	Event       string    `json:"event"`
+	Timestamp   time.Time `json:"timestamp"`
+	DataplaneMs float64   `json:"dataplane_ms"`
+	RIBMs       float64   `json:"rib_ms"`
+}
+
+// MarshalJSON serializes the convergence for the traffic results file,
+// with durations in milliseconds.
+func (c *Convergence) MarshalJSON() ([]byte, error) {
+	return json.Marshal(convergenceJSON{
+		Event:       c.Event,
+		Timestamp:   c.Time,
+		DataplaneMs: float64(c.Dataplane) / float64(time.Millisecond),
+		RIBMs:       float64(c.RIB) / float64(time.Millisecond),
+	})
+}
+
+// Rate is the rate a flow was received at, measured against the rate
+// the DUT was configured to limit it to, e.g. by a shaper.
+type Rate struct {
+	Flow string
+	// Time is when the rate was measured.
+	Time time.Time
+	// ConfiguredBps is the configured rate in bits per second, and
+	// MeasuredBps the rate the flow was received at.
+	ConfiguredBps uint64
+	MeasuredBps   float64
+}
+
+// rateJSON is the JSON form of a Rate.
+type rateJSON struct {
+	Flow          string    `json:"flow"`
+	Timestamp     time.Time `json:"timestamp"`
+	ConfiguredBps uint64    `json:"configured_bps"`
+	MeasuredBps   float64   `json:"measured_bps"`
+}
+
+// MarshalJSON serializes the rate for the traffic results file.
+func (r *Rate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(rateJSON{
+		Flow:          r.Flow,
+		Timestamp:     r.Time,
+		ConfiguredBps: r.ConfiguredBps,
+		MeasuredBps:   r.MeasuredBps,
+	})
+}
+
+// dutJSON describes a DUT of the testbed in the traffic results file.
+type dutJSON struct {
+	ID      string `json:"id"`
+	Vendor  string `json:"vendor"`
+	Model   string `json:"model"`
+	Version string `json:"version"`
+}
+
+// resultsFile is the content of the traffic results file of a test.
+type resultsFile struct {
+	Test       string            `json:"test"`
+	Testbed    string            `json:"testbed"`
+	DUTs       []*dutJSON        `json:"duts"`
+	Deviations map[string]string `json:"deviations"`
+	Results    []*Result         `json:"results"`
+	// Convergence and Rates are omitted from tests that do not measure
+	// any.
+	Convergence []*Convergence `json:"convergence,omitempty"`
+	Rates       []*Rate        `json:"rates,omitempty"`
+}
+
+// resultsLog holds the traffic results files of the tests, by test
+// name, so that the results of a test are appended to a single file.
+type resultsLog struct {
+	mu    sync.Mutex
+	files map[string]*resultsFile
+}
+
+// add updates the file of the test, creating it with newFile if needed,
+// and writes the whole file with write.  Calls are serialized, so
+// subtests may add results concurrently.
+func (l *resultsLog) add(test string, newFile func() *resultsFile, update func(*resultsFile), write func([]byte) error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.files == nil {
+		l.files = make(map[string]*resultsFile)
+	}
+	f, ok := l.files[test]
+	if !ok {
+		f = newFile()
+		f.Test = test
+		l.files[test] = f
+	}
+	update(f)
+	content, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return write(content)
+}
+
+// trafficResults holds the traffic results files written by RecordResult.
+var trafficResults resultsLog
+
+// topLevelTest returns the name of the top-level test of t, whose
+// results file the results of its subtests are appended to.
+func topLevelTest(t testing.TB) string {
+	return strings.SplitN(t.Name(), "/", 2)[0]
+}
+
+// newResultsFile describes the testbed and the active deviations for
+// the traffic results file of a test.
+func newResultsFile(t testing.TB) func() *resultsFile {
+	return func() *resultsFile {
+		f := &resultsFile{Deviations: deviations.Active()}
+		if fl := flag.Lookup("testbed"); fl != nil {
+			f.Testbed = fl.Value.String()
+		}
+		duts := ondatra.DUTs(t)
+		ids := make([]string, 0, len(duts))
+		for id := range duts {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			d := duts[id]
+			f.DUTs = append(f.DUTs, &dutJSON{ID: id, Vendor: d.Vendor().String(), Model: d.Model(), Version: d.Version()})
+		}
+		return f
+	}
+}
+
+// RecordResult appends the result to the traffic results file of the
+// top-level test in the directory specified by the -outputs_dir flag,
+// along with the testbed, the DUT vendors and versions, and the active
+// deviations.  The flow runners of this package record their results,
+// so tests only need to call it for results they compute themselves.
+func RecordResult(t testing.TB, r *Result) {
+	t.Helper()
+	test := topLevelTest(t)
+	if err := trafficResults.add(test, newResultsFile(t), func(f *resultsFile) {
+		f.Results = append(f.Results, r)
+	}, func(content []byte) error {
+		return fptest.ReplaceOutput(test, resultsSuffix, string(content))
+	}); err != nil {
+		t.Logf("Could not write traffic results of flow %s: %v", r.Flow, err)
+	}
+}
+
+// RecordConvergence appends the convergence to the traffic results file
+// of the top-level test, as RecordResult does for flow results.
+func RecordConvergence(t testing.TB, c *Convergence) {
+	t.Helper()
+	test := topLevelTest(t)
+	if err := trafficResults.add(test, newResultsFile(t), func(f *resultsFile) {
+		f.Convergence = append(f.Convergence, c)
+	}, func(content []byte) error {
+		return fptest.ReplaceOutput(test, resultsSuffix, string(content))
+	}); err != nil {
+		t.Logf("Could not write convergence after %s: %v", c.Event, err)
+	}
+}
+
+// RecordRate appends the rate to the traffic results file of the
+// top-level test, as RecordResult does for flow results.
+func RecordRate(t testing.TB, r *Rate) {
+	t.Helper()
+	test := topLevelTest(t)
+	if err := trafficResults.add(test, newResultsFile(t), func(f *resultsFile) {
+		f.Rates = append(f.Rates, r)
+	}, func(content []byte) error {
+		return fptest.ReplaceOutput(test, resultsSuffix, string(content))
+	}); err != nil {
+		t.Logf("Could not write rate of flow %s: %v", r.Flow, err)
+	}
+}
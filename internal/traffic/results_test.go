@@ -0,0 +1,201 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestResultMarshalJSON(t *testing.T) {
+	end := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	r := newResult("f", 1000, 900, 10*time.Second)
+	r.End = end
+	r.Frame = Frame{Size: 512}
+	r.Latency = newLatency(1000, 2000, 4000)
+	r.Outage = &Outage{Duration: 1500 * time.Microsecond}
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		t.Fatalf("json.Marshal() got error: %v", err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("json.Unmarshal() got error: %v", err)
+	}
+	want := map[string]interface{}{
+		"flow":        "f",
+		"timestamp":   "2022-06-01T12:00:00Z",
+		"frame":       "512 bytes, default rate",
+		"tx_pkts":     1000.0,
+		"rx_pkts":     900.0,
+		"loss_pct":    10.0,
+		"tx_rate_pps": 100.0,
+		"rx_rate_pps": 90.0,
+		"latency": map[string]interface{}{
+			"min_ns":    1000.0,
+			"avg_ns":    2000.0,
+			"max_ns":    4000.0,
+			"jitter_ns": 3000.0,
+		},
+		"outage_ms": 1.5,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("MarshalJSON() -want,+got:\n%s", diff)
+	}
+}
+
+func TestRetriedResultMarshalJSON(t *testing.T) {
+	first := newResult("f", 10000, 9990, 10*time.Second)
+	first.End = time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	r := newResult("f", 10000, 10000, 10*time.Second)
+	r.End = first.End.Add(time.Minute)
+	r.FirstAttempt = first
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		t.Fatalf("json.Marshal() got error: %v", err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("json.Unmarshal() got error: %v", err)
+	}
+	want := map[string]interface{}{
+		"flow":        "f",
+		"timestamp":   "2022-06-01T12:01:00Z",
+		"frame":       "default size, default rate",
+		"tx_pkts":     10000.0,
+		"rx_pkts":     10000.0,
+		"loss_pct":    0.0,
+		"tx_rate_pps": 1000.0,
+		"rx_rate_pps": 1000.0,
+		"retried":     true,
+		"first_attempt": map[string]interface{}{
+			"timestamp": "2022-06-01T12:00:00Z",
+			"tx_pkts":   10000.0,
+			"rx_pkts":   9990.0,
+			"loss_pct":  0.1,
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("MarshalJSON() -want,+got:\n%s", diff)
+	}
+}
+
+func TestConvergenceMarshalJSON(t *testing.T) {
+	c := &Convergence{
+		Event:     "withdraw",
+		Time:      time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC),
+		Dataplane: 250 * time.Millisecond,
+		RIB:       1500 * time.Microsecond,
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		t.Fatalf("json.Marshal() got error: %v", err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("json.Unmarshal() got error: %v", err)
+	}
+	want := map[string]interface{}{
+		"event":        "withdraw",
+		"timestamp":    "2022-06-01T12:00:00Z",
+		"dataplane_ms": 250.0,
+		"rib_ms":       1.5,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("MarshalJSON() -want,+got:\n%s", diff)
+	}
+}
+
+func TestRateMarshalJSON(t *testing.T) {
+	r := &Rate{
+		Flow:          "f",
+		Time:          time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC),
+		ConfiguredBps: 100000000,
+		MeasuredBps:   99500000,
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		t.Fatalf("json.Marshal() got error: %v", err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("json.Unmarshal() got error: %v", err)
+	}
+	want := map[string]interface{}{
+		"flow":           "f",
+		"timestamp":      "2022-06-01T12:00:00Z",
+		"configured_bps": 100000000.0,
+		"measured_bps":   99500000.0,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("MarshalJSON() -want,+got:\n%s", diff)
+	}
+}
+
+func TestResultsLog(t *testing.T) {
+	var l resultsLog
+	var mu sync.Mutex
+	written := make(map[string][]byte)
+	newFile := func() *resultsFile {
+		return &resultsFile{Testbed: "atedut_2.testbed", Deviations: map[string]string{"deviation_x": "true"}}
+	}
+
+	const n = 20
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			test := fmt.Sprintf("Test%d", i%2)
+			r := newResult(fmt.Sprintf("flow%d", i), 100, 100, time.Second)
+			if err := l.add(test, newFile, func(f *resultsFile) {
+				f.Results = append(f.Results, r)
+			}, func(content []byte) error {
+				mu.Lock()
+				defer mu.Unlock()
+				written[test] = content
+				return nil
+			}); err != nil {
+				t.Errorf("add() got error: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for _, test := range []string{"Test0", "Test1"} {
+		var got struct {
+			Test       string
+			Testbed    string
+			Deviations map[string]string
+			Results    []json.RawMessage
+		}
+		if err := json.Unmarshal(written[test], &got); err != nil {
+			t.Fatalf("Results file of %s got error: %v", test, err)
+		}
+		if got.Test != test || got.Testbed != "atedut_2.testbed" || got.Deviations["deviation_x"] != "true" {
+			t.Errorf("Results file of %s got test %q, testbed %q, deviations %v", test, got.Test, got.Testbed, got.Deviations)
+		}
+		if len(got.Results) != n/2 {
+			t.Errorf("Results file of %s got %d results, want %d", test, len(got.Results), n/2)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package traffic provides helpers to run and validate ATE traffic.
+package traffic
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/testt"
+)
+
+// Sample is a snapshot of the packet counters of a flow, and of the
+// octets it received.
+type Sample struct {
+	Time     time.Time
+	OutPkts  uint64
+	InPkts   uint64
+	InOctets uint64
+}
+
+// Interval is the change in the counters of a flow between two
+// consecutive samples.
+type Interval struct {
+	Start    time.Time
+	End      time.Time
+	OutPkts  uint64
+	InPkts   uint64
+	InOctets uint64
+}
+
+// LossPct returns the percentage of the packets sent during the
+// interval that were not received.  It is 0 if no packets were sent.
+func (i *Interval) LossPct() float64 {
+	if i.OutPkts == 0 || i.InPkts >= i.OutPkts {
+		return 0
+	}
+	return 100 * float64(i.OutPkts-i.InPkts) / float64(i.OutPkts)
+}
+
+// RxRateBps returns the rate in bits per second at which the flow was
+// received during the interval.
+func (i *Interval) RxRateBps() float64 {
+	d := i.End.Sub(i.Start)
+	if d <= 0 {
+		return 0
+	}
+	return float64(8*i.InOctets) / d.Seconds()
+}
+
+// Intervals returns the intervals between consecutive samples.
+// Counters that go backwards, e.g. because the flow was restarted,
+// are treated as an interval with no packets.
+func Intervals(samples []*Sample) []*Interval {
+	var intervals []*Interval
+	for i := 1; i < len(samples); i++ {
+		prev, cur := samples[i-1], samples[i]
+		iv := &Interval{Start: prev.Time, End: cur.Time}
+		if cur.OutPkts >= prev.OutPkts && cur.InPkts >= prev.InPkts && cur.InOctets >= prev.InOctets {
+			iv.OutPkts = cur.OutPkts - prev.OutPkts
+			iv.InPkts = cur.InPkts - prev.InPkts
+			iv.InOctets = cur.InOctets - prev.InOctets
+		}
+		intervals = append(intervals, iv)
+	}
+	return intervals
+}
+
+// MaxLoss returns the interval with the highest loss percentage, or nil
+// if there are no intervals.
+func MaxLoss(intervals []*Interval) *Interval {
+	var worst *Interval
+	for _, iv := range intervals {
+		if worst == nil || iv.LossPct() > worst.LossPct() {
+			worst = iv
+		}
+	}
+	return worst
+}
+
+// MedianRxRateBps returns the median of the receive rates of the
+// intervals during which the flow was transmitted, or 0 if there are
+// none.  Unlike the average rate of the whole flow, it is not skewed by
+// the intervals the flow started or stopped in, e.g. to measure the
+// rate a shaper limits the flow to.
+func MedianRxRateBps(intervals []*Interval) float64 {
+	var rates []float64
+	for _, iv := range intervals {
+		if iv.OutPkts > 0 {
+			rates = append(rates, iv.RxRateBps())
+		}
+	}
+	if len(rates) == 0 {
+		return 0
+	}
+	sort.Float64s(rates)
+	n := len(rates)
+	if n%2 == 1 {
+		return rates[n/2]
+	}
+	return (rates[n/2-1] + rates[n/2]) / 2
+}
+
+// Sampler periodically records the counters of a flow while it is
+// running, so that loss can be attributed to the time it occurred, and
+// the rate it was received at measured over time.
+type Sampler struct {
+	flowName string
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+	samples  []*Sample
+	// failed is the number of samples that could not be read, and err
+	// the error of the first of them.  They are reported by Stop, on
+	// the test goroutine.
+	failed int
+	err    string
+}
+
+// StartSampler starts recording the counters of the named flow every
+// interval until Stop is called.  The sampler is also stopped when the
+// test ends.
+func StartSampler(t testing.TB, ate *ondatra.ATEDevice, flowName string, interval time.Duration) *Sampler {
+	t.Helper()
+	counters := ate.Telemetry().Flow(flowName).Counters()
+	return startSampler(t, flowName, interval, func(t testing.TB) (*Sample, bool) {
+		q := counters.Lookup(t)
+		if !q.IsPresent() {
+			return nil, false
+		}
+		c := q.Val(t)
+		return &Sample{
+			OutPkts:  c.GetOutPkts(),
+			InPkts:   c.GetInPkts(),
+			InOctets: c.GetInOctets(),
+		}, true
+	})
+}
+
+// startSampler starts recording the samples read every interval until
+// Stop is called.  The reads run on the sampling goroutine, where a
+// test may not fail fatally, so their fatal failures are captured and
+// reported by Stop instead.
+func startSampler(t testing.TB, flowName string, interval time.Duration, read func(t testing.TB) (*Sample, bool)) *Sampler {
+	t.Helper()
+	s := &Sampler{
+		flowName: flowName,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case now := <-ticker.C:
+				var sample *Sample
+				var ok bool
+				if msg := testt.CaptureFatal(t, func(t testing.TB) {
+					sample, ok = read(t)
+				}); msg != nil {
+					if s.failed == 0 {
+						s.err = *msg
+					}
+					s.failed++
+					continue
+				}
+				if !ok {
+					continue
+				}
+				sample.Time = now
+				s.samples = append(s.samples, sample)
+			}
+		}
+	}()
+	t.Cleanup(func() { s.Stop(t) })
+	return s
+}
+
+// Stop stops recording and returns the samples recorded so far.  The
+// first call reports an error if some counters could not be read.
+func (s *Sampler) Stop(t testing.TB) []*Sample {
+	t.Helper()
+	s.stopOnce.Do(func() {
+		close(s.stop)
+		<-s.done
+		if s.failed > 0 {
+			t.Errorf("Cannot read %d counter samples of flow %s, first error: %s", s.failed, s.flowName, s.err)
+		}
+	})
+	<-s.done
+	return s.samples
+}
@@ -0,0 +1,148 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/openconfig/testt"
+)
+
+func TestIntervals(t *testing.T) {
+	t0 := time.Unix(0, 0)
+	at := func(sec int) time.Time { return t0.Add(time.Duration(sec) * time.Second) }
+
+	cases := []struct {
+		desc    string
+		samples []*Sample
+		want    []*Interval
+	}{{
+		desc: "none",
+	}, {
+		desc:    "single",
+		samples: []*Sample{{Time: at(0), OutPkts: 10, InPkts: 10}},
+	}, {
+		desc: "deltas",
+		samples: []*Sample{
+			{Time: at(0), OutPkts: 0, InPkts: 0},
+			{Time: at(1), OutPkts: 100, InPkts: 100},
+			{Time: at(2), OutPkts: 200, InPkts: 150},
+		},
+		want: []*Interval{
+			{Start: at(0), End: at(1), OutPkts: 100, InPkts: 100},
+			{Start: at(1), End: at(2), OutPkts: 100, InPkts: 50},
+		},
+	}, {
+		desc: "reset",
+		samples: []*Sample{
+			{Time: at(0), OutPkts: 100, InPkts: 100, InOctets: 10000},
+			{Time: at(1), OutPkts: 10, InPkts: 10, InOctets: 1000},
+			{Time: at(2), OutPkts: 20, InPkts: 20, InOctets: 2000},
+		},
+		want: []*Interval{
+			{Start: at(0), End: at(1)},
+			{Start: at(1), End: at(2), OutPkts: 10, InPkts: 10, InOctets: 1000},
+		},
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			got := Intervals(c.samples)
+			if diff := cmp.Diff(c.want, got); diff != "" {
+				t.Errorf("Intervals() -want,+got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMaxLoss(t *testing.T) {
+	intervals := []*Interval{
+		{OutPkts: 100, InPkts: 100},
+		{OutPkts: 100, InPkts: 40},
+		{OutPkts: 0, InPkts: 0},
+		{OutPkts: 100, InPkts: 90},
+		{OutPkts: 100, InPkts: 120},
+	}
+	got := MaxLoss(intervals)
+	if got != intervals[1] {
+		t.Errorf("MaxLoss() got %+v, want %+v", got, intervals[1])
+	}
+	if got, want := got.LossPct(), 60.0; got != want {
+		t.Errorf("LossPct() got %g, want %g", got, want)
+	}
+	if got := MaxLoss(nil); got != nil {
+		t.Errorf("MaxLoss(nil) got %+v, want nil", got)
+	}
+}
+
+func TestMedianRxRateBps(t *testing.T) {
+	t0 := time.Unix(0, 0)
+	iv := func(sec int, outPkts, inOctets uint64) *Interval {
+		start := t0.Add(time.Duration(sec) * time.Second)
+		return &Interval{Start: start, End: start.Add(time.Second), OutPkts: outPkts, InOctets: inOctets}
+	}
+	cases := []struct {
+		desc      string
+		intervals []*Interval
+		want      float64
+	}{{
+		desc: "none",
+	}, {
+		desc:      "odd",
+		intervals: []*Interval{iv(0, 10, 100), iv(1, 10, 1000), iv(2, 10, 1200)},
+		want:      8000,
+	}, {
+		desc:      "even",
+		intervals: []*Interval{iv(0, 10, 100), iv(1, 10, 1000), iv(2, 10, 1200), iv(3, 10, 1300)},
+		want:      8800,
+	}, {
+		desc:      "idle intervals",
+		intervals: []*Interval{iv(0, 0, 0), iv(1, 10, 1000), iv(2, 0, 500)},
+		want:      8000,
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			if got := MedianRxRateBps(c.intervals); got != c.want {
+				t.Errorf("MedianRxRateBps() got %g, want %g", got, c.want)
+			}
+		})
+	}
+}
+
+func TestSamplerFatalRead(t *testing.T) {
+	reads := 0
+	s := startSampler(t, "flow", time.Millisecond, func(t testing.TB) (*Sample, bool) {
+		reads++
+		if reads%2 == 0 {
+			t.Fatalf("counters unavailable")
+		}
+		return &Sample{OutPkts: uint64(reads)}, true
+	})
+	time.Sleep(20 * time.Millisecond)
+	var samples []*Sample
+	errs := testt.ExpectError(t, func(t testing.TB) {
+		samples = s.Stop(t)
+	})
+	if len(samples) == 0 {
+		t.Errorf("Stop() got no samples, want the samples read successfully")
+	}
+	if len(errs) != 1 {
+		t.Errorf("Stop() got errors %q, want one error for the fatal reads", errs)
+	}
+	if got := s.Stop(t); len(got) != len(samples) {
+		t.Errorf("Stop() again got %d samples, want %d", len(got), len(samples))
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"github.com/google/gopacket/layers"
+)
+
+// SFlowPort is the UDP port sFlow datagrams are sent to.
+const SFlowPort = 6343
+
+// SFlow is the decoded view of an sFlow version 5 datagram in a captured
+// packet.  Agent is the address of the agent that sent it, and
+// SequenceNumber the sequence number of the datagram, which the agent
+// increments with each datagram it sends to a collector.  Only the
+// number of counter samples is kept.  Decoding a datagram needs the
+// whole packet, e.g. captured with EnableFullCapture.
+type SFlow struct {
+	Agent          string
+	SequenceNumber uint32
+	FlowSamples    []*SFlowSample
+	CounterSamples int
+}
+
+// SFlowSample is the decoded view of a flow sample of an sFlow datagram.
+// SamplingRate is the 1-in-N rate the packet was sampled at, SamplePool
+// the number of packets the sampled interface could have sampled so far,
+// and Drops the number of samples the agent dropped for lack of
+// resources.  InputInterface and OutputInterface are the ifindexes the
+// packet was received on and sent to.  Packet is the decoded header of
+// the sampled packet, if the sample has a raw packet header record of
+// an Ethernet frame.
+type SFlowSample struct {
+	SamplingRate    uint32
+	SamplePool      uint32
+	Drops           uint32
+	InputInterface  uint32
+	OutputInterface uint32
+	Packet          *Packet
+}
+
+// decodeSFlow decodes an sFlow datagram.
+func decodeSFlow(l *layers.SFlowDatagram) *SFlow {
+	s := &SFlow{
+		SequenceNumber: l.SequenceNumber,
+		CounterSamples: len(l.CounterSamples),
+	}
+	if l.AgentAddress != nil {
+		s.Agent = l.AgentAddress.String()
+	}
+	for _, fs := range l.FlowSamples {
+		sample := &SFlowSample{
+			SamplingRate:    fs.SamplingRate,
+			SamplePool:      fs.SamplePool,
+			Drops:           fs.Dropped,
+			InputInterface:  fs.InputInterface,
+			OutputInterface: fs.OutputInterface,
+		}
+		for _, r := range fs.Records {
+			raw, ok := r.(layers.SFlowRawPacketFlowRecord)
+			if ok && raw.HeaderProtocol == layers.SFlowProtoEthernet && raw.Header != nil {
+				sample.Packet = decodePacket(raw.Header.Data())
+				break
+			}
+		}
+		s.FlowSamples = append(s.FlowSamples, sample)
+	}
+	return s
+}
@@ -0,0 +1,120 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// sflowWords encodes the 32-bit words of an sFlow structure.
+func sflowWords(words ...uint32) []byte {
+	var b []byte
+	for _, w := range words {
+		b = binary.BigEndian.AppendUint32(b, w)
+	}
+	return b
+}
+
+// sflowFlowSample encodes a flow sample of the sampled frame with a raw
+// packet header record, per sFlow version 5.
+func sflowFlowSample(rate, pool, input, output uint32, frame []byte) []byte {
+	header := append([]byte(nil), frame...)
+	for len(header)%4 != 0 {
+		header = append(header, 0)
+	}
+	record := append(sflowWords(uint32(layers.SFlowProtoEthernet), uint32(len(frame)+4), 4, uint32(len(frame))), header...)
+	record = append(sflowWords(uint32(layers.SFlowTypeRawPacketFlow), uint32(len(record))), record...)
+	sample := append(sflowWords(1, input, rate, pool, 0, input, output, 1), record...)
+	return append(sflowWords(uint32(layers.SFlowTypeFlowSample), uint32(len(sample))), sample...)
+}
+
+// sflowCounterSample encodes a counter sample without records.
+func sflowCounterSample(input uint32) []byte {
+	sample := sflowWords(1, input, 0)
+	return append(sflowWords(uint32(layers.SFlowTypeCounterSample), uint32(len(sample))), sample...)
+}
+
+// sflowDatagram encodes an sFlow version 5 datagram of the samples from
+// an IPv4 agent.
+func sflowDatagram(agent string, seq uint32, samples ...[]byte) []byte {
+	b := sflowWords(5, 1)
+	b = append(b, net.ParseIP(agent).To4()...)
+	b = append(b, sflowWords(0, seq, 1000, uint32(len(samples)))...)
+	for _, s := range samples {
+		b = append(b, s...)
+	}
+	return b
+}
+
+func TestDecodePCAPSFlow(t *testing.T) {
+	inner := &layers.IPv4{
+		Version:  4,
+		TTL:      64,
+		Protocol: layers.IPProtocolUDP,
+		SrcIP:    net.ParseIP("192.0.2.2"),
+		DstIP:    net.ParseIP("192.0.2.6"),
+	}
+	innerUDP := &layers.UDP{SrcPort: 1024, DstPort: 2048}
+	innerUDP.SetNetworkLayerForChecksum(inner)
+	sampled := serialize(t, inner, innerUDP, gopacket.Payload(make([]byte, 32)))
+
+	outer := &layers.IPv4{
+		Version:  4,
+		TTL:      64,
+		Protocol: layers.IPProtocolUDP,
+		SrcIP:    net.ParseIP("192.0.2.1"),
+		DstIP:    net.ParseIP("192.0.2.2"),
+	}
+	udp := &layers.UDP{SrcPort: 1024, DstPort: SFlowPort}
+	udp.SetNetworkLayerForChecksum(outer)
+	datagram := sflowDatagram("192.0.2.1", 7,
+		sflowFlowSample(1000, 5000, 3, 4, sampled),
+		sflowCounterSample(3),
+		sflowFlowSample(1000, 6000, 3, 4, sampled),
+	)
+
+	pkts, err := DecodePCAP(pcap(t, serialize(t, outer, udp, gopacket.Payload(datagram))))
+	if err != nil {
+		t.Fatalf("DecodePCAP() got error: %v", err)
+	}
+	s := pkts[0].SFlow
+	if s == nil {
+		t.Fatalf("DecodePCAP() got no sFlow datagram")
+	}
+	if s.Agent != "192.0.2.1" || s.SequenceNumber != 7 || s.CounterSamples != 1 {
+		t.Errorf("sFlow datagram got agent %s, sequence number %d, %d counter samples, want 192.0.2.1, 7, 1", s.Agent, s.SequenceNumber, s.CounterSamples)
+	}
+	if len(s.FlowSamples) != 2 {
+		t.Fatalf("sFlow datagram got %d flow samples, want 2", len(s.FlowSamples))
+	}
+	for i, pool := range []uint32{5000, 6000} {
+		fs := s.FlowSamples[i]
+		if fs.SamplingRate != 1000 || fs.SamplePool != pool || fs.InputInterface != 3 || fs.OutputInterface != 4 {
+			t.Errorf("flow sample %d got rate %d, pool %d, interfaces %d -> %d, want 1000, %d, 3 -> 4", i, fs.SamplingRate, fs.SamplePool, fs.InputInterface, fs.OutputInterface, pool)
+		}
+		if fs.Packet == nil || fs.Packet.Outer() == nil {
+			t.Errorf("flow sample %d got no sampled IP header", i)
+			continue
+		}
+		if got := fs.Packet.Outer(); got.Src != "192.0.2.2" || got.Dst != "192.0.2.6" {
+			t.Errorf("flow sample %d sampled packet got %s -> %s, want 192.0.2.2 -> 192.0.2.6", i, got.Src, got.Dst)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"strconv"
+	"strings"
+)
+
+// SyslogPort is the UDP port syslog messages are sent to, per RFC 5426.
+const SyslogPort = 514
+
+// Syslog is the decoded view of a syslog message in a captured packet,
+// in either the format of RFC 5424 or the BSD format of RFC 3164, which
+// both start with the priority.  Facility and Severity are the numeric
+// codes of the priority, e.g. 23 for local7 and 5 for notice, and
+// Message is the rest of the message, which the capture may truncate.
+type Syslog struct {
+	Facility uint8
+	Severity uint8
+	Message  string
+}
+
+// decodeSyslog decodes the UDP payload of a syslog message, or returns
+// nil if it does not start with a valid priority.
+func decodeSyslog(b []byte) *Syslog {
+	s := string(b)
+	if !strings.HasPrefix(s, "<") {
+		return nil
+	}
+	end := strings.IndexByte(s, '>')
+	// The priority has one to three digits, without leading zeros
+	// except for the priority 0 itself.
+	if end < 2 || end > 4 || (s[1] == '0' && end > 2) {
+		return nil
+	}
+	pri, err := strconv.ParseUint(s[1:end], 10, 8)
+	if err != nil || pri > 191 {
+		return nil
+	}
+	return &Syslog{
+		Facility: uint8(pri / 8),
+		Severity: uint8(pri % 8),
+		Message:  strings.TrimRight(s[end+1:], "\x00\r\n"),
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func TestDecodeSyslog(t *testing.T) {
+	tests := []struct {
+		desc string
+		msg  string
+		want *Syslog
+	}{{
+		desc: "RFC 5424",
+		msg:  "<189>1 2022-10-11T22:14:15.003Z dut - - - - Interface Ethernet2 down",
+		want: &Syslog{Facility: 23, Severity: 5, Message: "1 2022-10-11T22:14:15.003Z dut - - - - Interface Ethernet2 down"},
+	}, {
+		desc: "RFC 3164",
+		msg:  "<13>Oct 11 22:14:15 dut link down\n",
+		want: &Syslog{Facility: 1, Severity: 5, Message: "Oct 11 22:14:15 dut link down"},
+	}, {
+		desc: "zero priority",
+		msg:  "<0>kernel panic",
+		want: &Syslog{Facility: 0, Severity: 0, Message: "kernel panic"},
+	}, {
+		desc: "no priority",
+		msg:  "link down",
+	}, {
+		desc: "leading zero",
+		msg:  "<013>link down",
+	}, {
+		desc: "priority too high",
+		msg:  "<192>link down",
+	}, {
+		desc: "unterminated priority",
+		msg:  "<13 link down",
+	}, {
+		desc: "empty priority",
+		msg:  "<>link down",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, decodeSyslog([]byte(tt.msg))); diff != "" {
+				t.Errorf("decodeSyslog(%q) -want,+got:\n%s", tt.msg, diff)
+			}
+		})
+	}
+}
+
+func TestDecodePCAPSyslog(t *testing.T) {
+	ip := &layers.IPv4{
+		Version:  4,
+		TTL:      64,
+		Protocol: layers.IPProtocolUDP,
+		SrcIP:    net.ParseIP("192.0.2.1"),
+		DstIP:    net.ParseIP("192.0.2.2"),
+	}
+	syslog := &layers.UDP{SrcPort: 1024, DstPort: SyslogPort}
+	syslog.SetNetworkLayerForChecksum(ip)
+	other := &layers.UDP{SrcPort: 1024, DstPort: 2048}
+	other.SetNetworkLayerForChecksum(ip)
+	payload := gopacket.Payload("<189>link down")
+
+	pkts, err := DecodePCAP(pcap(t,
+		serialize(t, ip, syslog, payload),
+		serialize(t, ip, other, payload),
+	))
+	if err != nil {
+		t.Fatalf("DecodePCAP() got error: %v", err)
+	}
+	if len(pkts) != 2 {
+		t.Fatalf("DecodePCAP() got %d packets, want 2", len(pkts))
+	}
+	want := &Syslog{Facility: 23, Severity: 5, Message: "link down"}
+	if diff := cmp.Diff(want, pkts[0].Syslog); diff != "" {
+		t.Errorf("syslog packet -want,+got:\n%s", diff)
+	}
+	if pkts[1].Syslog != nil {
+		t.Errorf("packet to port 2048 got syslog %+v, want nil", pkts[1].Syslog)
+	}
+}
@@ -0,0 +1,449 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/ondatra"
+)
+
+const (
+	// DefaultMinTxPkts is the number of packets transmitted before a
+	// flow is stopped if Options.MinTxPkts is not set.
+	DefaultMinTxPkts = 10000
+	// DefaultMaxDuration is the maximum time a flow runs while waiting
+	// for MinTxPkts if Options.MaxDuration is not set.
+	DefaultMaxDuration = 15 * time.Second
+	// txPollInterval is how often the counters are read while waiting
+	// for a flow to transmit MinTxPkts packets.
+	txPollInterval = 500 * time.Millisecond
+	// stablePollInterval is how often the counters are read while
+	// waiting for them to stabilize after a flow is stopped.  It is
+	// longer than the ATE statistics refresh period, so that two
+	// identical reads mean that the counters stopped incrementing.
+	stablePollInterval = 2 * time.Second
+	// stableTimeout is the maximum time to wait for the counters to
+	// stabilize after a flow is stopped.
+	stableTimeout = 30 * time.Second
+	// DefaultMinOutPkts is the minimum number of packets a flow must
+	// transmit to be validated if Options.MinOutPkts is not set.
+	DefaultMinOutPkts = 1000
+	// DefaultSuspectLossPct is the loss percentage below which a flow is
+	// retried with Options.Retry if Options.SuspectLossPct is not set.
+	DefaultSuspectLossPct = 1
+	// retrySettle is how long to wait after the counters of a suspect
+	// flow stabilized before rerunning it, so that late ATE statistics
+	// updates of the first attempt do not leak into the second.
+	retrySettle = 5 * time.Second
+)
+
+// Options configure how a flow is run and validated.  The zero value
+// runs the flow until DefaultMinTxPkts packets are transmitted or
+// DefaultMaxDuration elapses, requires at least DefaultMinOutPkts
+// packets to be transmitted, and allows no loss beyond the
+// deviation_traffic_loss_tolerance.
+type Options struct {
+	// Duration, if set, runs the flow for a fixed time instead of until
+	// MinTxPkts packets are transmitted.
+	Duration time.Duration
+	// MinTxPkts is the number of packets to transmit before stopping
+	// the flow.
+	MinTxPkts uint64
+	// MaxDuration is the maximum time the flow runs while waiting for
+	// MinTxPkts packets to be transmitted.
+	MaxDuration time.Duration
+	// MinOutPkts is the minimum number of packets the flow must
+	// transmit for ValidateFlow to consider its loss meaningful.  Unlike
+	// MinTxPkts, it does not affect how long the flow runs.
+	MinOutPkts uint64
+	// LossTolerance is the maximum loss percentage allowed by
+	// ValidateFlow.  If nil, deviation_traffic_loss_tolerance is used,
+	// so a test can require no loss regardless of the deviation with
+	// ygot.Float64(0).
+	LossTolerance *float64
+	// WantLoss makes ValidateFlow expect all packets to be lost rather
+	// than received.
+	WantLoss bool
+	// MaxAvgLatency and MaxJitter, if set, are the maximum average
+	// latency and jitter of the received packets.  Latency tracking is
+	// enabled on the flow as needed.  If the ATE cannot measure the
+	// latency of the flow, the latency checks are skipped with a
+	// warning.
+	MaxAvgLatency time.Duration
+	MaxJitter     time.Duration
+	// Retry makes ValidateFlow rerun a flow once if its loss exceeds the
+	// loss tolerance but is below SuspectLossPct, which is typical of
+	// ATE statistics races rather than of forwarding failures.  The flow
+	// then only fails if the second attempt also exceeds the tolerance.
+	// A flow that received no packets is never retried.
+	Retry bool
+	// SuspectLossPct is the loss percentage below which Retry reruns a
+	// flow.  If zero, DefaultSuspectLossPct is used.
+	SuspectLossPct float64
+}
+
+func (o *Options) minOutPkts() uint64 {
+	if o == nil || o.MinOutPkts == 0 {
+		return DefaultMinOutPkts
+	}
+	return o.MinOutPkts
+}
+
+func (o *Options) minTxPkts() uint64 {
+	if o == nil || o.MinTxPkts == 0 {
+		return DefaultMinTxPkts
+	}
+	return o.MinTxPkts
+}
+
+func (o *Options) maxDuration() time.Duration {
+	if o == nil || o.MaxDuration == 0 {
+		return DefaultMaxDuration
+	}
+	return o.MaxDuration
+}
+
+func (o *Options) suspectLossPct() float64 {
+	if o == nil || o.SuspectLossPct == 0 {
+		return DefaultSuspectLossPct
+	}
+	return o.SuspectLossPct
+}
+
+func (o *Options) lossTolerance() float64 {
+	if o == nil || o.LossTolerance == nil {
+		return *deviations.TrafficLossTolerance
+	}
+	return *o.LossTolerance
+}
+
+// Result is the outcome of running a flow.
+type Result struct {
+	Flow    string
+	OutPkts uint64
+	InPkts  uint64
+	// LossPct is the percentage of the transmitted packets that were
+	// not received.
+	LossPct float64
+	// Elapsed is the time between starting and stopping the flow.
+	Elapsed time.Duration
+	// Frame is the frame size, rate and count the flow was created
+	// with by the flow builders of this package.
+	Frame Frame
+	// Latency is the latency of the received packets, or nil if it was
+	// not measured.
+	Latency *Latency
+	// Outage is the longest outage of the traffic of a background flow,
+	// or nil if it was not measured.
+	Outage *Outage
+	// End is the time the flow was stopped.
+	End time.Time
+	// FirstAttempt is the result of the first attempt of a flow that
+	// was rerun because of Options.Retry, or nil if it was not.
+	FirstAttempt *Result
+}
+
+// newResult computes the result of a flow from its packet counters.
+func newResult(flow string, outPkts, inPkts uint64, elapsed time.Duration) *Result {
+	r := &Result{Flow: flow, OutPkts: outPkts, InPkts: inPkts, Elapsed: elapsed}
+	if outPkts > 0 && inPkts < outPkts {
+		r.LossPct = 100 * float64(outPkts-inPkts) / float64(outPkts)
+	}
+	return r
+}
+
+// TxRate returns the average rate in packets per second at which the
+// flow was transmitted.
+func (r *Result) TxRate() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.OutPkts) / r.Elapsed.Seconds()
+}
+
+// RxRate returns the average rate in packets per second at which the
+// flow was received.
+func (r *Result) RxRate() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.InPkts) / r.Elapsed.Seconds()
+}
+
+// RunFlow runs the flow and returns its result without validating it.
+// The flow runs for opts.Duration if set, and otherwise until it has
+// transmitted opts.MinTxPkts packets, or the Frame.Count it was created
+// with, or opts.MaxDuration elapses.
+// After stopping the flow, RunFlow waits for its counters to stabilize
+// so that packets still in flight are not counted as lost.  opts may
+// be nil.
+func RunFlow(t testing.TB, ate *ondatra.ATEDevice, flow *ondatra.Flow, opts *Options) *Result {
+	t.Helper()
+	return RunFlows(t, ate, []*ondatra.Flow{flow}, opts)[0]
+}
+
+// RunFlows runs the flows together as RunFlow does, waiting for every
+// flow to transmit its packets, and returns their results in the same
+// order without validating them.  The flows may share endpoints, since
+// their packets are counted per flow.  The results are recorded with
+// RecordResult.
+func RunFlows(t testing.TB, ate *ondatra.ATEDevice, flows []*ondatra.Flow, opts *Options) []*Result {
+	t.Helper()
+	defer fptest.TimeStep(t, "run traffic")()
+	results := runFlows(t, ate, flows, opts)
+	for _, r := range results {
+		RecordResult(t, r)
+	}
+	return results
+}
+
+// runFlows runs the flows as RunFlows does, without recording their
+// results.  Starting the flows clears their ATE counters.
+func runFlows(t testing.TB, ate *ondatra.ATEDevice, flows []*ondatra.Flow, opts *Options) []*Result {
+	t.Helper()
+	want := make(map[string]uint64)
+	frames := make(map[string]Frame)
+	for _, f := range flows {
+		frames[f.Name()] = flowFrame(f.Name())
+		want[f.Name()] = opts.minTxPkts()
+		if c := frames[f.Name()].Count; c > 0 {
+			want[f.Name()] = uint64(c)
+		}
+	}
+
+	start := time.Now()
+	ate.Traffic().Start(t, flows...)
+	if opts != nil && opts.Duration > 0 {
+		time.Sleep(opts.Duration)
+	} else if !pollUntil(txPollInterval, opts.maxDuration(), func() bool {
+		return allSent(readFlowPkts(t, ate), want)
+	}) {
+		got := readFlowPkts(t, ate)
+		for _, f := range flows {
+			if n := want[f.Name()]; got[f.Name()].out < n {
+				t.Logf("Flow %s did not transmit %d packets within %v", f.Name(), n, opts.maxDuration())
+			}
+		}
+	}
+	ate.Traffic().Stop(t)
+	elapsed := time.Since(start)
+
+	if !pollUntil(stablePollInterval, stableTimeout, stable(func() (uint64, uint64) {
+		return sumPkts(readFlowPkts(t, ate), want)
+	})) {
+		t.Logf("Counters of %d flows did not stabilize within %v after they were stopped", len(flows), stableTimeout)
+	}
+
+	got := readFlowPkts(t, ate)
+	var results []*Result
+	for _, f := range flows {
+		p := got[f.Name()]
+		r := newResult(f.Name(), p.out, p.in, elapsed)
+		r.Frame = frames[f.Name()]
+		r.End = start.Add(elapsed)
+		t.Logf("Flow %s (%v) sent %d packets (%.1f pps) and received %d packets (%.1f pps), loss %.3f%%",
+			r.Flow, r.Frame, r.OutPkts, r.TxRate(), r.InPkts, r.RxRate(), r.LossPct)
+		results = append(results, r)
+	}
+	return results
+}
+
+// ValidateFlow runs the flow as RunFlow does, and reports an error if
+// it transmitted fewer than opts.MinOutPkts packets, if it received
+// more packets than it transmitted, if the loss exceeds the loss
+// tolerance in opts, or if the latency or jitter exceed their maximum
+// in opts.  The ATE flow telemetry does not report latency, so latency
+// checks are only performed by ValidateOTGFlow.  opts may be nil.
+func ValidateFlow(t testing.TB, ate *ondatra.ATEDevice, flow *ondatra.Flow, opts *Options) *Result {
+	t.Helper()
+	return ValidateFlows(t, ate, []*ondatra.Flow{flow}, opts)[0]
+}
+
+// ValidateFlows runs the flows together as RunFlows does, and validates
+// each of them as ValidateFlow does, reporting every failure of every
+// flow.  With opts.Retry, the suspect flows are rerun together once,
+// and their second attempt is validated instead.  opts may be nil.
+func ValidateFlows(t testing.TB, ate *ondatra.ATEDevice, flows []*ondatra.Flow, opts *Options) []*Result {
+	t.Helper()
+	defer fptest.TimeStep(t, "validate traffic")()
+	results := runFlows(t, ate, flows, opts)
+	var suspect []int
+	var retry []*ondatra.Flow
+	for i, r := range results {
+		if r.suspect(opts) {
+			logSuspect(t, r, opts)
+			suspect = append(suspect, i)
+			retry = append(retry, flows[i])
+		}
+	}
+	if len(retry) > 0 {
+		time.Sleep(retrySettle)
+		for j, r := range runFlows(t, ate, retry, opts) {
+			i := suspect[j]
+			results[i] = retried(t, results[i], r)
+		}
+	}
+	for _, r := range results {
+		RecordResult(t, r)
+		warnLatency(t, r, opts)
+		for _, err := range r.validate(opts) {
+			t.Error(err)
+		}
+	}
+	return results
+}
+
+// warnLatency logs a warning if opts requests latency checks but the
+// latency of the flow was not measured.
+func warnLatency(t testing.TB, r *Result, opts *Options) {
+	t.Helper()
+	if opts.wantLatency() && r.Latency == nil {
+		t.Logf("WARNING: the ATE did not measure the latency of flow %s, skipping latency checks", r.Flow)
+	}
+}
+
+// validate returns the errors found in the result according to opts.
+func (r *Result) validate(opts *Options) []error {
+	var errs []error
+	if min := opts.minOutPkts(); r.OutPkts < min {
+		errs = append(errs, fmt.Errorf("flow %s sent %d packets and received %d packets, want at least %d sent", r.Flow, r.OutPkts, r.InPkts, min))
+	}
+	if r.InPkts > r.OutPkts {
+		errs = append(errs, fmt.Errorf("flow %s sent %d packets and received %d packets, want no duplicated packets", r.Flow, r.OutPkts, r.InPkts))
+	}
+	if opts != nil && opts.WantLoss {
+		if r.InPkts > 0 {
+			errs = append(errs, fmt.Errorf("flow %s sent %d packets and received %d packets, want all lost", r.Flow, r.OutPkts, r.InPkts))
+		}
+		return errs
+	}
+	if tolerance := opts.lossTolerance(); r.LossPct > tolerance {
+		errs = append(errs, fmt.Errorf("flow %s sent %d packets and received %d packets, LossPct got %g, want at most %g", r.Flow, r.OutPkts, r.InPkts, r.LossPct, tolerance))
+	}
+	if r.Latency != nil && opts != nil {
+		if max := opts.MaxAvgLatency; max > 0 && r.Latency.Avg > max {
+			errs = append(errs, fmt.Errorf("flow %s average latency got %v, want at most %v", r.Flow, r.Latency.Avg, max))
+		}
+		if max := opts.MaxJitter; max > 0 && r.Latency.Jitter > max {
+			errs = append(errs, fmt.Errorf("flow %s jitter got %v, want at most %v", r.Flow, r.Latency.Jitter, max))
+		}
+	}
+	return errs
+}
+
+// suspect returns whether the flow is retried according to opts, i.e.
+// whether its loss exceeds the loss tolerance but is below the suspect
+// loss, without any other failure that a second attempt would not fix.
+func (r *Result) suspect(opts *Options) bool {
+	if opts == nil || !opts.Retry || opts.WantLoss {
+		return false
+	}
+	if r.InPkts == 0 || r.InPkts > r.OutPkts || r.OutPkts < opts.minOutPkts() {
+		return false
+	}
+	return r.LossPct > opts.lossTolerance() && r.LossPct < opts.suspectLossPct()
+}
+
+// logSuspect logs that the flow is retried.
+func logSuspect(t testing.TB, r *Result, opts *Options) {
+	t.Helper()
+	t.Logf("Flow %s loss %.3f%% exceeds the tolerance of %g%% but is below %g%%, rerunning it once in %v",
+		r.Flow, r.LossPct, opts.lossTolerance(), opts.suspectLossPct(), retrySettle)
+}
+
+// retried returns the second attempt of the flow, with its first
+// attempt, and logs both.
+func retried(t testing.TB, first, second *Result) *Result {
+	t.Helper()
+	second.FirstAttempt = first
+	t.Logf("Flow %s retried: first attempt sent %d packets and received %d packets, loss %.3f%%; second attempt sent %d packets and received %d packets, loss %.3f%%",
+		second.Flow, first.OutPkts, first.InPkts, first.LossPct, second.OutPkts, second.InPkts, second.LossPct)
+	return second
+}
+
+// pollUntil calls done every interval until it returns true or the
+// timeout elapses, and returns whether done returned true.
+func pollUntil(interval, timeout time.Duration, done func() bool) bool {
+	for deadline := time.Now().Add(timeout); ; {
+		if done() {
+			return true
+		}
+		if !time.Now().Add(interval).Before(deadline) {
+			return false
+		}
+		time.Sleep(interval)
+	}
+}
+
+// flowPkts are the packet counters of a flow.
+type flowPkts struct {
+	out, in uint64
+}
+
+// readFlowPkts returns the packet counters of the flows reported by the
+// ATE, by flow name.  Reading all the flows at once keeps the polling
+// of many flows as cheap as that of one.
+func readFlowPkts(t testing.TB, ate *ondatra.ATEDevice) map[string]flowPkts {
+	t.Helper()
+	pkts := make(map[string]flowPkts)
+	for _, q := range ate.Telemetry().FlowAny().Lookup(t) {
+		if !q.IsPresent() {
+			continue
+		}
+		f := q.Val(t)
+		pkts[f.GetName()] = flowPkts{out: f.GetCounters().GetOutPkts(), in: f.GetCounters().GetInPkts()}
+	}
+	return pkts
+}
+
+// allSent returns whether every flow in want transmitted at least the
+// wanted number of packets.
+func allSent(got map[string]flowPkts, want map[string]uint64) bool {
+	for name, n := range want {
+		if got[name].out < n {
+			return false
+		}
+	}
+	return true
+}
+
+// sumPkts returns the total packet counters of the flows in want.
+// Since the counters only increase, the sums are unchanged only if every
+// counter is unchanged.
+func sumPkts(got map[string]flowPkts, want map[string]uint64) (out, in uint64) {
+	for name := range want {
+		out += got[name].out
+		in += got[name].in
+	}
+	return out, in
+}
+
+// stable returns a function for pollUntil that returns true once read
+// returns the same counters twice in a row.
+func stable(read func() (uint64, uint64)) func() bool {
+	var lastOut, lastIn uint64
+	first := true
+	return func() bool {
+		out, in := read()
+		same := !first && out == lastOut && in == lastIn
+		lastOut, lastIn, first = out, in, false
+		return same
+	}
+}
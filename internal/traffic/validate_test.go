@@ -0,0 +1,355 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/ygot/ygot"
+)
+
+func TestNewResult(t *testing.T) {
+	cases := []struct {
+		desc    string
+		outPkts uint64
+		inPkts  uint64
+		elapsed time.Duration
+		want    *Result
+	}{{
+		desc: "nothing sent",
+		want: &Result{Flow: "f"},
+	}, {
+		desc:    "no loss",
+		outPkts: 1000,
+		inPkts:  1000,
+		elapsed: 10 * time.Second,
+		want:    &Result{Flow: "f", OutPkts: 1000, InPkts: 1000, Elapsed: 10 * time.Second},
+	}, {
+		desc:    "loss",
+		outPkts: 1000,
+		inPkts:  750,
+		elapsed: 10 * time.Second,
+		want:    &Result{Flow: "f", OutPkts: 1000, InPkts: 750, LossPct: 25, Elapsed: 10 * time.Second},
+	}, {
+		desc:    "all lost",
+		outPkts: 1000,
+		elapsed: 10 * time.Second,
+		want:    &Result{Flow: "f", OutPkts: 1000, LossPct: 100, Elapsed: 10 * time.Second},
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			got := newResult("f", c.outPkts, c.inPkts, c.elapsed)
+			if diff := cmp.Diff(c.want, got); diff != "" {
+				t.Errorf("newResult() -want,+got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestResultRates(t *testing.T) {
+	r := newResult("f", 1000, 500, 10*time.Second)
+	if got, want := r.TxRate(), 100.0; got != want {
+		t.Errorf("TxRate() got %g, want %g", got, want)
+	}
+	if got, want := r.RxRate(), 50.0; got != want {
+		t.Errorf("RxRate() got %g, want %g", got, want)
+	}
+	if got := (&Result{OutPkts: 1000}).TxRate(); got != 0 {
+		t.Errorf("TxRate() with no elapsed time got %g, want 0", got)
+	}
+}
+
+func TestOptionsDefaults(t *testing.T) {
+	var nilOpts *Options
+	if got := nilOpts.minTxPkts(); got != DefaultMinTxPkts {
+		t.Errorf("minTxPkts() of nil Options got %d, want %d", got, DefaultMinTxPkts)
+	}
+	if got := nilOpts.maxDuration(); got != DefaultMaxDuration {
+		t.Errorf("maxDuration() of nil Options got %v, want %v", got, DefaultMaxDuration)
+	}
+	opts := &Options{MinTxPkts: 100, MaxDuration: time.Second}
+	if got, want := opts.minTxPkts(), uint64(100); got != want {
+		t.Errorf("minTxPkts() got %d, want %d", got, want)
+	}
+	if got, want := opts.maxDuration(), time.Second; got != want {
+		t.Errorf("maxDuration() got %v, want %v", got, want)
+	}
+}
+
+func TestPollUntil(t *testing.T) {
+	calls := 0
+	if !pollUntil(time.Millisecond, time.Second, func() bool {
+		calls++
+		return calls == 3
+	}) {
+		t.Errorf("pollUntil() got false, want true")
+	}
+	if calls != 3 {
+		t.Errorf("pollUntil() called done %d times, want 3", calls)
+	}
+
+	if pollUntil(time.Millisecond, 10*time.Millisecond, func() bool { return false }) {
+		t.Errorf("pollUntil() with done never true got true, want false")
+	}
+}
+
+func TestStable(t *testing.T) {
+	reads := [][2]uint64{{10, 5}, {20, 15}, {30, 30}, {30, 30}}
+	i := 0
+	done := stable(func() (uint64, uint64) {
+		r := reads[i]
+		i++
+		return r[0], r[1]
+	})
+	var got []bool
+	for range reads {
+		got = append(got, done())
+	}
+	if want := []bool{false, false, false, true}; !cmp.Equal(got, want) {
+		t.Errorf("stable() got %v, want %v", got, want)
+	}
+}
+
+func TestResultValidate(t *testing.T) {
+	cases := []struct {
+		desc    string
+		outPkts uint64
+		inPkts  uint64
+		latency *Latency
+		opts    *Options
+		wantErr int
+	}{{
+		desc:    "no loss",
+		outPkts: 10000,
+		inPkts:  10000,
+	}, {
+		desc:    "nothing sent",
+		wantErr: 1,
+	}, {
+		desc:    "too few sent",
+		outPkts: 999,
+		inPkts:  999,
+		wantErr: 1,
+	}, {
+		desc:    "fewer sent than custom minimum",
+		outPkts: 999,
+		inPkts:  999,
+		opts:    &Options{MinOutPkts: 100},
+	}, {
+		desc:    "loss",
+		outPkts: 10000,
+		inPkts:  9000,
+		wantErr: 1,
+	}, {
+		desc:    "loss within tolerance",
+		outPkts: 10000,
+		inPkts:  9000,
+		opts:    &Options{LossTolerance: ygot.Float64(10)},
+	}, {
+		desc:    "duplicated",
+		outPkts: 10000,
+		inPkts:  10001,
+		wantErr: 1,
+	}, {
+		desc:    "want loss",
+		outPkts: 10000,
+		opts:    &Options{WantLoss: true},
+	}, {
+		desc:    "want loss but received",
+		outPkts: 10000,
+		inPkts:  1,
+		opts:    &Options{WantLoss: true},
+		wantErr: 1,
+	}, {
+		desc:    "want loss but nothing sent",
+		opts:    &Options{WantLoss: true},
+		wantErr: 1,
+	}, {
+		desc:    "latency within bounds",
+		outPkts: 10000,
+		inPkts:  10000,
+		latency: newLatency(10000, 20000, 30000),
+		opts:    &Options{MaxAvgLatency: 50 * time.Microsecond, MaxJitter: 50 * time.Microsecond},
+	}, {
+		desc:    "latency and jitter exceeded",
+		outPkts: 10000,
+		inPkts:  10000,
+		latency: newLatency(10000, 80000, 100000),
+		opts:    &Options{MaxAvgLatency: 50 * time.Microsecond, MaxJitter: 50 * time.Microsecond},
+		wantErr: 2,
+	}, {
+		desc:    "latency not measured",
+		outPkts: 10000,
+		inPkts:  10000,
+		opts:    &Options{MaxAvgLatency: time.Nanosecond},
+	}, {
+		desc:    "latency not requested",
+		outPkts: 10000,
+		inPkts:  10000,
+		latency: newLatency(10000, 80000, 100000),
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			r := newResult("f", c.outPkts, c.inPkts, time.Second)
+			r.Latency = c.latency
+			if got := r.validate(c.opts); len(got) != c.wantErr {
+				t.Errorf("validate() got errors %v, want %d errors", got, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestLossTolerance(t *testing.T) {
+	defer func(v float64) { *deviations.TrafficLossTolerance = v }(*deviations.TrafficLossTolerance)
+	*deviations.TrafficLossTolerance = 2
+
+	cases := []struct {
+		desc string
+		opts *Options
+		want float64
+	}{{
+		desc: "nil options",
+		want: 2,
+	}, {
+		desc: "unset",
+		opts: &Options{},
+		want: 2,
+	}, {
+		desc: "explicit zero",
+		opts: &Options{LossTolerance: ygot.Float64(0)},
+		want: 0,
+	}, {
+		desc: "explicit",
+		opts: &Options{LossTolerance: ygot.Float64(0.5)},
+		want: 0.5,
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			if got := c.opts.lossTolerance(); got != c.want {
+				t.Errorf("lossTolerance() got %g, want %g", got, c.want)
+			}
+		})
+	}
+}
+
+func TestAllSent(t *testing.T) {
+	want := map[string]uint64{"a": 100, "b": 200}
+	cases := []struct {
+		desc string
+		got  map[string]flowPkts
+		want bool
+	}{{
+		desc: "all sent",
+		got:  map[string]flowPkts{"a": {out: 100}, "b": {out: 250}, "other": {}},
+		want: true,
+	}, {
+		desc: "one short",
+		got:  map[string]flowPkts{"a": {out: 100}, "b": {out: 199}},
+	}, {
+		desc: "one missing",
+		got:  map[string]flowPkts{"a": {out: 100}},
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			if got := allSent(c.got, want); got != c.want {
+				t.Errorf("allSent() got %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestSumPkts(t *testing.T) {
+	got := map[string]flowPkts{
+		"a":     {out: 100, in: 90},
+		"b":     {out: 200, in: 200},
+		"other": {out: 1000, in: 1000},
+	}
+	out, in := sumPkts(got, map[string]uint64{"a": 1, "b": 1, "missing": 1})
+	if out != 300 || in != 290 {
+		t.Errorf("sumPkts() got (%d, %d), want (300, 290)", out, in)
+	}
+}
+
+func TestResultSuspect(t *testing.T) {
+	retry := &Options{Retry: true}
+	cases := []struct {
+		desc    string
+		outPkts uint64
+		inPkts  uint64
+		opts    *Options
+		want    bool
+	}{{
+		desc:    "small loss",
+		outPkts: 10000,
+		inPkts:  9990,
+		opts:    retry,
+		want:    true,
+	}, {
+		desc:    "retry not enabled",
+		outPkts: 10000,
+		inPkts:  9990,
+	}, {
+		desc:    "no loss",
+		outPkts: 10000,
+		inPkts:  10000,
+		opts:    retry,
+	}, {
+		desc:    "loss within tolerance",
+		outPkts: 10000,
+		inPkts:  9990,
+		opts:    &Options{Retry: true, LossTolerance: ygot.Float64(0.5)},
+	}, {
+		desc:    "loss at suspect threshold",
+		outPkts: 10000,
+		inPkts:  9900,
+		opts:    retry,
+	}, {
+		desc:    "loss below custom suspect threshold",
+		outPkts: 10000,
+		inPkts:  9900,
+		opts:    &Options{Retry: true, SuspectLossPct: 5},
+		want:    true,
+	}, {
+		desc:    "nothing received",
+		outPkts: 10000,
+		opts:    &Options{Retry: true, SuspectLossPct: 100},
+	}, {
+		desc:    "too few sent",
+		outPkts: 999,
+		inPkts:  998,
+		opts:    retry,
+	}, {
+		desc:    "duplicated",
+		outPkts: 10000,
+		inPkts:  10001,
+		opts:    retry,
+	}, {
+		desc:    "want loss",
+		outPkts: 10000,
+		inPkts:  9990,
+		opts:    &Options{Retry: true, WantLoss: true},
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			r := newResult("f", c.outPkts, c.inPkts, 10*time.Second)
+			if got := r.suspect(c.opts); got != c.want {
+				t.Errorf("suspect() with loss %g%% got %t, want %t", r.LossPct, got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package transfer streams content to the DUT in chunks over gNOI RPCs
+// such as File.Put and OS.Install, and computes and verifies the hashes
+// of the content sent and received.
+package transfer
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"io"
+
+	tpb "github.com/openconfig/gnoi/types"
+)
+
+// ChunkSize is the largest chunk of content sent in a single gNOI
+// message.  The gNOI SetPackage operation sets the maximum chunk size
+// at 64K, and the other operations are assumed to allow the same size.
+const ChunkSize = 64 * 1024
+
+// NewHash returns a hash computing the given gNOI hash method.
+func NewHash(method tpb.HashType_HashMethod) (hash.Hash, error) {
+	switch method {
+	case tpb.HashType_MD5:
+		return md5.New(), nil
+	case tpb.HashType_SHA256:
+		return sha256.New(), nil
+	case tpb.HashType_SHA512:
+		return sha512.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported hash method %v", method)
+	}
+}
+
+// Send reads r until EOF, calls send with each chunk of at most
+// chunkSize bytes read, and returns the hash of the content sent
+// computed with the given method.  With HashType_UNSPECIFIED, for
+// operations that have no hash to verify, the content is not hashed
+// and Send returns a nil hash.  The chunks passed to send are only
+// valid until it returns.
+func Send(r io.Reader, chunkSize int, method tpb.HashType_HashMethod, send func([]byte) error) (*tpb.HashType, error) {
+	var h hash.Hash
+	if method != tpb.HashType_UNSPECIFIED {
+		var err error
+		if h, err = NewHash(method); err != nil {
+			return nil, err
+		}
+	}
+	buf := make([]byte, chunkSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			if h != nil {
+				h.Write(buf[:n])
+			}
+			if err := send(buf[:n]); err != nil {
+				return nil, err
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if h == nil {
+		return nil, nil
+	}
+	return &tpb.HashType{Method: method, Hash: h.Sum(nil)}, nil
+}
+
+// Verify returns an error unless want is the hash of the content
+// written to h, computed with the same method.
+func Verify(h hash.Hash, method tpb.HashType_HashMethod, want *tpb.HashType) error {
+	if want.GetMethod() != method {
+		return fmt.Errorf("hash method got %v, want %v", want.GetMethod(), method)
+	}
+	if got := h.Sum(nil); !bytes.Equal(got, want.GetHash()) {
+		return fmt.Errorf("%v hash got %x, want %x", method, got, want.GetHash())
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transfer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	tpb "github.com/openconfig/gnoi/types"
+)
+
+func TestSend(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 25)
+	sum := sha256.Sum256(content)
+	for _, tc := range []struct {
+		desc      string
+		chunkSize int
+		want      []int
+	}{
+		{"smaller chunks", 100, []int{100, 100, 50}},
+		{"exact chunks", 50, []int{50, 50, 50, 50, 50}},
+		{"single chunk", ChunkSize, []int{250}},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			var sizes []int
+			var sent []byte
+			got, err := Send(bytes.NewReader(content), tc.chunkSize, tpb.HashType_SHA256, func(b []byte) error {
+				sizes = append(sizes, len(b))
+				sent = append(sent, b...)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Send() got error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, sizes); diff != "" {
+				t.Errorf("Send() chunk sizes -want,+got:\n%s", diff)
+			}
+			if !bytes.Equal(sent, content) {
+				t.Errorf("Send() sent %q, want %q", sent, content)
+			}
+			if got.GetMethod() != tpb.HashType_SHA256 || !bytes.Equal(got.GetHash(), sum[:]) {
+				t.Errorf("Send() got hash %v, want SHA256 %x", got, sum)
+			}
+		})
+	}
+}
+
+func TestSendUnhashed(t *testing.T) {
+	content := []byte("content")
+	var sent []byte
+	got, err := Send(bytes.NewReader(content), ChunkSize, tpb.HashType_UNSPECIFIED, func(b []byte) error {
+		sent = append(sent, b...)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Send() got error: %v", err)
+	}
+	if got != nil {
+		t.Errorf("Send() with unspecified hash method got hash %v, want nil", got)
+	}
+	if !bytes.Equal(sent, content) {
+		t.Errorf("Send() sent %q, want %q", sent, content)
+	}
+}
+
+func TestSendErrors(t *testing.T) {
+	content := []byte("content")
+	if _, err := Send(bytes.NewReader(content), ChunkSize, tpb.HashType_HashMethod(-1), func([]byte) error { return nil }); err == nil {
+		t.Errorf("Send() with unsupported hash method got no error, want error")
+	}
+	sendErr := errors.New("send failed")
+	if _, err := Send(bytes.NewReader(content), ChunkSize, tpb.HashType_MD5, func([]byte) error { return sendErr }); err != sendErr {
+		t.Errorf("Send() got error %v, want %v", err, sendErr)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	content := []byte("content")
+	want, err := Send(bytes.NewReader(content), ChunkSize, tpb.HashType_MD5, func([]byte) error { return nil })
+	if err != nil {
+		t.Fatalf("Send() got error: %v", err)
+	}
+	for _, tc := range []struct {
+		desc    string
+		content string
+		method  tpb.HashType_HashMethod
+		wantErr bool
+	}{
+		{"same content", "content", tpb.HashType_MD5, false},
+		{"other content", "other", tpb.HashType_MD5, true},
+		{"other method", "content", tpb.HashType_SHA512, true},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			h, err := NewHash(tc.method)
+			if err != nil {
+				t.Fatalf("NewHash() got error: %v", err)
+			}
+			h.Write([]byte(tc.content))
+			if err := Verify(h, tc.method, want); (err != nil) != tc.wantErr {
+				t.Errorf("Verify() got error %v, want error %v", err, tc.wantErr)
+			}
+		})
+	}
+}
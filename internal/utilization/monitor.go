@@ -0,0 +1,234 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utilization
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/components"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/ondatra"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// outputSuffix is the suffix of the utilization output of a test.
+const outputSuffix = ".utilization.json"
+
+// leaf is a utilization leaf of a component, and where to store its
+// value in a sample.
+type leaf struct {
+	component string
+	elems     []string
+	field     func(*Sample) **uint64
+}
+
+// path returns the gNMI path of the leaf.
+func (l *leaf) path() *gpb.Path {
+	p := &gpb.Path{Elem: []*gpb.PathElem{
+		{Name: "components"},
+		{Name: "component", Key: map[string]string{"name": l.component}},
+	}}
+	for _, e := range l.elems {
+		p.Elem = append(p.Elem, &gpb.PathElem{Name: e})
+	}
+	return p
+}
+
+// leaves returns the utilization leaves of the CPU and memory components.
+func leaves(cpu, memory []string) []*leaf {
+	var ls []*leaf
+	for _, c := range cpu {
+		ls = append(ls, &leaf{c, []string{"cpu", "utilization", "state", "instant"}, func(s *Sample) **uint64 { return &s.CPU }})
+	}
+	for _, c := range memory {
+		ls = append(ls,
+			&leaf{c, []string{"state", "memory", "utilized"}, func(s *Sample) **uint64 { return &s.MemoryUtilized }},
+			&leaf{c, []string{"state", "memory", "available"}, func(s *Sample) **uint64 { return &s.MemoryAvailable }},
+		)
+	}
+	return ls
+}
+
+// Monitor samples the utilization of the DUT in the background between
+// Start and Stop.
+type Monitor struct {
+	dut    *ondatra.DUTDevice
+	client gpb.GNMIClient
+	leaves []*leaf
+	opts   *Options
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu      sync.Mutex
+	samples []*Sample
+	errs    map[string]error // last Get error by component
+}
+
+// Report is the utilization output of a test section.
+type Report struct {
+	Test       string            `json:"test"`
+	Vendor     string            `json:"vendor"`
+	Model      string            `json:"model"`
+	Version    string            `json:"version"`
+	Deviations map[string]string `json:"deviations"`
+	IntervalS  float64           `json:"interval_s"`
+	Samples    []*Sample         `json:"samples"`
+	Violations []*Violation      `json:"violations"`
+}
+
+// Start starts sampling the CPU and memory utilization of the DUT every
+// opts.Interval, until Stop is called.  The components sampled are the
+// ones named in opts, or else the components of the type the DUT vendor
+// reports the utilization under.  opts may be nil.
+func Start(t *testing.T, dut *ondatra.DUTDevice, opts *Options) *Monitor {
+	t.Helper()
+	var cpu, memory []string
+	if opts != nil {
+		cpu, memory = opts.CPUComponents, opts.MemoryComponents
+	}
+	ct := typesOf(dut.Vendor())
+	if len(cpu) == 0 {
+		cpu = components.FindComponentsByType(t, dut, ct.cpu)
+	}
+	if len(memory) == 0 {
+		memory = components.FindComponentsByType(t, dut, ct.memory)
+	}
+	if len(cpu) == 0 && len(memory) == 0 {
+		t.Logf("No %v or %v components found, utilization is not sampled", ct.cpu, ct.memory)
+	} else {
+		t.Logf("Sampling CPU utilization of %v and memory utilization of %v every %v", cpu, memory, opts.interval())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &Monitor{
+		dut:    dut,
+		client: dut.RawAPIs().GNMI().Default(t),
+		leaves: leaves(cpu, memory),
+		opts:   opts,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		errs:   make(map[string]error),
+	}
+	go m.run(ctx)
+	return m
+}
+
+// run samples the utilization every interval until ctx is done.
+func (m *Monitor) run(ctx context.Context) {
+	defer close(m.done)
+	ticker := time.NewTicker(m.opts.interval())
+	defer ticker.Stop()
+	for {
+		m.sample(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sample gets the utilization leaves, and records a sample per
+// component.  Leaves the DUT fails to return are left out of the sample.
+func (m *Monitor) sample(ctx context.Context) {
+	now := time.Now()
+	byComponent := make(map[string]*Sample)
+	errs := make(map[string]error)
+	for _, l := range m.leaves {
+		resp, err := m.client.Get(ctx, &gpb.GetRequest{
+			Path:     []*gpb.Path{l.path()},
+			Type:     gpb.GetRequest_STATE,
+			Encoding: gpb.Encoding_JSON_IETF,
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			errs[l.component] = err
+			continue
+		}
+		s, ok := byComponent[l.component]
+		if !ok {
+			s = &Sample{Time: now, Component: l.component}
+			byComponent[l.component] = s
+		}
+		for _, n := range resp.GetNotification() {
+			for _, u := range n.GetUpdate() {
+				if v, ok := uintValue(u.GetVal()); ok {
+					*l.field(s) = &v
+				}
+			}
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(byComponent))
+	for name := range byComponent {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		m.samples = append(m.samples, byComponent[name])
+	}
+	for c, err := range errs {
+		m.errs[c] = err
+	}
+}
+
+// Stop stops sampling, writes the samples to the utilization output of
+// the test, and reports a test error for each component whose
+// utilization stayed above a threshold of opts for longer than the grace
+// period.
+func (m *Monitor) Stop(t *testing.T) *Report {
+	t.Helper()
+	m.cancel()
+	<-m.done
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := &Report{
+		Test:       t.Name(),
+		Vendor:     m.dut.Vendor().String(),
+		Model:      m.dut.Model(),
+		Version:    m.dut.Version(),
+		Deviations: deviations.Active(),
+		IntervalS:  m.opts.interval().Seconds(),
+		Samples:    m.samples,
+		Violations: violations(m.samples, m.opts),
+	}
+	for c, err := range m.errs {
+		t.Logf("Could not get utilization of component %s: %v", c, err)
+	}
+	for c, p := range peaks(m.samples) {
+		t.Logf("Component %s peak utilization: %v", c, p)
+	}
+	if content, err := json.MarshalIndent(r, "", "  "); err != nil {
+		t.Errorf("Cannot marshal utilization report: %v", err)
+	} else if err := fptest.WriteOutput(t.Name(), outputSuffix, string(content)); err != nil {
+		t.Errorf("Cannot write utilization report: %v", err)
+	}
+	for _, v := range r.Violations {
+		t.Errorf("DUT %v", v)
+	}
+	return r
+}
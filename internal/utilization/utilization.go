@@ -0,0 +1,296 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package utilization samples the CPU and memory utilization of the DUT
+// from the components telemetry while a test section runs, e.g. while
+// programming gRIBI entries at scale, to tell whether the control plane
+// keeps up.  The samples are written to a test output, and utilization
+// that stays above a threshold for longer than a grace period fails the
+// test.
+//
+// Vendors report utilization under components of different types, so
+// the components sampled are found by a per-vendor component type,
+// unless the test names them.
+package utilization
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/telemetry"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+const (
+	// DefaultInterval is how often the utilization is sampled if
+	// Options.Interval is not set.
+	DefaultInterval = 10 * time.Second
+	// DefaultGrace is how long the utilization may stay above a
+	// threshold if Options.Grace is not set.
+	DefaultGrace = time.Minute
+)
+
+// Options configure the monitor.  A nil *Options uses the defaults,
+// which only record the utilization.
+type Options struct {
+	// Interval is how often to sample the utilization.
+	Interval time.Duration
+	// CPUThreshold and MemoryThreshold are the utilization in percent
+	// above which a component may only stay for the grace period, or
+	// zero to only record the utilization.
+	CPUThreshold    float64
+	MemoryThreshold float64
+	// Grace is how long the utilization may stay above a threshold.
+	Grace time.Duration
+	// CPUComponents and MemoryComponents name the components whose CPU
+	// and memory utilization to sample, instead of the components of the
+	// type the vendor reports them under.
+	CPUComponents    []string
+	MemoryComponents []string
+}
+
+func (o *Options) interval() time.Duration {
+	if o == nil || o.Interval == 0 {
+		return DefaultInterval
+	}
+	return o.Interval
+}
+
+func (o *Options) grace() time.Duration {
+	if o == nil || o.Grace == 0 {
+		return DefaultGrace
+	}
+	return o.Grace
+}
+
+// componentTypes are the types of the components a vendor reports the
+// CPU and the memory utilization under.
+type componentTypes struct {
+	cpu, memory telemetry.E_PlatformTypes_OPENCONFIG_HARDWARE_COMPONENT
+}
+
+// vendorTypes maps the vendors to the types of the components they
+// report utilization under.  Other vendors use defaultTypes.
+var vendorTypes = map[ondatra.Vendor]componentTypes{
+	ondatra.ARISTA: {
+		cpu:    telemetry.PlatformTypes_OPENCONFIG_HARDWARE_COMPONENT_CPU,
+		memory: telemetry.PlatformTypes_OPENCONFIG_HARDWARE_COMPONENT_CHASSIS,
+	},
+	ondatra.CISCO: {
+		cpu:    telemetry.PlatformTypes_OPENCONFIG_HARDWARE_COMPONENT_CPU,
+		memory: telemetry.PlatformTypes_OPENCONFIG_HARDWARE_COMPONENT_CONTROLLER_CARD,
+	},
+	ondatra.JUNIPER: {
+		cpu:    telemetry.PlatformTypes_OPENCONFIG_HARDWARE_COMPONENT_CONTROLLER_CARD,
+		memory: telemetry.PlatformTypes_OPENCONFIG_HARDWARE_COMPONENT_CONTROLLER_CARD,
+	},
+	ondatra.NOKIA: {
+		cpu:    telemetry.PlatformTypes_OPENCONFIG_HARDWARE_COMPONENT_CONTROLLER_CARD,
+		memory: telemetry.PlatformTypes_OPENCONFIG_HARDWARE_COMPONENT_CONTROLLER_CARD,
+	},
+}
+
+var defaultTypes = componentTypes{
+	cpu:    telemetry.PlatformTypes_OPENCONFIG_HARDWARE_COMPONENT_CPU,
+	memory: telemetry.PlatformTypes_OPENCONFIG_HARDWARE_COMPONENT_CPU,
+}
+
+// typesOf returns the types of the components the vendor reports
+// utilization under.
+func typesOf(v ondatra.Vendor) componentTypes {
+	if ct, ok := vendorTypes[v]; ok {
+		return ct
+	}
+	return defaultTypes
+}
+
+// Sample is the utilization of a component at a time.  Leaves the
+// component did not report are nil.
+type Sample struct {
+	Time      time.Time `json:"time"`
+	Component string    `json:"component"`
+	// CPU is the instantaneous CPU utilization in percent.
+	CPU *uint64 `json:"cpu_pct,omitempty"`
+	// MemoryUtilized and MemoryAvailable are in bytes.
+	MemoryUtilized  *uint64 `json:"memory_utilized,omitempty"`
+	MemoryAvailable *uint64 `json:"memory_available,omitempty"`
+}
+
+// cpu returns the CPU utilization of the sample in percent, if reported.
+func (s *Sample) cpu() (float64, bool) {
+	if s.CPU == nil {
+		return 0, false
+	}
+	return float64(*s.CPU), true
+}
+
+// memory returns the memory utilization of the sample in percent, if
+// both the utilized and the available memory are reported.
+func (s *Sample) memory() (float64, bool) {
+	if s.MemoryUtilized == nil || s.MemoryAvailable == nil {
+		return 0, false
+	}
+	total := *s.MemoryUtilized + *s.MemoryAvailable
+	if total == 0 {
+		return 0, false
+	}
+	return 100 * float64(*s.MemoryUtilized) / float64(total), true
+}
+
+// metrics are the utilization metrics, by name.
+var metrics = []struct {
+	name  string
+	value func(*Sample) (float64, bool)
+}{
+	{"cpu", (*Sample).cpu},
+	{"memory", (*Sample).memory},
+}
+
+// Violation is a period the utilization of a component stayed above
+// its threshold for longer than the grace period.
+type Violation struct {
+	Component string    `json:"component"`
+	Metric    string    `json:"metric"`
+	Threshold float64   `json:"threshold_pct"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Peak      float64   `json:"peak_pct"`
+}
+
+func (v *Violation) String() string {
+	return fmt.Sprintf("component %s %s utilization above %g%% for %v from %v, peak %.1f%%",
+		v.Component, v.Metric, v.Threshold, v.End.Sub(v.Start), v.Start.Format(time.RFC3339), v.Peak)
+}
+
+// sustained returns the periods the metric value of each component
+// stayed above the threshold for longer than the grace period, by
+// component and time.  A period spans the samples above the threshold
+// up to the next sample at or below it; samples without a value neither
+// end nor extend it.
+func sustained(samples []*Sample, metric string, value func(*Sample) (float64, bool), threshold float64, grace time.Duration) []*Violation {
+	byComponent := make(map[string][]*Sample)
+	for _, s := range samples {
+		byComponent[s.Component] = append(byComponent[s.Component], s)
+	}
+	names := make([]string, 0, len(byComponent))
+	for name := range byComponent {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var violations []*Violation
+	for _, name := range names {
+		ss := byComponent[name]
+		sort.SliceStable(ss, func(i, j int) bool { return ss[i].Time.Before(ss[j].Time) })
+		var cur *Violation
+		end := func() {
+			if cur != nil && cur.End.Sub(cur.Start) > grace {
+				violations = append(violations, cur)
+			}
+			cur = nil
+		}
+		for _, s := range ss {
+			v, ok := value(s)
+			switch {
+			case !ok:
+			case v <= threshold:
+				end()
+			case cur == nil:
+				cur = &Violation{Component: name, Metric: metric, Threshold: threshold, Start: s.Time, End: s.Time, Peak: v}
+			default:
+				cur.End = s.Time
+				if v > cur.Peak {
+					cur.Peak = v
+				}
+			}
+		}
+		end()
+	}
+	return violations
+}
+
+// violations returns the violations of the thresholds in opts by the
+// samples.
+func violations(samples []*Sample, opts *Options) []*Violation {
+	if opts == nil {
+		return nil
+	}
+	var vs []*Violation
+	for _, m := range metrics {
+		threshold := opts.CPUThreshold
+		if m.name == "memory" {
+			threshold = opts.MemoryThreshold
+		}
+		if threshold == 0 {
+			continue
+		}
+		vs = append(vs, sustained(samples, m.name, m.value, threshold, opts.grace())...)
+	}
+	return vs
+}
+
+// peaks returns the highest value of each metric of each component, by
+// component and metric name.
+func peaks(samples []*Sample) map[string]map[string]float64 {
+	p := make(map[string]map[string]float64)
+	for _, s := range samples {
+		for _, m := range metrics {
+			v, ok := m.value(s)
+			if !ok {
+				continue
+			}
+			if p[s.Component] == nil {
+				p[s.Component] = make(map[string]float64)
+			}
+			if old, ok := p[s.Component][m.name]; !ok || v > old {
+				p[s.Component][m.name] = v
+			}
+		}
+	}
+	return p
+}
+
+// uintValue returns the value of a numeric leaf.  Leaves encoded as
+// JSON may hold the number as a string, as JSON_IETF does for 64-bit
+// integers.
+func uintValue(v *gpb.TypedValue) (uint64, bool) {
+	switch v.GetValue().(type) {
+	case *gpb.TypedValue_UintVal:
+		return v.GetUintVal(), true
+	case *gpb.TypedValue_IntVal:
+		if n := v.GetIntVal(); n >= 0 {
+			return uint64(n), true
+		}
+	case *gpb.TypedValue_JsonIetfVal, *gpb.TypedValue_JsonVal:
+		b := v.GetJsonIetfVal()
+		if b == nil {
+			b = v.GetJsonVal()
+		}
+		var s string
+		if err := json.Unmarshal(b, &s); err == nil {
+			n, err := strconv.ParseUint(s, 10, 64)
+			return n, err == nil
+		}
+		var n uint64
+		if err := json.Unmarshal(b, &n); err == nil {
+			return n, true
+		}
+	}
+	return 0, false
+}
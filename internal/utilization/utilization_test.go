@@ -0,0 +1,163 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utilization
+
+import (
+	"testing"
+	"time"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+func u64(n uint64) *uint64 { return &n }
+
+var t0 = time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)
+
+// cpuSamples returns samples of the component every 10 seconds from t0
+// with the CPU utilization values, where a negative value is not reported.
+func cpuSamples(component string, values ...int) []*Sample {
+	var ss []*Sample
+	for i, v := range values {
+		s := &Sample{Time: t0.Add(time.Duration(i) * 10 * time.Second), Component: component}
+		if v >= 0 {
+			s.CPU = u64(uint64(v))
+		}
+		ss = append(ss, s)
+	}
+	return ss
+}
+
+func TestSustained(t *testing.T) {
+	cases := []struct {
+		desc    string
+		samples []*Sample
+		want    []time.Duration // the durations of the violations
+	}{{
+		desc:    "below",
+		samples: cpuSamples("cpu0", 10, 50, 80, 10),
+	}, {
+		desc:    "brief spike",
+		samples: cpuSamples("cpu0", 10, 95, 95, 10, 95, 10),
+	}, {
+		desc:    "sustained",
+		samples: cpuSamples("cpu0", 10, 95, 95, 95, 95, 10),
+		want:    []time.Duration{30 * time.Second},
+	}, {
+		desc:    "sustained until the end",
+		samples: cpuSamples("cpu0", 10, 95, 95, 95, 95),
+		want:    []time.Duration{30 * time.Second},
+	}, {
+		desc:    "missing values do not end a period",
+		samples: cpuSamples("cpu0", 95, -1, -1, 95),
+		want:    []time.Duration{30 * time.Second},
+	}, {
+		desc:    "at the threshold ends a period",
+		samples: cpuSamples("cpu0", 95, 95, 90, 95, 95),
+	}, {
+		desc:    "per component",
+		samples: append(cpuSamples("cpu0", 95, 10, 95, 10), cpuSamples("cpu1", 95, 95, 95, 95)...),
+		want:    []time.Duration{30 * time.Second},
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			got := sustained(c.samples, "cpu", (*Sample).cpu, 90, 20*time.Second)
+			if len(got) != len(c.want) {
+				t.Fatalf("sustained got %v, want %d violations", got, len(c.want))
+			}
+			for i, v := range got {
+				if d := v.End.Sub(v.Start); d != c.want[i] {
+					t.Errorf("sustained violation %d duration got %v, want %v", i, d, c.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestViolations(t *testing.T) {
+	samples := cpuSamples("cpu0", 95, 95, 95, 95)
+	for _, s := range samples {
+		s.MemoryUtilized, s.MemoryAvailable = u64(95), u64(5)
+	}
+	cases := []struct {
+		desc    string
+		opts    *Options
+		metrics []string
+	}{
+		{"no options", nil, nil},
+		{"no thresholds", &Options{}, nil},
+		{"cpu", &Options{CPUThreshold: 90, Grace: 20 * time.Second}, []string{"cpu"}},
+		{"memory", &Options{MemoryThreshold: 90, Grace: 20 * time.Second}, []string{"memory"}},
+		{"both", &Options{CPUThreshold: 90, MemoryThreshold: 90, Grace: 20 * time.Second}, []string{"cpu", "memory"}},
+		{"default grace", &Options{CPUThreshold: 90, MemoryThreshold: 90}, nil},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			got := violations(samples, c.opts)
+			if len(got) != len(c.metrics) {
+				t.Fatalf("violations got %v, want metrics %v", got, c.metrics)
+			}
+			for i, v := range got {
+				if v.Metric != c.metrics[i] {
+					t.Errorf("violations metric %d got %s, want %s", i, v.Metric, c.metrics[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMemory(t *testing.T) {
+	cases := []struct {
+		desc   string
+		sample *Sample
+		want   float64
+		wantOK bool
+	}{
+		{"both", &Sample{MemoryUtilized: u64(25), MemoryAvailable: u64(75)}, 25, true},
+		{"no available", &Sample{MemoryUtilized: u64(25)}, 0, false},
+		{"no utilized", &Sample{MemoryAvailable: u64(75)}, 0, false},
+		{"zero", &Sample{MemoryUtilized: u64(0), MemoryAvailable: u64(0)}, 0, false},
+	}
+	for _, c := range cases {
+		got, ok := c.sample.memory()
+		if got != c.want || ok != c.wantOK {
+			t.Errorf("%s: memory got %g, %t, want %g, %t", c.desc, got, ok, c.want, c.wantOK)
+		}
+	}
+}
+
+func TestUintValue(t *testing.T) {
+	cases := []struct {
+		desc   string
+		val    *gpb.TypedValue
+		want   uint64
+		wantOK bool
+	}{
+		{"uint", &gpb.TypedValue{Value: &gpb.TypedValue_UintVal{UintVal: 42}}, 42, true},
+		{"int", &gpb.TypedValue{Value: &gpb.TypedValue_IntVal{IntVal: 42}}, 42, true},
+		{"negative int", &gpb.TypedValue{Value: &gpb.TypedValue_IntVal{IntVal: -1}}, 0, false},
+		{"json ietf string", &gpb.TypedValue{Value: &gpb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`"8589934592"`)}}, 8589934592, true},
+		{"json ietf number", &gpb.TypedValue{Value: &gpb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`42`)}}, 42, true},
+		{"json number", &gpb.TypedValue{Value: &gpb.TypedValue_JsonVal{JsonVal: []byte(`42`)}}, 42, true},
+		{"json object", &gpb.TypedValue{Value: &gpb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`{"instant": 42}`)}}, 0, false},
+		{"string", &gpb.TypedValue{Value: &gpb.TypedValue_StringVal{StringVal: "42"}}, 0, false},
+		{"nil", nil, 0, false},
+	}
+	for _, c := range cases {
+		got, ok := uintValue(c.val)
+		if got != c.want || ok != c.wantOK {
+			t.Errorf("%s: uintValue got %d, %t, want %d, %t", c.desc, got, ok, c.want, c.wantOK)
+		}
+	}
+}
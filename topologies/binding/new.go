@@ -56,6 +56,15 @@ var (
 //
 // For more detail about how to write a plugin, see: https://pkg.go.dev/plugin
 func New() (binding.Binding, error) {
+	b, err := newBinding()
+	if err != nil {
+		return nil, err
+	}
+	return &otgBind{Binding: b}, nil
+}
+
+// newBinding creates the binding selected by the command line flags.
+func newBinding() (binding.Binding, error) {
 	if *pluginFile != "" {
 		return loadBinding(*pluginFile, *pluginArgs)
 	}
@@ -0,0 +1,95 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binding
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/openconfig/ondatra/binding"
+
+	opb "github.com/openconfig/ondatra/proto"
+)
+
+// otgBind wraps a binding to record the ATEs of its reservation, so
+// that the raw OTG API of an ATE can be fetched by OTG.  Ondatra does
+// not expose the raw OTG API, e.g. for packet captures.
+type otgBind struct {
+	binding.Binding
+}
+
+var (
+	otgMu   sync.Mutex
+	otgATEs = make(map[string]binding.ATE)
+	otgAPIs = make(map[string]gosnappi.GosnappiApi)
+)
+
+func (b *otgBind) Reserve(ctx context.Context, tb *opb.Testbed, runTime, waitTime time.Duration, partial map[string]string) (*binding.Reservation, error) {
+	resv, err := b.Binding.Reserve(ctx, tb, runTime, waitTime, partial)
+	if err != nil {
+		return nil, err
+	}
+	recordATEs(resv)
+	return resv, nil
+}
+
+func (b *otgBind) FetchReservation(ctx context.Context, id string) (*binding.Reservation, error) {
+	resv, err := b.Binding.FetchReservation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	recordATEs(resv)
+	return resv, nil
+}
+
+func (b *otgBind) Release(ctx context.Context) error {
+	otgMu.Lock()
+	otgATEs = make(map[string]binding.ATE)
+	otgAPIs = make(map[string]gosnappi.GosnappiApi)
+	otgMu.Unlock()
+	return b.Binding.Release(ctx)
+}
+
+// recordATEs records the ATEs of the reservation by name.
+func recordATEs(resv *binding.Reservation) {
+	otgMu.Lock()
+	defer otgMu.Unlock()
+	for _, ate := range resv.ATEs {
+		otgATEs[ate.Name()] = ate
+	}
+}
+
+// OTG returns the raw OTG API of the named ATE of the reservation,
+// dialing it on first use.
+func OTG(ctx context.Context, name string) (gosnappi.GosnappiApi, error) {
+	otgMu.Lock()
+	defer otgMu.Unlock()
+	if api, ok := otgAPIs[name]; ok {
+		return api, nil
+	}
+	ate, ok := otgATEs[name]
+	if !ok {
+		return nil, fmt.Errorf("ATE %q is not reserved through the featureprofiles binding", name)
+	}
+	api, err := ate.DialOTG(ctx)
+	if err != nil {
+		return nil, err
+	}
+	otgAPIs[name] = api
+	return api, nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binding
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openconfig/ondatra/binding"
+	opb "github.com/openconfig/ondatra/proto"
+)
+
+// fakeBind reserves a single ATE that cannot dial OTG.
+type fakeBind struct {
+	binding.Binding
+	resv *binding.Reservation
+}
+
+func (b *fakeBind) Reserve(context.Context, *opb.Testbed, time.Duration, time.Duration, map[string]string) (*binding.Reservation, error) {
+	return b.resv, nil
+}
+
+func (b *fakeBind) Release(context.Context) error {
+	return nil
+}
+
+func TestOTG(t *testing.T) {
+	ctx := context.Background()
+	b := &otgBind{Binding: &fakeBind{resv: &binding.Reservation{
+		ATEs: map[string]binding.ATE{
+			"ate": &binding.AbstractATE{Dims: &binding.Dims{Name: "ate.name"}},
+		},
+	}}}
+
+	if _, err := OTG(ctx, "ate.name"); err == nil || !strings.Contains(err.Error(), "not reserved") {
+		t.Errorf("OTG() before reservation got error %v, want not reserved", err)
+	}
+	if _, err := b.Reserve(ctx, &opb.Testbed{}, 0, 0, nil); err != nil {
+		t.Fatalf("Could not reserve testbed: %v", err)
+	}
+	if _, err := OTG(ctx, "ate.name"); err == nil || !strings.Contains(err.Error(), "DialOTG unimplemented") {
+		t.Errorf("OTG() got error %v, want the DialOTG error of the ATE", err)
+	}
+	if err := b.Release(ctx); err != nil {
+		t.Fatalf("Could not release reservation: %v", err)
+	}
+	if _, err := OTG(ctx, "ate.name"); err == nil || !strings.Contains(err.Error(), "not reserved") {
+		t.Errorf("OTG() after release got error %v, want not reserved", err)
+	}
+}